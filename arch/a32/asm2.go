@@ -1,11 +1,49 @@
 package a32
 
 import (
+	"fmt"
 	"strings"
 
+	"github.com/rj45/nanogo/asm2"
 	"github.com/rj45/nanogo/ir2"
 )
 
 func (cpuArch) Asm(op ir2.Op, defs []string, args []string) string {
-	return op.String() + " " + strings.Join(defs, ", ") + " " + strings.Join(args, ", ")
+	switch op {
+	case LD, LD8, LD16:
+		return fmt.Sprintf("%s %s, [%s + %s]", op.(Opcode).Asm(), defs[0], args[0], args[1])
+	case ST, ST8, ST16:
+		return fmt.Sprintf("%s [%s + %s], %s", op.(Opcode).Asm(), args[0], args[1], args[2])
+	case CALL:
+		if _, err := RegString(args[0]); err == nil {
+			// func values point to a closure object which starts
+			// with the address of the code
+			return "call [" + args[0] + "]"
+		}
+		return "call " + args[0]
+	case RET:
+		// the arg slots are part of the caller's frame, so there are none to pop
+		return "ret 0"
+	case ERR:
+		return "brk\nerr"
+	case BR_EQ, BR_NEQ, BR_U_L, BR_U_LE, BR_U_GE, BR_U_G, BR_S_L, BR_S_LE, BR_S_GE, BR_S_G:
+		// the false branch falls through to the next block
+		return fmt.Sprintf("cmp %s, %s\n%s %s", args[0], args[1], op.(Opcode).Asm(), args[2])
+	default:
+		name := op.String()
+		if opcode, ok := op.(Opcode); ok {
+			name = opcode.Asm()
+		}
+		if len(defs) > 0 && len(args) > 0 {
+			return name + " " + strings.Join(defs, ", ") + ", " + strings.Join(args, ", ")
+		} else if len(defs) > 0 {
+			return name + " " + strings.Join(defs, ", ")
+		}
+		return name + " " + strings.Join(args, ", ")
+	}
+}
+
+func (cpuArch) DataSection() asm2.Section {
+	// a32 has no separate data bank, so initialized data goes with the code
+	return asm2.Code
 }
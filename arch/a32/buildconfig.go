@@ -1,13 +1,18 @@
 package a32
 
+import (
+	"io"
+
+	"github.com/rj45/nanogo/compiler"
+)
+
+// maxCycles stops programs that never halt
+const maxCycles = 1000000000
+
 func (cpuArch) AssemblerFormat() string {
 	return "binary"
 }
 
-func (cpuArch) EmulatorCmd() string {
-	return "a32emu"
-}
-
-func (cpuArch) EmulatorArgs() []string {
-	return []string{"--headless", "--max-cycles", "1000000000", "--rom"}
+func (cpuArch) NewEmulator(out, trace io.Writer) compiler.Emulator {
+	return &Emulator{Out: out, Trace: trace, MaxCycles: maxCycles}
 }
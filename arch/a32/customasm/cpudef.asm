@@ -65,6 +65,11 @@
     SHL  {d: reg}, {l: reg}, {r: reg} => le(0`10 @ {r} @ {l} @ {d} @ 0x8 @ 0b001)
     ASR  {d: reg}, {l: reg}, {r: reg} => le(0`10 @ {r} @ {l} @ {d} @ 0x9 @ 0b001)
     LSR  {d: reg}, {l: reg}, {r: reg} => le(0`10 @ {r} @ {l} @ {d} @ 0xA @ 0b001)
+    MUL  {d: reg}, {l: reg}, {r: reg} => le(0`10 @ {r} @ {l} @ {d} @ 0xB @ 0b001)
+    DIV  {d: reg}, {l: reg}, {r: reg} => le(0`10 @ {r} @ {l} @ {d} @ 0xC @ 0b001)
+    DIVU {d: reg}, {l: reg}, {r: reg} => le(0`10 @ {r} @ {l} @ {d} @ 0xD @ 0b001)
+    REM  {d: reg}, {l: reg}, {r: reg} => le(0`10 @ {r} @ {l} @ {d} @ 0xE @ 0b001)
+    REMU {d: reg}, {l: reg}, {r: reg} => le(0`10 @ {r} @ {l} @ {d} @ 0xF @ 0b001)
 
     ADD  {d: reg}, {l: reg}, {v: imm} => le({v} @ {l} @ {d} @ 0x1 @ 0b010)
     ADDC {d: reg}, {l: reg}, {v: imm} => le({v} @ {l} @ {d} @ 0x2 @ 0b010)
@@ -76,6 +81,11 @@
     SHL  {d: reg}, {l: reg}, {v: imm} => le({v} @ {l} @ {d} @ 0x8 @ 0b010)
     ASR  {d: reg}, {l: reg}, {v: imm} => le({v} @ {l} @ {d} @ 0x9 @ 0b010)
     LSR  {d: reg}, {l: reg}, {v: imm} => le({v} @ {l} @ {d} @ 0xA @ 0b010)
+    MUL  {d: reg}, {l: reg}, {v: imm} => le({v} @ {l} @ {d} @ 0xB @ 0b010)
+    DIV  {d: reg}, {l: reg}, {v: imm} => le({v} @ {l} @ {d} @ 0xC @ 0b010)
+    DIVU {d: reg}, {l: reg}, {v: imm} => le({v} @ {l} @ {d} @ 0xD @ 0b010)
+    REM  {d: reg}, {l: reg}, {v: imm} => le({v} @ {l} @ {d} @ 0xE @ 0b010)
+    REMU {d: reg}, {l: reg}, {v: imm} => le({v} @ {l} @ {d} @ 0xF @ 0b010)
 
     ADD  {d: reg}, {v: imm}, {r: reg} => le({v} @ {r} @ {d} @ 0x1 @ 0b011)
     ADDC {d: reg}, {v: imm}, {r: reg} => le({v} @ {r} @ {d} @ 0x2 @ 0b011)
@@ -87,6 +97,11 @@
     SHL  {d: reg}, {v: imm}, {r: reg} => le({v} @ {r} @ {d} @ 0x8 @ 0b011)
     ASR  {d: reg}, {v: imm}, {r: reg} => le({v} @ {r} @ {d} @ 0x9 @ 0b011)
     LSR  {d: reg}, {v: imm}, {r: reg} => le({v} @ {r} @ {d} @ 0xA @ 0b011)
+    MUL  {d: reg}, {v: imm}, {r: reg} => le({v} @ {r} @ {d} @ 0xB @ 0b011)
+    DIV  {d: reg}, {v: imm}, {r: reg} => le({v} @ {r} @ {d} @ 0xC @ 0b011)
+    DIVU {d: reg}, {v: imm}, {r: reg} => le({v} @ {r} @ {d} @ 0xD @ 0b011)
+    REM  {d: reg}, {v: imm}, {r: reg} => le({v} @ {r} @ {d} @ 0xE @ 0b011)
+    REMU {d: reg}, {v: imm}, {r: reg} => le({v} @ {r} @ {d} @ 0xF @ 0b011)
 
     LD   {d: reg}, [{s: reg} + {o: reg}] => le(0`10 @ {o} @ {s} @ {d} @ 0x0 @ 0b100)
     LD   {d: reg}, [{s: reg} + {v: imm}] => le(       {v} @ {s} @ {d} @ 0x1 @ 0b100)
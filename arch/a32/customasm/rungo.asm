@@ -1,3 +1,6 @@
+; special label for nil pointers
+nil = 0
+
 
 ; code bank is the main program memory bank, this is presumed
 ; to be in RAM and writable. So pre-initialized global variables
@@ -5,7 +8,7 @@
 #bankdef code
 {
   #addr 0x0
-  #size 0x1000
+  #size 0x10000
   ; todo: change to ram address once the emulator supports this
   ; #addr 0x01000000
   ; #size 0x00100000
@@ -28,6 +31,9 @@
 
 stackStartAddress = 0x01FFFFFC
 
+; the heap runs from the end of bss up to here, leaving the rest for the stack
+heapEndAddress = 0x01F00000
+
 ; run go's main__main function
 init:
   ; initialize the stack
@@ -0,0 +1,356 @@
+package a32
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// I/O ports used by the runtime
+const (
+	uartDataOut     = 0x005
+	uartOutputCount = 0x007
+)
+
+const pageBits = 12
+
+// ErrCPUError is returned when the program executes the ERR instruction
+var ErrCPUError = errors.New("cpu executed err instruction")
+
+// ErrMaxCycles is returned when the program runs too long
+var ErrMaxCycles = errors.New("exceeded maximum cycle count")
+
+// Emulator is an instruction set simulator for a32
+type Emulator struct {
+	// Out receives the characters written to the UART
+	Out io.Writer
+
+	// Trace receives a line per instruction executed if not nil
+	Trace io.Writer
+
+	// MaxCycles is the maximum number of cycles to run, or 0 for no limit
+	MaxCycles uint64
+
+	cycles uint64
+
+	regs [32]uint32
+	pc   uint32
+
+	carry, zero, sign, overflow bool
+
+	pages map[uint32]*[1 << pageBits]byte
+}
+
+// Load loads a raw binary image at address 0.
+func (emu *Emulator) Load(r io.Reader) error {
+	image, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	for i, b := range image {
+		emu.store8(uint32(i), b)
+	}
+	return nil
+}
+
+// Run runs the program until it halts, returning the exit code.
+func (emu *Emulator) Run() (int, error) {
+	for {
+		done, err := emu.Step()
+		if err != nil {
+			return 1, err
+		}
+		if done {
+			return 0, nil
+		}
+		if emu.MaxCycles != 0 && emu.cycles >= emu.MaxCycles {
+			return 1, ErrMaxCycles
+		}
+	}
+}
+
+// Cycles returns the number of cycles executed so far
+func (emu *Emulator) Cycles() uint64 {
+	return emu.cycles
+}
+
+func (emu *Emulator) page(addr uint32) *[1 << pageBits]byte {
+	if emu.pages == nil {
+		emu.pages = make(map[uint32]*[1 << pageBits]byte)
+	}
+	num := addr >> pageBits
+	page := emu.pages[num]
+	if page == nil {
+		page = new([1 << pageBits]byte)
+		emu.pages[num] = page
+	}
+	return page
+}
+
+func (emu *Emulator) load8(addr uint32) uint8 {
+	return emu.page(addr)[addr&(1<<pageBits-1)]
+}
+
+func (emu *Emulator) store8(addr uint32, val uint8) {
+	emu.page(addr)[addr&(1<<pageBits-1)] = val
+}
+
+func (emu *Emulator) load(addr uint32, size int) uint32 {
+	var val uint32
+	for i := size - 1; i >= 0; i-- {
+		val = val<<8 | uint32(emu.load8(addr+uint32(i)))
+	}
+	return val
+}
+
+func (emu *Emulator) store(addr uint32, size int, val uint32) {
+	for i := 0; i < size; i++ {
+		emu.store8(addr+uint32(i), uint8(val>>(i*8)))
+	}
+}
+
+func (emu *Emulator) setReg(r uint32, val uint32) {
+	if r != uint32(Zero) {
+		emu.regs[r] = val
+	}
+}
+
+// Step executes a single instruction and returns true if the cpu halted.
+func (emu *Emulator) Step() (bool, error) {
+	pc := emu.pc
+	word := emu.load(pc, 4)
+	emu.pc += 4
+	emu.cycles++
+
+	format := word & 0x7
+	op := (word >> 3) & 0xf
+	d := (word >> 7) & 0x1f
+	s := (word >> 12) & 0x1f
+	r := (word >> 17) & 0x1f
+
+	// the immediate, for the formats that have one
+	imm := uint32(int32(word<<1) >> 18)
+	if word&(1<<31) != 0 {
+		imm = (word>>17)&0x3fff | emu.load(emu.pc, 4)<<14
+		emu.pc += 4
+	}
+
+	if emu.Trace != nil {
+		fmt.Fprintf(emu.Trace, "%08x: %08x %v\n", pc, word, emu.regs)
+	}
+
+	switch format {
+	case fmtSys:
+		switch op {
+		case sysNop, sysBrk:
+		case sysHlt:
+			return true, nil
+		case sysErr:
+			return true, fmt.Errorf("%w at pc 0x%08x with a0 = %d", ErrCPUError, pc, int32(emu.regs[A0]))
+		default:
+			return true, fmt.Errorf("illegal instruction 0x%08x at pc 0x%08x", word, pc)
+		}
+
+	case fmtALU:
+		emu.setReg(d, emu.alu(op, emu.regs[s], emu.regs[r]))
+	case fmtALUImmRight:
+		emu.setReg(d, emu.alu(op, emu.regs[s], imm))
+	case fmtALUImmLeft:
+		emu.setReg(d, emu.alu(op, imm, emu.regs[s]))
+
+	case fmtMem:
+		offset := emu.regs[r]
+		if op&1 != 0 {
+			offset = imm
+		}
+		switch op &^ 1 {
+		case 0x0:
+			emu.setReg(d, emu.load(emu.regs[s]+offset, 4))
+		case 0x2:
+			emu.store(emu.regs[d]+offset, 4, emu.regs[s])
+		case 0x4:
+			emu.setReg(d, emu.load(emu.regs[s]+offset, 1))
+		case 0x6:
+			emu.store(emu.regs[d]+offset, 1, emu.regs[s])
+		case 0x8:
+			emu.setReg(d, emu.load(emu.regs[s]+offset, 2))
+		case 0xA:
+			emu.store(emu.regs[d]+offset, 2, emu.regs[s])
+		default:
+			return true, fmt.Errorf("illegal instruction 0x%08x at pc 0x%08x", word, pc)
+		}
+
+	case fmtJump:
+		var target uint32
+		switch d {
+		case jumpReg:
+			target = emu.regs[s] + emu.regs[r]
+		case jumpRegIndirect:
+			target = emu.load(emu.regs[s]+emu.regs[r], 4)
+		case jumpImm:
+			target = emu.regs[s] + imm
+		case jumpImmIndirect:
+			target = emu.load(emu.regs[s]+imm, 4)
+		case jumpRel:
+			target = pc + 4 + imm
+		default:
+			return true, fmt.Errorf("illegal instruction 0x%08x at pc 0x%08x", word, pc)
+		}
+		if emu.condition(op) {
+			emu.pc = target
+		}
+
+	case fmtIO:
+		offset := emu.regs[r]
+		if op&1 != 0 {
+			offset = imm
+		}
+		switch op &^ 1 {
+		case 0:
+			emu.setReg(d, emu.in(emu.regs[s]+offset))
+		case 2:
+			emu.out(emu.regs[d]+offset, emu.regs[s])
+		default:
+			return true, fmt.Errorf("illegal instruction 0x%08x at pc 0x%08x", word, pc)
+		}
+
+	default:
+		return true, fmt.Errorf("unsupported instruction 0x%08x at pc 0x%08x", word, pc)
+	}
+
+	return false, nil
+}
+
+func (emu *Emulator) in(port uint32) uint32 {
+	switch port {
+	case uartOutputCount:
+		// the output is never backed up
+		return 0
+	}
+	return 0
+}
+
+func (emu *Emulator) out(port uint32, val uint32) {
+	switch port {
+	case uartDataOut:
+		if emu.Out != nil {
+			emu.Out.Write([]byte{byte(val)})
+		}
+	}
+}
+
+func (emu *Emulator) condition(cond uint32) bool {
+	switch cond {
+	case 0x1:
+		return emu.carry
+	case 0x2:
+		return emu.zero
+	case 0x3:
+		return emu.sign
+	case 0x4:
+		return emu.overflow
+	case 0x5:
+		return !emu.carry
+	case 0x6:
+		return !emu.zero
+	case 0x7:
+		return !emu.sign
+	case 0x8:
+		return !emu.overflow
+	case 0x9:
+		return !emu.carry || emu.zero
+	case 0xA:
+		return emu.carry && !emu.zero
+	case 0xB:
+		return emu.sign != emu.overflow
+	case 0xC:
+		return emu.sign == emu.overflow
+	case 0xD:
+		return emu.zero || emu.sign != emu.overflow
+	case 0xE:
+		return !emu.zero && emu.sign == emu.overflow
+	case condAlways:
+		return true
+	}
+	return false
+}
+
+// divide does the divide and remainder operations. Dividing by zero
+// gives all ones and leaves the remainder as the dividend, and dividing
+// the most negative number by -1 overflows back to it, so they never
+// trap.
+func divide(op uint32, l, r uint32) uint32 {
+	if r == 0 {
+		if op == 0xC || op == 0xD {
+			return 0xffffffff
+		}
+		return l
+	}
+
+	switch op {
+	case 0xC: // DIV
+		if int32(r) == -1 {
+			return -l
+		}
+		return uint32(int32(l) / int32(r))
+	case 0xD: // DIVU
+		return l / r
+	case 0xE: // REM
+		if int32(r) == -1 {
+			return 0
+		}
+		return uint32(int32(l) % int32(r))
+	}
+	return l % r // REMU
+}
+
+// alu does the ALU operation and updates the flags. The carry flag is
+// set when there is no borrow for subtraction.
+func (emu *Emulator) alu(op uint32, l, r uint32) uint32 {
+	var result uint32
+	carryIn := uint64(0)
+	if emu.carry {
+		carryIn = 1
+	}
+
+	switch op {
+	case 0x1, 0x2: // ADD, ADDC
+		if op == 0x1 {
+			carryIn = 0
+		}
+		sum := uint64(l) + uint64(r) + carryIn
+		result = uint32(sum)
+		emu.carry = sum > 0xffffffff
+		emu.overflow = (l^result)&(r^result)&(1<<31) != 0
+	case 0x3, 0x4: // SUB, SUBB
+		// subtraction is done as l + ^r + carry
+		if op == 0x3 {
+			carryIn = 1
+		}
+		sum := uint64(l) + uint64(^r) + carryIn
+		result = uint32(sum)
+		emu.carry = sum > 0xffffffff
+		emu.overflow = (l^r)&(l^result)&(1<<31) != 0
+	case 0x5:
+		result = l & r
+	case 0x6:
+		result = l | r
+	case 0x7:
+		result = l ^ r
+	case 0x8:
+		result = l << (r & 31)
+	case 0x9:
+		result = uint32(int32(l) >> (r & 31))
+	case 0xA:
+		result = l >> (r & 31)
+	case 0xB:
+		result = l * r
+	case 0xC, 0xD, 0xE, 0xF:
+		result = divide(op, l, r)
+	}
+
+	emu.zero = result == 0
+	emu.sign = result&(1<<31) != 0
+
+	return result
+}
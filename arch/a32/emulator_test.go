@@ -0,0 +1,205 @@
+package a32
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// instr encodes an instruction with a register as its last operand
+func instr(format, op uint32, d, s, r Reg) []uint32 {
+	return []uint32{uint32(r)<<17 | uint32(s)<<12 | uint32(d)<<7 | op<<3 | format}
+}
+
+// instrImm encodes an instruction with an immediate as its last
+// operand, which is followed by its upper bits if it doesn't fit
+func instrImm(format, op uint32, d, s Reg, imm int32) []uint32 {
+	word := uint32(imm)&0x3fff<<17 | uint32(s)<<12 | uint32(d)<<7 | op<<3 | format
+	if imm<<18>>18 == imm {
+		return []uint32{word}
+	}
+	return []uint32{word | 1<<31, uint32(imm) >> 14}
+}
+
+func ldi(d Reg, imm int32) []uint32 {
+	return instrImm(fmtALUImmRight, aluOps[ADD], d, Zero, imm)
+}
+
+func alu(op Opcode, d, l, r Reg) []uint32 {
+	return instr(fmtALU, aluOps[op], d, l, r)
+}
+
+func aluImm(op Opcode, d, l Reg, imm int32) []uint32 {
+	return instrImm(fmtALUImmRight, aluOps[op], d, l, imm)
+}
+
+// branch encodes a jump relative to the next instruction
+func branch(cond uint32, offset int32) []uint32 {
+	return instrImm(fmtJump, cond, Reg(jumpRel), Zero, offset)
+}
+
+func sys(op uint32) []uint32 {
+	return instr(fmtSys, op, 0, 0, 0)
+}
+
+// program joins the instructions into a little endian image
+func program(instrs ...[]uint32) []byte {
+	var buf bytes.Buffer
+	for _, words := range instrs {
+		binary.Write(&buf, binary.LittleEndian, words)
+	}
+	return buf.Bytes()
+}
+
+// run runs the program until it halts or errors
+func run(t *testing.T, instrs ...[]uint32) (*Emulator, int, error) {
+	t.Helper()
+
+	emu := &Emulator{MaxCycles: 1000}
+	if err := emu.Load(bytes.NewReader(program(instrs...))); err != nil {
+		t.Fatal(err)
+	}
+	exit, err := emu.Run()
+	return emu, exit, err
+}
+
+func TestEmulator_decodes(t *testing.T) {
+	tests := []struct {
+		desc   string
+		instrs [][]uint32
+		want   uint32
+	}{
+		{"short immediate", [][]uint32{ldi(A0, -3)}, 0xfffffffd},
+		{"long immediate", [][]uint32{ldi(A0, 0x12345678)}, 0x12345678},
+		{"register add", [][]uint32{ldi(A1, 5), ldi(A2, 7), alu(ADD, A0, A1, A2)}, 12},
+		{"immediate on the left", [][]uint32{ldi(A1, 5), instrImm(fmtALUImmLeft, aluOps[SUB], A0, A1, 7)}, 2},
+		{"zero register", [][]uint32{ldi(Zero, 5), alu(ADD, A0, Zero, Zero)}, 0},
+		{"arithmetic shift", [][]uint32{ldi(A0, -16), aluImm(ASR, A0, A0, 2)}, 0xfffffffc},
+		{"logical shift", [][]uint32{ldi(A0, -16), aluImm(LSR, A0, A0, 28)}, 0xf},
+		{"multiply", [][]uint32{ldi(A0, -300), aluImm(MUL, A0, A0, 300)}, 0xfffea070},
+		{"divide", [][]uint32{ldi(A0, -7), aluImm(DIV, A0, A0, 2)}, 0xfffffffd},
+		{"divide unsigned", [][]uint32{ldi(A0, -7), aluImm(DIVU, A0, A0, 2)}, 0x7ffffffc},
+		{"remainder", [][]uint32{ldi(A0, -7), aluImm(REM, A0, A0, 2)}, 0xffffffff},
+		{"remainder unsigned", [][]uint32{ldi(A0, -7), aluImm(REMU, A0, A0, 2)}, 1},
+		{"divide by zero", [][]uint32{ldi(A0, 7), aluImm(DIV, A0, A0, 0)}, 0xffffffff},
+		{"remainder by zero", [][]uint32{ldi(A0, 7), aluImm(REMU, A0, A0, 0)}, 7},
+		{"divide overflow", [][]uint32{ldi(A0, -1<<31), aluImm(DIV, A0, A0, -1)}, 0x80000000},
+		{"store and load", [][]uint32{
+			ldi(A1, 0x1000), ldi(A2, 0x12345678),
+			instrImm(fmtMem, 0x3, A1, A2, 4), instr(fmtMem, 0x4, A0, A1, A3),
+			ldi(A3, 5), instr(fmtMem, 0x4, A0, A1, A3),
+		}, 0x56},
+		{"jump", [][]uint32{branch(condAlways, 4), ldi(A0, 1), sys(sysNop)}, 0},
+		{"branch not taken", [][]uint32{
+			ldi(A0, 1), aluImm(SUB, Zero, A0, 1),
+			branch(0x6, 4), ldi(A0, 2),
+		}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			emu, _, err := run(t, append(tt.instrs, sys(sysHlt))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := emu.regs[A0]; got != tt.want {
+				t.Errorf("expected a0 to be 0x%08x but got 0x%08x", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEmulator_flags(t *testing.T) {
+	tests := []struct {
+		desc                        string
+		instrs                      [][]uint32
+		want                        uint32
+		carry, zero, sign, overflow bool
+	}{
+		{"add", [][]uint32{ldi(A0, 1), aluImm(ADD, A0, A0, 1)}, 2, false, false, false, false},
+		{"add with carry out", [][]uint32{ldi(A0, -1), aluImm(ADD, A0, A0, 1)}, 0, true, true, false, false},
+		{"add with carry in", [][]uint32{ldi(A0, -1), aluImm(ADD, A0, A0, 1), aluImm(ADDC, A0, A0, 1)}, 2, false, false, false, false},
+		{"add with overflow", [][]uint32{ldi(A0, 1<<31-1), aluImm(ADD, A0, A0, 1)}, 0x80000000, false, false, true, true},
+		{"sub without borrow", [][]uint32{ldi(A0, 3), aluImm(SUB, A0, A0, 1)}, 2, true, false, false, false},
+		{"sub with borrow", [][]uint32{ldi(A0, 1), aluImm(SUB, A0, A0, 2)}, 0xffffffff, false, false, true, false},
+		{"sub with borrow in", [][]uint32{ldi(A0, 5), aluImm(SUB, A1, Zero, 1), aluImm(SUBB, A0, A0, 1)}, 3, true, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			emu, _, err := run(t, append(tt.instrs, sys(sysHlt))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if emu.regs[A0] != tt.want {
+				t.Errorf("expected a0 to be 0x%08x but got 0x%08x", tt.want, emu.regs[A0])
+			}
+			if emu.carry != tt.carry || emu.zero != tt.zero || emu.sign != tt.sign || emu.overflow != tt.overflow {
+				t.Errorf("expected flags c=%v z=%v s=%v o=%v but got c=%v z=%v s=%v o=%v",
+					tt.carry, tt.zero, tt.sign, tt.overflow, emu.carry, emu.zero, emu.sign, emu.overflow)
+			}
+		})
+	}
+}
+
+func TestEmulator_exitCodes(t *testing.T) {
+	tests := []struct {
+		desc   string
+		instrs [][]uint32
+		exit   int
+		err    error
+	}{
+		{"halt", [][]uint32{ldi(A0, 3), sys(sysHlt)}, 0, nil},
+		{"error", [][]uint32{ldi(A0, 7), sys(sysErr)}, 1, ErrCPUError},
+		{"runs too long", [][]uint32{branch(condAlways, -4)}, 1, ErrMaxCycles},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, exit, err := run(t, tt.instrs...)
+			if exit != tt.exit || !errors.Is(err, tt.err) {
+				t.Errorf("expected exit code %d with %v but got %d with %v", tt.exit, tt.err, exit, err)
+			}
+		})
+	}
+
+	_, exit, err := run(t, sys(0xf))
+	if exit != 1 || err == nil || !strings.Contains(err.Error(), "illegal instruction") {
+		t.Errorf("expected an illegal instruction but got %d with %v", exit, err)
+	}
+}
+
+func TestEmulator_output(t *testing.T) {
+	var out, trace bytes.Buffer
+	emu := &Emulator{Out: &out, Trace: &trace}
+	image := program(
+		ldi(A0, 'h'), instrImm(fmtIO, 0x3, Zero, A0, uartDataOut),
+		ldi(A0, 'i'), instrImm(fmtIO, 0x3, Zero, A0, uartDataOut),
+		ldi(A0, 0x12345678),
+		sys(sysHlt),
+	)
+	if err := emu.Load(bytes.NewReader(image)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := emu.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != "hi" {
+		t.Errorf("expected hi to be written to the uart but got %q", out.String())
+	}
+
+	lines := strings.Split(strings.TrimSuffix(trace.String(), "\n"), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("expected a line per instruction but got:\n%s", trace.String())
+	}
+	zeros := strings.Repeat(" 0", 27)
+	if want := "00000000: 00d0020a [0 0 0 0 0" + zeros + "]"; lines[0] != want {
+		t.Errorf("expected the first line to be %q but got %q", want, lines[0])
+	}
+	if want := "00000018: 00000010 [0 0 0 0 305419896" + zeros + "]"; lines[5] != want {
+		t.Errorf("expected the long immediate to be skipped to %q but got %q", want, lines[5])
+	}
+	if emu.Cycles() != 6 {
+		t.Errorf("expected 6 cycles but got %d", emu.Cycles())
+	}
+}
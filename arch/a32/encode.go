@@ -0,0 +1,642 @@
+package a32
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rj45/nanogo/assembler"
+)
+
+// aluOps are the op codes of the ALU operations
+var aluOps = map[Opcode]uint32{
+	ADD:  0x1,
+	ADDC: 0x2,
+	SUB:  0x3,
+	SUBB: 0x4,
+	AND:  0x5,
+	OR:   0x6,
+	XOR:  0x7,
+	SHL:  0x8,
+	ASR:  0x9,
+	LSR:  0xA,
+	MUL:  0xB,
+	DIV:  0xC,
+	DIVU: 0xD,
+	REM:  0xE,
+	REMU: 0xF,
+}
+
+// memOps are the op codes of the register offset form of loads
+// and stores, the immediate offset form is one more.
+var memOps = map[Opcode]uint32{
+	LD:   0x0,
+	ST:   0x2,
+	LD8:  0x4,
+	ST8:  0x6,
+	LD16: 0x8,
+	ST16: 0xA,
+}
+
+// conditions are the jump and branch conditions
+var conditions = map[string]uint32{
+	"c":    0x1,
+	"z":    0x2,
+	"s":    0x3,
+	"o":    0x4,
+	"nc":   0x5,
+	"nz":   0x6,
+	"ns":   0x7,
+	"no":   0x8,
+	"u.le": 0x9,
+	"u.g":  0xA,
+	"s.l":  0xB,
+	"s.ge": 0xC,
+	"s.le": 0xD,
+	"s.g":  0xE,
+
+	// aliases
+	"eq":   0x2,
+	"neq":  0x6,
+	"u.l":  0x5,
+	"u.ge": 0x1,
+}
+
+var regNums = map[string]uint32{}
+
+func init() {
+	for i, name := range RegStrings() {
+		regNums[name] = uint32(i)
+		regNums[fmt.Sprintf("r%d", i)] = uint32(i)
+	}
+}
+
+type encoder struct {
+	in    *assembler.Instr
+	words []uint32
+
+	// force a long immediate on the first immediate
+	force bool
+}
+
+// Encode encodes a single instruction for the native assembler.
+func (cpuArch) Encode(in *assembler.Instr) ([]uint64, error) {
+	words, err := encode(in, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(words)*4 < in.MinUnits {
+		// the assembler already allocated more space for this
+		// instruction in an earlier pass, so use a long immediate
+		words, err = encode(in, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for len(words)*4 < in.MinUnits {
+		words = append(words, sysNop<<3|fmtSys)
+	}
+
+	units := make([]uint64, 0, len(words)*4)
+	for _, w := range words {
+		units = append(units, uint64(w&0xff), uint64((w>>8)&0xff), uint64((w>>16)&0xff), uint64(w>>24))
+	}
+	return units, nil
+}
+
+// operand is a parsed operand
+type operand struct {
+	text     string
+	indirect bool
+
+	// base register and whether it's set
+	base    uint32
+	hasBase bool
+
+	// offset register and whether it's set
+	index    uint32
+	hasIndex bool
+
+	// the immediate expression, if any
+	expr string
+}
+
+func (op operand) isReg() bool {
+	return op.hasBase && !op.hasIndex && op.expr == "" && !op.indirect
+}
+
+func (op operand) isImm() bool {
+	return !op.hasBase && !op.indirect
+}
+
+func parseOperand(text string) operand {
+	op := operand{text: text}
+	text = strings.TrimSpace(text)
+
+	if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+		op.indirect = true
+		text = strings.TrimSpace(text[1 : len(text)-1])
+	}
+
+	first := text
+	rest := ""
+	if plus := strings.Index(text, "+"); plus >= 0 {
+		first = strings.TrimSpace(text[:plus])
+		rest = strings.TrimSpace(text[plus+1:])
+	}
+
+	if num, found := regNums[strings.ToLower(first)]; found {
+		op.base = num
+		op.hasBase = true
+		if rest != "" {
+			if num, found := regNums[strings.ToLower(rest)]; found {
+				op.index = num
+				op.hasIndex = true
+			} else {
+				op.expr = rest
+			}
+		}
+		return op
+	}
+
+	op.expr = text
+	return op
+}
+
+func encode(in *assembler.Instr, force bool) ([]uint32, error) {
+	e := &encoder{in: in, force: force}
+
+	ops := make([]operand, len(in.Operands))
+	for i, text := range in.Operands {
+		ops[i] = parseOperand(text)
+	}
+
+	mnemonic := strings.ToLower(in.Mnemonic)
+
+	if err := e.encode(mnemonic, ops); err != nil {
+		return nil, err
+	}
+	return e.words, nil
+}
+
+func (e *encoder) encode(mnemonic string, ops []operand) error {
+	switch mnemonic {
+	case "nop":
+		return e.sys(ops, sysNop)
+	case "brk":
+		return e.sys(ops, sysBrk)
+	case "hlt":
+		return e.sys(ops, sysHlt)
+	case "err":
+		return e.sys(ops, sysErr)
+	case "sys", "clrk":
+		if len(ops) != 0 {
+			return e.operandCount(0)
+		}
+		op := uint32(0)
+		if mnemonic == "clrk" {
+			op = 1
+		}
+		e.words = append(e.words, op<<3|fmtKernel)
+		return nil
+
+	case "mov":
+		if len(ops) != 2 {
+			return e.operandCount(2)
+		}
+		return e.alu(OR, ops[0], ops[1], regOp(Zero))
+	case "swp":
+		if len(ops) != 2 {
+			return e.operandCount(2)
+		}
+		for _, pair := range [][2]operand{{ops[0], ops[1]}, {ops[1], ops[0]}, {ops[0], ops[1]}} {
+			if err := e.alu(XOR, pair[0], pair[0], pair[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "cmp", "bit":
+		if len(ops) != 2 {
+			return e.operandCount(2)
+		}
+		op := SUB
+		if mnemonic == "bit" {
+			op = AND
+		}
+		return e.alu(op, regOp(Zero), ops[0], ops[1])
+	case "test":
+		if len(ops) != 1 {
+			return e.operandCount(1)
+		}
+		return e.alu(OR, regOp(Zero), ops[0], regOp(Zero))
+	case "inc", "dec", "incc", "decb":
+		if len(ops) != 1 {
+			return e.operandCount(1)
+		}
+		switch mnemonic {
+		case "inc":
+			return e.alu(ADD, ops[0], ops[0], immOp("1"))
+		case "dec":
+			return e.alu(SUB, ops[0], ops[0], immOp("1"))
+		case "incc":
+			return e.alu(ADDC, ops[0], ops[0], regOp(Zero))
+		}
+		return e.alu(SUBB, ops[0], ops[0], regOp(Zero))
+	case "neg", "negb":
+		if len(ops) != 2 {
+			return e.operandCount(2)
+		}
+		op := SUB
+		if mnemonic == "negb" {
+			op = SUBB
+		}
+		return e.alu(op, ops[0], regOp(Zero), ops[1])
+	case "not":
+		if len(ops) != 2 {
+			return e.operandCount(2)
+		}
+		return e.alu(XOR, ops[0], ops[1], immOp("-1"))
+
+	case "push", "push8", "push16":
+		if len(ops) != 1 {
+			return e.operandCount(1)
+		}
+		store := map[string]Opcode{"push": ST, "push8": ST8, "push16": ST16}[mnemonic]
+		if err := e.mem(store, parseOperand("[sp]"), ops[0]); err != nil {
+			return err
+		}
+		return e.alu(SUB, regOp(SP), regOp(SP), immOp("4"))
+	case "pop", "pop8", "pop16":
+		if len(ops) != 1 {
+			return e.operandCount(1)
+		}
+		load := map[string]Opcode{"pop": LD, "pop8": LD8, "pop16": LD16}[mnemonic]
+		if err := e.alu(ADD, regOp(SP), regOp(SP), immOp("4")); err != nil {
+			return err
+		}
+		return e.mem(load, ops[0], parseOperand("[sp]"))
+
+	case "call":
+		return e.call(ops)
+	case "calls":
+		if len(ops) != 0 {
+			return e.operandCount(0)
+		}
+		// return address is after the LD and SYS
+		ret := e.pc() + 8
+		if !e.fitsShort(ret) || e.force {
+			ret += 4
+		}
+		if err := e.alu(OR, regOp(RA), immOp(fmt.Sprint(ret)), regOp(Zero)); err != nil {
+			return err
+		}
+		e.words = append(e.words, fmtKernel)
+		return nil
+	case "ret":
+		if len(ops) != 1 {
+			return e.operandCount(1)
+		}
+		v, err := e.in.Eval(ops[0].text)
+		if err != nil {
+			return err
+		}
+		if err := e.alu(ADD, regOp(SP), regOp(BP), immOp(fmt.Sprint(v<<2))); err != nil {
+			return err
+		}
+		return e.jump(condAlways, regOp(RA))
+	case "rets":
+		if len(ops) != 0 {
+			return e.operandCount(0)
+		}
+		e.words = append(e.words, 1<<3|fmtKernel)
+		return e.jump(condAlways, regOp(RA))
+
+	case "in", "out":
+		return e.io(mnemonic == "out", ops)
+
+	case "jmp":
+		if len(ops) != 1 {
+			return e.operandCount(1)
+		}
+		return e.jump(condAlways, ops[0])
+	case "bra":
+		if len(ops) != 1 {
+			return e.operandCount(1)
+		}
+		return e.branch(condAlways, ops[0])
+	}
+
+	if strings.HasPrefix(mnemonic, "jp.") || strings.HasPrefix(mnemonic, "br.") {
+		cond, found := conditions[mnemonic[3:]]
+		if !found {
+			return fmt.Errorf("unknown condition %s", mnemonic)
+		}
+		if len(ops) != 1 {
+			return e.operandCount(1)
+		}
+		if strings.HasPrefix(mnemonic, "jp.") {
+			return e.jump(cond, ops[0])
+		}
+		return e.branch(cond, ops[0])
+	}
+
+	opcode, err := OpcodeString(strings.ReplaceAll(mnemonic, ".", "_"))
+	if err != nil {
+		return fmt.Errorf("unknown instruction %s", mnemonic)
+	}
+
+	if _, found := aluOps[opcode]; found {
+		if len(ops) != 3 {
+			return e.operandCount(3)
+		}
+		return e.alu(opcode, ops[0], ops[1], ops[2])
+	}
+
+	if _, found := memOps[opcode]; found {
+		if len(ops) != 2 {
+			return e.operandCount(2)
+		}
+		if opcode == LD && ops[1].isImm() {
+			// load immediate
+			return e.alu(OR, ops[0], ops[1], regOp(Zero))
+		}
+		return e.mem(opcode, ops[0], ops[1])
+	}
+
+	return fmt.Errorf("instruction %s is not supported by the assembler", mnemonic)
+}
+
+func regOp(r Reg) operand {
+	return operand{text: r.String(), base: uint32(r), hasBase: true}
+}
+
+func immOp(expr string) operand {
+	return operand{text: expr, expr: expr}
+}
+
+func (e *encoder) operandCount(n int) error {
+	return fmt.Errorf("%s expects %d operands but got %d", e.in.Mnemonic, n, len(e.in.Operands))
+}
+
+// pc returns the address of the next word
+func (e *encoder) pc() int64 {
+	return e.in.PC + int64(len(e.words))*4
+}
+
+func (e *encoder) fitsShort(v int64) bool {
+	return v >= -8192 && v < 8192
+}
+
+// emit emits an instruction word with an optional immediate
+func (e *encoder) emit(word uint32, hasImm bool, imm int64) {
+	if !hasImm {
+		e.words = append(e.words, word)
+		return
+	}
+
+	word |= (uint32(imm) & 0x3fff) << 17
+
+	if e.fitsShort(imm) && !e.force {
+		e.words = append(e.words, word)
+		return
+	}
+	e.force = false
+
+	e.words = append(e.words, word|1<<31, (uint32(imm)>>14)&0x3ffff)
+}
+
+func (e *encoder) sys(ops []operand, op uint32) error {
+	if len(ops) != 0 {
+		return e.operandCount(0)
+	}
+	e.emit(op<<3|fmtSys, false, 0)
+	return nil
+}
+
+func (e *encoder) reg(op operand) (uint32, error) {
+	if !op.isReg() {
+		return 0, fmt.Errorf("expected register but got %s", op.text)
+	}
+	return op.base, nil
+}
+
+func (e *encoder) alu(opcode Opcode, d, l, r operand) error {
+	code := aluOps[opcode]
+
+	dr, err := e.reg(d)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case l.isReg() && r.isReg():
+		e.emit(r.base<<17|l.base<<12|dr<<7|code<<3|fmtALU, false, 0)
+	case l.isReg() && r.isImm():
+		v, err := e.in.Eval(r.expr)
+		if err != nil {
+			return err
+		}
+		e.emit(l.base<<12|dr<<7|code<<3|fmtALUImmRight, true, v)
+	case l.isImm() && r.isReg():
+		v, err := e.in.Eval(l.expr)
+		if err != nil {
+			return err
+		}
+		e.emit(r.base<<12|dr<<7|code<<3|fmtALUImmLeft, true, v)
+	default:
+		return fmt.Errorf("bad operands for %s", e.in.Mnemonic)
+	}
+	return nil
+}
+
+func (e *encoder) mem(opcode Opcode, a, b operand) error {
+	code := memOps[opcode]
+
+	store := opcode == ST || opcode == ST8 || opcode == ST16
+	reg, addr := a, b
+	if store {
+		reg, addr = b, a
+	}
+
+	r, err := e.reg(reg)
+	if err != nil {
+		return err
+	}
+
+	if !addr.indirect {
+		return fmt.Errorf("expected memory operand but got %s", addr.text)
+	}
+
+	base := uint32(Zero)
+	if addr.hasBase {
+		base = addr.base
+	}
+
+	// loads have the base in s and dest in d, stores have the
+	// base in d and the source in s
+	fields := r<<7 | base<<12
+	if store {
+		fields = base<<7 | r<<12
+	}
+
+	if addr.hasIndex {
+		e.emit(addr.index<<17|fields|code<<3|fmtMem, false, 0)
+		return nil
+	}
+
+	v := int64(0)
+	if addr.expr != "" {
+		v, err = e.in.Eval(addr.expr)
+		if err != nil {
+			return err
+		}
+	}
+	e.emit(fields|(code+1)<<3|fmtMem, true, v)
+	return nil
+}
+
+func (e *encoder) io(out bool, ops []operand) error {
+	if len(ops) != 2 {
+		return e.operandCount(2)
+	}
+
+	reg, addr := ops[0], ops[1]
+	code := uint32(0)
+	if out {
+		reg, addr = ops[1], ops[0]
+		code = 2
+	}
+
+	r, err := e.reg(reg)
+	if err != nil {
+		return err
+	}
+	if !addr.indirect {
+		return fmt.Errorf("expected port operand but got %s", addr.text)
+	}
+
+	base := uint32(Zero)
+	if addr.hasBase {
+		base = addr.base
+	}
+
+	fields := r<<7 | base<<12
+	if out {
+		fields = base<<7 | r<<12
+	}
+
+	if addr.hasIndex {
+		e.emit(addr.index<<17|fields|code<<3|fmtIO, false, 0)
+		return nil
+	}
+
+	v := int64(0)
+	if addr.expr != "" {
+		v, err = e.in.Eval(addr.expr)
+		if err != nil {
+			return err
+		}
+	}
+	e.emit(fields|(code+1)<<3|fmtIO, true, v)
+	return nil
+}
+
+func (e *encoder) jump(cond uint32, target operand) error {
+	mode := jumpReg
+	if target.indirect {
+		mode = jumpRegIndirect
+	}
+
+	base := uint32(Zero)
+	if target.hasBase {
+		base = target.base
+	}
+
+	if target.hasIndex || (target.hasBase && target.expr == "") {
+		e.emit(target.index<<17|base<<12|mode<<7|cond<<3|fmtJump, false, 0)
+		return nil
+	}
+
+	v, err := e.in.Eval(target.expr)
+	if err != nil {
+		return err
+	}
+	e.emit(base<<12|(mode+jumpImm)<<7|cond<<3|fmtJump, true, v)
+	return nil
+}
+
+func (e *encoder) branch(cond uint32, target operand) error {
+	if !target.isImm() {
+		return fmt.Errorf("expected branch target but got %s", target.text)
+	}
+
+	v, err := e.in.Eval(target.expr)
+	if err != nil {
+		return err
+	}
+
+	// the offset is relative to the end of the first word
+	offset := v - e.pc() - 4
+	e.emit(jumpRel<<7|cond<<3|fmtJump, true, offset)
+	return nil
+}
+
+// call sets up the frame pointer and return address, then jumps
+// to the target.
+func (e *encoder) call(ops []operand) error {
+	if len(ops) != 1 {
+		return e.operandCount(1)
+	}
+	target := ops[0]
+
+	start := len(e.words)
+	force := e.force
+
+	// the size of the LD and JMP depend on the return address and the
+	// target, so try with short forms first and grow as needed
+	for _, longLD := range []bool{false, true} {
+		e.words = e.words[:start]
+		e.force = force
+
+		if err := e.alu(OR, regOp(BP), regOp(SP), regOp(Zero)); err != nil {
+			return err
+		}
+
+		size := int64(12)
+		if longLD {
+			size += 4
+		}
+		if !target.hasBase && !target.hasIndex {
+			v, err := e.in.Eval(target.expr)
+			if err != nil {
+				return err
+			}
+			if !e.fitsShort(v) {
+				size += 4
+			}
+		}
+
+		ret := e.in.PC + int64(start)*4 + size
+		if !longLD && !e.fitsShort(ret) {
+			continue
+		}
+
+		ldStart := len(e.words)
+		e.force = longLD
+		if err := e.alu(OR, regOp(RA), immOp(fmt.Sprint(ret)), regOp(Zero)); err != nil {
+			return err
+		}
+		if (len(e.words)-ldStart == 2) != longLD {
+			continue
+		}
+
+		if err := e.jump(condAlways, target); err != nil {
+			return err
+		}
+
+		if e.pc() == ret {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unable to encode call to %s", target.text)
+}
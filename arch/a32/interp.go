@@ -0,0 +1,89 @@
+package a32
+
+import (
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/interp"
+	"github.com/rj45/nanogo/ir2/op"
+)
+
+func (cpuArch) InterpOp(o ir2.Op) interp.Semantics {
+	switch o {
+	case MOV, LDI, NOP:
+		return interp.Semantics{Op: op.Copy}
+	case LD:
+		return interp.Semantics{Op: op.Load, Bits: 32}
+	case LD16:
+		return interp.Semantics{Op: op.Load, Bits: 16}
+	case LD8:
+		return interp.Semantics{Op: op.Load, Bits: 8}
+	case ST:
+		return interp.Semantics{Op: op.Store, Bits: 32}
+	case ST16:
+		return interp.Semantics{Op: op.Store, Bits: 16}
+	case ST8:
+		return interp.Semantics{Op: op.Store, Bits: 8}
+	case ADD:
+		return interp.Semantics{Op: op.Add}
+	case SUB:
+		return interp.Semantics{Op: op.Sub}
+	case ADDC:
+		return interp.Semantics{Op: op.AddCarry}
+	case SUBB:
+		return interp.Semantics{Op: op.SubBorrow}
+	case AND:
+		return interp.Semantics{Op: op.And}
+	case OR:
+		return interp.Semantics{Op: op.Or}
+	case XOR:
+		return interp.Semantics{Op: op.Xor}
+	case SHL:
+		return interp.Semantics{Op: op.ShiftLeft}
+	case LSR:
+		return interp.Semantics{Op: op.ShiftRight, Unsigned: true}
+	case ASR:
+		return interp.Semantics{Op: op.ShiftRight, Signed: true}
+	case MUL:
+		return interp.Semantics{Op: op.Mul}
+	case DIV:
+		return interp.Semantics{Op: op.Div, Signed: true}
+	case DIVU:
+		return interp.Semantics{Op: op.Div, Unsigned: true}
+	case REM:
+		return interp.Semantics{Op: op.Rem, Signed: true}
+	case REMU:
+		return interp.Semantics{Op: op.Rem, Unsigned: true}
+	case NOT:
+		return interp.Semantics{Op: op.Invert}
+	case NEG:
+		return interp.Semantics{Op: op.Negate}
+	case BR_EQ:
+		return interp.Semantics{Op: op.IfEqual}
+	case BR_NEQ:
+		return interp.Semantics{Op: op.IfNotEqual}
+	case BR_S_L:
+		return interp.Semantics{Op: op.IfLess, Signed: true}
+	case BR_S_LE:
+		return interp.Semantics{Op: op.IfLessEqual, Signed: true}
+	case BR_S_G:
+		return interp.Semantics{Op: op.IfGreater, Signed: true}
+	case BR_S_GE:
+		return interp.Semantics{Op: op.IfGreaterEqual, Signed: true}
+	case BR_U_L:
+		return interp.Semantics{Op: op.IfLess, Unsigned: true}
+	case BR_U_LE:
+		return interp.Semantics{Op: op.IfLessEqual, Unsigned: true}
+	case BR_U_G:
+		return interp.Semantics{Op: op.IfGreater, Unsigned: true}
+	case BR_U_GE:
+		return interp.Semantics{Op: op.IfGreaterEqual, Unsigned: true}
+	case BRA:
+		return interp.Semantics{Op: op.Jump}
+	case CALL:
+		return interp.Semantics{Op: op.Call}
+	case RET:
+		return interp.Semantics{Op: op.Return}
+	case ERR:
+		return interp.Semantics{Op: op.Panic}
+	}
+	return interp.Semantics{Op: op.Invalid}
+}
@@ -0,0 +1,42 @@
+package a32
+
+// Instructions are 32 bits wide, stored little endian. Immediates
+// that don't fit in 14 bits make the instruction 64 bits wide, with
+// the upper 18 bits of the immediate in the second word.
+//
+//	bits  0..2   format
+//	bits  3..6   op (or condition for jumps)
+//	bits  7..11  d register (or jump mode)
+//	bits 12..16  s register
+//	bits 17..30  r register or the low 14 bits of the immediate
+//	bit  31      long immediate follows
+const (
+	fmtSys uint32 = iota
+	fmtALU
+	fmtALUImmRight
+	fmtALUImmLeft
+	fmtMem
+	fmtJump
+	fmtIO
+	fmtKernel
+)
+
+// sys ops
+const (
+	sysNop uint32 = iota
+	sysBrk
+	sysHlt
+	sysErr
+)
+
+// jump modes
+const (
+	jumpReg uint32 = iota
+	jumpRegIndirect
+	jumpImm
+	jumpImmIndirect
+	jumpRel
+)
+
+// condAlways is the condition of unconditional jumps
+const condAlways = 0xF
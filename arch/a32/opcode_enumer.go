@@ -7,11 +7,11 @@ import (
 	"strings"
 )
 
-const _OpcodeName = "nopbrkhlterraddsubaddcsubbandorxorshlasrlsrldstld8st8ld16st16br_eqbr_neqbr_u_lbr_u_lebr_u_gebr_u_gbr_s_lbr_s_lebr_s_gebr_s_gbracallretjmpcmpnegnegbnotmovldiswpnumops"
+const _OpcodeName = "nopbrkhlterraddsubaddcsubbandorxorshlasrlsrmuldivdivuremremuldstld8st8ld16st16br_eqbr_neqbr_u_lbr_u_lebr_u_gebr_u_gbr_s_lbr_s_lebr_s_gebr_s_gbracallretjmpcmpnegnegbnotmovldiswpnumops"
 
-var _OpcodeIndex = [...]uint8{0, 3, 6, 9, 12, 15, 18, 22, 26, 29, 31, 34, 37, 40, 43, 45, 47, 50, 53, 57, 61, 66, 72, 78, 85, 92, 98, 104, 111, 118, 124, 127, 131, 134, 137, 140, 143, 147, 150, 153, 156, 159, 165}
+var _OpcodeIndex = [...]uint8{0, 3, 6, 9, 12, 15, 18, 22, 26, 29, 31, 34, 37, 40, 43, 46, 49, 53, 56, 60, 62, 64, 67, 70, 74, 78, 83, 89, 95, 102, 109, 115, 121, 128, 135, 141, 144, 148, 151, 154, 157, 160, 164, 167, 170, 173, 176, 182}
 
-const _OpcodeLowerName = "nopbrkhlterraddsubaddcsubbandorxorshlasrlsrldstld8st8ld16st16br_eqbr_neqbr_u_lbr_u_lebr_u_gebr_u_gbr_s_lbr_s_lebr_s_gebr_s_gbracallretjmpcmpnegnegbnotmovldiswpnumops"
+const _OpcodeLowerName = "nopbrkhlterraddsubaddcsubbandorxorshlasrlsrmuldivdivuremremuldstld8st8ld16st16br_eqbr_neqbr_u_lbr_u_lebr_u_gebr_u_gbr_s_lbr_s_lebr_s_gebr_s_gbracallretjmpcmpnegnegbnotmovldiswpnumops"
 
 func (i Opcode) String() string {
 	if i < 0 || i >= Opcode(len(_OpcodeIndex)-1) {
@@ -38,37 +38,42 @@ func _OpcodeNoOp() {
 	_ = x[SHL-(11)]
 	_ = x[ASR-(12)]
 	_ = x[LSR-(13)]
-	_ = x[LD-(14)]
-	_ = x[ST-(15)]
-	_ = x[LD8-(16)]
-	_ = x[ST8-(17)]
-	_ = x[LD16-(18)]
-	_ = x[ST16-(19)]
-	_ = x[BR_EQ-(20)]
-	_ = x[BR_NEQ-(21)]
-	_ = x[BR_U_L-(22)]
-	_ = x[BR_U_LE-(23)]
-	_ = x[BR_U_GE-(24)]
-	_ = x[BR_U_G-(25)]
-	_ = x[BR_S_L-(26)]
-	_ = x[BR_S_LE-(27)]
-	_ = x[BR_S_GE-(28)]
-	_ = x[BR_S_G-(29)]
-	_ = x[BRA-(30)]
-	_ = x[CALL-(31)]
-	_ = x[RET-(32)]
-	_ = x[JMP-(33)]
-	_ = x[CMP-(34)]
-	_ = x[NEG-(35)]
-	_ = x[NEGB-(36)]
-	_ = x[NOT-(37)]
-	_ = x[MOV-(38)]
-	_ = x[LDI-(39)]
-	_ = x[SWP-(40)]
-	_ = x[NumOps-(41)]
+	_ = x[MUL-(14)]
+	_ = x[DIV-(15)]
+	_ = x[DIVU-(16)]
+	_ = x[REM-(17)]
+	_ = x[REMU-(18)]
+	_ = x[LD-(19)]
+	_ = x[ST-(20)]
+	_ = x[LD8-(21)]
+	_ = x[ST8-(22)]
+	_ = x[LD16-(23)]
+	_ = x[ST16-(24)]
+	_ = x[BR_EQ-(25)]
+	_ = x[BR_NEQ-(26)]
+	_ = x[BR_U_L-(27)]
+	_ = x[BR_U_LE-(28)]
+	_ = x[BR_U_GE-(29)]
+	_ = x[BR_U_G-(30)]
+	_ = x[BR_S_L-(31)]
+	_ = x[BR_S_LE-(32)]
+	_ = x[BR_S_GE-(33)]
+	_ = x[BR_S_G-(34)]
+	_ = x[BRA-(35)]
+	_ = x[CALL-(36)]
+	_ = x[RET-(37)]
+	_ = x[JMP-(38)]
+	_ = x[CMP-(39)]
+	_ = x[NEG-(40)]
+	_ = x[NEGB-(41)]
+	_ = x[NOT-(42)]
+	_ = x[MOV-(43)]
+	_ = x[LDI-(44)]
+	_ = x[SWP-(45)]
+	_ = x[NumOps-(46)]
 }
 
-var _OpcodeValues = []Opcode{NOP, BRK, HLT, ERR, ADD, SUB, ADDC, SUBB, AND, OR, XOR, SHL, ASR, LSR, LD, ST, LD8, ST8, LD16, ST16, BR_EQ, BR_NEQ, BR_U_L, BR_U_LE, BR_U_GE, BR_U_G, BR_S_L, BR_S_LE, BR_S_GE, BR_S_G, BRA, CALL, RET, JMP, CMP, NEG, NEGB, NOT, MOV, LDI, SWP, NumOps}
+var _OpcodeValues = []Opcode{NOP, BRK, HLT, ERR, ADD, SUB, ADDC, SUBB, AND, OR, XOR, SHL, ASR, LSR, MUL, DIV, DIVU, REM, REMU, LD, ST, LD8, ST8, LD16, ST16, BR_EQ, BR_NEQ, BR_U_L, BR_U_LE, BR_U_GE, BR_U_G, BR_S_L, BR_S_LE, BR_S_GE, BR_S_G, BRA, CALL, RET, JMP, CMP, NEG, NEGB, NOT, MOV, LDI, SWP, NumOps}
 
 var _OpcodeNameToValueMap = map[string]Opcode{
 	_OpcodeName[0:3]:          NOP,
@@ -99,62 +104,72 @@ var _OpcodeNameToValueMap = map[string]Opcode{
 	_OpcodeLowerName[37:40]:   ASR,
 	_OpcodeName[40:43]:        LSR,
 	_OpcodeLowerName[40:43]:   LSR,
-	_OpcodeName[43:45]:        LD,
-	_OpcodeLowerName[43:45]:   LD,
-	_OpcodeName[45:47]:        ST,
-	_OpcodeLowerName[45:47]:   ST,
-	_OpcodeName[47:50]:        LD8,
-	_OpcodeLowerName[47:50]:   LD8,
-	_OpcodeName[50:53]:        ST8,
-	_OpcodeLowerName[50:53]:   ST8,
-	_OpcodeName[53:57]:        LD16,
-	_OpcodeLowerName[53:57]:   LD16,
-	_OpcodeName[57:61]:        ST16,
-	_OpcodeLowerName[57:61]:   ST16,
-	_OpcodeName[61:66]:        BR_EQ,
-	_OpcodeLowerName[61:66]:   BR_EQ,
-	_OpcodeName[66:72]:        BR_NEQ,
-	_OpcodeLowerName[66:72]:   BR_NEQ,
-	_OpcodeName[72:78]:        BR_U_L,
-	_OpcodeLowerName[72:78]:   BR_U_L,
-	_OpcodeName[78:85]:        BR_U_LE,
-	_OpcodeLowerName[78:85]:   BR_U_LE,
-	_OpcodeName[85:92]:        BR_U_GE,
-	_OpcodeLowerName[85:92]:   BR_U_GE,
-	_OpcodeName[92:98]:        BR_U_G,
-	_OpcodeLowerName[92:98]:   BR_U_G,
-	_OpcodeName[98:104]:       BR_S_L,
-	_OpcodeLowerName[98:104]:  BR_S_L,
-	_OpcodeName[104:111]:      BR_S_LE,
-	_OpcodeLowerName[104:111]: BR_S_LE,
-	_OpcodeName[111:118]:      BR_S_GE,
-	_OpcodeLowerName[111:118]: BR_S_GE,
-	_OpcodeName[118:124]:      BR_S_G,
-	_OpcodeLowerName[118:124]: BR_S_G,
-	_OpcodeName[124:127]:      BRA,
-	_OpcodeLowerName[124:127]: BRA,
-	_OpcodeName[127:131]:      CALL,
-	_OpcodeLowerName[127:131]: CALL,
-	_OpcodeName[131:134]:      RET,
-	_OpcodeLowerName[131:134]: RET,
-	_OpcodeName[134:137]:      JMP,
-	_OpcodeLowerName[134:137]: JMP,
-	_OpcodeName[137:140]:      CMP,
-	_OpcodeLowerName[137:140]: CMP,
-	_OpcodeName[140:143]:      NEG,
-	_OpcodeLowerName[140:143]: NEG,
-	_OpcodeName[143:147]:      NEGB,
-	_OpcodeLowerName[143:147]: NEGB,
-	_OpcodeName[147:150]:      NOT,
-	_OpcodeLowerName[147:150]: NOT,
-	_OpcodeName[150:153]:      MOV,
-	_OpcodeLowerName[150:153]: MOV,
-	_OpcodeName[153:156]:      LDI,
-	_OpcodeLowerName[153:156]: LDI,
-	_OpcodeName[156:159]:      SWP,
-	_OpcodeLowerName[156:159]: SWP,
-	_OpcodeName[159:165]:      NumOps,
-	_OpcodeLowerName[159:165]: NumOps,
+	_OpcodeName[43:46]:        MUL,
+	_OpcodeLowerName[43:46]:   MUL,
+	_OpcodeName[46:49]:        DIV,
+	_OpcodeLowerName[46:49]:   DIV,
+	_OpcodeName[49:53]:        DIVU,
+	_OpcodeLowerName[49:53]:   DIVU,
+	_OpcodeName[53:56]:        REM,
+	_OpcodeLowerName[53:56]:   REM,
+	_OpcodeName[56:60]:        REMU,
+	_OpcodeLowerName[56:60]:   REMU,
+	_OpcodeName[60:62]:        LD,
+	_OpcodeLowerName[60:62]:   LD,
+	_OpcodeName[62:64]:        ST,
+	_OpcodeLowerName[62:64]:   ST,
+	_OpcodeName[64:67]:        LD8,
+	_OpcodeLowerName[64:67]:   LD8,
+	_OpcodeName[67:70]:        ST8,
+	_OpcodeLowerName[67:70]:   ST8,
+	_OpcodeName[70:74]:        LD16,
+	_OpcodeLowerName[70:74]:   LD16,
+	_OpcodeName[74:78]:        ST16,
+	_OpcodeLowerName[74:78]:   ST16,
+	_OpcodeName[78:83]:        BR_EQ,
+	_OpcodeLowerName[78:83]:   BR_EQ,
+	_OpcodeName[83:89]:        BR_NEQ,
+	_OpcodeLowerName[83:89]:   BR_NEQ,
+	_OpcodeName[89:95]:        BR_U_L,
+	_OpcodeLowerName[89:95]:   BR_U_L,
+	_OpcodeName[95:102]:       BR_U_LE,
+	_OpcodeLowerName[95:102]:  BR_U_LE,
+	_OpcodeName[102:109]:      BR_U_GE,
+	_OpcodeLowerName[102:109]: BR_U_GE,
+	_OpcodeName[109:115]:      BR_U_G,
+	_OpcodeLowerName[109:115]: BR_U_G,
+	_OpcodeName[115:121]:      BR_S_L,
+	_OpcodeLowerName[115:121]: BR_S_L,
+	_OpcodeName[121:128]:      BR_S_LE,
+	_OpcodeLowerName[121:128]: BR_S_LE,
+	_OpcodeName[128:135]:      BR_S_GE,
+	_OpcodeLowerName[128:135]: BR_S_GE,
+	_OpcodeName[135:141]:      BR_S_G,
+	_OpcodeLowerName[135:141]: BR_S_G,
+	_OpcodeName[141:144]:      BRA,
+	_OpcodeLowerName[141:144]: BRA,
+	_OpcodeName[144:148]:      CALL,
+	_OpcodeLowerName[144:148]: CALL,
+	_OpcodeName[148:151]:      RET,
+	_OpcodeLowerName[148:151]: RET,
+	_OpcodeName[151:154]:      JMP,
+	_OpcodeLowerName[151:154]: JMP,
+	_OpcodeName[154:157]:      CMP,
+	_OpcodeLowerName[154:157]: CMP,
+	_OpcodeName[157:160]:      NEG,
+	_OpcodeLowerName[157:160]: NEG,
+	_OpcodeName[160:164]:      NEGB,
+	_OpcodeLowerName[160:164]: NEGB,
+	_OpcodeName[164:167]:      NOT,
+	_OpcodeLowerName[164:167]: NOT,
+	_OpcodeName[167:170]:      MOV,
+	_OpcodeLowerName[167:170]: MOV,
+	_OpcodeName[170:173]:      LDI,
+	_OpcodeLowerName[170:173]: LDI,
+	_OpcodeName[173:176]:      SWP,
+	_OpcodeLowerName[173:176]: SWP,
+	_OpcodeName[176:182]:      NumOps,
+	_OpcodeLowerName[176:182]: NumOps,
 }
 
 var _OpcodeNames = []string{
@@ -172,34 +187,39 @@ var _OpcodeNames = []string{
 	_OpcodeName[34:37],
 	_OpcodeName[37:40],
 	_OpcodeName[40:43],
-	_OpcodeName[43:45],
-	_OpcodeName[45:47],
-	_OpcodeName[47:50],
-	_OpcodeName[50:53],
-	_OpcodeName[53:57],
-	_OpcodeName[57:61],
-	_OpcodeName[61:66],
-	_OpcodeName[66:72],
-	_OpcodeName[72:78],
-	_OpcodeName[78:85],
-	_OpcodeName[85:92],
-	_OpcodeName[92:98],
-	_OpcodeName[98:104],
-	_OpcodeName[104:111],
-	_OpcodeName[111:118],
-	_OpcodeName[118:124],
-	_OpcodeName[124:127],
-	_OpcodeName[127:131],
-	_OpcodeName[131:134],
-	_OpcodeName[134:137],
-	_OpcodeName[137:140],
-	_OpcodeName[140:143],
-	_OpcodeName[143:147],
-	_OpcodeName[147:150],
-	_OpcodeName[150:153],
-	_OpcodeName[153:156],
-	_OpcodeName[156:159],
-	_OpcodeName[159:165],
+	_OpcodeName[43:46],
+	_OpcodeName[46:49],
+	_OpcodeName[49:53],
+	_OpcodeName[53:56],
+	_OpcodeName[56:60],
+	_OpcodeName[60:62],
+	_OpcodeName[62:64],
+	_OpcodeName[64:67],
+	_OpcodeName[67:70],
+	_OpcodeName[70:74],
+	_OpcodeName[74:78],
+	_OpcodeName[78:83],
+	_OpcodeName[83:89],
+	_OpcodeName[89:95],
+	_OpcodeName[95:102],
+	_OpcodeName[102:109],
+	_OpcodeName[109:115],
+	_OpcodeName[115:121],
+	_OpcodeName[121:128],
+	_OpcodeName[128:135],
+	_OpcodeName[135:141],
+	_OpcodeName[141:144],
+	_OpcodeName[144:148],
+	_OpcodeName[148:151],
+	_OpcodeName[151:154],
+	_OpcodeName[154:157],
+	_OpcodeName[157:160],
+	_OpcodeName[160:164],
+	_OpcodeName[164:167],
+	_OpcodeName[167:170],
+	_OpcodeName[170:173],
+	_OpcodeName[173:176],
+	_OpcodeName[176:182],
 }
 
 // OpcodeString retrieves an enum value from the enum constants string name.
@@ -6,6 +6,7 @@ import (
 
 	"github.com/rj45/nanogo/codegen/asm"
 	"github.com/rj45/nanogo/ir/op"
+	"github.com/rj45/nanogo/ir2"
 )
 
 type Opcode int
@@ -29,6 +30,11 @@ const (
 	SHL
 	ASR
 	LSR
+	MUL
+	DIV
+	DIVU
+	REM
+	REMU
 
 	LD
 	ST
@@ -81,48 +87,96 @@ func (op Opcode) IsCall() bool {
 	return op == CALL
 }
 
+func (op Opcode) IsCommutative() bool {
+	return opDefs[op].flags&commutative != 0
+}
+
+func (op Opcode) IsCompare() bool {
+	return opDefs[op].flags&compare != 0
+}
+
+func (op Opcode) IsBranch() bool {
+	return opDefs[op].flags&compare != 0
+}
+
+func (op Opcode) IsCopy() bool {
+	return op == MOV
+}
+
+func (op Opcode) IsSink() bool {
+	return opDefs[op].flags&sink != 0
+}
+
+func (op Opcode) ClobbersArg() bool {
+	return false
+}
+
+func (op Opcode) IsReturn() bool {
+	return op == RET
+}
+
+// Arch returns the name of the arch the instruction is from, which
+// it's qualified with when the IR is written out
+func (op Opcode) Arch() string {
+	return cpuArch{}.Name()
+}
+
+type flags uint16
+
+const (
+	commutative flags = 1 << iota
+	compare
+	sink
+)
+
 type def struct {
-	fmt Fmt
-	op  op.Op
-	asm string
+	fmt   Fmt
+	op    op.Op
+	asm   string
+	flags flags
 }
 
 var opDefs = [...]def{
-	NOP:     {fmt: NoFmt},
-	BRK:     {fmt: NoFmt},
-	HLT:     {fmt: NoFmt},
-	ERR:     {fmt: NoFmt},
-	ADD:     {fmt: BinaryFmt, op: op.Add},
+	NOP:     {fmt: NoFmt, flags: sink},
+	BRK:     {fmt: NoFmt, flags: sink},
+	HLT:     {fmt: NoFmt, flags: sink},
+	ERR:     {fmt: NoFmt, flags: sink},
+	ADD:     {fmt: BinaryFmt, op: op.Add, flags: commutative},
 	SUB:     {fmt: BinaryFmt, op: op.Sub},
-	ADDC:    {fmt: BinaryFmt},
+	ADDC:    {fmt: BinaryFmt, flags: commutative},
 	SUBB:    {fmt: BinaryFmt},
-	AND:     {fmt: BinaryFmt, op: op.And},
-	OR:      {fmt: BinaryFmt, op: op.Or},
-	XOR:     {fmt: BinaryFmt, op: op.Xor},
+	AND:     {fmt: BinaryFmt, op: op.And, flags: commutative},
+	OR:      {fmt: BinaryFmt, op: op.Or, flags: commutative},
+	XOR:     {fmt: BinaryFmt, op: op.Xor, flags: commutative},
 	SHL:     {fmt: BinaryFmt, op: op.ShiftLeft},
 	ASR:     {fmt: BinaryFmt},
 	LSR:     {fmt: BinaryFmt, op: op.ShiftRight},
+	MUL:     {fmt: BinaryFmt, flags: commutative},
+	DIV:     {fmt: BinaryFmt},
+	DIVU:    {fmt: BinaryFmt},
+	REM:     {fmt: BinaryFmt},
+	REMU:    {fmt: BinaryFmt},
 	LD:      {fmt: LoadFmt},
-	ST:      {fmt: StoreFmt},
+	ST:      {fmt: StoreFmt, flags: sink},
 	LD8:     {fmt: LoadFmt},
-	ST8:     {fmt: StoreFmt},
+	ST8:     {fmt: StoreFmt, flags: sink},
 	LD16:    {fmt: LoadFmt},
-	ST16:    {fmt: StoreFmt},
-	BR_EQ:   {fmt: CallFmt},
-	BR_NEQ:  {fmt: CallFmt},
-	BR_U_L:  {fmt: CallFmt},
-	BR_U_LE: {fmt: CallFmt},
-	BR_U_GE: {fmt: CallFmt},
-	BR_U_G:  {fmt: CallFmt},
-	BR_S_L:  {fmt: CallFmt},
-	BR_S_LE: {fmt: CallFmt},
-	BR_S_GE: {fmt: CallFmt},
-	BR_S_G:  {fmt: CallFmt},
-	BRA:     {fmt: CallFmt},
+	ST16:    {fmt: StoreFmt, flags: sink},
+	BR_EQ:   {fmt: CallFmt, flags: commutative | compare | sink},
+	BR_NEQ:  {fmt: CallFmt, flags: commutative | compare | sink},
+	BR_U_L:  {fmt: CallFmt, flags: compare | sink},
+	BR_U_LE: {fmt: CallFmt, flags: compare | sink},
+	BR_U_GE: {fmt: CallFmt, flags: compare | sink},
+	BR_U_G:  {fmt: CallFmt, flags: compare | sink},
+	BR_S_L:  {fmt: CallFmt, flags: compare | sink},
+	BR_S_LE: {fmt: CallFmt, flags: compare | sink},
+	BR_S_GE: {fmt: CallFmt, flags: compare | sink},
+	BR_S_G:  {fmt: CallFmt, flags: compare | sink},
+	BRA:     {fmt: CallFmt, flags: sink},
 	CALL:    {fmt: CallFmt, op: op.Call},
-	RET:     {fmt: CallFmt},
-	JMP:     {fmt: CallFmt},
-	CMP:     {fmt: CompareFmt},
+	RET:     {fmt: CallFmt, flags: sink},
+	JMP:     {fmt: CallFmt, flags: sink},
+	CMP:     {fmt: CompareFmt, flags: sink},
 	NEG:     {fmt: UnaryFmt, op: op.Negate},
 	NEGB:    {fmt: UnaryFmt},
 	NOT:     {fmt: UnaryFmt, op: op.Invert},
@@ -147,6 +201,13 @@ func init() {
 	}
 }
 
+// ParseOp returns the instruction with the name, for parsing IR
+// that's been lowered to the arch's instructions
+func (cpuArch) ParseOp(name string) (ir2.Op, bool) {
+	op, err := OpcodeString(name)
+	return op, err == nil
+}
+
 func (cpuArch) IsTwoOperand() bool {
 	return false
 }
@@ -75,9 +75,10 @@ func (cpuArch) ArgRegs() []reg.Reg {
 
 func (cpuArch) SpecialRegs() map[string]reg.Reg {
 	return map[string]reg.Reg{
-		"SP": reg.FromRegNum(int(SP)),
-		"FP": reg.FromRegNum(int(BP)),
-		"GP": reg.FromRegNum(int(Zero)),
-		"RA": reg.FromRegNum(int(RA)),
+		"SP":  reg.FromRegNum(int(SP)),
+		"FP":  reg.FromRegNum(int(BP)),
+		"GP":  reg.FromRegNum(int(Zero)),
+		"RA":  reg.FromRegNum(int(RA)),
+		"CTX": reg.FromRegNum(int(T9)),
 	}
 }
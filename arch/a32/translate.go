@@ -0,0 +1,164 @@
+package a32
+
+import (
+	"go/types"
+	"log"
+
+	"github.com/rj45/nanogo/diag"
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
+)
+
+var directTranslate = map[op.Op]Opcode{
+	op.Add:       ADD,
+	op.Sub:       SUB,
+	op.AddCarry:  ADDC,
+	op.SubBorrow: SUBB,
+	op.And:       AND,
+	op.Or:        OR,
+	op.Xor:       XOR,
+	op.ShiftLeft: SHL,
+	op.Mul:       MUL,
+	op.Invert:    NOT,
+	op.Negate:    NEG,
+	op.Return:    RET,
+	op.Jump:      BRA,
+}
+
+var branchesSigned = map[op.Op]Opcode{
+	op.Equal:        BR_EQ,
+	op.NotEqual:     BR_NEQ,
+	op.Less:         BR_S_L,
+	op.LessEqual:    BR_S_LE,
+	op.Greater:      BR_S_G,
+	op.GreaterEqual: BR_S_GE,
+}
+
+var branchesUnsigned = map[op.Op]Opcode{
+	op.Equal:        BR_EQ,
+	op.NotEqual:     BR_NEQ,
+	op.Less:         BR_U_L,
+	op.LessEqual:    BR_U_LE,
+	op.Greater:      BR_U_G,
+	op.GreaterEqual: BR_U_GE,
+}
+
+func translate(it ir2.Iter) {
+	instr := it.Instr()
+	originalOp := instr.Op
+	switch instr.Op {
+	case op.Copy:
+		// copy is done in the finishing stage, after register allocation
+	case op.Add, op.Sub, op.AddCarry, op.SubBorrow, op.And, op.Or, op.Xor, op.ShiftLeft, op.Mul, op.Invert, op.Negate, op.Return, op.Jump:
+		it.Update(directTranslate[instr.Op.(op.Op)], nil, instr.Args())
+	case op.ShiftRight:
+		op := LSR
+		if isSigned(instr.Def(0).Type) {
+			op = ASR
+		}
+		it.Update(op, nil, instr.Args())
+	case op.Div:
+		op := DIVU
+		if isSigned(instr.Def(0).Type) {
+			op = DIV
+		}
+		it.Update(op, nil, instr.Args())
+	case op.Rem:
+		op := REMU
+		if isSigned(instr.Def(0).Type) {
+			op = REM
+		}
+		it.Update(op, nil, instr.Args())
+	case op.Not:
+		// bools are 0 or 1, so flipping the lowest bit negates them
+		it.Update(XOR, nil, instr.Arg(0), 1)
+	case op.Equal, op.NotEqual, op.Less, op.LessEqual, op.Greater, op.GreaterEqual:
+		def := instr.Def(0)
+		if def.NumUses() > 1 || def.Use(0).Instr().Op != op.If {
+			diag.Errorf(instr.Pos, "using a comparison as a value is not supported yet")
+		}
+	case op.If:
+		compare := instr.Arg(0).Def().Instr()
+		if !compare.IsCompare() {
+			log.Panicf("expecting if to have compare, but instead had: %s", compare.LongString())
+		}
+
+		branchOp := branchesUnsigned[compare.Op.(op.Op)]
+		if isSigned(compare.Arg(0).Type) {
+			branchOp = branchesSigned[compare.Op.(op.Op)]
+		}
+		if branchOp == NOP {
+			log.Panicf("failed to translate compare %s", compare.Op.(op.Op))
+		}
+		it.Update(branchOp, nil, compare.Args())
+		if compare.Def(0).NumUses() == 0 {
+			it.RemoveInstr(compare)
+		}
+	case op.Panic:
+		it.Update(ERR, nil, instr.Args())
+	case op.Call:
+		instr.Op = CALL
+		it.Changed()
+	}
+	if it.Instr() == nil {
+		log.Panicf("translating %s from %s left iter in bad state", originalOp, instr.LongString())
+	}
+}
+
+// isSigned returns whether the type is a signed integer
+func isSigned(typ types.Type) bool {
+	if basic, ok := typ.Underlying().(*types.Basic); ok {
+		return basic.Info()&(types.IsInteger|types.IsUnsigned) == types.IsInteger
+	}
+	return false
+}
+
+// translateLoadStores translates loads and stores once they have
+// been made relative to a register after register allocation,
+// picking the instruction by the size of the value
+func translateLoadStores(it ir2.Iter) {
+	instr := it.Instr()
+	switch instr.Op {
+	case op.Load:
+		it.Update(sized(instr, instr.Def(0).Type, LD8, LD16, LD), instr.Def(0).Type, instr.Args())
+	case op.Store:
+		it.Update(sized(instr, instr.Arg(2).Type, ST8, ST16, ST), nil, instr.Args())
+	}
+}
+
+// sized picks the opcode for accessing a value of the type. Strings,
+// slices and interfaces in a register are a pointer to their header,
+// and copies of them as a word can be eliminated, so they're a word.
+func sized(instr *ir2.Instr, typ types.Type, op8, op16, op32 Opcode) Opcode {
+	size := sizes.Sizeof(typ)
+	switch typ := typ.Underlying().(type) {
+	case *types.Slice, *types.Interface:
+		size = sizes.WordSize()
+	case *types.Basic:
+		if typ.Kind() == types.String {
+			size = sizes.WordSize()
+		}
+	}
+
+	switch size {
+	case 1:
+		return op8
+	case 2:
+		return op16
+	case 4:
+		return op32
+	}
+	log.Panicf("access of unsupported size %d in %s", size, instr.LongString())
+	return NOP
+}
+
+func translateCopies(it ir2.Iter) {
+	instr := it.Instr()
+
+	opcode := MOV
+	if !instr.Arg(0).InReg() {
+		opcode = LDI
+	}
+	it.Update(opcode, instr.Def(0).Type, instr.Args())
+}
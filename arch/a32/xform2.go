@@ -1,10 +1,17 @@
 package a32
 
-import "github.com/rj45/nanogo/xform2"
+import (
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/xform2"
+)
 
 func (cpuArch) XformTags2() []xform2.Tag {
-	return nil
+	return []xform2.Tag{xform2.HasFramePointer, xform2.LoadStoreOffset, xform2.HasHardwareMul, xform2.HasHardwareDiv}
 }
 
 func (cpuArch) RegisterXforms() {
+	xform2.Register(translate, xform2.Passes(xform2.Lowering, xform2.Finishing))
+	xform2.Register(translateLoadStores, xform2.OnlyPass(xform2.Finishing), xform2.OnOp(op.Load))
+	xform2.Register(translateLoadStores, xform2.OnlyPass(xform2.Finishing), xform2.OnOp(op.Store))
+	xform2.Register(translateCopies, xform2.OnlyPass(xform2.Finishing), xform2.OnOp(op.Copy))
 }
@@ -10,6 +10,8 @@ import (
 	"github.com/rj45/nanogo/frontend"
 	"github.com/rj45/nanogo/ir/op"
 	"github.com/rj45/nanogo/ir/reg"
+	"github.com/rj45/nanogo/ir2/interp"
+	"github.com/rj45/nanogo/ir2/parseir"
 	"github.com/rj45/nanogo/parser"
 	"github.com/rj45/nanogo/sizes"
 	"github.com/rj45/nanogo/xform"
@@ -29,6 +31,8 @@ type Architecture interface {
 	frontend.Arch
 	xform2.Arch
 	asm2.Arch
+	interp.Arch
+	parseir.Arch
 }
 
 var arch Architecture
@@ -66,4 +70,6 @@ func SetArch(name string) {
 	frontend.SetArch(arch)
 	xform2.SetArch(arch)
 	asm2.SetArch(arch)
+	interp.SetArch(arch)
+	parseir.SetArch(arch)
 }
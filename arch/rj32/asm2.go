@@ -4,28 +4,47 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/rj45/nanogo/asm2"
 	"github.com/rj45/nanogo/ir2"
 )
 
 func (cpuArch) Asm(op ir2.Op, defs, args []string) string {
 	switch op {
 	case Load, Loadb:
-		return fmt.Sprintf("%s %s, [%s, %s]", op, defs[0], args[0], args[1])
+		return fmt.Sprintf("%s %s, [%s, %s]", op.(Opcode).Asm(), defs[0], args[0], args[1])
 	case Store, Storeb:
-		return fmt.Sprintf("%s [%s, %s], %s", op, args[0], args[1], args[2])
+		return fmt.Sprintf("%s [%s, %s], %s", op.(Opcode).Asm(), args[0], args[1], args[2])
 	case Return:
 		return "return"
 	case Call:
+		if _, err := RegString(args[0]); err == nil {
+			// there's no call to a register, so func values are
+			// called through a trampoline in rungo.asm
+			return "call callClosure"
+		}
 		return "call " + args[0]
+	case Error:
+		return "error"
+	case IfEq, IfNe, IfLt, IfGe, IfUlt, IfUge, IfGt, IfLe, IfUgt, IfUle:
+		// the next instruction is skipped if the condition is false
+		return fmt.Sprintf("%s %s, %s\n  jump %s", op.(Opcode).Asm(), args[0], args[1], args[2])
 	default:
+		name := op.String()
+		if opcode, ok := op.(Opcode); ok {
+			name = opcode.Asm()
+		}
 		if op.ClobbersArg() {
 			args = args[1:]
 		}
 		if len(defs) > 0 && len(args) > 0 {
-			return op.String() + " " + strings.Join(defs, ", ") + ", " + strings.Join(args, ", ")
+			return name + " " + strings.Join(defs, ", ") + ", " + strings.Join(args, ", ")
 		} else if len(defs) > 0 {
-			return op.String() + " " + strings.Join(defs, ", ")
+			return name + " " + strings.Join(defs, ", ")
 		}
-		return op.String() + " " + strings.Join(args, ", ")
+		return name + " " + strings.Join(args, ", ")
 	}
 }
+
+func (cpuArch) DataSection() asm2.Section {
+	return asm2.Data
+}
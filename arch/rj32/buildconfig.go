@@ -1,13 +1,18 @@
 package rj32
 
+import (
+	"io"
+
+	"github.com/rj45/nanogo/compiler"
+)
+
+// maxCycles stops programs that never halt
+const maxCycles = 1000000000
+
 func (cpuArch) AssemblerFormat() string {
 	return "logisim16"
 }
 
-func (cpuArch) EmulatorCmd() string {
-	return "emurj"
-}
-
-func (cpuArch) EmulatorArgs() []string {
-	return []string{"-run"}
+func (cpuArch) NewEmulator(out, trace io.Writer) compiler.Emulator {
+	return &Emulator{Out: out, Trace: trace, MaxCycles: maxCycles}
 }
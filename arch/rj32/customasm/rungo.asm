@@ -1,5 +1,8 @@
 stackStartAddress = 0xFEFF
 
+; the heap runs from the end of bss up to the data bank
+heapEndAddress = 0x8000
+
 ; run go's main__main function
 
 ; initialize the stack and global pointer
@@ -25,3 +28,10 @@ move a0, 0
 
 halt
 
+
+; func values point to a closure object, which starts with the address
+; of the func's code, and the caller puts it in the context register
+; (t4). There's no call to a register, so calls jump through here.
+callClosure:
+load t3, [t4, 0]
+jump t3
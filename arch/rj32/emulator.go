@@ -0,0 +1,294 @@
+package rj32
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// memory map of the emulated machine
+const (
+	codeWords = 0x10000
+	dataStart = 0x8000
+	consoleIO = 0xFF00
+)
+
+// ErrCPUError is returned when the program executes the error instruction
+var ErrCPUError = errors.New("cpu executed error instruction")
+
+// ErrMaxCycles is returned when the program runs too long
+var ErrMaxCycles = errors.New("exceeded maximum cycle count")
+
+// Emulator is an instruction set simulator for rj32
+type Emulator struct {
+	// Out receives the characters written to the console
+	Out io.Writer
+
+	// Trace receives a line per instruction executed if not nil
+	Trace io.Writer
+
+	// MaxCycles is the maximum number of cycles to run, or 0 for no limit
+	MaxCycles uint64
+
+	cycles uint64
+
+	regs  [16]uint16
+	pc    uint16
+	carry bool
+
+	code [codeWords]uint16
+	mem  [0x10000]uint16
+}
+
+// Load loads a program image in logisim16 format. The first
+// 64k words are the code, the words after that are the
+// pre-initialized data.
+func (emu *Emulator) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	scanner.Split(bufio.ScanWords)
+
+	if !scanner.Scan() || scanner.Text() != "v2.0" || !scanner.Scan() || scanner.Text() != "raw" {
+		return errors.New("image is not in logisim v2.0 raw format")
+	}
+
+	addr := 0
+	for scanner.Scan() {
+		word := scanner.Text()
+		count := 1
+		if star := strings.Index(word, "*"); star > 0 {
+			n, err := strconv.Atoi(word[:star])
+			if err != nil {
+				return fmt.Errorf("bad run length in image: %s", word)
+			}
+			count = n
+			word = word[star+1:]
+		}
+
+		v, err := strconv.ParseUint(word, 16, 16)
+		if err != nil {
+			return fmt.Errorf("bad word in image: %s", word)
+		}
+
+		for i := 0; i < count; i++ {
+			if addr < codeWords {
+				emu.code[addr] = uint16(v)
+			} else if addr-codeWords+dataStart < len(emu.mem) {
+				emu.mem[addr-codeWords+dataStart] = uint16(v)
+			}
+			addr++
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Run runs the program until it halts, returning the exit code
+// which is the value of a0 when the halt instruction was executed.
+func (emu *Emulator) Run() (int, error) {
+	for {
+		done, err := emu.Step()
+		if err != nil {
+			return 1, err
+		}
+		if done {
+			return int(int16(emu.regs[A0])), nil
+		}
+		if emu.MaxCycles != 0 && emu.cycles >= emu.MaxCycles {
+			return 1, ErrMaxCycles
+		}
+	}
+}
+
+// Cycles returns the number of cycles executed so far
+func (emu *Emulator) Cycles() uint64 {
+	return emu.cycles
+}
+
+func signExtend(v uint16, bits uint) uint16 {
+	shift := 16 - bits
+	return uint16(int16(v<<shift) >> shift)
+}
+
+// Step executes a single instruction (including its imm prefix), and
+// returns true if the cpu halted.
+func (emu *Emulator) Step() (bool, error) {
+	start := emu.pc
+	word := emu.fetch()
+
+	prefixed := false
+	var prefix uint16
+	if word&0xf == 0b1101 {
+		prefixed = true
+		prefix = word & 0xfff0
+		word = emu.fetch()
+	}
+
+	imm := func(field uint16, bits uint, signed bool) uint16 {
+		if prefixed {
+			return prefix | (field & 0xf)
+		}
+		if signed {
+			return signExtend(field, bits)
+		}
+		return field
+	}
+
+	rd := word >> 12
+	rs := (word >> 8) & 0xf
+
+	if emu.Trace != nil {
+		fmt.Fprintf(emu.Trace, "%04x: %04x %v\n", start, word, emu.regs)
+	}
+
+	switch {
+	case word&0b11 == 0b00: // fmtRR
+		op := Opcode((word >> 2) & 0x3f)
+		switch op {
+		case Nop, Rets:
+		case Error:
+			return true, fmt.Errorf("%w at pc 0x%04x with a0 = %d", ErrCPUError, start, int16(emu.regs[A0]))
+		case Halt:
+			return true, nil
+		case Move:
+			emu.regs[rd] = emu.regs[rs]
+		case Jump:
+			emu.pc = emu.regs[rd]
+		case Call:
+			target := emu.regs[rd]
+			emu.regs[RA] = emu.pc
+			emu.pc = target
+		default:
+			if op < Add || op > IfUge {
+				return true, fmt.Errorf("illegal instruction 0x%04x at pc 0x%04x", word, start)
+			}
+			emu.alu(op, rd, emu.regs[rs])
+		}
+
+	case word&0b11 == 0b11: // fmtRI6
+		op := Opcode((word>>2)&0xf) + Add
+		emu.alu(op, rd, imm((word>>6)&0x3f, 6, true))
+
+	case word&0b111 == 0b001: // fmtRI8
+		if (word>>3)&1 != 0 {
+			return true, fmt.Errorf("illegal instruction 0x%04x at pc 0x%04x", word, start)
+		}
+		emu.regs[rd] = imm((word>>4)&0xff, 8, true)
+
+	case word&0b1111 == 0b0101: // fmtI11
+		offset := imm(word>>5, 11, true)
+		if (word>>4)&1 != 0 {
+			emu.regs[RA] = emu.pc
+		}
+		emu.pc += offset
+
+	case word&0b11 == 0b10: // fmtLS
+		addr := emu.regs[rs] + imm((word>>4)&0xf, 4, false)
+		switch Opcode((word>>2)&0x3) + Load {
+		case Load:
+			emu.regs[rd] = emu.load(addr)
+		case Store:
+			emu.store(addr, emu.regs[rd])
+		case Loadb:
+			emu.regs[rd] = emu.load(addr) & 0xff
+		case Storeb:
+			emu.store(addr, emu.regs[rd]&0xff)
+		}
+
+	default:
+		return true, fmt.Errorf("illegal instruction 0x%04x at pc 0x%04x", word, start)
+	}
+
+	return false, nil
+}
+
+func (emu *Emulator) fetch() uint16 {
+	word := emu.code[emu.pc]
+	emu.pc++
+	emu.cycles++
+	return word
+}
+
+func (emu *Emulator) load(addr uint16) uint16 {
+	if addr == consoleIO {
+		return 0
+	}
+	return emu.mem[addr]
+}
+
+func (emu *Emulator) store(addr uint16, val uint16) {
+	if addr == consoleIO {
+		if emu.Out != nil {
+			emu.Out.Write([]byte{byte(val)})
+		}
+		return
+	}
+	emu.mem[addr] = val
+}
+
+// skip skips the next instruction, including its imm prefix
+func (emu *Emulator) skip() {
+	if emu.code[emu.pc]&0xf == 0b1101 {
+		emu.pc++
+	}
+	emu.pc++
+}
+
+func (emu *Emulator) alu(op Opcode, rd uint16, b uint16) {
+	a := emu.regs[rd]
+	var carry uint32
+	if emu.carry {
+		carry = 1
+	}
+
+	switch op {
+	case Add:
+		sum := uint32(a) + uint32(b)
+		emu.carry = sum > 0xffff
+		emu.regs[rd] = uint16(sum)
+	case Addc:
+		sum := uint32(a) + uint32(b) + carry
+		emu.carry = sum > 0xffff
+		emu.regs[rd] = uint16(sum)
+	case Sub:
+		emu.carry = uint32(b) > uint32(a)
+		emu.regs[rd] = a - b
+	case Subc:
+		emu.carry = uint32(b)+carry > uint32(a)
+		emu.regs[rd] = a - b - uint16(carry)
+	case Xor:
+		emu.regs[rd] = a ^ b
+	case And:
+		emu.regs[rd] = a & b
+	case Or:
+		emu.regs[rd] = a | b
+	case Shl:
+		emu.regs[rd] = a << (b & 0xf)
+	case Shr:
+		emu.regs[rd] = a >> (b & 0xf)
+	case Asr:
+		emu.regs[rd] = uint16(int16(a) >> (b & 0xf))
+	case IfEq, IfNe, IfLt, IfGe, IfUlt, IfUge:
+		var cond bool
+		switch op {
+		case IfEq:
+			cond = a == b
+		case IfNe:
+			cond = a != b
+		case IfLt:
+			cond = int16(a) < int16(b)
+		case IfGe:
+			cond = int16(a) >= int16(b)
+		case IfUlt:
+			cond = a < b
+		case IfUge:
+			cond = a >= b
+		}
+		if !cond {
+			emu.skip()
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package rj32
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// rr, ri6, ri8, ls, i11 and prefix encode an instruction in each of
+// the formats the emulator decodes
+
+func rr(op Opcode, rd, rs Reg) uint16 {
+	return uint16(rd)<<12 | uint16(rs)<<8 | uint16(op)<<2
+}
+
+func ri6(op Opcode, rd Reg, imm int) uint16 {
+	return uint16(rd)<<12 | uint16(imm&0x3f)<<6 | uint16(op-Add)<<2 | 0b11
+}
+
+func ri8(rd Reg, imm int) uint16 {
+	return uint16(rd)<<12 | uint16(imm&0xff)<<4 | 0b001
+}
+
+func ls(op Opcode, rd, rs Reg, offset int) uint16 {
+	return uint16(rd)<<12 | uint16(rs)<<8 | uint16(offset&0xf)<<4 | uint16(op-Load)<<2 | 0b10
+}
+
+func i11(link bool, offset int) uint16 {
+	word := uint16(offset&0x7ff)<<5 | 0b0101
+	if link {
+		word |= 1 << 4
+	}
+	return word
+}
+
+func prefix(imm uint16) uint16 {
+	return imm&0xfff0 | 0b1101
+}
+
+// run runs the code until it halts or errors
+func run(t *testing.T, code ...uint16) (*Emulator, int, error) {
+	t.Helper()
+
+	emu := &Emulator{MaxCycles: 1000}
+	copy(emu.code[:], code)
+	exit, err := emu.Run()
+	return emu, exit, err
+}
+
+func TestEmulator_Load(t *testing.T) {
+	emu := &Emulator{}
+	image := "v2.0 raw\n1234 2*abcd\n65533*0 beef\n"
+	if err := emu.Load(strings.NewReader(image)); err != nil {
+		t.Fatal(err)
+	}
+
+	for addr, want := range []uint16{0x1234, 0xabcd, 0xabcd, 0} {
+		if emu.code[addr] != want {
+			t.Errorf("expected code word %d to be 0x%04x but got 0x%04x", addr, want, emu.code[addr])
+		}
+	}
+	if emu.mem[dataStart] != 0xbeef {
+		t.Errorf("expected the data after the code to be loaded at 0x%04x but got 0x%04x", dataStart, emu.mem[dataStart])
+	}
+
+	if err := emu.Load(strings.NewReader("v3.0 hex\n1234\n")); err == nil {
+		t.Error("expected an error loading an image in the wrong format")
+	}
+	if err := emu.Load(strings.NewReader("v2.0 raw\nxyz\n")); err == nil {
+		t.Error("expected an error loading a bad word")
+	}
+}
+
+func TestEmulator_decodes(t *testing.T) {
+	tests := []struct {
+		desc string
+		code []uint16
+		reg  Reg
+		want uint16
+	}{
+		{"move immediate", []uint16{ri8(A0, -3)}, A0, 0xfffd},
+		{"imm prefix", []uint16{prefix(0x1230), ri8(A0, 4)}, A0, 0x1234},
+		{"register add", []uint16{ri8(A0, 5), ri8(A1, 7), rr(Add, A0, A1)}, A0, 12},
+		{"immediate add", []uint16{ri8(A0, 5), ri6(Add, A0, -7)}, A0, 0xfffe},
+		{"move", []uint16{ri8(A1, 9), rr(Move, A0, A1)}, A0, 9},
+		{"shift", []uint16{ri8(A0, -16), ri6(Asr, A0, 2)}, A0, 0xfffc},
+		{"logical shift", []uint16{ri8(A0, -16), ri6(Shr, A0, 2)}, A0, 0x3ffc},
+		{"store and load", []uint16{
+			prefix(0x8000), ri8(A1, 0), ri8(A2, 42),
+			ls(Store, A2, A1, 3), ls(Load, A0, A1, 3),
+		}, A0, 42},
+		{"load byte", []uint16{
+			prefix(0x8000), ri8(A1, 0), prefix(0x1230), ri8(A2, 4),
+			ls(Store, A2, A1, 0), ls(Loadb, A0, A1, 0),
+		}, A0, 0x34},
+		{"jump", []uint16{i11(false, 1), ri8(A0, 1), ri8(A1, 2)}, A0, 0},
+		{"call", []uint16{i11(true, 1), ri8(A0, 1), ri8(A1, 2)}, RA, 1},
+		{"skip when false", []uint16{ri8(A0, 1), ri6(IfEq, A0, 2), ri8(A0, 5)}, A0, 1},
+		{"no skip when true", []uint16{ri8(A0, 1), ri6(IfLt, A0, 2), ri8(A0, 5)}, A0, 5},
+		{"no skip with imm prefix", []uint16{ri8(A0, 1), ri6(IfUlt, A0, -1), prefix(0x1230), ri8(A0, 4)}, A0, 0x1234},
+		{"skip past imm prefix", []uint16{ri8(A0, 1), ri6(IfUge, A0, -1), prefix(0x1230), ri8(A0, 4)}, A0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			emu, _, err := run(t, append(tt.code, rr(Halt, 0, 0))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := emu.regs[tt.reg]; got != tt.want {
+				t.Errorf("expected %s to be 0x%04x but got 0x%04x", tt.reg, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEmulator_carry(t *testing.T) {
+	tests := []struct {
+		desc  string
+		code  []uint16
+		want  uint16
+		carry bool
+	}{
+		{"add without carry", []uint16{ri8(A0, 1), ri6(Add, A0, 1)}, 2, false},
+		{"add with carry out", []uint16{ri8(A0, -1), ri6(Add, A0, 2)}, 1, true},
+		{"add with carry in", []uint16{ri8(A0, -1), ri6(Add, A0, 1), ri6(Addc, A0, 1)}, 2, false},
+		{"sub without borrow", []uint16{ri8(A0, 3), ri6(Sub, A0, 1)}, 2, false},
+		{"sub with borrow out", []uint16{ri8(A0, 1), ri6(Sub, A0, 2)}, 0xffff, true},
+		{"sub with borrow in", []uint16{ri8(A0, 0), ri6(Sub, A0, 1), ri8(A0, 5), ri6(Subc, A0, 1)}, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			emu, _, err := run(t, append(tt.code, rr(Halt, 0, 0))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if emu.regs[A0] != tt.want || emu.carry != tt.carry {
+				t.Errorf("expected 0x%04x with carry %v but got 0x%04x with carry %v", tt.want, tt.carry, emu.regs[A0], emu.carry)
+			}
+		})
+	}
+}
+
+func TestEmulator_exitCodes(t *testing.T) {
+	tests := []struct {
+		desc string
+		code []uint16
+		exit int
+		err  error
+	}{
+		{"halt exits with a0", []uint16{ri8(A0, 3), rr(Halt, 0, 0)}, 3, nil},
+		{"halt exits with negative a0", []uint16{ri8(A0, -2), rr(Halt, 0, 0)}, -2, nil},
+		{"error", []uint16{ri8(A0, 7), rr(Error, 0, 0)}, 1, ErrCPUError},
+		{"runs too long", []uint16{i11(false, -1)}, 1, ErrMaxCycles},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, exit, err := run(t, tt.code...)
+			if exit != tt.exit || !errors.Is(err, tt.err) {
+				t.Errorf("expected exit code %d with %v but got %d with %v", tt.exit, tt.err, exit, err)
+			}
+		})
+	}
+
+	_, exit, err := run(t, rr(Rcsr, 0, 0))
+	if exit != 1 || err == nil || !strings.Contains(err.Error(), "illegal instruction") {
+		t.Errorf("expected an illegal instruction but got %d with %v", exit, err)
+	}
+}
+
+func TestEmulator_output(t *testing.T) {
+	var out, trace bytes.Buffer
+	emu := &Emulator{Out: &out, Trace: &trace}
+	copy(emu.code[:], []uint16{
+		prefix(0xff00), ri8(A1, 0),
+		ri8(A0, 'h'), ls(Store, A0, A1, 0),
+		ri8(A0, 'i'), ls(Store, A0, A1, 0),
+		rr(Halt, 0, 0),
+	})
+	if _, err := emu.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != "hi" {
+		t.Errorf("expected hi to be written to the console but got %q", out.String())
+	}
+
+	lines := strings.Split(strings.TrimSuffix(trace.String(), "\n"), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("expected a line per instruction but got:\n%s", trace.String())
+	}
+	if want := "0000: 2001 [0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0]"; lines[0] != want {
+		t.Errorf("expected the first line to be %q but got %q", want, lines[0])
+	}
+	if want := "0002: 1681 [0 0 65280 0 0 0 0 0 0 0 0 0 0 0 0 0]"; lines[1] != want {
+		t.Errorf("expected the second line to be %q but got %q", want, lines[1])
+	}
+	if emu.Cycles() != 7 {
+		t.Errorf("expected 7 cycles with the imm prefix but got %d", emu.Cycles())
+	}
+}
@@ -0,0 +1,399 @@
+package rj32
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rj45/nanogo/assembler"
+)
+
+// The native instruction formats. The low bits of each
+// instruction word select the format.
+//
+//	fmtRR:  rd`4 @ rs`4 @ op`6 @ 0b00
+//	fmtRI6: rd`4 @ imm`6 @ op`4 @ 0b11
+//	fmtRI8: rd`4 @ imm`8 @ op`1 @ 0b001
+//	fmtI11: imm`11 @ op`1 @ 0b0101
+//	fmtLS:  rd`4 @ rs`4 @ imm`4 @ op`2 @ 0b10
+//	fmtI12: imm`12 @ 0b1101
+//
+// The opcode in the fmtRR format is the Opcode itself. The other
+// formats use the Opcode minus the first opcode of its group.
+//
+// An instruction with an immediate that doesn't fit in its field is
+// prefixed with an `imm` instruction (fmtI12) holding bits 15:4 of
+// the immediate, and then only the low 4 bits of the field are used.
+const (
+	fmtRR uint16 = iota
+	fmtRI6
+	fmtRI8
+	fmtI11
+	fmtLS
+	fmtI12
+)
+
+// immediate field sizes and ranges for each format
+var immRanges = [...]struct{ min, max int64 }{
+	fmtRI6: {-(1 << 5), (1 << 5) - 1},
+	fmtRI8: {-(1 << 7), (1 << 7) - 1},
+	fmtI11: {-(1 << 10), (1 << 10) - 1},
+	fmtLS:  {0, (1 << 4) - 1},
+}
+
+var regNums = map[string]uint16{
+	"bp": uint16(GP),
+}
+
+func init() {
+	for i, name := range RegStrings() {
+		regNums[name] = uint16(i)
+		regNums[fmt.Sprintf("r%d", i)] = uint16(i)
+	}
+}
+
+// aliases for some of the mnemonics
+var aliases = map[string]string{
+	"if.lo": "if.ult",
+	"if.hs": "if.uge",
+}
+
+type encoder struct {
+	in    *assembler.Instr
+	words []uint64
+
+	// force an imm prefix on the first instruction with an immediate
+	force bool
+}
+
+// Encode encodes a single instruction for the native assembler.
+func (cpuArch) Encode(in *assembler.Instr) ([]uint64, error) {
+	words, err := encode(in, false)
+	if err != nil || len(words) >= in.MinUnits {
+		return words, err
+	}
+
+	// the assembler already allocated more space for this instruction
+	// in an earlier pass, so use the longer encoding if there is one
+	words, err = encode(in, true)
+	if err != nil {
+		return words, err
+	}
+	for len(words) < in.MinUnits {
+		words = append(words, uint64(Nop))
+	}
+	return words, nil
+}
+
+func encode(in *assembler.Instr, force bool) ([]uint64, error) {
+	e := &encoder{in: in, force: force}
+
+	mnemonic := in.Mnemonic
+	if alias, found := aliases[mnemonic]; found {
+		mnemonic = alias
+	}
+
+	if mnemonic == "sxt" {
+		if err := e.expect(1); err != nil {
+			return nil, err
+		}
+		rd, err := e.reg(0)
+		if err != nil {
+			return nil, err
+		}
+		e.ri6(Shl, rd, 8)
+		e.ri6(Asr, rd, 8)
+		return e.words, nil
+	}
+
+	opcode, err := OpcodeString(strings.ReplaceAll(mnemonic, ".", "_"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown instruction %s", in.Mnemonic)
+	}
+
+	switch opcode {
+	case Nop, Error, Halt, Rets:
+		if err := e.expect(0); err != nil {
+			return nil, err
+		}
+		e.rr(opcode, 0, 0)
+
+	case Return:
+		if err := e.expect(0); err != nil {
+			return nil, err
+		}
+		e.rr(Jump, uint16(RA), uint16(RA))
+
+	case Imm:
+		if err := e.expect(1); err != nil {
+			return nil, err
+		}
+		v, err := in.Eval(in.Operands[0])
+		if err != nil {
+			return nil, err
+		}
+		e.prefix(v)
+
+	case Move:
+		if err := e.expect(2); err != nil {
+			return nil, err
+		}
+		rd, err := e.reg(0)
+		if err != nil {
+			return nil, err
+		}
+		if rs, ok := e.isReg(1); ok {
+			e.rr(Move, rd, rs)
+			break
+		}
+		v, err := in.Eval(in.Operands[1])
+		if err != nil {
+			return nil, err
+		}
+		e.ri8(0, rd, v)
+
+	case Jump, Call:
+		if err := e.expect(1); err != nil {
+			return nil, err
+		}
+		if rd, ok := e.isReg(0); ok {
+			e.rr(opcode, rd, rd)
+			break
+		}
+		target, err := in.Eval(in.Operands[0])
+		if err != nil {
+			return nil, err
+		}
+		op := uint16(0)
+		if opcode == Call {
+			op = 1
+		}
+		e.i11(op, target)
+
+	case Load, Loadb, Store, Storeb:
+		return e.loadStore(opcode)
+
+	case Add, Sub, Addc, Subc, Xor, And, Or, Shl, Shr, Asr, IfEq, IfNe, IfLt, IfGe, IfUlt, IfUge:
+		if err := e.expect(2); err != nil {
+			return nil, err
+		}
+		rd, err := e.reg(0)
+		if err != nil {
+			return nil, err
+		}
+		if rs, ok := e.isReg(1); ok {
+			e.rr(opcode, rd, rs)
+			break
+		}
+		v, err := in.Eval(in.Operands[1])
+		if err != nil {
+			return nil, err
+		}
+		e.ri6(opcode, rd, v)
+
+	case IfGt, IfLe, IfUgt, IfUle:
+		return e.compare(opcode)
+
+	case Not, Neg:
+		if err := e.expect(1); err != nil {
+			return nil, err
+		}
+		rd, err := e.reg(0)
+		if err != nil {
+			return nil, err
+		}
+		e.ri6(Xor, rd, -1)
+		if opcode == Neg {
+			e.ri6(Add, rd, 1)
+		}
+
+	case Swap:
+		if err := e.expect(2); err != nil {
+			return nil, err
+		}
+		rd, err := e.reg(0)
+		if err != nil {
+			return nil, err
+		}
+		rs, err := e.reg(1)
+		if err != nil {
+			return nil, err
+		}
+		e.rr(Xor, rd, rs)
+		e.rr(Xor, rs, rd)
+		e.rr(Xor, rd, rs)
+
+	default:
+		return nil, fmt.Errorf("instruction %s is not supported by the assembler", in.Mnemonic)
+	}
+
+	return e.words, nil
+}
+
+// compare encodes the pseudo comparisons that are done by swapping
+// operands or adjusting the immediate
+func (e *encoder) compare(opcode Opcode) ([]uint64, error) {
+	if err := e.expect(2); err != nil {
+		return nil, err
+	}
+	rd, err := e.reg(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if rs, ok := e.isReg(1); ok {
+		switch opcode {
+		case IfGt:
+			e.rr(IfLt, rs, rd)
+		case IfLe:
+			e.rr(IfGe, rs, rd)
+		case IfUgt:
+			e.rr(IfUlt, rs, rd)
+		case IfUle:
+			e.rr(IfUge, rs, rd)
+		}
+		return e.words, nil
+	}
+
+	v, err := e.in.Eval(e.in.Operands[1])
+	if err != nil {
+		return nil, err
+	}
+
+	switch opcode {
+	case IfGt:
+		e.ri6(IfGe, rd, v+1)
+	case IfLe:
+		e.ri6(IfLt, rd, v+1)
+	case IfUgt:
+		e.ri6(IfUge, rd, v+1)
+	case IfUle:
+		e.ri6(IfUlt, rd, v+1)
+	}
+	return e.words, nil
+}
+
+func (e *encoder) loadStore(opcode Opcode) ([]uint64, error) {
+	if err := e.expect(2); err != nil {
+		return nil, err
+	}
+
+	regIdx, memIdx := 0, 1
+	if opcode == Store || opcode == Storeb {
+		regIdx, memIdx = 1, 0
+	}
+
+	rd, err := e.reg(regIdx)
+	if err != nil {
+		return nil, err
+	}
+
+	mem := strings.TrimSpace(e.in.Operands[memIdx])
+	if !strings.HasPrefix(mem, "[") || !strings.HasSuffix(mem, "]") {
+		return nil, fmt.Errorf("expected memory operand but got %s", mem)
+	}
+	mem = strings.TrimSpace(mem[1 : len(mem)-1])
+
+	base := mem
+	offset := "0"
+	if comma := strings.Index(mem, ","); comma >= 0 {
+		base = strings.TrimSpace(mem[:comma])
+		offset = strings.TrimSpace(mem[comma+1:])
+	}
+
+	rs, found := regNums[strings.ToLower(base)]
+	if !found {
+		return nil, fmt.Errorf("expected base register but got %s", base)
+	}
+
+	v, err := e.in.Eval(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	e.ls(opcode, rd, rs, v)
+	return e.words, nil
+}
+
+func (e *encoder) expect(n int) error {
+	if len(e.in.Operands) != n {
+		return fmt.Errorf("%s expects %d operands but got %d", e.in.Mnemonic, n, len(e.in.Operands))
+	}
+	return nil
+}
+
+func (e *encoder) isReg(i int) (uint16, bool) {
+	num, found := regNums[strings.ToLower(strings.TrimSpace(e.in.Operands[i]))]
+	return num, found
+}
+
+func (e *encoder) reg(i int) (uint16, error) {
+	num, found := e.isReg(i)
+	if !found {
+		return 0, fmt.Errorf("expected register but got %s", e.in.Operands[i])
+	}
+	return num, nil
+}
+
+// pc returns the address the next word will be placed at
+func (e *encoder) pc() int64 {
+	return e.in.PC + int64(len(e.words))
+}
+
+// needsPrefix returns whether an immediate needs an imm prefix
+// given the remaining minimum size of the encoding
+func (e *encoder) needsPrefix(format uint16, v int64) bool {
+	if v < immRanges[format].min || v > immRanges[format].max {
+		return true
+	}
+
+	if e.force {
+		e.force = false
+		return true
+	}
+	return false
+}
+
+func (e *encoder) prefix(v int64) {
+	e.words = append(e.words, uint64(uint16((v>>4)&0xfff)<<4|0b1101))
+}
+
+func (e *encoder) rr(op Opcode, rd, rs uint16) {
+	e.words = append(e.words, uint64(rd<<12|rs<<8|uint16(op)<<2))
+}
+
+func (e *encoder) ri6(op Opcode, rd uint16, v int64) {
+	if e.needsPrefix(fmtRI6, v) {
+		e.prefix(v)
+	}
+	imm := uint16(v) & 0x3f
+	e.words = append(e.words, uint64(rd<<12|imm<<6|uint16(op-Add)<<2|0b11))
+}
+
+func (e *encoder) ri8(op uint16, rd uint16, v int64) {
+	if e.needsPrefix(fmtRI8, v) {
+		e.prefix(v)
+	}
+	imm := uint16(v) & 0xff
+	e.words = append(e.words, uint64(rd<<12|imm<<4|op<<3|0b001))
+}
+
+func (e *encoder) ls(op Opcode, rd, rs uint16, v int64) {
+	if e.needsPrefix(fmtLS, v) {
+		e.prefix(v)
+	}
+	imm := uint16(v) & 0xf
+	e.words = append(e.words, uint64(rd<<12|rs<<8|imm<<4|uint16(op-Load)<<2|0b10))
+}
+
+// i11 encodes a pc relative jump or call. The offset is relative to
+// the word following the jump or call.
+func (e *encoder) i11(op uint16, target int64) {
+	offset := target - e.pc() - 1
+	if e.needsPrefix(fmtI11, offset) {
+		// the jump itself moves down by one word
+		offset--
+		e.prefix(offset)
+	}
+	imm := uint16(offset) & 0x7ff
+	e.words = append(e.words, uint64(imm<<5|op<<4|0b0101))
+}
@@ -0,0 +1,77 @@
+package rj32
+
+import (
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/interp"
+	"github.com/rj45/nanogo/ir2/op"
+)
+
+func (cpuArch) InterpOp(o ir2.Op) interp.Semantics {
+	switch o {
+	case Move, Swap:
+		return interp.Semantics{Op: op.Copy}
+	case Load:
+		return interp.Semantics{Op: op.Load, Bits: 16}
+	case Loadb:
+		return interp.Semantics{Op: op.Load, Bits: 8}
+	case Store:
+		return interp.Semantics{Op: op.Store, Bits: 16}
+	case Storeb:
+		return interp.Semantics{Op: op.Store, Bits: 8}
+	case Add:
+		return interp.Semantics{Op: op.Add}
+	case Sub:
+		return interp.Semantics{Op: op.Sub}
+	case Addc:
+		return interp.Semantics{Op: op.AddCarry}
+	case Subc:
+		return interp.Semantics{Op: op.SubBorrow}
+	case Xor:
+		return interp.Semantics{Op: op.Xor}
+	case And:
+		return interp.Semantics{Op: op.And}
+	case Or:
+		return interp.Semantics{Op: op.Or}
+	case Shl:
+		return interp.Semantics{Op: op.ShiftLeft}
+	case Shr:
+		return interp.Semantics{Op: op.ShiftRight, Unsigned: true}
+	case Asr:
+		return interp.Semantics{Op: op.ShiftRight, Signed: true}
+	case Not:
+		return interp.Semantics{Op: op.Invert}
+	case Neg:
+		return interp.Semantics{Op: op.Negate}
+	case IfEq:
+		return interp.Semantics{Op: op.IfEqual}
+	case IfNe:
+		return interp.Semantics{Op: op.IfNotEqual}
+	case IfLt:
+		return interp.Semantics{Op: op.IfLess, Signed: true}
+	case IfGe:
+		return interp.Semantics{Op: op.IfGreaterEqual, Signed: true}
+	case IfGt:
+		return interp.Semantics{Op: op.IfGreater, Signed: true}
+	case IfLe:
+		return interp.Semantics{Op: op.IfLessEqual, Signed: true}
+	case IfUlt:
+		return interp.Semantics{Op: op.IfLess, Unsigned: true}
+	case IfUge:
+		return interp.Semantics{Op: op.IfGreaterEqual, Unsigned: true}
+	case IfUgt:
+		return interp.Semantics{Op: op.IfGreater, Unsigned: true}
+	case IfUle:
+		return interp.Semantics{Op: op.IfLessEqual, Unsigned: true}
+	case Jump:
+		return interp.Semantics{Op: op.Jump}
+	case Call:
+		return interp.Semantics{Op: op.Call}
+	case Return:
+		return interp.Semantics{Op: op.Return}
+	case Error:
+		return interp.Semantics{Op: op.Panic}
+	case Nop:
+		return interp.Semantics{Op: op.Copy}
+	}
+	return interp.Semantics{Op: op.Invalid}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/rj45/nanogo/codegen/asm"
 	"github.com/rj45/nanogo/ir/op"
+	"github.com/rj45/nanogo/ir2"
 )
 
 type Opcode int
@@ -100,6 +101,16 @@ func (op Opcode) ClobbersArg() bool {
 	return opDefs[op].flags&clobbers != 0
 }
 
+func (op Opcode) IsReturn() bool {
+	return op == Return
+}
+
+// Arch returns the name of the arch the instruction is from, which
+// it's qualified with when the IR is written out
+func (op Opcode) Arch() string {
+	return cpuArch{}.Name()
+}
+
 type flags uint16
 
 const (
@@ -170,6 +181,13 @@ func init() {
 	}
 }
 
+// ParseOp returns the instruction with the name, for parsing IR
+// that's been lowered to the arch's instructions
+func (cpuArch) ParseOp(name string) (ir2.Op, bool) {
+	op, err := OpcodeString(name)
+	return op, err == nil
+}
+
 func (cpuArch) IsTwoOperand() bool {
 	return true
 }
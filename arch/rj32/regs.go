@@ -58,8 +58,9 @@ func (cpuArch) ArgRegs() []reg.Reg {
 
 func (cpuArch) SpecialRegs() map[string]reg.Reg {
 	return map[string]reg.Reg{
-		"SP": reg.FromRegNum(int(SP)),
-		"GP": reg.FromRegNum(int(GP)),
-		"RA": reg.FromRegNum(int(RA)),
+		"SP":  reg.FromRegNum(int(SP)),
+		"GP":  reg.FromRegNum(int(GP)),
+		"RA":  reg.FromRegNum(int(RA)),
+		"CTX": reg.FromRegNum(int(T4)),
 	}
 }
@@ -34,3 +34,7 @@ func (cpuArch) RuneSize() int {
 func (cpuArch) MinAddressableBits() int {
 	return 16
 }
+
+func (cpuArch) StackAlign() int {
+	return 1
+}
@@ -4,6 +4,7 @@ import (
 	"go/types"
 	"log"
 
+	"github.com/rj45/nanogo/diag"
 	"github.com/rj45/nanogo/ir2"
 	"github.com/rj45/nanogo/ir2/op"
 )
@@ -16,6 +17,8 @@ var directTranslate = map[op.Op]Opcode{
 var twoOperandTranslations = map[op.Op]Opcode{
 	op.Add:       Add,
 	op.Sub:       Sub,
+	op.AddCarry:  Addc,
+	op.SubBorrow: Subc,
 	op.And:       And,
 	op.Or:        Or,
 	op.Xor:       Xor,
@@ -53,30 +56,23 @@ func translate(it ir2.Iter) {
 		// copy is done in the finishing stage, after register allocation
 	case op.Return, op.Jump:
 		it.Update(directTranslate[instr.Op.(op.Op)], nil, instr.Args())
-	case op.Add, op.Sub, op.And, op.Or, op.Xor, op.ShiftLeft:
-		if instr.NumArgs() == 2 && instr.Arg(0).Reg() == instr.Def(0).Reg() {
-			it.Update(twoOperandTranslations[instr.Op.(op.Op)], nil, instr.Args())
-		}
+	case op.Add, op.Sub, op.AddCarry, op.SubBorrow, op.And, op.Or, op.Xor, op.ShiftLeft:
+		it.Update(twoOperandTranslations[instr.Op.(op.Op)], nil, instr.Args())
 	case op.ShiftRight:
 		op := Shr
-		typ := instr.Def(0).Type.Underlying()
-		if basic, ok := typ.(*types.Basic); ok {
-			// is signed integer?
-			if basic.Info()&(types.IsInteger|types.IsUnsigned) == types.IsInteger {
-				op = Asr
-			}
-		}
-		if instr.NumArgs() == 2 && instr.Arg(0).Reg() == instr.Def(0).Reg() {
-			it.Update(op, nil, instr.Args())
-		}
-	case op.Not, op.Negate:
-		if instr.NumArgs() == 1 && instr.Arg(0).Reg() == instr.Def(0).Reg() {
-			it.Update(oneOperandTranslations[instr.Op.(op.Op)], nil, instr.Args())
+		if isSigned(instr.Def(0).Type) {
+			op = Asr
 		}
+		it.Update(op, nil, instr.Args())
+	case op.Invert, op.Negate:
+		it.Update(oneOperandTranslations[instr.Op.(op.Op)], nil, instr.Args())
+	case op.Not:
+		// bools are 0 or 1, so flipping the lowest bit negates them
+		it.Update(Xor, nil, instr.Arg(0), 1)
 	case op.Equal, op.NotEqual, op.Less, op.LessEqual, op.Greater, op.GreaterEqual:
 		def := instr.Def(0)
 		if def.NumUses() > 1 || def.Use(0).Instr().Op != op.If {
-			log.Panicf("Lone comparison not tied to If %s", instr.LongString())
+			diag.Errorf(instr.Pos, "using a comparison as a value is not supported yet")
 		}
 	case op.If:
 		compare := instr.Arg(0).Def().Instr()
@@ -84,13 +80,9 @@ func translate(it ir2.Iter) {
 			log.Panicf("expecting if to have compare, but instead had: %s", compare.LongString())
 		}
 
-		typ := compare.Arg(0).Type.Underlying()
-		branchOp := branchesSigned[compare.Op.(op.Op)]
-		if basic, ok := typ.(*types.Basic); ok {
-			// is signed integer?
-			if basic.Info()&(types.IsInteger|types.IsUnsigned) == types.IsInteger {
-				branchOp = branchesUnsigned[compare.Op.(op.Op)]
-			}
+		branchOp := branchesUnsigned[compare.Op.(op.Op)]
+		if isSigned(compare.Arg(0).Type) {
+			branchOp = branchesSigned[compare.Op.(op.Op)]
 		}
 		if branchOp == 0 {
 			log.Panicf("failed to translate compare %s", compare.Op.(op.Op))
@@ -99,10 +91,8 @@ func translate(it ir2.Iter) {
 		if compare.Def(0).NumUses() == 0 {
 			it.RemoveInstr(compare)
 		}
-	case op.Load:
-		it.Update(Load, instr.Def(0).Type, instr.Args())
-	case op.Store:
-		it.Update(Store, nil, instr.Args())
+	case op.Panic:
+		it.Update(Error, nil, instr.Args())
 	case op.Call:
 		instr.Op = Call
 		it.Changed()
@@ -116,6 +106,46 @@ func translate(it ir2.Iter) {
 	}
 }
 
+// isSigned returns whether the type is a signed integer
+func isSigned(typ types.Type) bool {
+	if basic, ok := typ.Underlying().(*types.Basic); ok {
+		return basic.Info()&(types.IsInteger|types.IsUnsigned) == types.IsInteger
+	}
+	return false
+}
+
+// translateLoadStores translates loads and stores once they have
+// been made relative to a register after register allocation
+func translateLoadStores(it ir2.Iter) {
+	instr := it.Instr()
+	switch instr.Op {
+	case op.Load:
+		it.Update(Load, instr.Def(0).Type, instr.Args())
+	case op.Store:
+		it.Update(Store, nil, instr.Args())
+	}
+}
+
+// twoOperands moves the first operand into the destination register
+// when they were allocated different registers, since the instruction
+// overwrites its first operand
+func twoOperands(it ir2.Iter) {
+	instr := it.Instr()
+	if !instr.Op.ClobbersArg() || instr.NumDefs() < 1 {
+		return
+	}
+
+	def := instr.Def(0)
+	arg := instr.Arg(0)
+	if arg.Reg() == def.Reg() {
+		return
+	}
+
+	mv := it.Insert(Move, def.Type, arg)
+	mv.Def(0).SetReg(def.Reg())
+	instr.ReplaceArg(0, mv.Def(0))
+}
+
 func translateCopies(it ir2.Iter) {
 	instr := it.Instr()
 
@@ -10,6 +10,9 @@ func (cpuArch) XformTags2() []xform2.Tag {
 }
 
 func (cpuArch) RegisterXforms() {
-	xform2.Register(translate, xform2.OnlyPass(xform2.Lowering))
+	xform2.Register(translate, xform2.Passes(xform2.Lowering, xform2.Finishing))
+	xform2.Register(translateLoadStores, xform2.OnlyPass(xform2.Finishing), xform2.OnOp(op.Load))
+	xform2.Register(translateLoadStores, xform2.OnlyPass(xform2.Finishing), xform2.OnOp(op.Store))
+	xform2.Register(twoOperands, xform2.OnlyPass(xform2.Finishing))
 	xform2.Register(translateCopies, xform2.OnlyPass(xform2.Finishing), xform2.OnOp(op.Copy))
 }
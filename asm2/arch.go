@@ -4,6 +4,9 @@ import "github.com/rj45/nanogo/ir2"
 
 type Arch interface {
 	Asm(op ir2.Op, defs []string, args []string) string
+
+	// DataSection returns the section initialized globals go in
+	DataSection() Section
 }
 
 var arch Arch
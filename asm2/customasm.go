@@ -25,13 +25,21 @@ func (CustomASM) GlobalLabel(global *ir2.Global) string {
 	return global.FullName
 }
 
+func (CustomASM) FuncLabel(fn *ir2.Func) string {
+	return fn.FullName
+}
+
 func (CustomASM) PCRelAddress(offsetWords int) string {
 	return fmt.Sprintf("$ + %d", offsetWords)
 }
 
 func (CustomASM) Word(value string) string {
 	wordsize := int(sizes.WordSize()) * sizes.MinAddressableBits()
-	return fmt.Sprintf("#d%d le(%s)", wordsize, value)
+	if sizes.WordSize() == 1 {
+		// a word is a single unit so there are no bytes to order
+		return fmt.Sprintf("#d%d %s", wordsize, value)
+	}
+	return fmt.Sprintf("#d le((%s)`%d)", value, wordsize)
 }
 
 func (CustomASM) String(val string) string {
@@ -47,6 +55,11 @@ func (CustomASM) String(val string) string {
 	panic("unsupported byte size")
 }
 
+func (CustomASM) Align() string {
+	wordsize := int(sizes.WordSize()) * sizes.MinAddressableBits()
+	return fmt.Sprintf("#align %d", wordsize)
+}
+
 func (CustomASM) Reserve(bytes int) string {
 	return fmt.Sprintf("#res %d", bytes)
 }
@@ -2,10 +2,13 @@ package asm2
 
 import (
 	"fmt"
+	"go/types"
 	"io"
 	"strings"
+	"unicode/utf16"
 
 	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
 	"github.com/rj45/nanogo/sizes"
 )
 
@@ -17,12 +20,18 @@ const (
 	Bss  Section = "bss"
 )
 
+// BssEnd labels the end of the bss section, which is where
+// the runtime's heap starts
+const BssEnd = "bssEnd"
+
 type Formatter interface {
 	Section(s Section) string
 	GlobalLabel(global *ir2.Global) string
+	FuncLabel(fn *ir2.Func) string
 	PCRelAddress(offsetWords int) string
 	Word(val string) string
 	String(val string) string
+	Align() string
 	Reserve(bytes int) string
 	Comment(comment string) string
 	BlockLabel(id string) string
@@ -57,6 +66,91 @@ func (emit *Emitter) Program(prog *ir2.Program) {
 	mainpkg := prog.Package("main")
 	emit.assemble(mainpkg.Func("init"))
 	emit.assemble(mainpkg.Func("main"))
+
+	emit.bssEnd()
+}
+
+// EmitBssEnd emits the BssEnd label on its own, to be linked after the
+// objects of the packages so it ends up after their bss
+func EmitBssEnd(out io.Writer, fmter Formatter) {
+	emitter := NewEmitter(out, fmter)
+	emitter.bssEnd()
+}
+
+func (emit *Emitter) bssEnd() {
+	emit.ensureSection(Bss)
+	emit.line("%s", emit.fmter.Align())
+	emit.line("%s:", BssEnd)
+}
+
+// EmitPackage emits a package compiled on its own, to be assembled into
+// an object, and returns the labels of the shared funcs and globals in it
+func EmitPackage(out io.Writer, fmter Formatter, pkg *ir2.Package) []string {
+	emitter := NewEmitter(out, fmter)
+	return emitter.Package(pkg)
+}
+
+// Package emits the funcs and globals of the package, along with the
+// shared ones they use, which other objects may have copies of. Unlike
+// Program, what isn't used is left for the linker to drop.
+func (emit *Emitter) Package(pkg *ir2.Package) []string {
+	var shared []string
+	var funcs []*ir2.Func
+	var globals []*ir2.Global
+
+	for _, fn := range pkg.Funcs() {
+		if !fn.Shared && fn.NumBlocks() > 0 {
+			funcs = append(funcs, fn)
+		}
+	}
+	for _, glob := range pkg.Globals() {
+		if !glob.Shared {
+			globals = append(globals, glob)
+		}
+	}
+
+	for len(funcs) > 0 || len(globals) > 0 {
+		for len(globals) > 0 {
+			glob := globals[0]
+			globals = globals[1:]
+
+			if emit.emittedGlobals[glob] || (glob.Package() != pkg && !glob.Shared) {
+				continue
+			}
+			emit.emittedGlobals[glob] = true
+			emit.global(glob)
+			if glob.Shared {
+				shared = append(shared, emit.fmter.GlobalLabel(glob))
+			}
+
+			consts := glob.Words
+			if glob.Value != nil {
+				consts = append(consts, glob.Value)
+			}
+			for _, c := range consts {
+				funcs, globals = emit.ref(c, funcs, globals)
+			}
+		}
+
+		if len(funcs) > 0 {
+			fn := funcs[0]
+			funcs = funcs[1:]
+
+			// other packages emit their own funcs
+			if emit.emittedFuncs[fn] || (fn.Package() != pkg && !fn.Shared) || fn.NumBlocks() == 0 {
+				continue
+			}
+			emit.emittedFuncs[fn] = true
+			emit.fn(fn)
+			if fn.Shared {
+				shared = append(shared, emit.fmter.FuncLabel(fn))
+			}
+
+			funcs, globals = emit.scan(fn, funcs, globals)
+		}
+	}
+
+	return shared
 }
 
 func (emit *Emitter) assemble(fn *ir2.Func) {
@@ -72,6 +166,25 @@ func (emit *Emitter) assemble(fn *ir2.Func) {
 
 		funcs, globals = emit.scan(fn, funcs, globals)
 
+		for len(globals) > 0 {
+			glob := globals[0]
+			globals = globals[1:]
+
+			if !emit.emittedGlobals[glob] {
+				emit.emittedGlobals[glob] = true
+				emit.global(glob)
+
+				// func values and tables need what they refer to as well
+				consts := glob.Words
+				if glob.Value != nil {
+					consts = append(consts, glob.Value)
+				}
+				for _, c := range consts {
+					funcs, globals = emit.ref(c, funcs, globals)
+				}
+			}
+		}
+
 		for _, f := range funcs {
 			if !seenFunc[f] && !emit.emittedFuncs[f] {
 				seenFunc[f] = true
@@ -80,14 +193,6 @@ func (emit *Emitter) assemble(fn *ir2.Func) {
 		}
 		funcs = funcs[:]
 
-		for _, glob := range globals {
-			if !emit.emittedGlobals[glob] {
-				emit.emittedGlobals[glob] = true
-				emit.global(glob)
-			}
-		}
-		globals = globals[:]
-
 		emit.emittedFuncs[fn] = true
 		emit.fn(fn)
 	}
@@ -112,6 +217,7 @@ func (emit *Emitter) fn(fn *ir2.Func) {
 	}
 
 	emit.comment("func %s(%s)%s", fn.FullName, strings.Join(pstrs, ", "), resstr)
+	emit.line("%s:", emit.fmter.FuncLabel(fn))
 
 	for b := 0; b < fn.NumBlocks(); b++ {
 		blk := fn.Block(b)
@@ -122,6 +228,12 @@ func (emit *Emitter) fn(fn *ir2.Func) {
 		for i := 0; i < blk.NumInstrs(); i++ {
 			instr := blk.Instr(i)
 
+			if instr.Op == op.InlineAsm {
+				asm, _ := ir2.StringValue(instr.Arg(0).Const())
+				emit.lines(asm)
+				continue
+			}
+
 			defs := make([]string, 0, instr.NumDefs())
 			for d := 0; d < instr.NumDefs(); d++ {
 				def := instr.Def(d)
@@ -167,7 +279,7 @@ func (emit *Emitter) fn(fn *ir2.Func) {
 				}
 			}
 
-			emit.line("%s", arch.Asm(instr.Op, defs, args))
+			emit.lines(arch.Asm(instr.Op, defs, args))
 		}
 		emit.indent = ""
 	}
@@ -176,23 +288,35 @@ func (emit *Emitter) fn(fn *ir2.Func) {
 }
 
 func (emit *Emitter) global(glob *ir2.Global) {
-	if glob.Value != nil {
-		emit.ensureSection(Data)
+	if glob.Value != nil || glob.Words != nil {
+		emit.ensureSection(arch.DataSection())
 	} else {
 		emit.ensureSection(Bss)
 	}
 	emit.line("%s:", emit.fmter.GlobalLabel(glob))
-	if glob.Value == nil {
-		bytes := sizes.Sizeof(glob.Type)
+	if glob.Words != nil {
+		for _, word := range glob.Words {
+			emit.line("%s", emit.fmter.Word(emit.word(word)))
+		}
+	} else if glob.Value == nil {
+		// globals are referred to by their address
+		typ := glob.Type
+		if ptr, ok := typ.(*types.Pointer); ok {
+			typ = ptr.Elem()
+		}
+		bytes := sizes.Sizeof(typ)
 		emit.line("%s", emit.fmter.Reserve(int(bytes)))
 	} else if str, ok := ir2.StringValue(glob.Value); ok {
 		emit.line("%s", emit.fmter.Word(emit.fmter.PCRelAddress(int(sizes.WordSize()*2))))
 
-		emit.line("%s", emit.fmter.Word(fmt.Sprintf("%d", len(str))))
+		emit.line("%s", emit.fmter.Word(fmt.Sprintf("%d", stringLen(str))))
 		emit.line("%s", emit.fmter.String(str))
+		emit.line("%s", emit.fmter.Align())
 	} else if val, ok := ir2.IntValue(glob.Value); ok {
 		// todo: implement more types
 		emit.line("%s", emit.fmter.Word(fmt.Sprintf("%d", val)))
+	} else if fn, ok := ir2.FuncValue(glob.Value); ok {
+		emit.line("%s", emit.fmter.Word(emit.fmter.FuncLabel(fn)))
 	} else {
 		panic("todo: implement more types")
 	}
@@ -202,19 +326,18 @@ func (emit *Emitter) global(glob *ir2.Global) {
 func (emit *Emitter) scan(fn *ir2.Func, funcs []*ir2.Func, globals []*ir2.Global) ([]*ir2.Func, []*ir2.Global) {
 	for b := 0; b < fn.NumBlocks(); b++ {
 		blk := fn.Block(b)
+		// globals can also be passed to succ blocks
+		users := []*ir2.User{&blk.User}
 		for i := 0; i < blk.NumInstrs(); i++ {
-			instr := blk.Instr(i)
+			users = append(users, &blk.Instr(i).User)
+		}
 
-			for a := 0; a < instr.NumArgs(); a++ {
-				arg := instr.Arg(a)
+		for _, user := range users {
+			for a := 0; a < user.NumArgs(); a++ {
+				arg := user.Arg(a)
 
 				if arg.IsConst() {
-					constant := arg.Const()
-					if fnc, ok := ir2.FuncValue(constant); ok {
-						funcs = append(funcs, fnc)
-					} else if glob, ok := ir2.GlobalValue(constant); ok {
-						globals = append(globals, glob)
-					}
+					funcs, globals = emit.ref(arg.Const(), funcs, globals)
 				}
 			}
 		}
@@ -223,6 +346,30 @@ func (emit *Emitter) scan(fn *ir2.Func, funcs []*ir2.Func, globals []*ir2.Global
 	return funcs, globals
 }
 
+// ref adds the func or global a constant refers to
+func (emit *Emitter) ref(c ir2.Const, funcs []*ir2.Func, globals []*ir2.Global) ([]*ir2.Func, []*ir2.Global) {
+	if fnc, ok := ir2.FuncValue(c); ok {
+		funcs = append(funcs, fnc)
+	} else if glob, ok := ir2.GlobalValue(c); ok {
+		globals = append(globals, glob)
+	}
+	return funcs, globals
+}
+
+// word returns a constant as the value of a word in memory
+func (emit *Emitter) word(c ir2.Const) string {
+	if fnc, ok := ir2.FuncValue(c); ok {
+		return emit.fmter.FuncLabel(fnc)
+	}
+	if glob, ok := ir2.GlobalValue(c); ok {
+		return emit.fmter.GlobalLabel(glob)
+	}
+	if val, ok := ir2.IntValue(c); ok {
+		return fmt.Sprintf("%d", val)
+	}
+	panic("todo: implement more types")
+}
+
 func (emit *Emitter) ensureSection(section Section) {
 	if emit.section != section {
 		emit.line(emit.fmter.Section(section))
@@ -235,7 +382,26 @@ func (emit *Emitter) line(fmtstr string, args ...interface{}) {
 	fmt.Fprintln(emit.out, output)
 }
 
+// lines emits each line of a multi-line string
+func (emit *Emitter) lines(str string) {
+	for _, line := range strings.Split(str, "\n") {
+		emit.line("%s", line)
+	}
+}
+
 func (emit *Emitter) comment(fmtstr string, args ...interface{}) {
 	output := emit.fmter.Comment(fmt.Sprintf(fmtstr, args...))
 	fmt.Fprintln(emit.out, emit.indent+output)
 }
+
+// stringLen returns the length of the string in bytes, which hold UTF-16
+// when they are 16 bits wide and UTF-32 when they are 32 bits wide
+func stringLen(str string) int {
+	switch sizes.MinAddressableBits() {
+	case 16:
+		return len(utf16.Encode([]rune(str)))
+	case 32:
+		return len([]rune(str))
+	}
+	return len(str)
+}
@@ -0,0 +1,453 @@
+// Package assembler is a small assembler for the assembly emitted by
+// the code generators. It understands the subset of customasm syntax
+// the compiler and the runtime use (labels, constants, #bankdef, #bank,
+// #d, #res, #align), while the instruction encodings come from each
+// arch rather than from a customasm cpudef file.
+package assembler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Arch encodes the instructions for a particular CPU
+type Arch interface {
+	// Encode encodes the instruction into units of the bank's
+	// bit width. At least in.MinUnits units must be returned, larger
+	// encodings (such as ones with long immediates) can be used to
+	// satisfy this.
+	Encode(in *Instr) ([]uint64, error)
+}
+
+// Instr is an instruction to be encoded by an Arch
+type Instr struct {
+	Mnemonic string
+	Operands []string
+
+	// PC is the address of the instruction
+	PC int64
+
+	// MinUnits is the minimum size of the encoding in units
+	MinUnits int
+
+	asm *Assembler
+}
+
+// Eval evaluates an expression in the context of the instruction.
+// Symbols that are not resolved yet evaluate to zero during the
+// sizing passes, and are an error on the final pass.
+func (in *Instr) Eval(expr string) (int64, error) {
+	val, err := evalExpr(expr, in.asm)
+	if errors.Is(err, errUnresolved) && !in.asm.final {
+		return 0, nil
+	}
+	return val.v, err
+}
+
+// Error is an assembly error with the location it happened at
+type Error struct {
+	File string
+	Line int
+	Text string
+	Err  error
+
+	// Label is the last global label before the error, which
+	// is usually the func it's in
+	Label string
+}
+
+func (e *Error) Error() string {
+	if e.Label != "" {
+		return fmt.Sprintf("%s:%d: in %s: %s: %s", e.File, e.Line, e.Label, e.Err, strings.TrimSpace(e.Text))
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", e.File, e.Line, e.Err, strings.TrimSpace(e.Text))
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorList is the list of errors returned when assembling fails
+type ErrorList []*Error
+
+func (list ErrorList) Error() string {
+	msgs := make([]string, 0, len(list))
+	for i, err := range list {
+		if i >= 10 {
+			msgs = append(msgs, fmt.Sprintf("... and %d more errors", len(list)-i))
+			break
+		}
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// ErrTooManyPasses is returned if the label addresses do not converge
+var ErrTooManyPasses = errors.New("label addresses did not converge")
+
+type bank struct {
+	name string
+	bits int
+	addr int64
+	size int64
+	outp int64 // -1 means not output
+
+	pos int64
+}
+
+type stmtKind uint8
+
+const (
+	labelStmt stmtKind = iota
+	constStmt
+	instrStmt
+	bankStmt
+	dataStmt
+	resStmt
+	alignStmt
+	addrStmt
+
+	// unitsStmt is code or data from an object that was encoded
+	// when the object was assembled
+	unitsStmt
+)
+
+type stmt struct {
+	kind stmtKind
+	file string
+	line int
+	text string
+
+	name string
+	args []string
+
+	// width of data items for #dN
+	width int
+
+	// size in units from the last pass
+	size int
+
+	// units already encoded for a unitsStmt
+	units []uint64
+}
+
+// Source is an assembly file to be assembled
+type Source struct {
+	Name string
+	Text string
+}
+
+// Assembler assembles source files into an Image
+type Assembler struct {
+	arch  Arch
+	stmts []*stmt
+
+	banks    map[string]*bank
+	bankList []*bank
+	cur      *bank
+
+	symbols map[string]int64
+	global  string
+	here    int64
+
+	final   bool
+	changed bool
+	errs    ErrorList
+
+	image *Image
+
+	// refs records the global symbols looked up, and relative whether
+	// the pc or local labels were used, when they are not nil. This
+	// finds the relocations in objects, and what sections are referred
+	// to when linking.
+	refs     map[string]bool
+	relative *bool
+
+	// captured collects the units emitted instead of putting them
+	// in the image when encoding the statements of an object
+	captured  []uint64
+	capturing bool
+}
+
+// Assemble assembles the sources into an image. The errors in the
+// sources are returned as an ErrorList.
+func Assemble(arch Arch, sources ...Source) (*Image, error) {
+	asm := newAssembler(arch)
+
+	for _, src := range sources {
+		asm.parse(src)
+	}
+	if len(asm.errs) > 0 {
+		return nil, asm.errs
+	}
+
+	return asm.assemble()
+}
+
+func newAssembler(arch Arch) *Assembler {
+	return &Assembler{
+		arch:    arch,
+		banks:   make(map[string]*bank),
+		symbols: make(map[string]int64),
+	}
+}
+
+// assemble does passes over the statements until the label addresses
+// settle, then a final pass to put the units in the image
+func (asm *Assembler) assemble() (*Image, error) {
+	asm.ensureBank()
+
+	passes := 0
+	for {
+		passes++
+		asm.changed = false
+		asm.run()
+		if !asm.changed {
+			break
+		}
+		if passes > 32 {
+			return nil, ErrTooManyPasses
+		}
+	}
+
+	asm.final = true
+	asm.image = newImage(asm.bankList)
+	asm.run()
+	if len(asm.errs) > 0 {
+		return nil, asm.errs
+	}
+
+	asm.image.Symbols = asm.symbols
+
+	return asm.image, nil
+}
+
+func (asm *Assembler) errorf(st *stmt, format string, args ...interface{}) {
+	asm.error(st, fmt.Errorf(format, args...))
+}
+
+func (asm *Assembler) error(st *stmt, err error) {
+	asm.errs = append(asm.errs, &Error{
+		File: st.file,
+		Line: st.line,
+		Text: st.text,
+		Err:  err,
+
+		Label: asm.global,
+	})
+}
+
+// ensureBank defines a default bank if there are none
+func (asm *Assembler) ensureBank() {
+	if len(asm.bankList) == 0 {
+		asm.defineBank(&bank{name: "", bits: 8, outp: 0})
+	}
+}
+
+func (asm *Assembler) defineBank(b *bank) {
+	if _, found := asm.banks[b.name]; !found {
+		asm.bankList = append(asm.bankList, b)
+	}
+	asm.banks[b.name] = b
+}
+
+// lookup implements scope
+func (asm *Assembler) lookup(name string) (int64, bool) {
+	if strings.HasPrefix(name, ".") {
+		name = asm.global + name
+		if asm.relative != nil {
+			*asm.relative = true
+		}
+	} else if asm.refs != nil {
+		asm.refs[name] = true
+	}
+	v, found := asm.symbols[name]
+	return v, found
+}
+
+// pc implements scope
+func (asm *Assembler) pc() int64 {
+	if asm.relative != nil {
+		*asm.relative = true
+	}
+	return asm.here
+}
+
+func (asm *Assembler) define(st *stmt, name string, val int64) {
+	if strings.HasPrefix(name, ".") {
+		name = asm.global + name
+	} else {
+		asm.global = name
+	}
+
+	old, found := asm.symbols[name]
+	if !found || old != val {
+		asm.symbols[name] = val
+		asm.changed = true
+	}
+}
+
+// run does a single pass over all the statements
+func (asm *Assembler) run() {
+	for _, b := range asm.bankList {
+		b.pos = b.addr
+	}
+	asm.cur = asm.bankList[0]
+	asm.global = ""
+
+	for _, st := range asm.stmts {
+		asm.here = asm.cur.pos
+
+		switch st.kind {
+		case labelStmt:
+			asm.define(st, st.name, asm.cur.pos)
+
+		case constStmt:
+			val, err := evalExpr(st.args[0], asm)
+			if err != nil && (asm.final || !errors.Is(err, errUnresolved)) {
+				asm.error(st, err)
+			}
+			// constants don't change the scope for local labels
+			global := asm.global
+			asm.define(st, st.name, val.v)
+			asm.global = global
+
+		case bankStmt:
+			b, found := asm.banks[st.name]
+			if !found {
+				asm.errorf(st, "unknown bank %s", st.name)
+				continue
+			}
+			asm.cur = b
+
+		case addrStmt:
+			val, err := evalExpr(st.args[0], asm)
+			if err != nil {
+				asm.error(st, err)
+				continue
+			}
+			asm.cur.pos = val.v
+
+		case alignStmt:
+			val, err := evalExpr(st.args[0], asm)
+			if err != nil {
+				asm.error(st, err)
+				continue
+			}
+			units := val.v / int64(asm.cur.bits)
+			if units > 1 {
+				for asm.cur.pos%units != 0 {
+					asm.emit(st, 0)
+				}
+			}
+
+		case resStmt:
+			val, err := evalExpr(st.args[0], asm)
+			if err != nil {
+				asm.error(st, err)
+				continue
+			}
+			for i := int64(0); i < val.v; i++ {
+				asm.emit(st, 0)
+			}
+
+		case dataStmt:
+			asm.data(st)
+
+		case unitsStmt:
+			for _, u := range st.units {
+				asm.emit(st, u)
+			}
+
+		case instrStmt:
+			in := &Instr{
+				Mnemonic: st.name,
+				Operands: st.args,
+				PC:       asm.cur.pos,
+				MinUnits: st.size,
+				asm:      asm,
+			}
+			units, err := asm.arch.Encode(in)
+			if err != nil {
+				if asm.final {
+					asm.error(st, err)
+				}
+				units = make([]uint64, st.size)
+			}
+			if len(units) > st.size {
+				st.size = len(units)
+				asm.changed = true
+			}
+			for len(units) < st.size {
+				// this should not happen if the arch honors MinUnits
+				if asm.final {
+					asm.errorf(st, "encoding shrank from %d to %d units", st.size, len(units))
+				}
+				units = append(units, 0)
+			}
+			for _, u := range units {
+				asm.emit(st, u)
+			}
+		}
+	}
+}
+
+func (asm *Assembler) emit(st *stmt, unit uint64) {
+	if asm.capturing {
+		asm.captured = append(asm.captured, unit)
+		return
+	}
+
+	b := asm.cur
+	if asm.final && b.size > 0 && b.pos >= b.addr+b.size {
+		asm.errorf(st, "bank %s overflowed its size of %d", b.name, b.size)
+		b.size = 0 // only report once
+	}
+	if asm.final {
+		asm.image.put(b, b.pos, unit, st)
+	}
+	b.pos++
+}
+
+func (asm *Assembler) data(st *stmt) {
+	bits := asm.cur.bits
+	for _, item := range st.args {
+		if strings.HasPrefix(item, "\"") || strings.HasPrefix(item, "utf16le(") || strings.HasPrefix(item, "utf32le(") {
+			units, err := stringUnits(item, bits)
+			if err != nil {
+				asm.error(st, err)
+				continue
+			}
+			for _, u := range units {
+				asm.emit(st, u)
+			}
+			continue
+		}
+
+		val, err := evalExpr(item, asm)
+		if err != nil && (asm.final || !errors.Is(err, errUnresolved)) {
+			asm.error(st, err)
+			continue
+		}
+
+		width := st.width
+		if width == 0 {
+			width = val.width
+		}
+		if width == 0 {
+			asm.errorf(st, "data item %q needs an explicit width", item)
+			continue
+		}
+		if width%bits != 0 {
+			asm.errorf(st, "data width %d is not a multiple of %d bits", width, bits)
+			continue
+		}
+
+		// emit the most significant unit first
+		v := uint64(truncate(val.v, width))
+		for i := width/bits - 1; i >= 0; i-- {
+			asm.emit(st, (v>>(i*bits))&(1<<bits-1))
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package assembler_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/rj45/nanogo/assembler"
+)
+
+// testArch has 16 bit instructions with an 8 bit immediate, which
+// takes a second word when it doesn't fit
+type testArch struct{}
+
+func (testArch) Encode(in *assembler.Instr) ([]uint64, error) {
+	switch in.Mnemonic {
+	case "nop":
+		return []uint64{0}, nil
+	case "jump":
+		v, err := in.Eval(in.Operands[0])
+		if err != nil {
+			return nil, err
+		}
+		if v >= 0 && v < 0x100 && in.MinUnits < 2 {
+			return []uint64{0x100 | uint64(v)}, nil
+		}
+		return []uint64{0x200, uint64(v) & 0xffff}, nil
+	}
+	return nil, fmt.Errorf("unknown instruction %s", in.Mnemonic)
+}
+
+const banks = `
+#bankdef code
+{
+  #bits 16
+  #addr 0x0000
+  #size 0x1000
+  #outp 0
+}
+
+#bankdef data
+{
+  #bits 16
+  #addr 0x8000
+  #size 0x10
+  #outp 8*16
+}
+`
+
+func TestAssemble(t *testing.T) {
+	src := `
+#bank code
+start:
+  jump .next ; forward reference to a local label
+.next:
+  nop
+  jump far
+
+#bank data
+str:
+#d16 $ + 1, 2
+#d16 utf16le("hi")
+
+#bank code
+far = 0x1234
+  jump str
+`
+
+	img, err := assembler.Assemble(testArch{}, assembler.Source{Name: "banks.asm", Text: banks},
+		assembler.Source{Name: "test.asm", Text: src})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []uint64{
+		0x101,         // jump .next
+		0,             // nop
+		0x200, 0x1234, // jump far
+		0x200, 0x8000, // jump str
+		0, 0,
+		0x8001, 2, 'h', 'i', // data bank
+	}
+	if !reflect.DeepEqual(img.Units, want) {
+		t.Errorf("got units %x, want %x", img.Units, want)
+	}
+
+	if img.Symbols["start.next"] != 1 || img.Symbols["str"] != 0x8000 {
+		t.Errorf("bad symbols %v", img.Symbols)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := img.Write(buf, "logisim16"); err != nil {
+		t.Fatal(err)
+	}
+	wantText := "v2.0 raw\n0101 0000 0200 1234 0200 8000 0000 0000 8001 0002 0068 0069\n"
+	if buf.String() != wantText {
+		t.Errorf("got logisim16 %q, want %q", buf.String(), wantText)
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	src := `
+#bank code
+main:
+  nop
+  frob
+  jump missing
+`
+
+	_, err := assembler.Assemble(testArch{}, assembler.Source{Name: "banks.asm", Text: banks},
+		assembler.Source{Name: "test.asm", Text: src})
+
+	var list assembler.ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected an ErrorList, got %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 errors, got %v", list)
+	}
+
+	for i, line := range []int{5, 6} {
+		if list[i].File != "test.asm" || list[i].Line != line || list[i].Label != "main" {
+			t.Errorf("error %d is at the wrong place: %v", i, list[i])
+		}
+	}
+}
+
+func TestLink(t *testing.T) {
+	layout := []assembler.Source{{Name: "banks.asm", Text: banks}}
+
+	objA, err := assembler.AssembleObject(testArch{}, layout, assembler.Source{Name: "a.asm", Text: `
+#bank code
+main:
+  jump helper
+  nop
+unused:
+  jump unused
+table:
+  jump main
+`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	objA.Package = "a"
+	objA.Sections[2].Dup = true
+
+	objB, err := assembler.AssembleObject(testArch{}, layout, assembler.Source{Name: "b.asm", Text: `
+#bank data
+helper:
+#d16 table
+#bank code
+table:
+  nop
+`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	objB.Package = "b"
+	objB.Sections[1].Dup = true
+
+	if objA.Sections[0].Stmts[1].Units == nil {
+		t.Errorf("expected the nop to be encoded in the object")
+	}
+
+	// objects can be written and read back
+	buf := &bytes.Buffer{}
+	if err := objB.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	objB, err = assembler.ReadObject(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := assembler.Source{Name: "start.asm", Text: banks + "\n#bank code\n  jump main\n"}
+	img, err := assembler.Link(testArch{}, []assembler.Source{start}, objA, objB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// unused is left out, and only the first table is linked
+	want := []uint64{
+		0x101,         // jump main
+		0x200, 0x8000, // main: jump helper
+		0,     // nop
+		0x101, // table: jump main
+		0, 0, 0,
+		4, // helper: table
+	}
+	if !reflect.DeepEqual(img.Units, want) {
+		t.Errorf("got units %x, want %x", img.Units, want)
+	}
+	if _, found := img.Symbols["unused"]; found {
+		t.Errorf("expected unused to be left out")
+	}
+
+	objB.Sections[1].Dup = false
+	_, err = assembler.Link(testArch{}, []assembler.Source{start}, objA, objB)
+	if err == nil {
+		t.Errorf("expected an error for table being defined twice")
+	}
+}
@@ -0,0 +1,329 @@
+package assembler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// errUnresolved is returned when an expression refers to a symbol
+// that has not been defined (yet). During the sizing passes this is
+// expected for forward references.
+var errUnresolved = errors.New("unresolved symbol")
+
+// value is the result of evaluating an expression. A width of
+// zero means the value has no explicit bit width.
+type value struct {
+	v     int64
+	width int
+}
+
+// scope is used to look up symbols while evaluating expressions.
+type scope interface {
+	lookup(name string) (int64, bool)
+	pc() int64
+}
+
+type exprParser struct {
+	text  string
+	pos   int
+	scope scope
+
+	// unresolved is set if any symbol could not be found
+	unresolved bool
+}
+
+// evalExpr evaluates an expression string such as `label + 4` or
+// `le(($+8)`32)`.
+func evalExpr(text string, sc scope) (value, error) {
+	p := &exprParser{text: text, scope: sc}
+	val, err := p.parseBinary(0)
+	if err != nil {
+		return val, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.text) {
+		return val, fmt.Errorf("unexpected %q in expression %q", p.text[p.pos:], text)
+	}
+	if p.unresolved {
+		return val, errUnresolved
+	}
+	return val, nil
+}
+
+var binaryPrecedence = []struct {
+	ops []string
+}{
+	{[]string{"|"}},
+	{[]string{"^"}},
+	{[]string{"&"}},
+	{[]string{"==", "!="}},
+	{[]string{"<<", ">>"}},
+	{[]string{"+", "-"}},
+	{[]string{"*", "/", "%"}},
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.text) && (p.text[p.pos] == ' ' || p.text[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *exprParser) matchOp(ops []string) string {
+	p.skipSpace()
+	for _, op := range ops {
+		if strings.HasPrefix(p.text[p.pos:], op) {
+			// don't confuse `<<` with `<` and the like
+			rest := p.text[p.pos+len(op):]
+			if len(op) == 1 && len(rest) > 0 && (rest[0] == op[0] || rest[0] == '=') && op != "-" && op != "+" {
+				continue
+			}
+			p.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *exprParser) parseBinary(level int) (value, error) {
+	if level >= len(binaryPrecedence) {
+		return p.parseUnary()
+	}
+
+	lhs, err := p.parseBinary(level + 1)
+	if err != nil {
+		return lhs, err
+	}
+
+	for {
+		op := p.matchOp(binaryPrecedence[level].ops)
+		if op == "" {
+			return lhs, nil
+		}
+
+		rhs, err := p.parseBinary(level + 1)
+		if err != nil {
+			return rhs, err
+		}
+
+		a, b := lhs.v, rhs.v
+		switch op {
+		case "|":
+			a |= b
+		case "^":
+			a ^= b
+		case "&":
+			a &= b
+		case "==":
+			a = boolInt(a == b)
+		case "!=":
+			a = boolInt(a != b)
+		case "<<":
+			a <<= uint64(b)
+		case ">>":
+			a >>= uint64(b)
+		case "+":
+			a += b
+		case "-":
+			a -= b
+		case "*":
+			a *= b
+		case "/", "%":
+			if b == 0 {
+				if p.unresolved {
+					a = 0
+					break
+				}
+				return lhs, errors.New("division by zero in expression")
+			}
+			if op == "/" {
+				a /= b
+			} else {
+				a %= b
+			}
+		}
+		lhs = value{v: a}
+	}
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (p *exprParser) parseUnary() (value, error) {
+	p.skipSpace()
+	if p.pos >= len(p.text) {
+		return value{}, fmt.Errorf("unexpected end of expression %q", p.text)
+	}
+
+	switch p.text[p.pos] {
+	case '-':
+		p.pos++
+		val, err := p.parseUnary()
+		return value{v: -val.v}, err
+	case '+':
+		p.pos++
+		return p.parseUnary()
+	case '~', '!':
+		p.pos++
+		val, err := p.parseUnary()
+		return value{v: ^val.v}, err
+	}
+
+	return p.parsePostfix()
+}
+
+func (p *exprParser) parsePostfix() (value, error) {
+	val, err := p.parsePrimary()
+	if err != nil {
+		return val, err
+	}
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.text) || p.text[p.pos] != '`' {
+			return val, nil
+		}
+		p.pos++
+
+		start := p.pos
+		for p.pos < len(p.text) && p.text[p.pos] >= '0' && p.text[p.pos] <= '9' {
+			p.pos++
+		}
+		width, err := strconv.Atoi(p.text[start:p.pos])
+		if err != nil || width < 1 || width > 64 {
+			return val, fmt.Errorf("bad bit width in expression %q", p.text)
+		}
+
+		val = value{v: truncate(val.v, width), width: width}
+	}
+}
+
+// truncate returns the lowest width bits of v, as an unsigned number
+func truncate(v int64, width int) int64 {
+	if width >= 64 {
+		return v
+	}
+	return v & ((1 << width) - 1)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '.' || unicode.IsLetter(rune(c))
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '.' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+func (p *exprParser) parsePrimary() (value, error) {
+	p.skipSpace()
+	if p.pos >= len(p.text) {
+		return value{}, fmt.Errorf("unexpected end of expression %q", p.text)
+	}
+
+	c := p.text[p.pos]
+	switch {
+	case c == '(':
+		p.pos++
+		val, err := p.parseBinary(0)
+		if err != nil {
+			return val, err
+		}
+		p.skipSpace()
+		if p.pos >= len(p.text) || p.text[p.pos] != ')' {
+			return val, fmt.Errorf("missing ) in expression %q", p.text)
+		}
+		p.pos++
+		return val, nil
+
+	case c == '$':
+		p.pos++
+		return value{v: p.scope.pc()}, nil
+
+	case c == '\'':
+		end := strings.IndexByte(p.text[p.pos+1:], '\'')
+		if end < 0 {
+			return value{}, fmt.Errorf("unterminated character in expression %q", p.text)
+		}
+		str, err := strconv.Unquote(p.text[p.pos : p.pos+end+2])
+		if err != nil {
+			return value{}, err
+		}
+		p.pos += end + 2
+		return value{v: int64([]rune(str)[0])}, nil
+
+	case c >= '0' && c <= '9':
+		start := p.pos
+		for p.pos < len(p.text) && (isIdentChar(p.text[p.pos])) {
+			p.pos++
+		}
+		num := strings.ReplaceAll(p.text[start:p.pos], "_", "")
+		v, err := strconv.ParseInt(num, 0, 64)
+		if err != nil {
+			u, uerr := strconv.ParseUint(num, 0, 64)
+			if uerr != nil {
+				return value{}, fmt.Errorf("bad number %q", num)
+			}
+			v = int64(u)
+		}
+		return value{v: v}, nil
+
+	case isIdentStart(c):
+		start := p.pos
+		for p.pos < len(p.text) && isIdentChar(p.text[p.pos]) {
+			p.pos++
+		}
+		name := p.text[start:p.pos]
+
+		p.skipSpace()
+		if p.pos < len(p.text) && p.text[p.pos] == '(' {
+			return p.parseCall(name)
+		}
+
+		if name == "pc" {
+			return value{v: p.scope.pc()}, nil
+		}
+
+		v, found := p.scope.lookup(name)
+		if !found {
+			p.unresolved = true
+		}
+		return value{v: v}, nil
+	}
+
+	return value{}, fmt.Errorf("unexpected %q in expression %q", p.text[p.pos:], p.text)
+}
+
+func (p *exprParser) parseCall(name string) (value, error) {
+	// skip the (
+	p.pos++
+
+	arg, err := p.parseBinary(0)
+	if err != nil {
+		return arg, err
+	}
+	p.skipSpace()
+	if p.pos >= len(p.text) || p.text[p.pos] != ')' {
+		return arg, fmt.Errorf("missing ) in expression %q", p.text)
+	}
+	p.pos++
+
+	switch name {
+	case "le":
+		if arg.width == 0 || arg.width%8 != 0 {
+			return arg, fmt.Errorf("le() needs a value with a width that is a multiple of 8 in %q", p.text)
+		}
+		var swapped int64
+		for i := 0; i < arg.width/8; i++ {
+			swapped = (swapped << 8) | ((arg.v >> (i * 8)) & 0xff)
+		}
+		return value{v: swapped, width: arg.width}, nil
+	}
+
+	return arg, fmt.Errorf("unknown function %s in expression %q", name, p.text)
+}
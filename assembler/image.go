@@ -0,0 +1,164 @@
+package assembler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Image is the assembled output
+type Image struct {
+	// Bits is the number of bits in each unit
+	Bits int
+
+	// Units is the output, starting at output position 0
+	Units []uint64
+
+	// Symbols holds the address of every label and constant
+	Symbols map[string]int64
+
+	// Lines maps the output position of each instruction to
+	// the source line it came from
+	Lines map[int64]SourceLine
+}
+
+// SourceLine is the source of an assembled unit
+type SourceLine struct {
+	File string
+	Line int
+	Text string
+}
+
+func newImage(banks []*bank) *Image {
+	bits := 0
+	for _, b := range banks {
+		if b.outp >= 0 {
+			if bits != 0 && bits != b.bits {
+				// mixed widths are not supported, use the largest
+				if b.bits > bits {
+					bits = b.bits
+				}
+				continue
+			}
+			bits = b.bits
+		}
+	}
+	if bits == 0 {
+		bits = 8
+	}
+
+	return &Image{
+		Bits:  bits,
+		Lines: make(map[int64]SourceLine),
+	}
+}
+
+func (img *Image) put(b *bank, addr int64, unit uint64, st *stmt) {
+	if b.outp < 0 {
+		return
+	}
+
+	pos := b.outp/int64(b.bits) + (addr - b.addr)
+	for int64(len(img.Units)) <= pos {
+		img.Units = append(img.Units, 0)
+	}
+	img.Units[pos] = unit
+
+	if st.kind == instrStmt {
+		if _, found := img.Lines[pos]; !found {
+			img.Lines[pos] = SourceLine{File: st.file, Line: st.line, Text: st.text}
+		}
+	}
+}
+
+// Bytes returns the image as bytes, with units wider than a byte
+// in big endian order.
+func (img *Image) Bytes() []byte {
+	perUnit := (img.Bits + 7) / 8
+	out := make([]byte, 0, len(img.Units)*perUnit)
+	for _, u := range img.Units {
+		for i := perUnit - 1; i >= 0; i-- {
+			out = append(out, byte(u>>(i*8)))
+		}
+	}
+	return out
+}
+
+// WriteBinary writes the raw image
+func (img *Image) WriteBinary(w io.Writer) error {
+	_, err := w.Write(img.Bytes())
+	return err
+}
+
+// WriteLogisim16 writes the image as a logisim raw v2.0 image with
+// 16 bit words
+func (img *Image) WriteLogisim16(w io.Writer) error {
+	return img.writeLogisim(w, 16)
+}
+
+// WriteLogisim8 writes the image as a logisim raw v2.0 image with
+// 8 bit words
+func (img *Image) WriteLogisim8(w io.Writer) error {
+	return img.writeLogisim(w, 8)
+}
+
+func (img *Image) writeLogisim(w io.Writer, bits int) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "v2.0 raw")
+
+	words := img.words(bits)
+	for i, word := range words {
+		sep := " "
+		if i%16 == 15 || i == len(words)-1 {
+			sep = "\n"
+		}
+		fmt.Fprintf(bw, "%0*x%s", bits/4, word, sep)
+	}
+	return bw.Flush()
+}
+
+// WriteHex writes the image as a hex string
+func (img *Image) WriteHex(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, b := range img.Bytes() {
+		fmt.Fprintf(bw, "%02x", b)
+	}
+	fmt.Fprintln(bw)
+	return bw.Flush()
+}
+
+// words regroups the image into words of the given size
+func (img *Image) words(bits int) []uint64 {
+	if bits == img.Bits {
+		return img.Units
+	}
+	data := img.Bytes()
+	per := bits / 8
+	var words []uint64
+	for i := 0; i < len(data); i += per {
+		var w uint64
+		for j := 0; j < per; j++ {
+			w <<= 8
+			if i+j < len(data) {
+				w |= uint64(data[i+j])
+			}
+		}
+		words = append(words, w)
+	}
+	return words
+}
+
+// Write writes the image in the named format
+func (img *Image) Write(w io.Writer, format string) error {
+	switch format {
+	case "logisim16":
+		return img.WriteLogisim16(w)
+	case "logisim8":
+		return img.WriteLogisim8(w)
+	case "binary":
+		return img.WriteBinary(w)
+	case "hexstr", "hex":
+		return img.WriteHex(w)
+	}
+	return fmt.Errorf("unknown output format %s", format)
+}
@@ -0,0 +1,95 @@
+package assembler
+
+import "fmt"
+
+// Link links the objects into an image, after the sources, which
+// define the banks and start the program. Only the sections the sources
+// refer to, directly or through other sections, are linked, along with
+// the ones without a label. The sections are laid out in the order of
+// the objects, each in its own bank.
+func Link(arch Arch, sources []Source, objs ...*Object) (*Image, error) {
+	asm := newAssembler(arch)
+
+	for _, src := range sources {
+		asm.parse(src)
+	}
+	if len(asm.errs) > 0 {
+		return nil, asm.errs
+	}
+	asm.ensureBank()
+
+	// a sizing pass finds what the sources refer to
+	asm.refs = make(map[string]bool)
+	asm.run()
+	roots := asm.refs
+	asm.refs = nil
+	if len(asm.errs) > 0 {
+		return nil, asm.errs
+	}
+
+	defs := make(map[string]*Section)
+	from := make(map[*Section]*Object)
+
+	var todo []*Section
+	for _, obj := range objs {
+		for _, sec := range obj.Sections {
+			from[sec] = obj
+
+			// constants can be defined more than once, as they can
+			// be in a single source
+			for _, name := range sec.Consts {
+				if _, found := defs[name]; !found {
+					defs[name] = sec
+				}
+			}
+
+			if sec.Name == "" {
+				todo = append(todo, sec)
+				continue
+			}
+
+			prev, found := defs[sec.Name]
+			if !found {
+				defs[sec.Name] = sec
+				continue
+			}
+			if !prev.Dup || !sec.Dup {
+				return nil, fmt.Errorf("%s is defined in both %s and %s", sec.Name, from[prev].Package, obj.Package)
+			}
+		}
+	}
+
+	for name := range roots {
+		if sec, found := defs[name]; found {
+			todo = append(todo, sec)
+		}
+	}
+
+	linked := make(map[*Section]bool)
+	for len(todo) > 0 {
+		sec := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+
+		if linked[sec] {
+			continue
+		}
+		linked[sec] = true
+
+		for _, ref := range sec.Refs {
+			if def, found := defs[ref]; found && !linked[def] {
+				todo = append(todo, def)
+			}
+		}
+	}
+
+	for _, obj := range objs {
+		for _, sec := range obj.Sections {
+			// only the first of duplicate sections is referred to
+			if linked[sec] {
+				asm.stmts = append(asm.stmts, sec.stmts()...)
+			}
+		}
+	}
+
+	return asm.assemble()
+}
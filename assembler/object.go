@@ -0,0 +1,272 @@
+package assembler
+
+import (
+	"encoding/gob"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Object is a package assembled on its own, to be linked with other
+// objects into an Image. Its code and data are split into a section
+// for each global label, so the linker can leave out the sections
+// that nothing refers to.
+type Object struct {
+	// Arch is the name of the arch the object was assembled for
+	Arch string
+
+	// Package is the path of the package the object was compiled from
+	Package string
+
+	Sections []*Section
+}
+
+// Section is the code or data from a global label up to the next one
+type Section struct {
+	// Name is the global label, or empty for the code or data before
+	// the first one, which is always linked
+	Name string
+
+	// Bank is the bank the section starts in
+	Bank string
+
+	// Dup sections can be in more than one object, such as tables and
+	// wrappers generated for each package that uses them, and the
+	// linker keeps the first one
+	Dup bool
+
+	// Consts are the constants defined in the section
+	Consts []string
+
+	// Refs are the global symbols the section refers to, which are
+	// the relocations done when it is linked
+	Refs []string
+
+	Stmts []*Stmt
+}
+
+// Stmt is a statement in a section. The ones that refer to symbols or
+// depend on where they are placed are kept to be encoded when linked,
+// the rest are encoded already into Units.
+type Stmt struct {
+	Kind uint8
+	File string
+	Line int
+	Text string
+
+	Name  string
+	Args  []string
+	Width int
+	Units []uint64
+}
+
+// AssembleObject assembles the source into an Object. The layout sources
+// are only used for their #bankdefs, and are given to Link as well.
+func AssembleObject(arch Arch, layout []Source, src Source) (*Object, error) {
+	asm := newAssembler(arch)
+
+	for _, l := range layout {
+		asm.parse(l)
+	}
+	asm.stmts = nil
+
+	asm.parse(src)
+	if len(asm.errs) > 0 {
+		return nil, asm.errs
+	}
+	asm.ensureBank()
+
+	obj := &Object{}
+
+	asm.cur = asm.bankList[0]
+	asm.global = ""
+
+	var sec *Section
+	for _, st := range asm.stmts {
+		if st.kind == labelStmt && !strings.HasPrefix(st.name, ".") {
+			asm.global = st.name
+			sec = &Section{Name: st.name, Bank: asm.cur.name}
+			obj.Sections = append(obj.Sections, sec)
+			continue
+		}
+
+		if st.kind == bankStmt {
+			b, found := asm.banks[st.name]
+			if !found {
+				asm.errorf(st, "unknown bank %s", st.name)
+				continue
+			}
+			asm.cur = b
+
+			if sec == nil {
+				// each section starts in its bank anyway
+				continue
+			}
+		}
+
+		if sec == nil {
+			sec = &Section{Bank: asm.cur.name}
+			obj.Sections = append(obj.Sections, sec)
+		}
+
+		if st.kind == constStmt {
+			sec.Consts = append(sec.Consts, st.name)
+		}
+
+		for _, ref := range asm.relocate(st) {
+			sec.addRef(ref)
+		}
+		sec.Stmts = append(sec.Stmts, exportStmt(st))
+	}
+
+	if len(asm.errs) > 0 {
+		return nil, asm.errs
+	}
+
+	return obj, nil
+}
+
+// relocate returns the global symbols the statement refers to. If it
+// refers to none and doesn't depend on where it's placed, its code or
+// data is encoded now and it becomes a unitsStmt.
+func (asm *Assembler) relocate(st *stmt) []string {
+	relative := false
+	asm.refs = make(map[string]bool)
+	asm.relative = &relative
+	asm.here = 0
+	defer func() {
+		asm.refs = nil
+		asm.relative = nil
+	}()
+
+	var units []uint64
+	var err error
+
+	switch st.kind {
+	case instrStmt:
+		units, err = asm.arch.Encode(&Instr{Mnemonic: st.name, Operands: st.args, asm: asm})
+
+		// relative branches may use the PC without evaluating $
+		if err == nil && len(asm.refs) == 0 && !relative {
+			var moved []uint64
+			moved, err = asm.arch.Encode(&Instr{Mnemonic: st.name, Operands: st.args, PC: 0x1234, asm: asm})
+			relative = !equalUnits(units, moved)
+		}
+
+	case dataStmt:
+		asm.capturing = true
+		asm.captured = nil
+		numErrs := len(asm.errs)
+		asm.data(st)
+		asm.capturing = false
+		units = asm.captured
+		if len(asm.errs) > numErrs {
+			return nil
+		}
+
+	default:
+		for _, arg := range st.args {
+			evalExpr(arg, asm)
+		}
+		relative = true
+	}
+
+	if len(asm.refs) == 0 && !relative {
+		if err != nil {
+			asm.error(st, err)
+			return nil
+		}
+		st.kind = unitsStmt
+		st.units = units
+		return nil
+	}
+
+	refs := make([]string, 0, len(asm.refs))
+	for ref := range asm.refs {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+func equalUnits(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (sec *Section) addRef(ref string) {
+	for _, r := range sec.Refs {
+		if r == ref {
+			return
+		}
+	}
+	sec.Refs = append(sec.Refs, ref)
+}
+
+// stmts returns the statements to link the section, starting with
+// switching to its bank and its label
+func (sec *Section) stmts() []*stmt {
+	var stmts []*stmt
+
+	file, line := "", 0
+	if len(sec.Stmts) > 0 {
+		file, line = sec.Stmts[0].File, sec.Stmts[0].Line
+	}
+
+	stmts = append(stmts, &stmt{kind: bankStmt, file: file, line: line, name: sec.Bank})
+	if sec.Name != "" {
+		stmts = append(stmts, &stmt{kind: labelStmt, file: file, line: line, name: sec.Name})
+	}
+
+	for _, st := range sec.Stmts {
+		stmts = append(stmts, importStmt(st))
+	}
+	return stmts
+}
+
+func exportStmt(st *stmt) *Stmt {
+	return &Stmt{
+		Kind:  uint8(st.kind),
+		File:  st.file,
+		Line:  st.line,
+		Text:  st.text,
+		Name:  st.name,
+		Args:  st.args,
+		Width: st.width,
+		Units: st.units,
+	}
+}
+
+func importStmt(st *Stmt) *stmt {
+	return &stmt{
+		kind:  stmtKind(st.Kind),
+		file:  st.File,
+		line:  st.Line,
+		text:  st.Text,
+		name:  st.Name,
+		args:  st.Args,
+		width: st.Width,
+		units: st.Units,
+	}
+}
+
+// Write writes the object in a binary format ReadObject can read
+func (obj *Object) Write(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(obj)
+}
+
+// ReadObject reads an object written by Object.Write
+func ReadObject(r io.Reader) (*Object, error) {
+	obj := &Object{}
+	if err := gob.NewDecoder(r).Decode(obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
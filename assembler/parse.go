@@ -0,0 +1,281 @@
+package assembler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// parse splits the source up into statements
+func (asm *Assembler) parse(src Source) {
+	lines := strings.Split(src.Text, "\n")
+
+	for i := 0; i < len(lines); i++ {
+		st := &stmt{file: src.Name, line: i + 1, text: lines[i]}
+		line := strings.TrimSpace(stripComment(lines[i]))
+
+		if line == "" {
+			continue
+		}
+
+		if line[0] == '#' {
+			directive, rest := splitWord(line)
+			directive = strings.ToLower(directive)
+
+			switch directive {
+			case "#ruledef", "#subruledef", "#bankdef":
+				// gather up the block between the braces
+				block := rest
+				for !balanced(block) && i+1 < len(lines) {
+					i++
+					block += "\n" + stripComment(lines[i])
+				}
+
+				if directive == "#bankdef" {
+					asm.bankdef(st, block)
+				}
+
+			case "#bits":
+				// only relevant to customasm rule definitions
+
+			case "#bank":
+				st.kind = bankStmt
+				st.name = strings.TrimSpace(rest)
+				asm.stmts = append(asm.stmts, st)
+
+			case "#d", "#d8", "#d16", "#d32", "#d64":
+				st.kind = dataStmt
+				if directive != "#d" {
+					st.width, _ = strconv.Atoi(directive[2:])
+				}
+				st.args = splitOperands(rest)
+				asm.stmts = append(asm.stmts, st)
+
+			case "#res":
+				st.kind = resStmt
+				st.args = []string{rest}
+				asm.stmts = append(asm.stmts, st)
+
+			case "#align":
+				st.kind = alignStmt
+				st.args = []string{rest}
+				asm.stmts = append(asm.stmts, st)
+
+			case "#addr":
+				st.kind = addrStmt
+				st.args = []string{rest}
+				asm.stmts = append(asm.stmts, st)
+
+			default:
+				asm.errorf(st, "unknown directive %s", directive)
+			}
+			continue
+		}
+
+		// labels
+		for {
+			word, rest := splitWord(line)
+			if !strings.HasSuffix(word, ":") || !isIdent(word[:len(word)-1]) {
+				break
+			}
+			asm.stmts = append(asm.stmts, &stmt{
+				kind: labelStmt,
+				file: st.file,
+				line: st.line,
+				text: st.text,
+				name: word[:len(word)-1],
+			})
+			line = rest
+		}
+
+		if line == "" {
+			continue
+		}
+
+		// constant definitions
+		if eq := strings.Index(line, "="); eq > 0 && isIdent(strings.TrimSpace(line[:eq])) &&
+			!strings.HasPrefix(line[eq:], "==") {
+			st.kind = constStmt
+			st.name = strings.TrimSpace(line[:eq])
+			st.args = []string{strings.TrimSpace(line[eq+1:])}
+			asm.stmts = append(asm.stmts, st)
+			continue
+		}
+
+		mnemonic, rest := splitWord(line)
+		st.kind = instrStmt
+		st.name = strings.ToLower(mnemonic)
+		st.args = splitOperands(rest)
+		asm.stmts = append(asm.stmts, st)
+	}
+}
+
+func (asm *Assembler) bankdef(st *stmt, block string) {
+	open := strings.Index(block, "{")
+	close := strings.LastIndex(block, "}")
+	if open < 0 || close < open {
+		asm.errorf(st, "malformed #bankdef")
+		return
+	}
+
+	b := &bank{
+		name: strings.TrimSpace(block[:open]),
+		bits: 8,
+		outp: -1,
+	}
+
+	for _, line := range strings.Split(block[open+1:close], "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		directive, rest := splitWord(line)
+		val, err := evalExpr(rest, asm)
+		if err != nil {
+			asm.error(st, err)
+			return
+		}
+
+		switch strings.ToLower(directive) {
+		case "#bits":
+			b.bits = int(val.v)
+		case "#addr":
+			b.addr = val.v
+		case "#size":
+			b.size = val.v
+		case "#outp":
+			b.outp = val.v
+		default:
+			asm.errorf(st, "unknown bank directive %s", directive)
+		}
+	}
+
+	asm.defineBank(b)
+}
+
+// balanced returns true if block has a {} block with matching braces
+func balanced(block string) bool {
+	open := strings.Count(block, "{")
+	return open > 0 && open == strings.Count(block, "}")
+}
+
+// stripComment removes a trailing ; comment, ignoring semicolons in strings
+func stripComment(line string) string {
+	inString := byte(0)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case inString != 0 && c == '\\':
+			i++
+		case inString != 0 && c == inString:
+			inString = 0
+		case inString != 0:
+		case c == '"' || c == '\'':
+			inString = c
+		case c == ';':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitWord splits off the first whitespace separated word
+func splitWord(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	end := strings.IndexAny(line, " \t")
+	if end < 0 {
+		return line, ""
+	}
+	return line[:end], strings.TrimSpace(line[end:])
+}
+
+func isIdent(name string) bool {
+	if name == "" || !isIdentStart(name[0]) {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if !isIdentChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitOperands splits a comma separated list of operands, ignoring
+// commas inside of brackets, parentheses and strings
+func splitOperands(text string) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+
+	var ops []string
+	depth := 0
+	inString := byte(0)
+	start := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case inString != 0 && c == '\\':
+			i++
+		case inString != 0 && c == inString:
+			inString = 0
+		case inString != 0:
+		case c == '"' || c == '\'':
+			inString = c
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		case c == ',' && depth == 0:
+			ops = append(ops, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+	return append(ops, strings.TrimSpace(text[start:]))
+}
+
+// stringUnits converts a string literal data item into units
+func stringUnits(item string, bits int) ([]uint64, error) {
+	encoding := ""
+	if strings.HasSuffix(item, ")") {
+		open := strings.Index(item, "(")
+		encoding = item[:open]
+		item = item[open+1 : len(item)-1]
+	}
+
+	str, err := strconv.Unquote(item)
+	if err != nil {
+		return nil, fmt.Errorf("bad string %s: %w", item, err)
+	}
+
+	var chars []uint64
+	switch encoding {
+	case "":
+		for _, b := range []byte(str) {
+			chars = append(chars, uint64(b))
+		}
+		// each byte is put in its own unit
+		return chars, nil
+	case "utf16le":
+		for _, c := range utf16.Encode([]rune(str)) {
+			if bits == 16 {
+				chars = append(chars, uint64(c))
+			} else {
+				chars = append(chars, uint64(c&0xff), uint64(c>>8))
+			}
+		}
+		return chars, nil
+	case "utf32le":
+		for len(str) > 0 {
+			r, size := utf8.DecodeRuneInString(str)
+			str = str[size:]
+			chars = append(chars, uint64(r))
+		}
+		return chars, nil
+	}
+	return nil, fmt.Errorf("unknown string encoding %s", encoding)
+}
@@ -3,7 +3,9 @@ package compiler
 
 import (
 	"bytes"
+	"errors"
 	"flag"
+	"go/token"
 	"io"
 	"log"
 	"os"
@@ -12,14 +14,15 @@ import (
 	"strings"
 
 	"github.com/rj45/nanogo/asm2"
+	"github.com/rj45/nanogo/assembler"
 	"github.com/rj45/nanogo/codegen"
 	"github.com/rj45/nanogo/codegen/asm"
+	"github.com/rj45/nanogo/diag"
 	"github.com/rj45/nanogo/frontend"
 	"github.com/rj45/nanogo/goenv"
 	"github.com/rj45/nanogo/html"
 	html2 "github.com/rj45/nanogo/html2"
 	"github.com/rj45/nanogo/ir2"
-	"github.com/rj45/nanogo/ir2/parseir"
 	"github.com/rj45/nanogo/parser"
 	"github.com/rj45/nanogo/regalloc"
 	"github.com/rj45/nanogo/regalloc2"
@@ -29,16 +32,33 @@ import (
 
 	_ "github.com/rj45/nanogo/xform2/cleanup"
 	_ "github.com/rj45/nanogo/xform2/elaboration"
+	_ "github.com/rj45/nanogo/xform2/finishing"
+	_ "github.com/rj45/nanogo/xform2/inlining"
 	_ "github.com/rj45/nanogo/xform2/legalization"
 	_ "github.com/rj45/nanogo/xform2/lowering"
 	_ "github.com/rj45/nanogo/xform2/simplification"
 )
 
 type Arch interface {
+	assembler.Arch
+
 	Name() string
 	AssemblerFormat() string
-	EmulatorCmd() string
-	EmulatorArgs() []string
+	NewEmulator(out, trace io.Writer) Emulator
+}
+
+// Emulator simulates the CPU of an Arch, to run programs without the
+// hardware or any other tools
+type Emulator interface {
+	// Load loads a program image in the Arch's AssemblerFormat
+	Load(image io.Reader) error
+
+	// Run runs the program until it halts, and returns its exit code,
+	// or an error if it executed an error or illegal instruction
+	Run() (int, error)
+
+	// Cycles returns how many cycles the program has run for
+	Cycles() uint64
 }
 
 func SetArch(a Arch) {
@@ -53,7 +73,24 @@ const (
 	Asm Mode = 1 << iota
 	Assemble
 	Run
+
+	// IR writes the IR of the program after the stage given by the
+	// -stage flag, which can be parsed back in and compiled from there
 	IR
+
+	Legacy
+
+	// Separate compiles each package into an object, reusing the ones
+	// in the build cache, and links them when assembling
+	Separate
+
+	// Objects writes the object of each package into the output folder
+	// instead of linking them
+	Objects
+
+	// Interpret runs the program with the ir2 interpreter after the stage
+	// given by the -stage flag instead of assembling it
+	Interpret
 )
 
 type dumper interface {
@@ -99,9 +136,15 @@ var _ io.WriteCloser = nopWriteCloser{}
 var dump = flag.String("dump", "", "Dump a function to ssa.html")
 var trace = flag.Bool("trace", false, "debug program with tracing info")
 var debug = flag.Bool("debug", false, "dump debug html/dot files")
+var customasm = flag.Bool("customasm", false, "assemble with customasm instead of the built-in assembler")
 
 func Compile(outname, dir string, patterns []string, mode Mode) int {
 	log.SetFlags(log.Lshortfile)
+	diag.Reset()
+
+	if mode&Objects != 0 {
+		return compileObjects(outname, dir, patterns)
+	}
 
 	var finalout io.WriteCloser
 	var asmout io.WriteCloser
@@ -116,152 +159,538 @@ func Compile(outname, dir string, patterns []string, mode Mode) int {
 		finalout = f
 	}
 
-	if filepath.Ext(patterns[0]) == ".ngir" {
-		f, err := os.Open(patterns[0])
+	if mode&IR != 0 {
+		return writeIR(finalout, dir, patterns)
+	}
+
+	if mode&Interpret != 0 {
+		return interpret(finalout, dir, patterns)
+	}
+
+	if mode&Separate != 0 && mode&Assemble != 0 {
+		var image io.Reader
+		if objs := compilePackages(dir, patterns); objs != nil {
+			image = link(objs)
+		}
+
+		diag.Print(os.Stderr)
+		if diag.NumErrors() > 0 {
+			return 1
+		}
+		return output(image, finalout, mode)
+	}
+
+	asmout = finalout
+
+	var asmbuf *bytes.Buffer
+	var binfile string
+	var asmcmd *exec.Cmd
+	if mode&Assemble != 0 && !*customasm {
+		asmbuf = &bytes.Buffer{}
+		asmout = nopWriteCloser{asmbuf}
+	} else if mode&Assemble != 0 {
+		// todo: if specified, allow this to not be a temp file
+		asmtemp, err := os.CreateTemp("", "nanogo_*.asm")
 		if err != nil {
-			panic(err)
+			log.Fatalln("failed to create temp asm file for customasm:", err)
 		}
-		defer f.Close()
+		defer os.Remove(asmtemp.Name())
 
-		prog := &ir2.Program{}
-		p, err := parseir.NewParser(patterns[0], f, prog, *trace)
+		bintemp, err := os.CreateTemp("", "nanogo_*.bin")
 		if err != nil {
-			panic(err)
+			log.Fatalln("failed to create temp bin file for customasm:", err)
 		}
+		bintemp.Close() // customasm will write to it
+		binfile = bintemp.Name()
+		defer os.Remove(bintemp.Name())
+
+		root := goenv.Get("NANOGOROOT")
+		path := filepath.Join(root, "arch", arch.Name(), "customasm")
+		cpudef := filepath.Join(path, "cpudef.asm")
+		rungo := filepath.Join(path, "rungo.asm")
+
+		asmcmd = exec.Command("customasm", "-q",
+			"-f", arch.AssemblerFormat(),
+			"-o", bintemp.Name(),
+			cpudef, rungo, asmtemp.Name())
+		log.Println(asmcmd)
+		asmcmd.Stderr = os.Stderr
+		asmcmd.Stdout = os.Stdout
+		asmout = asmtemp
+	}
+
+	if mode&Legacy != 0 {
+		compileLegacy(asmout, dir, patterns)
+	} else {
+		compileIR2(asmout, dir, patterns)
+	}
+
+	asmout.Close()
 
-		err = p.Parse()
+	var image io.Reader
+	if asmbuf != nil && diag.NumErrors() == 0 {
+		image = assemble(asmbuf.Bytes())
+	}
+
+	diag.Print(os.Stderr)
+	if diag.NumErrors() > 0 {
+		return 1
+	}
+
+	if asmcmd != nil {
+		if err := asmcmd.Run(); err != nil {
+			os.Exit(1)
+		}
+		f, err := os.Open(binfile)
 		if err != nil {
-			p.PrintErrors()
-			return 1
+			log.Fatal(err)
 		}
+		defer f.Close()
+		image = f
+	}
 
-		prog.Emit(finalout, ir2.SSAString{})
+	return output(image, finalout, mode)
+}
 
+// output runs the image if the mode has Run, returning its exit code,
+// otherwise it writes the image to out
+func output(image io.Reader, out io.Writer, mode Mode) int {
+	if image == nil {
 		return 0
 	}
 
-	if mode&IR != 0 {
-		fe, err := frontend.NewFrontEnd(dir, patterns...)
+	if mode&Run != 0 {
+		return run(image, out)
+	}
+
+	if _, err := io.Copy(out, image); err != nil {
+		log.Fatal(err)
+	}
+	return 0
+}
+
+// assemble assembles the program with the built-in assembler, after the
+// arch's cpudef.asm and rungo.asm, and returns the image in the arch's
+// AssemblerFormat. If there are errors, the assembly is kept in a temp
+// file for them to refer to, and nil is returned.
+func assemble(program []byte) io.Reader {
+	// the program is named after the temp file it's kept in on errors
+	const programName = "program.asm"
+	sources := append(layoutSources(), assembler.Source{Name: programName, Text: string(program)})
+
+	img, err := assembler.Assemble(arch, sources...)
+	if err != nil {
+		kept := ""
+		reportAsmErrors(err, func(filename string) string {
+			if filename != programName {
+				return filename
+			}
+			if kept == "" {
+				kept = keepAsm(program)
+			}
+			return kept
+		})
+		return nil
+	}
+
+	return imageReader(img)
+}
+
+// layoutSources returns the arch's cpudef.asm and rungo.asm, which
+// define its banks and start the program
+func layoutSources() []assembler.Source {
+	root := goenv.Get("NANOGOROOT")
+	path := filepath.Join(root, "arch", arch.Name(), "customasm")
+
+	var sources []assembler.Source
+	for _, name := range []string{"cpudef.asm", "rungo.asm"} {
+		filename := filepath.Join(path, name)
+		text, err := os.ReadFile(filename)
 		if err != nil {
 			log.Fatal(err)
 		}
+		sources = append(sources, assembler.Source{Name: filename, Text: string(text)})
+	}
+	return sources
+}
+
+// imageReader returns the image in the arch's AssemblerFormat
+func imageReader(img *assembler.Image) io.Reader {
+	buf := &bytes.Buffer{}
+	if err := img.Write(buf, arch.AssemblerFormat()); err != nil {
+		log.Fatal(err)
+	}
+	return buf
+}
+
+// reportAsmErrors reports the errors from the assembler with the diag
+// package, with filename giving the file each one should refer to
+func reportAsmErrors(err error, filename func(string) string) {
+	list, ok := err.(assembler.ErrorList)
+	if !ok {
+		diag.ErrorAt(token.Position{}, "%s", err)
+		return
+	}
+
+	for _, e := range list {
+		msg := e.Err.Error()
+		if e.Label != "" {
+			msg = "in " + e.Label + ": " + msg
+		}
+		diag.ErrorAt(token.Position{Filename: filename(e.File), Line: e.Line}, "%s", msg)
+	}
+}
+
+// keepAsm writes the assembly to a temp file that isn't removed, and
+// returns its name
+func keepAsm(program []byte) string {
+	f, err := os.CreateTemp("", "nanogo_*.asm")
+	if err != nil {
+		log.Fatalln("failed to create temp asm file:", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(program); err != nil {
+		log.Fatalln("failed to write temp asm file:", err)
+	}
+	return f.Name()
+}
+
+// run runs the assembled program in the arch's emulator, with its output
+// going to out, and returns its exit code
+func run(image io.Reader, out io.Writer) int {
+	var tracer io.Writer
+	if *trace {
+		tracer = os.Stderr
+	}
+	emu := arch.NewEmulator(out, tracer)
+
+	if err := emu.Load(image); err != nil {
+		log.Fatal(err)
+	}
+
+	code, err := emu.Run()
+	log.Printf("ran for %d cycles", emu.Cycles())
+	if err != nil {
+		log.Println(err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	return code
+}
+
+// compileIR2 compiles the packages with the frontend, xform2, regalloc2
+// and asm2 pipeline and writes the assembly to out. A .ngir file is
+// compiled from the stage its funcs were written at instead. Errors are
+// reported with the diag package, in which case nothing is written.
+func compileIR2(out io.Writer, dir string, patterns []string) {
+	prog, src := newSource(dir, patterns)
+	if prog == nil {
+		return
+	}
+
+	c := newFuncCompiler(src)
+	defer c.close()
+	c.compile(stageFinishing)
+	if diag.NumErrors() > 0 {
+		return
+	}
+
+	prune(prog)
 
-		fe.Scan()
-		for fn := fe.NextUnparsedFunc(); fn != nil; fn = fe.NextUnparsedFunc() {
+	asm2.Emit(out, asm2.CustomASM{}, prog)
+}
+
+// prune removes the funcs and globals that can't be reached from main,
+// since dead code has been removed from the funcs by the end of the
+// pipeline, so what it referred to may not be needed anymore
+func prune(prog *ir2.Program) {
+	mainpkg := prog.Package("main")
+	if mainpkg == nil {
+		log.Fatal("the program has no main package")
+	}
+	roots := []*ir2.Func{mainpkg.Func("init"), mainpkg.Func("main")}
+	for i, name := range []string{"init", "main"} {
+		// the startup code calls them both
+		if roots[i] == nil {
+			log.Fatalf("the program has no main.%s func", name)
+		}
+	}
+	prog.Prune(roots...)
+}
+
+// newSource returns the program and the source of its funcs, which the
+// front end parses from the Go packages, or which are parsed already
+// from the IR of a .ngir file. Nil is returned once errors have been
+// reported with the diag package.
+func newSource(dir string, patterns []string) (*ir2.Program, funcSource) {
+	if filepath.Ext(patterns[0]) == ".ngir" {
+		prog := parseIR(patterns[0])
+		if prog == nil {
+			return nil, nil
+		}
+		diag.SetFileSet(prog.FileSet)
+		return prog, newIRSource(prog)
+	}
+
+	fe, err := frontend.NewFrontEnd(dir, patterns...)
+	if errors.Is(err, frontend.ErrParsing) {
+		// the errors have already been reported
+		return nil, nil
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	diag.SetFileSet(fe.Program().FileSet)
+
+	fe.Scan()
+
+	return fe.Program(), fe
+}
+
+// compileFuncs takes the funcs the front end has to parse through the
+// ir2 pipeline. Errors are reported with the diag package.
+func compileFuncs(fe *frontend.FrontEnd) {
+	c := newFuncCompiler(fe)
+	defer c.close()
+	c.compile(stageFinishing)
+}
+
+// the stages of the ir2 pipeline, which are named after the phases they
+// write to the dumpers
+const (
+	stageInitial = iota
+	stageInlining
+	stageElaboration
+	stageSimplification
+	stageLowering
+	stageLegalization
+	stageRegAlloc
+	stageCleanUp
+	stageFinishing
+)
+
+var stageNames = []string{
+	"initial",
+	"inlining",
+	"elaboration",
+	"simplification",
+	"lowering",
+	"legalization",
+	"regalloc",
+	"cleanup",
+	"finishing",
+}
+
+// funcSource has the funcs for a funcCompiler to compile, which it
+// parses as they come to be referenced. The front end parses them from
+// Go, and the funcs of a .ngir file are parsed already.
+type funcSource interface {
+	NextUnparsedFunc() *ir2.Func
+	ParseFunc(fn *ir2.Func)
+	DumpOrignalSource(fn *ir2.Func) (filename string, lines []string, startline int)
+}
+
+// funcCompiler takes the funcs of a funcSource through the stages of
+// the ir2 pipeline, and can stop after any of them, so that the program
+// can be looked at as it is after each stage
+type funcCompiler struct {
+	src funcSource
+
+	// the funcs parsed, in order, and the last stage each has been
+	// through, or failed in
+	fns     []*ir2.Func
+	stage   map[*ir2.Func]int
+	failed  map[*ir2.Func]bool
+	dumpers map[*ir2.Func]dumper2
+}
+
+func newFuncCompiler(src funcSource) *funcCompiler {
+	return &funcCompiler{
+		src:     src,
+		stage:   make(map[*ir2.Func]int),
+		failed:  make(map[*ir2.Func]bool),
+		dumpers: make(map[*ir2.Func]dumper2),
+	}
+}
+
+// compile takes all the funcs through the stages up to and including
+// the last, parsing the funcs they come to refer to as they go
+func (c *funcCompiler) compile(last int) {
+	// the funcs are parsed in rounds, all before any are transformed,
+	// so the funcs inlined are copied as they were parsed. The runtime
+	// funcs elaboration adds calls to are parsed in the next round.
+	for {
+		var fns []*ir2.Func
+		for fn := c.src.NextUnparsedFunc(); fn != nil; fn = c.src.NextUnparsedFunc() {
 			var w dumper2
 			w = nopDumper2{}
 			if *dump != "" && strings.Contains(fn.FullName, *dump) {
 				w = html2.NewHTMLWriter("ssa.html", fn)
-				filename, lines, start := fe.DumpOrignalSource(fn)
+				filename, lines, start := c.src.DumpOrignalSource(fn)
 				w.WriteSources("go", filename, lines, start)
 				// w.WriteAsmBuf("tools/go/ssa", parser.DumpOriginalSSA(fn))
 			}
-			defer w.Close()
 
-			fe.ParseFunc(fn)
+			numErrors := diag.NumErrors()
 
-			w.WritePhase("initial", "initial")
+			c.src.ParseFunc(fn)
 
-			xform2.Transform(xform2.Elaboration, fn)
-			w.WritePhase("elaboration", "elaboration")
+			stage := funcStage(fn)
+			w.WritePhase(stageNames[stage], stageNames[stage])
 
-			xform2.Transform(xform2.Simplification, fn)
-			w.WritePhase("simplification", "simplification")
+			fns = append(fns, fn)
+			c.fns = append(c.fns, fn)
+			c.setStage(fn, stage)
+			c.dumpers[fn] = w
+			c.failed[fn] = diag.NumErrors() > numErrors
+		}
 
-			xform2.Transform(xform2.Lowering, fn)
-			w.WritePhase("lowering", "lowering")
+		if len(fns) == 0 && !c.behind(last) {
+			break
+		}
 
-			xform2.Transform(xform2.Legalization, fn)
-			w.WritePhase("legalization", "legalization")
-
-			ra := regalloc2.NewRegAlloc(fn)
-			err = ra.Allocate()
-			if *debug {
-				regalloc2.WriteGraphvizCFG(ra)
-				regalloc2.DumpLivenessChart(ra)
-				regalloc2.WriteGraphvizInterferenceGraph(ra)
-				regalloc2.WriteGraphvizLivenessGraph(ra)
+		// a func that failed to parse could be inlined into the others,
+		// so nothing is inlined once there are errors
+		if last >= stageInlining {
+			var parsed []*ir2.Func
+			for _, fn := range c.fns {
+				if c.stage[fn] == stageInitial {
+					parsed = append(parsed, fn)
+					c.setStage(fn, stageInlining)
+				}
 			}
-			w.WritePhase("regalloc", "regalloc")
-			if err != nil {
-				log.Fatal(err)
+			if diag.NumErrors() == 0 {
+				for _, fn := range parsed {
+					xform2.Transform(xform2.Inlining, fn)
+					c.dumpers[fn].WritePhase("inlining", "inlining")
+				}
 			}
-			errs := verify.Verify(fn)
-			for _, err := range errs {
-				log.Printf("verification error: %s\n", err)
-			}
-			if len(errs) > 0 {
-				log.Fatal("verification failed")
+		}
+
+		for _, fn := range c.fns {
+			if !c.failed[fn] && c.stage[fn] < last {
+				var stage int
+				stage, c.failed[fn] = transformFunc(fn, c.dumpers[fn], c.stage[fn], last)
+				c.setStage(fn, stage)
 			}
+		}
+	}
+}
 
-			xform2.Transform(xform2.CleanUp, fn)
-			w.WritePhase("cleanup", "cleanup")
+// setStage records the stage the func has been through, which is also
+// kept with the func, so it's written out with its IR
+func (c *funcCompiler) setStage(fn *ir2.Func, stage int) {
+	c.stage[fn] = stage
+	fn.Stage = stageNames[stage]
+}
 
-			xform2.Transform(xform2.Finishing, fn)
-			w.WritePhase("finishing", "finishing")
+// funcStage returns the stage a func has been through when it's parsed,
+// which is the initial stage unless it was parsed from IR written after
+// a later one
+func funcStage(fn *ir2.Func) int {
+	if fn.Stage == "" {
+		return stageInitial
+	}
+	for i, name := range stageNames {
+		if name == fn.Stage {
+			return i
 		}
+	}
+	log.Fatalf("%s is at the unknown stage %q", fn.FullName, fn.Stage)
+	return stageInitial
+}
 
-		// fe.Program().Emit(finalout, ir2.SSAString{})
-		asm2.Emit(finalout, asm2.CustomASM{}, fe.Program())
+// behind returns whether any func that hasn't failed still has to be
+// taken through the last stage
+func (c *funcCompiler) behind(last int) bool {
+	for _, fn := range c.fns {
+		if !c.failed[fn] && c.stage[fn] < last {
+			return true
+		}
+	}
+	return false
+}
 
-		return 0
+func (c *funcCompiler) close() {
+	for _, fn := range c.fns {
+		c.dumpers[fn].Close()
 	}
+}
 
-	asmout = finalout
+// transformFunc takes a func through the stages of the ir2 pipeline after
+// the one it's at up to the last, and returns the stage it got to, and
+// whether it failed. Once the func has errors, the rest of its stages
+// are skipped, but other funcs are still compiled to find more errors.
+func transformFunc(fn *ir2.Func, w dumper2, stage, last int) (int, bool) {
+	numErrors := diag.NumErrors()
 
-	var binfile string
-	var asmcmd *exec.Cmd
-	if mode&Assemble != 0 {
-		// todo: if specified, allow this to not be a temp file
-		asmtemp, err := os.CreateTemp("", "nanogo_*.asm")
-		if err != nil {
-			log.Fatalln("failed to create temp asm file for customasm:", err)
+	for stage < last {
+		stage++
+
+		switch stage {
+		case stageElaboration:
+			xform2.Transform(xform2.Elaboration, fn)
+			fn.Elaborated = true
+		case stageSimplification:
+			xform2.Transform(xform2.Simplification, fn)
+		case stageLowering:
+			xform2.Transform(xform2.Lowering, fn)
+		case stageLegalization:
+			xform2.Transform(xform2.Legalization, fn)
+		case stageRegAlloc:
+			allocate(fn, w)
+			continue
+		case stageCleanUp:
+			xform2.Transform(xform2.CleanUp, fn)
+		case stageFinishing:
+			xform2.Transform(xform2.Finishing, fn)
 		}
-		defer os.Remove(asmtemp.Name())
+		w.WritePhase(stageNames[stage], stageNames[stage])
 
-		bintemp, err := os.CreateTemp("", "nanogo_*.bin")
-		if err != nil {
-			log.Fatalln("failed to create temp bin file for customasm:", err)
+		if diag.NumErrors() > numErrors {
+			return stage, true
 		}
-		bintemp.Close() // customasm will write to it
-		binfile = bintemp.Name()
-		defer os.Remove(bintemp.Name())
+	}
 
-		root := goenv.Get("NANOGOROOT")
-		path := filepath.Join(root, "arch", arch.Name(), "customasm")
-		cpudef := filepath.Join(path, "cpudef.asm")
-		rungo := filepath.Join(path, "rungo.asm")
+	return stage, false
+}
 
-		asmcmd = exec.Command("customasm", "-q",
-			"-f", arch.AssemblerFormat(),
-			"-o", bintemp.Name(),
-			cpudef, rungo, asmtemp.Name())
-		log.Println(asmcmd)
-		asmcmd.Stderr = os.Stderr
-		asmcmd.Stdout = os.Stdout
-		asmout = asmtemp
+// allocate allocates registers for the func, and verifies the allocation
+func allocate(fn *ir2.Func, w dumper2) {
+	ra := regalloc2.NewRegAlloc(fn)
+	err := ra.Allocate()
+	if *debug {
+		regalloc2.WriteGraphvizCFG(ra)
+		regalloc2.DumpLivenessChart(ra)
+		regalloc2.WriteGraphvizInterferenceGraph(ra)
+		regalloc2.WriteGraphvizLivenessGraph(ra)
 	}
-
-	var runcmd *exec.Cmd
-	if mode&Run != 0 {
-		args := arch.EmulatorArgs()
-		args = append(args, binfile)
-		if *trace {
-			args = append(args, "-trace")
-		}
-		runcmd = exec.Command(arch.EmulatorCmd(), args...)
-		runcmd.Stderr = os.Stderr
-		runcmd.Stdout = finalout
-		runcmd.Stdin = os.Stdin
+	w.WritePhase("regalloc", "regalloc")
+	if err != nil {
+		log.Fatal(err)
 	}
+	errs := verify.Verify(fn)
+	for _, err := range errs {
+		log.Printf("verification error: %s\n", err)
+	}
+	if len(errs) > 0 {
+		log.Fatal("verification failed")
+	}
+}
 
+// compileLegacy compiles the packages with the original parser, xform,
+// regalloc and codegen pipeline and writes the assembly to out
+func compileLegacy(out io.Writer, dir string, patterns []string) {
 	parser := parser.NewParser(dir, patterns...)
 	parser.Scan()
 
 	pkg := parser.Package()
 
 	gen := codegen.NewGenerator(pkg)
-	emit := asm.NewEmitter(asmout)
+	emit := asm.NewEmitter(out)
 
 	for fn := parser.NextUnparsedFunc(); fn != nil; fn = parser.NextUnparsedFunc() {
 		var w dumper
@@ -309,37 +738,4 @@ func Compile(outname, dir string, patterns []string, mode Mode) int {
 		w.WriteAsm("asm", asm)
 		emit.Func(asm)
 	}
-
-	asmout.Close()
-
-	if asmcmd != nil {
-		if err := asmcmd.Run(); err != nil {
-			os.Exit(1)
-		}
-		if mode&Run == 0 {
-			// todo: read file and emit to finalout
-			f, err := os.Open(binfile)
-			if err != nil {
-				log.Fatal(err)
-			}
-			_, err = io.Copy(finalout, f)
-			f.Close()
-			if err != nil {
-				log.Fatal(err)
-			}
-		}
-	}
-
-	if runcmd != nil {
-		if err := runcmd.Run(); err != nil {
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				// don't treat exit errors as an error, instead return the exit code
-				return exitErr.ExitCode()
-			}
-			return 1
-		}
-		return runcmd.ProcessState.ExitCode()
-	}
-
-	return 0
 }
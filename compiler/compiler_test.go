@@ -2,13 +2,16 @@ package compiler_test
 
 import (
 	"bytes"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/rj45/nanogo/arch"
 	"github.com/rj45/nanogo/compiler"
-	"github.com/rj45/nanogo/frontend"
-	"github.com/rj45/nanogo/ir2"
-	"github.com/rj45/nanogo/ir2/parseir"
 
 	// load the supported architectures so they register with the arch package
 	_ "github.com/rj45/nanogo/arch/a32"
@@ -57,11 +60,164 @@ var testCases = []struct {
 	},
 }
 
+// ir2TestCases are only supported by the ir2 pipeline
+var ir2TestCases = []struct {
+	desc     string
+	filename string
+}{
+	{
+		desc:     "more params and results than arg registers",
+		filename: "./manyparams/",
+	},
+	{
+		desc:     "making, appending, slicing and copying slices",
+		filename: "./slices/",
+	},
+	{
+		desc:     "escaping locals, heap buffers and string concatenation",
+		filename: "./heap/",
+	},
+	{
+		desc:     "software multiply, divide and remainder",
+		filename: "./arith/",
+	},
+	{
+		desc:     "integers wider than a word",
+		filename: "./multiword/",
+	},
+	{
+		desc:     "converting to integers narrower than a word",
+		filename: "./conversions/",
+	},
+	{
+		desc:     "closures and func values",
+		filename: "./closures/",
+	},
+	{
+		desc:     "interfaces and methods",
+		filename: "./interfaces/",
+	},
+	{
+		desc:     "maps",
+		filename: "./maps/",
+	},
+	{
+		desc:     "strings",
+		filename: "./strings/",
+	},
+	{
+		desc:     "goroutines and channels",
+		filename: "./goroutines/",
+	},
+	{
+		desc:     "defer, panic and recover",
+		filename: "./panics/",
+	},
+	{
+		desc:     "constant folding and propagation",
+		filename: "./constfold/",
+	},
+	{
+		desc:     "loop optimizations",
+		filename: "./loops/",
+	},
+	{
+		desc:     "dead code elimination",
+		filename: "./deadcode/",
+	},
+	{
+		desc:     "inlining",
+		filename: "./inline/",
+	},
+	{
+		desc:     "programs with more than one package",
+		filename: "./packages/",
+	},
+}
+
+// pipelines are the ways the compiler can be run
+var pipelines = []struct {
+	desc string
+	mode compiler.Mode
+}{
+	{
+		desc: "ir2",
+		mode: 0,
+	},
+	{
+		desc: "legacy",
+		mode: compiler.Legacy,
+	},
+	{
+		desc: "separate compilation",
+		mode: compiler.Separate,
+	},
+}
+
+func TestMain(m *testing.M) {
+	// the tests share a build cache for separate compilation,
+	// so each arch's runtime is only compiled once
+	dir, err := os.MkdirTemp("", "nanogo_cache_*")
+	if err != nil {
+		log.Fatal(err)
+	}
+	flag.Set("cachedir", dir)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func TestCompilerForRj32(t *testing.T) {
-	for _, tC := range testCases {
-		t.Run("runs "+tC.desc+" on rj32", func(t *testing.T) {
-			arch.SetArch("rj32")
-			result := compiler.Compile("-", "../testdata/", []string{tC.filename}, compiler.Assemble|compiler.Run)
+	testCompilerFor(t, "rj32")
+}
+
+func TestCompilerForA32(t *testing.T) {
+	testCompilerFor(t, "a32")
+}
+
+func testCompilerFor(t *testing.T, archName string) {
+	for _, pl := range pipelines {
+		cases := testCases
+		if pl.mode&compiler.Legacy == 0 {
+			cases = append(cases, ir2TestCases...)
+		}
+
+		for _, tC := range cases {
+			t.Run("runs "+tC.desc+" on "+archName+" with "+pl.desc, func(t *testing.T) {
+				arch.SetArch(archName)
+				result := compiler.Compile("-", "../testdata/", []string{tC.filename}, compiler.Assemble|compiler.Run|pl.mode)
+				if result != 0 {
+					t.Errorf("test %s failed with code %d", tC.filename, result)
+				}
+			})
+		}
+	}
+}
+
+func TestInterpreterForRj32(t *testing.T) {
+	testInterpreterFor(t, "rj32")
+}
+
+func TestInterpreterForA32(t *testing.T) {
+	testInterpreterFor(t, "a32")
+}
+
+// testInterpreterFor runs each test program with the ir2 interpreter
+// after every stage, which fails if a stage changes what it does
+func testInterpreterFor(t *testing.T, archName string) {
+	flag.Set("stage", "each")
+	defer flag.Set("stage", "finishing")
+
+	for _, tC := range append(testCases, ir2TestCases...) {
+		if tC.filename == "./nqueens/" {
+			// too slow to interpret after every stage
+			continue
+		}
+
+		t.Run("interprets "+tC.desc+" on "+archName+" after each stage", func(t *testing.T) {
+			arch.SetArch(archName)
+			result := compiler.Compile("-", "../testdata/", []string{tC.filename}, compiler.Interpret)
 			if result != 0 {
 				t.Errorf("test %s failed with code %d", tC.filename, result)
 			}
@@ -69,69 +225,101 @@ func TestCompilerForRj32(t *testing.T) {
 	}
 }
 
-// func TestCompilerForA32(t *testing.T) {
-// 	for _, tC := range testCases {
-// 		t.Run("runs "+tC.desc+" on a32", func(t *testing.T) {
-// 			arch.SetArch("a32")
-// 			result := compiler.Compile("-", "../testdata/", []string{tC.filename}, compiler.Assemble|compiler.Run)
-// 			if result != 0 {
-// 				t.Errorf("test %s failed with code %d", tC.filename, result)
-// 			}
-// 		})
-// 	}
-// }
-
-func TestCompileToFromIR(t *testing.T) {
-	t.Skip("not producing identical IR right now for some reason")
-	for _, tC := range testCases {
-		t.Run("compiles "+tC.desc+" IR identically", func(t *testing.T) {
-			arch.SetArch("rj32")
-
-			bufA := &bytes.Buffer{}
-			bufB := &bytes.Buffer{}
+func TestCompileAndLink(t *testing.T) {
+	for _, archName := range []string{"rj32", "a32"} {
+		t.Run("links objects on "+archName, func(t *testing.T) {
+			arch.SetArch(archName)
 
-			// compile the go to IR
-			{
-				fe, err := frontend.NewFrontEnd("../testdata/", tC.filename)
-				if err != nil {
-					t.Error(err)
-				}
+			dir := t.TempDir()
+			result := compiler.Compile(dir, "../testdata/", []string{"./packages/"}, compiler.Separate|compiler.Objects)
+			if result != 0 {
+				t.Fatalf("compile failed with code %d", result)
+			}
 
-				fe.Scan()
-				for fn := fe.NextUnparsedFunc(); fn != nil; fn = fe.NextUnparsedFunc() {
-					fe.ParseFunc(fn)
-				}
+			objects, err := filepath.Glob(filepath.Join(dir, "*.o"))
+			if err != nil || len(objects) < 3 {
+				t.Fatalf("expected objects for main, shapes and runtime, got %v", objects)
+			}
 
-				fe.Program().Emit(bufA, ir2.SSAString{})
+			result = compiler.Link("-", objects, compiler.Run)
+			if result != 0 {
+				t.Errorf("linked program failed with code %d", result)
 			}
+		})
+	}
+}
+
+// stages are the stages of the ir2 pipeline the IR can be written after
+var stages = []string{
+	"initial",
+	"inlining",
+	"elaboration",
+	"simplification",
+	"lowering",
+	"legalization",
+	"regalloc",
+	"cleanup",
+	"finishing",
+}
 
-			// compile the IR
-			{
-				prog := &ir2.Program{}
-				p, err := parseir.NewParser(tC.filename, bytes.NewReader(bufA.Bytes()), prog, false)
+func TestCompileToFromIRForRj32(t *testing.T) {
+	testCompileToFromIRFor(t, "rj32")
+}
+
+func TestCompileToFromIRForA32(t *testing.T) {
+	testCompileToFromIRFor(t, "a32")
+}
+
+// testCompileToFromIRFor writes the IR of each test program after every
+// stage, checks that parsing it back in writes out the same IR, and
+// that the program compiled from there still runs
+func testCompileToFromIRFor(t *testing.T, archName string) {
+	defer flag.Set("stage", "finishing")
+
+	for _, tC := range append(testCases, ir2TestCases...) {
+		if tC.filename == "./nqueens/" {
+			// too slow to compile from after every stage
+			continue
+		}
+
+		for _, stage := range stages {
+			t.Run("compiles "+tC.desc+" on "+archName+" from IR after "+stage, func(t *testing.T) {
+				arch.SetArch(archName)
+				flag.Set("stage", stage)
+
+				dir := t.TempDir()
+				a := filepath.Join(dir, "a.ngir")
+				b := filepath.Join(dir, "b.ngir")
+
+				if result := compiler.Compile(a, "../testdata/", []string{tC.filename}, compiler.IR); result != 0 {
+					t.Fatalf("writing IR failed with code %d", result)
+				}
+				if result := compiler.Compile(b, "../testdata/", []string{a}, compiler.IR); result != 0 {
+					t.Fatalf("parsing IR failed with code %d", result)
+				}
+
+				bufA, err := os.ReadFile(a)
 				if err != nil {
 					t.Fatal(err)
 				}
-
-				err = p.Parse()
+				bufB, err := os.ReadFile(b)
 				if err != nil {
-					p.PrintErrors()
 					t.Fatal(err)
 				}
+				if !bytes.Equal(bufA, bufB) {
+					t.Error("expected the IR parsed back in to be written out the same, was not!")
+				}
 
-				prog.Emit(bufB, ir2.SSAString{})
-			}
-
-			// check if they are equal
-			if !bytes.Equal(bufA.Bytes(), bufB.Bytes()) {
-				t.Error("expected equal outputs, was not!")
-			}
-		})
+				if result := compiler.Compile("-", "../testdata/", []string{a}, compiler.Assemble|compiler.Run); result != 0 {
+					t.Errorf("test %s failed from IR with code %d", tC.filename, result)
+				}
+			})
+		}
 	}
 }
 
 func TestTryIR2Compile(t *testing.T) {
-	for _, tC := range testCases {
+	for _, tC := range append(testCases, ir2TestCases...) {
 		t.Run("compiles "+tC.desc, func(t *testing.T) {
 			arch.SetArch("rj32")
 
@@ -142,3 +330,99 @@ func TestTryIR2Compile(t *testing.T) {
 		})
 	}
 }
+
+// compileIR writes the IR of the test program after the stage, and
+// returns the IR of each of its funcs by name
+func compileIR(t *testing.T, archName, filename, stage string) map[string]string {
+	t.Helper()
+	arch.SetArch(archName)
+	flag.Set("stage", stage)
+	defer flag.Set("stage", "finishing")
+
+	out := filepath.Join(t.TempDir(), "out.ngir")
+	if result := compiler.Compile(out, "../testdata/", []string{filename}, compiler.IR); result != 0 {
+		t.Fatalf("writing IR failed with code %d", result)
+	}
+	buf, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	funcs := make(map[string]string)
+	for _, chunk := range strings.Split(string(buf), "\n\n") {
+		chunk = strings.TrimSpace(chunk)
+		if !strings.HasPrefix(chunk, "func ") {
+			continue
+		}
+		name := strings.TrimPrefix(chunk, "func ")
+		name = name[:strings.IndexAny(name, "(:")]
+		funcs[name] = chunk
+	}
+	return funcs
+}
+
+func TestNoBoundsPragma(t *testing.T) {
+	for _, archName := range []string{"rj32", "a32"} {
+		t.Run("skips bounds checks on "+archName, func(t *testing.T) {
+			funcs := compileIR(t, archName, "./print/", "elaboration")
+
+			printstring, ok := funcs["runtime__printstring"]
+			if !ok {
+				t.Fatal("expected runtime.printstring to be compiled")
+			}
+			if strings.Contains(printstring, "panicError") {
+				t.Errorf("expected no bounds checks in the //go:nobounds runtime.printstring, got:\n%s", printstring)
+			}
+		})
+	}
+}
+
+func TestInlining(t *testing.T) {
+	for _, archName := range []string{"rj32", "a32"} {
+		t.Run("follows the pragmas on "+archName, func(t *testing.T) {
+			funcs := compileIR(t, archName, "./inline/", "inlining")
+
+			main := funcs["main__main"]
+			calls := map[string]bool{
+				"main__ptr_counter_get":  false,
+				"main__ptr_counter_bump": true,
+				"main__sumTo":            false, // //go:inline, though it's too big
+				"main__triple":           true,  // //go:noinline, though it's small
+			}
+			for name, called := range calls {
+				call := regexp.MustCompile(`call \^` + name + `\b`)
+				if got := call.MatchString(main); got != called {
+					t.Errorf("expected main to call %s to be %v, got:\n%s", name, called, main)
+				}
+			}
+		})
+	}
+}
+
+func TestHardwareMulDiv(t *testing.T) {
+	for _, tC := range []struct {
+		archName string
+		hardware bool
+	}{
+		{"rj32", false},
+		{"a32", true},
+	} {
+		t.Run("follows the tags on "+tC.archName, func(t *testing.T) {
+			funcs := compileIR(t, tC.archName, "./arith/", "elaboration")
+
+			calls := map[string]string{
+				"main__mul":  "runtime__mulsi",
+				"main__div":  "runtime__divsi",
+				"main__rem":  "runtime__modsi",
+				"main__divu": "runtime__udivsi",
+				"main__remu": "runtime__umodsi",
+			}
+			for fn, name := range calls {
+				call := regexp.MustCompile(`call \^` + name + `\b`)
+				if got := call.MatchString(funcs[fn]); got == tC.hardware {
+					t.Errorf("expected %s to call %s to be %v, got:\n%s", fn, name, !tC.hardware, funcs[fn])
+				}
+			}
+		})
+	}
+}
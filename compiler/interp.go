@@ -0,0 +1,171 @@
+package compiler
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/rj45/nanogo/diag"
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/interp"
+)
+
+var stage = flag.String("stage", "finishing", "ir2 stage to write the IR or interpret the program after, or \"each\" to find the first that changes what it does")
+
+// interpStages are how the interpreter keeps the values of the funcs
+// after each stage
+var interpStages = []interp.Stage{
+	stageInitial:        interp.Parsed,
+	stageInlining:       interp.Parsed,
+	stageElaboration:    interp.Elaborated,
+	stageSimplification: interp.Elaborated,
+	stageLowering:       interp.Elaborated,
+	stageLegalization:   interp.Elaborated,
+	stageRegAlloc:       interp.Allocated,
+	stageCleanUp:        interp.Allocated,
+	stageFinishing:      interp.Finished,
+}
+
+// interpret compiles the packages with the ir2 pipeline up to the stage
+// given by the -stage flag, and runs them with the ir2 interpreter, with
+// their output going to out, and returns their exit code. With "each",
+// the program is run after every stage, and the stages that change its
+// output or exit code are reported.
+func interpret(out io.Writer, dir string, patterns []string) int {
+	last := stageIndex(*stage)
+	if last < 0 && *stage != "each" {
+		log.Fatalf("unknown stage %q, expected one of: %s, each", *stage, strings.Join(stageNames, ", "))
+	}
+
+	prog, src := newSource(dir, patterns)
+	if prog == nil {
+		diag.Print(os.Stderr)
+		return 1
+	}
+
+	// the interpreter calls the runtime for what elaboration would
+	if last < stageElaboration {
+		referenceRuntime(prog)
+	}
+
+	c := newFuncCompiler(src)
+	defer c.close()
+
+	if last >= 0 {
+		c.compile(last)
+		diag.Print(os.Stderr)
+		if diag.NumErrors() > 0 {
+			return 1
+		}
+		code, err := interpretAt(prog, last, out)
+		if err != nil {
+			log.Println(err)
+		}
+		return code
+	}
+
+	return interpretEach(c, prog, out)
+}
+
+// interpretEach runs the program after each stage, comparing what it
+// does to what it did after the stage before. What it printed after the
+// first stage is written to out.
+func interpretEach(c *funcCompiler, prog *ir2.Program, out io.Writer) int {
+	var prevOut []byte
+	prevCode := -1
+	prevStage := ""
+	changed := false
+
+	for s, name := range stageNames {
+		c.compile(s)
+		diag.Print(os.Stderr)
+		if diag.NumErrors() > 0 {
+			return 1
+		}
+
+		buf := &bytes.Buffer{}
+		code, err := interpretAt(prog, s, buf)
+		if err != nil && !errors.Is(err, interp.ErrAbort) {
+			// the program can't be compared with what it did before
+			log.Printf("after the %s stage: %s", name, err)
+			changed = true
+			continue
+		}
+
+		if prevStage == "" {
+			if _, err := out.Write(buf.Bytes()); err != nil {
+				log.Fatal(err)
+			}
+		} else if code != prevCode || !bytes.Equal(buf.Bytes(), prevOut) {
+			log.Printf("the %s stage changed what the program does from after the %s stage:", name, prevStage)
+			log.Printf("  exit code %d, was %d", code, prevCode)
+			if line, prevLine := firstDiff(buf.Bytes(), prevOut); line != prevLine {
+				log.Printf("  printed %q, was %q", line, prevLine)
+			}
+			changed = true
+		}
+
+		prevOut = buf.Bytes()
+		prevCode = code
+		prevStage = name
+	}
+
+	if changed {
+		return 1
+	}
+	return prevCode
+}
+
+// interpretAt runs the program as it is after the stage, and returns
+// its exit code, and the error it aborted with, if any
+func interpretAt(prog *ir2.Program, s int, out io.Writer) (int, error) {
+	in := interp.New(prog, interpStages[s])
+	in.Out = out
+	if *trace {
+		in.Trace = os.Stderr
+	}
+
+	code, err := in.Run()
+	log.Printf("interpreted %d instructions after the %s stage", in.Steps(), stageNames[s])
+	if err != nil && code == 0 {
+		code = 1
+	}
+	return code, err
+}
+
+// firstDiff returns the first line that differs between the outputs
+func firstDiff(a, b []byte) (string, string) {
+	al := strings.SplitAfter(string(a), "\n")
+	bl := strings.SplitAfter(string(b), "\n")
+	for i := 0; i < len(al) || i < len(bl); i++ {
+		var x, y string
+		if i < len(al) {
+			x = al[i]
+		}
+		if i < len(bl) {
+			y = bl[i]
+		}
+		if x != y {
+			return x, y
+		}
+	}
+	return "", ""
+}
+
+// referenceRuntime marks the runtime funcs the interpreter calls before
+// elaboration as referenced, so they're parsed along with the program
+func referenceRuntime(prog *ir2.Program) {
+	runtime := prog.Package("runtime")
+	if runtime == nil {
+		return
+	}
+	for _, name := range interp.RuntimeFuncs {
+		if fn := runtime.Func(name); fn != nil {
+			fn.Referenced = true
+		}
+	}
+}
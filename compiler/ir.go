@@ -0,0 +1,132 @@
+package compiler
+
+import (
+	"go/scanner"
+	"go/token"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/rj45/nanogo/diag"
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/parseir"
+)
+
+// writeIR compiles the packages with the ir2 pipeline up to the stage
+// given by the -stage flag, and writes the IR of the program to out,
+// which can be parsed back in to compile it from there. Funcs in a .ngir
+// file that are past the stage are written as they are.
+func writeIR(out io.Writer, dir string, patterns []string) int {
+	last := stageIndex(*stage)
+	if last < 0 {
+		log.Fatalf("unknown stage %q, expected one of: %s", *stage, strings.Join(stageNames, ", "))
+	}
+
+	prog, src := newSource(dir, patterns)
+	if prog == nil {
+		diag.Print(os.Stderr)
+		return 1
+	}
+
+	// the later stages add calls to runtime funcs, so they have to be
+	// written out along with the program to compile it from there
+	if last < stageFinishing {
+		if runtime := prog.Package("runtime"); runtime != nil {
+			for _, fn := range runtime.Funcs() {
+				fn.Referenced = true
+			}
+		}
+	}
+
+	c := newFuncCompiler(src)
+	defer c.close()
+	c.compile(last)
+
+	diag.Print(os.Stderr)
+	if diag.NumErrors() > 0 {
+		return 1
+	}
+
+	if last == stageFinishing {
+		prune(prog)
+	}
+
+	prog.Emit(out, ir2.SSAString{})
+	return 0
+}
+
+// stageIndex returns the index of the stage with the name, or -1 if
+// there's none
+func stageIndex(name string) int {
+	for i, n := range stageNames {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseIR parses the program in a .ngir file. Nil is returned once the
+// errors have been reported with the diag package.
+func parseIR(filename string) *ir2.Program {
+	f, err := os.Open(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	prog := &ir2.Program{}
+	p, err := parseir.NewParser(filename, f, prog, *trace)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = p.Parse()
+	if err != nil {
+		if list, ok := err.(scanner.ErrorList); ok {
+			for _, e := range list {
+				diag.ErrorAt(e.Pos, "%s", e.Msg)
+			}
+		} else {
+			diag.ErrorAt(token.Position{Filename: filename}, "%s", err)
+		}
+		return nil
+	}
+
+	return prog
+}
+
+// irSource is the source of the funcs of a program parsed from a .ngir
+// file, which are parsed already, and carry on from the stage they
+// were written after
+type irSource struct {
+	prog   *ir2.Program
+	parsed map[*ir2.Func]bool
+}
+
+func newIRSource(prog *ir2.Program) *irSource {
+	return &irSource{
+		prog:   prog,
+		parsed: make(map[*ir2.Func]bool),
+	}
+}
+
+func (src *irSource) NextUnparsedFunc() *ir2.Func {
+	for _, pkg := range src.prog.Packages() {
+		for _, fn := range pkg.Funcs() {
+			if fn.Referenced && !src.parsed[fn] {
+				return fn
+			}
+		}
+	}
+	return nil
+}
+
+func (src *irSource) ParseFunc(fn *ir2.Func) {
+	src.parsed[fn] = true
+}
+
+func (src *irSource) DumpOrignalSource(fn *ir2.Func) (filename string, lines []string, startline int) {
+	return
+}
@@ -0,0 +1,443 @@
+package compiler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/token"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rj45/nanogo/asm2"
+	"github.com/rj45/nanogo/assembler"
+	"github.com/rj45/nanogo/diag"
+	"github.com/rj45/nanogo/frontend"
+	"github.com/rj45/nanogo/goenv"
+	"github.com/rj45/nanogo/ir2"
+)
+
+// Separate compilation compiles each package into an object, and links
+// the objects into the image. The objects are kept in a build cache,
+// keyed on a hash of everything that goes into them, so only the
+// packages that changed since the last build are compiled again.
+
+var cachedir = flag.String("cachedir", "", "build cache folder for separate compilation (default is objects in GOCACHE)")
+
+// numberingFile keeps the type codes and method IDs given out, which
+// the objects in the cache depend on
+const numberingFile = "numbering.json"
+
+// cacheDir returns the build cache folder, creating it if needed
+func cacheDir() string {
+	dir := *cachedir
+	if dir == "" {
+		dir = filepath.Join(goenv.Get("GOCACHE"), "objects")
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		log.Fatalln("failed to create the build cache:", err)
+	}
+	return dir
+}
+
+// compilePackages compiles each package of the program into an object,
+// or reads it from the build cache if it hasn't changed. The objects
+// are returned in the order of the program's packages, or nil if there
+// were errors, which are reported with the diag package.
+func compilePackages(dir string, patterns []string) []*assembler.Object {
+	fe, err := frontend.NewFrontEnd(dir, patterns...)
+	if errors.Is(err, frontend.ErrParsing) {
+		// the errors have already been reported
+		return nil
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	prog := fe.Program()
+	diag.SetFileSet(prog.FileSet)
+
+	cache := cacheDir()
+	numbering := loadNumbering(cache)
+	prog.Separate = true
+	prog.UseNumbering(numbering)
+
+	fe.Scan()
+
+	keys := packageKeys(fe)
+
+	objs := make(map[*ir2.Package]*assembler.Object)
+	var compile []*ir2.Package
+	for _, pkg := range prog.Packages() {
+		if obj := readObject(filepath.Join(cache, keys[pkg]+".o")); obj != nil {
+			objs[pkg] = obj
+			continue
+		}
+		compile = append(compile, pkg)
+	}
+
+	if len(compile) > 0 {
+		fe.CompileOnly(compile)
+		compileFuncs(fe)
+		if diag.NumErrors() > 0 {
+			return nil
+		}
+
+		// the objects compiled now depend on the numbers given out
+		saveNumbering(cache, numbering)
+
+		layout := layoutSources()
+		for _, pkg := range compile {
+			buf := &bytes.Buffer{}
+			shared := asm2.EmitPackage(buf, asm2.CustomASM{}, pkg)
+
+			// the assembly is kept for errors and debug info to refer to
+			asmfile := filepath.Join(cache, keys[pkg]+".asm")
+			if err := os.WriteFile(asmfile, buf.Bytes(), 0666); err != nil {
+				log.Fatalln("failed to write to the build cache:", err)
+			}
+
+			obj, err := assembler.AssembleObject(arch, layout, assembler.Source{Name: asmfile, Text: buf.String()})
+			if err != nil {
+				reportAsmErrors(err, func(filename string) string { return filename })
+				continue
+			}
+			obj.Arch = arch.Name()
+			obj.Package = pkg.Path
+			markDups(obj, shared)
+
+			writeObject(filepath.Join(cache, keys[pkg]+".o"), obj)
+			objs[pkg] = obj
+		}
+		if diag.NumErrors() > 0 {
+			return nil
+		}
+	}
+
+	var list []*assembler.Object
+	for _, pkg := range prog.Packages() {
+		list = append(list, objs[pkg])
+	}
+	return list
+}
+
+// markDups marks the sections of the shared funcs and globals, which
+// other objects may have copies of
+func markDups(obj *assembler.Object, shared []string) {
+	dups := make(map[string]bool)
+	for _, label := range shared {
+		dups[label] = true
+	}
+	for _, sec := range obj.Sections {
+		sec.Dup = dups[sec.Name]
+	}
+}
+
+// link links the objects after the arch's cpudef.asm and rungo.asm,
+// and returns the image in the arch's AssemblerFormat, or nil if there
+// were errors, which are reported with the diag package
+func link(objs []*assembler.Object) io.Reader {
+	layout := layoutSources()
+
+	// the end of the bss goes after the bss of all the objects
+	buf := &bytes.Buffer{}
+	asm2.EmitBssEnd(buf, asm2.CustomASM{})
+	end, err := assembler.AssembleObject(arch, layout, assembler.Source{Name: asm2.BssEnd, Text: buf.String()})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, obj := range objs {
+		if obj.Arch != arch.Name() {
+			diag.ErrorAt(token.Position{}, "object for %s is for %s, not %s", obj.Package, obj.Arch, arch.Name())
+		}
+	}
+	if diag.NumErrors() > 0 {
+		return nil
+	}
+
+	img, err := assembler.Link(arch, layout, append(objs, end)...)
+	if err != nil {
+		reportAsmErrors(err, func(filename string) string { return filename })
+		return nil
+	}
+
+	return imageReader(img)
+}
+
+// compileObjects compiles each package into an object, and writes them
+// to the outdir named after the package path
+func compileObjects(outdir, dir string, patterns []string) int {
+	diag.Reset()
+
+	if outdir == "-" {
+		outdir = "."
+	}
+	if err := os.MkdirAll(outdir, 0777); err != nil {
+		log.Fatal(err)
+	}
+
+	objs := compilePackages(dir, patterns)
+	diag.Print(os.Stderr)
+	if diag.NumErrors() > 0 {
+		return 1
+	}
+
+	for _, obj := range objs {
+		name := strings.ReplaceAll(obj.Package, "/", "_") + ".o"
+		writeObject(filepath.Join(outdir, name), obj)
+	}
+	return 0
+}
+
+// Link links object files written by compiling the packages separately
+// into an image, and either writes it to the outname or runs it
+func Link(outname string, objects []string, mode Mode) int {
+	log.SetFlags(log.Lshortfile)
+	diag.Reset()
+
+	var objs []*assembler.Object
+	for _, filename := range objects {
+		f, err := os.Open(filename)
+		if err != nil {
+			log.Fatal(err)
+		}
+		obj, err := assembler.ReadObject(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("failed to read object %s: %s", filename, err)
+		}
+		objs = append(objs, obj)
+	}
+
+	image := link(objs)
+	diag.Print(os.Stderr)
+	if diag.NumErrors() > 0 {
+		return 1
+	}
+
+	var out io.Writer = os.Stdout
+	if outname != "-" {
+		f, err := os.Create(outname)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	return output(image, out, mode)
+}
+
+// packageKeys returns the key each package's object is cached under. It
+// hashes everything the object depends on: the compiler, the arch, the
+// package's files and the keys of the packages it imports.
+func packageKeys(fe *frontend.FrontEnd) map[*ir2.Package]string {
+	prog := fe.Program()
+	keys := make(map[*ir2.Package]string)
+	visiting := make(map[*ir2.Package]bool)
+
+	// the names of the packages depend on the other packages with the
+	// same name in the program
+	byName := make(map[string][]string)
+	for _, pkg := range prog.Packages() {
+		byName[pkg.Name] = append(byName[pkg.Name], pkg.Path)
+	}
+
+	var layout []string
+	for _, src := range layoutSources() {
+		layout = append(layout, src.Text)
+	}
+
+	runtime := prog.Package("runtime")
+
+	var keyFor func(pkg *ir2.Package) string
+	keyFor = func(pkg *ir2.Package) string {
+		if key, found := keys[pkg]; found {
+			return key
+		}
+		if visiting[pkg] {
+			// the runtime can import packages, which all depend on it
+			return ""
+		}
+		visiting[pkg] = true
+
+		h := sha256.New()
+		fmt.Fprintf(h, "compiler %s\narch %s\n", compilerHash(), arch.Name())
+		for _, text := range layout {
+			fmt.Fprintf(h, "layout %d\n%s\n", len(text), text)
+		}
+
+		fmt.Fprintf(h, "package %s\n", pkg.Path)
+		same := append([]string(nil), byName[pkg.Name]...)
+		sort.Strings(same)
+		fmt.Fprintf(h, "same name %s\n", strings.Join(same, " "))
+
+		hashDir(h, fe.PackageDir(pkg))
+
+		var deps []*ir2.Package
+		if runtime != nil && pkg != runtime {
+			deps = append(deps, runtime)
+		}
+		if pkg.Type != nil {
+			for _, imp := range pkg.Type.Imports() {
+				if dep := prog.Package(imp.Path()); dep != nil {
+					deps = append(deps, dep)
+				}
+			}
+		}
+		sort.Slice(deps, func(i, j int) bool {
+			return deps[i].Path < deps[j].Path
+		})
+		for _, dep := range deps {
+			fmt.Fprintf(h, "import %s %s\n", dep.Path, keyFor(dep))
+		}
+
+		key := hex.EncodeToString(h.Sum(nil))
+		keys[pkg] = key
+		return key
+	}
+
+	// the runtime goes first, so its key doesn't depend on the order
+	// the packages it imports are visited in
+	if runtime != nil {
+		keyFor(runtime)
+	}
+	for _, pkg := range prog.Packages() {
+		keyFor(pkg)
+	}
+
+	return keys
+}
+
+// hashDir hashes the names and contents of the files in the folder,
+// which has the package's source and assembly files
+func hashDir(h hash.Hash, dir string) {
+	if dir == "" {
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		buf, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(h, "file %s %d\n", entry.Name(), len(buf))
+		h.Write(buf)
+	}
+}
+
+var compilerSum string
+
+// compilerHash returns a hash of the compiler's executable, since a
+// different compiler can produce different objects
+func compilerHash() string {
+	if compilerSum != "" {
+		return compilerSum
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	buf, err := os.ReadFile(exe)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sum := sha256.Sum256(buf)
+	compilerSum = hex.EncodeToString(sum[:])
+	return compilerSum
+}
+
+// readObject reads an object from the cache, or returns nil if it's
+// not there
+func readObject(filename string) *assembler.Object {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	obj, err := assembler.ReadObject(f)
+	if err != nil {
+		log.Printf("ignoring bad object %s: %s", filename, err)
+		return nil
+	}
+	return obj
+}
+
+// writeObject writes the object to a file
+func writeObject(filename string, obj *assembler.Object) {
+	writeFile(filename, obj.Write)
+}
+
+// writeFile writes a file through a temp file, so that a build that is
+// cut short doesn't leave half of it behind
+func writeFile(filename string, write func(w io.Writer) error) {
+	f, err := os.CreateTemp(filepath.Dir(filename), "nanogo_*.tmp")
+	if err != nil {
+		log.Fatalln("failed to write to the build cache:", err)
+	}
+	defer os.Remove(f.Name())
+
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		log.Fatalf("failed to write %s: %s", filename, err)
+	}
+}
+
+// loadNumbering reads the type codes and method IDs given out to the
+// objects in the cache
+func loadNumbering(cache string) *ir2.Numbering {
+	numbering := &ir2.Numbering{}
+
+	buf, err := os.ReadFile(filepath.Join(cache, numberingFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return numbering
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := json.Unmarshal(buf, numbering); err != nil {
+		log.Fatalf("bad %s in the build cache, it can be deleted along with the objects: %s", numberingFile, err)
+	}
+	return numbering
+}
+
+// saveNumbering writes the numbers given out so far, which only ever
+// grow so the objects compiled before stay valid
+func saveNumbering(cache string, numbering *ir2.Numbering) {
+	buf, err := json.MarshalIndent(numbering, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	writeFile(filepath.Join(cache, numberingFile), func(w io.Writer) error {
+		_, err := w.Write(buf)
+		return err
+	})
+}
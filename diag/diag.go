@@ -0,0 +1,223 @@
+// Copyright (c) 2021 rj45 (github.com/rj45), MIT Licensed, see LICENSE.
+
+// Package diag collects the errors and warnings found while compiling
+// a program, so that as many as possible can be reported at once, each
+// with the line of source it refers to.
+package diag
+
+import (
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Severity int
+
+const (
+	Warning Severity = iota
+	Error
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// Diagnostic is a single error or warning
+type Diagnostic struct {
+	Severity Severity
+	Pos      token.Position
+	Msg      string
+}
+
+func (d Diagnostic) String() string {
+	if d.Pos.Filename == "" && !d.Pos.IsValid() {
+		return fmt.Sprintf("%s: %s", d.Severity, d.Msg)
+	}
+	return fmt.Sprintf("%s: %s: %s", d.Pos, d.Severity, d.Msg)
+}
+
+var (
+	mu    sync.Mutex
+	fset  *token.FileSet
+	diags []Diagnostic
+)
+
+// SetFileSet sets the FileSet used to look up positions
+func SetFileSet(f *token.FileSet) {
+	mu.Lock()
+	defer mu.Unlock()
+	fset = f
+}
+
+// Reset forgets all the diagnostics reported so far
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	fset = nil
+	diags = nil
+}
+
+// Errorf reports an error at a position in the FileSet
+func Errorf(pos token.Pos, format string, args ...interface{}) {
+	add(Error, position(pos), format, args)
+}
+
+// Warnf reports a warning at a position in the FileSet
+func Warnf(pos token.Pos, format string, args ...interface{}) {
+	add(Warning, position(pos), format, args)
+}
+
+// ErrorAt reports an error at an already resolved position, for
+// positions that come from somewhere other than the FileSet
+func ErrorAt(pos token.Position, format string, args ...interface{}) {
+	add(Error, pos, format, args)
+}
+
+func position(pos token.Pos) token.Position {
+	mu.Lock()
+	defer mu.Unlock()
+	if fset == nil || !pos.IsValid() {
+		return token.Position{}
+	}
+	return fset.Position(pos)
+}
+
+func add(sev Severity, pos token.Position, format string, args []interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	d := Diagnostic{
+		Severity: sev,
+		Pos:      pos,
+		Msg:      fmt.Sprintf(format, args...),
+	}
+
+	// xforms may see the same instr more than once
+	for _, other := range diags {
+		if other == d {
+			return
+		}
+	}
+
+	diags = append(diags, d)
+}
+
+// NumErrors returns the number of errors reported so far
+func NumErrors() int {
+	mu.Lock()
+	defer mu.Unlock()
+	n := 0
+	for _, d := range diags {
+		if d.Severity == Error {
+			n++
+		}
+	}
+	return n
+}
+
+// Diagnostics returns everything reported so far, sorted by position
+func Diagnostics() []Diagnostic {
+	mu.Lock()
+	defer mu.Unlock()
+	list := append([]Diagnostic(nil), diags...)
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i].Pos, list[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return list
+}
+
+// Print writes out each diagnostic with the line of source it refers
+// to and a caret under the column, followed by a summary
+func Print(w io.Writer) {
+	list := Diagnostics()
+	if len(list) == 0 {
+		return
+	}
+
+	files := make(map[string][]string)
+	errors, warnings := 0, 0
+	for _, d := range list {
+		if d.Severity == Error {
+			errors++
+		} else {
+			warnings++
+		}
+
+		fmt.Fprintln(w, d)
+
+		if !d.Pos.IsValid() {
+			continue
+		}
+		lines, ok := files[d.Pos.Filename]
+		if !ok {
+			buf, err := os.ReadFile(d.Pos.Filename)
+			if err == nil {
+				lines = strings.Split(string(buf), "\n")
+			}
+			files[d.Pos.Filename] = lines
+		}
+		if d.Pos.Line > len(lines) {
+			continue
+		}
+		fmt.Fprint(w, context(lines[d.Pos.Line-1], d.Pos))
+	}
+
+	fmt.Fprintln(w, summary(errors, warnings))
+}
+
+// context returns the line of source with a caret under the column
+func context(line string, pos token.Position) string {
+	num := fmt.Sprintf("%d", pos.Line)
+	gutter := strings.Repeat(" ", len(num))
+
+	// keep the tabs so the caret lines up with the source
+	var pad strings.Builder
+	for i, r := range line {
+		if i >= pos.Column-1 {
+			break
+		}
+		if r == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteRune(' ')
+		}
+	}
+
+	caret := ""
+	if pos.Column > 0 {
+		caret = pad.String() + "^"
+	}
+
+	return fmt.Sprintf(" %s | %s\n %s | %s\n", num, line, gutter, caret)
+}
+
+func summary(errors, warnings int) string {
+	plural := func(n int, what string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s", n, what)
+		}
+		return fmt.Sprintf("%d %ss", n, what)
+	}
+
+	switch {
+	case warnings == 0:
+		return plural(errors, "error")
+	case errors == 0:
+		return plural(warnings, "warning")
+	}
+	return plural(errors, "error") + " and " + plural(warnings, "warning")
+}
@@ -0,0 +1,65 @@
+package diag_test
+
+import (
+	"bytes"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rj45/nanogo/diag"
+)
+
+func TestPrint_SourceLineAndCaret(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "main.go")
+	src := "package main\n\nfunc main() {\n\tx := a + b\n}\n"
+	if err := os.WriteFile(filename, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fset := token.NewFileSet()
+	file := fset.AddFile(filename, -1, len(src))
+	file.SetLinesForContent([]byte(src))
+
+	diag.Reset()
+	diag.SetFileSet(fset)
+	diag.Warnf(file.Pos(38), "b is unused")
+	diag.Errorf(file.Pos(34), "a is undefined")
+	diag.Errorf(file.Pos(34), "a is undefined")
+
+	if got := diag.NumErrors(); got != 1 {
+		t.Errorf("expected duplicate errors to be reported once, but got %d errors", got)
+	}
+
+	buf := &bytes.Buffer{}
+	diag.Print(buf)
+
+	want := filename + ":4:7: error: a is undefined\n" +
+		" 4 | \tx := a + b\n" +
+		"   | \t     ^\n" +
+		filename + ":4:11: warning: b is unused\n" +
+		" 4 | \tx := a + b\n" +
+		"   | \t         ^\n" +
+		"1 error and 1 warning\n"
+
+	if buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestPrint_NoPosition(t *testing.T) {
+	diag.Reset()
+	diag.ErrorAt(token.Position{}, "something went wrong")
+	diag.ErrorAt(token.Position{Filename: "missing.go", Line: 3, Column: 1}, "can't read the source")
+
+	buf := &bytes.Buffer{}
+	diag.Print(buf)
+
+	want := "error: something went wrong\n" +
+		"missing.go:3:1: error: can't read the source\n" +
+		"2 errors\n"
+
+	if buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
@@ -40,7 +40,7 @@ func (fe *FrontEnd) translateBlockArgs(irBlock *ir2.Block, ssaBlock *ssa.BasicBl
 			arg := fe.val2val[ssaVal]
 
 			if con, ok := ssaVal.(*ssa.Const); ok {
-				arg = irBlock.Func().ValueFor(phi.Type(), con.Value)
+				arg = irBlock.Func().ValueFor(phi.Type(), constValue(irBlock.Func(), con, getPos(phi)))
 			}
 
 			if arg == nil {
@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
+	"go/ast"
 	"go/token"
 	"go/types"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/rj45/nanogo/ir2"
@@ -19,18 +21,28 @@ type Arch interface {
 	Name() string
 }
 
-// var arch Arch
+var arch Arch
 
 func SetArch(a Arch) {
-	// arch = a
+	arch = a
 }
 
 type FrontEnd struct {
 	prog     *ir2.Program
 	members  []ssa.Member
+	decls    map[token.Pos]*ast.FuncDecl
 	ssaFuncs map[*ir2.Func]*ssa.Function
+	irFuncs  map[*ssa.Function]*ir2.Func
 	parsed   map[*ir2.Func]bool
 
+	// only has the packages being compiled, when they are compiled
+	// separately
+	only map[*ir2.Package]bool
+
+	// method and type tables for interfaces, made as they're needed
+	typeTables      typeutil.Map
+	interfaceTables typeutil.Map
+
 	val2instr map[ssa.Value]*ir2.Instr
 	val2val   map[ssa.Value]*ir2.Value
 	blockmap  map[*ssa.BasicBlock]*ir2.Block
@@ -50,7 +62,7 @@ type critBlock struct {
 }
 
 func NewFrontEnd(dir string, patterns ...string) (*FrontEnd, error) {
-	members, err := parseProgram(dir, patterns...)
+	members, decls, err := parseProgram(dir, patterns...)
 	if err != nil {
 		return nil, err
 	}
@@ -63,6 +75,7 @@ func NewFrontEnd(dir string, patterns ...string) (*FrontEnd, error) {
 	return &FrontEnd{
 		prog:    &ir2.Program{FileSet: fset},
 		members: members,
+		decls:   decls,
 	}, nil
 }
 
@@ -76,6 +89,7 @@ func (fe *FrontEnd) Scan() {
 	}
 
 	fe.ssaFuncs = make(map[*ir2.Func]*ssa.Function)
+	fe.irFuncs = make(map[*ssa.Function]*ir2.Func)
 	for _, member := range fe.members {
 		switch member.Token() {
 		case token.FUNC:
@@ -85,16 +99,18 @@ func (fe *FrontEnd) Scan() {
 			main := fn.Pkg.Pkg.Name() == "main"
 			referenced := main && (name == "main" || name == "init")
 
-			pkg := fe.getPackage(fn.Pkg.Pkg)
-
-			irFunc := pkg.NewFunc(fn.Name(), fn.Signature)
+			irFunc := fe.funcFor(fn, nil)
 			irFunc.Referenced = referenced
 
-			fe.ssaFuncs[irFunc] = fn
-
 		case token.VAR:
 			pkg := fe.getPackage(member.Package().Pkg)
-			pkg.NewGlobal(member.Name(), member.Type())
+			glob := pkg.NewGlobal(member.Name(), member.Type())
+
+			if pkg.Name == "runtime" && glob.Name == "nilInterface" {
+				// the header of nil interfaces is never written, so it
+				// goes with the constant tables instead of in the bss
+				glob.Words = []ir2.Const{ir2.ConstFor(0), ir2.ConstFor(0)}
+			}
 
 		case token.TYPE:
 			pkg := fe.getPackage(member.Package().Pkg)
@@ -108,6 +124,69 @@ func (fe *FrontEnd) Scan() {
 	fe.parsed = make(map[*ir2.Func]bool)
 }
 
+// funcFor returns the Func for an ssa function, adding it the first
+// time it's seen. Func literals, methods and the wrappers ssa makes for
+// them are not members of a package, so they are added as they are
+// referenced. Synthetic funcs with no package go in the package of their
+// receiver, or else the package of the caller, from.
+func (fe *FrontEnd) funcFor(fn *ssa.Function, from *ir2.Func) *ir2.Func {
+	if irFunc, ok := fe.irFuncs[fn]; ok {
+		return irFunc
+	}
+
+	var typPkg *types.Package
+	if fn.Pkg != nil {
+		typPkg = fn.Pkg.Pkg
+	} else if recv := fn.Signature.Recv(); recv != nil {
+		typPkg = recvPackage(recv.Type())
+	} else if obj := fn.Object(); obj != nil {
+		typPkg = obj.Pkg()
+	}
+
+	var pkg *ir2.Package
+	if typPkg != nil {
+		pkg = fe.getPackage(typPkg)
+	} else if from != nil {
+		pkg = from.Package()
+	} else {
+		log.Fatalf("could not find the package for %s", fn)
+	}
+
+	irFunc := pkg.NewFunc(fn.RelString(pkg.Type), funcSig(fn.Signature))
+	// wrappers and thunks are made for the code that uses them
+	irFunc.Shared = fn.Pkg == nil
+	fe.ssaFuncs[irFunc] = fn
+	fe.irFuncs[fn] = irFunc
+	return irFunc
+}
+
+// recvPackage returns the package that declares a receiver's type
+func recvPackage(typ types.Type) *types.Package {
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	if named, ok := typ.(*types.Named); ok {
+		return named.Obj().Pkg()
+	}
+	return nil
+}
+
+// funcSig returns the signature of a func with the receiver, if there
+// is one, as the first param, since that's how it's passed
+func funcSig(sig *types.Signature) *types.Signature {
+	recv := sig.Recv()
+	if recv == nil {
+		return sig
+	}
+
+	params := []*types.Var{recv}
+	for i := 0; i < sig.Params().Len(); i++ {
+		params = append(params, sig.Params().At(i))
+	}
+
+	return types.NewSignatureType(nil, nil, nil, types.NewTuple(params...), sig.Results(), sig.Variadic())
+}
+
 func (fe *FrontEnd) getPackage(typPkg *types.Package) *ir2.Package {
 	pkg := fe.prog.Package(typPkg.Path())
 	if pkg == nil {
@@ -137,10 +216,62 @@ func (fe *FrontEnd) Program() *ir2.Program {
 	return fe.prog
 }
 
+// CompileOnly limits the funcs parsed to the ones in the packages, and
+// the shared funcs they use, to compile the packages separately. All of
+// the funcs in the packages are parsed, including the methods of their
+// types, rather than only the ones reachable from main.
+func (fe *FrontEnd) CompileOnly(pkgs []*ir2.Package) {
+	fe.only = make(map[*ir2.Package]bool)
+	for _, pkg := range pkgs {
+		fe.only[pkg] = true
+	}
+
+	for _, member := range fe.members {
+		if !fe.only[fe.getPackage(member.Package().Pkg)] {
+			continue
+		}
+
+		switch member := member.(type) {
+		case *ssa.Function:
+			fe.funcFor(member, nil).Referenced = true
+
+		case *ssa.Type:
+			named, ok := member.Type().(*types.Named)
+			if !ok || types.IsInterface(named) || named.TypeParams().Len() > 0 {
+				continue
+			}
+
+			ssaProg := member.Package().Prog
+			for _, typ := range []types.Type{named, types.NewPointer(named)} {
+				mset := ssaProg.MethodSets.MethodSet(typ)
+				for i := 0; i < mset.Len(); i++ {
+					// wrappers are shared, so they're compiled
+					// for the packages that use them
+					if fn := ssaProg.MethodValue(mset.At(i)); fn != nil && fn.Pkg != nil {
+						fe.funcFor(fn, nil).Referenced = true
+					}
+				}
+			}
+		}
+	}
+}
+
+// PackageDir returns the folder with the package's source files, or ""
+// if it has none
+func (fe *FrontEnd) PackageDir(pkg *ir2.Package) string {
+	for _, member := range fe.members {
+		if member.Package().Pkg != pkg.Type || !member.Pos().IsValid() {
+			continue
+		}
+		return filepath.Dir(fe.prog.FileSet.Position(member.Pos()).Filename)
+	}
+	return ""
+}
+
 func (fe *FrontEnd) NextUnparsedFunc() *ir2.Func {
 	for _, pkg := range fe.prog.Packages() {
 		for _, fn := range pkg.Funcs() {
-			if fn.Referenced && !fe.parsed[fn] {
+			if fn.Referenced && !fe.parsed[fn] && (fe.only == nil || fe.only[pkg] || fn.Shared) {
 				return fn
 			}
 		}
@@ -1,6 +1,15 @@
 package frontend
 
 import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
 	"github.com/rj45/nanogo/ir/reg"
 	"github.com/rj45/nanogo/ir2"
 	"github.com/rj45/nanogo/ir2/op"
@@ -10,10 +19,14 @@ import (
 func (fe *FrontEnd) translateFunc(irFunc *ir2.Func, ssaFunc *ssa.Function) {
 	if ssaFunc.Blocks == nil {
 		// extern function
-		// handleExternFunc(irFunc, ssaFunc)
+		handleExternFunc(irFunc, ssaFunc)
 		return
 	}
 
+	irFunc.NoBounds = fe.hasPragma(ssaFunc, "//go:nobounds")
+	irFunc.NoInline = fe.hasPragma(ssaFunc, "//go:noinline")
+	irFunc.Inline = fe.hasPragma(ssaFunc, "//go:inline")
+
 	// order blocks by reverse succession
 	blockList := reverseSSASuccessorSort(ssaFunc.Blocks[0], nil, make(map[*ssa.BasicBlock]bool))
 
@@ -22,6 +35,12 @@ func (fe *FrontEnd) translateFunc(irFunc *ir2.Func, ssaFunc *ssa.Function) {
 		blockList[i], blockList[j] = blockList[j], blockList[i]
 	}
 
+	if ssaFunc.Recover != nil {
+		// nothing jumps to the recover block until the deferred calls
+		// are set up, so it's not found by the sort
+		blockList = append(blockList, ssaFunc.Recover)
+	}
+
 	for bn, ssaBlock := range blockList {
 		irBlock := irFunc.NewBlock()
 
@@ -50,6 +69,10 @@ func (fe *FrontEnd) translateFunc(irFunc *ir2.Func, ssaFunc *ssa.Function) {
 			}
 		}
 
+		if bn == 0 && len(ssaFunc.FreeVars) > 0 {
+			fe.translateFreeVars(irBlock, ssaFunc)
+		}
+
 		fe.blockmap[ssaBlock] = irBlock
 
 		prevCritBlockNum := len(fe.critBlocks) - 1
@@ -99,6 +122,10 @@ func (fe *FrontEnd) translateFunc(irFunc *ir2.Func, ssaFunc *ssa.Function) {
 
 	fe.resolvePlaceholders(irFunc)
 
+	if ssaFunc.Recover != nil {
+		irFunc.Recover = fe.blockmap[ssaFunc.Recover]
+	}
+
 	for _, block := range blockList {
 		irBlock := fe.blockmap[block]
 
@@ -137,6 +164,109 @@ func (fe *FrontEnd) translateFunc(irFunc *ir2.Func, ssaFunc *ssa.Function) {
 	}
 }
 
+// translateFreeVars gets the free vars of a func literal out of its
+// closure object, which the caller passes in the context register
+func (fe *FrontEnd) translateFreeVars(irBlock *ir2.Block, ssaFunc *ssa.Function) {
+	fn := irBlock.Func()
+
+	typs := make([]types.Type, len(ssaFunc.FreeVars))
+	for i, fv := range ssaFunc.FreeVars {
+		typs[i] = fv.Type()
+	}
+
+	ctx := fn.NewValue(types.NewPointer(ir2.ClosureType(typs)))
+	irBlock.AddDef(ctx)
+	ctx.SetReg(reg.Context)
+
+	// the context register is a temp reg, so copy it out of there
+	cp := fn.NewInstr(op.Copy, ctx.Type, ctx)
+	irBlock.InsertInstr(-1, cp)
+	cp.Pos = ssaFunc.Pos()
+
+	for i, fv := range ssaFunc.FreeVars {
+		instr := fn.NewInstr(op.FreeVar, fv.Type(), fn.ValueFor(types.Typ[types.Int], i), cp.Def(0))
+		irBlock.InsertInstr(-1, instr)
+		instr.Pos = fv.Pos()
+
+		fe.val2instr[fv] = instr
+		fe.val2val[fv] = instr.Def(0)
+	}
+}
+
+// handleExternFunc finds the assembly implementing a function declared
+// without a body in the assembly files in the same folder, and puts it
+// in the Func as inline assembly
+func handleExternFunc(irFunc *ir2.Func, ssaFunc *ssa.Function) {
+	filename := ssaFunc.Prog.Fset.File(ssaFunc.Pos()).Name()
+	folder, err := filepath.EvalSymlinks(filepath.Dir(filename))
+	if err != nil {
+		log.Fatalf("could not follow symlinks for folder %s", folder)
+	}
+
+	asm := ""
+	filepath.WalkDir(folder, func(path string, d fs.DirEntry, err error) error {
+		ext := filepath.Ext(d.Name())
+		if ext != ".asm" && ext != ".s" && ext != ".S" {
+			return nil
+		}
+
+		noext := strings.TrimSuffix(d.Name(), ext)
+		parts := strings.Split(noext, "_")
+		if len(parts) > 1 && parts[len(parts)-1] != arch.Name() {
+			// skip files with an underscore where the last part
+			// of the name does not match the arch name
+			return nil
+		}
+
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		lines := bytes.Split(buf, []byte("\n"))
+		label := []byte(fmt.Sprintf("%s:", ssaFunc.Name()))
+
+		startLine := -1
+		for i, line := range lines {
+			if bytes.HasPrefix(bytes.TrimSpace(line), label) {
+				startLine = i + 1
+				break
+			}
+		}
+
+		if startLine == -1 {
+			return nil
+		}
+
+		// the func ends at the next label that isn't a local label
+		endLine := len(lines)
+		for i := startLine; i < len(lines); i++ {
+			trimmed := bytes.TrimSpace(lines[i])
+			if !bytes.HasPrefix(trimmed, []byte(".")) && bytes.HasSuffix(trimmed, []byte(":")) {
+				endLine = i
+				break
+			}
+		}
+
+		if asm != "" {
+			log.Fatalf("found duplicate of extern func %s in %s", ssaFunc.Name(), path)
+		}
+
+		asm = strings.TrimRight(string(bytes.Join(lines[startLine:endLine], []byte("\n"))), " \t\r\n")
+		return nil
+	})
+
+	if asm == "" {
+		log.Fatalf("could not find assembly for extern func %s in %s", ssaFunc.Name(), folder)
+	}
+
+	blk := irFunc.NewBlock()
+	irFunc.InsertBlock(-1, blk)
+
+	blk.InsertInstr(-1, irFunc.NewInstr(op.InlineAsm, nil, irFunc.ValueFor(types.Typ[types.String], asm)))
+	blk.InsertInstr(-1, irFunc.NewInstr(op.Return, nil))
+}
+
 func reverseSSASuccessorSort(block *ssa.BasicBlock, list []*ssa.BasicBlock, visited map[*ssa.BasicBlock]bool) []*ssa.BasicBlock {
 	visited[block] = true
 
@@ -149,3 +279,18 @@ func reverseSSASuccessorSort(block *ssa.BasicBlock, list []*ssa.BasicBlock, visi
 
 	return append(list, block)
 }
+
+// hasPragma returns whether the func's doc comment contains the pragma
+func (fe *FrontEnd) hasPragma(ssaFunc *ssa.Function, pragma string) bool {
+	decl, ok := fe.decls[ssaFunc.Pos()]
+	if !ok || decl.Doc == nil {
+		return false
+	}
+
+	for _, comment := range decl.Doc.List {
+		if comment.Text == pragma {
+			return true
+		}
+	}
+	return false
+}
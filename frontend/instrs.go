@@ -1,11 +1,14 @@
 package frontend
 
 import (
+	"fmt"
 	"go/constant"
 	"go/token"
 	"go/types"
 	"log"
+	"strings"
 
+	"github.com/rj45/nanogo/diag"
 	"github.com/rj45/nanogo/ir2"
 	"github.com/rj45/nanogo/ir2/op"
 	"golang.org/x/tools/go/ssa"
@@ -47,6 +50,43 @@ func (fe *FrontEnd) translateInstrs(irBlock *ir2.Block, ssaBlock *ssa.BasicBlock
 			}
 		case *ssa.Slice:
 			opcode = op.Slice
+		case *ssa.MakeSlice:
+			opcode = op.MakeSlice
+		case *ssa.MakeMap:
+			opcode = op.MakeMap
+		case *ssa.MapUpdate:
+			opcode = op.MapUpdate
+		case *ssa.MakeChan:
+			opcode = op.MakeChan
+		case *ssa.Send:
+			opcode = op.Send
+		case *ssa.Select:
+			// the blocking flag, then the direction, channel and value
+			// to send of each state, with a nil value for receives
+			opcode = op.Select
+			arg = irBlock.Func().ValueFor(types.Typ[types.Bool], ins.Blocking)
+		case *ssa.Go:
+			// the goroutine is started with a func value, or a method
+			// of the value in an interface, like calling one
+			opcode = op.Go
+
+			if _, ok := ins.Call.Value.(*ssa.Builtin); ok {
+				diag.Errorf(getPos(ins), "go with a builtin is not supported yet")
+				opcode = op.Invalid
+			} else if ins.Call.IsInvoke() {
+				arg = fe.translateInterfaceOp(irBlock.Func(), ins)
+			}
+		case *ssa.Defer:
+			// the call is deferred with a func value, or a method of
+			// the value in an interface, like a goroutine
+			opcode = op.Defer
+
+			if _, ok := ins.Call.Value.(*ssa.Builtin); ok {
+				diag.Errorf(getPos(ins), "defer with a builtin is not supported yet")
+				opcode = op.Invalid
+			} else if ins.Call.IsInvoke() {
+				arg = fe.translateInterfaceOp(irBlock.Func(), ins)
+			}
 		case *ssa.Call:
 			opcode = op.Call
 			switch call := ins.Call.Value.(type) {
@@ -72,13 +112,38 @@ func (fe *FrontEnd) translateInstrs(irBlock *ir2.Block, ssaBlock *ssa.BasicBlock
 				// con = constant.MakeString(name)
 				// typ = call.Type()
 			default:
-				log.Fatalf("unsupported call type: %#v", ins.Call.Value)
+				// calling a func value, which is passed along so the
+				// callee can find its free variables, or a method of
+				// the value in an interface
+				retType := ins.Call.Signature().Results()
+				typ = retType
+				if retType.Len() == 1 {
+					typ = retType.At(0).Type()
+				}
+				irBlock.Func().NumCalls++
+
+				if ins.Call.IsInvoke() {
+					opcode = op.Invoke
+					arg = fe.translateInterfaceOp(irBlock.Func(), ins)
+				}
 			}
 
 		case *ssa.Convert:
 			opcode = op.Convert
+		case *ssa.ChangeType:
+			opcode = op.ChangeType
+		case *ssa.MakeClosure:
+			opcode = op.MakeClosure
 		case *ssa.MakeInterface:
 			opcode = op.MakeInterface
+			arg = fe.translateInterfaceOp(irBlock.Func(), ins)
+		case *ssa.ChangeInterface:
+			opcode = op.ChangeInterface
+		case *ssa.TypeAssert:
+			opcode = op.TypeAssert
+			arg = fe.translateInterfaceOp(irBlock.Func(), ins)
+		case *ssa.Index:
+			opcode = op.Index
 		case *ssa.IndexAddr:
 			opcode = op.IndexAddr
 		case *ssa.FieldAddr:
@@ -97,12 +162,9 @@ func (fe *FrontEnd) translateInstrs(irBlock *ir2.Block, ssaBlock *ssa.BasicBlock
 			fe.val2val[ins] = mulret.Def(ins.Index)
 
 		case *ssa.Lookup:
+			// comma-ok lookups have a second def for whether the key
+			// was found
 			opcode = op.Lookup
-			if ins.CommaOk {
-				// these should be a separate instruction as they have
-				// different semantics
-				log.Fatal("Comma lookups not yet implented")
-			}
 		case *ssa.BinOp:
 			switch ins.Op {
 			case token.ADD:
@@ -140,7 +202,8 @@ func (fe *FrontEnd) translateInstrs(irBlock *ir2.Block, ssaBlock *ssa.BasicBlock
 			case token.GEQ:
 				opcode = op.GreaterEqual
 			default:
-				log.Fatalf("unsupported binop: %#v", ins)
+				diag.Errorf(getPos(ins), "operator %s is not supported yet", ins.Op)
+				opcode = op.Invalid
 			}
 		case *ssa.UnOp:
 			switch ins.Op {
@@ -152,14 +215,23 @@ func (fe *FrontEnd) translateInstrs(irBlock *ir2.Block, ssaBlock *ssa.BasicBlock
 				opcode = op.Load
 			case token.XOR:
 				opcode = op.Invert
+			case token.ARROW:
+				// comma-ok receives have a second def for whether
+				// the channel was open
+				opcode = op.Recv
 			default:
-				log.Fatalf("unsupported unop: %#v", ins)
+				diag.Errorf(getPos(ins), "operator %s is not supported yet", ins.Op)
+				opcode = op.Invalid
 			}
 
 		case *ssa.RunDefers:
-			// ignore
+			opcode = op.RunDefers
 		default:
-			log.Fatalf("unknown instruction type %#v", instr)
+			// an invalid instr stands in for it so the rest of the
+			// func can still be checked for errors
+			name := strings.TrimPrefix(fmt.Sprintf("%T", instr), "*ssa.")
+			diag.Errorf(getPos(instr), "%s is not supported yet", name)
+			opcode = op.Invalid
 		}
 
 		if opcode == nil {
@@ -186,6 +258,14 @@ func (fe *FrontEnd) translateInstrs(irBlock *ir2.Block, ssaBlock *ssa.BasicBlock
 
 		fe.translateArgs(irBlock, ins, instr)
 
+		if sel, ok := instr.(*ssa.Select); ok {
+			// insert the directions, after the blocking flag
+			for i := len(sel.States) - 1; i >= 0; i-- {
+				dir := irBlock.Func().ValueFor(types.Typ[types.Uintptr], int(sel.States[i].Dir))
+				ins.InsertArg(1+2*i, dir)
+			}
+		}
+
 		if ins == nil {
 			log.Panicf("ins is nil! %s", instr)
 		}
@@ -222,28 +302,22 @@ func (fe *FrontEnd) translateArgs(block *ir2.Block, irInstr *ir2.Instr, ssaInstr
 			ok = true
 			switch con := (*val).(type) {
 			case *ssa.Const:
-				if con.Type().Underlying().String() == "string" {
-					str := constant.StringVal(con.Value)
-					fn := block.Func()
-					glob := fn.Package().NewStringLiteral(fn.Name, str)
-					glob.Referenced = true
-					arg = glob
-				} else {
-					arg = con.Value
-				}
+				arg = constValue(block.Func(), con, getPos(ssaInstr))
 
 			case *ssa.Function:
-				pkg := block.Func().Package()
-				pkg = pkg.Program().Package(con.Pkg.Pkg.Path())
-				otherFunc := pkg.Func(con.Name())
-				if otherFunc == nil {
-					log.Fatalf("reference to unknown function %s in function %s", con.Name(), block.Func().Name)
-				}
+				otherFunc := fe.funcFor(con, block.Func())
 				// ensure it gets loaded
 				otherFunc.Referenced = true
 				arg = otherFunc
 				block.Func().NumCalls++
 
+				if !isCodeAddr(ssaInstr, val) {
+					// a func value points to a closure object
+					glob := otherFunc.Package().NewFuncValue(otherFunc)
+					glob.Referenced = true
+					arg = glob
+				}
+
 			case *ssa.Builtin:
 				arg = con.Name()
 
@@ -266,7 +340,8 @@ func (fe *FrontEnd) translateArgs(block *ir2.Block, irInstr *ir2.Instr, ssaInstr
 			}
 		}
 		if ok && arg != nil {
-			var typ types.Type
+			// the nil const is shared, so make sure it has a type
+			typ := types.Type(types.Typ[types.UntypedNil])
 			if *val != nil {
 				typ = (*val).Type()
 			}
@@ -283,6 +358,69 @@ func (fe *FrontEnd) translateArgs(block *ir2.Block, irInstr *ir2.Instr, ssaInstr
 	}
 }
 
+// constValue returns the value for a constant at pos. Strings are
+// globals holding the string literal.
+func constValue(fn *ir2.Func, con *ssa.Const, pos token.Pos) interface{} {
+	if con.Value == nil {
+		return nilValue(fn, con.Type(), pos)
+	}
+
+	if basic, ok := con.Type().Underlying().(*types.Basic); ok && basic.Info()&types.IsString != 0 {
+		str := constant.StringVal(con.Value)
+		glob := fn.Package().NewStringLiteral(fn.Name, str)
+		glob.Referenced = true
+		return glob
+	}
+
+	return con.Value
+}
+
+// isCodeAddr returns whether the operand of the instr is the address
+// of a func's code rather than a func value
+func isCodeAddr(instr ssa.Instruction, operand *ssa.Value) bool {
+	switch instr := instr.(type) {
+	case *ssa.Go, *ssa.Defer:
+		// the goroutine or deferred call needs a func value to call
+		return false
+	case ssa.CallInstruction:
+		return operand == &instr.Common().Value
+	case *ssa.MakeClosure:
+		return operand == &instr.Fn
+	}
+	return false
+}
+
+// nilValue returns the value for a nil constant of the type. Slices
+// are a pointer to their (ptr, len, cap) header, so a nil slice points
+// to an all zero header in the runtime. Interfaces are the same with
+// their (type, data) header.
+func nilValue(fn *ir2.Func, typ types.Type, pos token.Pos) interface{} {
+	var name string
+	switch typ.Underlying().(type) {
+	case *types.Slice:
+		name = "nilSlice"
+	case *types.Interface:
+		name = "nilInterface"
+	default:
+		return ir2.ConstFor(nil)
+	}
+
+	runtime := fn.Package().Program().Package("runtime")
+	if runtime == nil {
+		diag.Errorf(pos, "runtime not loaded, needed for %s", name)
+		return ir2.ConstFor(nil)
+	}
+
+	glob := runtime.Global(name)
+	if glob == nil {
+		diag.Errorf(pos, "runtime.%s not found", name)
+		return ir2.ConstFor(nil)
+	}
+	glob.Referenced = true
+
+	return glob
+}
+
 func (fe *FrontEnd) resolvePlaceholders(fn *ir2.Func) {
 	for _, label := range fn.PlaceholderLabels() {
 		ssaValue := fe.placeholders[label]
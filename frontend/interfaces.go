@@ -0,0 +1,173 @@
+package frontend
+
+import (
+	"go/token"
+	"go/types"
+	"sort"
+
+	"github.com/rj45/nanogo/diag"
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/sizes"
+	"golang.org/x/tools/go/ssa"
+)
+
+// typeTable returns the table describing a type put in an interface.
+// It has the type's code, followed by the number of methods and the
+// method ID and func of each method, sorted by ID:
+//
+//	code, numMethods, id0, fn0, id1, fn1, ...
+//
+// The funcs are the ssa method values, which for the methods of a
+// pointer to T with a value receiver are wrappers that load the value.
+func (fe *FrontEnd) typeTable(pos token.Pos, from *ir2.Func, typ types.Type) *ir2.Global {
+	if glob, ok := fe.typeTables.At(typ).(*ir2.Global); ok {
+		return glob
+	}
+
+	prog := fe.prog
+	ssaProg := fe.ssaFuncs[from].Prog
+
+	if isMultiWordInt(typ) {
+		diag.Errorf(pos, "%s values in interfaces are not supported yet", typ)
+	}
+
+	type entry struct {
+		id int
+		fn *ir2.Func
+	}
+	var methods []entry
+
+	mset := ssaProg.MethodSets.MethodSet(typ)
+	for i := 0; i < mset.Len(); i++ {
+		sel := mset.At(i)
+
+		ssaFn := ssaProg.MethodValue(sel)
+		if ssaFn == nil {
+			diag.Errorf(pos, "no method value for %s", sel)
+			continue
+		}
+
+		fn := fe.funcFor(ssaFn, from)
+		fn.Referenced = true
+
+		methods = append(methods, entry{
+			id: prog.MethodID(sel.Obj().(*types.Func)),
+			fn: fn,
+		})
+	}
+
+	sort.Slice(methods, func(i, j int) bool {
+		return methods[i].id < methods[j].id
+	})
+
+	words := []ir2.Const{
+		ir2.ConstFor(fe.typeCode(pos, typ)),
+		ir2.ConstFor(len(methods)),
+	}
+	for _, m := range methods {
+		words = append(words, ir2.ConstFor(m.id), ir2.ConstFor(m.fn))
+	}
+
+	runtime := fe.runtimePackage(pos)
+	if runtime == nil {
+		return nil
+	}
+	glob := runtime.NewTable("typeInfo", words)
+	glob.Referenced = true
+	fe.typeTables.Set(typ, glob)
+
+	return glob
+}
+
+// interfaceTable returns the table for an interface type asserted to,
+// which has the number of methods followed by their sorted method IDs
+func (fe *FrontEnd) interfaceTable(pos token.Pos, typ types.Type) *ir2.Global {
+	if glob, ok := fe.interfaceTables.At(typ).(*ir2.Global); ok {
+		return glob
+	}
+
+	iface := typ.Underlying().(*types.Interface)
+
+	ids := make([]int, iface.NumMethods())
+	for i := range ids {
+		ids[i] = fe.prog.MethodID(iface.Method(i))
+	}
+	sort.Ints(ids)
+
+	words := []ir2.Const{ir2.ConstFor(len(ids))}
+	for _, id := range ids {
+		words = append(words, ir2.ConstFor(id))
+	}
+
+	runtime := fe.runtimePackage(pos)
+	if runtime == nil {
+		return nil
+	}
+	glob := runtime.NewTable("interfaceInfo", words)
+	glob.Referenced = true
+	fe.interfaceTables.Set(typ, glob)
+
+	return glob
+}
+
+// typeCode returns the code that identifies the type at runtime
+func (fe *FrontEnd) typeCode(pos token.Pos, typ types.Type) int {
+	code := fe.prog.TypeCode(typ)
+	if code == 0 {
+		diag.Errorf(pos, "too many types in the program to give %s a type code", typ)
+	}
+	return int(code)
+}
+
+// isMultiWordInt returns whether the type is an integer wider than a
+// word, which can't be the data of an interface without boxing it
+func isMultiWordInt(typ types.Type) bool {
+	basic, ok := typ.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsInteger != 0 && sizes.Sizeof(basic) > sizes.WordSize()
+}
+
+// runtimePackage returns the runtime package the type tables go in,
+// reporting an error at pos and returning nil if it isn't loaded
+func (fe *FrontEnd) runtimePackage(pos token.Pos) *ir2.Package {
+	runtime := fe.prog.Package("runtime")
+	if runtime == nil {
+		diag.Errorf(pos, "runtime not loaded, needed for interfaces")
+	}
+	return runtime
+}
+
+// translateInterfaceOp adds the extra arg interface ops need: the type
+// table of the value being put into an interface, the type code or
+// interface table being asserted to, or the method ID being called
+func (fe *FrontEnd) translateInterfaceOp(fn *ir2.Func, instr ssa.Instruction) *ir2.Value {
+	uintptr := types.Typ[types.Uintptr]
+
+	switch instr := instr.(type) {
+	case *ssa.MakeInterface:
+		table := fe.typeTable(getPos(instr), fn, instr.X.Type())
+		if table == nil {
+			return nil
+		}
+		return fn.ValueFor(table.Type, table)
+
+	case *ssa.TypeAssert:
+		if types.IsInterface(instr.AssertedType) {
+			table := fe.interfaceTable(getPos(instr), instr.AssertedType)
+			if table == nil {
+				return nil
+			}
+			return fn.ValueFor(table.Type, table)
+		}
+
+		if isMultiWordInt(instr.AssertedType) {
+			diag.Errorf(getPos(instr), "%s values in interfaces are not supported yet", instr.AssertedType)
+		}
+		return fn.ValueFor(uintptr, fe.typeCode(getPos(instr), instr.AssertedType))
+
+	case ssa.CallInstruction:
+		return fn.ValueFor(uintptr, fe.prog.MethodID(instr.Common().Method))
+	}
+
+	diag.Errorf(getPos(instr), "unexpected interface op %s", instr)
+	return nil
+}
@@ -3,9 +3,14 @@ package frontend
 import (
 	"errors"
 	"fmt"
+	"go/ast"
+	"go/token"
 	"os"
 	"sort"
+	"strconv"
+	"strings"
 
+	"github.com/rj45/nanogo/diag"
 	"github.com/rj45/nanogo/goenv"
 	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/go/ssa"
@@ -20,10 +25,13 @@ func (m members) Len() int           { return len(m) }
 func (m members) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
 func (m members) Less(i, j int) bool { return m[i].Pos() < m[j].Pos() }
 
-func parseProgram(dir string, patterns ...string) ([]ssa.Member, error) {
+// parseProgram loads the packages and builds them into SSA, returning
+// their members sorted by position, and the declarations of their funcs
+// by the position of their names
+func parseProgram(dir string, patterns ...string) ([]ssa.Member, map[token.Pos]*ast.FuncDecl, error) {
 	goroot, err := goenv.GetCachedGoroot()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	needs := packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles |
@@ -39,7 +47,7 @@ func parseProgram(dir string, patterns ...string) ([]ssa.Member, error) {
 
 	initial, err := packages.Load(&cfg, patterns...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	hasRuntime := false
@@ -59,19 +67,19 @@ func parseProgram(dir string, patterns ...string) ([]ssa.Member, error) {
 
 		rt, err := packages.Load(&cfg, "runtime")
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		if packages.PrintErrors(rt) > 0 {
-			return nil, fmt.Errorf("%w: runtime parsing had errors", ErrParsing)
+		if reportErrors(rt) > 0 {
+			return nil, nil, fmt.Errorf("%w: runtime parsing had errors", ErrParsing)
 		}
 
 		main.Imports["runtime"] = rt[0]
 	}
 
-	// Print any errors that happened in the build process
-	if packages.PrintErrors(initial) > 0 {
-		return nil, fmt.Errorf("%w: initial package parsing had errors", ErrParsing)
+	// Report any errors that happened in the build process
+	if reportErrors(initial) > 0 {
+		return nil, nil, fmt.Errorf("%w: initial package parsing had errors", ErrParsing)
 	}
 
 	// Create SSA packages for well-typed packages and their dependencies.
@@ -95,7 +103,25 @@ func parseProgram(dir string, patterns ...string) ([]ssa.Member, error) {
 	// Sort by Pos()
 	sort.Sort(members)
 
-	return members, nil
+	return members, funcDecls(initial), nil
+}
+
+// funcDecls returns the declarations of the funcs in the packages and
+// their imports by the position of their names, which is the position
+// of their SSA funcs. The SSA funcs only have their syntax when built
+// in debug mode.
+func funcDecls(pkgs []*packages.Package) map[token.Pos]*ast.FuncDecl {
+	decls := make(map[token.Pos]*ast.FuncDecl)
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				if fn, ok := decl.(*ast.FuncDecl); ok {
+					decls[fn.Name.Pos()] = fn
+				}
+			}
+		}
+	})
+	return decls
 }
 
 func hasRuntimePackage(pkg *packages.Package) bool {
@@ -109,3 +135,48 @@ func hasRuntimePackage(pkg *packages.Package) bool {
 	}
 	return false
 }
+
+// reportErrors reports the errors from loading the packages as
+// diagnostics, returning how many there were
+func reportErrors(pkgs []*packages.Package) int {
+	n := 0
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, err := range pkg.Errors {
+			diag.ErrorAt(parsePos(err.Pos), "%s", err.Msg)
+			n++
+		}
+	})
+	return n
+}
+
+// parsePos parses the "file:line:col" positions packages errors have,
+// where the line and column are optional
+func parsePos(str string) token.Position {
+	var pos token.Position
+	if str == "" || str == "-" {
+		return pos
+	}
+
+	var nums []int
+	for len(nums) < 2 {
+		i := strings.LastIndex(str, ":")
+		if i < 0 {
+			break
+		}
+		n, err := strconv.Atoi(str[i+1:])
+		if err != nil {
+			break
+		}
+		nums = append([]int{n}, nums...)
+		str = str[:i]
+	}
+
+	pos.Filename = str
+	if len(nums) > 0 {
+		pos.Line = nums[0]
+	}
+	if len(nums) > 1 {
+		pos.Column = nums[1]
+	}
+	return pos
+}
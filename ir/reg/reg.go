@@ -36,6 +36,7 @@ func SetArch(arch Arch) {
 	GP = spec["GP"]
 	SP = spec["SP"]
 	FP = spec["FP"]
+	Context = spec["CTX"]
 }
 
 var names []string
@@ -59,10 +60,24 @@ var FP Reg
 var GP Reg
 var RA Reg
 
+// Context holds the closure object when calling a func value, so the
+// func can find its free variables. It's otherwise an ordinary temp reg.
+var Context Reg
+
 func FromRegNum(num int) Reg {
 	return 1 << num
 }
 
+// Named returns the register with the name, or None if there isn't one
+func Named(name string) Reg {
+	for i, n := range names {
+		if n == name {
+			return FromRegNum(i)
+		}
+	}
+	return None
+}
+
 func (reg Reg) String() string {
 	if reg == None {
 		return "none"
@@ -76,6 +76,41 @@ func (blk *Block) AddSucc(succ *Block) {
 	blk.succs = append(blk.succs, succ)
 }
 
+// SuccArgIndex returns the index of the first arg passed
+// to the ith successor
+func (blk *Block) SuccArgIndex(i int) int {
+	index := 0
+	for _, succ := range blk.succs[:i] {
+		index += len(succ.defs)
+	}
+	return index
+}
+
+// RemovePred removes the Block from the predecessor list
+func (blk *Block) RemovePred(pred *Block) {
+	for i, p := range blk.preds {
+		if p == pred {
+			blk.preds = append(blk.preds[:i], blk.preds[i+1:]...)
+			return
+		}
+	}
+	log.Panicf("%v is not a pred of %v", pred, blk)
+}
+
+// RemoveSucc removes the ith successor and the args passed
+// to it, and removes this Block from its predecessor list
+func (blk *Block) RemoveSucc(i int) {
+	succ := blk.succs[i]
+
+	index := blk.SuccArgIndex(i)
+	for range succ.defs {
+		blk.RemoveArgAt(index)
+	}
+
+	blk.succs = append(blk.succs[:i], blk.succs[i+1:]...)
+	succ.RemovePred(blk)
+}
+
 // SwapSuccs swaps the successors, useful for inverting `If`
 func (blk *Block) SwapSuccs() {
 	if len(blk.succs) != 2 {
@@ -122,6 +157,45 @@ func (blk *Block) Unlink() {
 	}
 }
 
+// SplitAt splits the Block before the ith Instr, moving that Instr
+// and the ones after it into a new Block placed right after this one.
+// The new Block takes over the succs and the args passed to them,
+// and becomes the only succ of this Block, which is left without a
+// control flow Instr.
+func (blk *Block) SplitAt(i int) *Block {
+	fn := blk.fn
+
+	rest := fn.NewBlock()
+	fn.InsertBlock(fn.BlockIndex(blk)+1, rest)
+
+	for _, instr := range blk.instrs[i:] {
+		instr.blk = rest
+		instr.index = len(rest.instrs)
+		rest.instrs = append(rest.instrs, instr)
+	}
+	blk.instrs = blk.instrs[:i]
+
+	for _, arg := range blk.Args() {
+		blk.RemoveArg(arg)
+		rest.InsertArg(-1, arg)
+	}
+
+	for _, succ := range blk.succs {
+		for j, pred := range succ.preds {
+			if pred == blk {
+				succ.preds[j] = rest
+			}
+		}
+		rest.succs = append(rest.succs, succ)
+	}
+
+	blk.succs = blk.succs[:0]
+	blk.AddSucc(rest)
+	rest.AddPred(blk)
+
+	return rest
+}
+
 // NumInstrs returns the number of instructions
 func (blk *Block) NumInstrs() int {
 	return len(blk.instrs)
@@ -0,0 +1,20 @@
+package ir2
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+)
+
+// ClosureType returns the type of the object a func value points to,
+// which is the address of the func's code followed by the func's free
+// variables, if it has any
+func ClosureType(freeVars []types.Type) *types.Struct {
+	fields := []*types.Var{
+		types.NewField(token.NoPos, nil, "fn", types.Typ[types.Uintptr], false),
+	}
+	for i, typ := range freeVars {
+		fields = append(fields, types.NewField(token.NoPos, nil, fmt.Sprintf("fv%d", i), typ, false))
+	}
+	return types.NewStruct(fields, nil)
+}
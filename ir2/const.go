@@ -108,6 +108,10 @@ func ConstFor(v interface{}) Const {
 			if i, ok := constant.Int64Val(v); ok {
 				return intConst(i)
 			}
+			if u, ok := constant.Uint64Val(v); ok {
+				// keep the bits of large unsigned values
+				return intConst(u)
+			}
 			return notConst{}
 
 		default:
@@ -154,6 +158,22 @@ func Int64Value(c Const) (int64, bool) {
 	return int64(c.(intConst)), true
 }
 
+// Log2Value returns the power of two an IntConst is, if it is a
+// positive power of two
+func Log2Value(c Const) (int64, bool) {
+	v, ok := Int64Value(c)
+	if !ok || v <= 0 || v&(v-1) != 0 {
+		return 0, false
+	}
+
+	n := int64(0)
+	for v > 1 {
+		v >>= 1
+		n++
+	}
+	return n, true
+}
+
 // FuncValue returns a *Func for a FuncConst
 func FuncValue(c Const) (*Func, bool) {
 	if c.Kind() != FuncConst {
@@ -0,0 +1,176 @@
+package ir2
+
+// DomTree is the dominator tree of a Func's Blocks. A Block
+// dominates another if every path from the entry to the other
+// Block goes through it, and the immediate dominator (IDom) of
+// a Block is the closest of the Blocks that dominate it. Blocks
+// that can't be reached from the entry are not in the tree.
+//
+// The tree is not updated as the Func changes, so it needs
+// to be computed again after the control flow graph changes.
+type DomTree struct {
+	fn *Func
+
+	// indexed by the Block ID index
+	idom     []*Block
+	children [][]*Block
+	pre      []int
+	post     []int
+
+	preorder []*Block
+}
+
+// DomTree computes the dominator tree of the Func's Blocks
+func (fn *Func) DomTree() *DomTree {
+	dt := &DomTree{
+		fn:       fn,
+		idom:     make([]*Block, fn.NumBlockIDs()),
+		children: make([][]*Block, fn.NumBlockIDs()),
+		pre:      make([]int, fn.NumBlockIDs()),
+		post:     make([]int, fn.NumBlockIDs()),
+	}
+
+	if fn.NumBlocks() == 0 {
+		return dt
+	}
+
+	dt.compute()
+	dt.number(fn.blocks[0])
+
+	return dt
+}
+
+// compute finds the immediate dominators with the algorithm from
+// "A Simple, Fast Dominance Algorithm" by Cooper, Harvey and Kennedy,
+// which refines them in reverse postorder until they stop changing
+func (dt *DomTree) compute() {
+	entry := dt.fn.blocks[0]
+	order := reversePostorder(entry)
+
+	rpo := make([]int, len(dt.idom))
+	for i, blk := range order {
+		rpo[blk.Index()] = i
+	}
+
+	intersect := func(a, b *Block) *Block {
+		for a != b {
+			for rpo[a.Index()] > rpo[b.Index()] {
+				a = dt.idom[a.Index()]
+			}
+			for rpo[b.Index()] > rpo[a.Index()] {
+				b = dt.idom[b.Index()]
+			}
+		}
+		return a
+	}
+
+	dt.idom[entry.Index()] = entry
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, blk := range order[1:] {
+			var idom *Block
+			for _, pred := range blk.preds {
+				if dt.idom[pred.Index()] == nil {
+					// not processed yet, or can't be reached
+					continue
+				}
+				if idom == nil {
+					idom = pred
+				} else {
+					idom = intersect(pred, idom)
+				}
+			}
+
+			if dt.idom[blk.Index()] != idom {
+				dt.idom[blk.Index()] = idom
+				changed = true
+			}
+		}
+	}
+
+	// the entry has no immediate dominator
+	dt.idom[entry.Index()] = nil
+
+	for _, blk := range order[1:] {
+		idom := dt.idom[blk.Index()]
+		dt.children[idom.Index()] = append(dt.children[idom.Index()], blk)
+	}
+}
+
+// number walks the tree to number the Blocks in preorder and
+// postorder, which makes checking for dominance quick
+func (dt *DomTree) number(entry *Block) {
+	count := 0
+
+	var walk func(blk *Block)
+	walk = func(blk *Block) {
+		count++
+		dt.pre[blk.Index()] = count
+		dt.preorder = append(dt.preorder, blk)
+
+		for _, child := range dt.children[blk.Index()] {
+			walk(child)
+		}
+
+		count++
+		dt.post[blk.Index()] = count
+	}
+	walk(entry)
+}
+
+// reversePostorder returns the Blocks that can be reached from the
+// entry in reverse postorder, so each Block comes before its succs,
+// other than along back edges
+func reversePostorder(entry *Block) []*Block {
+	var order []*Block
+	visited := make(map[*Block]bool)
+
+	var visit func(blk *Block)
+	visit = func(blk *Block) {
+		visited[blk] = true
+		for _, succ := range blk.succs {
+			if !visited[succ] {
+				visit(succ)
+			}
+		}
+		order = append(order, blk)
+	}
+	visit(entry)
+
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+	return order
+}
+
+// IDom returns the immediate dominator of the Block, or nil
+// for the entry Block and Blocks that can't be reached
+func (dt *DomTree) IDom(blk *Block) *Block {
+	return dt.idom[blk.Index()]
+}
+
+// Children returns the Blocks the Block immediately dominates
+func (dt *DomTree) Children(blk *Block) []*Block {
+	return dt.children[blk.Index()]
+}
+
+// Reachable returns whether the Block can be reached from the entry
+func (dt *DomTree) Reachable(blk *Block) bool {
+	return dt.pre[blk.Index()] != 0
+}
+
+// Dominates returns whether a dominates b. A Block dominates itself.
+func (dt *DomTree) Dominates(a, b *Block) bool {
+	if !dt.Reachable(a) || !dt.Reachable(b) {
+		return false
+	}
+	return dt.pre[a.Index()] <= dt.pre[b.Index()] && dt.post[b.Index()] <= dt.post[a.Index()]
+}
+
+// Preorder returns the Blocks that can be reached in the order of a
+// walk of the tree, so every Block comes after the ones dominating it
+func (dt *DomTree) Preorder() []*Block {
+	return dt.preorder
+}
@@ -0,0 +1,76 @@
+package ir2_test
+
+import (
+	"testing"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/parseir"
+
+	// registering rj32 sets it as the default arch
+	_ "github.com/rj45/nanogo/arch/rj32"
+)
+
+func TestDomTree_withLoopAndDiamond(t *testing.T) {
+	fn, err := parseir.ParseString(`
+	.b0:
+		v0:bool = parameter 0
+		v1:int = parameter 1
+		jump .b1
+	.b1:
+		if v0, .b2, .b5
+	.b2:
+		if v0, .b3, .b4
+	.b3:
+		jump .b4
+	.b4:
+		jump .b1
+	.b5:
+		return
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blk := make([]*ir2.Block, fn.NumBlocks())
+	for i := range blk {
+		blk[i] = fn.Block(i)
+	}
+
+	dt := fn.DomTree()
+
+	idoms := []*ir2.Block{nil, blk[0], blk[1], blk[2], blk[2], blk[1]}
+	for i, want := range idoms {
+		if got := dt.IDom(blk[i]); got != want {
+			t.Errorf("expected idom of %v to be %v but got %v", blk[i], want, got)
+		}
+	}
+
+	tests := []struct {
+		a, b int
+		want bool
+	}{
+		{0, 5, true},
+		{1, 4, true},
+		{2, 2, true},
+		{3, 4, false},
+		{4, 1, false},
+		{2, 5, false},
+	}
+	for _, tt := range tests {
+		if got := dt.Dominates(blk[tt.a], blk[tt.b]); got != tt.want {
+			t.Errorf("expected %v dominates %v to be %v", blk[tt.a], blk[tt.b], tt.want)
+		}
+	}
+
+	order := dt.Preorder()
+	if len(order) != len(blk) || order[0] != blk[0] {
+		t.Fatalf("expected preorder of all blocks starting with the entry but got %v", order)
+	}
+	seen := make(map[*ir2.Block]bool)
+	for _, b := range order {
+		if idom := dt.IDom(b); idom != nil && !seen[idom] {
+			t.Errorf("expected %v to come after its idom %v in %v", b, idom, order)
+		}
+		seen[b] = true
+	}
+}
@@ -3,10 +3,12 @@ package ir2
 import (
 	"bytes"
 	"fmt"
+	"go/token"
 	"go/types"
 	"io"
 	"log"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -89,30 +91,83 @@ func (pkg *Package) Emit(out io.Writer, dec Decorator) {
 
 func (glob *Global) Emit(out io.Writer, dec Decorator) {
 	dec.Begin(out, glob)
-	val := glob.Value
 	valstr := ""
-	if val != nil {
-		// todo: wrap this in the decorator?
-		if val.Kind() == StringConst {
-			valstr = fmt.Sprintf(" = %q", val.String())
-		} else {
-			valstr = fmt.Sprintf(" = %s", val.String())
+	if glob.Words != nil {
+		words := make([]string, len(glob.Words))
+		for i, word := range glob.Words {
+			words[i] = constString(word)
 		}
+		valstr = fmt.Sprintf(" = {%s}", strings.Join(words, ", "))
+	} else if glob.Value != nil {
+		// todo: wrap this in the decorator?
+		valstr = fmt.Sprintf(" = %s", constString(glob.Value))
+	}
+	if glob.Shared {
+		valstr += " shared"
 	}
 
 	typstr := types.TypeString(glob.Type, qualifier(glob.pkg.Type))
-	fmt.Fprintf(out, "var %s:%s%s\n",
-		dec.WrapLabel(glob.FullName, glob),
+	fmt.Fprintf(out, "var %s%s:%s%s\n",
+		dec.WrapLabel(glob.FullName, glob), nameString(glob.FullName, glob.Name),
 		dec.WrapType(typstr), valstr)
 
 	dec.End(out, glob)
 }
 
+// constString returns how a const is written in the initial value
+// of a global, where funcs and globals are marked with a ^
+func constString(c Const) string {
+	switch c.Kind() {
+	case StringConst:
+		return fmt.Sprintf("%q", c.String())
+	case FuncConst, GlobalConst:
+		return "^" + c.String()
+	}
+	return c.String()
+}
+
+// nameString returns the name of a func or global quoted, for when it
+// can't be told from its full name, which is usually the package name
+// and the name made into a label
+func nameString(fullName, name string) string {
+	if i := strings.Index(fullName, "__"); i >= 0 && fullName[i+2:] == name {
+		return ""
+	}
+	return " " + strconv.Quote(name)
+}
+
+// Emit writes a type definition with the underlying type of the named
+// type, followed by its methods, if it has any, in braces like the
+// methods of an interface, with a * before the ones with a pointer
+// receiver. Aliases are written with an = like in Go.
 func (td *TypeDef) Emit(out io.Writer, dec Decorator) {
 	dec.Begin(out, td)
 
-	typ := td.Type.Underlying()
-	typstr := types.TypeString(typ, qualifier(td.pkg.Type))
+	qual := qualifier(td.pkg.Type)
+	named, ok := td.Type.(*types.Named)
+	if !ok {
+		fmt.Fprintf(out, "type %s = %s\n",
+			dec.WrapLabel(td.Name, td),
+			dec.WrapType(types.TypeString(td.Type, qual)))
+		dec.End(out, td)
+		return
+	}
+
+	typstr := types.TypeString(named.Underlying(), qual)
+	if named.NumMethods() > 0 {
+		methods := make([]string, named.NumMethods())
+		for i := range methods {
+			method := named.Method(i)
+			sig := method.Type().(*types.Signature)
+			ptr := ""
+			if _, ok := sig.Recv().Type().(*types.Pointer); ok {
+				ptr = "*"
+			}
+			methods[i] = ptr + method.Name() + strings.TrimPrefix(types.TypeString(sig, qual), "func")
+		}
+		typstr += fmt.Sprintf(" {%s}", strings.Join(methods, "; "))
+	}
+
 	fmt.Fprintf(out, "type %s:%s\n",
 		dec.WrapLabel(td.Name, td),
 		dec.WrapType(typstr))
@@ -120,11 +175,46 @@ func (td *TypeDef) Emit(out io.Writer, dec Decorator) {
 	dec.End(out, td)
 }
 
+// Emit writes the func with its signature, and attributes for what
+// else is needed to carry on compiling it once it's parsed back in,
+// like the stage it's been through and its pragmas
 func (fn *Func) Emit(out io.Writer, dec Decorator) {
 	dec.Begin(out, fn)
 
-	// fmt.Fprintf(out, "; %s\n", dec.WrapType(fn.Sig.String()))
-	fmt.Fprintf(out, "func %s:\n", dec.WrapLabel(fn.FullName, fn))
+	sigstr := ""
+	if fn.Sig != nil {
+		sigstr = strings.TrimPrefix(types.TypeString(fn.Sig, qualifier(fn.pkg.Type)), "func")
+	}
+
+	var attrs []string
+	if fn.Stage != "" {
+		attrs = append(attrs, "stage="+fn.Stage)
+	}
+	for _, attr := range []struct {
+		name string
+		set  bool
+	}{
+		{"elaborated", fn.Elaborated},
+		{"nobounds", fn.NoBounds},
+		{"noinline", fn.NoInline},
+		{"inline", fn.Inline},
+		{"shared", fn.Shared},
+	} {
+		if attr.set {
+			attrs = append(attrs, attr.name)
+		}
+	}
+	if fn.Recover != nil {
+		attrs = append(attrs, "recover=."+fn.Recover.String())
+	}
+	attrstr := ""
+	if len(attrs) > 0 {
+		attrstr = " " + strings.Join(attrs, " ")
+	}
+
+	fmt.Fprintf(out, "func %s%s%s:%s\n",
+		dec.WrapLabel(fn.FullName, fn), nameString(fn.FullName, fn.Name),
+		dec.WrapType(sigstr), attrstr)
 	for _, blk := range fn.blocks {
 		blk.Emit(out, dec)
 	}
@@ -211,6 +301,11 @@ func (in *Instr) Emit(out io.Writer, dec Decorator) {
 		}
 	}
 
+	var def types.Type
+	if len(in.defs) > 0 {
+		def = in.defs[0].Type
+	}
+
 	argstr := ""
 	for i, arg := range in.args {
 		if i != 0 {
@@ -222,28 +317,18 @@ func (in *Instr) Emit(out io.Writer, dec Decorator) {
 			continue
 		}
 
-		globref := ""
-		if arg.Const().Kind() == FuncConst || arg.Const().Kind() == GlobalConst {
-			globref = "^" // denote it's a global
-		}
-
-		argstr += dec.WrapRef(globref+arg.String(), arg)
-
-		if arg.Const().Kind() == StringConst {
-			basic, ok := arg.Type.(*types.Basic)
-			if !ok || (basic.Info()&types.IsUntyped) == 0 {
-				argstr += ":"
-				typstr := types.TypeString(arg.Type, qualifier(in.Func().pkg.Type))
-				argstr += dec.WrapType(typstr)
-			}
-		}
+		argstr += argString(arg, def, dec, qualifier(in.Func().pkg.Type))
 	}
 
 	str := ""
 
 	opstr := "<!nilOp>"
 	if in.Op != nil {
-		opstr = dec.WrapOp(in.String(), in.Op)
+		name := in.Op.String()
+		if aop, ok := in.Op.(ArchOp); ok {
+			name = aop.Arch() + "." + name
+		}
+		opstr = dec.WrapOp(name, in.Op)
 	}
 
 	if dec.SSAForm() {
@@ -295,7 +380,7 @@ func (in *Instr) Emit(out io.Writer, dec Decorator) {
 					arg := in.blk.args[argn]
 					argn++
 
-					str += dec.WrapLabel(arg.String(), arg)
+					str += argString(arg, nil, dec, qualifier(in.Func().pkg.Type))
 				}
 				str += ")"
 			}
@@ -307,6 +392,82 @@ func (in *Instr) Emit(out io.Writer, dec Decorator) {
 	dec.End(out, in)
 }
 
+// argString returns how an arg is written. Consts are written with
+// their type when it isn't the one they're taken to have without it,
+// which is the type of what funcs and globals refer to, the untyped
+// kind of strings, and the type of the instr's first def, def, for
+// other consts, or else their untyped kind. Strings are quoted unless
+// they're identifiers that can't be taken for anything else. Values
+// nothing defines, like the registers the prologue saves, are written
+// with their type too.
+func argString(arg *Value, def types.Type, dec Decorator, qual types.Qualifier) string {
+	if !arg.IsConst() || arg.ID == Placeholder {
+		str := dec.WrapRef(arg.String(), arg)
+		if arg.ID != Placeholder && arg.def == nil && arg.Type != nil {
+			str += ":" + dec.WrapType(types.TypeString(arg.Type, qual))
+		}
+		return str
+	}
+
+	con := arg.Const()
+	str := con.String()
+	var typ types.Type
+
+	switch con.Kind() {
+	case FuncConst:
+		fn, _ := FuncValue(con)
+		str = "^" + str
+		typ = fn.Sig
+	case GlobalConst:
+		glob, _ := GlobalValue(con)
+		str = "^" + str
+		typ = glob.Type
+	case StringConst:
+		if quoteString(str) {
+			str = strconv.Quote(str)
+		}
+		typ = types.Typ[types.UntypedString]
+	default:
+		typ = def
+		if typ == nil {
+			typ = untypedConst(con)
+		}
+	}
+
+	str = dec.WrapRef(str, arg)
+	if arg.Type != nil && (typ == nil || !types.Identical(arg.Type, typ)) {
+		str += ":" + dec.WrapType(types.TypeString(arg.Type, qual))
+	}
+	return str
+}
+
+// untypedConst returns the untyped type of the kind of const
+func untypedConst(con Const) types.Type {
+	switch con.Kind() {
+	case BoolConst:
+		return types.Typ[types.UntypedBool]
+	case IntConst:
+		return types.Typ[types.UntypedInt]
+	case NilConst:
+		return types.Typ[types.UntypedNil]
+	}
+	return nil
+}
+
+// quoteString returns whether a string const has to be quoted, which
+// it doesn't if it's an identifier that can't be taken for a value,
+// a func or global, or another kind of const
+func quoteString(str string) bool {
+	if !token.IsIdentifier(str) || strings.Contains(str, "__") {
+		return true
+	}
+	switch str {
+	case "nil", "true", "false":
+		return true
+	}
+	return len(str) > 1 && str[0] == 'v' && str[1] >= '0' && str[1] <= '9'
+}
+
 func (in *Instr) LongString() string {
 	buf := &bytes.Buffer{}
 	in.Emit(buf, SSAString{})
@@ -17,6 +17,36 @@ type Func struct {
 	Referenced bool
 	NumCalls   int
 
+	// NoBounds is set by the //go:nobounds pragma to
+	// disable bounds checks
+	NoBounds bool
+
+	// NoInline is set by the //go:noinline pragma to keep
+	// calls to the func from being inlined
+	NoInline bool
+
+	// Inline is set by the //go:inline pragma to inline calls
+	// to the func no matter how big it is
+	Inline bool
+
+	// Shared funcs are generated for the code that uses them, such
+	// as method wrappers. When packages are compiled separately, each
+	// object that uses one has a copy, and the linker keeps one.
+	Shared bool
+
+	// Elaborated is set once the func has been elaborated, after
+	// which it's too late to inline it into other funcs
+	Elaborated bool
+
+	// Stage is the name of the last stage of the pipeline the func
+	// has been through, which is written out with it, so that the
+	// pipeline can carry on from there when it's parsed back in
+	Stage string
+
+	// Recover is the block that a call continues from when one of
+	// its deferred calls recovers from a panic, or nil if it has none
+	Recover *Block
+
 	numArgSlots   int
 	numParamSlots int
 	numSpillSlots int
@@ -25,7 +55,7 @@ type Func struct {
 
 	blocks []*Block
 
-	consts map[Const]*Value
+	consts map[constKey]*Value
 
 	// placeholders that need filling
 	placeholders map[string]*Value
@@ -52,6 +82,21 @@ func (fn *Func) Package() *Package {
 	return fn.pkg
 }
 
+// NumParamSlots returns the number of param slots used by the Func.
+func (fn *Func) NumParamSlots() int {
+	return fn.numParamSlots
+}
+
+// NumArgSlots returns the number of arg slots used by calls in the Func.
+func (fn *Func) NumArgSlots() int {
+	return fn.numArgSlots
+}
+
+// NumSpillSlots returns the number of spill slots used by the Func.
+func (fn *Func) NumSpillSlots() int {
+	return fn.numSpillSlots
+}
+
 func (fn *Func) NumValues() int {
 	return len(fn.idValues)
 }
@@ -63,6 +108,14 @@ func (fn *Func) ValueForID(v ID) *Value {
 
 // NewValue creates a new Value of type typ
 func (fn *Func) NewValue(typ types.Type) *Value {
+	val := fn.allocValue(len(fn.idValues), typ)
+
+	fn.idValues = append(fn.idValues, val)
+
+	return val
+}
+
+func (fn *Func) allocValue(id int, typ types.Type) *Value {
 	// allocate values in contiguous slabs in memory
 	// to increase data locality
 	if len(fn.valueslab) == cap(fn.valueslab) {
@@ -71,13 +124,66 @@ func (fn *Func) NewValue(typ types.Type) *Value {
 	fn.valueslab = append(fn.valueslab, Value{})
 	val := &fn.valueslab[len(fn.valueslab)-1]
 
-	val.init(idFor(ValueID, len(fn.idValues)), typ)
-
-	fn.idValues = append(fn.idValues, val)
+	val.init(idFor(ValueID, id), typ)
 
 	return val
 }
 
+// Renumber gives the Values and Blocks the ID numbers they're mapped
+// to, such as the ones they were written out with when they're parsed
+// back in, so they're written out the same again. The rest are given
+// the IDs after them, and the IDs left over in between are given to
+// Values and Blocks that aren't used.
+func (fn *Func) Renumber(values map[*Value]int, blocks map[*Block]int) {
+	var idValues []*Value
+	for val, id := range values {
+		for len(idValues) <= id {
+			idValues = append(idValues, nil)
+		}
+		idValues[id] = val
+	}
+	for _, val := range fn.idValues {
+		if _, ok := values[val]; !ok {
+			idValues = append(idValues, val)
+		}
+	}
+	for id, val := range idValues {
+		if val == nil {
+			idValues[id] = fn.allocValue(id, nil)
+			continue
+		}
+		val.ID = idFor(ValueID, id)
+	}
+	fn.idValues = idValues
+
+	var idBlocks []*Block
+	for blk, id := range blocks {
+		for len(idBlocks) <= id {
+			idBlocks = append(idBlocks, nil)
+		}
+		idBlocks[id] = blk
+	}
+	for _, blk := range fn.idBlocks {
+		if _, ok := blocks[blk]; !ok {
+			idBlocks = append(idBlocks, blk)
+		}
+	}
+	for id, blk := range idBlocks {
+		if blk == nil {
+			idBlocks[id] = fn.allocBlock(id)
+			continue
+		}
+		blk.ID = idFor(BlockID, id)
+	}
+	fn.idBlocks = idBlocks
+}
+
+// constKey identifies the Value of a const in a func
+type constKey struct {
+	con Const
+	typ types.Type
+}
+
 // ValueFor looks up an existing Value
 func (fn *Func) ValueFor(typ types.Type, v interface{}) *Value {
 	switch v := v.(type) {
@@ -93,17 +199,20 @@ func (fn *Func) ValueFor(typ types.Type, v interface{}) *Value {
 
 	con := ConstFor(v)
 	if con.Kind() != NotConst {
-		if conval, ok := fn.consts[con]; ok {
+		// consts of different types are different values, since the
+		// type decides how they are stored and printed
+		key := constKey{con, typ}
+		if conval, ok := fn.consts[key]; ok {
 			return conval
 		}
 		conval := fn.NewValue(typ)
 		conval.SetConst(con)
 
 		if fn.consts == nil {
-			fn.consts = make(map[Const]*Value)
+			fn.consts = make(map[constKey]*Value)
 		}
 
-		fn.consts[con] = conval
+		fn.consts[key] = conval
 
 		return conval
 	}
@@ -204,6 +313,12 @@ func (fn *Func) NumBlocks() int {
 	return len(fn.blocks)
 }
 
+// NumBlockIDs returns the number of Block IDs, which is more
+// than NumBlocks() once any Blocks have been removed
+func (fn *Func) NumBlockIDs() int {
+	return len(fn.idBlocks)
+}
+
 // Block returns the ith Block
 func (fn *Func) Block(i int) *Block {
 	return fn.blocks[i]
@@ -216,6 +331,14 @@ func (fn *Func) BlockForID(b ID) *Block {
 
 // NewBlock adds a new block
 func (fn *Func) NewBlock() *Block {
+	blk := fn.allocBlock(len(fn.idBlocks))
+
+	fn.idBlocks = append(fn.idBlocks, blk)
+
+	return blk
+}
+
+func (fn *Func) allocBlock(id int) *Block {
 	// allocate blocks in contiguous slabs in memory
 	// to increase data locality
 	if len(fn.blockslab) == cap(fn.blockslab) {
@@ -224,9 +347,7 @@ func (fn *Func) NewBlock() *Block {
 	fn.blockslab = append(fn.blockslab, Block{})
 	blk := &fn.blockslab[len(fn.blockslab)-1]
 
-	blk.init(fn, idFor(BlockID, len(fn.idBlocks)))
-
-	fn.idBlocks = append(fn.idBlocks, blk)
+	blk.init(fn, idFor(BlockID, id))
 
 	return blk
 }
@@ -11,8 +11,17 @@ type Global struct {
 	Type       types.Type
 	Referenced bool
 
+	// Shared globals are generated for the code that uses them, such
+	// as tables and func values. When packages are compiled separately,
+	// each object that uses one has a copy, and the linker keeps one.
+	Shared bool
+
 	// initial value
 	Value Const
+
+	// initial value of each word of a table, such as a method
+	// table, which is used instead of Value
+	Words []Const
 }
 
 func (glob *Global) String() string {
@@ -30,6 +30,15 @@ type Op interface {
 	IsSink() bool
 	ClobbersArg() bool
 	IsBranch() bool
+	IsReturn() bool
+}
+
+// ArchOp is an Op that's one of the instructions of an arch rather
+// than a generic op. They're written qualified with the name of the
+// arch, since they can have the same names as the generic ops.
+type ArchOp interface {
+	Op
+	Arch() string
 }
 
 // Index returns the index in the Block's Instr list
@@ -89,8 +98,7 @@ func (in *Instr) update(op Op, typ types.Type, args []interface{}) {
 	}
 
 	for len(in.args) > (len(args) + offset) {
-		// todo: replace with in.RemoveArgAt()?
-		in.RemoveArg(in.args[len(in.args)-1])
+		in.RemoveArgAt(len(in.args) - 1)
 	}
 }
 
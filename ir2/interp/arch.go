@@ -0,0 +1,53 @@
+package interp
+
+import (
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+)
+
+// Arch says what the instructions of an arch do, so that funcs can be
+// run after they've been translated into them
+type Arch interface {
+	// InterpOp returns what the arch's instruction does in terms of
+	// the generic ops
+	InterpOp(op ir2.Op) Semantics
+}
+
+// Semantics is what an arch's instruction does in terms of a generic op
+type Semantics struct {
+	Op op.Op
+
+	// Signed or Unsigned is set when the instruction treats its operands
+	// as signed or unsigned, rather than going by their types
+	Signed   bool
+	Unsigned bool
+
+	// Bits is how many bits a load or store accesses, in as many min
+	// addressable units as it takes, or 0 to go by the type
+	Bits int
+}
+
+var arch Arch
+
+func SetArch(a Arch) {
+	arch = a
+}
+
+// semantics returns what the op does
+func semantics(o ir2.Op) Semantics {
+	if o, ok := o.(op.Op); ok {
+		return Semantics{Op: o}
+	}
+	if arch == nil {
+		return Semantics{}
+	}
+	return arch.InterpOp(o)
+}
+
+// signed returns whether the instruction treats the value as signed
+func (sem Semantics) signed(val *ir2.Value) bool {
+	if sem.Signed || sem.Unsigned {
+		return sem.Signed
+	}
+	return isSigned(val.Type)
+}
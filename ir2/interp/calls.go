@@ -0,0 +1,237 @@
+package interp
+
+import (
+	"fmt"
+
+	"github.com/rj45/nanogo/ir/reg"
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+)
+
+// argsFunc returns the ith word passed to a func, or value before
+// elaboration, numbered across the arg registers then the arg slots
+type argsFunc func(i int) value
+
+func noArgs(int) value {
+	return value{}
+}
+
+// listArgs returns the args in the list
+func listArgs(vals []value) argsFunc {
+	return func(i int) value {
+		if i < len(vals) {
+			return vals[i]
+		}
+		return value{}
+	}
+}
+
+// regArgs returns the args in the arg registers and the frame's arg
+// slots, where they are once registers are allocated
+func (in *Interpreter) regArgs(fr *frame) argsFunc {
+	return func(i int) value {
+		if i < len(reg.ArgRegs) {
+			return wordValue(in.task.regs[reg.ArgRegs[i].RegNumber()])
+		}
+		if fr == nil {
+			return value{}
+		}
+		return wordValue(fr.argSlot(i - len(reg.ArgRegs)))
+	}
+}
+
+// call calls the func or func value in the first arg of the instr
+func (in *Interpreter) call(fr *frame, instr *ir2.Instr) error {
+	target := instr.Arg(0)
+
+	var fn *ir2.Func
+	var closure uint64
+	if target.IsConst() {
+		callee, ok := ir2.FuncValue(target.Const())
+		if !ok {
+			return fmt.Errorf("call of %s, which isn't a func", target)
+		}
+		fn = callee
+	} else {
+		val, err := in.read(fr, target)
+		if err != nil {
+			return err
+		}
+		closure = val.word
+
+		callee, err := in.funcValue(closure)
+		if err != nil {
+			return err
+		}
+		fn = callee
+	}
+
+	var args argsFunc
+	if in.stage < Allocated {
+		vals, err := in.readArgs(fr, instr.Args()[1:])
+		if err != nil {
+			return err
+		}
+		args = listArgs(vals)
+	} else {
+		args = in.regArgs(fr)
+	}
+
+	return in.invoke(fn, closure, fr, instr, args)
+}
+
+// funcValue returns the func a func value calls, which is the first
+// word of the closure object it points to
+func (in *Interpreter) funcValue(closure uint64) (*ir2.Func, error) {
+	if closure == 0 {
+		return nil, fmt.Errorf("%w: call of nil func value", ErrAbort)
+	}
+	fn := in.funcs[in.loadWord(closure)]
+	if fn == nil {
+		return nil, fmt.Errorf("call of func value 0x%x, which doesn't point to a func", closure)
+	}
+	return fn, nil
+}
+
+// invoke calls the func, which is called from the call in the caller's
+// frame, or by the interpreter when they're nil
+func (in *Interpreter) invoke(fn *ir2.Func, closure uint64, caller *frame, call *ir2.Instr, args argsFunc) error {
+	if isExtern(fn) {
+		return in.native(fn, caller, call, args)
+	}
+	return in.enter(fn, closure, caller, call, args)
+}
+
+// isExtern returns whether the func is implemented in assembly, which
+// can come after a prologue once it's finished
+func isExtern(fn *ir2.Func) bool {
+	if fn.NumBlocks() == 0 {
+		return false
+	}
+	blk := fn.Block(0)
+	for i := 0; i < blk.NumInstrs(); i++ {
+		if blk.Instr(i).Op == op.InlineAsm {
+			return true
+		}
+	}
+	return false
+}
+
+// enter starts running the func in a new frame
+func (in *Interpreter) enter(fn *ir2.Func, closure uint64, caller *frame, call *ir2.Instr, args argsFunc) error {
+	if fn.NumBlocks() == 0 {
+		return fmt.Errorf("call of %s, which has no body", fn.FullName)
+	}
+
+	fr := &frame{
+		in:     in,
+		fn:     fn,
+		blk:    fn.Block(0),
+		caller: caller,
+		call:   call,
+		sp:     in.task.regs[reg.SP.RegNumber()],
+		locals: make(map[*ir2.Instr]uint64),
+	}
+
+	if in.stage < Allocated {
+		fr.vals = make([]value, fn.NumValues())
+		in.bindParams(fr, closure, args)
+	} else if in.stage < Finished {
+		fr.spills = make([]uint64, fn.NumSpillSlots())
+
+		// the prologue would save these
+		for _, r := range reg.SavedRegs {
+			fr.savedReg = append(fr.savedReg, in.task.regs[r.RegNumber()])
+		}
+	}
+
+	in.task.frames = append(in.task.frames, fr)
+	return nil
+}
+
+// bindParams sets the defs of the entry block to the args, by the arg
+// register or param slot they're in, or the closure object for the
+// context register
+func (in *Interpreter) bindParams(fr *frame, closure uint64, args argsFunc) {
+	for i, def := range fr.blk.Defs() {
+		switch {
+		case def.InReg() && def.Reg() == reg.Context:
+			in.write(fr, def, wordValue(closure))
+		case def.InReg():
+			for a, r := range reg.ArgRegs {
+				if def.Reg() == r {
+					in.write(fr, def, in.bridge(args(a), def.Type))
+				}
+			}
+		case def.InParamSlot():
+			in.write(fr, def, in.bridge(args(len(reg.ArgRegs)+def.ParamSlot()), def.Type))
+		default:
+			in.write(fr, def, in.bridge(args(i), def.Type))
+		}
+	}
+}
+
+// ret returns from the func in the frame
+func (in *Interpreter) ret(fr *frame, instr *ir2.Instr) error {
+	var results []value
+	if in.stage < Allocated {
+		vals, err := in.readArgs(fr, instr.Args())
+		if err != nil {
+			return err
+		}
+		results = vals
+	}
+
+	in.leave(fr)
+
+	if fr.caller != nil {
+		return in.deliver(fr.caller, fr.call, results)
+	}
+	return nil
+}
+
+// leave removes the frame, freeing its locals, and restoring the saved
+// registers like the epilogue would
+func (in *Interpreter) leave(fr *frame) {
+	t := in.task
+	t.frames = t.frames[:len(t.frames)-1]
+
+	if in.stage >= Finished {
+		return
+	}
+
+	t.regs[reg.SP.RegNumber()] = fr.sp
+	for i, r := range reg.SavedRegs {
+		if i < len(fr.savedReg) {
+			t.regs[r.RegNumber()] = fr.savedReg[i]
+		}
+	}
+}
+
+// deliver gives the results of a call to the frame that made it, which
+// go in the defs of the call, or where the ABI puts them once registers
+// are allocated
+func (in *Interpreter) deliver(fr *frame, call *ir2.Instr, results []value) error {
+	if then := fr.then; then != nil {
+		fr.then = nil
+		return then(results)
+	}
+
+	if in.stage < Allocated {
+		for i, def := range call.Defs() {
+			if i < len(results) {
+				in.write(fr, def, in.bridge(results[i], def.Type))
+			}
+		}
+		return nil
+	}
+
+	for i, res := range results {
+		if i < len(reg.ArgRegs) {
+			in.task.regs[reg.ArgRegs[i].RegNumber()] = res.word
+		} else {
+			fr.setArgSlot(i-len(reg.ArgRegs), res.word)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+package interp
+
+import (
+	"fmt"
+	"go/types"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/sizes"
+)
+
+// how the runtime compares map keys, which must match elaboration
+const (
+	mapKeyMemory = 0
+	mapKeyString = 1
+)
+
+// slice slices a slice, array or string with the runtime, after it
+// checks the bounds, with missing bounds defaulting like Go's
+func (in *Interpreter) slice(fr *frame, instr *ir2.Instr, args []value) error {
+	x, low, high, max := args[0], args[1], args[2], args[3]
+	isNil := func(i int) bool {
+		arg := instr.Arg(i)
+		return arg.IsConst() && arg.Const().Kind() == ir2.NilConst
+	}
+	if isNil(1) {
+		low = wordValue(0)
+	}
+
+	var ptr, length, cap value
+	var elem types.Type
+
+	switch typ := instr.Arg(0).Type.Underlying().(type) {
+	case *types.Slice:
+		ptr = wordValue(in.unitsWord(x.units, 0))
+		length = wordValue(in.unitsWord(x.units, 1))
+		cap = wordValue(in.unitsWord(x.units, 2))
+		elem = typ.Elem()
+
+	case *types.Pointer:
+		array := typ.Elem().Underlying().(*types.Array)
+		ptr = x
+		length = wordValue(uint64(array.Len()))
+		cap = length
+		elem = array.Elem()
+
+	default:
+		// strings have no capacity
+		length = wordValue(in.unitsWord(x.units, 1))
+		if isNil(2) {
+			high = length
+		}
+		var calls []runtimeCall
+		if !fr.fn.NoBounds {
+			calls = append(calls, runtimeCall{"sliceBounds", []value{low, high, high, length}})
+		}
+		return in.callEach(fr, instr, calls, func() error {
+			return in.callRuntime(fr, instr, "stringSlice", []value{x, low, high}, in.setDefs(fr, instr))
+		})
+	}
+
+	if isNil(2) {
+		high = length
+	}
+	if isNil(3) {
+		max = cap
+	}
+
+	var calls []runtimeCall
+	if !fr.fn.NoBounds {
+		calls = append(calls, runtimeCall{"sliceBounds", []value{low, high, max, cap}})
+	}
+	size := wordValue(uint64(sizes.Sizeof(elem)))
+	return in.callEach(fr, instr, calls, func() error {
+		return in.callRuntime(fr, instr, "slice", []value{ptr, size, low, high, max}, in.setDefs(fr, instr))
+	})
+}
+
+// mapType returns the type of the map, or a map of the key and elem
+// types for nil maps
+func mapType(m *ir2.Value, key, elem types.Type) *types.Map {
+	if m.Type != nil {
+		if typ, ok := m.Type.Underlying().(*types.Map); ok {
+			return typ
+		}
+	}
+	return types.NewMap(key, elem)
+}
+
+func (in *Interpreter) makeMap(fr *frame, instr *ir2.Instr, args []value) error {
+	typ := instr.Def(0).Type.Underlying().(*types.Map)
+
+	kind := uint64(mapKeyMemory)
+	if isString(typ.Key()) {
+		kind = mapKeyString
+	}
+
+	return in.callRuntime(fr, instr, "hashmapMake", []value{
+		wordValue(uint64(sizes.Sizeof(typ.Key()))),
+		wordValue(uint64(sizes.Sizeof(typ.Elem()))),
+		wordValue(kind),
+		args[0],
+	}, in.setDefs(fr, instr))
+}
+
+// mapUpdate sets the value for a key, which are passed to the runtime
+// in memory
+func (in *Interpreter) mapUpdate(fr *frame, instr *ir2.Instr, args []value) error {
+	typ := mapType(instr.Arg(0), instr.Arg(1).Type, instr.Arg(2).Type)
+	key := in.box(fr, instr, -1, args[1], typ.Key())
+	val := in.box(fr, instr, -2, args[2], typ.Elem())
+	return in.callRuntime(fr, instr, "hashmapSet", []value{args[0], key, val}, nil)
+}
+
+// mapLookup gets the value for a key, which the runtime copies into
+// memory, along with whether it was found
+func (in *Interpreter) mapLookup(fr *frame, instr *ir2.Instr, args []value) error {
+	typ := mapType(instr.Arg(0), instr.Arg(1).Type, instr.Def(0).Type)
+	key := in.box(fr, instr, -1, args[1], typ.Key())
+
+	size := uint64(sizes.Sizeof(typ.Elem()))
+	val := in.scratch(fr, instr, -2, size)
+
+	callArgs := []value{args[0], key, wordValue(val), wordValue(size)}
+	return in.callRuntime(fr, instr, "hashmapGet", callArgs, func(results []value) error {
+		in.write(fr, instr.Def(0), in.loadValue(val, typ.Elem()))
+		if instr.NumDefs() > 1 {
+			in.write(fr, instr.Def(1), results[0])
+		}
+		return nil
+	})
+}
+
+func (in *Interpreter) mapBuiltin(fr *frame, instr *ir2.Instr, name string, args []value) error {
+	switch name {
+	case "len":
+		return in.callRuntime(fr, instr, "hashmapLen", args[1:2], in.setDefs(fr, instr))
+
+	case "delete":
+		key := in.box(fr, instr, -1, args[2], instr.Arg(2).Type)
+		return in.callRuntime(fr, instr, "hashmapDelete", []value{args[1], key}, nil)
+	}
+	return fmt.Errorf("%w builtin %s of a map", errUnsupported, name)
+}
+
+// rangeIter starts a range over a map or string, with an iterator in
+// memory that starts at the beginning
+func (in *Interpreter) rangeIter(fr *frame, instr *ir2.Instr, args []value) error {
+	words := uint64(1)
+	if isMapValue(instr.Arg(0)) {
+		// the bucket and entry
+		words = 2
+	} else if !isString(instr.Arg(0).Type) {
+		return fmt.Errorf("%w range over %s", errUnsupported, instr.Arg(0).Type)
+	}
+
+	iter := in.scratch(fr, instr, -1, words*in.wordSize)
+	for i := uint64(0); i < words; i++ {
+		in.storeWord(iter+i*in.wordSize, 0)
+	}
+	in.write(fr, instr.Def(0), wordValue(iter))
+	return nil
+}
+
+// next gets the next entry of a range over a map or the next rune of a
+// range over a string
+func (in *Interpreter) next(fr *frame, instr *ir2.Instr) error {
+	iter, err := in.read(fr, instr.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	rng := instr.Arg(0).Def().Instr()
+	x := rng.Arg(0)
+	xv, err := in.read(fr, x)
+	if err != nil {
+		return err
+	}
+
+	if isString(x.Type) {
+		enc := wordValue(0)
+		if in.mem.unitBits == 16 {
+			enc = wordValue(1)
+		}
+		return in.callRuntime(fr, instr, "stringNext", []value{xv, iter, enc}, in.setDefs(fr, instr))
+	}
+
+	if x.IsConst() {
+		// nil maps have no entries to copy out
+		callArgs := []value{xv, iter, xv, xv}
+		return in.callRuntime(fr, instr, "hashmapNext", callArgs, func(results []value) error {
+			in.write(fr, instr.Def(0), results[0])
+			return nil
+		})
+	}
+
+	typ := mapType(x, instr.Def(1).Type, instr.Def(2).Type)
+	key := in.scratch(fr, instr, -1, uint64(sizes.Sizeof(typ.Key())))
+	val := in.scratch(fr, instr, -2, uint64(sizes.Sizeof(typ.Elem())))
+
+	callArgs := []value{xv, iter, wordValue(key), wordValue(val)}
+	return in.callRuntime(fr, instr, "hashmapNext", callArgs, func(results []value) error {
+		in.write(fr, instr.Def(0), results[0])
+		if def := instr.Def(1); def.Type != nil {
+			in.write(fr, def, in.loadValue(key, typ.Key()))
+		}
+		if def := instr.Def(2); def.Type != nil {
+			in.write(fr, def, in.loadValue(val, typ.Elem()))
+		}
+		return nil
+	})
+}
+
+func isChan(val *ir2.Value) bool {
+	if val.Type == nil {
+		return false
+	}
+	_, ok := val.Type.Underlying().(*types.Chan)
+	return ok
+}
+
+func chanElem(c *ir2.Value) types.Type {
+	return c.Type.Underlying().(*types.Chan).Elem()
+}
+
+// recv receives a value from a channel, which the runtime copies into
+// memory, along with whether the channel is still open
+func (in *Interpreter) recv(fr *frame, instr *ir2.Instr, args []value) error {
+	elem := chanElem(instr.Arg(0))
+	val := in.scratch(fr, instr, -1, uint64(sizes.Sizeof(elem)))
+
+	return in.callRuntime(fr, instr, "chanRecv", []value{args[0], wordValue(val)}, func(results []value) error {
+		in.write(fr, instr.Def(0), in.loadValue(val, elem))
+		if instr.NumDefs() > 1 {
+			in.write(fr, instr.Def(1), results[0])
+		}
+		return nil
+	})
+}
+
+func (in *Interpreter) chanBuiltin(fr *frame, instr *ir2.Instr, name string, args []value) error {
+	switch name {
+	case "len":
+		return in.callRuntime(fr, instr, "chanLen", args[1:2], in.setDefs(fr, instr))
+	case "cap":
+		return in.callRuntime(fr, instr, "chanCap", args[1:2], in.setDefs(fr, instr))
+	case "close":
+		return in.callRuntime(fr, instr, "chanClose", args[1:2], nil)
+	}
+	return fmt.Errorf("%w builtin %s of a channel", errUnsupported, name)
+}
+
+// selectCase runs a select with the runtime, which takes an array of the
+// direction, channel and pointer to the data of each case
+func (in *Interpreter) selectCase(fr *frame, instr *ir2.Instr, args []value) error {
+	states := instr.Args()[1:]
+	numCases := len(states) / 3
+	cases := in.scratch(fr, instr, -1, uint64(numCases*3)*in.wordSize)
+
+	type received struct {
+		def  *ir2.Value
+		addr uint64
+		elem types.Type
+	}
+	var recvs []received
+
+	for i := 0; i < numCases; i++ {
+		dir, c := args[1+i*3], instr.Arg(2+i*3)
+		elem := chanElem(c)
+
+		var data uint64
+		if dir.word == uint64(types.SendOnly) {
+			data = in.box(fr, instr, -2-i, args[3+i*3], elem).word
+		} else {
+			data = in.scratch(fr, instr, -2-i, uint64(sizes.Sizeof(elem)))
+			recvs = append(recvs, received{instr.Def(2 + len(recvs)), data, elem})
+		}
+
+		in.storeWord(cases+uint64(i*3)*in.wordSize, dir.word)
+		in.storeWord(cases+uint64(i*3+1)*in.wordSize, args[2+i*3].word)
+		in.storeWord(cases+uint64(i*3+2)*in.wordSize, data)
+	}
+
+	callArgs := []value{wordValue(cases), wordValue(uint64(numCases)), args[0]}
+	return in.callRuntime(fr, instr, "chanSelect", callArgs, func(results []value) error {
+		in.write(fr, instr.Def(0), results[0])
+		in.write(fr, instr.Def(1), results[1])
+		for _, r := range recvs {
+			if r.def.Type != nil {
+				in.write(fr, r.def, in.loadValue(r.addr, r.elem))
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,131 @@
+package interp
+
+import (
+	"fmt"
+
+	"github.com/rj45/nanogo/ir/reg"
+	"github.com/rj45/nanogo/ir2"
+)
+
+// deferRegs is how many registers the runtime's deferFrame has room for
+const deferRegs = 12
+
+// deferCall defers a call, which the runtime adds to the calls of the
+// frame's defer frame. Elaboration enters the defer frame when the func
+// is called, but before then it's entered when the first call is
+// deferred, which is the same as far as the runtime can tell. Resuming
+// it after a recovered panic goes to the func's recover block.
+func (in *Interpreter) deferCall(fr *frame, instr *ir2.Instr, args []value) error {
+	if fr.fn.Recover == nil {
+		return fmt.Errorf("%w defer in %s, which has no recover block", errUnsupported, fr.fn.FullName)
+	}
+
+	push := func() error {
+		// the deferCall goes before the args
+		heap := inLoop(instr.Block())
+		return in.callWords(fr, instr, args, 3, heap, func(fnval, call uint64) error {
+			in.storeWord(call+in.wordSize, fnval)
+			in.storeWord(call+2*in.wordSize, call+3*in.wordSize)
+			return in.callRuntime(fr, instr, "deferPush", []value{wordValue(fr.deferFrame), wordValue(call)}, nil)
+		})
+	}
+
+	if fr.deferFrame != 0 {
+		return push()
+	}
+
+	f := in.scratch(fr, nil, -1, (deferRegs+2)*in.wordSize)
+	fr.deferFrame = f
+	return in.callRuntime(fr, instr, "deferEnter", []value{wordValue(f)}, func([]value) error {
+		in.saves[f] = &save{
+			task:  in.task,
+			frame: fr,
+			depth: len(in.task.frames),
+			blk:   fr.fn.Recover,
+			regs:  in.task.regs,
+		}
+		return push()
+	})
+}
+
+// goCall starts a goroutine with the runtime
+func (in *Interpreter) goCall(fr *frame, instr *ir2.Instr, args []value) error {
+	return in.callWords(fr, instr, args, 0, true, func(fnval, block uint64) error {
+		return in.callRuntime(fr, instr, "spawn", []value{wordValue(fnval), wordValue(block)}, nil)
+	})
+}
+
+// callWords lays out the func value and args of a deferred call or
+// goroutine as elaboration does, in a block of words for the arg
+// registers, after the given number of words, with the headers of any
+// strings, slices or interfaces after it. The block is on the heap or
+// in the frame. Method calls through an interface have a method ID and
+// the interface in place of the func value, and are looked up first.
+func (in *Interpreter) callWords(fr *frame, instr *ir2.Instr, args []value, before uint64, heap bool, then func(fnval, block uint64) error) error {
+	target := instr.Arg(0)
+	if target.IsConst() && target.Const().Kind() == ir2.IntConst {
+		x := args[1]
+		return in.callRuntime(fr, instr, "lookupMethod", []value{x, args[0]}, func(results []value) error {
+			words := append([]value{wordValue(in.unitsWord(x.units, 1))}, args[2:]...)
+			return in.storeCallWords(fr, instr, results[0].word, words, before, heap, then)
+		})
+	}
+	return in.storeCallWords(fr, instr, args[0].word, args[1:], before, heap, then)
+}
+
+func (in *Interpreter) storeCallWords(fr *frame, instr *ir2.Instr, fnval uint64, words []value, before uint64, heap bool, then func(fnval, block uint64) error) error {
+	if len(words) > len(reg.ArgRegs) {
+		return fmt.Errorf("%w %s with more than %d args", errUnsupported, instr.Op, len(reg.ArgRegs))
+	}
+
+	size := (before + uint64(len(reg.ArgRegs))) * in.wordSize
+	for _, w := range words {
+		size += uint64(len(w.units))
+	}
+
+	store := func(base uint64) error {
+		block := base + before*in.wordSize
+		hdr := block + uint64(len(reg.ArgRegs))*in.wordSize
+		for i, w := range words {
+			word := w.word
+			if w.units != nil {
+				in.mem.write(hdr, w.units)
+				word = hdr
+				hdr += uint64(len(w.units))
+			}
+			in.storeWord(block+uint64(i)*in.wordSize, word)
+		}
+		return then(fnval, base)
+	}
+
+	if !heap {
+		return store(in.scratch(fr, instr, -1, size))
+	}
+	return in.callRuntime(fr, instr, "alloc", []value{wordValue(size)}, func(results []value) error {
+		return store(results[0].word)
+	})
+}
+
+// inLoop returns whether the block is in a loop, where a deferred call
+// is allocated on the heap rather than in the frame
+func inLoop(blk *ir2.Block) bool {
+	seen := make(map[*ir2.Block]bool)
+	work := []*ir2.Block{blk}
+
+	for len(work) > 0 {
+		b := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		for i := 0; i < b.NumSuccs(); i++ {
+			succ := b.Succ(i)
+			if succ == blk {
+				return true
+			}
+			if !seen[succ] {
+				seen[succ] = true
+				work = append(work, succ)
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,438 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"go/types"
+	"math/bits"
+
+	"github.com/rj45/nanogo/ir/reg"
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
+)
+
+// exec runs an instruction in the frame
+func (in *Interpreter) exec(fr *frame, instr *ir2.Instr) error {
+	sem := semantics(instr.Op)
+
+	switch sem.Op {
+	case op.Copy:
+		// copies with several defs are parallel copies
+		vals, err := in.readArgs(fr, instr.Args())
+		if err != nil {
+			return err
+		}
+		for i, def := range instr.Defs() {
+			in.write(fr, def, vals[i])
+		}
+		return nil
+
+	case op.Add, op.Sub, op.AddCarry, op.SubBorrow, op.Mul, op.Div, op.Rem,
+		op.And, op.Or, op.Xor, op.ShiftLeft, op.ShiftRight, op.AndNot:
+		if in.held(instr.Arg(0).Type) {
+			break
+		}
+		return in.binary(fr, instr, sem)
+
+	case op.Not, op.Negate, op.Invert:
+		if in.held(instr.Arg(0).Type) {
+			break
+		}
+		return in.unary(fr, instr, sem)
+
+	case op.Equal, op.NotEqual, op.Less, op.LessEqual, op.Greater, op.GreaterEqual:
+		if in.held(instr.Arg(0).Type) || in.held(instr.Arg(1).Type) {
+			break
+		}
+		cond, err := in.compare(fr, sem.Op, instr.Arg(0), instr.Arg(1), sem)
+		if err != nil {
+			return err
+		}
+		in.write(fr, instr.Def(0), boolValue(cond))
+		return nil
+
+	case op.If:
+		cond, err := in.read(fr, instr.Arg(0))
+		if err != nil {
+			return err
+		}
+		if cond.word != 0 {
+			return in.jump(fr, 0)
+		}
+		return in.jump(fr, 1)
+
+	case op.IfEqual, op.IfNotEqual, op.IfLess, op.IfLessEqual, op.IfGreater, op.IfGreaterEqual:
+		cond, err := in.compare(fr, compareOps[sem.Op], instr.Arg(0), instr.Arg(1), sem)
+		if err != nil {
+			return err
+		}
+		if cond {
+			return in.jump(fr, 0)
+		}
+		return in.jump(fr, 1)
+
+	case op.Jump:
+		return in.jump(fr, 0)
+
+	case op.Call:
+		return in.call(fr, instr)
+
+	case op.Return:
+		return in.ret(fr, instr)
+
+	case op.Load:
+		return in.load(fr, instr, sem)
+
+	case op.Store:
+		return in.store(fr, instr, sem)
+
+	case op.Local:
+		addr, ok := fr.locals[instr]
+		if !ok {
+			elem := instr.Def(0).Type.(*types.Pointer).Elem()
+			addr = in.pushStack(uint64(sizes.Sizeof(elem)))
+			fr.locals[instr] = addr
+		}
+		in.write(fr, instr.Def(0), wordValue(addr))
+		return nil
+
+	case op.Panic:
+		if instr.NumArgs() == 0 {
+			return fmt.Errorf("%w: panicked in %s", ErrAbort, fr.fn.FullName)
+		}
+
+	case op.InlineAsm:
+		return fmt.Errorf("can't interpret assembly of %s", fr.fn.FullName)
+
+	case op.Invalid:
+		return fmt.Errorf("unknown instruction %s", instr.Op)
+	}
+
+	if in.stage < Elaborated {
+		return in.execParsed(fr, instr)
+	}
+	return fmt.Errorf("can't interpret %s once elaborated", instr.Op)
+}
+
+// compareOps are the comparisons the branches on them do
+var compareOps = map[op.Op]op.Op{
+	op.IfEqual:        op.Equal,
+	op.IfNotEqual:     op.NotEqual,
+	op.IfLess:         op.Less,
+	op.IfLessEqual:    op.LessEqual,
+	op.IfGreater:      op.Greater,
+	op.IfGreaterEqual: op.GreaterEqual,
+}
+
+func boolValue(b bool) value {
+	if b {
+		return wordValue(1)
+	}
+	return wordValue(0)
+}
+
+// binary does an arithmetic or logic op on two words, or integers
+// wider than a word
+func (in *Interpreter) binary(fr *frame, instr *ir2.Instr, sem Semantics) error {
+	x, err := in.read(fr, instr.Arg(0))
+	if err != nil {
+		return err
+	}
+	y, err := in.read(fr, instr.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	def := instr.Def(0)
+	a, b := x.word, y.word
+	width := in.bits(def.Type)
+	t := in.task
+	_, generic := instr.Op.(op.Op)
+
+	var r uint64
+	switch sem.Op {
+	case op.Add:
+		r, t.carry = add(a, b, 0, width)
+	case op.AddCarry:
+		r, t.carry = add(a, b, t.carry, width)
+	case op.Sub:
+		r, t.carry = sub(a, b, 0, width)
+	case op.SubBorrow:
+		r, t.carry = sub(a, b, t.carry, width)
+	case op.Mul:
+		r = a * b
+	case op.Div, op.Rem:
+		if in.trunc(b, def.Type) == 0 {
+			if in.stage < Elaborated && fr.checked() {
+				return in.panicError(fr, instr, "integer divide by zero")
+			}
+			return fmt.Errorf("%w: integer divide by zero in %s", ErrAbort, fr.fn.FullName)
+		}
+		if sem.signed(instr.Arg(0)) {
+			sa, sb := in.signed(a, def.Type), in.signed(b, def.Type)
+			if sem.Op == op.Div {
+				r = uint64(sa / sb)
+			} else {
+				r = uint64(sa % sb)
+			}
+		} else if sem.Op == op.Div {
+			r = a / b
+		} else {
+			r = a % b
+		}
+	case op.And:
+		r = a & b
+	case op.Or:
+		r = a | b
+	case op.Xor:
+		r = a ^ b
+	case op.AndNot:
+		r = a &^ b
+	case op.ShiftLeft, op.ShiftRight:
+		r = in.shift(sem, instr, a, b, generic)
+	}
+
+	if generic {
+		r = in.wrap(r, def.Type)
+	}
+	in.write(fr, def, wordValue(r))
+	return nil
+}
+
+// add adds with a carry in, and returns the sum and carry out
+func add(a, b, carry uint64, width uint) (uint64, uint64) {
+	if width >= 64 {
+		return bits.Add64(a, b, carry)
+	}
+	sum := a + b + carry
+	return sum & (1<<width - 1), sum >> width & 1
+}
+
+// sub subtracts with a borrow in, and returns the difference and
+// borrow out
+func sub(a, b, borrow uint64, width uint) (uint64, uint64) {
+	if width >= 64 {
+		return bits.Sub64(a, b, borrow)
+	}
+	diff := a - b - borrow
+	return diff & (1<<width - 1), diff >> width & 1
+}
+
+// shift shifts like Go does for the generic ops, and like the hardware
+// does for the arch's instructions, which only use the low bits of the
+// amount for words
+func (in *Interpreter) shift(sem Semantics, instr *ir2.Instr, a, b uint64, generic bool) uint64 {
+	def := instr.Def(0)
+	signed := sem.Op == op.ShiftRight && sem.signed(def)
+
+	width := in.bits(def.Type)
+	if generic {
+		width = in.typeBits(def.Type)
+	}
+
+	if width == in.wordBits && !generic {
+		b &= uint64(width - 1)
+	} else if b >= uint64(width) {
+		if signed && in.signed(a, def.Type) < 0 {
+			return ^uint64(0)
+		}
+		return 0
+	}
+
+	switch {
+	case sem.Op == op.ShiftLeft:
+		return a << b
+	case signed:
+		return uint64(in.signed(a, def.Type) >> b)
+	}
+	return in.trunc(a, def.Type) >> b
+}
+
+// unary does an op on one word, or integer wider than a word
+func (in *Interpreter) unary(fr *frame, instr *ir2.Instr, sem Semantics) error {
+	x, err := in.read(fr, instr.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var r uint64
+	switch sem.Op {
+	case op.Not:
+		r = x.word ^ 1
+	case op.Negate:
+		r = -x.word
+	case op.Invert:
+		r = ^x.word
+	}
+
+	if _, generic := instr.Op.(op.Op); generic {
+		r = in.wrap(r, instr.Def(0).Type)
+	}
+	in.write(fr, instr.Def(0), wordValue(r))
+	return nil
+}
+
+// compare compares two words, or integers wider than a word
+func (in *Interpreter) compare(fr *frame, o op.Op, x, y *ir2.Value, sem Semantics) (bool, error) {
+	a, err := in.read(fr, x)
+	if err != nil {
+		return false, err
+	}
+	b, err := in.read(fr, y)
+	if err != nil {
+		return false, err
+	}
+
+	typ := x.Type
+	if in.bits(y.Type) > in.bits(typ) {
+		typ = y.Type
+	}
+
+	if o == op.Equal || o == op.NotEqual {
+		eq := in.trunc(a.word, typ) == in.trunc(b.word, typ)
+		return eq == (o == op.Equal), nil
+	}
+
+	var less, equal bool
+	if sem.signed(x) {
+		sa, sb := in.signed(a.word, typ), in.signed(b.word, typ)
+		less, equal = sa < sb, sa == sb
+	} else {
+		ua, ub := in.trunc(a.word, typ), in.trunc(b.word, typ)
+		less, equal = ua < ub, ua == ub
+	}
+
+	switch o {
+	case op.Less:
+		return less, nil
+	case op.LessEqual:
+		return less || equal, nil
+	case op.Greater:
+		return !less && !equal, nil
+	case op.GreaterEqual:
+		return !less, nil
+	}
+	return false, fmt.Errorf("unknown comparison %s", o)
+}
+
+// jump goes to the block's ith successor, passing the args for it
+func (in *Interpreter) jump(fr *frame, i int) error {
+	blk := fr.blk
+	succ := blk.Succ(i)
+
+	// once allocated, the args are already where the succ's defs are
+	if in.stage < Allocated && succ.NumDefs() > 0 {
+		start := blk.SuccArgIndex(i)
+		vals, err := in.readArgs(fr, blk.Args()[start:start+succ.NumDefs()])
+		if err != nil {
+			return err
+		}
+		for d, def := range succ.Defs() {
+			in.write(fr, def, vals[d])
+		}
+	}
+
+	fr.blk = succ
+	fr.index = 0
+	return nil
+}
+
+// address returns the address a load or store accesses, which is a
+// pointer, or a base and offset after simplification
+func (in *Interpreter) address(fr *frame, args []*ir2.Value) (uint64, error) {
+	vals, err := in.readArgs(fr, args)
+	if err != nil {
+		return 0, err
+	}
+
+	addr := vals[0].word
+	if len(vals) > 1 {
+		addr += vals[1].word
+	}
+	return addr & in.mem.addrMask, nil
+}
+
+// access returns how many units a load or store of the type accesses,
+// and the mask for the bits of it that are kept
+func (in *Interpreter) access(typ types.Type, sem Semantics) (uint64, uint64) {
+	if sem.Bits == 0 {
+		n := in.units(typ)
+		if n > 64/uint64(in.mem.unitBits) {
+			n = 64 / uint64(in.mem.unitBits)
+		}
+		return n, ^uint64(0)
+	}
+
+	unitBits := int(in.mem.unitBits)
+	n := uint64((sem.Bits + unitBits - 1) / unitBits)
+	if sem.Bits >= 64 {
+		return n, ^uint64(0)
+	}
+	return n, 1<<sem.Bits - 1
+}
+
+func (in *Interpreter) load(fr *frame, instr *ir2.Instr, sem Semantics) error {
+	addr, err := in.address(fr, instr.Args())
+	if err != nil {
+		return err
+	}
+	if in.stage < Elaborated {
+		if failed, err := in.nilCheck(fr, instr, instr.Arg(0), addr); failed {
+			return err
+		}
+	}
+
+	def := instr.Def(0)
+	if in.held(def.Type) {
+		in.write(fr, def, value{units: in.mem.read(addr, in.units(def.Type))})
+		return nil
+	}
+
+	n, mask := in.access(def.Type, sem)
+	val := in.mem.load(addr, n) & mask
+	if _, generic := instr.Op.(op.Op); generic {
+		val = in.wrap(val, def.Type)
+	}
+	in.write(fr, def, wordValue(val))
+	return nil
+}
+
+func (in *Interpreter) store(fr *frame, instr *ir2.Instr, sem Semantics) error {
+	args := instr.Args()
+	addr, err := in.address(fr, args[:len(args)-1])
+	if err != nil {
+		return err
+	}
+	if in.stage < Elaborated {
+		if failed, err := in.nilCheck(fr, instr, args[0], addr); failed {
+			return err
+		}
+	}
+
+	arg := args[len(args)-1]
+	val, err := in.read(fr, arg)
+	if err != nil {
+		return err
+	}
+
+	if in.held(arg.Type) {
+		in.mem.write(addr, val.units)
+		return nil
+	}
+
+	n, mask := in.access(arg.Type, sem)
+	in.mem.store(addr, n, val.word&mask)
+	return nil
+}
+
+// pushStack allocates space on the stack, for locals before the funcs
+// are finished and allocate them in their frames
+func (in *Interpreter) pushStack(size uint64) uint64 {
+	sp := &in.task.regs[reg.SP.RegNumber()]
+	*sp = (*sp - in.alignWord(size)) & in.mem.addrMask
+	return *sp
+}
+
+// errUnsupported is returned for ops that can't be interpreted
+var errUnsupported = errors.New("can't interpret")
@@ -0,0 +1,109 @@
+package interp
+
+import (
+	"github.com/rj45/nanogo/ir/reg"
+	"github.com/rj45/nanogo/ir2"
+)
+
+// task is a goroutine, or the main program, with its own registers
+// and stack
+type task struct {
+	frames []*frame
+	regs   [64]uint64
+
+	// the carry (or borrow) of the last add or subtract
+	carry uint64
+}
+
+// frame is a call of a func
+type frame struct {
+	in *Interpreter
+	fn *ir2.Func
+
+	// the block running, and the index of the next instr in it
+	blk   *ir2.Block
+	index int
+
+	// the frame of the caller and the call in it, which are nil when
+	// the interpreter made the call
+	caller *frame
+	call   *ir2.Instr
+
+	// the values by ID, for funcs that haven't been allocated registers
+	vals []value
+
+	// the stack pointer when the func was called
+	sp uint64
+
+	// the address of each local, which is allocated on the stack the
+	// first time it's run, before the funcs are finished
+	locals map[*ir2.Instr]uint64
+
+	// what's done with the results of the runtime call the frame made
+	// for an op before elaboration, instead of writing the call's defs
+	then func(results []value) error
+
+	// the scratch memory for the runtime calls made for ops, and the
+	// defer frame, which is entered when the frame first defers a call
+	scratch    map[scratch]uint64
+	deferFrame uint64
+
+	// the stack slots, and the saved registers to restore on return,
+	// for funcs that are allocated but not finished
+	spills   []uint64
+	args     []uint64
+	params   []uint64
+	savedReg []uint64
+}
+
+// argSlot returns the ith arg slot for a call the frame makes
+func (fr *frame) argSlot(i int) uint64 {
+	if fr.in.stage >= Finished {
+		return fr.in.loadWord(fr.in.task.regs[reg.SP.RegNumber()] + uint64(i)*fr.in.wordSize)
+	}
+	fr.growArgs(i)
+	return fr.args[i]
+}
+
+func (fr *frame) setArgSlot(i int, val uint64) {
+	if fr.in.stage >= Finished {
+		fr.in.storeWord(fr.in.task.regs[reg.SP.RegNumber()]+uint64(i)*fr.in.wordSize, val)
+		return
+	}
+	fr.growArgs(i)
+	fr.args[i] = val
+}
+
+func (fr *frame) growArgs(i int) {
+	for len(fr.args) <= i {
+		fr.args = append(fr.args, 0)
+	}
+}
+
+// paramSlot returns the ith param slot, which is the caller's arg slot
+func (fr *frame) paramSlot(i int) uint64 {
+	switch {
+	case fr.in.stage >= Finished:
+		return fr.in.loadWord(fr.sp + uint64(i)*fr.in.wordSize)
+	case fr.caller != nil:
+		return fr.caller.argSlot(i)
+	}
+	for len(fr.params) <= i {
+		fr.params = append(fr.params, 0)
+	}
+	return fr.params[i]
+}
+
+func (fr *frame) setParamSlot(i int, val uint64) {
+	switch {
+	case fr.in.stage >= Finished:
+		fr.in.storeWord(fr.sp+uint64(i)*fr.in.wordSize, val)
+	case fr.caller != nil:
+		fr.caller.setArgSlot(i, val)
+	default:
+		for len(fr.params) <= i {
+			fr.params = append(fr.params, 0)
+		}
+		fr.params[i] = val
+	}
+}
@@ -0,0 +1,193 @@
+// Package interp runs the funcs of an ir2 Program directly, without
+// assembling them, as they are after whichever stage of the pipeline
+// they've been taken through. Running a program after each stage and
+// comparing what it prints shows which stage miscompiled it.
+//
+// Memory is a flat array of min addressable units, laid out like the
+// assembled program: the globals come first, then the heap, with the
+// stack at the top. The funcs aren't in memory, but each has an address
+// so that func values can refer to them.
+//
+// How the values of a func are kept depends on the stage:
+//
+//   - Before elaboration, aggregates like structs, strings and slices
+//     are held in values, and the high level ops that work on them are
+//     done by the interpreter, calling the runtime like the compiled
+//     code would for the ones that need it.
+//   - After elaboration, every value is a word, and each is kept by
+//     its ID until register allocation.
+//   - After register allocation, values are kept in their registers
+//     and stack slots, so the allocation is checked as well. The saved
+//     registers are saved and restored around each call until the
+//     funcs are finished with their prologues and epilogues.
+//
+// Funcs implemented in assembly are emulated by name.
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rj45/nanogo/ir/reg"
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/sizes"
+)
+
+// Stage is how far through the pipeline the funcs of a program have
+// been taken, which decides how their values are kept
+type Stage int
+
+const (
+	// Parsed funcs are as the frontend made them, or after inlining
+	Parsed Stage = iota
+
+	// Elaborated funcs have had their high level ops elaborated into
+	// words, memory accesses and calls to the runtime
+	Elaborated
+
+	// Allocated funcs have their values in registers and stack slots
+	Allocated
+
+	// Finished funcs have stack frames, with prologues and epilogues
+	// that save registers
+	Finished
+)
+
+// ErrAbort is returned when the program stops with an error, such as
+// when it panics
+var ErrAbort = errors.New("program aborted")
+
+// ErrMaxSteps is returned when the program runs too long
+var ErrMaxSteps = errors.New("exceeded maximum step count")
+
+// Interpreter runs a program
+type Interpreter struct {
+	// Out receives what the program prints
+	Out io.Writer
+
+	// Trace receives a line per instruction executed if not nil
+	Trace io.Writer
+
+	// MaxSteps is the maximum number of instructions to run,
+	// or 0 for no limit
+	MaxSteps uint64
+
+	prog  *ir2.Program
+	stage Stage
+	steps uint64
+
+	mem memory
+
+	wordBits uint
+	wordSize uint64
+
+	funcAddrs   map[*ir2.Func]uint64
+	funcs       map[uint64]*ir2.Func
+	globalAddrs map[*ir2.Global]uint64
+	strings     map[string]uint64
+
+	heapStart uint64
+	heapEnd   uint64
+	reserved  uint64
+	stackTop  uint64
+
+	// the task running, and the task of the main program
+	task *task
+	main *task
+
+	// the tasks switched away from, by the handle saved for them
+	switched   map[uint64]*task
+	nextHandle uint64
+
+	// the calls saved by deferSave, by the address of their frame
+	saves map[uint64]*save
+}
+
+// New returns an Interpreter for the program, with its funcs at the stage
+func New(prog *ir2.Program, stage Stage) *Interpreter {
+	in := &Interpreter{
+		prog:        prog,
+		stage:       stage,
+		wordSize:    uint64(sizes.WordSize()),
+		wordBits:    uint(sizes.WordSize()) * uint(sizes.MinAddressableBits()),
+		funcAddrs:   make(map[*ir2.Func]uint64),
+		funcs:       make(map[uint64]*ir2.Func),
+		globalAddrs: make(map[*ir2.Global]uint64),
+		strings:     make(map[string]uint64),
+		switched:    make(map[uint64]*task),
+		saves:       make(map[uint64]*save),
+	}
+	in.mem.init(uint(sizes.MinAddressableBits()), in.wordBits)
+	in.layout()
+	return in
+}
+
+// Steps returns the number of instructions run so far
+func (in *Interpreter) Steps() uint64 {
+	return in.steps
+}
+
+// Run runs the program's init and main funcs like the startup code
+// does, and returns its exit code, or an error if it aborted
+func (in *Interpreter) Run() (int, error) {
+	mainpkg := in.prog.Package("main")
+	if mainpkg == nil {
+		return 1, errors.New("program has no main package")
+	}
+
+	in.main = &task{}
+	in.task = in.main
+	in.main.regs[reg.SP.RegNumber()] = in.stackTop
+
+	for _, name := range []string{"init", "main"} {
+		fn := mainpkg.Func(name)
+		if fn == nil {
+			return 1, fmt.Errorf("program has no main.%s", name)
+		}
+
+		if err := in.enter(fn, 0, nil, nil, noArgs); err != nil {
+			return 1, err
+		}
+
+		for in.task != in.main || len(in.main.frames) > 0 {
+			if err := in.step(); err != nil {
+				return 1, err
+			}
+		}
+	}
+
+	return 0, nil
+}
+
+// step runs the next instruction of the running task
+func (in *Interpreter) step() error {
+	t := in.task
+	if len(t.frames) == 0 {
+		return fmt.Errorf("%w: goroutine returned with no caller", ErrAbort)
+	}
+	fr := t.frames[len(t.frames)-1]
+
+	if fr.index >= fr.blk.NumInstrs() {
+		return fmt.Errorf("%w: ran off the end of %s in %s", ErrAbort, fr.blk, fr.fn.FullName)
+	}
+	instr := fr.blk.Instr(fr.index)
+	fr.index++
+
+	in.steps++
+	if in.MaxSteps != 0 && in.steps > in.MaxSteps {
+		return ErrMaxSteps
+	}
+
+	if in.Trace != nil {
+		fmt.Fprintf(in.Trace, "%s: %s\n", fr.fn.FullName, instr.LongString())
+	}
+
+	if err := in.exec(fr, instr); err != nil {
+		if errors.Is(err, ErrAbort) || errors.Is(err, ErrMaxSteps) {
+			return err
+		}
+		return fmt.Errorf("%s: %s: %w", fr.fn.FullName, instr.LongString(), err)
+	}
+	return nil
+}
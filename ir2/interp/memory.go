@@ -0,0 +1,239 @@
+package interp
+
+import (
+	"go/types"
+	"unicode/utf16"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/sizes"
+)
+
+const pageBits = 12
+
+type page [1 << pageBits]uint16
+
+// memory is a sparse array of min addressable units, with addresses
+// that wrap around at the word size
+type memory struct {
+	pages map[uint64]*page
+
+	unitBits uint
+	unitMask uint64
+	addrMask uint64
+
+	// the page used last, since accesses are often to the same one
+	lastNum  uint64
+	lastPage *page
+}
+
+func (m *memory) init(unitBits, wordBits uint) {
+	m.pages = make(map[uint64]*page)
+	m.unitBits = unitBits
+	m.unitMask = 1<<unitBits - 1
+	m.addrMask = 1<<wordBits - 1
+}
+
+func (m *memory) page(addr uint64) *page {
+	num := addr >> pageBits
+	if m.lastPage != nil && m.lastNum == num {
+		return m.lastPage
+	}
+
+	p := m.pages[num]
+	if p == nil {
+		p = &page{}
+		m.pages[num] = p
+	}
+	m.lastNum = num
+	m.lastPage = p
+	return p
+}
+
+// unit returns the unit at the address
+func (m *memory) unit(addr uint64) uint16 {
+	addr &= m.addrMask
+	return m.page(addr)[addr&(1<<pageBits-1)]
+}
+
+// setUnit sets the unit at the address
+func (m *memory) setUnit(addr uint64, u uint16) {
+	addr &= m.addrMask
+	m.page(addr)[addr&(1<<pageBits-1)] = u
+}
+
+// load reads a little endian value n units long
+func (m *memory) load(addr uint64, n uint64) uint64 {
+	var val uint64
+	for i := n; i > 0; i-- {
+		val = val<<m.unitBits | uint64(m.unit(addr+i-1))
+	}
+	return val
+}
+
+// store writes a little endian value n units long
+func (m *memory) store(addr uint64, n uint64, val uint64) {
+	for i := uint64(0); i < n; i++ {
+		m.setUnit(addr+i, uint16(val&m.unitMask))
+		val >>= m.unitBits
+	}
+}
+
+// read copies n units out of memory
+func (m *memory) read(addr uint64, n uint64) []uint16 {
+	units := make([]uint16, n)
+	for i := range units {
+		units[i] = m.unit(addr + uint64(i))
+	}
+	return units
+}
+
+// write copies the units into memory
+func (m *memory) write(addr uint64, units []uint16) {
+	for i, u := range units {
+		m.setUnit(addr+uint64(i), u)
+	}
+}
+
+// layout gives each func an address, and lays out the globals like the
+// assembler does, followed by the heap, with the stack at the top of
+// memory
+func (in *Interpreter) layout() {
+	// address zero is nil
+	next := uint64(1)
+	var globals []*ir2.Global
+
+	for _, pkg := range in.prog.Packages() {
+		for _, fn := range pkg.Funcs() {
+			in.funcAddrs[fn] = next
+			in.funcs[next] = fn
+			next++
+		}
+		globals = append(globals, pkg.Globals()...)
+	}
+
+	next = in.alignWord(next)
+	for _, glob := range globals {
+		in.globalAddrs[glob] = next
+		next = in.alignWord(next + in.globalSize(glob))
+	}
+
+	for _, glob := range globals {
+		in.initGlobal(glob)
+	}
+
+	// what the interpreter needs in memory goes after the heap
+	top := in.mem.addrMask + 1
+	in.heapStart = next
+	in.heapEnd = top / 2
+	in.reserved = in.heapEnd
+	in.stackTop = top - in.wordSize
+}
+
+// globalSize returns the size of the global in units
+func (in *Interpreter) globalSize(glob *ir2.Global) uint64 {
+	if glob.Words != nil {
+		return uint64(len(glob.Words)) * in.wordSize
+	}
+	if glob.Value == nil {
+		typ := glob.Type
+		if ptr, ok := typ.(*types.Pointer); ok {
+			typ = ptr.Elem()
+		}
+		return uint64(sizes.Sizeof(typ))
+	}
+	if str, ok := ir2.StringValue(glob.Value); ok {
+		return 2*in.wordSize + uint64(len(in.encode(str)))
+	}
+	return in.wordSize
+}
+
+// initGlobal stores the initial value of the global
+func (in *Interpreter) initGlobal(glob *ir2.Global) {
+	addr := in.globalAddrs[glob]
+
+	if glob.Words != nil {
+		for i, word := range glob.Words {
+			in.storeWord(addr+uint64(i)*in.wordSize, in.constWord(word))
+		}
+		return
+	}
+
+	if glob.Value == nil {
+		return
+	}
+
+	if str, ok := ir2.StringValue(glob.Value); ok {
+		units := in.encode(str)
+		in.storeWord(addr, addr+2*in.wordSize)
+		in.storeWord(addr+in.wordSize, uint64(len(units)))
+		in.mem.write(addr+2*in.wordSize, units)
+		return
+	}
+
+	in.storeWord(addr, in.constWord(glob.Value))
+}
+
+// stringHeader returns the address of a string header for the string,
+// which is laid out like a string literal global
+func (in *Interpreter) stringHeader(str string) uint64 {
+	if addr, ok := in.strings[str]; ok {
+		return addr
+	}
+
+	units := in.encode(str)
+	addr := in.reserve(2*in.wordSize + uint64(len(units)))
+	in.storeWord(addr, addr+2*in.wordSize)
+	in.storeWord(addr+in.wordSize, uint64(len(units)))
+	in.mem.write(addr+2*in.wordSize, units)
+
+	in.strings[str] = addr
+	return addr
+}
+
+// encode returns the units of a string, which are UTF-8 bytes, UTF-16
+// or runes depending on the size of the min addressable unit
+func (in *Interpreter) encode(str string) []uint16 {
+	var units []uint16
+	switch in.mem.unitBits {
+	case 16:
+		units = utf16.Encode([]rune(str))
+	default:
+		for i := 0; i < len(str); i++ {
+			units = append(units, uint16(str[i]))
+		}
+	}
+	return units
+}
+
+// decode returns the string in the units
+func (in *Interpreter) decode(units []uint16) string {
+	if in.mem.unitBits == 16 {
+		return string(utf16.Decode(units))
+	}
+
+	buf := make([]byte, len(units))
+	for i, u := range units {
+		buf[i] = byte(u)
+	}
+	return string(buf)
+}
+
+// reserve reserves space for things the interpreter needs in memory,
+// between the heap and the stack
+func (in *Interpreter) reserve(size uint64) uint64 {
+	addr := in.reserved
+	in.reserved = in.alignWord(addr + size)
+	return addr
+}
+
+func (in *Interpreter) alignWord(addr uint64) uint64 {
+	return (addr + in.wordSize - 1) / in.wordSize * in.wordSize
+}
+
+func (in *Interpreter) loadWord(addr uint64) uint64 {
+	return in.mem.load(addr, in.wordSize)
+}
+
+func (in *Interpreter) storeWord(addr uint64, val uint64) {
+	in.mem.store(addr, in.wordSize, val)
+}
@@ -0,0 +1,168 @@
+package interp
+
+import (
+	"fmt"
+
+	"github.com/rj45/nanogo/ir/reg"
+	"github.com/rj45/nanogo/ir2"
+)
+
+// save is what deferSave saves to resume a call with deferResume
+type save struct {
+	task  *task
+	frame *frame
+	depth int
+
+	// the call of deferSave, and where to continue after it, which is
+	// the func's recover block before elaboration
+	call  *ir2.Instr
+	blk   *ir2.Block
+	index int
+
+	regs [64]uint64
+}
+
+// native emulates a func implemented in assembly, which is called from
+// the call in the caller's frame, or by the interpreter when they're nil
+func (in *Interpreter) native(fn *ir2.Func, caller *frame, call *ir2.Instr, args argsFunc) error {
+	if fn.Name == "putc" {
+		// user packages can have their own putc, with a rune
+		if in.Out != nil {
+			in.Out.Write([]byte{byte(args(0).word)})
+		}
+		return in.result(caller, call)
+	}
+
+	if fn.Package().Name != "runtime" {
+		return fmt.Errorf("can't interpret %s, which is implemented in assembly", fn.FullName)
+	}
+
+	switch fn.Name {
+	case "heapStart":
+		return in.result(caller, call, in.heapStart)
+
+	case "heapEnd":
+		return in.result(caller, call, in.heapEnd)
+
+	case "abort":
+		return fmt.Errorf("%w: aborted", ErrAbort)
+
+	case "deferSave":
+		if caller == nil {
+			return fmt.Errorf("deferSave called without a caller")
+		}
+		in.saves[args(0).word] = &save{
+			task:  in.task,
+			frame: caller,
+			depth: len(in.task.frames),
+			call:  call,
+			blk:   caller.blk,
+			index: caller.index,
+			regs:  in.task.regs,
+		}
+		return in.result(caller, call, 0)
+
+	case "deferResume":
+		return in.deferResume(args(0).word)
+
+	case "switchTask":
+		from, to := args(0).word, args(1).word
+		next := in.switched[to]
+		if next == nil {
+			return fmt.Errorf("switch to task 0x%x, which isn't switched out", to)
+		}
+		delete(in.switched, to)
+		in.switchOut(from)
+		in.task = next
+		return in.result(caller, call)
+
+	case "startTask":
+		from, top, start := args(0).word, args(1).word, args(2).word
+		fn, err := in.funcValue(start)
+		if err != nil {
+			return err
+		}
+
+		if err := in.result(caller, call); err != nil {
+			return err
+		}
+		prev := in.task
+		in.switchOut(from)
+
+		// the new task starts with the registers as they were, but its
+		// own stack, and the func value to call in the context register
+		in.task = &task{regs: prev.regs}
+		in.task.regs[reg.SP.RegNumber()] = top
+		in.task.regs[reg.Context.RegNumber()] = start
+		return in.invoke(fn, start, nil, nil, in.regArgs(nil))
+
+	case "callFunc":
+		closure, ptr := args(0).word, args(1).word
+		fn, err := in.funcValue(closure)
+		if err != nil {
+			return err
+		}
+
+		words := make([]value, len(reg.ArgRegs))
+		for i := range words {
+			words[i] = wordValue(in.loadWord(ptr + uint64(i)*in.wordSize))
+			in.task.regs[reg.ArgRegs[i].RegNumber()] = words[i].word
+		}
+		in.task.regs[reg.Context.RegNumber()] = closure
+		return in.invoke(fn, closure, caller, call, listArgs(words))
+
+	default:
+		return fmt.Errorf("can't interpret %s, which is implemented in assembly", fn.FullName)
+	}
+}
+
+// result gives the results of a native func to its caller
+func (in *Interpreter) result(caller *frame, call *ir2.Instr, words ...uint64) error {
+	if caller == nil {
+		return nil
+	}
+	results := make([]value, len(words))
+	for i, w := range words {
+		results[i] = wordValue(w)
+	}
+	return in.deliver(caller, call, results)
+}
+
+// switchOut saves the running task to be switched back to, with its
+// handle saved in from like its stack pointer would be
+func (in *Interpreter) switchOut(from uint64) {
+	in.nextHandle++
+	handle := in.nextHandle * in.wordSize
+	in.switched[handle] = in.task
+	in.storeWord(from, handle)
+}
+
+// deferResume resumes the call that saved the frame with deferSave,
+// returning true from deferSave this time
+func (in *Interpreter) deferResume(f uint64) error {
+	s := in.saves[f]
+	if s == nil || s.task != in.task || len(in.task.frames) < s.depth || in.task.frames[s.depth-1] != s.frame {
+		return fmt.Errorf("deferResume of frame 0x%x, which wasn't saved by a call that's still running", f)
+	}
+
+	t := in.task
+	t.frames = t.frames[:s.depth]
+	s.frame.blk = s.blk
+	s.frame.index = s.index
+
+	// the stack pointer and the registers calls preserve are restored
+	restore := append([]reg.Reg{reg.SP, reg.RA}, reg.SavedRegs...)
+	if reg.FP != reg.None {
+		restore = append(restore, reg.FP)
+	}
+	for _, r := range restore {
+		t.regs[r.RegNumber()] = s.regs[r.RegNumber()]
+	}
+
+	if s.call == nil {
+		// the frame goes on from the recover block instead
+		s.frame.then = nil
+		return nil
+	}
+	return in.result(s.frame, s.call, 1)
+}
@@ -0,0 +1,564 @@
+package interp
+
+import (
+	"fmt"
+	"go/types"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
+)
+
+// execParsed runs the high level ops of funcs before elaboration. Most
+// of them call the runtime like the code elaboration turns them into
+// does, so that the program does the same thing after every stage.
+func (in *Interpreter) execParsed(fr *frame, instr *ir2.Instr) error {
+	args, err := in.readArgs(fr, instr.Args())
+	if err != nil {
+		return err
+	}
+
+	switch instr.Op {
+	case op.FieldAddr:
+		return in.fieldAddr(fr, instr, args)
+
+	case op.IndexAddr:
+		return in.indexAddr(fr, instr, args)
+
+	case op.Index, op.Lookup:
+		if isMapValue(instr.Arg(0)) {
+			return in.mapLookup(fr, instr, args)
+		}
+		return in.stringIndex(fr, instr, args)
+
+	case op.New:
+		size := sizes.Sizeof(pointerElem(instr.Def(0).Type))
+		return in.callRuntime(fr, instr, "alloc", []value{wordValue(uint64(size))}, in.setDefs(fr, instr))
+
+	case op.MakeClosure:
+		return in.makeClosure(fr, instr, args)
+
+	case op.FreeVar:
+		i := int(args[0].word)
+		strct := pointerElem(instr.Arg(1).Type).Underlying().(*types.Struct)
+		offsets := sizes.Offsetsof(sizes.Fieldsof(strct))
+		in.write(fr, instr.Def(0), in.loadValue(args[1].word+uint64(offsets[i+1]), instr.Def(0).Type))
+		return nil
+
+	case op.Convert:
+		return in.convert(fr, instr, args)
+
+	case op.ChangeType, op.ChangeInterface:
+		in.write(fr, instr.Def(0), args[0])
+		return nil
+
+	case op.Equal, op.NotEqual, op.Less, op.LessEqual, op.Greater, op.GreaterEqual:
+		return in.compareHeld(fr, instr, args)
+
+	case op.Add:
+		if isString(instr.Arg(0).Type) {
+			return in.callRuntime(fr, instr, "stringConcat", args, in.setDefs(fr, instr))
+		}
+
+	case op.CallBuiltin:
+		return in.builtin(fr, instr, args)
+
+	case op.MakeInterface:
+		return in.makeInterface(fr, instr, args)
+
+	case op.TypeAssert:
+		name := "typeAssert"
+		if types.IsInterface(instr.Def(0).Type) {
+			name = "interfaceAssert"
+		}
+		if instr.NumDefs() > 1 {
+			name += "Ok"
+		}
+		return in.callRuntime(fr, instr, name, []value{args[1], args[0]}, in.setDefs(fr, instr))
+
+	case op.Invoke:
+		return in.invokeMethod(fr, instr, args)
+
+	case op.Panic:
+		name := "gopanic"
+		if isString(instr.Arg(0).Type) {
+			name = "panicError"
+		}
+		return in.callRuntime(fr, instr, name, []value{args[0], in.panicWhere(instr)}, nil)
+
+	case op.MakeSlice:
+		elem := instr.Def(0).Type.Underlying().(*types.Slice).Elem()
+		size := wordValue(uint64(sizes.Sizeof(elem)))
+		return in.callRuntime(fr, instr, "makeslice", []value{size, args[0], args[1]}, in.setDefs(fr, instr))
+
+	case op.Slice:
+		return in.slice(fr, instr, args)
+
+	case op.MakeMap:
+		return in.makeMap(fr, instr, args)
+
+	case op.MapUpdate:
+		return in.mapUpdate(fr, instr, args)
+
+	case op.Range:
+		return in.rangeIter(fr, instr, args)
+
+	case op.Next:
+		return in.next(fr, instr)
+
+	case op.MakeChan:
+		elem := instr.Def(0).Type.Underlying().(*types.Chan).Elem()
+		size := wordValue(uint64(sizes.Sizeof(elem)))
+		return in.callRuntime(fr, instr, "chanMake", []value{size, args[0]}, in.setDefs(fr, instr))
+
+	case op.Send:
+		ptr := in.box(fr, instr, -1, args[1], chanElem(instr.Arg(0)))
+		return in.callRuntime(fr, instr, "chanSend", []value{args[0], ptr}, nil)
+
+	case op.Recv:
+		return in.recv(fr, instr, args)
+
+	case op.Select:
+		return in.selectCase(fr, instr, args)
+
+	case op.Defer:
+		return in.deferCall(fr, instr, args)
+
+	case op.RunDefers:
+		if fr.deferFrame == 0 {
+			// nothing was deferred
+			return nil
+		}
+		return in.callRuntime(fr, instr, "deferRun", []value{wordValue(fr.deferFrame)}, nil)
+
+	case op.Go:
+		return in.goCall(fr, instr, args)
+	}
+
+	return fmt.Errorf("%w %s before elaboration", errUnsupported, instr.Op)
+}
+
+// setDefs returns a continuation that sets the defs of the instr to the
+// results of the runtime call made for it
+func (in *Interpreter) setDefs(fr *frame, instr *ir2.Instr) func([]value) error {
+	return func(results []value) error {
+		for i, def := range instr.Defs() {
+			if i < len(results) {
+				in.write(fr, def, in.bridge(results[i], def.Type))
+			}
+		}
+		return nil
+	}
+}
+
+// runtimeCall is one of several runtime calls made for an op
+type runtimeCall struct {
+	name string
+	args []value
+}
+
+// callEach makes the runtime calls one after another, and then calls
+// then, if it's not nil
+func (in *Interpreter) callEach(fr *frame, instr *ir2.Instr, calls []runtimeCall, then func() error) error {
+	if len(calls) == 0 {
+		if then != nil {
+			return then()
+		}
+		return nil
+	}
+	return in.callRuntime(fr, instr, calls[0].name, calls[0].args, func([]value) error {
+		return in.callEach(fr, instr, calls[1:], then)
+	})
+}
+
+func pointerElem(typ types.Type) types.Type {
+	return typ.Underlying().(*types.Pointer).Elem()
+}
+
+func isString(typ types.Type) bool {
+	if typ == nil {
+		return false
+	}
+	basic, ok := typ.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsString != 0
+}
+
+// isMapValue returns whether the value is a map, or a nil const, which
+// can only be a nil map where a map or string is expected
+func isMapValue(val *ir2.Value) bool {
+	if val.Type != nil {
+		if _, ok := val.Type.Underlying().(*types.Map); ok {
+			return true
+		}
+	}
+	return val.IsConst() && val.Const().Kind() == ir2.NilConst
+}
+
+// mayBeNil returns whether elaboration adds a nil check for the pointer,
+// which isn't needed for the addresses of things
+func mayBeNil(val *ir2.Value) bool {
+	if _, ok := val.Type.Underlying().(*types.Pointer); !ok {
+		return false
+	}
+	if def := val.Def(); def != nil && def.IsInstr() {
+		switch def.Instr().Op {
+		case op.Local, op.New, op.FieldAddr, op.IndexAddr:
+			return false
+		}
+	}
+	return true
+}
+
+// nilCheck panics if the pointer is nil and elaboration would check it,
+// and returns whether it did
+func (in *Interpreter) nilCheck(fr *frame, instr *ir2.Instr, ptr *ir2.Value, addr uint64) (bool, error) {
+	if addr&in.mem.addrMask != 0 || !fr.checked() || !mayBeNil(ptr) {
+		return false, nil
+	}
+	return true, in.panicError(fr, instr, "invalid memory address or nil pointer dereference")
+}
+
+// boundsCheck panics if the index is out of range and elaboration would
+// check it, and returns whether it did
+func (in *Interpreter) boundsCheck(fr *frame, instr *ir2.Instr, index *ir2.Value, i, length uint64) (bool, error) {
+	if fr.fn.NoBounds {
+		return false, nil
+	}
+
+	out := in.trunc(i, types.Typ[types.Uintptr]) >= in.trunc(length, types.Typ[types.Uintptr])
+	if index.IsConst() {
+		// constants are compared as they are
+		out = in.signed(i, index.Type) >= in.signed(length, types.Typ[types.Uintptr])
+	}
+	if !out {
+		return false, nil
+	}
+	return true, in.panicError(fr, instr, "index out of range")
+}
+
+func (in *Interpreter) fieldAddr(fr *frame, instr *ir2.Instr, args []value) error {
+	ptr := args[1].word
+	if failed, err := in.nilCheck(fr, instr, instr.Arg(1), ptr); failed {
+		return err
+	}
+
+	strct := pointerElem(instr.Arg(1).Type).Underlying().(*types.Struct)
+	offsets := sizes.Offsetsof(sizes.Fieldsof(strct))
+	in.write(fr, instr.Def(0), wordValue(ptr+uint64(offsets[args[0].word])))
+	return nil
+}
+
+func (in *Interpreter) indexAddr(fr *frame, instr *ir2.Instr, args []value) error {
+	x, index := instr.Arg(0), instr.Arg(1)
+	i := args[1].word
+
+	var base uint64
+	switch typ := x.Type.Underlying().(type) {
+	case *types.Slice:
+		base = in.unitsWord(args[0].units, 0)
+		if failed, err := in.boundsCheck(fr, instr, index, i, in.unitsWord(args[0].units, 1)); failed {
+			return err
+		}
+
+	case *types.Pointer:
+		base = args[0].word
+		if failed, err := in.nilCheck(fr, instr, x, base); failed {
+			return err
+		}
+		if array, ok := typ.Elem().Underlying().(*types.Array); ok {
+			if failed, err := in.boundsCheck(fr, instr, index, i, uint64(array.Len())); failed {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("%w index address of %s", errUnsupported, x.Type)
+	}
+
+	size := uint64(sizes.Sizeof(pointerElem(instr.Def(0).Type)))
+	in.write(fr, instr.Def(0), wordValue((base+i*size)&in.mem.addrMask))
+	return nil
+}
+
+// stringIndex loads a byte of a string
+func (in *Interpreter) stringIndex(fr *frame, instr *ir2.Instr, args []value) error {
+	if !isString(instr.Arg(0).Type) {
+		return fmt.Errorf("%w indexing %s", errUnsupported, instr.Arg(0).Type)
+	}
+
+	str, i := args[0].units, args[1].word
+	if failed, err := in.boundsCheck(fr, instr, instr.Arg(1), i, in.unitsWord(str, 1)); failed {
+		return err
+	}
+
+	addr := in.unitsWord(str, 0) + i
+	in.write(fr, instr.Def(0), in.loadValue(addr&in.mem.addrMask, instr.Def(0).Type))
+	return nil
+}
+
+// makeClosure allocates a closure object with the func and the values
+// of its free vars
+func (in *Interpreter) makeClosure(fr *frame, instr *ir2.Instr, args []value) error {
+	var typs []types.Type
+	for _, arg := range instr.Args()[1:] {
+		typs = append(typs, arg.Type)
+	}
+	strct := ir2.ClosureType(typs)
+	offsets := sizes.Offsetsof(sizes.Fieldsof(strct))
+
+	size := wordValue(uint64(sizes.Sizeof(strct)))
+	return in.callRuntime(fr, instr, "alloc", []value{size}, func(results []value) error {
+		addr := results[0].word
+		in.storeWord(addr, args[0].word)
+		for i, typ := range typs {
+			in.storeValue(addr+uint64(offsets[i+1]), args[i+1], typ)
+		}
+		in.write(fr, instr.Def(0), wordValue(addr))
+		return nil
+	})
+}
+
+// convert converts integers like Go does, and strings with the runtime
+func (in *Interpreter) convert(fr *frame, instr *ir2.Instr, args []value) error {
+	from, to := instr.Arg(0).Type, instr.Def(0).Type
+	enc := wordValue(0)
+	if in.mem.unitBits == 16 {
+		enc = wordValue(1)
+	}
+
+	switch {
+	case isString(from) && isString(to):
+
+	case isString(to):
+		if elem, ok := sliceElem(from); ok {
+			if elem == types.Int32 {
+				return in.callRuntime(fr, instr, "stringFromRunes", []value{args[0], enc}, in.setDefs(fr, instr))
+			}
+			return in.callRuntime(fr, instr, "stringFromBytes", args, in.setDefs(fr, instr))
+		}
+		return in.callRuntime(fr, instr, "stringFromRune", []value{args[0], enc}, in.setDefs(fr, instr))
+
+	case isString(from):
+		if elem, _ := sliceElem(to); elem == types.Int32 {
+			return in.callRuntime(fr, instr, "stringToRunes", args, in.setDefs(fr, instr))
+		}
+		return in.callRuntime(fr, instr, "stringToBytes", args, in.setDefs(fr, instr))
+
+	case !in.held(to):
+		in.write(fr, instr.Def(0), wordValue(in.wrap(in.extend(args[0].word, from), to)))
+		return nil
+	}
+
+	in.write(fr, instr.Def(0), args[0])
+	return nil
+}
+
+// extend sign extends a signed integer from the bits it's kept in
+func (in *Interpreter) extend(x uint64, typ types.Type) uint64 {
+	if isSigned(typ) {
+		return uint64(in.signed(x, typ))
+	}
+	return in.trunc(x, typ)
+}
+
+// sliceElem returns the kind of the elements of a slice of a basic type
+func sliceElem(typ types.Type) (types.BasicKind, bool) {
+	slice, ok := typ.Underlying().(*types.Slice)
+	if !ok {
+		return types.Invalid, false
+	}
+	basic, ok := slice.Elem().Underlying().(*types.Basic)
+	if !ok {
+		return types.Invalid, false
+	}
+	return basic.Kind(), true
+}
+
+// compareHeld compares strings and interfaces with the runtime, and
+// slices and other aggregates with nil
+func (in *Interpreter) compareHeld(fr *frame, instr *ir2.Instr, args []value) error {
+	x, y := instr.Arg(0), instr.Arg(1)
+	def := instr.Def(0)
+
+	if isString(x.Type) && isString(y.Type) {
+		name := "stringLess"
+		negate := false
+		switch instr.Op {
+		case op.Equal:
+			name = "stringEqual"
+		case op.NotEqual:
+			name = "stringEqual"
+			negate = true
+		case op.Greater:
+			args[0], args[1] = args[1], args[0]
+		case op.LessEqual:
+			args[0], args[1] = args[1], args[0]
+			negate = true
+		case op.GreaterEqual:
+			negate = true
+		}
+		return in.callRuntime(fr, instr, name, args, func(results []value) error {
+			in.write(fr, def, boolValue((results[0].word != 0) != negate))
+			return nil
+		})
+	}
+
+	if instr.Op != op.Equal && instr.Op != op.NotEqual {
+		return fmt.Errorf("%w %s of %s", errUnsupported, instr.Op, x.Type)
+	}
+	equal := instr.Op == op.Equal
+
+	if isInterface(x.Type) || isInterface(y.Type) {
+		if isNilInterface(x) {
+			args[0], args[1] = args[1], args[0]
+			x, y = y, x
+		}
+		if isNilInterface(y) {
+			nilTyp := in.unitsWord(args[0].units, 0) == 0
+			in.write(fr, def, boolValue(nilTyp == equal))
+			return nil
+		}
+		return in.callRuntime(fr, instr, "interfaceEqual", args, func(results []value) error {
+			in.write(fr, def, boolValue((results[0].word != 0) == equal))
+			return nil
+		})
+	}
+
+	if isSlice(x.Type) || isSlice(y.Type) {
+		// slices can only be compared with nil, by their pointers
+		a, b := in.unitsWord(args[0].units, 0), in.unitsWord(args[1].units, 0)
+		in.write(fr, def, boolValue((a == b) == equal))
+		return nil
+	}
+
+	same := len(args[0].units) == len(args[1].units)
+	for i := 0; same && i < len(args[0].units); i++ {
+		same = args[0].units[i] == args[1].units[i]
+	}
+	in.write(fr, def, boolValue(same == equal))
+	return nil
+}
+
+func isInterface(typ types.Type) bool {
+	return typ != nil && types.IsInterface(typ)
+}
+
+func isSlice(typ types.Type) bool {
+	if typ == nil {
+		return false
+	}
+	_, ok := typ.Underlying().(*types.Slice)
+	return ok
+}
+
+// isNilInterface returns whether the value is the runtime's header of
+// nil interfaces
+func isNilInterface(val *ir2.Value) bool {
+	if !val.IsConst() {
+		return false
+	}
+	glob, ok := ir2.GlobalValue(val.Const())
+	return ok && glob.Package().Name == "runtime" && glob.Name == "nilInterface"
+}
+
+// builtin runs a call of a builtin func
+func (in *Interpreter) builtin(fr *frame, instr *ir2.Instr, args []value) error {
+	name, _ := ir2.StringValue(instr.Arg(0).Const())
+	var x *ir2.Value
+	if instr.NumArgs() > 1 {
+		x = instr.Arg(1)
+	}
+
+	switch {
+	case x != nil && isMapValue(x):
+		return in.mapBuiltin(fr, instr, name, args)
+	case x != nil && isChan(x):
+		return in.chanBuiltin(fr, instr, name, args)
+	}
+
+	switch name {
+	case "len":
+		in.write(fr, instr.Def(0), wordValue(in.unitsWord(args[1].units, 1)))
+		return nil
+
+	case "cap":
+		in.write(fr, instr.Def(0), wordValue(in.unitsWord(args[1].units, 2)))
+		return nil
+
+	case "append":
+		if instr.NumArgs() < 3 {
+			in.write(fr, instr.Def(0), args[1])
+			return nil
+		}
+		size := in.elemSize(x)
+		return in.callRuntime(fr, instr, "sliceAppend", []value{args[1], args[2], size}, in.setDefs(fr, instr))
+
+	case "copy":
+		size := in.elemSize(x)
+		return in.callRuntime(fr, instr, "sliceCopy", []value{args[1], args[2], size}, in.setDefs(fr, instr))
+
+	case "print", "println":
+		var calls []runtimeCall
+		for i, arg := range instr.Args()[1:] {
+			if name == "println" && i != 0 {
+				calls = append(calls, runtimeCall{"printspace", nil})
+			}
+
+			typ := arg.Type.Underlying()
+			if arg.Type == types.Universe.Lookup("rune").Type() {
+				typ = arg.Type
+			}
+			calls = append(calls, runtimeCall{"print" + typ.String(), []value{args[i+1]}})
+		}
+		if name == "println" {
+			calls = append(calls, runtimeCall{"printnl", nil})
+		}
+		return in.callEach(fr, instr, calls, nil)
+
+	case "recover":
+		return in.callRuntime(fr, instr, "gorecover", nil, in.setDefs(fr, instr))
+	}
+
+	return fmt.Errorf("%w builtin %s", errUnsupported, name)
+}
+
+// elemSize returns the size of the elements of a slice
+func (in *Interpreter) elemSize(slice *ir2.Value) value {
+	elem := slice.Type.Underlying().(*types.Slice).Elem()
+	return wordValue(uint64(sizes.Sizeof(elem)))
+}
+
+// makeInterface builds an interface header of the type table and the
+// value, which is boxed if it's held as an aggregate
+func (in *Interpreter) makeInterface(fr *frame, instr *ir2.Instr, args []value) error {
+	table, x := args[0], args[1]
+	if !in.held(instr.Arg(1).Type) {
+		in.write(fr, instr.Def(0), in.wordsValue(table.word, x.word))
+		return nil
+	}
+
+	size := wordValue(uint64(len(x.units)))
+	return in.callRuntime(fr, instr, "alloc", []value{size}, func(results []value) error {
+		data := results[0].word
+		in.mem.write(data, x.units)
+		in.write(fr, instr.Def(0), in.wordsValue(table.word, data))
+		return nil
+	})
+}
+
+// invokeMethod looks up the method in the type table of the value in
+// the interface, and calls it with the data as the receiver
+func (in *Interpreter) invokeMethod(fr *frame, instr *ir2.Instr, args []value) error {
+	id, x := args[0], args[1]
+	return in.callRuntime(fr, instr, "lookupMethod", []value{x, id}, func(results []value) error {
+		entry := results[0].word
+		fn, err := in.funcValue(entry)
+		if err != nil {
+			return err
+		}
+
+		data := wordValue(in.unitsWord(x.units, 1))
+		params := append([]value{data}, args[2:]...)
+		return in.invoke(fn, entry, fr, instr, listArgs(params))
+	})
+}
@@ -0,0 +1,160 @@
+package interp
+
+import (
+	"fmt"
+	"go/types"
+	"path/filepath"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/sizes"
+)
+
+// RuntimeFuncs are the runtime funcs the interpreter calls to run the
+// funcs before elaboration, which have to be parsed along with them
+var RuntimeFuncs = []string{
+	"alloc",
+	"panicError", "gopanic", "gorecover",
+	"deferEnter", "deferPush", "deferRun",
+	"spawn",
+	"lookupMethod", "typeAssert", "typeAssertOk",
+	"interfaceAssert", "interfaceAssertOk", "interfaceEqual",
+	"stringEqual", "stringLess", "stringConcat", "stringSlice", "stringNext",
+	"stringFromBytes", "stringToBytes", "stringFromRunes", "stringToRunes", "stringFromRune",
+	"makeslice", "slice", "sliceBounds", "sliceAppend", "sliceCopy",
+	"hashmapMake", "hashmapLen", "hashmapGet", "hashmapSet", "hashmapDelete", "hashmapNext",
+	"chanMake", "chanLen", "chanCap", "chanSend", "chanRecv", "chanClose", "chanSelect",
+	"printstring", "printrune", "printbool", "printint", "printuint", "printint32", "printuint32",
+	"printint64", "printuint64", "printspace", "printnl",
+}
+
+// scratch is memory a frame uses for an arg of a runtime call it makes
+// for an op, like the locals elaboration adds for them
+type scratch struct {
+	instr *ir2.Instr
+	slot  int
+	size  uint64
+}
+
+// callRuntime calls the runtime func for an op before elaboration, like
+// the op is elaborated into, and passes its results to then
+func (in *Interpreter) callRuntime(fr *frame, instr *ir2.Instr, name string, args []value, then func(results []value) error) error {
+	var fn *ir2.Func
+	if pkg := in.prog.Package("runtime"); pkg != nil {
+		fn = pkg.Func(name)
+	}
+	if fn == nil || fn.NumBlocks() == 0 {
+		return fmt.Errorf("%w %s before elaboration without runtime.%s", errUnsupported, instr.Op, name)
+	}
+
+	// aggregates are passed as pointers to them where the runtime takes
+	// a pointer, like they are once elaborated
+	params := fn.Sig.Params()
+	for i, arg := range args {
+		if arg.units != nil && i < params.Len() && !in.held(params.At(i).Type()) {
+			args[i] = in.box(fr, instr, i, arg, nil)
+		}
+	}
+
+	if then == nil {
+		then = func([]value) error { return nil }
+	}
+	fr.then = then
+	return in.invoke(fn, 0, fr, instr, listArgs(args))
+}
+
+// scratch returns the address of the frame's scratch memory for the
+// slot of the op, which is allocated on the stack the first time
+func (in *Interpreter) scratch(fr *frame, instr *ir2.Instr, slot int, size uint64) uint64 {
+	key := scratch{instr, slot, size}
+	addr, ok := fr.scratch[key]
+	if !ok {
+		if fr.scratch == nil {
+			fr.scratch = make(map[scratch]uint64)
+		}
+		addr = in.pushStack(size)
+		fr.scratch[key] = addr
+	}
+	return addr
+}
+
+// box stores the value of the type in scratch memory and returns a
+// pointer to it, for runtime funcs that take a pointer to it. Values
+// held as units don't need their type.
+func (in *Interpreter) box(fr *frame, instr *ir2.Instr, slot int, v value, typ types.Type) value {
+	if v.units != nil {
+		addr := in.scratch(fr, instr, slot, uint64(len(v.units)))
+		in.mem.write(addr, v.units)
+		return wordValue(addr)
+	}
+
+	size := uint64(sizes.Sizeof(typ))
+	addr := in.scratch(fr, instr, slot, size)
+	in.storeValue(addr, v, typ)
+	return wordValue(addr)
+}
+
+// storeValue stores a value of the type in memory
+func (in *Interpreter) storeValue(addr uint64, v value, typ types.Type) {
+	if in.held(typ) {
+		in.mem.write(addr, v.units)
+		return
+	}
+	in.mem.store(addr, in.units(typ), v.word)
+}
+
+// loadValue loads a value of the type from memory
+func (in *Interpreter) loadValue(addr uint64, typ types.Type) value {
+	if in.held(typ) {
+		return value{units: in.mem.read(addr, in.units(typ))}
+	}
+	return wordValue(in.wrap(in.mem.load(addr, in.units(typ)), typ))
+}
+
+// unitsWord returns the ith word of an aggregate held as units
+func (in *Interpreter) unitsWord(units []uint16, i int) uint64 {
+	var w uint64
+	for u := int(in.wordSize) - 1; u >= 0; u-- {
+		if n := i*int(in.wordSize) + u; n < len(units) {
+			w = w<<in.mem.unitBits | uint64(units[n])
+		}
+	}
+	return w
+}
+
+// wordsValue returns an aggregate held as units made of the words
+func (in *Interpreter) wordsValue(words ...uint64) value {
+	units := make([]uint16, 0, uint64(len(words))*in.wordSize)
+	for _, w := range words {
+		for u := uint64(0); u < in.wordSize; u++ {
+			units = append(units, uint16(w&in.mem.unitMask))
+			w >>= in.mem.unitBits
+		}
+	}
+	return value{units: units}
+}
+
+// panicWhere returns where the instr is for a panic from it, like the
+// string elaboration adds
+func (in *Interpreter) panicWhere(instr *ir2.Instr) value {
+	fn := instr.Func()
+	where := fn.Package().Name + "." + fn.Name
+
+	if fset := in.prog.FileSet; fset != nil && instr.Pos.IsValid() {
+		pos := fset.Position(instr.Pos)
+		where = fmt.Sprintf("%s (%s:%d)", where, filepath.Base(pos.Filename), pos.Line)
+	}
+	return value{units: in.mem.read(in.stringHeader(where), 2*in.wordSize)}
+}
+
+// panicError panics with a runtime error from the instr, like the
+// checks elaboration adds do
+func (in *Interpreter) panicError(fr *frame, instr *ir2.Instr, msg string) error {
+	str := value{units: in.mem.read(in.stringHeader(msg), 2*in.wordSize)}
+	return in.callRuntime(fr, instr, "panicError", []value{str, in.panicWhere(instr)}, nil)
+}
+
+// checked returns whether elaboration adds the runtime checks to the
+// frame's func, which it doesn't for the runtime
+func (fr *frame) checked() bool {
+	return fr.fn.Package().Name != "runtime"
+}
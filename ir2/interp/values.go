@@ -0,0 +1,250 @@
+package interp
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/sizes"
+)
+
+// value is the value of an ir2.Value while the program runs, which is a
+// word, or an integer wider than a word, or the units of an aggregate
+// laid out like it is in memory, before elaboration puts them there
+type value struct {
+	word  uint64
+	units []uint16
+}
+
+func wordValue(w uint64) value {
+	return value{word: w}
+}
+
+// isAggregate returns whether values of the type are held in memory
+// once they've been elaborated, with a pointer to them in their place
+func isAggregate(typ types.Type) bool {
+	switch t := underlying(typ).(type) {
+	case *types.Struct, *types.Array, *types.Slice, *types.Interface:
+		return true
+	case *types.Basic:
+		return t.Kind() == types.String
+	}
+	return false
+}
+
+// underlying returns the underlying type of the type, or nil if it's not
+// a go/types type, like the range iterator's type from go/ssa, which
+// can't be asked for its underlying type
+func underlying(typ types.Type) types.Type {
+	switch typ.(type) {
+	case *types.Basic, *types.Named, *types.Pointer, *types.Struct, *types.Array,
+		*types.Slice, *types.Map, *types.Chan, *types.Signature, *types.Tuple,
+		*types.Interface:
+		return typ.Underlying()
+	}
+	return nil
+}
+
+// isSigned returns whether the type is a signed integer
+func isSigned(typ types.Type) bool {
+	if basic, ok := underlying(typ).(*types.Basic); ok {
+		return basic.Info()&(types.IsInteger|types.IsUnsigned) == types.IsInteger
+	}
+	return false
+}
+
+// held returns whether values of the type are held as units rather
+// than as a word in funcs at the current stage
+func (in *Interpreter) held(typ types.Type) bool {
+	return in.stage < Elaborated && typ != nil && isAggregate(typ)
+}
+
+// units returns the size of values of the type in units, as they're
+// loaded and stored in funcs at the current stage. A range iterator is
+// a pointer to the iterator in memory.
+func (in *Interpreter) units(typ types.Type) uint64 {
+	if underlying(typ) == nil || (in.stage >= Elaborated && isAggregate(typ)) {
+		return in.wordSize
+	}
+	return uint64(sizes.Sizeof(typ))
+}
+
+// bits returns the number of bits integers of the type are kept in.
+// Integers narrower than a word are kept in a word, since arithmetic
+// on them isn't truncated.
+func (in *Interpreter) bits(typ types.Type) uint {
+	if typ != nil {
+		if bits := uint(in.units(typ)) * in.mem.unitBits; bits > in.wordBits && bits <= 64 {
+			return bits
+		}
+	}
+	return in.wordBits
+}
+
+// typeBits returns the number of bits in values of the type
+func (in *Interpreter) typeBits(typ types.Type) uint {
+	if bits := uint(in.units(typ)) * in.mem.unitBits; bits < 64 {
+		return bits
+	}
+	return 64
+}
+
+// wrap wraps the integer around to the size of its type like Go does,
+// and extends it to the bits the type is kept in by its signedness
+func (in *Interpreter) wrap(x uint64, typ types.Type) uint64 {
+	bits := in.typeBits(typ)
+	if bits >= in.bits(typ) {
+		return in.trunc(x, typ)
+	}
+	x &= 1<<bits - 1
+	if isSigned(typ) && x>>(bits-1) != 0 {
+		x |= ^uint64(0) << bits
+	}
+	return in.trunc(x, typ)
+}
+
+// trunc truncates the integer to the number of bits the type is kept in
+func (in *Interpreter) trunc(x uint64, typ types.Type) uint64 {
+	bits := in.bits(typ)
+	if bits >= 64 {
+		return x
+	}
+	return x & (1<<bits - 1)
+}
+
+// signed returns the integer sign extended from the bits the type is
+// kept in
+func (in *Interpreter) signed(x uint64, typ types.Type) int64 {
+	shift := 64 - in.bits(typ)
+	return int64(x<<shift) >> shift
+}
+
+// read returns the value of an arg in the frame, from wherever it's kept
+func (in *Interpreter) read(fr *frame, val *ir2.Value) (value, error) {
+	if val.IsConst() {
+		return in.constValue(val)
+	}
+
+	if in.stage >= Allocated {
+		switch val.Location() {
+		case ir2.InReg:
+			return wordValue(in.task.regs[val.Reg().RegNumber()]), nil
+		case ir2.InSpillSlot:
+			return wordValue(fr.spills[val.SpillSlot()]), nil
+		case ir2.InArgSlot:
+			return wordValue(fr.argSlot(val.ArgSlot())), nil
+		case ir2.InParamSlot:
+			return wordValue(fr.paramSlot(val.ParamSlot())), nil
+		}
+	}
+
+	return fr.vals[val.ID.Index()], nil
+}
+
+// readArgs reads all the args of a user, which is done before writing
+// any defs since their registers can overlap
+func (in *Interpreter) readArgs(fr *frame, args []*ir2.Value) ([]value, error) {
+	vals := make([]value, len(args))
+	for i, arg := range args {
+		v, err := in.read(fr, arg)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+// write sets the value of a def in the frame, wherever it's kept
+func (in *Interpreter) write(fr *frame, val *ir2.Value, v value) {
+	if !in.held(val.Type) {
+		v.word = in.trunc(v.word, val.Type)
+	}
+
+	if in.stage >= Allocated {
+		switch val.Location() {
+		case ir2.InReg:
+			in.task.regs[val.Reg().RegNumber()] = v.word
+			return
+		case ir2.InSpillSlot:
+			fr.spills[val.SpillSlot()] = v.word
+			return
+		case ir2.InArgSlot:
+			fr.setArgSlot(val.ArgSlot(), v.word)
+			return
+		case ir2.InParamSlot:
+			fr.setParamSlot(val.ParamSlot(), v.word)
+			return
+		}
+	}
+
+	fr.vals[val.ID.Index()] = v
+}
+
+// constValue returns the value of a constant arg
+func (in *Interpreter) constValue(val *ir2.Value) (value, error) {
+	c := val.Const()
+
+	switch c.Kind() {
+	case ir2.NilConst:
+		if in.held(val.Type) {
+			return value{units: make([]uint16, in.units(val.Type))}, nil
+		}
+		return wordValue(0), nil
+
+	case ir2.StringConst:
+		str, _ := ir2.StringValue(c)
+		addr := in.stringHeader(str)
+		if in.held(val.Type) {
+			return value{units: in.mem.read(addr, 2*in.wordSize)}, nil
+		}
+		return wordValue(addr), nil
+
+	case ir2.GlobalConst:
+		glob, _ := ir2.GlobalValue(c)
+		addr := in.globalAddrs[glob]
+
+		// before elaboration, a global that isn't a pointer, like a
+		// string literal, stands for its contents
+		if in.held(val.Type) {
+			return value{units: in.mem.read(addr, in.units(val.Type))}, nil
+		}
+		return wordValue(addr), nil
+	}
+
+	return wordValue(in.trunc(in.constWord(c), val.Type)), nil
+}
+
+// constWord returns a constant as the value of a word, like the
+// assembler would
+func (in *Interpreter) constWord(c ir2.Const) uint64 {
+	switch c.Kind() {
+	case ir2.BoolConst:
+		if b, _ := ir2.BoolValue(c); b {
+			return 1
+		}
+		return 0
+	case ir2.IntConst:
+		i, _ := ir2.Int64Value(c)
+		return uint64(i)
+	case ir2.FuncConst:
+		fn, _ := ir2.FuncValue(c)
+		return in.funcAddrs[fn]
+	case ir2.GlobalConst:
+		glob, _ := ir2.GlobalValue(c)
+		return in.globalAddrs[glob]
+	case ir2.StringConst:
+		str, _ := ir2.StringValue(c)
+		return in.stringHeader(str)
+	}
+	return 0
+}
+
+// bridge returns the value as a def of the type holds it. Before
+// elaboration, the runtime passes a pointer to an aggregate where the
+// compiled code would pass the aggregate itself, which is read here.
+func (in *Interpreter) bridge(v value, typ types.Type) value {
+	if in.held(typ) && v.units == nil {
+		return value{units: in.mem.read(v.word&in.mem.addrMask, in.units(typ))}
+	}
+	return v
+}
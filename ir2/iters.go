@@ -52,6 +52,10 @@ type Iter interface {
 	// Update updates the instruction at the cursor position
 	Update(op Op, typ types.Type, args ...interface{}) *Instr
 
+	// SplitBefore splits the Block before the cursor position, and the
+	// cursor follows the current instruction into the new Block
+	SplitBefore() *Block
+
 	// HasChanged returns true if `Changed()` was called, or one of the mutation methods
 	HasChanged() bool
 
@@ -218,6 +222,17 @@ func (it *BlockIter) Update(op Op, typ types.Type, args ...interface{}) *Instr {
 	return instr
 }
 
+// SplitBefore splits the Block before the cursor position, and the
+// cursor follows the current instruction into the new Block
+func (it *BlockIter) SplitBefore() *Block {
+	it.blk = it.blk.SplitAt(it.insIdx)
+	it.insIdx = 0
+
+	it.changed = true
+
+	return it.blk
+}
+
 // inter-block iterator (ie, whole function)
 
 type CrossBlockIter struct {
@@ -306,3 +321,12 @@ func (it *CrossBlockIter) Last() bool {
 	it.insIdx = len(it.blk.instrs) - 1
 	return it.blkIdx >= 0 && it.insIdx >= 0
 }
+
+// SplitBefore splits the Block before the cursor position, and the
+// cursor follows the current instruction into the new Block
+func (it *CrossBlockIter) SplitBefore() *Block {
+	it.BlockIter.SplitBefore()
+	it.blkIdx++
+
+	return it.blk
+}
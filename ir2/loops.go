@@ -0,0 +1,164 @@
+package ir2
+
+import "sort"
+
+// Loop is a natural loop in a Func. It has a single entry, the
+// Header, which dominates the rest of the Blocks in the loop, and
+// one or more Latches, which are the Blocks in the loop that go
+// back to the Header.
+type Loop struct {
+	// Header is the Block the loop starts each iteration at
+	Header *Block
+
+	// Blocks are the Blocks in the loop, including the Header and
+	// the Blocks of the loops inside this one, in dominator order
+	Blocks []*Block
+
+	// Latches are the Blocks in the loop that go back to the Header
+	Latches []*Block
+
+	// Exits are the Blocks outside the loop that it can go to
+	Exits []*Block
+
+	// Preheader is the only Block outside the loop that goes to
+	// the Header, if it does nothing else, or nil if there's none
+	Preheader *Block
+
+	// Parent is the loop this loop is in, or nil if it's outermost
+	Parent *Loop
+
+	// Children are the loops immediately inside this one
+	Children []*Loop
+
+	// Depth is how many loops this one is in, starting from 1
+	Depth int
+
+	contains map[*Block]bool
+}
+
+// Contains returns whether the Block is in the loop
+func (loop *Loop) Contains(blk *Block) bool {
+	return loop.contains[blk]
+}
+
+// Invariant returns whether the Value is the same for each iteration
+// of the loop, because it's a constant or it's defined outside it
+func (loop *Loop) Invariant(val *Value) bool {
+	if val.IsConst() || val.Def() == nil {
+		return true
+	}
+	return !loop.contains[val.Def().Block()]
+}
+
+// LoopNest is the loops in a Func and how they nest inside
+// each other
+type LoopNest struct {
+	loops []*Loop
+
+	// the innermost loop of each Block, by Block ID index
+	innermost []*Loop
+}
+
+// LoopNest finds the loops in the Func with the dominator tree. A
+// loop is found for each Block with a pred it dominates, which goes
+// back to it, and the loops with the same Header are combined.
+func (fn *Func) LoopNest(dt *DomTree) *LoopNest {
+	ln := &LoopNest{innermost: make([]*Loop, fn.NumBlockIDs())}
+
+	// headers are found in dominator order, so loops come before
+	// the loops inside them
+	for _, header := range dt.Preorder() {
+		var latches []*Block
+		for _, pred := range header.preds {
+			if dt.Dominates(header, pred) {
+				latches = append(latches, pred)
+			}
+		}
+		if len(latches) > 0 {
+			ln.loops = append(ln.loops, newLoop(dt, header, latches))
+		}
+	}
+
+	// the loops inside others come later and replace them as the
+	// innermost loop of their blocks
+	for _, loop := range ln.loops {
+		loop.Depth = 1
+		if outer := ln.innermost[loop.Header.Index()]; outer != nil {
+			loop.Parent = outer
+			loop.Depth = outer.Depth + 1
+			outer.Children = append(outer.Children, loop)
+		}
+
+		for _, blk := range loop.Blocks {
+			ln.innermost[blk.Index()] = loop
+		}
+	}
+
+	return ln
+}
+
+// newLoop finds the Blocks in the loop by walking backwards from the
+// latches until the Header, and then its exits and preheader
+func newLoop(dt *DomTree, header *Block, latches []*Block) *Loop {
+	loop := &Loop{
+		Header:   header,
+		Latches:  latches,
+		contains: map[*Block]bool{header: true},
+	}
+
+	work := append([]*Block(nil), latches...)
+	for len(work) > 0 {
+		blk := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		if loop.contains[blk] || !dt.Reachable(blk) {
+			continue
+		}
+		loop.contains[blk] = true
+		work = append(work, blk.preds...)
+	}
+
+	for _, blk := range dt.Preorder() {
+		if loop.contains[blk] {
+			loop.Blocks = append(loop.Blocks, blk)
+		}
+	}
+
+	seen := make(map[*Block]bool)
+	for _, blk := range loop.Blocks {
+		for _, succ := range blk.succs {
+			if !loop.contains[succ] && !seen[succ] {
+				seen[succ] = true
+				loop.Exits = append(loop.Exits, succ)
+			}
+		}
+	}
+
+	var outside []*Block
+	for _, pred := range header.preds {
+		if !loop.contains[pred] {
+			outside = append(outside, pred)
+		}
+	}
+	if len(outside) == 1 && len(outside[0].succs) == 1 {
+		loop.Preheader = outside[0]
+	}
+
+	return loop
+}
+
+// Loops returns the loops, with the loops inside others
+// before the loops they're in
+func (ln *LoopNest) Loops() []*Loop {
+	loops := append([]*Loop(nil), ln.loops...)
+	sort.SliceStable(loops, func(i, j int) bool {
+		return loops[i].Depth > loops[j].Depth
+	})
+	return loops
+}
+
+// LoopFor returns the innermost loop the Block is in,
+// or nil if it's not in one
+func (ln *LoopNest) LoopFor(blk *Block) *Loop {
+	return ln.innermost[blk.Index()]
+}
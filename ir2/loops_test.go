@@ -0,0 +1,87 @@
+package ir2_test
+
+import (
+	"testing"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/parseir"
+)
+
+func TestLoopNest_withNestedLoops(t *testing.T) {
+	fn, err := parseir.ParseString(`
+	.b0:
+		v0:bool = parameter 0
+		jump .b1
+	.b1:
+		if v0, .b2, .b6
+	.b2:
+		jump .b3
+	.b3:
+		if v0, .b4, .b5
+	.b4:
+		jump .b3
+	.b5:
+		jump .b1
+	.b6:
+		return
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blk := make([]*ir2.Block, fn.NumBlocks())
+	for i := range blk {
+		blk[i] = fn.Block(i)
+	}
+
+	ln := fn.LoopNest(fn.DomTree())
+
+	loops := ln.Loops()
+	if len(loops) != 2 {
+		t.Fatalf("expected 2 loops but got %d", len(loops))
+	}
+
+	inner, outer := loops[0], loops[1]
+	if inner.Header != blk[3] || outer.Header != blk[1] {
+		t.Fatalf("expected headers %v and %v but got %v and %v", blk[3], blk[1], inner.Header, outer.Header)
+	}
+
+	if inner.Parent != outer || inner.Depth != 2 || outer.Depth != 1 {
+		t.Errorf("expected %v to be inside %v", inner.Header, outer.Header)
+	}
+
+	if inner.Preheader != blk[2] || outer.Preheader != blk[0] {
+		t.Errorf("expected preheaders %v and %v but got %v and %v", blk[2], blk[0], inner.Preheader, outer.Preheader)
+	}
+
+	if len(inner.Exits) != 1 || inner.Exits[0] != blk[5] {
+		t.Errorf("expected inner loop to exit to %v but got %v", blk[5], inner.Exits)
+	}
+
+	tests := []struct {
+		loop *ir2.Loop
+		blk  int
+		want bool
+	}{
+		{outer, 1, true},
+		{outer, 4, true},
+		{outer, 0, false},
+		{outer, 6, false},
+		{inner, 3, true},
+		{inner, 4, true},
+		{inner, 2, false},
+		{inner, 5, false},
+	}
+	for _, tt := range tests {
+		if got := tt.loop.Contains(blk[tt.blk]); got != tt.want {
+			t.Errorf("expected loop at %v contains %v to be %v", tt.loop.Header, blk[tt.blk], tt.want)
+		}
+	}
+
+	if got := ln.LoopFor(blk[4]); got != inner {
+		t.Errorf("expected innermost loop of %v to be at %v", blk[4], inner.Header)
+	}
+	if got := ln.LoopFor(blk[6]); got != nil {
+		t.Errorf("expected %v to not be in a loop", blk[6])
+	}
+}
@@ -7,11 +7,11 @@ import (
 	"strings"
 )
 
-const _OpName = "invalidbuiltincallcallBuiltinchangeInterfacechangeTypeconstconvertcopyextractfieldfieldAddrfreeVarfuncglobalindexindexAddrinlineAsmlocallookupmakeInterfacemakeSlicenextnewparameterrangeregslicesliceToArrayPointerstoreswapInswapOuttypeAssertaddsubmuldivremandorxorshiftLeftshiftRightandNotequalnotEquallesslessEqualgreatergreaterEqualnotnegateloadinvertjumpifreturnpanicifEqualifNotEqualifLessifLessEqualifGreaterifGreaterEqualnumOps"
+const _OpName = "invalidbuiltincallcallBuiltininvokechangeInterfacechangeTypeconstconvertcopydeferextractfieldfieldAddrfreeVarfuncglobalgoindexindexAddrinlineAsmlocallookupmakeChanmakeClosuremakeInterfacemakeMapmakeSlicemapUpdatenextnewparameterrangerecvregrunDefersselectsendslicesliceToArrayPointerstoreswapInswapOuttypeAssertaddsubaddCarrysubBorrowmuldivremandorxorshiftLeftshiftRightandNotequalnotEquallesslessEqualgreatergreaterEqualnotnegateloadinvertjumpifreturnpanicifEqualifNotEqualifLessifLessEqualifGreaterifGreaterEqualnumOps"
 
-var _OpIndex = [...]uint16{0, 7, 14, 18, 29, 44, 54, 59, 66, 70, 77, 82, 91, 98, 102, 108, 113, 122, 131, 136, 142, 155, 164, 168, 171, 180, 185, 188, 193, 212, 217, 223, 230, 240, 243, 246, 249, 252, 255, 258, 260, 263, 272, 282, 288, 293, 301, 305, 314, 321, 333, 336, 342, 346, 352, 356, 358, 364, 369, 376, 386, 392, 403, 412, 426, 432}
+var _OpIndex = [...]uint16{0, 7, 14, 18, 29, 35, 50, 60, 65, 72, 76, 81, 88, 93, 102, 109, 113, 119, 121, 126, 135, 144, 149, 155, 163, 174, 187, 194, 203, 212, 216, 219, 228, 233, 237, 240, 249, 255, 259, 264, 283, 288, 294, 301, 311, 314, 317, 325, 334, 337, 340, 343, 346, 348, 351, 360, 370, 376, 381, 389, 393, 402, 409, 421, 424, 430, 434, 440, 444, 446, 452, 457, 464, 474, 480, 491, 500, 514, 520}
 
-const _OpLowerName = "invalidbuiltincallcallbuiltinchangeinterfacechangetypeconstconvertcopyextractfieldfieldaddrfreevarfuncglobalindexindexaddrinlineasmlocallookupmakeinterfacemakeslicenextnewparameterrangeregsliceslicetoarraypointerstoreswapinswapouttypeassertaddsubmuldivremandorxorshiftleftshiftrightandnotequalnotequallesslessequalgreatergreaterequalnotnegateloadinvertjumpifreturnpanicifequalifnotequaliflessiflessequalifgreaterifgreaterequalnumops"
+const _OpLowerName = "invalidbuiltincallcallbuiltininvokechangeinterfacechangetypeconstconvertcopydeferextractfieldfieldaddrfreevarfuncglobalgoindexindexaddrinlineasmlocallookupmakechanmakeclosuremakeinterfacemakemapmakeslicemapupdatenextnewparameterrangerecvregrundefersselectsendsliceslicetoarraypointerstoreswapinswapouttypeassertaddsubaddcarrysubborrowmuldivremandorxorshiftleftshiftrightandnotequalnotequallesslessequalgreatergreaterequalnotnegateloadinvertjumpifreturnpanicifequalifnotequaliflessiflessequalifgreaterifgreaterequalnumops"
 
 func (i Op) String() string {
 	if i >= Op(len(_OpIndex)-1) {
@@ -28,70 +28,83 @@ func _OpNoOp() {
 	_ = x[Builtin-(1)]
 	_ = x[Call-(2)]
 	_ = x[CallBuiltin-(3)]
-	_ = x[ChangeInterface-(4)]
-	_ = x[ChangeType-(5)]
-	_ = x[Const-(6)]
-	_ = x[Convert-(7)]
-	_ = x[Copy-(8)]
-	_ = x[Extract-(9)]
-	_ = x[Field-(10)]
-	_ = x[FieldAddr-(11)]
-	_ = x[FreeVar-(12)]
-	_ = x[Func-(13)]
-	_ = x[Global-(14)]
-	_ = x[Index-(15)]
-	_ = x[IndexAddr-(16)]
-	_ = x[InlineAsm-(17)]
-	_ = x[Local-(18)]
-	_ = x[Lookup-(19)]
-	_ = x[MakeInterface-(20)]
-	_ = x[MakeSlice-(21)]
-	_ = x[Next-(22)]
-	_ = x[New-(23)]
-	_ = x[Parameter-(24)]
-	_ = x[Range-(25)]
-	_ = x[Reg-(26)]
-	_ = x[Slice-(27)]
-	_ = x[SliceToArrayPointer-(28)]
-	_ = x[Store-(29)]
-	_ = x[SwapIn-(30)]
-	_ = x[SwapOut-(31)]
-	_ = x[TypeAssert-(32)]
-	_ = x[Add-(33)]
-	_ = x[Sub-(34)]
-	_ = x[Mul-(35)]
-	_ = x[Div-(36)]
-	_ = x[Rem-(37)]
-	_ = x[And-(38)]
-	_ = x[Or-(39)]
-	_ = x[Xor-(40)]
-	_ = x[ShiftLeft-(41)]
-	_ = x[ShiftRight-(42)]
-	_ = x[AndNot-(43)]
-	_ = x[Equal-(44)]
-	_ = x[NotEqual-(45)]
-	_ = x[Less-(46)]
-	_ = x[LessEqual-(47)]
-	_ = x[Greater-(48)]
-	_ = x[GreaterEqual-(49)]
-	_ = x[Not-(50)]
-	_ = x[Negate-(51)]
-	_ = x[Load-(52)]
-	_ = x[Invert-(53)]
-	_ = x[Jump-(54)]
-	_ = x[If-(55)]
-	_ = x[Return-(56)]
-	_ = x[Panic-(57)]
-	_ = x[IfEqual-(58)]
-	_ = x[IfNotEqual-(59)]
-	_ = x[IfLess-(60)]
-	_ = x[IfLessEqual-(61)]
-	_ = x[IfGreater-(62)]
-	_ = x[IfGreaterEqual-(63)]
-	_ = x[NumOps-(64)]
+	_ = x[Invoke-(4)]
+	_ = x[ChangeInterface-(5)]
+	_ = x[ChangeType-(6)]
+	_ = x[Const-(7)]
+	_ = x[Convert-(8)]
+	_ = x[Copy-(9)]
+	_ = x[Defer-(10)]
+	_ = x[Extract-(11)]
+	_ = x[Field-(12)]
+	_ = x[FieldAddr-(13)]
+	_ = x[FreeVar-(14)]
+	_ = x[Func-(15)]
+	_ = x[Global-(16)]
+	_ = x[Go-(17)]
+	_ = x[Index-(18)]
+	_ = x[IndexAddr-(19)]
+	_ = x[InlineAsm-(20)]
+	_ = x[Local-(21)]
+	_ = x[Lookup-(22)]
+	_ = x[MakeChan-(23)]
+	_ = x[MakeClosure-(24)]
+	_ = x[MakeInterface-(25)]
+	_ = x[MakeMap-(26)]
+	_ = x[MakeSlice-(27)]
+	_ = x[MapUpdate-(28)]
+	_ = x[Next-(29)]
+	_ = x[New-(30)]
+	_ = x[Parameter-(31)]
+	_ = x[Range-(32)]
+	_ = x[Recv-(33)]
+	_ = x[Reg-(34)]
+	_ = x[RunDefers-(35)]
+	_ = x[Select-(36)]
+	_ = x[Send-(37)]
+	_ = x[Slice-(38)]
+	_ = x[SliceToArrayPointer-(39)]
+	_ = x[Store-(40)]
+	_ = x[SwapIn-(41)]
+	_ = x[SwapOut-(42)]
+	_ = x[TypeAssert-(43)]
+	_ = x[Add-(44)]
+	_ = x[Sub-(45)]
+	_ = x[AddCarry-(46)]
+	_ = x[SubBorrow-(47)]
+	_ = x[Mul-(48)]
+	_ = x[Div-(49)]
+	_ = x[Rem-(50)]
+	_ = x[And-(51)]
+	_ = x[Or-(52)]
+	_ = x[Xor-(53)]
+	_ = x[ShiftLeft-(54)]
+	_ = x[ShiftRight-(55)]
+	_ = x[AndNot-(56)]
+	_ = x[Equal-(57)]
+	_ = x[NotEqual-(58)]
+	_ = x[Less-(59)]
+	_ = x[LessEqual-(60)]
+	_ = x[Greater-(61)]
+	_ = x[GreaterEqual-(62)]
+	_ = x[Not-(63)]
+	_ = x[Negate-(64)]
+	_ = x[Load-(65)]
+	_ = x[Invert-(66)]
+	_ = x[Jump-(67)]
+	_ = x[If-(68)]
+	_ = x[Return-(69)]
+	_ = x[Panic-(70)]
+	_ = x[IfEqual-(71)]
+	_ = x[IfNotEqual-(72)]
+	_ = x[IfLess-(73)]
+	_ = x[IfLessEqual-(74)]
+	_ = x[IfGreater-(75)]
+	_ = x[IfGreaterEqual-(76)]
+	_ = x[NumOps-(77)]
 }
 
-var _OpValues = []Op{Invalid, Builtin, Call, CallBuiltin, ChangeInterface, ChangeType, Const, Convert, Copy, Extract, Field, FieldAddr, FreeVar, Func, Global, Index, IndexAddr, InlineAsm, Local, Lookup, MakeInterface, MakeSlice, Next, New, Parameter, Range, Reg, Slice, SliceToArrayPointer, Store, SwapIn, SwapOut, TypeAssert, Add, Sub, Mul, Div, Rem, And, Or, Xor, ShiftLeft, ShiftRight, AndNot, Equal, NotEqual, Less, LessEqual, Greater, GreaterEqual, Not, Negate, Load, Invert, Jump, If, Return, Panic, IfEqual, IfNotEqual, IfLess, IfLessEqual, IfGreater, IfGreaterEqual, NumOps}
+var _OpValues = []Op{Invalid, Builtin, Call, CallBuiltin, Invoke, ChangeInterface, ChangeType, Const, Convert, Copy, Defer, Extract, Field, FieldAddr, FreeVar, Func, Global, Go, Index, IndexAddr, InlineAsm, Local, Lookup, MakeChan, MakeClosure, MakeInterface, MakeMap, MakeSlice, MapUpdate, Next, New, Parameter, Range, Recv, Reg, RunDefers, Select, Send, Slice, SliceToArrayPointer, Store, SwapIn, SwapOut, TypeAssert, Add, Sub, AddCarry, SubBorrow, Mul, Div, Rem, And, Or, Xor, ShiftLeft, ShiftRight, AndNot, Equal, NotEqual, Less, LessEqual, Greater, GreaterEqual, Not, Negate, Load, Invert, Jump, If, Return, Panic, IfEqual, IfNotEqual, IfLess, IfLessEqual, IfGreater, IfGreaterEqual, NumOps}
 
 var _OpNameToValueMap = map[string]Op{
 	_OpName[0:7]:          Invalid,
@@ -102,128 +115,154 @@ var _OpNameToValueMap = map[string]Op{
 	_OpLowerName[14:18]:   Call,
 	_OpName[18:29]:        CallBuiltin,
 	_OpLowerName[18:29]:   CallBuiltin,
-	_OpName[29:44]:        ChangeInterface,
-	_OpLowerName[29:44]:   ChangeInterface,
-	_OpName[44:54]:        ChangeType,
-	_OpLowerName[44:54]:   ChangeType,
-	_OpName[54:59]:        Const,
-	_OpLowerName[54:59]:   Const,
-	_OpName[59:66]:        Convert,
-	_OpLowerName[59:66]:   Convert,
-	_OpName[66:70]:        Copy,
-	_OpLowerName[66:70]:   Copy,
-	_OpName[70:77]:        Extract,
-	_OpLowerName[70:77]:   Extract,
-	_OpName[77:82]:        Field,
-	_OpLowerName[77:82]:   Field,
-	_OpName[82:91]:        FieldAddr,
-	_OpLowerName[82:91]:   FieldAddr,
-	_OpName[91:98]:        FreeVar,
-	_OpLowerName[91:98]:   FreeVar,
-	_OpName[98:102]:       Func,
-	_OpLowerName[98:102]:  Func,
-	_OpName[102:108]:      Global,
-	_OpLowerName[102:108]: Global,
-	_OpName[108:113]:      Index,
-	_OpLowerName[108:113]: Index,
-	_OpName[113:122]:      IndexAddr,
-	_OpLowerName[113:122]: IndexAddr,
-	_OpName[122:131]:      InlineAsm,
-	_OpLowerName[122:131]: InlineAsm,
-	_OpName[131:136]:      Local,
-	_OpLowerName[131:136]: Local,
-	_OpName[136:142]:      Lookup,
-	_OpLowerName[136:142]: Lookup,
-	_OpName[142:155]:      MakeInterface,
-	_OpLowerName[142:155]: MakeInterface,
-	_OpName[155:164]:      MakeSlice,
-	_OpLowerName[155:164]: MakeSlice,
-	_OpName[164:168]:      Next,
-	_OpLowerName[164:168]: Next,
-	_OpName[168:171]:      New,
-	_OpLowerName[168:171]: New,
-	_OpName[171:180]:      Parameter,
-	_OpLowerName[171:180]: Parameter,
-	_OpName[180:185]:      Range,
-	_OpLowerName[180:185]: Range,
-	_OpName[185:188]:      Reg,
-	_OpLowerName[185:188]: Reg,
-	_OpName[188:193]:      Slice,
-	_OpLowerName[188:193]: Slice,
-	_OpName[193:212]:      SliceToArrayPointer,
-	_OpLowerName[193:212]: SliceToArrayPointer,
-	_OpName[212:217]:      Store,
-	_OpLowerName[212:217]: Store,
-	_OpName[217:223]:      SwapIn,
-	_OpLowerName[217:223]: SwapIn,
-	_OpName[223:230]:      SwapOut,
-	_OpLowerName[223:230]: SwapOut,
-	_OpName[230:240]:      TypeAssert,
-	_OpLowerName[230:240]: TypeAssert,
-	_OpName[240:243]:      Add,
-	_OpLowerName[240:243]: Add,
-	_OpName[243:246]:      Sub,
-	_OpLowerName[243:246]: Sub,
-	_OpName[246:249]:      Mul,
-	_OpLowerName[246:249]: Mul,
-	_OpName[249:252]:      Div,
-	_OpLowerName[249:252]: Div,
-	_OpName[252:255]:      Rem,
-	_OpLowerName[252:255]: Rem,
-	_OpName[255:258]:      And,
-	_OpLowerName[255:258]: And,
-	_OpName[258:260]:      Or,
-	_OpLowerName[258:260]: Or,
-	_OpName[260:263]:      Xor,
-	_OpLowerName[260:263]: Xor,
-	_OpName[263:272]:      ShiftLeft,
-	_OpLowerName[263:272]: ShiftLeft,
-	_OpName[272:282]:      ShiftRight,
-	_OpLowerName[272:282]: ShiftRight,
-	_OpName[282:288]:      AndNot,
-	_OpLowerName[282:288]: AndNot,
-	_OpName[288:293]:      Equal,
-	_OpLowerName[288:293]: Equal,
-	_OpName[293:301]:      NotEqual,
-	_OpLowerName[293:301]: NotEqual,
-	_OpName[301:305]:      Less,
-	_OpLowerName[301:305]: Less,
-	_OpName[305:314]:      LessEqual,
-	_OpLowerName[305:314]: LessEqual,
-	_OpName[314:321]:      Greater,
-	_OpLowerName[314:321]: Greater,
-	_OpName[321:333]:      GreaterEqual,
-	_OpLowerName[321:333]: GreaterEqual,
-	_OpName[333:336]:      Not,
-	_OpLowerName[333:336]: Not,
-	_OpName[336:342]:      Negate,
-	_OpLowerName[336:342]: Negate,
-	_OpName[342:346]:      Load,
-	_OpLowerName[342:346]: Load,
-	_OpName[346:352]:      Invert,
-	_OpLowerName[346:352]: Invert,
-	_OpName[352:356]:      Jump,
-	_OpLowerName[352:356]: Jump,
-	_OpName[356:358]:      If,
-	_OpLowerName[356:358]: If,
-	_OpName[358:364]:      Return,
-	_OpLowerName[358:364]: Return,
-	_OpName[364:369]:      Panic,
-	_OpLowerName[364:369]: Panic,
-	_OpName[369:376]:      IfEqual,
-	_OpLowerName[369:376]: IfEqual,
-	_OpName[376:386]:      IfNotEqual,
-	_OpLowerName[376:386]: IfNotEqual,
-	_OpName[386:392]:      IfLess,
-	_OpLowerName[386:392]: IfLess,
-	_OpName[392:403]:      IfLessEqual,
-	_OpLowerName[392:403]: IfLessEqual,
-	_OpName[403:412]:      IfGreater,
-	_OpLowerName[403:412]: IfGreater,
-	_OpName[412:426]:      IfGreaterEqual,
-	_OpLowerName[412:426]: IfGreaterEqual,
-	_OpName[426:432]:      NumOps,
-	_OpLowerName[426:432]: NumOps,
+	_OpName[29:35]:        Invoke,
+	_OpLowerName[29:35]:   Invoke,
+	_OpName[35:50]:        ChangeInterface,
+	_OpLowerName[35:50]:   ChangeInterface,
+	_OpName[50:60]:        ChangeType,
+	_OpLowerName[50:60]:   ChangeType,
+	_OpName[60:65]:        Const,
+	_OpLowerName[60:65]:   Const,
+	_OpName[65:72]:        Convert,
+	_OpLowerName[65:72]:   Convert,
+	_OpName[72:76]:        Copy,
+	_OpLowerName[72:76]:   Copy,
+	_OpName[76:81]:        Defer,
+	_OpLowerName[76:81]:   Defer,
+	_OpName[81:88]:        Extract,
+	_OpLowerName[81:88]:   Extract,
+	_OpName[88:93]:        Field,
+	_OpLowerName[88:93]:   Field,
+	_OpName[93:102]:       FieldAddr,
+	_OpLowerName[93:102]:  FieldAddr,
+	_OpName[102:109]:      FreeVar,
+	_OpLowerName[102:109]: FreeVar,
+	_OpName[109:113]:      Func,
+	_OpLowerName[109:113]: Func,
+	_OpName[113:119]:      Global,
+	_OpLowerName[113:119]: Global,
+	_OpName[119:121]:      Go,
+	_OpLowerName[119:121]: Go,
+	_OpName[121:126]:      Index,
+	_OpLowerName[121:126]: Index,
+	_OpName[126:135]:      IndexAddr,
+	_OpLowerName[126:135]: IndexAddr,
+	_OpName[135:144]:      InlineAsm,
+	_OpLowerName[135:144]: InlineAsm,
+	_OpName[144:149]:      Local,
+	_OpLowerName[144:149]: Local,
+	_OpName[149:155]:      Lookup,
+	_OpLowerName[149:155]: Lookup,
+	_OpName[155:163]:      MakeChan,
+	_OpLowerName[155:163]: MakeChan,
+	_OpName[163:174]:      MakeClosure,
+	_OpLowerName[163:174]: MakeClosure,
+	_OpName[174:187]:      MakeInterface,
+	_OpLowerName[174:187]: MakeInterface,
+	_OpName[187:194]:      MakeMap,
+	_OpLowerName[187:194]: MakeMap,
+	_OpName[194:203]:      MakeSlice,
+	_OpLowerName[194:203]: MakeSlice,
+	_OpName[203:212]:      MapUpdate,
+	_OpLowerName[203:212]: MapUpdate,
+	_OpName[212:216]:      Next,
+	_OpLowerName[212:216]: Next,
+	_OpName[216:219]:      New,
+	_OpLowerName[216:219]: New,
+	_OpName[219:228]:      Parameter,
+	_OpLowerName[219:228]: Parameter,
+	_OpName[228:233]:      Range,
+	_OpLowerName[228:233]: Range,
+	_OpName[233:237]:      Recv,
+	_OpLowerName[233:237]: Recv,
+	_OpName[237:240]:      Reg,
+	_OpLowerName[237:240]: Reg,
+	_OpName[240:249]:      RunDefers,
+	_OpLowerName[240:249]: RunDefers,
+	_OpName[249:255]:      Select,
+	_OpLowerName[249:255]: Select,
+	_OpName[255:259]:      Send,
+	_OpLowerName[255:259]: Send,
+	_OpName[259:264]:      Slice,
+	_OpLowerName[259:264]: Slice,
+	_OpName[264:283]:      SliceToArrayPointer,
+	_OpLowerName[264:283]: SliceToArrayPointer,
+	_OpName[283:288]:      Store,
+	_OpLowerName[283:288]: Store,
+	_OpName[288:294]:      SwapIn,
+	_OpLowerName[288:294]: SwapIn,
+	_OpName[294:301]:      SwapOut,
+	_OpLowerName[294:301]: SwapOut,
+	_OpName[301:311]:      TypeAssert,
+	_OpLowerName[301:311]: TypeAssert,
+	_OpName[311:314]:      Add,
+	_OpLowerName[311:314]: Add,
+	_OpName[314:317]:      Sub,
+	_OpLowerName[314:317]: Sub,
+	_OpName[317:325]:      AddCarry,
+	_OpLowerName[317:325]: AddCarry,
+	_OpName[325:334]:      SubBorrow,
+	_OpLowerName[325:334]: SubBorrow,
+	_OpName[334:337]:      Mul,
+	_OpLowerName[334:337]: Mul,
+	_OpName[337:340]:      Div,
+	_OpLowerName[337:340]: Div,
+	_OpName[340:343]:      Rem,
+	_OpLowerName[340:343]: Rem,
+	_OpName[343:346]:      And,
+	_OpLowerName[343:346]: And,
+	_OpName[346:348]:      Or,
+	_OpLowerName[346:348]: Or,
+	_OpName[348:351]:      Xor,
+	_OpLowerName[348:351]: Xor,
+	_OpName[351:360]:      ShiftLeft,
+	_OpLowerName[351:360]: ShiftLeft,
+	_OpName[360:370]:      ShiftRight,
+	_OpLowerName[360:370]: ShiftRight,
+	_OpName[370:376]:      AndNot,
+	_OpLowerName[370:376]: AndNot,
+	_OpName[376:381]:      Equal,
+	_OpLowerName[376:381]: Equal,
+	_OpName[381:389]:      NotEqual,
+	_OpLowerName[381:389]: NotEqual,
+	_OpName[389:393]:      Less,
+	_OpLowerName[389:393]: Less,
+	_OpName[393:402]:      LessEqual,
+	_OpLowerName[393:402]: LessEqual,
+	_OpName[402:409]:      Greater,
+	_OpLowerName[402:409]: Greater,
+	_OpName[409:421]:      GreaterEqual,
+	_OpLowerName[409:421]: GreaterEqual,
+	_OpName[421:424]:      Not,
+	_OpLowerName[421:424]: Not,
+	_OpName[424:430]:      Negate,
+	_OpLowerName[424:430]: Negate,
+	_OpName[430:434]:      Load,
+	_OpLowerName[430:434]: Load,
+	_OpName[434:440]:      Invert,
+	_OpLowerName[434:440]: Invert,
+	_OpName[440:444]:      Jump,
+	_OpLowerName[440:444]: Jump,
+	_OpName[444:446]:      If,
+	_OpLowerName[444:446]: If,
+	_OpName[446:452]:      Return,
+	_OpLowerName[446:452]: Return,
+	_OpName[452:457]:      Panic,
+	_OpLowerName[452:457]: Panic,
+	_OpName[457:464]:      IfEqual,
+	_OpLowerName[457:464]: IfEqual,
+	_OpName[464:474]:      IfNotEqual,
+	_OpLowerName[464:474]: IfNotEqual,
+	_OpName[474:480]:      IfLess,
+	_OpLowerName[474:480]: IfLess,
+	_OpName[480:491]:      IfLessEqual,
+	_OpLowerName[480:491]: IfLessEqual,
+	_OpName[491:500]:      IfGreater,
+	_OpLowerName[491:500]: IfGreater,
+	_OpName[500:514]:      IfGreaterEqual,
+	_OpLowerName[500:514]: IfGreaterEqual,
+	_OpName[514:520]:      NumOps,
+	_OpLowerName[514:520]: NumOps,
 }
 
 var _OpNames = []string{
@@ -231,67 +270,80 @@ var _OpNames = []string{
 	_OpName[7:14],
 	_OpName[14:18],
 	_OpName[18:29],
-	_OpName[29:44],
-	_OpName[44:54],
-	_OpName[54:59],
-	_OpName[59:66],
-	_OpName[66:70],
-	_OpName[70:77],
-	_OpName[77:82],
-	_OpName[82:91],
-	_OpName[91:98],
-	_OpName[98:102],
-	_OpName[102:108],
-	_OpName[108:113],
-	_OpName[113:122],
-	_OpName[122:131],
-	_OpName[131:136],
-	_OpName[136:142],
-	_OpName[142:155],
-	_OpName[155:164],
-	_OpName[164:168],
-	_OpName[168:171],
-	_OpName[171:180],
-	_OpName[180:185],
-	_OpName[185:188],
-	_OpName[188:193],
-	_OpName[193:212],
-	_OpName[212:217],
-	_OpName[217:223],
-	_OpName[223:230],
-	_OpName[230:240],
-	_OpName[240:243],
-	_OpName[243:246],
-	_OpName[246:249],
-	_OpName[249:252],
-	_OpName[252:255],
-	_OpName[255:258],
-	_OpName[258:260],
-	_OpName[260:263],
-	_OpName[263:272],
-	_OpName[272:282],
-	_OpName[282:288],
-	_OpName[288:293],
-	_OpName[293:301],
-	_OpName[301:305],
-	_OpName[305:314],
-	_OpName[314:321],
-	_OpName[321:333],
-	_OpName[333:336],
-	_OpName[336:342],
-	_OpName[342:346],
-	_OpName[346:352],
-	_OpName[352:356],
-	_OpName[356:358],
-	_OpName[358:364],
-	_OpName[364:369],
-	_OpName[369:376],
-	_OpName[376:386],
-	_OpName[386:392],
-	_OpName[392:403],
-	_OpName[403:412],
-	_OpName[412:426],
-	_OpName[426:432],
+	_OpName[29:35],
+	_OpName[35:50],
+	_OpName[50:60],
+	_OpName[60:65],
+	_OpName[65:72],
+	_OpName[72:76],
+	_OpName[76:81],
+	_OpName[81:88],
+	_OpName[88:93],
+	_OpName[93:102],
+	_OpName[102:109],
+	_OpName[109:113],
+	_OpName[113:119],
+	_OpName[119:121],
+	_OpName[121:126],
+	_OpName[126:135],
+	_OpName[135:144],
+	_OpName[144:149],
+	_OpName[149:155],
+	_OpName[155:163],
+	_OpName[163:174],
+	_OpName[174:187],
+	_OpName[187:194],
+	_OpName[194:203],
+	_OpName[203:212],
+	_OpName[212:216],
+	_OpName[216:219],
+	_OpName[219:228],
+	_OpName[228:233],
+	_OpName[233:237],
+	_OpName[237:240],
+	_OpName[240:249],
+	_OpName[249:255],
+	_OpName[255:259],
+	_OpName[259:264],
+	_OpName[264:283],
+	_OpName[283:288],
+	_OpName[288:294],
+	_OpName[294:301],
+	_OpName[301:311],
+	_OpName[311:314],
+	_OpName[314:317],
+	_OpName[317:325],
+	_OpName[325:334],
+	_OpName[334:337],
+	_OpName[337:340],
+	_OpName[340:343],
+	_OpName[343:346],
+	_OpName[346:348],
+	_OpName[348:351],
+	_OpName[351:360],
+	_OpName[360:370],
+	_OpName[370:376],
+	_OpName[376:381],
+	_OpName[381:389],
+	_OpName[389:393],
+	_OpName[393:402],
+	_OpName[402:409],
+	_OpName[409:421],
+	_OpName[421:424],
+	_OpName[424:430],
+	_OpName[430:434],
+	_OpName[434:440],
+	_OpName[440:444],
+	_OpName[444:446],
+	_OpName[446:452],
+	_OpName[452:457],
+	_OpName[457:464],
+	_OpName[464:474],
+	_OpName[474:480],
+	_OpName[480:491],
+	_OpName[491:500],
+	_OpName[500:514],
+	_OpName[514:520],
 }
 
 // OpString retrieves an enum value from the enum constants string name.
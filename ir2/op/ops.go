@@ -47,6 +47,10 @@ func (op Op) IsBranch() bool {
 	return opDefs[op]&branch != 0
 }
 
+func (op Op) IsReturn() bool {
+	return op == Return
+}
+
 func (op Op) Opposite() Op {
 	switch op {
 	case Equal:
@@ -70,29 +74,40 @@ const (
 	Builtin
 	Call
 	CallBuiltin
+	Invoke
 	ChangeInterface
 	ChangeType
 	Const
 	Convert
 	Copy
+	Defer
 	Extract
 	Field
 	FieldAddr
 	FreeVar
 	Func
 	Global
+	Go
 	Index
 	IndexAddr
 	InlineAsm
 	Local
 	Lookup
+	MakeChan
+	MakeClosure
 	MakeInterface
+	MakeMap
 	MakeSlice
+	MapUpdate
 	Next
 	New
 	Parameter
 	Range
+	Recv
 	Reg
+	RunDefers
+	Select
+	Send
 	Slice
 	SliceToArrayPointer
 	Store
@@ -101,6 +116,12 @@ const (
 	TypeAssert
 	Add
 	Sub
+
+	// these take the carry (or borrow) from the instr right before
+	// them, and are used to chain together multi-word arithmetic
+	AddCarry
+	SubBorrow
+
 	Mul
 	Div
 	Rem
@@ -146,7 +167,13 @@ var opDefs = [...]def{
 	Global:       constant,
 	Reg:          move,
 	Store:        sink,
+	MapUpdate:    sink,
+	Go:           sink,
+	Defer:        sink,
+	RunDefers:    sink,
+	Send:         sink,
 	Add:          commute,
+	AddCarry:     commute,
 	Mul:          commute,
 	And:          commute,
 	Or:           commute,
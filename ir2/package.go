@@ -3,6 +3,7 @@ package ir2
 import (
 	"fmt"
 	"go/types"
+	"hash/fnv"
 	"strings"
 )
 
@@ -77,14 +78,60 @@ func (pkg *Package) NewStringLiteral(funcname, str string) *Global {
 	}
 
 	// move to building a global as the string literal
-	name := pkg.makeUnique(funcname)
+	var name string
+	if pkg.prog.Separate {
+		name = contentName("str", str)
+	} else {
+		name = pkg.makeUnique(funcname)
+	}
 	glob = pkg.NewGlobal(name, types.Typ[types.String])
 	glob.Value = ConstFor(str)
+	glob.Shared = pkg.prog.Separate
 	pkg.prog.registerStringLiteral(glob)
 
 	return glob
 }
 
+// NewFuncValue creates a global closure object for a func that is used
+// as a value. It has no free variables, so one can be shared by every use.
+func (pkg *Package) NewFuncValue(fn *Func) *Global {
+	name := fn.Name + "$value"
+	if glob := pkg.Global(name); glob != nil {
+		return glob
+	}
+
+	glob := pkg.NewGlobal(name, types.NewPointer(ClosureType(nil)))
+	glob.Value = ConstFor(fn)
+	glob.Shared = true
+
+	return glob
+}
+
+// NewTable creates a global holding a table of words, named after
+// what it's for
+func (pkg *Package) NewTable(name string, words []Const) *Global {
+	typ := types.NewPointer(types.NewArray(types.Typ[types.Uintptr], int64(len(words))))
+
+	if pkg.prog.Separate {
+		contents := make([]string, len(words))
+		for i, word := range words {
+			contents[i] = word.String()
+		}
+		name = contentName(name, contents...)
+		if glob := pkg.Global(name); glob != nil {
+			return glob
+		}
+	} else {
+		name = pkg.makeUnique(name)
+	}
+
+	glob := pkg.NewGlobal(name, typ)
+	glob.Words = words
+	glob.Shared = pkg.prog.Separate
+
+	return glob
+}
+
 // Globals returns a copy of the global list
 func (pkg *Package) Globals() []*Global {
 	return append([]*Global(nil), pkg.globals...)
@@ -147,8 +194,23 @@ func (pkg *Package) makeUnique(name string) string {
 	}
 }
 
+// contentName names a global generated for its contents, so that the
+// same one generated for different packages has the same name
+func contentName(name string, contents ...string) string {
+	h := fnv.New64a()
+	for _, c := range contents {
+		h.Write([]byte(c))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%s_%016x", name, h.Sum64())
+}
+
+// labelReplacer turns names like `(*T).Method`, `main$1` and `init#1`
+// into something that can be used as a label
+var labelReplacer = strings.NewReplacer("$", "_", "#", "_", "(*", "ptr_", "(", "", ")", "", ".", "_")
+
 func (pkg *Package) genUniqueName(name string) string {
-	name = strings.ReplaceAll(name, "$", "_")
+	name = labelReplacer.Replace(name)
 	parts := strings.Split(pkg.Path, "/")
 	fullName := fmt.Sprintf("%s__%s", pkg.Name, name)
 	for pkg.prog.takenNames[fullName] {
@@ -0,0 +1,18 @@
+package parseir
+
+import "github.com/rj45/nanogo/ir2"
+
+// Arch is the architecture IR that's been lowered to its instructions
+// is parsed for
+type Arch interface {
+	Name() string
+
+	// ParseOp returns the instruction with the name, if there is one
+	ParseOp(name string) (ir2.Op, bool)
+}
+
+var arch Arch
+
+func SetArch(a Arch) {
+	arch = a
+}
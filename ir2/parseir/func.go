@@ -2,8 +2,12 @@ package parseir
 
 import (
 	"go/token"
+	"go/types"
+	"regexp"
+	"strconv"
 	"strings"
 
+	"github.com/rj45/nanogo/ir/reg"
 	"github.com/rj45/nanogo/ir2"
 )
 
@@ -12,19 +16,25 @@ func (p *Parser) parseFunc() {
 		defer un(trace(p, "func"))
 	}
 
-	// Read a func label
-	name := p.parseLabel(nil)
+	if p.declaring {
+		p.declareFunc()
+		p.skipDecl(true)
+		return
+	}
 
-	parts := strings.Split(name, "__")
-	name = parts[len(parts)-1]
+	// the header was parsed when the func was declared
+	p.fn = p.funcs[0]
+	p.funcs = p.funcs[1:]
+	p.skipHeader()
 
-	p.fn = p.pkg.NewFunc(name, nil)
-	p.fn.Referenced = true // todo: fixme with correct value
 	p.blk = nil
 
 	p.blkLabels = make(map[string]*ir2.Block)
 	p.values = make(map[string]*ir2.Value)
-	p.blkLinks = make(map[*ir2.Block][]string)
+	p.valueIDs = make(map[*ir2.Value]int)
+	p.idLabels = make(map[int]string)
+	p.blockIDs = make(map[*ir2.Block]int)
+	p.blkLinks = nil
 
 	for {
 		tok, lit := p.scan()
@@ -33,37 +43,125 @@ func (p *Parser) parseFunc() {
 		case token.PERIOD:
 			p.parseBlock()
 
-		case token.FUNC:
+		case token.FUNC, token.VAR, token.TYPE, token.PACKAGE, token.EOF:
 			p.resolveLinks()
 			p.unscan()
 			return
 
-		case token.EOF:
-			p.resolveLinks()
-			p.unscan()
+		default:
+			p.errorf("found %q, expected func or block label", lit)
+		}
+	}
+}
+
+// declareFunc parses the header of a func and adds it to the package,
+// which is its label, its name if it can't be told from the label,
+// its signature and its attributes:
+//
+//	func main__ptr_T_String "(*T).String"(t *T) string: stage=initial
+func (p *Parser) declareFunc() {
+	label, name := p.parseName()
+
+	sig := types.NewSignatureType(nil, nil, nil, nil, nil, false)
+	if tok, _ := p.scan(); tok == token.LPAREN {
+		p.unscan()
+		sig = p.parseSignature(nil)
+	} else {
+		p.unscan()
+	}
+
+	p.expect(token.COLON, "func header")
+
+	fn := p.pkg.NewFunc(name, sig)
+	if strings.Contains(label, "__") {
+		fn.FullName = label
+	}
+	fn.Referenced = true
+	p.funcs = append(p.funcs, fn)
+
+	for {
+		tok, lit := p.scan()
+		if tok != token.IDENT {
+			if tok != token.SEMICOLON {
+				p.unscan()
+			}
 			return
+		}
 
-		case token.PACKAGE:
-			p.resolveLinks()
+		switch lit {
+		case "stage":
+			p.expect(token.ASSIGN, "stage attribute")
+			_, fn.Stage = p.expect(token.IDENT, "stage attribute")
+		case "elaborated":
+			fn.Elaborated = true
+		case "nobounds":
+			fn.NoBounds = true
+		case "noinline":
+			fn.NoInline = true
+		case "inline":
+			fn.Inline = true
+		case "shared":
+			fn.Shared = true
+		case "recover":
+			p.expect(token.ASSIGN, "recover attribute")
+			p.expect(token.PERIOD, "recover attribute")
+			_, p.recovers[fn] = p.expect(token.IDENT, "recover attribute")
+		default:
+			p.errorf("unknown func attribute %s", lit)
+		}
+	}
+}
+
+// skipHeader skips the header of a func, up to the end of the line
+// it's on
+func (p *Parser) skipHeader() {
+	line := p.fset.Position(p.buf.pos).Line
+	for {
+		tok, _ := p.scan()
+		if tok == token.EOF || p.fset.Position(p.buf.pos).Line != line {
 			p.unscan()
 			return
+		}
+	}
+}
 
-		default:
-			p.errorf("found %q, expected func or block label", lit)
+// parseName parses the label of a func or global, and the name it
+// was given, which is written after it in quotes when it isn't the
+// part of the label after the package name
+func (p *Parser) parseName() (label, name string) {
+	tok, lit := p.scan()
+	if tok != token.IDENT {
+		p.errorf("found %q, expected label", lit)
+	}
+	label = lit
+
+	name = label
+	if i := strings.Index(label, "__"); i >= 0 {
+		name = label[i+2:]
+	}
+
+	if tok, lit := p.scan(); tok == token.STRING {
+		var err error
+		name, err = strconv.Unquote(lit)
+		if err != nil {
+			p.errorf("failed unquoting name %s", lit)
 		}
+	} else {
+		p.unscan()
 	}
+
+	return label, name
 }
 
 func (p *Parser) resolveLinks() {
-	for a, labels := range p.blkLinks {
-		for _, label := range labels {
-			b, ok := p.blkLabels[label]
-			if !ok {
-				p.errorf("unable to resolve block link %s from %s", b, a)
-			}
-			a.AddSucc(b)
-			b.AddPred(a)
+	for _, link := range p.blkLinks {
+		b, ok := p.blkLabels[link.label]
+		if !ok {
+			p.errs.Add(p.fset.Position(link.pos), "unable to resolve block link ."+link.label+" from "+link.blk.String())
+			continue
 		}
+		link.blk.AddSucc(b)
+		b.AddPred(link.blk)
 	}
 
 	for _, label := range p.fn.PlaceholderLabels() {
@@ -74,6 +172,17 @@ func (p *Parser) resolveLinks() {
 		}
 		p.fn.ResolvePlaceholder(label, v)
 	}
+
+	if label, ok := p.recovers[p.fn]; ok {
+		p.fn.Recover = p.blkLabels[label]
+		if p.fn.Recover == nil {
+			p.errorf("unable to resolve recover block .%s in %s", label, p.fn.FullName)
+		}
+	}
+
+	// give the values and blocks the IDs in their labels, so they're
+	// written out the same as they were read in
+	p.fn.Renumber(p.valueIDs, p.blockIDs)
 }
 
 func (p *Parser) parseBlock() {
@@ -86,33 +195,28 @@ func (p *Parser) parseBlock() {
 	// Read a block label
 	name := p.parseLabel(p.blk)
 
+	if p.blkLabels[name] != nil {
+		p.errorf("block .%s is defined twice", name)
+	}
 	p.blkLabels[name] = p.blk
+	if m := blockRefRe.FindStringSubmatch(name); m != nil {
+		id, _ := strconv.Atoi(m[1])
+		p.blockIDs[p.blk] = id
+	}
 	p.fn.InsertBlock(-1, p.blk)
 
 	for {
 		tok, lit := p.scan()
 
 		switch tok {
-		case token.PERIOD:
+		case token.PERIOD, token.FUNC, token.VAR, token.TYPE, token.PACKAGE, token.EOF:
 			p.unscan()
 			return
 
-		case token.FUNC:
-			p.unscan()
-			return
-
-		case token.IDENT, token.IF, token.RETURN:
+		case token.IDENT, token.IF, token.RETURN, token.RANGE, token.GO, token.DEFER, token.SELECT, token.CONST:
 			p.unscan()
 			p.parseInstr()
 
-		case token.PACKAGE:
-			p.unscan()
-			return
-
-		case token.EOF:
-			p.unscan()
-			return
-
 		default:
 			p.errorf("found %q, expected block label or instr", lit)
 		}
@@ -134,15 +238,23 @@ func (p *Parser) parseLabel(blk *ir2.Block) string {
 	tok, lit = p.scan()
 
 	if tok == token.LPAREN && blk != nil {
-		p.unscan()
-		list := p.parseParenList()
-
-		for _, v := range list {
-			val := blk.Func().NewValue(v.typ)
-
-			p.values[v.lit] = val
+		for {
+			tok, lit := p.scan()
+			if tok == token.RPAREN {
+				break
+			}
+			if tok != token.IDENT {
+				p.errorf("found %q, expected block param", lit)
+				break
+			}
 
+			val := p.defValue(lit, p.parseColonType())
 			blk.AddDef(val)
+			p.setLocation(val, lit)
+
+			if tok, _ := p.scan(); tok != token.COMMA {
+				p.unscan()
+			}
 		}
 
 		tok, lit = p.scan()
@@ -154,3 +266,67 @@ func (p *Parser) parseLabel(blk *ir2.Block) string {
 
 	return label
 }
+
+var blockRefRe = regexp.MustCompile(`^b(\d+)$`)
+
+var valueRefRe = regexp.MustCompile(`^v(\d+)(?:_(\w+))?$`)
+
+// slotRe matches the arg, param and spill slots in value labels
+var slotRe = regexp.MustCompile(`^s([aps])(\d+)$`)
+
+// defValue creates the Value defined with a label, which is given the
+// ID in the label
+func (p *Parser) defValue(label string, typ types.Type) *ir2.Value {
+	if p.values[label] != nil {
+		p.errorf("value %s is defined twice", label)
+	}
+
+	val := p.fn.NewValue(typ)
+	p.values[label] = val
+
+	if m := valueRefRe.FindStringSubmatch(label); m != nil {
+		id, _ := strconv.Atoi(m[1])
+		if other, ok := p.idLabels[id]; ok {
+			p.errorf("value %s has the same ID as %s", label, other)
+		}
+		p.idLabels[id] = label
+		p.valueIDs[val] = id
+	}
+
+	return val
+}
+
+// setLocation puts the Value in the register or stack slot in its
+// label, once it's been defined, since that's needed for its func
+func (p *Parser) setLocation(val *ir2.Value, label string) {
+	m := valueRefRe.FindStringSubmatch(label)
+	if m == nil || m[2] == "" {
+		return
+	}
+	loc := m[2]
+
+	if r := reg.Named(loc); r != reg.None {
+		val.SetReg(r)
+		return
+	}
+
+	s := slotRe.FindStringSubmatch(loc)
+	if s == nil {
+		p.errorf("unknown location %s of value %s", loc, label)
+		return
+	}
+	if val.Def() == nil {
+		p.errorf("value %s nothing defines can't be in a stack slot", label)
+		return
+	}
+
+	slot, _ := strconv.Atoi(s[2])
+	switch s[1] {
+	case "a":
+		val.SetArgSlot(slot)
+	case "p":
+		val.SetParamSlot(slot)
+	case "s":
+		val.SetSpillSlot(slot)
+	}
+}
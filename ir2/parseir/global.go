@@ -8,93 +8,129 @@ import (
 	"github.com/rj45/nanogo/ir2"
 )
 
+// globalRef is a ref to a func or global in the initial value of a
+// global, which is resolved once they've all been declared
+type globalRef struct {
+	glob  *ir2.Global
+	word  int // or -1 for the Value
+	label string
+	pos   token.Pos
+}
+
+// parseGlobal parses a global, with its initial value, or the value of
+// each of its words, and whether it's shared:
+//
+//	var runtime__typeInfo_1:*[4]uintptr = {51, 1, 2, ^main__T_String} shared
 func (p *Parser) parseGlobal() {
 	if p.trace {
 		defer un(trace(p, "global"))
 	}
 
-	tok, lit := p.scan()
-	if tok != token.IDENT {
-		p.errorf("found %q, expected global name", lit)
-	}
-
-	fullname := lit
-
-	name := strings.Split(fullname, "__")[1]
+	label, name := p.parseName()
 
 	p.expect(token.COLON, "global")
 
 	typ := p.parseType()
 	glob := p.pkg.NewGlobal(name, typ)
-
-	tok, _ = p.scan()
-	if tok == token.SEMICOLON {
-		return
+	if strings.Contains(label, "__") {
+		glob.FullName = label
 	}
+	glob.Referenced = true
+
+	tok, _ := p.scan()
 	if tok == token.ASSIGN {
-		glob.Value = p.parseLiteral()
-		return
+		if tok, _ := p.scan(); tok == token.LBRACE {
+			glob.Words = []ir2.Const{}
+			for {
+				if tok, _ := p.scan(); tok == token.RBRACE {
+					break
+				}
+				p.unscan()
+
+				glob.Words = append(glob.Words, p.parseLiteral(glob, len(glob.Words)))
+
+				if tok, _ := p.scan(); tok != token.COMMA {
+					p.unscan()
+					p.expect(token.RBRACE, "global words")
+					break
+				}
+			}
+		} else {
+			p.unscan()
+			glob.Value = p.parseLiteral(glob, -1)
+		}
+		tok, _ = p.scan()
+	}
+
+	if tok == token.IDENT && p.buf.lit == "shared" {
+		glob.Shared = true
+		tok, _ = p.scan()
+	}
+
+	if tok != token.SEMICOLON {
+		p.unscan()
 	}
-	p.unscan()
 }
 
-func (p *Parser) parseLiteral() ir2.Const {
+// parseLiteral parses the initial value of a global, or one of its
+// words, which refs to funcs and globals are resolved for later
+func (p *Parser) parseLiteral(glob *ir2.Global, word int) ir2.Const {
 	tok, lit := p.scan()
-	var con ir2.Const
 	switch tok {
 	case token.STRING:
 		str, err := strconv.Unquote(lit)
 		if err != nil {
 			p.errorf("failed unquoting string: %s", err.Error())
 		}
-		con = ir2.ConstFor(str)
-	case token.INT:
-		i, err := strconv.Atoi(lit)
+		return ir2.ConstFor(str)
+
+	case token.SUB, token.INT:
+		if tok == token.SUB {
+			_, lit = p.expect(token.INT, "negative int")
+			lit = "-" + lit
+		}
+		i, err := strconv.ParseInt(lit, 0, 64)
 		if err != nil {
 			p.errorf("failed converting to int: %s", err.Error())
 		}
-		con = ir2.ConstFor(i)
-	default:
-		p.errorf("bad literal %q, expecting string or int", lit)
-		return nil
-	}
+		return ir2.ConstFor(i)
+
+	case token.IDENT:
+		switch lit {
+		case "true", "false":
+			return ir2.ConstFor(lit == "true")
+		case "nil":
+			return ir2.ConstFor(nil)
+		}
 
-	tok, _ = p.scan()
-	if tok != token.SEMICOLON {
-		p.unscan()
+	case token.XOR:
+		_, label := p.expect(token.IDENT, "func or global ref")
+		p.refs = append(p.refs, globalRef{glob, word, label, p.buf.pos})
+
+		// filled in once it's resolved
+		return ir2.ConstFor(nil)
 	}
 
-	return con
+	p.errorf("bad literal %q, expecting string, int, bool, nil or ^ref", lit)
+	return ir2.ConstFor(nil)
 }
 
 func (p *Parser) resolveGlobalLinks() {
-	for label, fnvals := range p.globals {
-		for fn, val := range fnvals {
-			glob := p.prog.Global(label)
-			if glob != nil {
-				val.Type = glob.Type
-				val.SetConst(ir2.ConstFor(glob))
-				if p.trace {
-					p.printTrace("resolved", label, "with global", glob)
-				}
-				glob.Referenced = true
-				continue
-			}
-
-			reffn := p.prog.Func(label)
-			if reffn == nil {
-				p.errorf("unable to resolve global link %s in %s", label, fn.FullName)
-				continue
-			}
-
-			reffn.Referenced = true
-
-			val.Type = reffn.Sig
-			val.SetConst(ir2.ConstFor(reffn))
+	for _, ref := range p.refs {
+		var con ir2.Const
+		if fn := p.prog.Func(ref.label); fn != nil {
+			con = ir2.ConstFor(fn)
+		} else if glob := p.prog.Global(ref.label); glob != nil {
+			con = ir2.ConstFor(glob)
+		} else {
+			p.errs.Add(p.fset.Position(ref.pos), "unable to resolve "+ref.label+" in "+ref.glob.FullName)
+			continue
+		}
 
-			if p.trace {
-				p.printTrace("resolved", label, "with func", fn.FullName)
-			}
+		if ref.word < 0 {
+			ref.glob.Value = con
+		} else {
+			ref.glob.Words[ref.word] = con
 		}
 	}
 }
@@ -6,369 +6,324 @@ package parseir
 import (
 	"go/token"
 	"go/types"
-	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/rj45/nanogo/ir2"
 	"github.com/rj45/nanogo/ir2/op"
 )
 
-type typedToken struct {
-	tok   token.Token
-	lit   string
-	typ   types.Type
-	glob  bool
-	block bool
-	args  []typedToken
-}
-
+// parseInstr parses an instr, which is one of:
+//
+//	a, b = op e, f
+//	b = op e
+//	op e, f
+//	op
+//
+// where args can be values, consts, funcs and globals marked with a ^,
+// and blocks marked with a . and followed by their args in parentheses
 func (p *Parser) parseInstr() {
 	if p.trace {
 		defer un(trace(p, "instr"))
 	}
 
-	var list []typedToken
-	var opcode string
-	var last typedToken
-	var defs []typedToken
-
-	for {
-		tok, lit := p.scan()
-
-		// a, b = op e, f
-		// a, b = op e
-		// a, b = op
-		// b = op e, f
-		// b = op e
-		// b = op
-		// op
-		// op e
-		// op e, f
-
-		switch tok {
-		case token.ASSIGN:
-			if last.tok != token.ILLEGAL {
-				list = append(list, last)
-				last.tok = token.ILLEGAL
-			}
-
-			if len(list) == 0 {
-				p.errorf("found %q, expected list", lit)
-			}
-
-			// list is def list
-			defs = list
-			list = nil
-
-		case token.SUB, token.INT, token.IDENT, token.XOR, token.PERIOD, token.RANGE, token.IF, token.RETURN:
-			p.unscan()
+	type def struct {
+		label string
+		typ   types.Type
+	}
+	var defs []def
 
-			if last.tok == token.ILLEGAL {
-				last = p.parseVar()
-			} else if opcode == "" {
-				opcode = last.lit
-				last = p.parseVar()
-			} else {
-				return
-			}
+	tok, lit := p.scan()
+	pos := p.buf.pos
+	oppos := pos
 
-		case token.COMMA:
-			p.unscan()
-			list = p.parseList(last)
-			last.tok = token.ILLEGAL
-
-		case token.SEMICOLON:
-			if opcode == "" {
-				opcode = last.lit
-			} else if last.tok != token.ILLEGAL {
-				list = append(list, last)
+	// the defs come first if there's an = after the first label
+	if tok == token.IDENT {
+		next, _ := p.scan()
+		p.unscan()
+		if next == token.COLON || next == token.COMMA || next == token.ASSIGN {
+			defs = append(defs, def{lit, p.parseColonType()})
+			for {
+				tok, _ := p.scan()
+				if tok == token.ASSIGN {
+					break
+				}
+				if tok != token.COMMA {
+					p.errorf("found %s, expected , or = after def", tok)
+					p.unscan()
+					break
+				}
+				_, lit := p.expect(token.IDENT, "def")
+				defs = append(defs, def{lit, p.parseColonType()})
 			}
-
-			p.addInstr(defs, opcode, list)
-			return
-
-		default:
-			p.errorf("found %s %q, expected instr", tok, lit)
+			tok, lit = p.scan()
+			oppos = p.buf.pos
 		}
 	}
-}
 
-func (p *Parser) parseVar() typedToken {
-	if p.trace {
-		defer un(trace(p, "var"))
+	opv := p.parseOp(tok, lit, oppos)
+	if opv == nil {
+		p.skipLine()
+		return
 	}
 
-	tok, lit := p.scan()
-
-	switch tok {
-	case token.SUB, token.INT:
-		p.unscan()
-		return p.parseIntVar()
-
-	case token.XOR:
-		tok, lit = p.expect(token.IDENT, "global var ref")
-		p.scan()
-		p.unscan()
-
-		return typedToken{
-			tok: tok, lit: lit, typ: nil, glob: true}
+	ins := p.fn.NewInstr(opv, nil)
+	ins.Pos = pos
 
-	case token.PERIOD:
-		_, lit = p.expect(token.IDENT, "block ref")
+	// the instr is put in the block first, since the stack slots of
+	// its defs are counted for the func it's in
+	p.blk.InsertInstr(-1, ins)
 
-		blkname := lit
+	var deftyp types.Type
+	for i, def := range defs {
+		if i == 0 {
+			deftyp = def.typ
+		}
+		v := ins.AddDef(p.defValue(def.label, def.typ))
+		p.setLocation(v, def.label)
+	}
 
-		tok, _ = p.scan()
+	for {
+		tok, _ := p.scan()
+		if tok == token.SEMICOLON || tok == token.EOF {
+			break
+		}
 		p.unscan()
 
-		var args []typedToken
-		if tok == token.LPAREN {
-			args = p.parseParenList()
+		if tok == token.PERIOD {
+			p.parseSucc()
+		} else if arg := p.parseArg(deftyp); arg != nil {
+			ins.InsertArg(-1, arg)
 		}
 
-		return typedToken{
-			tok: token.PERIOD, lit: blkname, typ: nil, glob: true, block: true, args: args}
-
-	case token.IDENT, token.RANGE, token.IF, token.RETURN:
-		next, _ := p.scan()
-		p.unscan()
-		var typ types.Type
-		if next == token.COLON {
-			typ = p.parseColonType()
+		if tok, _ := p.scan(); tok != token.COMMA {
+			p.unscan()
+			if tok != token.SEMICOLON && tok != token.EOF {
+				p.errorf("found %s, expected , or end of instr", tok)
+				p.skipLine()
+				break
+			}
 		}
-
-		return typedToken{
-			tok: tok, lit: lit, typ: typ}
-	default:
 	}
-
-	p.errorf("expected identifier or literal; got %q %s", lit, tok)
-	return typedToken{}
 }
 
-func (p *Parser) parseIntVar() typedToken {
-	if p.trace {
-		defer un(trace(p, "intVar"))
+// skipLine skips to the end of the line after an error
+func (p *Parser) skipLine() {
+	for {
+		tok, _ := p.scan()
+		if tok == token.SEMICOLON || tok == token.EOF {
+			return
+		}
 	}
+}
 
-	tok, _ := p.scan()
-
-	negate := false
-	if tok == token.SUB {
-		negate = true
-		p.scan()
+// parseOp parses the name of an op, once its first token has been
+// scanned at pos. It's either a generic op or an instruction of the arch,
+// which is qualified with the arch's name when it's written out, since
+// they can have the same names.
+func (p *Parser) parseOp(tok token.Token, name string, pos token.Pos) ir2.Op {
+	if p.trace {
+		defer un(trace(p, "op"))
 	}
 
-	p.unscan()
-	tok, lit := p.expect(token.INT, "int variable")
-	if negate {
-		lit = "-" + lit
+	if tok != token.IDENT && !tok.IsKeyword() {
+		p.errorf("found %q, expected op", name)
+		return nil
 	}
+	end := pos + token.Pos(len(name))
+
+	// a qualified name has no spaces in it, unlike an op followed by a
+	// block, like `jump .b1`
+	if tok, _ := p.scan(); tok == token.PERIOD && p.buf.pos == end {
+		qual := name
+		tok, name = p.scan()
+		if tok != token.IDENT && !tok.IsKeyword() {
+			p.errorf("found %q, expected op qualified with %s", name, qual)
+			return nil
+		}
 
-	return typedToken{tok: tok, lit: lit, typ: types.Typ[types.UntypedInt]}
-}
-
-var valueRefRe = regexp.MustCompile(`^v(\d+)(_\w+)?$`)
+		if arch == nil || !strings.EqualFold(qual, arch.Name()) {
+			p.errorf("op %s.%s is not from the arch being compiled for", qual, name)
+			return nil
+		}
 
-func (p *Parser) addInstr(defs []typedToken, opcode string, args []typedToken) {
-	if p.trace {
-		defer un(trace(p, "addInstr"))
+		opv, ok := arch.ParseOp(name)
+		if !ok {
+			p.errorf("unknown %s instruction %q", qual, name)
+			return nil
+		}
+		return opv
 	}
+	p.unscan()
 
-	opv, err := op.OpString(opcode)
-	if err != nil {
-		p.errorf("unknown instruction %q found: %s", opcode, err)
+	if opv, err := op.OpString(name); err == nil {
+		return opv
 	}
 
-	// todo: fix type here
-	ins := p.fn.NewInstr(opv, nil)
-
-	for _, def := range defs {
-		if def.typ == nil && opcode != "next" {
-			p.errorf("def %s is missing a type for instruction %s", def.lit, opcode)
+	// allow hand written IR to leave the arch off its instructions
+	if arch != nil {
+		if opv, ok := arch.ParseOp(name); ok {
+			return opv
 		}
-		v := ins.AddDef(p.fn.NewValue(def.typ))
-		p.values[def.lit] = v
 	}
 
-	for an, arg := range args {
-		if arg.tok == token.PERIOD {
-			p.blkLinks[p.blk] = append(p.blkLinks[p.blk], arg.lit)
+	// the token after it has been scanned, so the error is put at the op
+	p.errs.Add(p.fset.Position(pos), "unknown instruction "+strconv.Quote(name))
+	return nil
+}
 
-			for _, barg := range arg.args {
-				if val, ok := p.values[barg.lit]; ok {
-					p.blk.InsertArg(-1, val)
+// parseSucc parses a successor of the block, and the args passed to
+// its params
+func (p *Parser) parseSucc() {
+	if p.trace {
+		defer un(trace(p, "succ"))
+	}
 
-					if p.trace {
-						p.printTrace("block value arg:", val)
-					}
-					continue
-				}
+	p.expect(token.PERIOD, "block ref")
+	_, label := p.expect(token.IDENT, "block ref")
 
-				if valueRefRe.MatchString(barg.lit) {
-					p.blk.InsertArg(-1, p.fn.PlaceholderFor(barg.lit))
-					if p.trace {
-						p.printTrace("block arg placeholder:", barg.lit)
-					}
-					continue
-				}
+	p.blkLinks = append(p.blkLinks, blockLink{p.blk, label, p.buf.pos})
 
-				if barg.typ != nil {
-					if p.trace {
-						p.printTrace("block typed arg:", barg.typ, barg.lit)
-					}
-					val := p.fn.ValueFor(barg.typ, barg.lit)
-					p.blk.InsertArg(-1, val)
-					continue
-				}
-			}
-
-			continue
-		}
+	if tok, _ := p.scan(); tok != token.LPAREN {
+		p.unscan()
+		return
+	}
 
-		if val, ok := p.values[arg.lit]; ok {
-			if p.trace {
-				p.printTrace("arg type: value", val)
-			}
-			ins.InsertArg(an, val)
-			continue
+	for {
+		if tok, _ := p.scan(); tok == token.RPAREN {
+			return
 		}
+		p.unscan()
 
-		glob := p.prog.Global(arg.lit)
-		if glob != nil {
-			if p.trace {
-				p.printTrace("arg type: global", glob)
-			}
-
-			val := p.fn.ValueFor(glob.Type, glob)
-			glob.Referenced = true
-			ins.InsertArg(an, val)
-			continue
+		if arg := p.parseArg(nil); arg != nil {
+			p.blk.InsertArg(-1, arg)
 		}
 
-		if fn := p.prog.Func(arg.lit); fn != nil {
-			if p.trace {
-				p.printTrace("arg type: func", fn.FullName)
-			}
-
-			fn.Referenced = true
-			val := p.fn.ValueFor(fn.Sig, fn)
-			ins.InsertArg(an, val)
-			continue
+		if tok, _ := p.scan(); tok != token.COMMA {
+			p.unscan()
+			p.expect(token.RPAREN, "block args")
+			return
 		}
+	}
+}
 
-		if arg.glob {
-			if p.globals == nil {
-				p.globals = make(map[string]map[*ir2.Func]*ir2.Value)
-			}
-
-			val, found := p.globals[arg.lit][p.fn]
-			if !found {
-				val = p.fn.NewValue(nil)
+// parseArg parses an arg. Consts without a type are given the type
+// they're written out without it, which is the type of the func or
+// global for refs to them, untyped string for strings, and for other
+// consts, the type of the first def of the instr, def, or else their
+// untyped kind.
+func (p *Parser) parseArg(def types.Type) *ir2.Value {
+	if p.trace {
+		defer un(trace(p, "arg"))
+	}
 
-				if p.globals[arg.lit] == nil {
-					p.globals[arg.lit] = make(map[*ir2.Func]*ir2.Value)
-				}
+	tok, lit := p.scan()
 
-				p.globals[arg.lit][p.fn] = val
-			}
+	var con ir2.Const
+	var typ types.Type
 
-			ins.InsertArg(an, val)
-			if p.trace {
-				p.printTrace("arg type: global placeholder", an)
-			}
-			continue
+	switch tok {
+	case token.SUB, token.INT:
+		if tok == token.SUB {
+			_, lit = p.expect(token.INT, "negative int")
+			lit = "-" + lit
 		}
-
-		if arg.typ != nil {
-			if p.trace {
-				p.printTrace("arg type: given type", arg.typ)
+		i, err := strconv.ParseInt(lit, 0, 64)
+		if err != nil {
+			u, uerr := strconv.ParseUint(lit, 0, 64)
+			if uerr != nil {
+				p.errorf("bad int %s: %s", lit, err)
 			}
-			val := p.fn.ValueFor(arg.typ, arg.lit)
-			ins.InsertArg(an, val)
-			continue
+			i = int64(u)
 		}
+		con = ir2.ConstFor(i)
 
-		if valueRefRe.MatchString(arg.lit) {
-			ins.InsertArg(an, p.fn.PlaceholderFor(arg.lit))
-			if p.trace {
-				p.printTrace("arg type: placeholder", an)
-			}
-			continue
+	case token.STRING:
+		str, err := strconv.Unquote(lit)
+		if err != nil {
+			p.errorf("failed unquoting string %s", lit)
 		}
+		con = ir2.ConstFor(str)
 
-		builtin := types.Universe.Lookup(arg.lit)
-		if builtin != nil && builtin.Type() != nil && builtin.Type() != types.Typ[types.Invalid] {
-			val := p.fn.ValueFor(builtin.Type(), arg.lit)
-			ins.InsertArg(an, val)
-			if p.trace {
-				p.printTrace("arg type: builtin", builtin.Type().String())
-			}
-			continue
+	case token.XOR:
+		_, label := p.expect(token.IDENT, "func or global ref")
+		if fn := p.prog.Func(label); fn != nil {
+			con = ir2.ConstFor(fn)
+			typ = fn.Sig
+		} else if glob := p.prog.Global(label); glob != nil {
+			con = ir2.ConstFor(glob)
+			typ = glob.Type
+		} else {
+			p.errorf("unknown func or global %s", label)
+			return nil
 		}
 
-		if len(defs) == 1 && defs[0].typ != nil {
-			typ := defs[0].typ
-			if p.trace {
-				p.printTrace("arg type: def", defs[0])
+	case token.IDENT:
+		switch lit {
+		case "true", "false":
+			con = ir2.ConstFor(lit == "true")
+		case "nil":
+			con = ir2.ConstFor(nil)
+		default:
+			if _, ok := p.values[lit]; ok || valueRefRe.MatchString(lit) {
+				return p.valueRef(lit)
 			}
 
-			val := p.fn.ValueFor(typ, arg.lit)
-			ins.InsertArg(an, val)
-			continue
+			// other identifiers are strings, like the names of builtins
+			con = ir2.ConstFor(lit)
 		}
 
-		p.errorf("type is required on arg %s for instruction %s", arg.lit, opcode)
+	default:
+		p.errorf("expected value or const, got %s %q", tok, lit)
+		return nil
 	}
 
-	p.blk.InsertInstr(-1, ins)
-}
-
-func (p *Parser) parseParenList() (list []typedToken) {
-	if p.trace {
-		defer un(trace(p, "parenList"))
+	switch {
+	case typ != nil:
+	case con.Kind() == ir2.StringConst:
+		typ = types.Typ[types.UntypedString]
+	case def != nil:
+		typ = def
+	default:
+		typ = untypedConst(con)
 	}
 
-	p.expect(token.LPAREN, "param/arg list")
-
-	var args []typedToken
-
-	tok, _ := p.scan()
-	if tok != token.RPAREN {
-		p.unscan()
-		first := p.parseVar()
-
-		tok, _ := p.scan()
-		if tok == token.COMMA {
-			p.unscan()
-			args = p.parseList(first)
-		} else {
-			args = append(args, first)
-			p.unscan()
-		}
+	if t := p.parseColonType(); t != nil {
+		typ = t
 	}
 
-	p.expect(token.RPAREN, "param/arg list end")
-
-	p.scan()
-	p.unscan()
-
-	return args
+	return p.fn.ValueFor(typ, con)
 }
 
-func (p *Parser) parseList(first typedToken) (list []typedToken) {
-	if p.trace {
-		defer un(trace(p, "list"))
+// valueRef returns the Value with the label. Values that haven't been
+// defined yet are given a placeholder, except for the ones written
+// with a type, which nothing defines, like the registers the prologue
+// saves.
+func (p *Parser) valueRef(label string) *ir2.Value {
+	typ := p.parseColonType()
+
+	if val, ok := p.values[label]; ok {
+		return val
 	}
 
-	if first.tok != token.ILLEGAL {
-		list = append(list, first)
+	if typ == nil {
+		return p.fn.PlaceholderFor(label)
 	}
-	for {
-		if tok, _ := p.scan(); tok != token.COMMA {
-			p.unscan()
-			return
-		}
 
-		list = append(list, p.parseVar())
+	val := p.defValue(label, typ)
+	p.setLocation(val, label)
+	return val
+}
+
+// untypedConst returns the untyped type of the kind of const
+func untypedConst(con ir2.Const) types.Type {
+	switch con.Kind() {
+	case ir2.BoolConst:
+		return types.Typ[types.UntypedBool]
+	case ir2.IntConst:
+		return types.Typ[types.UntypedInt]
+	case ir2.NilConst:
+		return types.Typ[types.UntypedNil]
 	}
+	return nil
 }
@@ -1,6 +1,7 @@
 package parseir
 
 import (
+	"go/scanner"
 	"go/token"
 	"go/types"
 	"strconv"
@@ -13,19 +14,86 @@ func (p *Parser) parse() {
 		defer un(trace(p, "parse"))
 	}
 
+	p.declarePackages()
+
+	p.declaring = true
+	p.rewind()
+	p.parsePackages()
+	p.resolveGlobalLinks()
+	p.checkTypes()
+	if p.errs.Len() > 0 {
+		return
+	}
+
+	p.declaring = false
+	p.rewind()
+	p.parsePackages()
+}
+
+func (p *Parser) parsePackages() {
 	for {
 		if tok, lit := p.scan(); tok != token.PACKAGE {
 			if tok == token.EOF {
-				p.resolveGlobalLinks()
 				return
 			}
 			p.errorf("found %q, expected package", lit)
+			p.skipDecl(false)
+			continue
 		}
 
 		p.parsePackage()
 	}
 }
 
+// declarePackages adds the packages in the file to the program before
+// anything else is parsed, since types can be qualified with packages
+// that come later in the file
+func (p *Parser) declarePackages() {
+	var s scanner.Scanner
+	s.Init(token.NewFileSet().AddFile(p.file.Name(), -1, len(p.src)), p.src, nil, 0)
+
+	var toks [4]token.Token
+	var lits [4]string
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return
+		}
+		copy(toks[:], toks[1:])
+		copy(lits[:], lits[1:])
+		toks[3], lits[3] = tok, lit
+
+		// package name "path" at the start of a line
+		if (toks[0] != token.SEMICOLON && toks[0] != token.ILLEGAL) ||
+			toks[1] != token.PACKAGE || toks[2] != token.IDENT || toks[3] != token.STRING {
+			continue
+		}
+
+		name := lits[2]
+		path, err := strconv.Unquote(lits[3])
+		if err != nil {
+			continue
+		}
+
+		pkg := p.prog.Package(path)
+		if pkg == nil {
+			typ := types.NewPackage(path, name)
+			if path == "unsafe" {
+				// the types in it are qualified with it
+				typ = types.Unsafe
+			}
+			pkg = &ir2.Package{
+				Name: name,
+				Path: path,
+				Type: typ,
+			}
+
+			p.prog.AddPackage(pkg)
+		}
+		p.packages[name] = pkg
+	}
+}
+
 func (p *Parser) parsePackage() {
 	if p.trace {
 		defer un(trace(p, "package"))
@@ -54,14 +122,10 @@ func (p *Parser) parsePackage() {
 	}
 
 	p.pkg = p.prog.Package(path)
-	if p.pkg == nil {
-		p.pkg = &ir2.Package{
-			Name: name,
-			Path: path,
-			Type: types.NewPackage(path, name),
-		}
-
-		p.prog.AddPackage(p.pkg)
+	if p.pkg == nil || p.pkg.Name != name {
+		p.errorf("package %s %q wasn't declared", name, path)
+		p.skipDecl(false)
+		return
 	}
 
 	for {
@@ -76,11 +140,52 @@ func (p *Parser) parsePackage() {
 		case token.FUNC:
 			p.parseFunc()
 		case token.VAR:
-			p.parseGlobal()
+			if p.declaring {
+				p.parseGlobal()
+			} else {
+				p.skipDecl(false)
+			}
 		case token.TYPE:
-			p.parseTypeDef()
+			if p.declaring {
+				p.parseTypeDef()
+			} else {
+				p.skipDecl(false)
+			}
 		default:
 			p.errorf("found %q %q, expected func", lit, tok)
+			p.skipDecl(false)
+		}
+	}
+}
+
+// skipDecl skips to the next declaration, which is a line that
+// starts with package, type, var or func
+func (p *Parser) skipDecl(lineStart bool) {
+	for {
+		tok, _ := p.scan()
+		switch tok {
+		case token.EOF:
+			p.unscan()
+			return
+		case token.PACKAGE, token.TYPE, token.VAR, token.FUNC:
+			if lineStart {
+				p.unscan()
+				return
+			}
 		}
+		lineStart = tok == token.SEMICOLON
+	}
+}
+
+// checkTypes checks that the types referred to have been defined,
+// once they all have been, and completes the interfaces
+func (p *Parser) checkTypes() {
+	for named, pos := range p.forward {
+		obj := named.Obj()
+		p.errs.Add(p.fset.Position(pos), "type "+obj.Pkg().Name()+"."+obj.Name()+" is never defined")
+	}
+
+	for _, iface := range p.ifaces {
+		iface.Complete()
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"go/scanner"
 	"go/token"
+	"go/types"
 	"io"
 	"os"
 	"strings"
@@ -13,6 +14,8 @@ import (
 
 type Parser struct {
 	fset *token.FileSet
+	file *token.File
+	src  []byte
 	s    *scanner.Scanner
 	errs scanner.ErrorList
 
@@ -23,25 +26,49 @@ type Parser struct {
 		n   int         // buffer size (max=1)
 	}
 
+	// declaring is set for the first pass over the file, which declares
+	// the types, globals and funcs, so the second pass can parse the
+	// funcs' blocks knowing everything they can refer to
+	declaring bool
+
 	// current context
 	prog *ir2.Program
 	pkg  *ir2.Package
 	fn   *ir2.Func
 	blk  *ir2.Block
 
+	// declarations
+	packages map[string]*ir2.Package
+	named    map[*ir2.Package]map[string]*types.Named
+	forward  map[*types.Named]token.Pos
+	ifaces   []*types.Interface
+	funcs    []*ir2.Func
+	recovers map[*ir2.Func]string
+	refs     []globalRef
+
 	// context maps
 	blkLabels map[string]*ir2.Block
 	values    map[string]*ir2.Value
-	globals   map[string]map[*ir2.Func]*ir2.Value
+	valueIDs  map[*ir2.Value]int
+	idLabels  map[int]string
+	blockIDs  map[*ir2.Block]int
 
 	// forward reference links
-	blkLinks map[*ir2.Block][]string
+	blkLinks []blockLink
 
 	// debugging / diagnostics
 	indent int
 	trace  bool
 }
 
+// blockLink is a link from a block to one of its successors, which
+// is added once all the blocks of the func have been parsed
+type blockLink struct {
+	blk   *ir2.Block
+	label string
+	pos   token.Pos
+}
+
 // NewParser returns a new instance of Parser.
 func NewParser(filename string, r io.Reader, prog *ir2.Program, trace bool) (*Parser, error) {
 	buf, err := io.ReadAll(r)
@@ -50,11 +77,24 @@ func NewParser(filename string, r io.Reader, prog *ir2.Program, trace bool) (*Pa
 	}
 	fset := token.NewFileSet()
 	file := fset.AddFile(filename, -1, len(buf))
-	errs := scanner.ErrorList{}
-	s := &scanner.Scanner{}
-	s.Init(file, buf, errs.Add, 0)
 
-	return &Parser{fset: fset, s: s, errs: errs, prog: prog, trace: trace}, nil
+	// positions of instrs are in the IR file
+	if prog.FileSet == nil {
+		prog.FileSet = fset
+	}
+
+	return &Parser{
+		fset:     fset,
+		file:     file,
+		src:      buf,
+		s:        &scanner.Scanner{},
+		prog:     prog,
+		packages: make(map[string]*ir2.Package),
+		named:    make(map[*ir2.Package]map[string]*types.Named),
+		forward:  make(map[*types.Named]token.Pos),
+		recovers: make(map[*ir2.Func]string),
+		trace:    trace,
+	}, nil
 }
 
 // ParseString parses an IR listing and returns the func for that listing, which
@@ -88,20 +128,23 @@ func ParseString(text string) (*ir2.Func, error) {
 }
 
 func (p *Parser) Parse() error {
-	// defer func() {
-	// 	if r := recover(); r != nil {
-	// 		p.PrintErrors()
-	// 		log.Fatal(r)
-	// 	}
-	// }()
 	p.parse()
 
 	if p.errs.Len() < 1 {
 		return nil
 	}
+	p.errs.Sort()
 	return p.errs
 }
 
+// rewind starts scanning the file from the start again
+func (p *Parser) rewind() {
+	p.s.Init(p.file, p.src, func(pos token.Position, msg string) {
+		p.errs.Add(pos, msg)
+	}, 0)
+	p.buf.pos, p.buf.tok, p.buf.lit, p.buf.n = token.NoPos, token.ILLEGAL, "", 0
+}
+
 func (p *Parser) PrintErrors() {
 	scanner.PrintError(os.Stderr, p.errs)
 }
@@ -14,17 +14,47 @@ func (p *Parser) parseTypeDef() {
 	if tok != token.IDENT {
 		p.errorf("found %q, expected type name", lit)
 	}
-	pos := p.buf.pos
 
 	name := lit
 
-	p.expect(token.COLON, "typedef")
+	if p.pkg.TypeDef(name) != nil {
+		p.errorf("type %s is defined twice", name)
+	}
+
+	tok, lit = p.scan()
+	switch tok {
+	case token.ASSIGN:
+		// an alias, which has to be defined before it's used, since
+		// it can't be told from a named type until it is
+		if named := p.named[p.pkg][name]; named != nil {
+			p.errorf("alias %s is used before it's defined", name)
+			delete(p.forward, named)
+		}
+		p.pkg.NewTypeDef(name, p.parseType())
 
-	typ := p.parseType()
+	case token.COLON:
+		named := p.named[p.pkg][name]
+		if named == nil {
+			named = p.newNamed(p.pkg, name)
+		}
+		delete(p.forward, named)
 
-	typ = types.NewNamed(types.NewTypeName(pos, p.pkg.Type, name, nil), typ, nil)
+		// the typedef is added first so the type can refer to itself
+		p.pkg.NewTypeDef(name, named)
 
-	p.pkg.NewTypeDef(name, typ)
+		if typ := p.parseType(); typ != nil {
+			named.SetUnderlying(typ.Underlying())
+		}
+
+		if tok, _ := p.scan(); tok == token.LBRACE {
+			p.parseMethods(named)
+		} else {
+			p.unscan()
+		}
+
+	default:
+		p.errorf("found %q, expected : or = after type name", lit)
+	}
 
 	tok, _ = p.scan()
 	if tok == token.SEMICOLON {
@@ -32,3 +62,37 @@ func (p *Parser) parseTypeDef() {
 	}
 	p.unscan()
 }
+
+// parseMethods parses the methods of a named type, which are written
+// like the methods of an interface, with a * before the methods with
+// a pointer receiver
+func (p *Parser) parseMethods(named *types.Named) {
+	if p.trace {
+		defer un(trace(p, "methods"))
+	}
+
+	for {
+		tok, lit := p.scan()
+		if tok == token.RBRACE {
+			return
+		}
+
+		var recv types.Type = named
+		if tok == token.MUL {
+			recv = types.NewPointer(named)
+			tok, lit = p.scan()
+		}
+		if tok != token.IDENT {
+			p.errorf("found %q, expected method name", lit)
+			return
+		}
+		pos := p.buf.pos
+
+		sig := p.parseSignature(types.NewVar(pos, p.pkg.Type, "", recv))
+		named.AddMethod(types.NewFunc(pos, p.pkg.Type, lit, sig))
+
+		if tok, _ := p.scan(); tok != token.SEMICOLON {
+			p.unscan()
+		}
+	}
+}
@@ -4,14 +4,19 @@ import (
 	"go/token"
 	"go/types"
 	"strconv"
+
+	"github.com/rj45/nanogo/ir2"
 )
 
+// opaqueType is a type that isn't a Go type, like the iterators of
+// range, which like in ssa, is its own underlying type
 type opaqueType struct {
 	types.Type
 	name string
 }
 
-func (t *opaqueType) String() string { return t.name }
+func (t *opaqueType) Underlying() types.Type { return t }
+func (t *opaqueType) String() string         { return t.name }
 
 var tRangeIter = &opaqueType{nil, "iter"}
 
@@ -69,12 +74,12 @@ func (p *Parser) tryParseType() types.Type {
 		return p.parseInterfaceType()
 	case token.STRUCT:
 		return p.parseStructType()
+	case token.MAP:
+		return p.parseMapType()
+	case token.CHAN, token.ARROW:
+		return p.parseChanType()
 
 	// TODO: parse these types
-	// case token.MAP:
-	// 	return p.parseMapType()
-	// case token.CHAN, token.ARROW:
-	// 	return p.parseChanType()
 	// case token.LPAREN:
 	// 	lparen := p.pos
 	// 	p.next()
@@ -96,75 +101,84 @@ func (p *Parser) parseTypeName() types.Type {
 	tok, lit := p.scan()
 	if tok != token.IDENT {
 		p.errorf("expected type or package name; found %q", lit)
+		return nil
 	}
 
-	name := lit
+	return p.resolveTypeName(lit)
+}
 
-	pkg := p.pkg
+// resolveTypeName returns the type with the name, once its first
+// identifier has been scanned, which is either the package it's
+// qualified with or the type name
+func (p *Parser) resolveTypeName(name string) types.Type {
+	tok, lit := p.scan()
+	if tok == token.PERIOD {
+		_, lit := p.expect(token.IDENT, "qualified type name")
 
-	tok, _ = p.scan()
+		pkg := p.packages[name]
+		if pkg == nil {
+			if name == "unsafe" && lit == "Pointer" {
+				return types.Typ[types.UnsafePointer]
+			}
+			p.errorf("unknown package %s", name)
+			return nil
+		}
 
-	if tok == token.PERIOD {
-		// todo: handle aliases
-		pkg = p.prog.Package(name)
+		return p.typeNamed(pkg, lit)
+	}
 
-		tok, lit := p.scan()
-		if tok != token.IDENT {
-			p.errorf("expected type name; found %q", lit)
+	if name == "untyped" && tok == token.IDENT {
+		for _, typ := range types.Typ {
+			if typ.Name() == "untyped "+lit {
+				return typ
+			}
 		}
-		name = lit
-	} else {
-		p.unscan()
+		p.errorf("unknown untyped type %s", lit)
+		return nil
 	}
+	p.unscan()
 
-	typ := types.Universe.Lookup(name)
-	if typ != nil {
-		return typ.Type()
+	if obj, ok := types.Universe.Lookup(name).(*types.TypeName); ok {
+		return obj.Type()
 	}
 
 	if name == "iter" {
 		return tRangeIter
 	}
 
-	if pkg.Type == nil {
-		panic("missing type on pkg " + pkg.Name)
-	}
+	return p.typeNamed(p.pkg, name)
+}
 
-	td := pkg.TypeDef(name)
-	if td != nil {
+// typeNamed returns the type defined with the name in a package. If
+// it hasn't been defined yet, a named type is returned that's given
+// its underlying type when it is.
+func (p *Parser) typeNamed(pkg *ir2.Package, name string) types.Type {
+	if td := pkg.TypeDef(name); td != nil {
 		return td.Type
 	}
 
-	p.errorf("unable to resolve type %s.%s", pkg.Name, name)
-
-	return nil
-}
-
-func (p *Parser) lookupTypeFor(pkgname string, name string) types.Type {
-	pkg := p.pkg
-
-	if pkgname == "" {
-		typ := types.Universe.Lookup(name)
-		if typ != nil {
-			return typ.Type()
-		}
+	if named := p.named[pkg][name]; named != nil {
+		return named
 	}
 
-	if pkgname != "" {
-		// todo: handle aliases
-		pkg = p.prog.Package(pkgname)
-		if pkg == nil {
-			panic("implement forward refs for packages")
-		}
+	// unsafe.Pointer is written unqualified in the unsafe package
+	if pkg.Path == "unsafe" && name == "Pointer" {
+		return types.Typ[types.UnsafePointer]
 	}
 
-	// todo: not sure this is correct.... may not add types to scopes
-	scope := pkg.Type.Scope().Lookup(name)
-	if scope != nil {
-		return scope.Type()
-	} else {
-		panic("implement forward refs for extern packages")
+	named := p.newNamed(pkg, name)
+	p.forward[named] = p.buf.pos
+	return named
+}
+
+// newNamed creates a named type without an underlying type yet
+func (p *Parser) newNamed(pkg *ir2.Package, name string) *types.Named {
+	named := types.NewNamed(types.NewTypeName(p.buf.pos, pkg.Type, name, nil), nil, nil)
+	if p.named[pkg] == nil {
+		p.named[pkg] = make(map[string]*types.Named)
 	}
+	p.named[pkg][name] = named
+	return named
 }
 
 func (p *Parser) parseInterfaceType() types.Type {
@@ -174,9 +188,38 @@ func (p *Parser) parseInterfaceType() types.Type {
 
 	p.expect(token.INTERFACE, "interface type")
 	p.expect(token.LBRACE, "interface type")
-	p.expect(token.RBRACE, "interface type")
 
-	return types.NewInterfaceType(nil, nil)
+	var methods []*types.Func
+	var embeddeds []types.Type
+
+	for {
+		tok, lit := p.scan()
+		if tok == token.RBRACE {
+			break
+		}
+		if tok != token.IDENT {
+			p.errorf("expected method or embedded interface, got %s %q", tok, lit)
+			break
+		}
+		pos := p.buf.pos
+
+		if next, _ := p.scan(); next == token.LPAREN {
+			p.unscan()
+			sig := p.parseSignature(nil)
+			methods = append(methods, types.NewFunc(pos, p.pkg.Type, lit, sig))
+		} else {
+			p.unscan()
+			embeddeds = append(embeddeds, p.resolveTypeName(lit))
+		}
+
+		if tok, _ := p.scan(); tok != token.SEMICOLON {
+			p.unscan()
+		}
+	}
+
+	iface := types.NewInterfaceType(methods, embeddeds)
+	p.ifaces = append(p.ifaces, iface)
+	return iface
 }
 
 func (p *Parser) parsePointerType() types.Type {
@@ -219,6 +262,37 @@ func (p *Parser) parseArrayType() types.Type {
 	return types.NewArray(p.parseType(), int64(len))
 }
 
+func (p *Parser) parseMapType() types.Type {
+	if p.trace {
+		defer un(trace(p, "mapType"))
+	}
+
+	p.expect(token.MAP, "map type")
+	p.expect(token.LBRACK, "map type")
+	key := p.parseType()
+	p.expect(token.RBRACK, "map type")
+
+	return types.NewMap(key, p.parseType())
+}
+
+func (p *Parser) parseChanType() types.Type {
+	if p.trace {
+		defer un(trace(p, "chanType"))
+	}
+
+	dir := types.SendRecv
+	if tok, _ := p.scan(); tok == token.ARROW {
+		p.expect(token.CHAN, "chan type")
+		dir = types.RecvOnly
+	} else if tok, _ := p.scan(); tok == token.ARROW {
+		dir = types.SendOnly
+	} else {
+		p.unscan()
+	}
+
+	return types.NewChan(dir, p.parseType())
+}
+
 func (p *Parser) parseFuncType() types.Type {
 	if p.trace {
 		defer un(trace(p, "funcType"))
@@ -226,18 +300,19 @@ func (p *Parser) parseFuncType() types.Type {
 
 	p.expect(token.FUNC, "func type")
 
-	params := p.parseParams()
-	results := p.parseResults()
-	var tparams *types.Tuple
-	var tresults *types.Tuple
-	if len(params) > 0 {
-		tparams = types.NewTuple(params...)
-	}
-	if len(results) > 0 {
-		tresults = types.NewTuple(results...)
+	return p.parseSignature(nil)
+}
+
+// parseSignature parses the params and results of a signature
+func (p *Parser) parseSignature(recv *types.Var) *types.Signature {
+	if p.trace {
+		defer un(trace(p, "signature"))
 	}
 
-	return types.NewSignature(nil, tparams, tresults, false)
+	params, variadic := p.parseParams()
+	results := p.parseResults()
+
+	return types.NewSignatureType(recv, nil, nil, types.NewTuple(params...), types.NewTuple(results...), variadic)
 }
 
 func (p *Parser) parseResults() []*types.Var {
@@ -245,14 +320,16 @@ func (p *Parser) parseResults() []*types.Var {
 		defer un(trace(p, "results"))
 	}
 
-	tok, _ := p.scan()
+	tok, lit := p.scan()
 	pos := p.buf.pos
 	p.unscan()
 	if tok == token.LPAREN {
-		return p.parseParams()
+		results, _ := p.parseParams()
+		return results
 	}
 
-	if tok == token.EOF || tok == token.SEMICOLON || tok == token.COMMA || tok == token.COLON {
+	// shared is an attribute of globals that can follow their type
+	if tok == token.IDENT && lit == "shared" {
 		return nil
 	}
 
@@ -264,95 +341,81 @@ func (p *Parser) parseResults() []*types.Var {
 	return nil
 }
 
-func (p *Parser) parseParams() []*types.Var {
+// parseParams parses a list of params in parentheses, and returns
+// whether the last one is variadic
+func (p *Parser) parseParams() (params []*types.Var, variadic bool) {
 	if p.trace {
 		defer un(trace(p, "params"))
 	}
 
 	p.expect(token.LPAREN, "start func parameters")
-	tok, _ := p.scan()
-	p.unscan()
-	var params []*types.Var
-	if tok != token.RPAREN {
-		params = p.parseParamList()
-	}
-	p.expect(token.RPAREN, "end func parameters")
-	p.scan()
-	p.unscan()
-	return params
-}
-
-func (p *Parser) parseParamList() []*types.Var {
-	if p.trace {
-		defer un(trace(p, "paramList"))
-	}
-
-	var params []*types.Var
-
-	tok, _ := p.scan()
-	if tok == token.EOF || tok == token.RPAREN {
-		return nil
-	}
-	p.unscan()
-
 	for {
-		params = append(params, p.parseParamDecl())
-
 		tok, _ := p.scan()
+		if tok == token.RPAREN || tok == token.EOF {
+			break
+		}
 		p.unscan()
-		if tok == token.EOF || tok == token.RPAREN {
-			return params
+
+		if variadic {
+			p.errorf("only the last param can be variadic")
 		}
 
-		p.expect(token.COMMA, "param list comma")
+		var param *types.Var
+		param, variadic = p.parseParamDecl()
+		params = append(params, param)
+
+		if tok, _ := p.scan(); tok != token.COMMA {
+			p.unscan()
+			p.expect(token.RPAREN, "end func parameters")
+			break
+		}
 	}
+	return params, variadic
 }
 
-func (p *Parser) parseParamDecl() *types.Var {
+func (p *Parser) parseParamDecl() (param *types.Var, variadic bool) {
 	if p.trace {
 		defer un(trace(p, "paramDecl"))
 	}
 
 	var name string
-	var typ types.Type
 	pos := p.buf.pos
 
 	tok, lit := p.scan()
-	switch tok {
-	case token.IDENT:
-		// name
-		name = lit
-		tok, lit = p.scan()
-		p.unscan()
-
-		switch tok {
-		case token.IDENT, token.MUL, token.ARROW, token.FUNC, token.CHAN, token.MAP, token.STRUCT, token.INTERFACE, token.LPAREN, token.LBRACK:
-			typ = p.parseType()
+	if tok == token.IDENT {
+		switch next, _ := p.scan(); next {
+		case token.PERIOD, token.COMMA, token.RPAREN:
+			// an unnamed param with a type name
+			p.unscan()
+			return types.NewVar(pos, p.pkg.Type, "", p.resolveTypeName(lit)), false
 		case token.ELLIPSIS:
-			element := p.parseType()
-			typ = types.NewSlice(element)
-		case token.PERIOD:
-			p.scan()
-			typ = p.lookupTypeFor(name, lit)
-			name = ""
-		case token.RPAREN, token.COMMA:
-			typ = p.lookupTypeFor("", name)
-			name = ""
+			if tok, _ := p.scan(); tok == token.RPAREN {
+				// the string... of append([]byte, string...)
+				p.unscan()
+				return types.NewVar(pos, p.pkg.Type, "", p.resolveTypeName(lit)), true
+			}
+			p.unscan()
+			return types.NewVar(pos, p.pkg.Type, lit, types.NewSlice(p.parseType())), true
 		default:
-			p.errorf("expected type, got %s %q", tok, lit)
+			p.unscan()
+			name = lit
 		}
+	} else {
+		p.unscan()
+	}
 
-	case token.MUL, token.ARROW, token.FUNC, token.LBRACK, token.CHAN, token.MAP, token.STRUCT, token.INTERFACE, token.LPAREN:
+	if tok, _ := p.scan(); tok == token.ELLIPSIS {
+		variadic = true
+	} else {
 		p.unscan()
-		typ = p.parseType()
-	case token.ELLIPSIS:
-		element := p.parseType()
-		typ = types.NewSlice(element)
-	default:
-		p.errorf("expected param/result, got %s %q", tok, lit)
 	}
 
-	return types.NewVar(pos, p.pkg.Type, name, typ)
+	typ := p.parseType()
+	if variadic {
+		typ = types.NewSlice(typ)
+	}
+
+	return types.NewVar(pos, p.pkg.Type, name, typ), variadic
 }
 
 func (p *Parser) parseStructType() types.Type {
@@ -363,7 +426,8 @@ func (p *Parser) parseStructType() types.Type {
 	p.expect(token.STRUCT, "struct type")
 	p.expect(token.LBRACE, "struct type")
 
-	var list []*types.Var
+	var fields []*types.Var
+	var tags []string
 
 	for {
 		tok, _ := p.scan()
@@ -372,47 +436,71 @@ func (p *Parser) parseStructType() types.Type {
 			break
 		}
 
-		list = append(list, p.parseFieldDecl())
+		field, tag := p.parseFieldDecl()
+		fields = append(fields, field)
+		tags = append(tags, tag)
 	}
 
 	p.expect(token.RBRACE, "struct type")
 
-	return types.NewStruct(list, nil)
+	return types.NewStruct(fields, tags)
 }
 
-func (p *Parser) parseFieldDecl() *types.Var {
+func (p *Parser) parseFieldDecl() (*types.Var, string) {
 	if p.trace {
 		defer un(trace(p, "fieldDecl"))
 	}
 
 	tok, lit := p.scan()
-	var typ types.Type
 	pos := p.buf.pos
-	var name string
 
+	var field *types.Var
 	if tok == token.IDENT {
-		name = lit
-		tok, lit = p.scan()
+		next, _ := p.scan()
 		p.unscan()
-		if tok == token.PERIOD {
-			typ = p.lookupTypeFor(name, lit)
-		} else if tok == token.STRING || tok == token.SEMICOLON || tok == token.RBRACE {
-			typ = p.lookupTypeFor(p.pkg.Name, name)
-		} else if tok == token.LBRACK {
-			typ = p.parseArrayType()
-		} else {
-			// T P
-			typ = p.parseType()
+		switch next {
+		case token.PERIOD, token.STRING, token.SEMICOLON, token.RBRACE:
+			// embedded type
+			typ := p.resolveTypeName(lit)
+			field = types.NewField(pos, p.pkg.Type, embeddedName(typ), typ, true)
+		default:
+			field = types.NewField(pos, p.pkg.Type, lit, p.parseType(), false)
+		}
+	} else {
+		// embedded pointer type
+		p.unscan()
+		typ := p.parseType()
+		field = types.NewField(pos, p.pkg.Type, embeddedName(typ), typ, true)
+	}
+
+	tag := ""
+	if tok, lit := p.scan(); tok == token.STRING {
+		var err error
+		tag, err = strconv.Unquote(lit)
+		if err != nil {
+			p.errorf("failed unquoting tag %s", lit)
 		}
 	} else {
-		// embedded type
-		typ = p.parseType()
+		p.unscan()
 	}
 
-	tok, _ = p.scan()
-	if tok != token.SEMICOLON {
+	if tok, _ := p.scan(); tok != token.SEMICOLON {
 		p.unscan()
 	}
 
-	return types.NewVar(pos, p.pkg.Type, name, typ)
+	return field, tag
+}
+
+// embeddedName returns the name of an embedded field with the type
+func embeddedName(typ types.Type) string {
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	switch t := typ.(type) {
+	case *types.Named:
+		return t.Obj().Name()
+	case *types.Basic:
+		return t.Name()
+	}
+	return ""
 }
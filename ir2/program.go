@@ -1,6 +1,11 @@
 package ir2
 
-import "go/token"
+import (
+	"go/token"
+	"go/types"
+
+	"github.com/rj45/nanogo/ir2/typ"
+)
 
 // Program is a collection of packages,
 // which comprise a whole program.
@@ -11,6 +16,36 @@ type Program struct {
 
 	takenNames map[string]bool
 	strings    map[string]*Global
+
+	types     typ.Table
+	methodIDs map[string]int
+
+	// Separate is set when the packages are compiled separately into
+	// objects. Globals generated for the code, such as string literals,
+	// are then named for their contents and shared, since they could be
+	// generated again for another package.
+	Separate bool
+}
+
+// Numbering is the type code indexes and method IDs given out in a
+// program. The objects of packages compiled separately need the same
+// numbers from one build to the next, so they can be kept.
+type Numbering struct {
+	TypeIndexes map[string]int
+	MethodIDs   map[string]int
+}
+
+// UseNumbering makes the program give out the numbers in n, and add
+// the new ones to it. It's used before any have been given out.
+func (prog *Program) UseNumbering(n *Numbering) {
+	if n.TypeIndexes == nil {
+		n.TypeIndexes = make(map[string]int)
+	}
+	if n.MethodIDs == nil {
+		n.MethodIDs = make(map[string]int)
+	}
+	prog.types.Indexes = n.TypeIndexes
+	prog.methodIDs = n.MethodIDs
 }
 
 // Packages returns a copy of the package list
@@ -82,3 +117,26 @@ func (prog *Program) claimName(name string) {
 	}
 	prog.takenNames[name] = true
 }
+
+// TypeCode returns the code that identifies the type at runtime
+func (prog *Program) TypeCode(t types.Type) typ.Type {
+	return prog.types.TypeFor(t)
+}
+
+// MethodID returns the number that identifies a method at runtime.
+// Methods with the same name and signature share a number, so that
+// a method of a type can be matched to an interface's method.
+func (prog *Program) MethodID(method *types.Func) int {
+	key := method.Id() + types.TypeString(method.Type(), nil)
+
+	if prog.methodIDs == nil {
+		prog.methodIDs = make(map[string]int)
+	}
+
+	id, ok := prog.methodIDs[key]
+	if !ok {
+		id = len(prog.methodIDs) + 1
+		prog.methodIDs[key] = id
+	}
+	return id
+}
@@ -0,0 +1,79 @@
+package ir2
+
+// Prune removes the Funcs and Globals that can't be reached from the
+// roots. A Func reaches the Funcs and Globals its Instrs and Blocks
+// use as constants, and a Global reaches the ones its initial value
+// refers to, like the methods in a method table. String literals
+// that are removed can't be found with StringLiteral anymore either.
+func (prog *Program) Prune(roots ...*Func) {
+	funcs := make(map[*Func]bool)
+	globals := make(map[*Global]bool)
+	var todo []*Func
+
+	var ref func(c Const)
+	ref = func(c Const) {
+		if fn, ok := FuncValue(c); ok && !funcs[fn] {
+			funcs[fn] = true
+			todo = append(todo, fn)
+		} else if glob, ok := GlobalValue(c); ok && !globals[glob] {
+			globals[glob] = true
+			if glob.Value != nil {
+				ref(glob.Value)
+			}
+			for _, word := range glob.Words {
+				ref(word)
+			}
+		}
+	}
+
+	for _, fn := range roots {
+		if fn != nil {
+			ref(ConstFor(fn))
+		}
+	}
+
+	for len(todo) > 0 {
+		fn := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+
+		for _, blk := range fn.blocks {
+			// globals can also be passed to succ blocks
+			users := []*User{&blk.User}
+			for _, instr := range blk.instrs {
+				users = append(users, &instr.User)
+			}
+
+			for _, user := range users {
+				for _, arg := range user.args {
+					if arg.IsConst() {
+						ref(arg.Const())
+					}
+				}
+			}
+		}
+	}
+
+	for _, pkg := range prog.packages {
+		keptFuncs := pkg.funcs[:0]
+		for _, fn := range pkg.funcs {
+			if funcs[fn] {
+				keptFuncs = append(keptFuncs, fn)
+			}
+		}
+		pkg.funcs = keptFuncs
+
+		keptGlobals := pkg.globals[:0]
+		for _, glob := range pkg.globals {
+			if globals[glob] {
+				keptGlobals = append(keptGlobals, glob)
+			}
+		}
+		pkg.globals = keptGlobals
+	}
+
+	for str, glob := range prog.strings {
+		if !globals[glob] {
+			delete(prog.strings, str)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package ir2_test
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/ir2/parseir"
+)
+
+func TestProgramPrune_removesUnreachable(t *testing.T) {
+	fn, err := parseir.ParseString(`
+	package main "test"
+
+	var main__count:*int
+	var main__unused:*int
+	var main__used_1:string = "used"
+	var main__unused_1:string = "unused"
+
+	func main:
+	.b0:
+		call ^used
+		return
+
+	func used:
+	.b0:
+		v0:int = load ^main__count
+		v1:string = copy ^main__used_1
+		return
+
+	func unused:
+	.b0:
+		v0:int = load ^main__unused
+		v1:string = copy ^main__unused_1
+		call ^used
+		return
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkg := fn.Package()
+	prog := pkg.Program()
+
+	// string literals are only made by the frontend
+	kept := pkg.NewStringLiteral("main", "kept")
+	use := fn.NewInstr(op.Copy, types.Typ[types.String], kept)
+	fn.Block(0).InsertInstr(0, use)
+	pkg.NewStringLiteral("main", "dropped")
+
+	prog.Prune(fn)
+
+	for _, name := range []string{"main", "used"} {
+		if prog.Func(name) == nil {
+			t.Errorf("expected func %s to be kept", name)
+		}
+	}
+	if prog.Func("unused") != nil {
+		t.Errorf("expected func unused to be removed")
+	}
+
+	for _, name := range []string{"main__count", "main__used_1"} {
+		if prog.Global(name) == nil {
+			t.Errorf("expected global %s to be kept", name)
+		}
+	}
+	for _, name := range []string{"main__unused", "main__unused_1"} {
+		if prog.Global(name) != nil {
+			t.Errorf("expected global %s to be removed", name)
+		}
+	}
+
+	if prog.StringLiteral("kept", "") != kept {
+		t.Errorf("expected string literal kept to be kept")
+	}
+	if prog.StringLiteral("dropped", "") != nil {
+		t.Errorf("expected string literal dropped to be removed")
+	}
+}
@@ -0,0 +1,86 @@
+package typ
+
+import (
+	"go/types"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// Table assigns a Type code to each type in a program. Types that
+// can't be packed into a simple code are given the next index into
+// the side tables.
+type Table struct {
+	codes typeutil.Map
+	num   int
+
+	// Indexes, when set, gives the index of each type by its string
+	// instead, and the new ones are added to it. This keeps the codes
+	// the same from one program to the next.
+	Indexes map[string]int
+}
+
+// TypeFor returns the Type code for the types.Type, or Unknown if
+// there are too many types for the index to fit in the code.
+func (t *Table) TypeFor(typ types.Type) Type {
+	if code := SimpleTypeFor(typ); code != Unknown {
+		return code
+	}
+
+	if code, ok := t.codes.At(typ).(Type); ok {
+		return code
+	}
+
+	// indexes start at 1 so named basic types don't look unnamed
+	t.num++
+	index := t.num
+	if t.Indexes != nil {
+		key := types.TypeString(typ, nil)
+		if index = t.Indexes[key]; index == 0 {
+			index = len(t.Indexes) + 1
+			t.Indexes[key] = index
+		}
+	}
+
+	code := extendedCodeFor(typ, index)
+	t.codes.Set(typ, code)
+	return code
+}
+
+func extendedCodeFor(typ types.Type, index int) Type {
+	kind := kindOf(typ.Underlying())
+	if kind < firstDecoratorType {
+		if index >= 1<<(typeBits-6) {
+			return Unknown
+		}
+		return Type(index)<<6 | Type(kind)<<1
+	}
+
+	if index >= 1<<(typeBits-5) {
+		return Unknown
+	}
+	return Type(index)<<5 | 1<<4 | Type(kind-firstDecoratorType)<<1 | 1
+}
+
+func kindOf(typ types.Type) Kind {
+	switch t := typ.(type) {
+	case *types.Basic:
+		return Kind(t.Kind())
+	case *types.Interface:
+		return Interface
+	case *types.Struct:
+		return Struct
+	case *types.Signature:
+		return Func
+	case *types.Chan:
+		return Chan
+	case *types.Pointer:
+		return Ptr
+	case *types.Slice:
+		return Slice
+	case *types.Array:
+		return Array
+	case *types.Map:
+		return Map
+	}
+	return Invalid
+}
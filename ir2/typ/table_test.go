@@ -0,0 +1,55 @@
+package typ_test
+
+import (
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/rj45/nanogo/ir2/typ"
+)
+
+func TestTable_NamedTypesKeepTheirKind(t *testing.T) {
+	pkg := types.NewPackage("main", "main")
+	named := func(name string, underlying types.Type) types.Type {
+		obj := types.NewTypeName(token.NoPos, pkg, name, nil)
+		return types.NewNamed(obj, underlying, nil)
+	}
+
+	tests := []struct {
+		typ  types.Type
+		kind typ.Kind
+	}{
+		{named("Celsius", types.Typ[types.Int]), typ.I},
+		{named("Name", types.Typ[types.String]), typ.Str},
+		{named("Point", types.NewStruct(nil, nil)), typ.Struct},
+		{types.NewPointer(named("Node", types.NewStruct(nil, nil))), typ.Ptr},
+		{types.NewSlice(types.NewSlice(types.Typ[types.Int])), typ.Slice},
+	}
+
+	table := &typ.Table{}
+	seen := make(map[typ.Type]bool)
+	for _, tt := range tests {
+		got := table.TypeFor(tt.typ)
+		if got.Kind() != tt.kind {
+			t.Errorf("expected %s to be a %s but got %s", tt.typ, tt.kind, got.Kind())
+		}
+		if seen[got] {
+			t.Errorf("expected %s to have a unique code but got %#x again", tt.typ, uint16(got))
+		}
+		seen[got] = true
+	}
+}
+
+func TestTable_IdenticalTypesShareACode(t *testing.T) {
+	table := &typ.Table{}
+
+	a := table.TypeFor(types.NewSlice(types.NewSlice(types.Typ[types.Int])))
+	b := table.TypeFor(types.NewSlice(types.NewSlice(types.Typ[types.Int])))
+	if a != b {
+		t.Errorf("expected identical types to share a code but got %#x and %#x", uint16(a), uint16(b))
+	}
+
+	if got, want := table.TypeFor(types.Typ[types.Int]), typ.SimpleTypeFor(types.Typ[types.Int]); got != want {
+		t.Errorf("expected basic types to have their simple code %#x but got %#x", uint16(want), uint16(got))
+	}
+}
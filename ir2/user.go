@@ -172,6 +172,14 @@ func (use *User) RemoveArg(arg *Value) {
 	use.args = append(use.args[:i], use.args[i+1:]...)
 }
 
+// RemoveArgAt removes the ith argument, which unlike RemoveArg works
+// when the same Value is passed more than once
+func (use *User) RemoveArgAt(i int) {
+	use.args[i].removeUse(use)
+
+	use.args = append(use.args[:i], use.args[i+1:]...)
+}
+
 // ReplaceArg replaces the ith argument with the
 // value specified. Will call InsertArg instead
 // if i == NumArgs().
@@ -110,7 +110,16 @@ func (val *Value) IsDefinedByOp(op Op) bool {
 // NeedsReg indicates if this Value should be allocated
 // a register
 func (val *Value) NeedsReg() bool {
-	return !val.IsConst() && !val.IsBlock()
+	return !val.IsConst() && !val.IsBlock() && !val.InStackSlot()
+}
+
+// InStackSlot returns whether the value is in any kind of slot on the stack.
+func (val *Value) InStackSlot() bool {
+	switch val.Location() {
+	case InParamSlot, InArgSlot, InSpillSlot:
+		return true
+	}
+	return false
 }
 
 // stg is the storage for a value
@@ -228,7 +237,7 @@ func (val *Value) SetArgSlot(slot int) {
 
 // spill slots
 
-type spillStg uint8
+type spillStg int
 
 func (spillStg) Location() Location { return InSpillSlot }
 
@@ -26,6 +26,8 @@ type dumper interface {
 var output = flag.String("o", "", "output file for the result")
 var dir = flag.String("c", "", "set working dir (default current dir)")
 var theArch = flag.String("arch", "", "architecture to compile for")
+var legacy = flag.Bool("legacy", false, "compile with the legacy pipeline for comparison")
+var separate = flag.Bool("separate", false, "compile each package separately, reusing the ones in the build cache, and link them")
 
 func main() {
 	log.SetFlags(log.Lshortfile)
@@ -45,10 +47,22 @@ func main() {
 	case "r", "run":
 		mode = compiler.Assemble | compiler.Run
 	case "s", "asm":
+	case "t", "interp":
+		mode = compiler.Interpret
+	case "c", "compile":
+		mode = compiler.Separate | compiler.Objects
+	case "l", "link":
 	default:
 		printUsage = true
 	}
 
+	if *legacy {
+		mode |= compiler.Legacy
+	}
+	if *separate {
+		mode |= compiler.Separate
+	}
+
 	if printUsage {
 		fmt.Fprintln(os.Stderr, "NanoGo - A Go Compiler for Homebrew/Hobby CPUs")
 		fmt.Fprintln(os.Stderr, "https://github.com/rj45/nanogo")
@@ -56,9 +70,13 @@ func main() {
 		fmt.Fprintln(os.Stderr, "Usage: nanogo <flags> <command> <packages...>")
 		fmt.Fprintln(os.Stderr, "")
 		fmt.Fprintln(os.Stderr, "Commands:")
-		fmt.Fprintln(os.Stderr, "  build: compile and assemble with customasm")
+		fmt.Fprintln(os.Stderr, "  build: compile and assemble")
 		fmt.Fprintln(os.Stderr, "  asm: compile and write assembly to file")
 		fmt.Fprintln(os.Stderr, "  run: compile, assemble and run emulator")
+		fmt.Fprintln(os.Stderr, "  interp: compile up to the -stage given and run the ir2 interpreter")
+		fmt.Fprintln(os.Stderr, "  ir: compile up to the -stage given and write the IR, which a .ngir file can be compiled from")
+		fmt.Fprintln(os.Stderr, "  compile: compile each package into an object in the output folder")
+		fmt.Fprintln(os.Stderr, "  link: link objects into a program")
 		fmt.Fprintln(os.Stderr, "")
 		fmt.Fprintln(os.Stderr, "Flags:")
 		flag.PrintDefaults()
@@ -82,7 +100,12 @@ func main() {
 		arch.SetArch(*theArch)
 	}
 
-	result := compiler.Compile(outname, *dir, flag.Args()[1:], mode)
+	var result int
+	if command == "l" || command == "link" {
+		result = compiler.Link(outname, flag.Args()[1:], mode)
+	} else {
+		result = compiler.Compile(outname, *dir, flag.Args()[1:], mode)
+	}
 
 	os.Exit(result)
 }
@@ -0,0 +1,535 @@
+package regalloc2
+
+import (
+	"math"
+	"sort"
+)
+
+const noColour uint16 = 0
+
+// the states a move can be in during iterated register coalescing
+type moveState uint8
+
+const (
+	// the move has not been considered yet
+	worklistMove moveState = iota
+
+	// the move is not yet ready to be coalesced
+	activeMove
+
+	// the move has been coalesced
+	coalescedMove
+
+	// the move's source and destination interfere
+	constrainedMove
+
+	// the move will no longer be considered for coalescing
+	frozenMove
+)
+
+type iMove struct {
+	a, b  iNodeID
+	state moveState
+}
+
+// coalescer holds the state of the iterated register coalescing
+type coalescer struct {
+	ig *iGraph
+
+	moves    []iMove
+	moveList [][]int
+
+	degree    []int
+	alias     []iNodeID
+	coalesced []bool
+	onStack   []bool
+
+	simplifyWorklist map[iNodeID]struct{}
+	freezeWorklist   map[iNodeID]struct{}
+	spillWorklist    map[iNodeID]struct{}
+
+	selectStack []iNodeID
+
+	// cost returns how expensive it is to spill a node
+	cost func(iNodeID) float64
+}
+
+// pickColours colours the graph using iterated register coalescing,
+// as described by George and Appel in "Iterated Register Coalescing"
+// (1996). Returns the nodes that could not be coloured and need to be
+// spilled.
+//
+// Nodes with fewer neighbours than there are registers can always be
+// coloured, so they are removed from the graph (simplified) and pushed
+// on a stack. Nodes connected by a move (copy) are combined (coalesced)
+// when the Briggs or George tests show that it's safe to do so, which
+// means the copy will be between the same register and can be removed.
+// When nothing can be simplified or coalesced, a move-related node gives
+// up on coalescing (is frozen), and when there are no more of those, a
+// high degree node is picked as a potential spill. Then nodes are popped
+// off the stack and assigned colours, and potential spills that can't
+// be coloured become actual spills.
+//
+// Values live across a call must be in callee saved registers, so those
+// nodes have fewer colours available than other nodes.
+func (ig *iGraph) pickColours(cost func(iNodeID) float64) []iNodeID {
+	c := &coalescer{
+		ig:               ig,
+		moveList:         make([][]int, len(ig.nodes)),
+		degree:           make([]int, len(ig.nodes)),
+		alias:            make([]iNodeID, len(ig.nodes)),
+		coalesced:        make([]bool, len(ig.nodes)),
+		onStack:          make([]bool, len(ig.nodes)),
+		simplifyWorklist: make(map[iNodeID]struct{}),
+		freezeWorklist:   make(map[iNodeID]struct{}),
+		spillWorklist:    make(map[iNodeID]struct{}),
+		cost:             cost,
+	}
+
+	c.build()
+	c.makeWorklist()
+
+	for {
+		if len(c.simplifyWorklist) > 0 {
+			c.simplify()
+		} else if c.hasWorklistMoves() {
+			c.coalesce()
+		} else if len(c.freezeWorklist) > 0 {
+			c.freeze()
+		} else if len(c.spillWorklist) > 0 {
+			c.selectSpill()
+		} else {
+			break
+		}
+	}
+
+	return c.assignColours()
+}
+
+// build gathers the moves and degrees from the interference graph
+func (c *coalescer) build() {
+	ig := c.ig
+
+	for n := range ig.nodes {
+		c.alias[n] = iNodeID(n)
+		c.degree[n] = len(ig.nodes[n].interferes)
+		if c.precoloured(iNodeID(n)) {
+			c.degree[n] = math.MaxInt32
+		}
+	}
+
+	for n := range ig.nodes {
+		node := &ig.nodes[n]
+		if node.val == 0 {
+			continue
+		}
+
+		for _, other := range node.moves {
+			if other <= iNodeID(n) || ig.nodes[other].val == 0 {
+				// each move is recorded on both nodes, so only add it once
+				continue
+			}
+
+			c.moveList[n] = append(c.moveList[n], len(c.moves))
+			c.moveList[other] = append(c.moveList[other], len(c.moves))
+			c.moves = append(c.moves, iMove{a: iNodeID(n), b: other})
+		}
+	}
+}
+
+func (c *coalescer) precoloured(n iNodeID) bool {
+	return c.ig.nodes[n].colour != noColour
+}
+
+// k returns the number of colours available to the node
+func (c *coalescer) k(n iNodeID) int {
+	if c.ig.nodes[n].callerSaved {
+		return len(regList) - int(savedStart) + 1
+	}
+	return len(regList)
+}
+
+func (c *coalescer) makeWorklist() {
+	for n := range c.ig.nodes {
+		id := iNodeID(n)
+		if c.ig.nodes[n].val == 0 || c.precoloured(id) {
+			continue
+		}
+
+		if c.degree[n] >= c.k(id) {
+			c.spillWorklist[id] = struct{}{}
+		} else if c.moveRelated(id) {
+			c.freezeWorklist[id] = struct{}{}
+		} else {
+			c.simplifyWorklist[id] = struct{}{}
+		}
+	}
+}
+
+// adjacent returns the neighbours of the node still in the graph
+func (c *coalescer) adjacent(n iNodeID) []iNodeID {
+	var list []iNodeID
+	for nb := range c.ig.nodes[n].interferes {
+		if !c.onStack[nb] && !c.coalesced[nb] {
+			list = append(list, nb)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
+
+func (c *coalescer) interferes(a, b iNodeID) bool {
+	_, found := c.ig.nodes[a].interferes[b]
+	return found
+}
+
+// nodeMoves returns the moves of the node that could still be coalesced
+func (c *coalescer) nodeMoves(n iNodeID) []int {
+	var list []int
+	for _, m := range c.moveList[n] {
+		if c.moves[m].state == worklistMove || c.moves[m].state == activeMove {
+			list = append(list, m)
+		}
+	}
+	return list
+}
+
+func (c *coalescer) moveRelated(n iNodeID) bool {
+	return len(c.nodeMoves(n)) > 0
+}
+
+func (c *coalescer) hasWorklistMoves() bool {
+	for m := range c.moves {
+		if c.moves[m].state == worklistMove {
+			return true
+		}
+	}
+	return false
+}
+
+// pick returns the lowest numbered node in the worklist, so that
+// the allocation is deterministic
+func pick(worklist map[iNodeID]struct{}) iNodeID {
+	first := true
+	var lowest iNodeID
+	for n := range worklist {
+		if first || n < lowest {
+			lowest = n
+			first = false
+		}
+	}
+	return lowest
+}
+
+func (c *coalescer) simplify() {
+	n := pick(c.simplifyWorklist)
+	delete(c.simplifyWorklist, n)
+
+	c.selectStack = append(c.selectStack, n)
+	c.onStack[n] = true
+
+	for _, m := range c.adjacent(n) {
+		c.decrementDegree(m)
+	}
+}
+
+func (c *coalescer) decrementDegree(m iNodeID) {
+	if c.precoloured(m) {
+		return
+	}
+
+	d := c.degree[m]
+	c.degree[m]--
+
+	if d == c.k(m) {
+		c.enableMoves(m)
+		for _, nb := range c.adjacent(m) {
+			c.enableMoves(nb)
+		}
+
+		delete(c.spillWorklist, m)
+		if c.moveRelated(m) {
+			c.freezeWorklist[m] = struct{}{}
+		} else {
+			c.simplifyWorklist[m] = struct{}{}
+		}
+	}
+}
+
+func (c *coalescer) enableMoves(n iNodeID) {
+	for _, m := range c.nodeMoves(n) {
+		if c.moves[m].state == activeMove {
+			c.moves[m].state = worklistMove
+		}
+	}
+}
+
+func (c *coalescer) getAlias(n iNodeID) iNodeID {
+	for c.coalesced[n] {
+		n = c.alias[n]
+	}
+	return n
+}
+
+func (c *coalescer) coalesce() {
+	var m int
+	for m = range c.moves {
+		if c.moves[m].state == worklistMove {
+			break
+		}
+	}
+
+	x := c.getAlias(c.moves[m].a)
+	y := c.getAlias(c.moves[m].b)
+
+	u, v := x, y
+	if c.precoloured(y) {
+		u, v = y, x
+	}
+
+	switch {
+	case u == v:
+		c.moves[m].state = coalescedMove
+		c.addWorklist(u)
+
+	case c.precoloured(v) || c.interferes(u, v) || !c.compatible(u, v):
+		c.moves[m].state = constrainedMove
+		c.addWorklist(u)
+		c.addWorklist(v)
+
+	case c.precoloured(u) && c.george(u, v), !c.precoloured(u) && c.briggs(u, v):
+		c.moves[m].state = coalescedMove
+		c.combine(u, v)
+		c.addWorklist(u)
+
+	default:
+		c.moves[m].state = activeMove
+	}
+}
+
+// compatible checks if the colour of a precoloured node is usable by
+// the other node, since nodes live across calls can only use callee
+// saved registers, and nodes can't share a register with a neighbour
+func (c *coalescer) compatible(u, v iNodeID) bool {
+	if !c.precoloured(u) {
+		return true
+	}
+	colour := c.ig.nodes[u].colour
+	if colour == dontColour {
+		return false
+	}
+	if c.ig.nodes[v].callerSaved && colour < savedStart {
+		return false
+	}
+
+	// there can be more than one node pre-coloured with the same
+	// register, so make sure v doesn't interfere with any of them
+	for _, t := range c.adjacent(v) {
+		if c.precoloured(t) && c.ig.nodes[t].colour == colour {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *coalescer) addWorklist(u iNodeID) {
+	if !c.precoloured(u) && !c.moveRelated(u) && c.degree[u] < c.k(u) {
+		delete(c.freezeWorklist, u)
+		c.simplifyWorklist[u] = struct{}{}
+	}
+}
+
+// george checks if each neighbour of v already interferes with u, or is
+// of low enough degree that it won't be a problem
+func (c *coalescer) george(u, v iNodeID) bool {
+	for _, t := range c.adjacent(v) {
+		if !(c.degree[t] < c.k(t) || c.precoloured(t) || c.interferes(t, u)) {
+			return false
+		}
+	}
+	return true
+}
+
+// briggs checks if the combined node would have fewer neighbours of
+// significant degree than there are colours available
+func (c *coalescer) briggs(u, v iNodeID) bool {
+	k := c.k(u)
+	if c.k(v) < k {
+		k = c.k(v)
+	}
+
+	seen := make(map[iNodeID]bool)
+	significant := 0
+	for _, list := range [2][]iNodeID{c.adjacent(u), c.adjacent(v)} {
+		for _, n := range list {
+			if seen[n] {
+				continue
+			}
+			seen[n] = true
+			if c.degree[n] >= c.k(n) {
+				significant++
+			}
+		}
+	}
+	return significant < k
+}
+
+func (c *coalescer) combine(u, v iNodeID) {
+	ig := c.ig
+
+	delete(c.freezeWorklist, v)
+	delete(c.spillWorklist, v)
+
+	c.coalesced[v] = true
+	c.alias[v] = u
+	c.moveList[u] = append(c.moveList[u], c.moveList[v]...)
+	c.enableMoves(v)
+
+	ig.dbg("%s: coalescing %s into %s", ig.fn.Name, v, u)
+
+	if ig.nodes[v].callerSaved {
+		ig.nodes[u].callerSaved = true
+	}
+
+	for _, t := range c.adjacent(v) {
+		c.addEdge(t, u)
+		c.decrementDegree(t)
+	}
+
+	if _, found := c.freezeWorklist[u]; found && c.degree[u] >= c.k(u) {
+		delete(c.freezeWorklist, u)
+		c.spillWorklist[u] = struct{}{}
+	}
+}
+
+func (c *coalescer) addEdge(a, b iNodeID) {
+	if a == b || c.interferes(a, b) {
+		return
+	}
+
+	for _, pair := range [2][2]iNodeID{{a, b}, {b, a}} {
+		node := &c.ig.nodes[pair[0]]
+		if node.interferes == nil {
+			node.interferes = make(map[iNodeID]struct{})
+		}
+		node.interferes[pair[1]] = struct{}{}
+
+		if !c.precoloured(pair[0]) {
+			c.degree[pair[0]]++
+		}
+	}
+}
+
+func (c *coalescer) freeze() {
+	u := pick(c.freezeWorklist)
+	delete(c.freezeWorklist, u)
+	c.simplifyWorklist[u] = struct{}{}
+	c.freezeMoves(u)
+}
+
+func (c *coalescer) freezeMoves(u iNodeID) {
+	for _, m := range c.nodeMoves(u) {
+		x := c.moves[m].a
+		y := c.moves[m].b
+
+		v := c.getAlias(y)
+		if v == c.getAlias(u) {
+			v = c.getAlias(x)
+		}
+
+		c.moves[m].state = frozenMove
+
+		if _, found := c.freezeWorklist[v]; found && !c.moveRelated(v) && c.degree[v] < c.k(v) {
+			delete(c.freezeWorklist, v)
+			c.simplifyWorklist[v] = struct{}{}
+		}
+	}
+}
+
+// selectSpill picks the cheapest node to spill from the spill worklist
+func (c *coalescer) selectSpill() {
+	var best iNodeID
+	bestCost := math.Inf(1)
+	first := true
+
+	for n := range c.spillWorklist {
+		cost := c.cost(n)
+		if first || cost < bestCost || (cost == bestCost && n < best) {
+			best = n
+			bestCost = cost
+			first = false
+		}
+	}
+
+	delete(c.spillWorklist, best)
+	c.simplifyWorklist[best] = struct{}{}
+	c.freezeMoves(best)
+}
+
+// assignColours pops the nodes off the select stack and gives each one
+// a colour not used by its neighbours. When there's a choice, the colour
+// of a node it's copied to or from is preferred.
+func (c *coalescer) assignColours() []iNodeID {
+	ig := c.ig
+
+	var spilled []iNodeID
+
+	for i := len(c.selectStack) - 1; i >= 0; i-- {
+		n := c.selectStack[i]
+		node := &ig.nodes[n]
+		node.order = uint16(len(c.selectStack) - 1 - i)
+
+		used := make(map[uint16]bool)
+		for nb := range node.interferes {
+			colour := ig.nodes[c.getAlias(nb)].colour
+			if colour != noColour {
+				used[colour] = true
+			}
+		}
+
+		start := uint16(1)
+		if node.callerSaved {
+			start = savedStart
+		}
+
+		available := func(colour uint16) bool {
+			return colour >= start && int(colour) <= len(regList) && !used[colour]
+		}
+
+		colour := noColour
+		for _, mv := range node.moves {
+			moveColour := ig.nodes[c.getAlias(mv)].colour
+			if moveColour != dontColour && available(moveColour) {
+				colour = moveColour
+				ig.dbg("%s: pick move colour %d for %s", ig.fn.Name, colour, node)
+				break
+			}
+		}
+
+		for try := start; colour == noColour && int(try) <= len(regList); try++ {
+			if available(try) {
+				colour = try
+				ig.dbg("%s: pick colour %d for %s", ig.fn.Name, colour, node)
+			}
+		}
+
+		if colour == noColour {
+			ig.dbg("%s: failed to colour %s", ig.fn.Name, node)
+			spilled = append(spilled, n)
+			continue
+		}
+
+		node.colour = colour
+		if ig.maxColour < colour {
+			ig.maxColour = colour
+		}
+	}
+
+	for n := range ig.nodes {
+		if c.coalesced[n] {
+			ig.nodes[n].colour = ig.nodes[c.getAlias(iNodeID(n))].colour
+		}
+	}
+
+	return spilled
+}
@@ -71,6 +71,7 @@ func (ra *RegAlloc) buildInterferenceGraph() {
 
 	ig.nodes = nil
 	ig.valNode = make(map[ir2.ID]iNodeID)
+	ig.maxColour = 0
 
 	addNode := func(id ir2.ID) iNodeID {
 		if !id.ValueIn(ra.fn).NeedsReg() {
@@ -181,7 +182,24 @@ func (ra *RegAlloc) buildInterferenceGraph() {
 		}
 
 		for interferance := range node2.interferes {
+			if interferance == node1ID {
+				continue
+			}
 			node1.interferes[interferance] = struct{}{}
+
+			// neighbours now interfere with the merged node
+			neighbor := &ig.nodes[interferance]
+			delete(neighbor.interferes, node2ID)
+			neighbor.interferes[node1ID] = struct{}{}
+		}
+		delete(node1.interferes, node2ID)
+
+		for _, mv := range node2.moves {
+			for i, id := range ig.nodes[mv].moves {
+				if id == node2ID {
+					ig.nodes[mv].moves[i] = node1ID
+				}
+			}
 		}
 
 		if node2.callerSaved {
@@ -218,6 +236,11 @@ func (ra *RegAlloc) buildInterferenceGraph() {
 				def := succ.Def(d)
 				arg := blk.Arg(offset + d)
 
+				// spilled block args and defs are in a stack slot
+				if !def.NeedsReg() || !arg.NeedsReg() {
+					continue
+				}
+
 				live[arg.ID] = struct{}{}
 
 				ig.dbg("%s: merging %s -- %s", ra.fn.Name, def, arg)
@@ -263,6 +286,24 @@ func (ra *RegAlloc) buildInterferenceGraph() {
 						addEdge(def.ID, id)
 					}
 
+					// including the other defs, even if they are never used
+					for d2 := 0; d2 < d; d2++ {
+						if instr.Def(d2).NeedsReg() {
+							addEdge(def.ID, instr.Def(d2).ID)
+						}
+					}
+
+					// two-operand instructions overwrite their first arg, so a
+					// move into the def will be needed if they don't share a reg,
+					// which can't be allowed to clobber the other args
+					if instr.Op.ClobbersArg() {
+						for a := 1; a < instr.NumArgs(); a++ {
+							if instr.Arg(a).NeedsReg() {
+								addEdge(def.ID, instr.Arg(a).ID)
+							}
+						}
+					}
+
 					// if it's a move (aka copy)
 					if instr.Op.IsCopy() && instr.Arg(d).NeedsReg() {
 						// add the move between the corresponding defs and args
@@ -285,7 +326,9 @@ func (ra *RegAlloc) buildInterferenceGraph() {
 			// spilled which is handled separately
 			if instr.Op.IsCall() {
 				for id := range live {
-					node := &ig.nodes[ig.valNode[id]]
+					// args only become nodes once they interfere with a def,
+					// so the node may not exist yet
+					node := &ig.nodes[addNode(id)]
 					node.callerSaved = true
 					ig.dbg(ra.fn.Name, ": marking val", node.val.ValueIn(ra.fn), "in val", node.val, "as caller saved")
 				}
@@ -315,144 +358,3 @@ func (ra *RegAlloc) buildInterferenceGraph() {
 		}
 	}
 }
-
-// findPerfectEliminationOrder finds the perfect elimination order by
-// using the max cardinality search algorithm. This is done because
-// the graph should be chordal thanks to SSA. Chordal graphs can
-// be optimally coloured in reverse perfect elimination order.
-// There are other algorithms that could find the PEO as well,
-// such as lexicographic breadth first search. This seemed simpler
-// though it may be slower (not sure).
-func (ig *iGraph) findPerfectEliminationOrder() []iNodeID {
-	marked := make(map[iNodeID]struct{})
-	output := make([]iNodeID, 0, len(ig.nodes))
-	unmarked := make([]iNodeID, len(ig.nodes))
-	for i := range ig.nodes {
-		unmarked[i] = iNodeID(i)
-	}
-
-	// for each unmarked node
-	for len(unmarked) > 0 {
-		// find the unmarked node with the most marked neighbors
-		maxNode := unmarked[0]
-		maxI := 0
-		maxCard := -1
-		for i, cand := range unmarked {
-			card := 0
-			for neighbor := range ig.nodes[cand].interferes {
-				if _, found := marked[neighbor]; found {
-					card++
-				}
-			}
-			if card > maxCard {
-				maxI = i
-				maxNode = cand
-				maxCard = card
-			}
-		}
-
-		// remove node from unmarked list. Order doesn't matter
-		// so the faster way of removing an item from the slice works.
-		unmarked[maxI] = unmarked[len(unmarked)-1]
-		unmarked = unmarked[:len(unmarked)-1]
-
-		// mark the node
-		marked[maxNode] = struct{}{}
-
-		// add node to output
-		output = append(output, maxNode)
-
-		ig.nodes[maxNode].order = uint16(len(output) - 1)
-	}
-
-	return output
-}
-
-func (ig *iGraph) pickColours() {
-	order := ig.findPerfectEliminationOrder()
-
-	// pick colours in reverse perfect elimination order
-	for i := len(order) - 1; i >= 0; i-- {
-		nodeID := order[i]
-		node := &ig.nodes[nodeID]
-		if node.val == 0 {
-			continue
-		}
-		node.pickColour(ig)
-	}
-}
-
-const noColour uint16 = 0
-
-func (nd *iNode) pickColour(ig *iGraph) {
-	if nd.colour != noColour {
-		// already coloured
-		return
-	}
-
-	// try to pick a move colour if that colour doesn't
-	// interfere with any others
-	for _, mv := range nd.moves {
-		moveColour := ig.nodes[mv].colour
-
-		// skip if the move node has not already been assigned a colour
-		if moveColour == noColour || moveColour == dontColour {
-			continue
-		}
-
-		// check if that colour interferes with any neighbors
-		interferes := false
-		for nb := range nd.interferes {
-			if ig.nodes[nb].colour == moveColour {
-				interferes = true
-				break
-			}
-		}
-
-		// if it doesn't interfere  and the move colour is caller saved if it needs to be
-		if !interferes && (!nd.callerSaved || moveColour >= savedStart) {
-			// then choose that colour
-			nd.colour = moveColour
-			ig.dbg("%s: pick move colour %d for %s", ig.fn.Name, nd.colour, nd)
-			return
-		}
-	}
-
-	// if the node must be in caller saved registers, then start it there rather
-	// than at 1 where the callee saved registers are
-	start := uint16(1)
-	if nd.callerSaved {
-		ig.dbg("starting node %s in callee saved regs", nd.val)
-		start = savedStart
-	}
-
-	// find the lowest numbered colour that doesn't interfere
-	for colour := start; ; colour++ {
-		interferes := false
-
-		// for each neighbour in the interferences
-		for nb := range nd.interferes {
-			// if the neighbour already has this colour
-			if ig.nodes[nb].colour == colour {
-				// then it interferes and we can't use it
-				interferes = true
-				break
-			}
-		}
-
-		// if it doesn't interfere then
-		if !interferes {
-			// choose the colour
-			nd.colour = colour
-
-			ig.dbg("%s: pick colour %d for %s", ig.fn.Name, nd.colour, nd)
-
-			// keep track of the largest chosen colour
-			if ig.maxColour < colour {
-				ig.maxColour = colour
-			}
-
-			return
-		}
-	}
-}
@@ -35,7 +35,7 @@ func (ra *RegAlloc) liveInOutScan() error {
 
 	// set up a work queue
 	work := make([]*ir2.Block, 0, fn.NumBlocks())
-	inWork := make([]bool, fn.NumBlocks())
+	inWork := make([]bool, fn.NumBlockIDs())
 
 	// add all blocks to the queue in reverse
 	// order (bottom to top)
@@ -15,6 +15,9 @@ type RegAlloc struct {
 	info []blockInfo
 
 	iGraph iGraph
+
+	// values created by spilling that must not be spilled again
+	noSpill map[ir2.ID]bool
 }
 
 type blockInfo struct {
@@ -25,11 +28,12 @@ type blockInfo struct {
 // NewRegAlloc returns a new register allocator, ready to have
 // the registers allocated for the given function.
 func NewRegAlloc(fn *ir2.Func) *RegAlloc {
-	info := make([]blockInfo, fn.NumBlocks())
+	info := make([]blockInfo, fn.NumBlockIDs())
 
 	return &RegAlloc{
-		fn:   fn,
-		info: info,
+		fn:      fn,
+		info:    info,
+		noSpill: make(map[ir2.ID]bool),
 	}
 }
 
@@ -76,13 +80,13 @@ func (ra *RegAlloc) CheckInput() error {
 // Allocate will run the allocator and assign a physical
 // register or stack slot to each Value that needs one.
 //
-// This uses a graph colouring algorithm designed for use on SSA
-// code. The SSA code should have copies added for block
-// defs/args such that the allocator is free to choose different
-// registers as it crosses that boundary. Attemps will be
-// made to strongly prefer choosing the same register accross
-// a copy and across block args/defs to minimize the number
-// of copies in the resultant code.
+// This uses iterated register coalescing to colour the
+// interference graph. The SSA code should have copies added for
+// block defs/args such that the allocator is free to choose
+// different registers as it crosses that boundary. The values
+// on either side of a copy are coalesced into the same register
+// whenever that can be done without making the graph harder to
+// colour, so that the copies can later be removed.
 //
 // A simpler algorithm is chosen rather than a fast algorithm
 // because this can easily be a very complex peice of code and
@@ -93,14 +97,41 @@ func (ra *RegAlloc) CheckInput() error {
 // To that end, there is a verifier in the verify sub-package
 // which will double check the work of the alocator. See that
 // code for more information on how it works.
+//
+// If there are not enough registers, values are spilled to
+// spill slots on the stack, and the colouring is redone until
+// everything fits.
 func (ra *RegAlloc) Allocate() error {
-	if err := ra.liveInOutScan(); err != nil {
-		return err
+	for round := 0; ; round++ {
+		if err := ra.liveInOutScan(); err != nil {
+			return err
+		}
+
+		ra.buildInterferenceGraph()
+		ra.preColour()
+		uncoloured := ra.iGraph.pickColours(ra.spillCost)
+
+		spills, err := ra.findSpills(uncoloured)
+		if err != nil {
+			return err
+		}
+
+		if len(spills) == 0 {
+			break
+		}
+
+		if round >= maxSpillRounds {
+			return ErrTooManyRequiredRegisters
+		}
+
+		for _, nodeID := range spills {
+			ra.spill(nodeID)
+		}
+
+		// the liveness info is now stale
+		ra.info = make([]blockInfo, ra.fn.NumBlockIDs())
 	}
 
-	ra.buildInterferenceGraph()
-	ra.preColour()
-	ra.iGraph.pickColours()
 	if err := ra.assignRegisters(); err != nil {
 		return err
 	}
@@ -115,12 +146,11 @@ const dontColour = 0xffff
 
 // preColour finds all the values with already assigned registers and sets their colour to them
 func (ra *RegAlloc) preColour() {
-	if regList == nil {
-		regList = append(regList, reg.ArgRegs...)
-		regList = append(regList, reg.TempRegs...)
-		savedStart = uint16(len(regList) + 1)
-		regList = append(regList, reg.SavedRegs...)
-	}
+	// rebuilt each time in case the arch has changed
+	regList = append(regList[:0], reg.ArgRegs...)
+	regList = append(regList, reg.TempRegs...)
+	savedStart = uint16(len(regList) + 1)
+	regList = append(regList, reg.SavedRegs...)
 
 	for id := range ra.iGraph.nodes {
 		node := &ra.iGraph.nodes[id]
@@ -1,10 +1,16 @@
 package regalloc2_test
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/rj45/nanogo/ir2/parseir"
 	"github.com/rj45/nanogo/regalloc2"
+	"github.com/rj45/nanogo/regalloc2/verify"
+
+	// registering rj32 sets it as the default arch
+	_ "github.com/rj45/nanogo/arch/rj32"
 )
 
 func TestCriticalEdgeFinder_withNoCriticalEdges(t *testing.T) {
@@ -63,3 +69,200 @@ func TestCriticalEdgeFinder_withCriticalEdges(t *testing.T) {
 		t.Error("expected that the critical edge would be found and reported")
 	}
 }
+
+func TestAllocate_spillsWhenOutOfRegisters(t *testing.T) {
+	fn, err := parseir.ParseString(`
+	.b0:
+		v0:int = parameter 0
+		v1:int = add v0, 1
+		v2:int = add v0, 2
+		v3:int = add v0, 3
+		v4:int = add v0, 4
+		v5:int = add v0, 5
+		v6:int = add v0, 6
+		v7:int = add v0, 7
+		v8:int = add v0, 8
+		v9:int = add v0, 9
+		v10:int = add v0, 10
+		v11:int = add v0, 11
+		v12:int = add v0, 12
+		v13:int = add v0, 13
+		v14:int = add v0, 14
+		v15:int = add v1, v2
+		v16:int = add v15, v3
+		v17:int = add v16, v4
+		v18:int = add v17, v5
+		v19:int = add v18, v6
+		v20:int = add v19, v7
+		v21:int = add v20, v8
+		v22:int = add v21, v9
+		v23:int = add v22, v10
+		v24:int = add v23, v11
+		v25:int = add v24, v12
+		v26:int = add v25, v13
+		v27:int = add v26, v14
+		return v27
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ra := regalloc2.NewRegAlloc(fn)
+
+	if err := ra.Allocate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if fn.NumSpillSlots() == 0 {
+		t.Error("expected values to be spilled")
+	}
+
+	for _, err := range verify.Verify(fn) {
+		t.Error(err)
+	}
+}
+
+func TestAllocate_spillsIntoMoreThan256Slots(t *testing.T) {
+	// each value is live until the sum at the end, so nearly all of
+	// them need their own spill slot
+	const n = 300
+
+	var src strings.Builder
+	fmt.Fprintf(&src, ".b0:\n\tv0:int = parameter 0\n")
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&src, "\tv%d:int = add v0, %d\n", i, i)
+	}
+	sum := 1
+	for i := 2; i <= n; i++ {
+		fmt.Fprintf(&src, "\tv%d:int = add v%d, v%d\n", n+i, sum, i)
+		sum = n + i
+	}
+	fmt.Fprintf(&src, "\treturn v%d\n", sum)
+
+	fn, err := parseir.ParseString(src.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ra := regalloc2.NewRegAlloc(fn)
+
+	if err := ra.Allocate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if fn.NumSpillSlots() <= 256 {
+		t.Errorf("expected more than 256 spill slots, got %d", fn.NumSpillSlots())
+	}
+
+	for _, err := range verify.Verify(fn) {
+		t.Error(err)
+	}
+}
+
+func TestAllocate_spillsAcrossLoops(t *testing.T) {
+	fn, err := parseir.ParseString(`
+	.b0:
+		v0:int = parameter 0
+		v1:int = add v0, 1
+		v2:int = add v0, 2
+		v3:int = add v0, 3
+		v4:int = add v0, 4
+		v5:int = add v0, 5
+		v6:int = add v0, 6
+		v7:int = add v0, 7
+		v8:int = add v0, 8
+		v9:int = add v0, 9
+		v10:int = add v0, 10
+		v11:int = add v0, 11
+		v12:int = add v0, 12
+		v13:int = add v0, 13
+		v14:int = add v0, 14
+		v15:int = copy v0
+		jump .b1(v15)
+	.b1(v16:int):
+		v17:int = add v16, v1
+		v18:int = add v17, v2
+		v19:int = add v18, v3
+		v20:int = add v19, v4
+		v21:int = add v20, v5
+		v22:int = add v21, v6
+		v23:int = add v22, v7
+		v24:int = add v23, v8
+		v25:int = add v24, v9
+		v26:int = add v25, v10
+		v27:int = add v26, v11
+		v28:int = add v27, v12
+		v29:int = add v28, v13
+		v30:int = add v29, v14
+		v31:bool = less v30, 1000
+		if v31, .b2, .b3
+	.b2:
+		v32:int = copy v30
+		jump .b1(v32)
+	.b3:
+		return v30
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ra := regalloc2.NewRegAlloc(fn)
+
+	if err := ra.Allocate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if fn.NumSpillSlots() == 0 {
+		t.Error("expected values to be spilled")
+	}
+
+	for _, err := range verify.Verify(fn) {
+		t.Error(err)
+	}
+}
+
+func TestAllocate_coalescesCopies(t *testing.T) {
+	fn, err := parseir.ParseString(`
+	.b0:
+		v0:int = parameter 0
+		v1:int = copy v0
+		v2:int = add v1, 1
+		v3:int = copy v2
+		jump .b1(v3)
+	.b1(v4:int):
+		v5:int = add v4, 1
+		v6:bool = less v5, 10
+		if v6, .b2, .b3
+	.b2:
+		v7:int = copy v5
+		jump .b1(v7)
+	.b3:
+		return v5
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ra := regalloc2.NewRegAlloc(fn)
+
+	if err := ra.Allocate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, err := range verify.Verify(fn) {
+		t.Error(err)
+	}
+
+	for b := 0; b < fn.NumBlocks(); b++ {
+		blk := fn.Block(b)
+		for i := 0; i < blk.NumInstrs(); i++ {
+			instr := blk.Instr(i)
+			if !instr.Op.IsCopy() {
+				continue
+			}
+			if instr.Def(0).Reg() != instr.Arg(0).Reg() {
+				t.Errorf("expected copy %s to be coalesced", instr.LongString())
+			}
+		}
+	}
+}
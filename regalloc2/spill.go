@@ -0,0 +1,246 @@
+package regalloc2
+
+import (
+	"math"
+	"sort"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+)
+
+// maxSpillRounds limits how many times colouring is retried after
+// spilling, in case spilling fails to make progress
+const maxSpillRounds = 64
+
+// findSpills picks which nodes to spill for each of the nodes that
+// could not be coloured. If the node itself can't be spilled, one of
+// its neighbours is chosen instead based on how cheap it is to spill.
+// Returns ErrTooManyRequiredRegisters if none of the nodes can be
+// spilled.
+func (ra *RegAlloc) findSpills(uncoloured []iNodeID) ([]iNodeID, error) {
+	ig := &ra.iGraph
+
+	var spills []iNodeID
+	chosen := make(map[iNodeID]bool)
+
+	for _, nodeID := range uncoloured {
+		node := &ig.nodes[nodeID]
+
+		if ra.canSpill(nodeID) {
+			if !chosen[nodeID] {
+				chosen[nodeID] = true
+				spills = append(spills, nodeID)
+			}
+			continue
+		}
+
+		// the candidates are the neighbours, sorted to keep
+		// allocation deterministic
+		var candidates []iNodeID
+		for nb := range node.interferes {
+			candidates = append(candidates, nb)
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i] < candidates[j]
+		})
+
+		// if a spill was already picked nearby, it may make room
+		// for this node too, so wait and see on the next round
+		alreadyChosen := false
+		for _, cand := range candidates {
+			if chosen[cand] {
+				alreadyChosen = true
+				break
+			}
+		}
+		if alreadyChosen {
+			continue
+		}
+
+		best := iNodeID(0)
+		bestCost := math.Inf(1)
+		for _, cand := range candidates {
+			// a node live across a call that can't get a callee saved
+			// register should prefer to spill other nodes live across calls
+			cost := ra.spillCost(cand)
+			if node.callerSaved && !ig.nodes[cand].callerSaved {
+				cost *= 2
+			}
+
+			if cost < bestCost {
+				best = cand
+				bestCost = cost
+			}
+		}
+
+		if math.IsInf(bestCost, 1) {
+			return nil, ErrTooManyRequiredRegisters
+		}
+
+		chosen[best] = true
+		spills = append(spills, best)
+	}
+
+	return spills, nil
+}
+
+// nodeValues returns the node's value and the values merged with it
+func (ig *iGraph) nodeValues(nodeID iNodeID) []ir2.ID {
+	node := &ig.nodes[nodeID]
+	vals := []ir2.ID{node.val}
+	for _, id := range node.merged {
+		if id != node.val {
+			vals = append(vals, id)
+		}
+	}
+	return vals
+}
+
+// canSpill returns whether it's possible to spill the node. Nodes with
+// pre-assigned registers and the values created while spilling can't
+// be spilled.
+func (ra *RegAlloc) canSpill(nodeID iNodeID) bool {
+	if ra.iGraph.nodes[nodeID].val == 0 {
+		return false
+	}
+
+	for _, id := range ra.iGraph.nodeValues(nodeID) {
+		if ra.noSpill[id] {
+			return false
+		}
+
+		val := id.ValueIn(ra.fn)
+		if val == nil || val.InReg() || val.Def() == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// spillCost estimates the cost of spilling a node. Every use and def
+// adds a load or store, but the more neighbours the node has, the
+// more it relieves register pressure. Nodes that can't be spilled
+// have an infinite cost.
+func (ra *RegAlloc) spillCost(nodeID iNodeID) float64 {
+	if !ra.canSpill(nodeID) {
+		return math.Inf(1)
+	}
+
+	accesses := 0
+	for _, id := range ra.iGraph.nodeValues(nodeID) {
+		accesses += 1 + id.ValueIn(ra.fn).NumUses()
+	}
+	return float64(accesses) / float64(len(ra.iGraph.nodes[nodeID].interferes)+1)
+}
+
+// spill puts a node's values in a new spill slot on the stack. The
+// values merged together across block boundaries all share the slot.
+//
+// Values defined by a copy from a register are moved directly into the
+// slot, turning the copy into a store. Other values get a copy into
+// the slot inserted just after their definition. Then for each use of
+// the slot, a copy out of the slot (a reload) is inserted just before
+// the use, unless the use is a copy into a register, which already is
+// a reload.
+func (ra *RegAlloc) spill(nodeID iNodeID) {
+	fn := ra.fn
+	slot := fn.NumSpillSlots()
+
+	var slotVals []*ir2.Value
+
+	for _, id := range ra.iGraph.nodeValues(nodeID) {
+		val := id.ValueIn(fn)
+
+		if val.Def().IsBlock() || isCopyFromReg(val) {
+			// block defs are in the same slot as the block args
+			// coming from each pred block
+			val.SetSpillSlot(slot)
+			slotVals = append(slotVals, val)
+			continue
+		}
+
+		instr := val.Def().Instr()
+		store := fn.NewInstr(op.Copy, val.Type, val)
+		instr.Block().InsertInstr(instr.Index()+1, store)
+		stored := store.Def(0)
+
+		// everything else uses the spilled value
+		for _, use := range users(val) {
+			if use.ID == store.ID {
+				continue
+			}
+			replaceArgs(use, val, stored)
+		}
+
+		stored.SetSpillSlot(slot)
+		slotVals = append(slotVals, stored)
+
+		// the original value now only lives until the store
+		ra.noSpill[val.ID] = true
+	}
+
+	for _, val := range slotVals {
+		for _, use := range users(val) {
+			if use.IsBlock() {
+				// block args are in the slot along with the succ block defs
+				continue
+			}
+
+			instr := use.Instr()
+			if instr.Op.IsCopy() && instr.Def(use.ArgIndex(val)).NeedsReg() {
+				// this is already a reload
+				continue
+			}
+
+			reload := fn.NewInstr(op.Copy, val.Type, val)
+			instr.Block().InsertInstr(instr.Index(), reload)
+			loaded := reload.Def(0)
+			ra.noSpill[loaded.ID] = true
+
+			replaceArgs(use, val, loaded)
+		}
+	}
+}
+
+// isCopyFromReg returns whether the value is defined by a copy of
+// a value in a register
+func isCopyFromReg(val *ir2.Value) bool {
+	instr := val.Def().Instr()
+	if !instr.Op.IsCopy() {
+		return false
+	}
+	for d := 0; d < instr.NumDefs(); d++ {
+		if instr.Def(d) == val {
+			return instr.Arg(d).NeedsReg()
+		}
+	}
+	return false
+}
+
+// users returns each user of the value once, skipping instructions
+// that have been removed without being unlinked from their args
+func users(val *ir2.Value) []*ir2.User {
+	var list []*ir2.User
+	seen := make(map[ir2.ID]bool)
+	for i := 0; i < val.NumUses(); i++ {
+		use := val.Use(i)
+		if use.IsInstr() && use.Block() == nil {
+			continue
+		}
+		if !seen[use.ID] {
+			seen[use.ID] = true
+			list = append(list, use)
+		}
+	}
+	return list
+}
+
+// replaceArgs replaces every use of val in the user's args with other
+func replaceArgs(use *ir2.User, val, other *ir2.Value) {
+	for a := 0; a < use.NumArgs(); a++ {
+		if use.Arg(a) == val {
+			use.ReplaceArg(a, other)
+		}
+	}
+}
@@ -16,6 +16,7 @@ var regIndex map[reg.Reg]uint8
 var ErrNoRegAssigned = errors.New("no register assigned to a variable")
 var ErrWrongValueInReg = errors.New("attempt to read wrong value from register")
 var ErrMissingCopy = errors.New("missing copy of block parameter")
+var ErrWrongValueInSlot = errors.New("attempt to read wrong value from stack slot")
 
 // Verify executes the function symbolically, tracking which values are
 // in which registers. Each block is executed with each permutation of
@@ -27,6 +28,10 @@ var ErrMissingCopy = errors.New("missing copy of block parameter")
 // different from the way the register allocator works, thus increasing
 // the likelihood of catching bugs.
 //
+// Values in stack slots are tracked the same way as values in
+// registers, with each param, arg and spill slot getting its own
+// entry in the live set after the registers.
+//
 // This verifier wasn't really designed to be fast, since it probably
 // won't need to run in production. It's mainly for catching bugs in
 // tests / development. But if tests end up slow, this could be
@@ -34,28 +39,29 @@ var ErrMissingCopy = errors.New("missing copy of block parameter")
 func Verify(fn *ir2.Func) []error {
 	var errs []error
 
-	if regList == nil {
-		regList = append(regList, reg.None)
-		regList = append(regList, reg.ArgRegs...)
-		regList = append(regList, reg.TempRegs...)
-		regList = append(regList, reg.SavedRegs...)
+	// rebuilt each time in case the arch has changed
+	regList = append(regList[:0], reg.None)
+	regList = append(regList, reg.ArgRegs...)
+	regList = append(regList, reg.TempRegs...)
+	regList = append(regList, reg.SavedRegs...)
 
-		regIndex = make(map[reg.Reg]uint8, len(regList))
-		for idx, reg := range regList {
-			regIndex[reg] = uint8(idx)
-		}
+	regIndex = make(map[reg.Reg]uint8, len(regList))
+	for idx, reg := range regList {
+		regIndex[reg] = uint8(idx)
 	}
 
 	if fn.NumBlocks() < 1 {
 		return nil
 	}
 
+	locs := newLocations(fn)
+
 	// calculate the initial live regs for the first block
 	firstblk := fn.Block(0)
-	firstlive := make([]ir2.ID, len(regList))
+	firstlive := make([]ir2.ID, locs.size)
 	for d := 0; d < firstblk.NumDefs(); d++ {
 		arg := firstblk.Def(d)
-		firstlive[regIndex[arg.Reg()]] = arg.ID
+		firstlive[locs.index(arg)] = arg.ID
 	}
 
 	// add it to the worklist
@@ -66,7 +72,7 @@ func Verify(fn *ir2.Func) []error {
 	done := map[uint64]struct{}{}
 
 	// mark it as done
-	key := make([]byte, len(regList)*2+2)
+	key := make([]byte, locs.size*2+2)
 	done[genKey(firstblk, firstlive, key)] = struct{}{}
 
 	// for each worklist item
@@ -87,6 +93,17 @@ func Verify(fn *ir2.Func) []error {
 			// for each arg (use) of the instruction that needs a register
 			for a := 0; a < instr.NumArgs(); a++ {
 				arg := instr.Arg(a)
+
+				// check the value currently residing in the stack slot
+				if arg.InStackSlot() {
+					slotidx := locs.index(arg)
+					if live[slotidx] != arg.ID {
+						errs = append(errs,
+							fmt.Errorf("%w: slot %s contains %s but wanted to read %s: fn %s blk %s instr %q arg %s", ErrWrongValueInSlot, locs.name(slotidx), idString(fn, live[slotidx]), arg.IDString(), fn.Name, blk, instr, arg))
+					}
+					continue
+				}
+
 				if !arg.NeedsReg() {
 					continue
 				}
@@ -102,13 +119,8 @@ func Verify(fn *ir2.Func) []error {
 				// match, then report it
 				regidx := regIndex[arg.Reg()]
 				if live[regidx] != arg.ID {
-					oldval := live[regidx].ValueIn(fn)
-					oldstr := "<unk>"
-					if oldval != nil {
-						oldstr = oldval.IDString()
-					}
 					errs = append(errs,
-						fmt.Errorf("%w: reg %s contains %s but wanted to read %s: fn %s blk %s instr %q arg %s", ErrWrongValueInReg, arg.Reg(), oldstr, arg.IDString(), fn.Name, blk, instr, arg))
+						fmt.Errorf("%w: reg %s contains %s but wanted to read %s: fn %s blk %s instr %q arg %s", ErrWrongValueInReg, arg.Reg(), idString(fn, live[regidx]), arg.IDString(), fn.Name, blk, instr, arg))
 				}
 			}
 
@@ -120,11 +132,24 @@ func Verify(fn *ir2.Func) []error {
 						live[regidx] = 0
 					}
 				}
+
+				// the callee is free to overwrite its params, which are
+				// the arg slots of this function
+				for i := 0; i < fn.NumArgSlots(); i++ {
+					live[locs.argSlot(i)] = 0
+				}
 			}
 
 			// for each def in the instruction that needs a register
 			for d := 0; d < instr.NumDefs(); d++ {
 				def := instr.Def(d)
+
+				// update the value in the stack slot
+				if def.InStackSlot() {
+					live[locs.index(def)] = def.ID
+					continue
+				}
+
 				if !def.NeedsReg() {
 					continue
 				}
@@ -156,7 +181,7 @@ func Verify(fn *ir2.Func) []error {
 				continue
 			}
 
-			succlive := make([]ir2.ID, len(regList))
+			succlive := make([]ir2.ID, locs.size)
 			copy(succlive, live)
 
 			// write all to zero before we write the actual ones so we don't clobber them
@@ -164,20 +189,18 @@ func Verify(fn *ir2.Func) []error {
 				def := succ.Def(d)
 				arg := blk.Arg(argoffset + d)
 
-				if def.Reg() != arg.Reg() {
+				if locs.index(def) != locs.index(arg) {
 					// todo: when blk parameter copies are implemented uncomment this
 					errs = append(errs,
 						fmt.Errorf("%w: fn %s from blk %s to blk %s: from arg %s to def %s", ErrMissingCopy, fn.Name, blk, succ, arg, def))
-					regidx := regIndex[arg.Reg()]
-					succlive[regidx] = 0
+					succlive[locs.index(arg)] = 0
 				}
 			}
 
 			for d := 0; d < succ.NumDefs(); d++ {
 				def := succ.Def(d)
 
-				regidx := regIndex[def.Reg()]
-				succlive[regidx] = def.ID
+				succlive[locs.index(def)] = def.ID
 			}
 
 			// add it to the worklist and mark it as having been added
@@ -195,6 +218,59 @@ func Verify(fn *ir2.Func) []error {
 	return errs
 }
 
+// locations maps registers and stack slots to indices in the live set
+type locations struct {
+	numSpill int
+	numArg   int
+	size     int
+}
+
+func newLocations(fn *ir2.Func) locations {
+	return locations{
+		numSpill: fn.NumSpillSlots(),
+		numArg:   fn.NumArgSlots(),
+		size:     len(regList) + fn.NumSpillSlots() + fn.NumArgSlots() + fn.NumParamSlots(),
+	}
+}
+
+// index returns where the value's register or stack slot is in the live set
+func (locs locations) index(val *ir2.Value) int {
+	switch {
+	case val.InSpillSlot():
+		return len(regList) + val.SpillSlot()
+	case val.InArgSlot():
+		return locs.argSlot(val.ArgSlot())
+	case val.InParamSlot():
+		return len(regList) + locs.numSpill + locs.numArg + val.ParamSlot()
+	}
+	return int(regIndex[val.Reg()])
+}
+
+func (locs locations) argSlot(slot int) int {
+	return len(regList) + locs.numSpill + slot
+}
+
+// name returns a name for the location at the index in the live set
+func (locs locations) name(index int) string {
+	switch {
+	case index < len(regList):
+		return regList[index].String()
+	case index < len(regList)+locs.numSpill:
+		return fmt.Sprintf("spill%d", index-len(regList))
+	case index < len(regList)+locs.numSpill+locs.numArg:
+		return fmt.Sprintf("arg%d", index-len(regList)-locs.numSpill)
+	}
+	return fmt.Sprintf("param%d", index-len(regList)-locs.numSpill-locs.numArg)
+}
+
+func idString(fn *ir2.Func, id ir2.ID) string {
+	val := id.ValueIn(fn)
+	if val == nil {
+		return "<unk>"
+	}
+	return val.IDString()
+}
+
 var seed = maphash.MakeSeed()
 
 func genKey(blk *ir2.Block, live []ir2.ID, key []byte) uint64 {
@@ -12,12 +12,14 @@ type Arch interface {
 	BasicSizes() [17]byte
 	RuneSize() int
 	MinAddressableBits() int
+	StackAlign() int
 }
 
 func SetArch(a Arch) {
 	basicSizes = a.BasicSizes()
 	runeSize = a.RuneSize()
 	minAddressableBits = a.MinAddressableBits()
+	stackAlign = a.StackAlign()
 }
 
 // sizes of basic types
@@ -29,6 +31,9 @@ var runeSize = 0
 // size of min addressable unit in bits
 var minAddressableBits = 0
 
+// alignment of the stack pointer in min addressable units
+var stackAlign = 0
+
 func WordSize() int64 {
 	return int64(basicSizes[types.Uintptr])
 }
@@ -37,6 +42,12 @@ func MinAddressableBits() int {
 	return minAddressableBits
 }
 
+// StackAlign returns the alignment of the stack pointer
+// in min addressable units
+func StackAlign() int64 {
+	return int64(stackAlign)
+}
+
 func Sizeof(T types.Type) int64 {
 	switch t := T.Underlying().(type) {
 	case *types.Basic:
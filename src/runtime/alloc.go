@@ -0,0 +1,59 @@
+package runtime
+
+import "unsafe"
+
+// This file implements a simple bump allocator. The heap starts at the
+// end of bss and runs up to near the stack, and memory is never freed.
+
+// The bounds of the heap, which come from the linker.
+func heapStart() uintptr
+func heapEnd() uintptr
+
+// The next free address in the heap, or zero before the first allocation.
+var heapNext uintptr
+
+// Allocate size bytes of zeroed memory on the heap.
+func alloc(size uintptr) unsafe.Pointer {
+	if heapNext == 0 {
+		heapNext = heapStart()
+	}
+
+	// align to 4 so any value can be stored there
+	start := heapNext + ((0 - heapNext) & 3)
+
+	end := start + size
+	if end > heapEnd() || end < start {
+		outOfMemory()
+	}
+	heapNext = end
+
+	memzero(unsafe.Pointer(start), size)
+
+	return unsafe.Pointer(start)
+}
+
+// Panic when there isn't enough heap left for an allocation.
+func outOfMemory() {
+	panic("out of memory")
+}
+
+// Set size bytes starting at ptr to zero.
+func memzero(ptr unsafe.Pointer, size uintptr) {
+	for i := uintptr(0); i < size; i++ {
+		*(*byte)(unsafe.Pointer(uintptr(ptr) + i)) = 0
+	}
+}
+
+// Copy size bytes from src to dst, which may overlap.
+func memmove(dst, src unsafe.Pointer, size uintptr) {
+	if uintptr(dst) < uintptr(src) {
+		for i := uintptr(0); i < size; i++ {
+			*(*byte)(unsafe.Pointer(uintptr(dst) + i)) = *(*byte)(unsafe.Pointer(uintptr(src) + i))
+		}
+		return
+	}
+
+	for i := size; i > 0; i-- {
+		*(*byte)(unsafe.Pointer(uintptr(dst) + i - 1)) = *(*byte)(unsafe.Pointer(uintptr(src) + i - 1))
+	}
+}
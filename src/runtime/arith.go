@@ -0,0 +1,86 @@
+package runtime
+
+// These are called by the compiler for multiplies, divides and
+// remainders on CPUs without hardware support for them. They must
+// not use `*`, `/` or `%` themselves.
+
+// mulsi multiplies with shifts and adds. The low word of a product
+// is the same whether the operands are signed or unsigned, so it is
+// used for both.
+func mulsi(a, b uint) uint {
+	res := uint(0)
+	for b != 0 {
+		if b&1 != 0 {
+			res += a
+		}
+		a <<= 1
+		b >>= 1
+	}
+	return res
+}
+
+func divsi(n, d int) int {
+	neg := false
+	if n < 0 {
+		n = -n
+		neg = true
+	}
+	if d < 0 {
+		d = -d
+		neg = !neg
+	}
+
+	q := udivsi(uint(n), uint(d))
+	if neg {
+		return -int(q)
+	}
+	return int(q)
+}
+
+// modsi returns the remainder, which takes the sign of the dividend
+func modsi(n, d int) int {
+	if d < 0 {
+		d = -d
+	}
+	if n < 0 {
+		return -int(umodsi(uint(-n), uint(d)))
+	}
+	return int(umodsi(uint(n), uint(d)))
+}
+
+func udivsi(n, d uint) uint {
+	q, _ := udivmodsi(n, d)
+	return q
+}
+
+func umodsi(n, d uint) uint {
+	_, r := udivmodsi(n, d)
+	return r
+}
+
+// udivmodsi does restoring division. The divisor is first shifted up
+// until it passes the dividend or its top bit is set, which avoids
+// needing to know the word size.
+func udivmodsi(n, d uint) (q, r uint) {
+	if d == 0 {
+		panic("integer divide by zero")
+	}
+
+	r = n
+	bit := uint(1)
+	for d < r && int(d) > 0 {
+		d <<= 1
+		bit <<= 1
+	}
+
+	for bit != 0 {
+		if r >= d {
+			r -= d
+			q |= bit
+		}
+		d >>= 1
+		bit >>= 1
+	}
+
+	return q, r
+}
@@ -0,0 +1,114 @@
+package runtime
+
+// These are called by the compiler for integers wider than a word.
+// Narrower multi-word integers are extended to 64 bits first. They
+// must not multiply, divide or shift by a variable amount themselves.
+
+func mul64(a, b uint64) uint64 {
+	res := uint64(0)
+	for b != 0 {
+		if b&1 != 0 {
+			res += a
+		}
+		a <<= 1
+		b >>= 1
+	}
+	return res
+}
+
+func div64(n, d int64) int64 {
+	neg := false
+	if n < 0 {
+		n = -n
+		neg = true
+	}
+	if d < 0 {
+		d = -d
+		neg = !neg
+	}
+
+	q := udiv64(uint64(n), uint64(d))
+	if neg {
+		return -int64(q)
+	}
+	return int64(q)
+}
+
+// mod64 returns the remainder, which takes the sign of the dividend
+func mod64(n, d int64) int64 {
+	if d < 0 {
+		d = -d
+	}
+	if n < 0 {
+		return -int64(umod64(uint64(-n), uint64(d)))
+	}
+	return int64(umod64(uint64(n), uint64(d)))
+}
+
+func udiv64(n, d uint64) uint64 {
+	q, _ := udivmod64(n, d)
+	return q
+}
+
+func umod64(n, d uint64) uint64 {
+	_, r := udivmod64(n, d)
+	return r
+}
+
+// udivmod64 does restoring division the same way udivmodsi does
+func udivmod64(n, d uint64) (q, r uint64) {
+	if d == 0 {
+		panic("integer divide by zero")
+	}
+
+	r = n
+	bit := uint64(1)
+	for d < r && int64(d) > 0 {
+		d <<= 1
+		bit <<= 1
+	}
+
+	for bit != 0 {
+		if r >= d {
+			r -= d
+			q |= bit
+		}
+		d >>= 1
+		bit >>= 1
+	}
+
+	return q, r
+}
+
+func shl64(x uint64, n uint) uint64 {
+	if n >= 64 {
+		return 0
+	}
+	for n != 0 {
+		x <<= 1
+		n--
+	}
+	return x
+}
+
+func shr64(x uint64, n uint) uint64 {
+	if n >= 64 {
+		return 0
+	}
+	for n != 0 {
+		x >>= 1
+		n--
+	}
+	return x
+}
+
+func ashr64(x int64, n uint) int64 {
+	if n >= 64 {
+		n = 63
+	}
+	for n != 0 {
+		x >>= 1
+		n--
+	}
+	return x
+}
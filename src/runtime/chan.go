@@ -0,0 +1,303 @@
+package runtime
+
+import "unsafe"
+
+// This file implements channels and select. A channel is a pointer to
+// the struct below, and values are passed by pointer, so the same code
+// works for every element type.
+//
+// Tasks blocked sending or receiving wait in a queue in the channel,
+// and tasks blocked in a select wait in selectQueue, where every channel
+// op looks for a case that can go ahead with it. The value is copied
+// straight between the tasks when one is waiting.
+
+// The underlying struct for the Go chan type.
+type channel struct {
+	elemSize  uintptr
+	buf       unsafe.Pointer // room for capacity values, used as a ring
+	capacity  uintptr
+	length    uintptr
+	head      uintptr // the index of the first value in buf
+	closed    bool
+	senders   taskQueue
+	receivers taskQueue
+}
+
+// The directions of the cases of a select, which are the ones from
+// go/types. This must match the compiler.
+const (
+	selectSend = 1
+	selectRecv = 2
+)
+
+// The most cases a select can have. This just sets the size of the
+// array, the compiler makes one as long as it needs.
+const maxSelectCases = 256
+
+// A case of a select, which the compiler makes an array of.
+type selectCase struct {
+	dir  uintptr
+	c    *channel
+	data unsafe.Pointer // the value to send, or where to receive to
+}
+
+// The tasks blocked in a select.
+var selectQueue taskQueue
+
+// Make a channel with a buffer for capacity values.
+func chanMake(elemSize, capacity uintptr) *channel {
+	c := &channel{
+		elemSize: elemSize,
+		capacity: capacity,
+	}
+	if capacity > 0 {
+		c.buf = alloc(elemSize * capacity)
+	}
+	return c
+}
+
+// Return the number of values in the buffer.
+func chanLen(c *channel) int {
+	if c == nil {
+		return 0
+	}
+	return int(c.length)
+}
+
+// Return the number of values the buffer has room for.
+func chanCap(c *channel) int {
+	if c == nil {
+		return 0
+	}
+	return int(c.capacity)
+}
+
+// Send the value, blocking until a receiver takes it or there's room
+// in the buffer. Sending on a nil channel blocks forever.
+func chanSend(c *channel, value unsafe.Pointer) {
+	if chanTrySend(c, value) {
+		return
+	}
+
+	t := taskCurrent()
+	if c != nil {
+		t.data = value
+		taskPush(&c.senders, t)
+	}
+	taskBlock()
+
+	if !t.ok {
+		panic("send on closed channel")
+	}
+}
+
+// Receive a value into value, blocking until there is one, and return
+// whether one was sent, rather than the channel being closed. Receiving
+// on a nil channel blocks forever.
+func chanRecv(c *channel, value unsafe.Pointer) bool {
+	t := taskCurrent()
+	if chanTryRecv(c, value, t) {
+		return t.ok
+	}
+
+	if c != nil {
+		t.data = value
+		taskPush(&c.receivers, t)
+	}
+	taskBlock()
+
+	return t.ok
+}
+
+// Close the channel, waking the tasks blocked on it. Receivers get the
+// zero value, and senders panic.
+func chanClose(c *channel) {
+	if c == nil {
+		panic("close of nil channel")
+	}
+	if c.closed {
+		panic("close of closed channel")
+	}
+	c.closed = true
+
+	for r := chanTakeReceiver(c); r != nil; r = chanTakeReceiver(c) {
+		memzero(r.data, c.elemSize)
+		r.ok = false
+		taskReady(r)
+	}
+	for s := chanTakeSender(c); s != nil; s = chanTakeSender(c) {
+		s.ok = false
+		taskReady(s)
+	}
+}
+
+// Do the first case that can go ahead without blocking, or when there
+// isn't one and it's blocking, block until one can. Return the index of
+// the case, and whether a value was received. Not blocking returns -1
+// when no case can go ahead.
+//
+//go:nobounds
+func chanSelect(cases *[maxSelectCases]selectCase, numCases uintptr, blocking bool) (int, bool) {
+	t := taskCurrent()
+
+	for i := uintptr(0); i < numCases; i++ {
+		if cases[i].dir == selectSend {
+			if chanTrySend(cases[i].c, cases[i].data) {
+				return int(i), false
+			}
+		} else if chanTryRecv(cases[i].c, cases[i].data, t) {
+			return int(i), t.ok
+		}
+	}
+
+	if !blocking {
+		return -1, false
+	}
+
+	t.cases = cases
+	t.numCases = numCases
+	taskPush(&selectQueue, t)
+	taskBlock()
+	t.cases = nil
+
+	if cases[t.selected].dir == selectSend {
+		if !t.ok {
+			panic("send on closed channel")
+		}
+		return int(t.selected), false
+	}
+	return int(t.selected), t.ok
+}
+
+// Send the value to a blocked receiver or the buffer, and return
+// whether it could without blocking.
+func chanTrySend(c *channel, value unsafe.Pointer) bool {
+	if c == nil {
+		return false
+	}
+	if c.closed {
+		panic("send on closed channel")
+	}
+
+	r := chanTakeReceiver(c)
+	if r != nil {
+		memmove(r.data, value, c.elemSize)
+		r.ok = true
+		taskReady(r)
+		return true
+	}
+
+	if c.length < c.capacity {
+		memmove(chanSlot(c, c.head+c.length), value, c.elemSize)
+		c.length++
+		return true
+	}
+
+	return false
+}
+
+// Receive a value from the buffer or a blocked sender into value, or
+// the zero value if the channel is closed, and return whether it could
+// without blocking. Whether a value was sent is put in t.ok.
+func chanTryRecv(c *channel, value unsafe.Pointer, t *task) bool {
+	if c == nil {
+		return false
+	}
+
+	if c.length > 0 {
+		memmove(value, chanSlot(c, c.head), c.elemSize)
+		c.head = chanNext(c, c.head)
+		c.length--
+
+		// there's room for a blocked sender's value now
+		s := chanTakeSender(c)
+		if s != nil {
+			memmove(chanSlot(c, c.head+c.length), s.data, c.elemSize)
+			c.length++
+			s.ok = true
+			taskReady(s)
+		}
+
+		t.ok = true
+		return true
+	}
+
+	s := chanTakeSender(c)
+	if s != nil {
+		memmove(value, s.data, c.elemSize)
+		s.ok = true
+		taskReady(s)
+
+		t.ok = true
+		return true
+	}
+
+	if c.closed {
+		memzero(value, c.elemSize)
+		t.ok = false
+		return true
+	}
+
+	return false
+}
+
+// Return the address of the ith value in the buffer, counting from the
+// start of it. The index must be less than twice the capacity.
+func chanSlot(c *channel, i uintptr) unsafe.Pointer {
+	if i >= c.capacity {
+		i -= c.capacity
+	}
+	return unsafe.Pointer(uintptr(c.buf) + i*c.elemSize)
+}
+
+// Return the index in the buffer after i.
+func chanNext(c *channel, i uintptr) uintptr {
+	i++
+	if i == c.capacity {
+		return 0
+	}
+	return i
+}
+
+// Take a task blocked receiving from the channel out of the queue it's
+// in, with its data set to where to receive to, or return nil.
+func chanTakeReceiver(c *channel) *task {
+	t := taskPop(&c.receivers)
+	if t != nil {
+		return t
+	}
+	return selectTake(c, selectRecv)
+}
+
+// Take a task blocked sending to the channel out of the queue it's in,
+// with its data set to the value to send, or return nil.
+func chanTakeSender(c *channel) *task {
+	t := taskPop(&c.senders)
+	if t != nil {
+		return t
+	}
+	return selectTake(c, selectSend)
+}
+
+// Take the first task blocked in a select with a case for the channel
+// in the direction out of the select queue, setting its data and which
+// case was selected, or return nil.
+//
+//go:nobounds
+func selectTake(c *channel, dir uintptr) *task {
+	var prev *task
+	for t := selectQueue.head; t != nil; t = t.next {
+		for i := uintptr(0); i < t.numCases; i++ {
+			if t.cases[i].c == c {
+				if t.cases[i].dir == dir {
+					taskRemove(&selectQueue, t, prev)
+					t.selected = i
+					t.data = t.cases[i].data
+					return t
+				}
+			}
+		}
+		prev = t
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package runtime
+
+import "unsafe"
+
+// This file implements Go maps as a hash table, where each bucket is a
+// linked list of entries. Entries only have room for their key and value
+// and the table starts small, so small maps use little memory.
+//
+// Keys and values are passed by pointer, so the same code works for
+// every map type.
+
+// The underlying struct for the Go map type. A map is a pointer to this.
+type hashmap struct {
+	buckets   []*hashmapEntry // the first entry of each bucket, always a power of two of them
+	count     uintptr
+	keySize   uintptr
+	valueSize uintptr
+	keyKind   uintptr
+}
+
+// How keys are compared and hashed. This must match the compiler.
+const (
+	hashmapKeyMemory = 0 // compared byte by byte
+	hashmapKeyString = 1 // compared as strings
+)
+
+// An entry in a bucket. The data is the key followed by the value.
+type hashmapEntry struct {
+	next *hashmapEntry
+	hash uintptr
+	data unsafe.Pointer
+}
+
+// The iterator state for a range over a map. Entries added while
+// ranging may or may not be seen.
+type hashmapIterator struct {
+	bucket uintptr
+	entry  *hashmapEntry
+}
+
+// The number of buckets a map starts with.
+const hashmapMinBuckets = 8
+
+// Make a map with room for about hint entries before it grows.
+func hashmapMake(keySize, valueSize, keyKind, hint uintptr) *hashmap {
+	numBuckets := uintptr(hashmapMinBuckets)
+	for numBuckets < hint {
+		numBuckets <<= 1
+	}
+
+	return &hashmap{
+		buckets:   make([]*hashmapEntry, numBuckets),
+		keySize:   keySize,
+		valueSize: valueSize,
+		keyKind:   keyKind,
+	}
+}
+
+// Return the number of entries in the map.
+func hashmapLen(m *hashmap) int {
+	if m == nil {
+		return 0
+	}
+	return int(m.count)
+}
+
+// Copy the value for the key into value, or zero it if the key isn't
+// in the map, and return whether it was.
+func hashmapGet(m *hashmap, key, value unsafe.Pointer, valueSize uintptr) bool {
+	entry := hashmapFind(m, key)
+	if entry == nil {
+		memzero(value, valueSize)
+		return false
+	}
+	memmove(value, hashmapEntryValue(m, entry), valueSize)
+	return true
+}
+
+// Set the value for the key, adding an entry if it isn't in the map.
+func hashmapSet(m *hashmap, key, value unsafe.Pointer) {
+	if m == nil {
+		panic("assignment to entry in nil map")
+	}
+
+	entry := hashmapFind(m, key)
+	if entry == nil {
+		if m.count>>1 >= uintptr(len(m.buckets)) {
+			hashmapGrow(m)
+		}
+
+		entry = &hashmapEntry{
+			hash: hashmapHash(m, key),
+			data: alloc(m.keySize + m.valueSize),
+		}
+		memmove(entry.data, key, m.keySize)
+
+		bucket := hashmapBucket(m.buckets, entry.hash)
+		entry.next = *bucket
+		*bucket = entry
+		m.count++
+	}
+
+	memmove(hashmapEntryValue(m, entry), value, m.valueSize)
+}
+
+// Remove the entry for the key, if there is one. The memory isn't freed.
+func hashmapDelete(m *hashmap, key unsafe.Pointer) {
+	if m == nil {
+		return
+	}
+
+	link := hashmapBucket(m.buckets, hashmapHash(m, key))
+	for *link != nil {
+		entry := *link
+		if hashmapKeyEqual(m, entry.data, key) {
+			*link = entry.next
+			m.count--
+			return
+		}
+		link = &entry.next
+	}
+}
+
+// Copy the key and value of the next entry into key and value, and
+// return whether there was one.
+func hashmapNext(m *hashmap, it *hashmapIterator, key, value unsafe.Pointer) bool {
+	if m == nil {
+		return false
+	}
+
+	for it.entry == nil {
+		if it.bucket >= uintptr(len(m.buckets)) {
+			return false
+		}
+		it.entry = m.buckets[it.bucket]
+		it.bucket++
+	}
+
+	entry := it.entry
+	it.entry = entry.next
+
+	memmove(key, entry.data, m.keySize)
+	memmove(value, hashmapEntryValue(m, entry), m.valueSize)
+	return true
+}
+
+// Return the entry for the key, or nil if there isn't one.
+func hashmapFind(m *hashmap, key unsafe.Pointer) *hashmapEntry {
+	if m == nil {
+		return nil
+	}
+
+	hash := hashmapHash(m, key)
+	for entry := *hashmapBucket(m.buckets, hash); entry != nil; entry = entry.next {
+		if entry.hash == hash {
+			if hashmapKeyEqual(m, entry.data, key) {
+				return entry
+			}
+		}
+	}
+	return nil
+}
+
+// Double the number of buckets, moving the entries to their new buckets.
+// The new buckets are only stored in the map at the end, since the old
+// ones are read from the map.
+func hashmapGrow(m *hashmap) {
+	buckets := make([]*hashmapEntry, len(m.buckets)<<1)
+
+	for _, entry := range m.buckets {
+		for entry != nil {
+			next := entry.next
+
+			bucket := hashmapBucket(buckets, entry.hash)
+			entry.next = *bucket
+			*bucket = entry
+
+			entry = next
+		}
+	}
+
+	m.buckets = buckets
+}
+
+// Return the address of the first entry of the bucket for the hash.
+//
+//go:nobounds
+func hashmapBucket(buckets []*hashmapEntry, hash uintptr) **hashmapEntry {
+	return &buckets[hash&uintptr(len(buckets)-1)]
+}
+
+func hashmapEntryValue(m *hashmap, entry *hashmapEntry) unsafe.Pointer {
+	return unsafe.Pointer(uintptr(entry.data) + m.keySize)
+}
+
+// Hash the key with djb2, which only needs shifts and adds.
+func hashmapHash(m *hashmap, key unsafe.Pointer) uintptr {
+	ptr := key
+	size := m.keySize
+	if m.keyKind == hashmapKeyString {
+		str := (*_string)(key)
+		ptr = unsafe.Pointer(str.ptr)
+		size = str.length
+	}
+
+	hash := uintptr(5381)
+	for i := uintptr(0); i < size; i++ {
+		hash = (hash << 5) + hash + uintptr(*(*byte)(unsafe.Pointer(uintptr(ptr) + i)))
+	}
+	return hash
+}
+
+// Return true iff the keys are equal.
+func hashmapKeyEqual(m *hashmap, x, y unsafe.Pointer) bool {
+	if m.keyKind == hashmapKeyString {
+		return stringEqual(*(*string)(x), *(*string)(y))
+	}
+	return memequal(x, y, m.keySize)
+}
+
+// Return true iff size bytes at x and y are equal.
+func memequal(x, y unsafe.Pointer, size uintptr) bool {
+	for i := uintptr(0); i < size; i++ {
+		if *(*byte)(unsafe.Pointer(uintptr(x) + i)) != *(*byte)(unsafe.Pointer(uintptr(y) + i)) {
+			return false
+		}
+	}
+	return true
+}
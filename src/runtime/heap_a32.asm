@@ -0,0 +1,7 @@
+; func() uintptr
+heapStart:
+  ld a0, bssEnd
+
+; func() uintptr
+heapEnd:
+  ld a0, heapEndAddress
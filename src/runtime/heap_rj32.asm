@@ -0,0 +1,7 @@
+; func() uintptr
+heapStart:
+  move a0, bssEnd
+
+; func() uintptr
+heapEnd:
+  move a0, heapEndAddress
@@ -0,0 +1,143 @@
+package runtime
+
+import "unsafe"
+
+// This file implements interfaces, type assertions and method calls
+// through interfaces.
+
+// The most methods a type or interface can have. The tables are only
+// as long as they need to be, this just sets the size of the arrays.
+const maxMethods = 256
+
+// The low bits of the type code of strings, named or not.
+const stringCode = 17 << 1
+
+// The underlying struct for the Go interface type.
+type _interface struct {
+	typ  *typeInfo
+	data uintptr
+}
+
+// The table the compiler makes for each type put in an interface. The
+// methods are sorted by method ID.
+type typeInfo struct {
+	code       uintptr
+	numMethods uintptr
+	methods    [maxMethods]method
+}
+
+type method struct {
+	id uintptr
+	fn uintptr
+}
+
+// The table the compiler makes for each interface type asserted to.
+// The method IDs are sorted.
+type interfaceInfo struct {
+	numMethods uintptr
+	methods    [maxMethods]uintptr
+}
+
+// The header of nil interfaces.
+var nilInterface _interface
+
+// Return the address of the method's func in the type table, which is
+// called like a func value.
+//
+//go:nobounds
+func lookupMethod(x *_interface, id uintptr) uintptr {
+	t := x.typ
+	if t == nil {
+		panic("method call on nil interface")
+	}
+	for i := uintptr(0); i < t.numMethods; i++ {
+		if t.methods[i].id == id {
+			return uintptr(unsafe.Pointer(&t.methods[i].fn))
+		}
+	}
+	panic("method not found")
+}
+
+// Return true iff the type has all the methods of the interface.
+//
+//go:nobounds
+func typeImplements(t *typeInfo, iface *interfaceInfo) bool {
+	j := uintptr(0)
+	for i := uintptr(0); i < iface.numMethods; i++ {
+		id := iface.methods[i]
+		for j < t.numMethods && t.methods[j].id < id {
+			j++
+		}
+		if j >= t.numMethods {
+			return false
+		}
+		if t.methods[j].id != id {
+			return false
+		}
+	}
+	return true
+}
+
+// Return the data of the interface, which must have the type code.
+func typeAssert(x *_interface, code uintptr) uintptr {
+	if x.typ == nil {
+		panic("interface conversion: interface is nil")
+	}
+	if x.typ.code != code {
+		panic("interface conversion: wrong type")
+	}
+	return x.data
+}
+
+// Return the data of the interface, and whether it has the type code.
+func typeAssertOk(x *_interface, code uintptr) (uintptr, bool) {
+	if x.typ == nil {
+		return 0, false
+	}
+	if x.typ.code != code {
+		return 0, false
+	}
+	return x.data, true
+}
+
+// Return the interface, which must have the interface's methods.
+func interfaceAssert(x *_interface, iface *interfaceInfo) *_interface {
+	if x.typ == nil {
+		panic("interface conversion: interface is nil")
+	}
+	if !typeImplements(x.typ, iface) {
+		panic("interface conversion: missing method")
+	}
+	return x
+}
+
+// Return the interface, and whether it has the interface's methods.
+func interfaceAssertOk(x *_interface, iface *interfaceInfo) (*_interface, bool) {
+	if x.typ == nil {
+		return &nilInterface, false
+	}
+	if !typeImplements(x.typ, iface) {
+		return &nilInterface, false
+	}
+	return x, true
+}
+
+// Return true iff the interfaces hold the same type and value. Values
+// that don't fit in a word are compared by address, except strings.
+func interfaceEqual(x, y *_interface) bool {
+	if x.typ != y.typ {
+		return false
+	}
+	if x.typ == nil {
+		return true
+	}
+	if x.data == y.data {
+		return true
+	}
+	if x.typ.code&0b111111 == stringCode {
+		if stringEqual(*(*string)(unsafe.Pointer(x.data)), *(*string)(unsafe.Pointer(y.data))) {
+			return true
+		}
+	}
+	return false
+}
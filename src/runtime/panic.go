@@ -0,0 +1,227 @@
+package runtime
+
+import "unsafe"
+
+// This file implements defer, panic and recover. A call of a func that
+// defers calls has a frame on its stack, which is linked into a list in
+// the goroutine's task, and holds the calls it deferred, most recent
+// first.
+//
+// A panic runs the deferred calls of each frame in turn, starting with
+// the innermost. When one of them recovers, the rest of its frame's calls
+// are run, and the func is resumed with the registers saved when it set
+// up its frame, like longjmp, which makes it return. If nothing recovers,
+// the panic is printed and the program halts.
+
+// The number of registers a frame saves, which is enough for either
+// arch. This must match the compiler.
+const deferRegs = 12
+
+// The frame of a call of a func that defers calls, which the compiler
+// puts on its stack. This must match the compiler.
+type deferFrame struct {
+	regs  [deferRegs]uintptr // the registers to resume the call with
+	calls *deferCall         // the calls still to run, most recent first
+	next  *deferFrame        // the frame of a call further up the stack
+}
+
+// A deferred call. This must match the compiler.
+type deferCall struct {
+	next *deferCall
+
+	// the func value to call, and the words to pass to it in the arg
+	// registers, like a goroutine
+	fn   uintptr
+	args unsafe.Pointer
+}
+
+// A panic in progress.
+type _panic struct {
+	value     interface{}
+	where     string // the func, file and line the panic was in
+	recovered bool
+	frame     *deferFrame // the frame whose deferred calls are running
+	next      *_panic     // a panic this one happened during
+}
+
+// The low bits of the type codes of basic types, which are their kind
+// shifted up by one. This must match go/types and the compiler.
+const (
+	boolCode    = 1 << 1
+	intCode     = 2 << 1
+	int8Code    = 3 << 1
+	int16Code   = 4 << 1
+	uintCode    = 7 << 1
+	uint8Code   = 8 << 1
+	uint16Code  = 9 << 1
+	uintptrCode = 12 << 1
+)
+
+// A runtime error, which the checks the compiler adds panic with.
+type runtimeError struct {
+	msg string
+}
+
+func (e *runtimeError) Error() string {
+	return "runtime error: " + e.msg
+}
+
+// Save the registers and stack pointer of the caller in the frame, and
+// return false, or return true when the frame is resumed.
+func deferSave(f *deferFrame) bool
+
+// Restore the registers and stack pointer saved in the frame, which
+// returns true from the deferSave that saved them.
+func deferResume(f *deferFrame)
+
+// Halt the program with an error.
+func abort()
+
+// Set up the frame, and add it to the running task's frames.
+func deferEnter(f *deferFrame) {
+	t := taskCurrent()
+	f.calls = nil
+	f.next = t.defers
+	t.defers = f
+}
+
+// Add the call to the frame's deferred calls.
+func deferPush(f *deferFrame, c *deferCall) {
+	c.next = f.calls
+	f.calls = c
+}
+
+// Run the frame's deferred calls, most recent first, and remove it from
+// the running task's frames, before the func returns.
+func deferRun(f *deferFrame) {
+	for f.calls != nil {
+		c := f.calls
+		f.calls = c.next
+		callFunc(c.fn, c.args)
+	}
+	taskCurrent().defers = f.next
+}
+
+// Panic with the value, running the deferred calls until one of them
+// recovers. If none do, print the panic and halt.
+func gopanic(value interface{}, where string) {
+	t := taskCurrent()
+	p := &_panic{value: value, where: where, next: t.panicking}
+	t.panicking = p
+
+	for t.defers != nil {
+		f := t.defers
+		p.frame = f
+		for f.calls != nil {
+			c := f.calls
+			f.calls = c.next
+			callFunc(c.fn, c.args)
+
+			if p.recovered {
+				t.panicking = unwound(p.next, f)
+				deferRun(f)
+				deferResume(f)
+			}
+		}
+		t.defers = f.next
+	}
+
+	printPanic(p)
+	abort()
+}
+
+// Return the first of the panics that's still running once the frame
+// is resumed. The ones running the deferred calls of the frame, or of a
+// frame further up the stack, were replaced by the panic that recovered,
+// since resuming the frame unwinds them.
+func unwound(p *_panic, resumed *deferFrame) *_panic {
+	for p != nil {
+		for f := p.frame; f != resumed; f = f.next {
+			if f == nil {
+				return p
+			}
+		}
+		p = p.next
+	}
+	return nil
+}
+
+// Panic with a runtime error, for the checks the compiler adds.
+func panicError(msg string, where string) {
+	gopanic(&runtimeError{msg: msg}, where)
+}
+
+// Stop the running task's panic, and return its value, or nil if it's
+// not panicking.
+func gorecover() interface{} {
+	p := taskCurrent().panicking
+	if p == nil || p.recovered {
+		return nil
+	}
+	p.recovered = true
+	return p.value
+}
+
+// Print the panic, after the ones it happened during.
+func printPanic(p *_panic) {
+	if p.next != nil {
+		printPanic(p.next)
+	}
+
+	printstring("panic: ")
+	if e, ok := p.value.(error); ok {
+		printstring(e.Error())
+	} else {
+		printValue(p.value)
+	}
+	if p.recovered {
+		printstring(" [recovered]")
+	}
+	printnl()
+
+	printstring("\tin ")
+	printstring(p.where)
+	printnl()
+}
+
+// Print a value in an interface, if it's a basic type.
+func printValue(value interface{}) {
+	x := (*_interface)(unsafe.Pointer(&value))
+	if x.typ == nil {
+		printstring("nil")
+		return
+	}
+
+	switch x.typ.code & 0b111111 {
+	case stringCode:
+		printstring(*(*string)(unsafe.Pointer(x.data)))
+	case boolCode:
+		if x.data != 0 {
+			printstring("true")
+		} else {
+			printstring("false")
+		}
+	case intCode:
+		printsigned(int(x.data))
+	case int8Code:
+		printsigned(int(int8(x.data)))
+	case int16Code:
+		printsigned(int(int16(x.data)))
+	case uintCode, uint8Code, uint16Code, uintptrCode:
+		printuint(uint(x.data))
+	default:
+		printstring("(value of type code ")
+		printuint(uint(x.typ.code))
+		printstring(")")
+	}
+}
+
+// Print a signed int, which printint doesn't do for negative ones.
+func printsigned(i int) {
+	if i < 0 {
+		putc('-')
+		printuint(uint(-i))
+		return
+	}
+	printuint(uint(i))
+}
@@ -0,0 +1,45 @@
+; the frame saves the stack pointer and return address of the caller,
+; and the registers calls preserve, so that resuming it returns from
+; deferSave a second time. The frame pointer is saved and restored
+; around this by the compiler, so the caller's stack pointer is above
+; where it's saved.
+
+; func(f *deferFrame) bool
+deferSave:
+  add t0, sp, 8
+  st [a0 + 0], t0
+  st [a0 + 4], ra
+  st [a0 + 8], s0
+  st [a0 + 12], s1
+  st [a0 + 16], s2
+  st [a0 + 20], s3
+  st [a0 + 24], s4
+  st [a0 + 28], s5
+  st [a0 + 32], s6
+  st [a0 + 36], s7
+  st [a0 + 40], s8
+  st [a0 + 44], s9
+  ld a0, 0
+
+; func(f *deferFrame)
+deferResume:
+  mov t0, a0
+  ld sp, [t0 + 0]
+  ld ra, [t0 + 4]
+  ld s0, [t0 + 8]
+  ld s1, [t0 + 12]
+  ld s2, [t0 + 16]
+  ld s3, [t0 + 20]
+  ld s4, [t0 + 24]
+  ld s5, [t0 + 28]
+  ld s6, [t0 + 32]
+  ld s7, [t0 + 36]
+  ld s8, [t0 + 40]
+  ld s9, [t0 + 44]
+  ld a0, 1
+  jmp ra
+
+; func()
+abort:
+  brk
+  err
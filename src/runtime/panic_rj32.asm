@@ -0,0 +1,29 @@
+; the frame saves the stack pointer and return address of the caller,
+; and the registers calls preserve, so that resuming it returns from
+; deferSave a second time
+
+; func(f *deferFrame) bool
+deferSave:
+  store [a0, 0], sp
+  store [a0, 1], ra
+  store [a0, 2], s0
+  store [a0, 3], s1
+  store [a0, 4], s2
+  store [a0, 5], s3
+  store [a0, 6], t5
+  move a0, 0
+
+; func(f *deferFrame)
+deferResume:
+  load sp, [a0, 0]
+  load ra, [a0, 1]
+  load s0, [a0, 2]
+  load s1, [a0, 3]
+  load s2, [a0, 4]
+  load s3, [a0, 5]
+  load t5, [a0, 6]
+  move a0, 1
+
+; func()
+abort:
+  error
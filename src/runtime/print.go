@@ -9,6 +9,10 @@ func printstring(s string) {
 	}
 }
 
+func printspace() {
+	putc(' ')
+}
+
 func printnl() {
 	putc('\r')
 	putc('\n')
@@ -18,6 +22,14 @@ func printrune(x rune) {
 	putc(byte(x))
 }
 
+func printbool(x bool) {
+	if x {
+		printstring("true")
+	} else {
+		printstring("false")
+	}
+}
+
 func printint(x int) {
 	i := x
 
@@ -61,3 +73,33 @@ func printint(x int) {
 
 	putc('0' + byte(i))
 }
+
+func printuint(x uint) {
+	if x >= 10 {
+		printuint(x / 10)
+	}
+	putc('0' + byte(x%10))
+}
+
+func printint32(x int32) {
+	printint64(int64(x))
+}
+
+func printuint32(x uint32) {
+	printuint64(uint64(x))
+}
+
+func printint64(x int64) {
+	if x < 0 {
+		putc('-')
+		x = -x
+	}
+	printuint64(uint64(x))
+}
+
+func printuint64(x uint64) {
+	if x >= 10 {
+		printuint64(x / 10)
+	}
+	putc('0' + byte(x%10))
+}
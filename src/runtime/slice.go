@@ -0,0 +1,93 @@
+package runtime
+
+import "unsafe"
+
+// This file implements functions related to Go slices.
+
+// The underlying struct for the Go slice type. A slice is a pointer
+// to this header, and strings share the layout of the first two fields.
+type _slice struct {
+	ptr    unsafe.Pointer
+	length uintptr
+	cap    uintptr
+}
+
+// The header nil slices point to.
+var nilSlice _slice
+
+// Make a slice of length elements with room for cap elements.
+func makeslice(elemsize, length, cap uintptr) *_slice {
+	if length > cap {
+		panic("makeslice: len out of range")
+	}
+	return &_slice{ptr: alloc(elemOffset(cap, elemsize)), length: length, cap: cap}
+}
+
+// Slice the elements starting at ptr.
+func slice(ptr unsafe.Pointer, elemsize, low, high, max uintptr) *_slice {
+	return &_slice{
+		ptr:    unsafe.Pointer(uintptr(ptr) + elemOffset(low, elemsize)),
+		length: high - low,
+		cap:    max - low,
+	}
+}
+
+// Slice a string.
+func stringSlice(s *_string, low, high uintptr) *_string {
+	return &_string{
+		ptr:    (*byte)(unsafe.Pointer(uintptr(unsafe.Pointer(s.ptr)) + low)),
+		length: high - low,
+	}
+}
+
+// Panic if a slice expression is out of range.
+func sliceBounds(low, high, max, cap uintptr) {
+	if low > high || high > max || max > cap {
+		panic("slice bounds out of range")
+	}
+}
+
+// Append elems to s, growing it if there isn't enough room. Elems
+// can also be a string.
+func sliceAppend(s, elems *_slice, elemsize uintptr) *_slice {
+	length := s.length + elems.length
+	if length <= s.cap {
+		memmove(unsafe.Pointer(uintptr(s.ptr)+elemOffset(s.length, elemsize)), elems.ptr, elemOffset(elems.length, elemsize))
+		return &_slice{ptr: s.ptr, length: length, cap: s.cap}
+	}
+
+	cap := s.cap * 2
+	if cap < length {
+		cap = length
+	}
+
+	ptr := alloc(elemOffset(cap, elemsize))
+	memmove(ptr, s.ptr, elemOffset(s.length, elemsize))
+	memmove(unsafe.Pointer(uintptr(ptr)+elemOffset(s.length, elemsize)), elems.ptr, elemOffset(elems.length, elemsize))
+
+	return &_slice{ptr: ptr, length: length, cap: cap}
+}
+
+// Copy as many elements as will fit from src to dst, returning the
+// number copied. Src can also be a string.
+func sliceCopy(dst, src *_slice, elemsize uintptr) int {
+	n := dst.length
+	if src.length < n {
+		n = src.length
+	}
+	memmove(dst.ptr, src.ptr, elemOffset(n, elemsize))
+	return int(n)
+}
+
+// The size of n elements of elemsize, without needing multiplication.
+func elemOffset(n, elemsize uintptr) uintptr {
+	offset := uintptr(0)
+	for elemsize != 0 {
+		if elemsize&1 != 0 {
+			offset += n
+		}
+		n <<= 1
+		elemsize >>= 1
+	}
+	return offset
+}
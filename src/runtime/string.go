@@ -5,6 +5,8 @@
 
 package runtime
 
+import "unsafe"
+
 // This file implements functions related to Go strings.
 
 // The underlying struct for the Go string type.
@@ -18,7 +20,15 @@ type stringIterator struct {
 	index int
 }
 
+// How strings are encoded. This must match the compiler, which uses
+// UTF-16 when bytes are 16 bits wide, and UTF-8 otherwise.
+const (
+	stringUTF8  = 0
+	stringUTF16 = 1
+)
+
 // Return true iff the strings match.
+//
 //go:nobounds
 func stringEqual(x, y string) bool {
 	if len(x) != len(y) {
@@ -33,6 +43,7 @@ func stringEqual(x, y string) bool {
 }
 
 // Return true iff x < y.
+//
 //go:nobounds
 func stringLess(x, y string) bool {
 	l := len(x)
@@ -47,197 +58,261 @@ func stringLess(x, y string) bool {
 			return false
 		}
 	}
-	return len(x) < len(y)
+	if len(x) < len(y) {
+		return true
+	}
+	return false
 }
 
 // Add two strings together.
-// func stringConcat(x, y _string) _string {
-// 	if x.length == 0 {
-// 		return y
-// 	} else if y.length == 0 {
-// 		return x
-// 	} else {
-// 		length := x.length + y.length
-// 		buf := alloc(length, nil)
-// 		memcpy(buf, unsafe.Pointer(x.ptr), x.length)
-// 		memcpy(unsafe.Pointer(uintptr(buf)+x.length), unsafe.Pointer(y.ptr), y.length)
-// 		return _string{ptr: (*byte)(buf), length: length}
-// 	}
-// }
+func stringConcat(x, y *_string) *_string {
+	if x.length == 0 {
+		return y
+	} else if y.length == 0 {
+		return x
+	}
+
+	length := x.length + y.length
+	buf := alloc(length)
+	memmove(buf, unsafe.Pointer(x.ptr), x.length)
+	memmove(unsafe.Pointer(uintptr(buf)+x.length), unsafe.Pointer(y.ptr), y.length)
+	return &_string{ptr: (*byte)(buf), length: length}
+}
 
 // Create a string from a []byte slice.
-// func stringFromBytes(x struct {
-// 	ptr *byte
-// 	len uintptr
-// 	cap uintptr
-// }) _string {
-// 	buf := alloc(x.len, nil)
-// 	memcpy(buf, unsafe.Pointer(x.ptr), x.len)
-// 	return _string{ptr: (*byte)(buf), length: x.len}
-// }
+func stringFromBytes(b *_slice) *_string {
+	buf := alloc(b.length)
+	memmove(buf, b.ptr, b.length)
+	return &_string{ptr: (*byte)(buf), length: b.length}
+}
 
 // Convert a string to a []byte slice.
-// func stringToBytes(x _string) (slice struct {
-// 	ptr *byte
-// 	len uintptr
-// 	cap uintptr
-// }) {
-// 	buf := alloc(x.length, nil)
-// 	memcpy(buf, unsafe.Pointer(x.ptr), x.length)
-// 	slice.ptr = (*byte)(buf)
-// 	slice.len = x.length
-// 	slice.cap = x.length
-// 	return
-// }
+func stringToBytes(s *_string) *_slice {
+	buf := alloc(s.length)
+	memmove(buf, unsafe.Pointer(s.ptr), s.length)
+	return &_slice{ptr: buf, length: s.length, cap: s.length}
+}
 
 // Convert a []rune slice to a string.
-// func stringFromRunes(runeSlice []rune) (s _string) {
-// 	// Count the number of characters that will be in the string.
-// 	for _, r := range runeSlice {
-// 		_, numBytes := encodeUTF8(r)
-// 		s.length += numBytes
-// 	}
-
-// 	// Allocate memory for the string.
-// 	s.ptr = (*byte)(alloc(s.length, nil))
-
-// 	// Encode runes to UTF-8 and store the resulting bytes in the string.
-// 	index := uintptr(0)
-// 	for _, r := range runeSlice {
-// 		array, numBytes := encodeUTF8(r)
-// 		for _, c := range array[:numBytes] {
-// 			*(*byte)(unsafe.Pointer(uintptr(unsafe.Pointer(s.ptr)) + index)) = c
-// 			index++
-// 		}
-// 	}
-
-// 	return
-// }
-
-// Convert a string to []rune slice.
-// func stringToRunes(s string) []rune {
-// 	var n = 0
-// 	for range s {
-// 		n++
-// 	}
-// 	var r = make([]rune, n)
-// 	n = 0
-// 	for _, e := range s {
-// 		r[n] = e
-// 		n++
-// 	}
-// 	return r
-// }
+func stringFromRunes(runes []rune, encoding uintptr) *_string {
+	length := uintptr(0)
+	for _, r := range runes {
+		length += runeLen(r, encoding)
+	}
+
+	buf := alloc(length)
+	n := uintptr(0)
+	for _, r := range runes {
+		n += encodeRune(r, unsafe.Pointer(uintptr(buf)+n), encoding)
+	}
+
+	return &_string{ptr: (*byte)(buf), length: length}
+}
+
+// Convert a string to a []rune slice. Ranging over the string decodes
+// it with the encoding strings have.
+func stringToRunes(s string) []rune {
+	n := 0
+	for range s {
+		n++
+	}
+
+	runes := make([]rune, n)
+	n = 0
+	for _, r := range s {
+		runes[n] = r
+		n++
+	}
+
+	return runes
+}
 
 // Create a string from a Unicode code point.
-// func stringFromUnicode(x rune) _string {
-// 	array, length := encodeUTF8(x)
-// 	// Array will be heap allocated.
-// 	// The heap most likely doesn't work with blocks below 4 bytes, so there's
-// 	// no point in allocating a smaller buffer for the string here.
-// 	return _string{ptr: (*byte)(unsafe.Pointer(&array)), length: length}
-// }
+func stringFromRune(r rune, encoding uintptr) *_string {
+	length := runeLen(r, encoding)
+	buf := alloc(length)
+	encodeRune(r, buf, encoding)
+	return &_string{ptr: (*byte)(buf), length: length}
+}
 
 // Iterate over a string.
 // Returns (ok, key, value).
-func stringNext(s string, it *stringIterator) (bool, int, rune) {
+func stringNext(s string, it *stringIterator, encoding uintptr) (bool, int, rune) {
 	i := it.index
 
 	if i >= len(s) {
 		return false, 0, 0
 	}
 
-	val := rune(s[i])
-
-	// todo: utf8? utf16?
-	// r, length := decodeUTF8(s, it.index)
-	// it.index += length
-	it.index++
-
-	return true, i, val
-}
-
-// Convert a Unicode code point into an array of bytes and its length.
-// func encodeUTF8(x rune) ([4]byte, uintptr) {
-// 	// https://stackoverflow.com/questions/6240055/manually-converting-unicode-codepoints-into-utf-8-and-utf-16
-// 	// Note: this code can probably be optimized (in size and speed).
-// 	switch {
-// 	case x <= 0x7f:
-// 		return [4]byte{byte(x), 0, 0, 0}, 1
-// 	case x <= 0x7ff:
-// 		b1 := 0xc0 | byte(x>>6)
-// 		b2 := 0x80 | byte(x&0x3f)
-// 		return [4]byte{b1, b2, 0, 0}, 2
-// 	case 0xd800 <= x && x <= 0xdfff:
-// 		// utf-16 surrogates are replaced with "invalid code point"
-// 		return [4]byte{0xef, 0xbf, 0xbd, 0}, 3
-// 	case x <= 0xffff:
-// 		b1 := 0xe0 | byte(x>>12)
-// 		b2 := 0x80 | byte((x>>6)&0x3f)
-// 		b3 := 0x80 | byte((x>>0)&0x3f)
-// 		return [4]byte{b1, b2, b3, 0}, 3
-// 	case x <= 0x10ffff:
-// 		b1 := 0xf0 | byte(x>>18)
-// 		b2 := 0x80 | byte((x>>12)&0x3f)
-// 		b3 := 0x80 | byte((x>>6)&0x3f)
-// 		b4 := 0x80 | byte((x>>0)&0x3f)
-// 		return [4]byte{b1, b2, b3, b4}, 4
-// 	default:
-// 		// Invalid Unicode code point.
-// 		return [4]byte{0xef, 0xbf, 0xbd, 0}, 3
-// 	}
-// }
-
-// Decode a single UTF-8 character from a string.
+	if encoding == stringUTF16 {
+		r, size := decodeUTF16(s, i)
+		it.index += size
+		return true, i, r
+	}
+
+	r, size := decodeUTF8(s, i)
+	it.index += size
+	return true, i, r
+}
+
+// Return the number of bytes it takes to encode the rune.
+func runeLen(r rune, encoding uintptr) uintptr {
+	if encoding == stringUTF16 {
+		return 1
+	}
+	return runeLenUTF8(r)
+}
+
+// Write the encoding of the rune to buf, and return the number of bytes
+// written.
+func encodeRune(r rune, buf unsafe.Pointer, encoding uintptr) uintptr {
+	if encoding == stringUTF16 {
+		return encodeUTF16(r, buf)
+	}
+	return encodeUTF8(r, buf)
+}
+
+// Decode a single UTF-8 character from a string. Invalid encodings are
+// decoded as the replacement character with a length of 1.
+//
+//go:nobounds
+func decodeUTF8(s string, i int) (rune, int) {
+	remaining := len(s) - i
+	x := s[i]
+
+	if x&0x80 == 0x00 { // 0xxxxxxx
+		return rune(x), 1
+	}
+
+	if x&0xe0 == 0xc0 { // 110xxxxx
+		if remaining >= 2 && isContinuation(s[i+1]) {
+			r := rune(x&0x1f)<<6 | rune(s[i+1]&0x3f)
+			if r >= 1<<7 {
+				// only the shortest encoding is valid
+				return r, 2
+			}
+		}
+	} else if x&0xf0 == 0xe0 { // 1110xxxx
+		if remaining >= 3 && isContinuation(s[i+1]) && isContinuation(s[i+2]) {
+			r := rune(x&0x0f)<<12 | rune(s[i+1]&0x3f)<<6 | rune(s[i+2]&0x3f)
+			if r >= 1<<11 && (r < 0xd800 || r > 0xdfff) {
+				// surrogates are not allowed in UTF-8
+				return r, 3
+			}
+		}
+	} else if x&0xf8 == 0xf0 { // 11110xxx
+		if remaining >= 4 && isContinuation(s[i+1]) && isContinuation(s[i+2]) && isContinuation(s[i+3]) {
+			r := rune(x&0x07)<<18 | rune(s[i+1]&0x3f)<<12 | rune(s[i+2]&0x3f)<<6 | rune(s[i+3]&0x3f)
+			if r >= 1<<16 && r <= 0x10ffff {
+				return r, 4
+			}
+		}
+	}
+
+	return 0xfffd, 1
+}
+
+// Return true iff this is a UTF-8 continuation byte.
+func isContinuation(b byte) bool {
+	if b&0xc0 == 0x80 {
+		return true
+	}
+	return false
+}
+
+// Return the number of bytes in the UTF-8 encoding of the rune.
+func runeLenUTF8(r rune) uintptr {
+	if !validRune(r) {
+		r = 0xfffd
+	}
+
+	if r < 1<<7 {
+		return 1
+	}
+	if r < 1<<11 {
+		return 2
+	}
+	if r < 1<<16 {
+		return 3
+	}
+	return 4
+}
+
+// Write the UTF-8 encoding of the rune to buf, and return its length.
+// Invalid runes are encoded as the replacement character.
+func encodeUTF8(r rune, buf unsafe.Pointer) uintptr {
+	if !validRune(r) {
+		r = 0xfffd
+	}
+
+	if r < 1<<7 {
+		setByte(buf, 0, byte(r))
+		return 1
+	}
+	if r < 1<<11 {
+		setByte(buf, 0, 0xc0|byte(r>>6))
+		setByte(buf, 1, 0x80|byte(r)&0x3f)
+		return 2
+	}
+	if r < 1<<16 {
+		setByte(buf, 0, 0xe0|byte(r>>12))
+		setByte(buf, 1, 0x80|byte(r>>6)&0x3f)
+		setByte(buf, 2, 0x80|byte(r)&0x3f)
+		return 3
+	}
+	setByte(buf, 0, 0xf0|byte(r>>18))
+	setByte(buf, 1, 0x80|byte(r>>12)&0x3f)
+	setByte(buf, 2, 0x80|byte(r>>6)&0x3f)
+	setByte(buf, 3, 0x80|byte(r)&0x3f)
+	return 4
+}
+
+// Decode a single UTF-16 character from a string. Runes are only as big
+// as a byte where strings are UTF-16, so surrogate pairs are decoded as
+// the replacement character.
+//
 //go:nobounds
-// func decodeUTF8(s string, index uintptr) (rune, uintptr) {
-// 	remaining := uintptr(len(s)) - index // must be >= 1 before calling this function
-// 	x := s[index]
-// 	switch {
-// 	case x&0x80 == 0x00: // 0xxxxxxx
-// 		return rune(x), 1
-// 	case x&0xe0 == 0xc0: // 110xxxxx
-// 		if remaining < 2 || !isContinuation(s[index+1]) {
-// 			return 0xfffd, 1
-// 		}
-// 		r := (rune(x&0x1f) << 6) | (rune(s[index+1]) & 0x3f)
-// 		if r >= 1<<7 {
-// 			// Check whether the rune really needed to be encoded as a two-byte
-// 			// sequence. UTF-8 requires every rune to be encoded in the smallest
-// 			// sequence possible.
-// 			return r, 2
-// 		}
-// 	case x&0xf0 == 0xe0: // 1110xxxx
-// 		if remaining < 3 || !isContinuation(s[index+1]) || !isContinuation(s[index+2]) {
-// 			return 0xfffd, 1
-// 		}
-// 		r := (rune(x&0x0f) << 12) | ((rune(s[index+1]) & 0x3f) << 6) | (rune(s[index+2]) & 0x3f)
-// 		if r >= 1<<11 && !(r >= 0xD800 && r <= 0xDFFF) {
-// 			// Check whether the rune really needed to be encoded as a
-// 			// three-byte sequence and check that this is not a Unicode
-// 			// surrogate pair (which are not allowed by UTF-8).
-// 			return r, 3
-// 		}
-// 	case x&0xf8 == 0xf0: // 11110xxx
-// 		if remaining < 4 || !isContinuation(s[index+1]) || !isContinuation(s[index+2]) || !isContinuation(s[index+3]) {
-// 			return 0xfffd, 1
-// 		}
-// 		r := (rune(x&0x07) << 18) | ((rune(s[index+1]) & 0x3f) << 12) | ((rune(s[index+2]) & 0x3f) << 6) | (rune(s[index+3]) & 0x3f)
-// 		if r >= 1<<16 && r <= '\U0010FFFF' {
-// 			// Check whether this rune really needed to be encoded as a four
-// 			// byte sequence and check that the resulting rune is in the valid
-// 			// range (up to at most U+10FFFF).
-// 			return r, 4
-// 		}
-// 	}
-
-// 	// Failed to decode. Return the Unicode replacement character and a length of 1.
-// 	return 0xfffd, 1
-// }
-
-// isContinuation returns true if (and only if) this is a UTF-8 continuation
-// byte.
-// func isContinuation(b byte) bool {
-// 	// Continuation bytes have their topmost bits set to 0b10.
-// 	return b&0xc0 == 0x80
-// }
+func decodeUTF16(s string, i int) (rune, int) {
+	x := uintptr(s[i])
+	if x < 0xd800 || x > 0xdfff {
+		return rune(x), 1
+	}
+
+	if x < 0xdc00 && i+1 < len(s) {
+		y := uintptr(s[i+1])
+		if y >= 0xdc00 && y <= 0xdfff {
+			return 0xfffd, 2
+		}
+	}
+
+	return 0xfffd, 1
+}
+
+// Write the UTF-16 encoding of the rune to buf, and return its length.
+// Surrogates are encoded as the replacement character.
+func encodeUTF16(r rune, buf unsafe.Pointer) uintptr {
+	x := uintptr(r)
+	if x >= 0xd800 && x <= 0xdfff {
+		x = 0xfffd
+	}
+	setByte(buf, 0, byte(x))
+	return 1
+}
+
+// Return true iff the rune is a Unicode code point that can be encoded
+// in UTF-8.
+func validRune(r rune) bool {
+	x := uintptr(r)
+	if x >= 0xd800 && x <= 0xdfff {
+		return false
+	}
+	if x > 0x10ffff {
+		return false
+	}
+	return true
+}
+
+// Set the byte at offset i from ptr.
+func setByte(ptr unsafe.Pointer, i uintptr, b byte) {
+	*(*byte)(unsafe.Pointer(uintptr(ptr) + i)) = b
+}
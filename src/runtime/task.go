@@ -0,0 +1,155 @@
+package runtime
+
+import "unsafe"
+
+// This file implements goroutines with a cooperative scheduler on a
+// single core. Each goroutine runs in a task with its own fixed size
+// stack, and tasks only switch when the running one blocks or exits,
+// so nothing needs locking.
+//
+// Switching stacks is done in assembly, which saves the registers
+// that calls preserve on the stack being switched away from.
+
+// The number of words in the stack of each goroutine. Nothing checks
+// that a goroutine stays within it.
+const taskStackWords = 512
+
+// A goroutine, or the main program, which runs on the initial stack.
+type task struct {
+	sp    uintptr // the stack pointer while switched out, or zero before starting
+	next  *task   // the next task in whichever queue the task is in
+	stack []uintptr
+
+	// the func value the goroutine calls, and the words to pass to it
+	// in the arg registers
+	fn   uintptr
+	args unsafe.Pointer
+
+	// the channel op the task is blocked on
+	data     unsafe.Pointer              // the value to send, or where to receive to
+	ok       bool                        // whether a value was sent, rather than the channel closed
+	cases    *[maxSelectCases]selectCase // the cases of a select
+	numCases uintptr
+	selected uintptr // the case of the select that went ahead
+
+	defers    *deferFrame // the frame of the innermost call that defers calls
+	panicking *_panic     // the panic in progress
+}
+
+// A queue of tasks, linked through their next fields.
+type taskQueue struct {
+	head *task
+	tail *task
+}
+
+var (
+	currentTask *task     // the running task, or nil before it's needed
+	runQueue    taskQueue // the tasks ready to run, in order
+	deadTasks   *task     // tasks that exited, whose stacks are reused
+)
+
+// Save the registers and stack pointer of the running task to from, and
+// restore the ones saved to to by an earlier switch.
+func switchTask(from *uintptr, to uintptr)
+
+// Save the registers and stack pointer of the running task to from like
+// switchTask, and call start on a new stack with top as its top.
+func startTask(from *uintptr, top uintptr, start func())
+
+// Call the func value with the words in args in the arg registers.
+func callFunc(fn uintptr, args unsafe.Pointer)
+
+// Start a goroutine that calls the func value with the args, which the
+// compiler has put in the words for the arg registers. It starts once
+// the running goroutine blocks.
+func spawn(fn uintptr, args unsafe.Pointer) {
+	t := deadTasks
+	if t != nil {
+		deadTasks = t.next
+		t.sp = 0
+	} else {
+		t = &task{stack: make([]uintptr, taskStackWords)}
+	}
+
+	t.fn = fn
+	t.args = args
+	taskReady(t)
+}
+
+// Return the running task. The main program's task is allocated when
+// it's first needed, so programs that don't need it don't have it.
+func taskCurrent() *task {
+	if currentTask == nil {
+		currentTask = &task{}
+	}
+	return currentTask
+}
+
+// Switch to the next ready task, returning when something readies the
+// running task again. Blocking with nothing ready is a deadlock.
+func taskBlock() {
+	from := taskCurrent()
+	to := taskPop(&runQueue)
+	if to == nil {
+		panic("all goroutines are asleep - deadlock!")
+	}
+	currentTask = to
+
+	if to.sp == 0 {
+		startTask(&from.sp, uintptr(unsafe.Pointer(&to.stack[len(to.stack)-1])), taskStart)
+		return
+	}
+	switchTask(&from.sp, to.sp)
+}
+
+// Make the task ready to run once the running task blocks.
+func taskReady(t *task) {
+	taskPush(&runQueue, t)
+}
+
+// Run the goroutine of the task that was just started, on its own
+// stack. There's nothing to return to, so when the goroutine returns,
+// its task exits and runs the next task instead.
+func taskStart() {
+	t := currentTask
+	callFunc(t.fn, t.args)
+
+	t.next = deadTasks
+	deadTasks = t
+	taskBlock()
+}
+
+// Add the task to the end of the queue.
+func taskPush(q *taskQueue, t *task) {
+	t.next = nil
+	if q.tail == nil {
+		q.head = t
+	} else {
+		q.tail.next = t
+	}
+	q.tail = t
+}
+
+// Remove and return the task at the start of the queue, or nil if it's
+// empty.
+func taskPop(q *taskQueue) *task {
+	t := q.head
+	if t != nil {
+		taskRemove(q, t, nil)
+	}
+	return t
+}
+
+// Remove the task from the queue, where prev is the task before it, or
+// nil if it's the first.
+func taskRemove(q *taskQueue, t, prev *task) {
+	if prev == nil {
+		q.head = t.next
+	} else {
+		prev.next = t.next
+	}
+	if q.tail == t {
+		q.tail = prev
+	}
+	t.next = nil
+}
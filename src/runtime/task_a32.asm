@@ -0,0 +1,75 @@
+; the registers calls preserve are saved on the stack of the task being
+; switched from, and its stack pointer is saved in *from. The frame
+; pointer is saved and restored around this by the compiler.
+
+; func(from *uintptr, to uintptr)
+switchTask:
+  sub sp, sp, 44
+  st [sp + 0], ra
+  st [sp + 4], s0
+  st [sp + 8], s1
+  st [sp + 12], s2
+  st [sp + 16], s3
+  st [sp + 20], s4
+  st [sp + 24], s5
+  st [sp + 28], s6
+  st [sp + 32], s7
+  st [sp + 36], s8
+  st [sp + 40], s9
+  st [a0 + 0], sp
+
+  mov sp, a1
+  ld ra, [sp + 0]
+  ld s0, [sp + 4]
+  ld s1, [sp + 8]
+  ld s2, [sp + 12]
+  ld s3, [sp + 16]
+  ld s4, [sp + 20]
+  ld s5, [sp + 24]
+  ld s6, [sp + 28]
+  ld s7, [sp + 32]
+  ld s8, [sp + 36]
+  ld s9, [sp + 40]
+  add sp, sp, 44
+
+; func(from *uintptr, top uintptr, start func())
+startTask:
+  sub sp, sp, 44
+  st [sp + 0], ra
+  st [sp + 4], s0
+  st [sp + 8], s1
+  st [sp + 12], s2
+  st [sp + 16], s3
+  st [sp + 20], s4
+  st [sp + 24], s5
+  st [sp + 28], s6
+  st [sp + 32], s7
+  st [sp + 36], s8
+  st [sp + 40], s9
+  st [a0 + 0], sp
+
+  ; start never returns, so it's jumped to rather than called
+  mov sp, a1
+  mov bp, sp
+  mov t9, a2
+  jmp [a2]
+
+; func(fn uintptr, args unsafe.Pointer)
+callFunc:
+  sub sp, sp, 8
+  st [sp + 0], ra
+
+  mov t9, a0
+  mov t0, a1
+  ld a0, [t0 + 0]
+  ld a1, [t0 + 4]
+  ld a2, [t0 + 8]
+  ld a3, [t0 + 12]
+  ld a4, [t0 + 16]
+  ld a5, [t0 + 20]
+  ld a6, [t0 + 24]
+  ld a7, [t0 + 28]
+  call [t9]
+
+  ld ra, [sp + 0]
+  add sp, sp, 8
@@ -0,0 +1,53 @@
+; the registers calls preserve are saved on the stack of the task being
+; switched from, and its stack pointer is saved in *from
+
+; func(from *uintptr, to uintptr)
+switchTask:
+  sub sp, 6
+  store [sp, 0], ra
+  store [sp, 1], s0
+  store [sp, 2], s1
+  store [sp, 3], s2
+  store [sp, 4], s3
+  store [sp, 5], t5
+  store [a0, 0], sp
+
+  move sp, a1
+  load ra, [sp, 0]
+  load s0, [sp, 1]
+  load s1, [sp, 2]
+  load s2, [sp, 3]
+  load s3, [sp, 4]
+  load t5, [sp, 5]
+  add sp, 6
+
+; func(from *uintptr, top uintptr, start func())
+startTask:
+  sub sp, 6
+  store [sp, 0], ra
+  store [sp, 1], s0
+  store [sp, 2], s1
+  store [sp, 3], s2
+  store [sp, 4], s3
+  store [sp, 5], t5
+  store [a0, 0], sp
+
+  ; start never returns, so it's jumped to rather than called
+  move sp, a1
+  move t4, a2
+  jump callClosure
+
+; func(fn uintptr, args unsafe.Pointer)
+callFunc:
+  sub sp, 1
+  store [sp, 0], ra
+
+  move t4, a0
+  move t0, a1
+  load a0, [t0, 0]
+  load a1, [t0, 1]
+  load a2, [t0, 2]
+  call callClosure
+
+  load ra, [sp, 0]
+  add sp, 1
@@ -0,0 +1,234 @@
+package main
+
+func mul(a, b int) int {
+	return a * b
+}
+
+func div(a, b int) int {
+	return a / b
+}
+
+func rem(a, b int) int {
+	return a % b
+}
+
+func divu(a, b uint) uint {
+	return a / b
+}
+
+func remu(a, b uint) uint {
+	return a % b
+}
+
+func scale(a int, b uint) (int, int, uint, uint) {
+	return a * 8, 4 * a, b / 16, b % 16
+}
+
+// halve divides by powers of two, which round towards zero when the
+// dividend is negative
+func halve(a int) (int, int, int) {
+	return a / 8, a / 2, a / 1
+}
+
+// the int16s are narrower than a word on some arches, so they have to
+// wrap around like they would if they were a word
+
+//go:noinline
+func add16(a, b int16) int16 {
+	return a + b
+}
+
+//go:noinline
+func sub16u(a, b uint16) uint16 {
+	return a - b
+}
+
+//go:noinline
+func mul16(a, b int16) int16 {
+	return a * b
+}
+
+//go:noinline
+func mul16u(a, b uint16) uint16 {
+	return a * b
+}
+
+//go:noinline
+func shl16u(a uint16, s uint) uint16 {
+	return a << s
+}
+
+//go:noinline
+func neg16(a int16) int16 {
+	return -a
+}
+
+//go:noinline
+func not16u(a uint16) uint16 {
+	return ^a
+}
+
+//go:noinline
+func div16(a, b int16) int16 {
+	return a / b
+}
+
+//go:noinline
+func addByte(a, b byte) byte {
+	return a + b
+}
+
+// bytes are as wide as the smallest unit of memory, so they're 16 bits
+// when it is, which is when strings are UTF-16
+var accent = "é"
+
+func wideBytes() bool {
+	if len(accent) == 1 {
+		return true
+	}
+	return false
+}
+
+func main() {
+	if got := mul(7, 6); got != 42 {
+		panic(got)
+	}
+	if got := mul(-7, 6); got != -42 {
+		panic(got)
+	}
+	if got := mul(-7, -6); got != 42 {
+		panic(got)
+	}
+	if got := mul(123, 0); got != 0 {
+		panic(got)
+	}
+
+	if got := div(1234, 13); got != 94 {
+		panic(got)
+	}
+	if got := div(-1234, 13); got != -94 {
+		panic(got)
+	}
+	if got := div(1234, -13); got != -94 {
+		panic(got)
+	}
+	if got := div(-1234, -13); got != 94 {
+		panic(got)
+	}
+	if got := div(5, 7); got != 0 {
+		panic(got)
+	}
+	if got := div(-9, 2); got != -4 {
+		panic(got)
+	}
+
+	if got := rem(1234, 13); got != 12 {
+		panic(got)
+	}
+	if got := rem(-1234, 13); got != -12 {
+		panic(got)
+	}
+	if got := rem(1234, -13); got != 12 {
+		panic(got)
+	}
+
+	if got := divu(30000, 7); got != 4285 {
+		panic(got)
+	}
+	if got := remu(30000, 7); got != 5 {
+		panic(got)
+	}
+	if got := divu(7, 7); got != 1 {
+		panic(got)
+	}
+
+	a, b, c, d := scale(-5, 1000)
+	if got := a; got != -40 {
+		panic(got)
+	}
+	if got := b; got != -20 {
+		panic(got)
+	}
+	if got := c; got != 62 {
+		panic(got)
+	}
+	if got := d; got != 8 {
+		panic(got)
+	}
+
+	a, b, e := halve(-41)
+	if got := a; got != -5 {
+		panic(got)
+	}
+	if got := b; got != -20 {
+		panic(got)
+	}
+	if got := e; got != -41 {
+		panic(got)
+	}
+	a, b, e = halve(41)
+	if got := a; got != 5 {
+		panic(got)
+	}
+	if got := b; got != 20 {
+		panic(got)
+	}
+	if got := e; got != 41 {
+		panic(got)
+	}
+	a, b, _ = halve(-7)
+	if got := a; got != 0 {
+		panic(got)
+	}
+	if got := b; got != -3 {
+		panic(got)
+	}
+	a, _, _ = halve(-32)
+	if got := a; got != -4 {
+		panic(got)
+	}
+
+	if got := int(add16(32767, 1)); got != -32768 {
+		panic(got)
+	}
+	if got := uint(sub16u(0, 1)); got != 65535 {
+		panic(got)
+	}
+	if got := int(mul16(300, 300)); got != 24464 {
+		panic(got)
+	}
+	if got := int(mul16(-300, 300)); got != -24464 {
+		panic(got)
+	}
+	if got := uint(mul16u(300, 300)); got != 24464 {
+		panic(got)
+	}
+	if got := uint(shl16u(0x8001, 1)); got != 2 {
+		panic(got)
+	}
+	if got := int(neg16(-32768)); got != -32768 {
+		panic(got)
+	}
+	if got := uint(not16u(0)); got != 65535 {
+		panic(got)
+	}
+	if got := int(div16(-32768, -1)); got != -32768 {
+		panic(got)
+	}
+	if add16(32767, 1) >= 0 || sub16u(0, 1) != 65535 {
+		panic("wrong answer")
+	}
+
+	if wideBytes() {
+		if got := uint(addByte(200, 100)); got != 300 {
+			panic(got)
+		}
+	} else {
+		if got := uint(addByte(200, 100)); got != 44 {
+			panic(got)
+		}
+	}
+
+	println(1234)
+	println(uint(30000))
+}
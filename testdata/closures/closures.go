@@ -0,0 +1,111 @@
+package main
+
+type op func(a, b int) int
+
+func add(a, b int) int {
+	return a + b
+}
+
+func sub(a, b int) int {
+	return a - b
+}
+
+var ops = []op{add, sub}
+
+func apply(f op, a, b int) int {
+	return f(a, b)
+}
+
+func counter() func() int {
+	n := 0
+	return func() int {
+		n++
+		return n
+	}
+}
+
+func adder(x int) func(int) int {
+	return func(y int) int {
+		return x + y
+	}
+}
+
+func twice(f func(int) int) func(int) int {
+	return func(x int) int {
+		return f(f(x))
+	}
+}
+
+func each(list []int, f func(int)) {
+	for _, v := range list {
+		f(v)
+	}
+}
+
+func main() {
+	if apply(add, 3, 4) != 7 {
+		panic("func value")
+	}
+	if apply(sub, 3, 4) != -1 {
+		panic("another func value")
+	}
+
+	if ops[0](10, 5) != 15 {
+		panic("table add")
+	}
+	if ops[1](10, 5) != 5 {
+		panic("table sub")
+	}
+
+	mul := func(a, b int) int {
+		return a * b
+	}
+	if apply(mul, 6, 7) != 42 {
+		panic("func literal")
+	}
+
+	next := counter()
+	next()
+	next()
+	if next() != 3 {
+		panic("captured var")
+	}
+
+	other := counter()
+	if other() != 1 {
+		panic("separate closures")
+	}
+	if next() != 4 {
+		panic("closure keeps state")
+	}
+
+	add5 := adder(5)
+	if add5(10) != 15 {
+		panic("captured param")
+	}
+	if twice(add5)(1) != 11 {
+		panic("closure of a closure")
+	}
+
+	sum := 0
+	each([]int{1, 2, 3, 4}, func(v int) {
+		sum += v
+	})
+	if sum != 10 {
+		panic("modified capture")
+	}
+
+	var f func(int) int
+	if f != nil {
+		panic("nil func")
+	}
+	f = adder(-1)
+	if f == nil {
+		panic("non-nil func")
+	}
+	if f(1) != 0 {
+		panic("assigned func")
+	}
+
+	println(sum)
+}
@@ -0,0 +1,178 @@
+package main
+
+const debug = false
+
+// wraps adds past the end of 16-bit ints, which wrap around
+func wraps() {
+	var u uint16 = 65535
+	u++
+	if int(u) != 0 {
+		panic("uint16 wrap")
+	}
+
+	var s int16 = 32767
+	s++
+	if int(s) != -32768 {
+		panic("int16 wrap")
+	}
+
+	var n int16 = -32768
+	n = -n
+	if int(n) != -32768 {
+		panic("int16 negate")
+	}
+
+	var m uint16 = 3
+	m = m - 5
+	if int(m) != 65534 {
+		panic("uint16 borrow")
+	}
+
+	var x uint16 = 0x1234
+	if int(^x) != 0xedcb {
+		panic("uint16 invert")
+	}
+	if int(x&^0xff) != 0x1200 {
+		panic("uint16 and not")
+	}
+	if int(x|0x8000) != 0x9234 {
+		panic("uint16 or")
+	}
+	if int(x^0x1034) != 0x200 {
+		panic("uint16 xor")
+	}
+}
+
+//go:noinline
+func overflow16(a, b, c int16) int16 {
+	return a + b<<3 + c
+}
+
+//go:noinline
+func overflowByte(a, b byte) byte {
+	return a + b<<2 - b
+}
+
+// overflows checks overflowing integers that can be narrower than a
+// word wrap around the same when folded as when they're run
+func overflows() {
+	var a, b, c int16 = 30000, 3000, 20000
+	if int(a+b<<3+c) != int(overflow16(30000, 3000, 20000)) {
+		panic("int16 overflow")
+	}
+
+	var x, y byte = 200, 100
+	if int(x+y<<2-y) != int(overflowByte(200, 100)) {
+		panic("byte overflow")
+	}
+}
+
+// compares checks comparisons use the signedness of the values
+func compares() {
+	var a int16 = -1
+	var b uint16 = 65535
+
+	if a >= 0 {
+		panic("signed less")
+	}
+	if b <= 0 {
+		panic("unsigned greater")
+	}
+	if uint16(a) != b {
+		panic("converted equal")
+	}
+	if int16(b) >= 0 {
+		panic("converted greater equal")
+	}
+	if a != -1 {
+		panic("not equal")
+	}
+
+	t := a < 0
+	if !t {
+		panic("not")
+	}
+}
+
+// shifts checks shifts past the size of the value, and signed right
+// shifts, which fill with the sign
+func shifts() {
+	var one uint16 = 1
+	var amt uint = 15
+	if int(one<<amt) != 32768 {
+		panic("shift left")
+	}
+	amt++
+	if int(one<<amt) != 0 {
+		panic("shift out")
+	}
+
+	var neg int16 = -32768
+	if int(neg>>15) != -1 {
+		panic("signed shift right")
+	}
+
+	var top uint16 = 32768
+	if int(top>>15) != 1 {
+		panic("unsigned shift right")
+	}
+}
+
+// divides checks divides round towards zero
+func divides() {
+	a, b := -7, 2
+	if a/b != -3 {
+		panic("signed div")
+	}
+	if a%b != -1 {
+		panic("signed rem")
+	}
+
+	var c, d uint16 = 65535, 16
+	if int(c/d) != 4095 {
+		panic("unsigned div")
+	}
+	if int(c%d) != 15 {
+		panic("unsigned rem")
+	}
+}
+
+// pick only ever takes one way through the loop, since x is always 10
+func pick(n int) int {
+	x := 10
+	if debug {
+		x = 20
+	}
+
+	y := 0
+	k := 1
+	for i := 0; i < n; i++ {
+		if x == 10 {
+			y += k
+		} else {
+			y += 100
+			k = 2
+		}
+		k = k + 0
+	}
+	return y
+}
+
+func main() {
+	wraps()
+	compares()
+	shifts()
+	divides()
+	overflows()
+
+	if pick(3) != 3 {
+		panic("pick")
+	}
+	if pick(0) != 0 {
+		panic("pick none")
+	}
+
+	if debug {
+		panic("unreachable")
+	}
+}
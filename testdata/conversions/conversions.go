@@ -0,0 +1,142 @@
+package main
+
+// the conversions take their values as params so they're done at
+// run time rather than folded
+
+//go:noinline
+func toByte(x int) byte {
+	return byte(x)
+}
+
+//go:noinline
+func toInt8(x int) int8 {
+	return int8(x)
+}
+
+//go:noinline
+func toUint16(x uint32) uint16 {
+	return uint16(x)
+}
+
+//go:noinline
+func toInt16(x int32) int16 {
+	return int16(x)
+}
+
+//go:noinline
+func int8ToByte(x int8) byte {
+	return byte(x)
+}
+
+//go:noinline
+func int8ToUint16(x int8) uint16 {
+	return uint16(x)
+}
+
+//go:noinline
+func byteToInt8(x byte) int8 {
+	return int8(x)
+}
+
+//go:noinline
+func byteToInt(x byte) int {
+	return int(x)
+}
+
+// bytes are as wide as the smallest unit of memory, so they're 16 bits
+// when it is, which is when strings are UTF-16
+var accent = "é"
+
+func wideBytes() bool {
+	if len(accent) == 1 {
+		return true
+	}
+	return false
+}
+
+var bytes [4]int8
+var halves [2]int16
+
+func main() {
+	if got := uint(toUint16(0x10005)); got != 5 {
+		panic(got)
+	}
+	if got := uint(toUint16(0xfffff)); got != 0xffff {
+		panic(got)
+	}
+
+	if got := int(toInt16(40000)); got != -25536 {
+		panic(got)
+	}
+	if got := int(toInt16(-32769)); got != 32767 {
+		panic(got)
+	}
+
+	if got := uint(int8ToUint16(-1)); got != 0xffff {
+		panic(got)
+	}
+	if got := int(toInt8(-5)); got != -5 {
+		panic(got)
+	}
+	if got := byteToInt(200); got != 200 {
+		panic(got)
+	}
+
+	if wideBytes() {
+		if got := uint(toByte(300)); got != 300 {
+			panic(got)
+		}
+		if got := uint(toByte(-1)); got != 0xffff {
+			panic(got)
+		}
+		if got := int(toInt8(200)); got != 200 {
+			panic(got)
+		}
+		if got := uint(int8ToByte(-1)); got != 0xffff {
+			panic(got)
+		}
+		if got := int(byteToInt8(200)); got != 200 {
+			panic(got)
+		}
+	} else {
+		if got := uint(toByte(300)); got != 44 {
+			panic(got)
+		}
+		if got := uint(toByte(-1)); got != 255 {
+			panic(got)
+		}
+		if got := int(toInt8(200)); got != -56 {
+			panic(got)
+		}
+		if got := int(toInt8(-129)); got != 127 {
+			panic(got)
+		}
+		if got := uint(int8ToByte(-1)); got != 255 {
+			panic(got)
+		}
+		if got := int(byteToInt8(200)); got != -56 {
+			panic(got)
+		}
+	}
+
+	// signed values narrower than a word keep their sign in memory
+	bytes[1] = toInt8(-56)
+	if got := int(bytes[1]); got != -56 {
+		panic(got)
+	}
+	halves[1] = int16(toInt8(-3))
+	if got := int(halves[1]); got != -3 {
+		panic(got)
+	}
+	if bytes[1] >= 0 || halves[1] >= 0 {
+		panic("wrong answer")
+	}
+
+	// wrapped values compare like the type they're converted to
+	if toInt16(0x18000) >= 0 || toUint16(0x10000) != 0 {
+		panic("wrong answer")
+	}
+
+	println(uint(toUint16(0x10005)))
+	println(int(toInt16(0x10064)))
+}
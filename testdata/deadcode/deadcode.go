@@ -0,0 +1,80 @@
+package main
+
+var nums = [8]int{1, 2, 3, 4, 5, 6, 7, 8}
+var calls int
+
+func count() int {
+	calls++
+	return calls
+}
+
+// unused computes values that are never used, around the ones that are
+func unused(a, b int) int {
+	x := a * b
+	y := x / (b + 1)
+	_ = y
+	z := nums[a&7]
+	_ = z
+	return a + b
+}
+
+// loopParam has a value that's only passed around the loop to itself
+func loopParam(n int) int {
+	sum := 0
+	dead := 1
+	for i := 0; i < n; i++ {
+		sum += i
+		dead = dead<<1 + i
+	}
+	return sum
+}
+
+// effects calls a func for its effects, ignoring what it returns
+func effects() int {
+	count()
+	_ = count() * 2
+	return calls
+}
+
+// carries only uses the high word of a sum that's wider than a word on
+// some arches, so the low word is dead but its carry is still needed
+func carries(a, b int64) int {
+	sum := a + b
+	return int(sum >> 16)
+}
+
+// divides has a dead divide by a value that's zero, which still panics
+func divides(a, b int) (result int) {
+	defer func() {
+		if recover() != nil {
+			result = -1
+		}
+	}()
+	q := a / b
+	_ = q
+	return a
+}
+
+func main() {
+	if unused(3, 4) != 7 {
+		panic("unused")
+	}
+	if loopParam(10) != 45 {
+		panic("loop param")
+	}
+	if effects() != 2 {
+		panic("effects")
+	}
+	if calls != 2 {
+		panic("calls")
+	}
+	if carries(0xffff, 1) != 1 {
+		panic("carries")
+	}
+	if divides(6, 2) != 6 {
+		panic("divides")
+	}
+	if divides(6, 0) != -1 {
+		panic("divide by zero")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+type worker interface {
+	work(out chan int)
+}
+
+type doubler struct {
+	n int
+}
+
+func (d doubler) work(out chan int) {
+	out <- d.n * 2
+}
+
+func produce(out chan int, n int) {
+	for i := 1; i <= n; i++ {
+		out <- i
+	}
+	close(out)
+}
+
+func sum(in chan int, done chan int) {
+	total := 0
+	for v := range in {
+		total += v
+	}
+	done <- total
+}
+
+func main() {
+	// unbuffered, with a range over the channel until it's closed
+	nums := make(chan int)
+	done := make(chan int)
+	go produce(nums, 10)
+	go sum(nums, done)
+	if <-done != 55 {
+		panic("range over channel")
+	}
+
+	v, ok := <-nums
+	if ok {
+		panic("receive from closed channel")
+	}
+	if v != 0 {
+		panic("zero value from closed channel")
+	}
+
+	// buffered, filled before anything receives
+	buf := make(chan int, 3)
+	buf <- 5
+	buf <- 6
+	buf <- 7
+	if len(buf) != 3 {
+		panic("buffered len")
+	}
+	if cap(buf) != 3 {
+		panic("buffered cap")
+	}
+	if <-buf*100+<-buf*10+<-buf != 567 {
+		panic("buffered order")
+	}
+
+	// a closure and a method through an interface
+	results := make(chan int)
+	offset := 4
+	go func() {
+		results <- offset
+	}()
+	var w worker = doubler{n: 8}
+	go w.work(results)
+	if <-results+<-results != 20 {
+		panic("closure and method")
+	}
+
+	// select between sending and receiving
+	total := 0
+	quit := make(chan bool)
+	go func() {
+		for i := 0; i < 3; i++ {
+			total += <-results
+		}
+		quit <- true
+	}()
+	sent := 0
+	for sent >= 0 {
+		select {
+		case results <- sent + 1:
+			sent++
+		case <-quit:
+			sent = -1
+		}
+	}
+	if total != 6 {
+		panic("select")
+	}
+
+	// select with a default when nothing is ready
+	select {
+	case v := <-results:
+		if v != -1 {
+			panic("select default")
+		}
+	default:
+		total++
+	}
+	if total != 7 {
+		panic("select default")
+	}
+
+	println(total)
+}
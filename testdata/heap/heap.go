@@ -0,0 +1,50 @@
+package main
+
+type point struct {
+	x, y int
+}
+
+func newPoint(x, y int) *point {
+	p := point{x, y}
+	return &p
+}
+
+func counter() *int {
+	n := 0
+	return &n
+}
+
+func greet(name string) string {
+	return "hello, " + name + "!"
+}
+
+func main() {
+	a := newPoint(1, 2)
+	b := newPoint(3, 4)
+	if a == b || a.x != 1 || a.y != 2 || b.x != 3 || b.y != 4 {
+		panic(1)
+	}
+
+	c := counter()
+	for i := 0; i < 5; i++ {
+		*c += 2
+	}
+	if *c != 10 {
+		panic(2)
+	}
+
+	n := *c
+	buf := make([]int, n)
+	for i := range buf {
+		buf[i] = i
+	}
+	if len(buf) != 10 || buf[9] != 9 {
+		panic(3)
+	}
+
+	msg := greet("heap")
+	if len(msg) != 12 || msg[7] != 'h' || msg[11] != '!' {
+		panic(4)
+	}
+	println(msg)
+}
@@ -0,0 +1,118 @@
+package main
+
+type counter struct {
+	count int
+	step  int
+}
+
+// small getters like get are inlined
+func (c *counter) get() int {
+	return c.count
+}
+
+// bump loads and stores the count as well as the step, so it's just
+// too big to be inlined
+func (c *counter) bump() {
+	c.count += c.step
+}
+
+func double(x int) int {
+	return x + x
+}
+
+// swap has more than one result
+func swap(a, b int) (int, int) {
+	return b, a
+}
+
+// abs has more than one return
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// sumTo has a loop, and is inlined even though it's too big
+//
+//go:inline
+func sumTo(n int) int {
+	sum := 0
+	for i := 1; i <= n; i++ {
+		sum += double(i)
+	}
+	return sum
+}
+
+// triple is small, but not inlined
+//
+//go:noinline
+func triple(x int) int {
+	return x + x + x
+}
+
+// fact calls itself, so it can't be inlined into itself
+func fact(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return n * fact(n-1)
+}
+
+// even and odd call each other
+func even(n int) bool {
+	if n == 0 {
+		return true
+	}
+	return odd(n - 1)
+}
+
+func odd(n int) bool {
+	if n == 0 {
+		return false
+	}
+	return even(n - 1)
+}
+
+func main() {
+	c := &counter{step: 3}
+	for i := 0; i < 5; i++ {
+		c.bump()
+	}
+	if c.get() != 15 {
+		panic("getter")
+	}
+
+	if double(double(5)) != 20 {
+		panic("nested")
+	}
+
+	a, b := swap(1, 2)
+	if a != 2 {
+		panic("swap first")
+	}
+	if b != 1 {
+		panic("swap second")
+	}
+
+	if abs(-7) != 7 {
+		panic("abs negative")
+	}
+	if abs(7) != 7 {
+		panic("abs positive")
+	}
+
+	if sumTo(4) != 20 {
+		panic("sum to")
+	}
+	if triple(4) != 12 {
+		panic("triple")
+	}
+	if fact(5) != 120 {
+		panic("fact")
+	}
+
+	if !even(10) || even(7) || !odd(3) {
+		panic("even and odd")
+	}
+}
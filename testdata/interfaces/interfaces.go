@@ -0,0 +1,174 @@
+package main
+
+type Shape interface {
+	Area() int
+	Sides() int
+}
+
+type Namer interface {
+	Name() string
+}
+
+type Square struct {
+	size int
+}
+
+func (s Square) Area() int {
+	return s.size * s.size
+}
+
+func (s Square) Sides() int {
+	return 4
+}
+
+func (s Square) Name() string {
+	return "square"
+}
+
+type Rect struct {
+	w, h int
+}
+
+func (r *Rect) Area() int {
+	return r.w * r.h
+}
+
+func (r *Rect) Sides() int {
+	return 4
+}
+
+func (r *Rect) Grow(n int) {
+	r.w += n
+	r.h += n
+}
+
+type Tri int
+
+func (t Tri) Area() int {
+	return int(t) / 2
+}
+
+func (t Tri) Sides() int {
+	return 3
+}
+
+type Counter struct {
+	n int
+}
+
+func (c *Counter) Add(n int) int {
+	c.n += n
+	return c.n
+}
+
+type Adder interface {
+	Add(n int) int
+}
+
+func total(shapes []Shape) int {
+	sum := 0
+	for _, s := range shapes {
+		sum += s.Area()
+	}
+	return sum
+}
+
+func describe(v interface{}) int {
+	switch x := v.(type) {
+	case nil:
+		return -1
+	case int:
+		return x
+	case string:
+		return len(x)
+	case Square:
+		return x.size
+	case Shape:
+		return x.Sides()
+	}
+	return 0
+}
+
+func main() {
+	r := &Rect{w: 2, h: 3}
+	shapes := []Shape{Square{size: 3}, r, Tri(10)}
+
+	if total(shapes) != 9+6+5 {
+		panic("method calls")
+	}
+	if shapes[2].Sides() != 3 {
+		panic("named basic type")
+	}
+
+	r.Grow(1)
+	if shapes[1].Area() != 12 {
+		panic("pointer receiver")
+	}
+
+	var a Adder = &Counter{}
+	a.Add(3)
+	if a.Add(4) != 7 {
+		panic("state through interface")
+	}
+
+	if sq, ok := shapes[0].(Square); !ok || sq.size != 3 {
+		panic("comma-ok type assert")
+	}
+	if _, ok := shapes[1].(Square); ok {
+		panic("wrong comma-ok type assert")
+	}
+	if shapes[2].(Tri).Area() != 5 {
+		panic("type assert")
+	}
+
+	if n, ok := shapes[0].(Namer); !ok || n.Name() != "square" {
+		panic("interface type assert")
+	}
+	if _, ok := shapes[1].(Namer); ok {
+		panic("wrong interface type assert")
+	}
+
+	if describe(nil) != -1 {
+		panic("nil case")
+	}
+	if describe(42) != 42 {
+		panic("int case")
+	}
+	if describe("hello") != 5 {
+		panic("string case")
+	}
+	if describe(Square{size: 7}) != 7 {
+		panic("struct case")
+	}
+	if describe(r) != 4 {
+		panic("interface case")
+	}
+	if describe(true) != 0 {
+		panic("default case")
+	}
+
+	var s Shape
+	if s != nil {
+		panic("nil interface")
+	}
+	s = Tri(4)
+	if s == nil {
+		panic("non-nil interface")
+	}
+	var other Shape = Tri(4)
+	if s != other {
+		panic("equal interfaces")
+	}
+	other = Tri(6)
+	if s == other {
+		panic("unequal interfaces")
+	}
+
+	var x, y interface{} = "abc", "ab"
+	y = y.(string) + "c"
+	if x != y {
+		panic("equal strings in interfaces")
+	}
+
+	println(total(shapes))
+}
@@ -0,0 +1,95 @@
+package main
+
+type point struct {
+	x, y int
+}
+
+var nums [16]int
+var points [8]point
+var grid [4][4]int
+
+// fill walks the array forwards from the start
+func fill() {
+	for i := 0; i < len(nums); i++ {
+		nums[i] = i * 3
+	}
+}
+
+// sumFrom walks the array from an index that's only known at run time,
+// in steps of more than one
+func sumFrom(start, step int) int {
+	sum := 0
+	for i := start; i < len(nums); i += step {
+		sum += nums[i]
+	}
+	return sum
+}
+
+// backwards walks the array from the end
+func backwards() int {
+	sum := 0
+	for i := len(nums) - 1; i >= 4; i-- {
+		sum = sum*2 + nums[i]&1
+	}
+	return sum
+}
+
+// structs walks an array of structs
+func structs(scale int) int {
+	for i := 0; i < len(points); i++ {
+		points[i].x = i * scale
+		points[i].y = i + scale
+	}
+
+	sum := 0
+	for i := 2; i < len(points); i++ {
+		sum += points[i].x - points[i].y
+	}
+	return sum
+}
+
+// nested walks the rows and columns of a 2d array, with a value the inner
+// loop can hoist
+func nested(n int) int {
+	for r := 0; r < len(grid); r++ {
+		for c := 0; c < len(grid[r]); c++ {
+			grid[r][c] = r*n + c
+		}
+	}
+
+	sum := 0
+	for r := 1; r < len(grid); r++ {
+		for c := r; c < len(grid[r]); c++ {
+			sum += grid[r][c] * (n + r)
+		}
+	}
+	return sum
+}
+
+func main() {
+	fill()
+	if nums[0] != 0 {
+		panic("fill first")
+	}
+	if nums[15] != 45 {
+		panic("fill last")
+	}
+	if sumFrom(0, 1) != 360 {
+		panic("sum all")
+	}
+	if sumFrom(3, 4) != 9+21+33+45 {
+		panic("sum every fourth")
+	}
+	if sumFrom(20, 1) != 0 {
+		panic("sum past the end")
+	}
+	if backwards() != 0xaaa {
+		panic("backwards")
+	}
+	if structs(5) != 78 {
+		panic("structs")
+	}
+	if nested(10) != 1365 {
+		panic("nested")
+	}
+}
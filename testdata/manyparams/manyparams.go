@@ -0,0 +1,33 @@
+package main
+
+func sum(a, b, c, d, e int) int {
+	return a + b + c + d + e
+}
+
+func reverse(a, b, c, d, e int) (int, int, int, int, int) {
+	return e, d, c, b, a
+}
+
+func main() {
+	s := sum(1, 2, 3, 4, 5)
+	if s != 15 {
+		panic(s)
+	}
+
+	a, b, c, d, e := reverse(1, 2, 3, 4, 5)
+	if a != 5 {
+		panic(a)
+	}
+	if b != 4 {
+		panic(b)
+	}
+	if c != 3 {
+		panic(c)
+	}
+	if d != 2 {
+		panic(d)
+	}
+	if e != 1 {
+		panic(e)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+type point struct {
+	x, y int
+}
+
+func count(words []string) map[string]int {
+	counts := make(map[string]int)
+	for _, w := range words {
+		counts[w]++
+	}
+	return counts
+}
+
+func main() {
+	m := make(map[int]int)
+	m[1] = 10
+	m[2] = 20
+	m[3] = 30
+	if len(m) != 3 {
+		panic("len")
+	}
+	if m[2] != 20 {
+		panic("get")
+	}
+	if m[4] != 0 {
+		panic("missing key")
+	}
+
+	m[2] = 25
+	if m[2] != 25 {
+		panic("overwrite")
+	}
+	if len(m) != 3 {
+		panic("len after overwrite")
+	}
+
+	if v, ok := m[3]; !ok || v != 30 {
+		panic("comma-ok found")
+	}
+	if _, ok := m[5]; ok {
+		panic("comma-ok missing")
+	}
+
+	delete(m, 1)
+	if len(m) != 2 {
+		panic("len after delete")
+	}
+	if _, ok := m[1]; ok {
+		panic("deleted key")
+	}
+	delete(m, 100)
+
+	sum := 0
+	for k, v := range m {
+		sum += k + v
+	}
+	if sum != 2+25+3+30 {
+		panic("range")
+	}
+
+	keys := 0
+	for k := range m {
+		keys += k
+	}
+	if keys != 5 {
+		panic("range keys")
+	}
+
+	counts := count([]string{"a", "bb", "a", "ccc", "bb", "a"})
+	if len(counts) != 3 {
+		panic("string keys len")
+	}
+	if counts["a"] != 3 {
+		panic("string key a")
+	}
+	if counts["b"+"b"] != 2 {
+		panic("string key bb")
+	}
+	if counts["ccc"] != 1 {
+		panic("string key ccc")
+	}
+
+	lit := map[string]int{"one": 1, "two": 2}
+	if lit["two"] != 2 {
+		panic("map literal")
+	}
+
+	points := map[point]int{{1, 2}: 12, {3, 4}: 34}
+	if points[point{3, 4}] != 34 {
+		panic("struct keys")
+	}
+	if points[point{4, 3}] != 0 {
+		panic("missing struct key")
+	}
+
+	var none map[int]int
+	if none[1] != 0 {
+		panic("nil map read")
+	}
+	if len(none) != 0 {
+		panic("nil map len")
+	}
+	for range none {
+		panic("nil map range")
+	}
+
+	big := make(map[int]int, 4)
+	for i := 0; i < 40; i++ {
+		big[i] = i * 2
+	}
+	if len(big) != 40 {
+		panic("grown len")
+	}
+	total := 0
+	for i := 0; i < 40; i++ {
+		total += big[i]
+	}
+	if total != 40*39 {
+		panic("grown values")
+	}
+
+	println(len(big) + sum)
+}
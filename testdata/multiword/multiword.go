@@ -0,0 +1,142 @@
+package main
+
+var ticks uint32
+
+func tick(n uint32) uint32 {
+	ticks += n
+	return ticks
+}
+
+func checksum(data []byte) uint32 {
+	sum := uint32(0)
+	for _, b := range data {
+		sum = sum<<5 + sum + uint32(b)
+	}
+	return sum
+}
+
+func add(a, b int32) int32 {
+	return a + b
+}
+
+func sub(a, b int64) int64 {
+	return a - b
+}
+
+func mul(a, b uint32) uint32 {
+	return a * b
+}
+
+func div(a, b int32) (int32, int32) {
+	return a / b, a % b
+}
+
+func shift(x uint32, n uint) (uint32, uint32) {
+	return x << n, x >> n
+}
+
+func less(a, b int32) bool {
+	if a < b {
+		return true
+	}
+	return false
+}
+
+func lessu(a, b uint64) bool {
+	if a < b {
+		return true
+	}
+	return false
+}
+
+func main() {
+	tick(40000)
+	if uint64(tick(40000)) != 80000 {
+		panic("tick")
+	}
+
+	if int64(add(70000, -5)) != 69995 {
+		panic("add")
+	}
+	if int64(add(-70000, 5)) != -69995 {
+		panic("add negative")
+	}
+	if sub(1, 2) != -1 {
+		panic("sub")
+	}
+	if sub(0x100000000, 1) != 0xFFFFFFFF {
+		panic("sub borrow")
+	}
+
+	if uint64(mul(1000, 1000)) != 1000000 {
+		panic("mul")
+	}
+	if uint64(mul(70000, 16)) != 1120000 {
+		panic("mul pow2")
+	}
+
+	q, r := div(-1000000, 7)
+	if int64(q) != -142857 {
+		panic("div")
+	}
+	if int64(r) != -1 {
+		panic("rem")
+	}
+
+	l, h := shift(0x12345678, 4)
+	if uint64(l) != 0x23456780 {
+		panic("shl")
+	}
+	if uint64(h) != 0x01234567 {
+		panic("shr")
+	}
+	l, h = shift(0x12345678, 20)
+	if uint64(l) != 0x67800000 {
+		panic("shl far")
+	}
+	if uint64(h) != 0x123 {
+		panic("shr far")
+	}
+
+	x := int32(-0x10000)
+	if int64(x>>4) != -0x1000 {
+		panic("asr")
+	}
+	if int64(x>>17) != -1 {
+		panic("asr far")
+	}
+
+	if !less(-70000, 5) {
+		panic("less")
+	}
+	if less(70000, -5) {
+		panic("not less")
+	}
+	if !less(65535, 65536) {
+		panic("less word")
+	}
+	if !lessu(0xFFFFFFFF, 0x100000000) {
+		panic("lessu")
+	}
+	if lessu(0x100000000, 0xFFFFFFFF) {
+		panic("not lessu")
+	}
+
+	var big uint64 = 0xFFFFFFFFFFFFFFFF
+	if big+1 != 0 {
+		panic("carry out")
+	}
+	if uint64(uint16(big)) != 0xFFFF {
+		panic("truncate")
+	}
+	if int64(int32(int8(-3))) != -3 {
+		panic("sign extend")
+	}
+
+	if uint64(checksum([]byte("nanogo"))) != 129037730 {
+		panic("checksum")
+	}
+
+	println(tick(1))
+	println(int64(-1234567890123))
+}
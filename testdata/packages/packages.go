@@ -0,0 +1,67 @@
+package main
+
+import "github.com/rj45/nanogo/testdata/packages/shapes"
+
+// Tri is a shape from outside the shapes package
+type Tri int
+
+func (t Tri) Area() int {
+	return int(t) / 2
+}
+
+func (t Tri) Name() string {
+	return "tri"
+}
+
+func double(x int) int {
+	return x * 2
+}
+
+func main() {
+	list := []shapes.Shape{
+		shapes.New("square", 3),
+		shapes.New("rect", 2),
+		Tri(10),
+	}
+	if shapes.Made != 2 {
+		panic("global in another package")
+	}
+	if shapes.Total(list) != 9+6+5 {
+		panic("calls into another package")
+	}
+
+	// the same string literal is in both packages
+	if list[0].Name() != "square" || list[1].Name() != "rect" || list[2].Name() != "tri" {
+		panic("method calls across packages")
+	}
+
+	if sq, ok := list[0].(shapes.Square); !ok || sq.Size != 3 {
+		panic("type assert to a type from another package")
+	}
+	r, ok := list[1].(*shapes.Rect)
+	if !ok {
+		panic("type assert to a pointer from another package")
+	}
+	r.Grow(1)
+	if list[1].Area() != 12 {
+		panic("pointer receiver")
+	}
+
+	// the type tables made in each package need to be the same one
+	var other shapes.Shape = r
+	if list[1] != other {
+		panic("equal interfaces from different packages")
+	}
+
+	if shapes.Apply(list, double) != 2*(9+12+5) {
+		panic("func value passed to another package")
+	}
+
+	grow := r.Grow
+	grow(1)
+	if r.Area() != 20 {
+		panic("method value from another package")
+	}
+
+	println(shapes.Total(list))
+}
@@ -0,0 +1,76 @@
+// Package shapes is imported by the main package, to test programs with
+// more than one package
+package shapes
+
+type Shape interface {
+	Area() int
+	Name() string
+}
+
+type Square struct {
+	Size int
+}
+
+func (s Square) Area() int {
+	return s.Size * s.Size
+}
+
+func (s Square) Name() string {
+	return "square"
+}
+
+type Rect struct {
+	W, H int
+}
+
+func (r *Rect) Area() int {
+	return r.W * r.H
+}
+
+func (r *Rect) Name() string {
+	return "rect"
+}
+
+func (r *Rect) Grow(n int) {
+	r.W += n
+	r.H += n
+}
+
+// Made counts the shapes made with New
+var Made int
+
+var names []string
+
+func init() {
+	names = []string{"square", "rect"}
+}
+
+// New makes a shape by name
+func New(name string, size int) Shape {
+	Made++
+	switch name {
+	case names[0]:
+		return Square{Size: size}
+	case names[1]:
+		return &Rect{W: size, H: size + 1}
+	}
+	return nil
+}
+
+// Total adds up the areas of the shapes
+func Total(shapes []Shape) int {
+	sum := 0
+	for _, s := range shapes {
+		sum += s.Area()
+	}
+	return sum
+}
+
+// Apply calls fn with each shape's area
+func Apply(shapes []Shape, fn func(int) int) int {
+	sum := 0
+	for _, s := range shapes {
+		sum += fn(s.Area())
+	}
+	return sum
+}
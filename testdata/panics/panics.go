@@ -0,0 +1,165 @@
+package main
+
+type T struct{ n int }
+
+func (t *T) inc() { t.n++ }
+
+type E struct{ msg string }
+
+func (e *E) Error() string { return e.msg }
+
+// recovers sets its result from the deferred func
+func recovers() (r int) {
+	defer func() {
+		if x := recover(); x != nil {
+			r = x.(int) + 1
+		}
+	}()
+	panic(41)
+}
+
+// loop defers a call in each iteration, which run in reverse
+func loop(n int) (digits int) {
+	for i := 1; i <= n; i++ {
+		defer func(i int) { digits = digits*10 + i }(i)
+	}
+	return 0
+}
+
+func div(a, b int) (q int, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return a / b, true
+}
+
+func deref(p *T) (n int) {
+	defer func() {
+		if recover() != nil {
+			n = -1
+		}
+	}()
+	return p.n
+}
+
+func index(s []int, i int) (v int) {
+	defer func() {
+		if e, ok := recover().(error); ok && e.Error() == "runtime error: index out of range" {
+			v = -2
+		}
+	}()
+	return s[i]
+}
+
+// nested recovers from a panic in a func it calls, after that func's
+// deferred call runs
+func nested() (r int) {
+	defer func() {
+		recover()
+		r += 100
+	}()
+	inner(&r)
+	return 1
+}
+
+func inner(r *int) {
+	defer func() { *r += 10 }()
+	panic(&E{"boom"})
+}
+
+// repanics panics in a deferred call while panicking, and recovers the
+// second panic, which replaces the first
+func repanics() (r int) {
+	defer func() {
+		r = recover().(int)
+	}()
+	defer func() {
+		panic(4)
+	}()
+	panic(3)
+}
+
+// stillPanicking recovers a panic in a call made by a deferred call,
+// which leaves the first panic running
+func stillPanicking() (r int) {
+	defer func() {
+		r = recover().(int)
+	}()
+	defer func() {
+		recovers()
+	}()
+	panic(5)
+}
+
+func main() {
+	if recovers() != 42 {
+		panic("recovers")
+	}
+	if loop(3) != 321 {
+		panic("loop")
+	}
+
+	q, ok := div(7, 2)
+	if q != 3 {
+		panic("div")
+	}
+	_, ok = div(1, 0)
+	if ok {
+		panic("div by zero not caught")
+	}
+
+	if deref(&T{5}) != 5 {
+		panic("deref")
+	}
+	if deref(nil) != -1 {
+		panic("deref nil")
+	}
+	if index([]int{1, 2}, 1) != 2 {
+		panic("index")
+	}
+	if index([]int{1, 2}, 2) != -2 {
+		panic("index oob")
+	}
+
+	t := &T{}
+	func() {
+		defer t.inc()
+		defer t.inc()
+	}()
+	if t.n != 2 {
+		panic("method")
+	}
+
+	if nested() != 110 {
+		panic("nested")
+	}
+
+	if repanics() != 4 {
+		panic("repanics")
+	}
+	if recover() != nil {
+		panic("recovered a replaced panic")
+	}
+	if stillPanicking() != 5 {
+		panic("still panicking")
+	}
+
+	results := make(chan int)
+	go func() {
+		defer func() {
+			results <- recover().(int)
+		}()
+		panic(7)
+	}()
+	if <-results != 7 {
+		panic("goroutine")
+	}
+
+	if recover() != nil {
+		panic("recovered without panicking")
+	}
+
+	println(t.n + q)
+}
@@ -15,4 +15,6 @@ func main() {
 	if num != 15 {
 		panic(num)
 	}
+
+	println(true, false)
 }
@@ -0,0 +1,77 @@
+package main
+
+var squares []int
+
+func fill(n int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = i + 1
+	}
+	return s
+}
+
+func sum(s []int) int {
+	total := 0
+	for _, v := range s {
+		total += v
+	}
+	return total
+}
+
+func main() {
+	squares = fill(5)
+	if len(squares) != 5 || cap(squares) != 5 {
+		panic(1)
+	}
+	if sum(squares) != 15 {
+		panic(2)
+	}
+
+	var grown []int
+	if grown != nil {
+		panic(3)
+	}
+	for i := 0; i < 10; i++ {
+		grown = append(grown, i)
+	}
+	if grown == nil || len(grown) != 10 || cap(grown) < 10 {
+		panic(4)
+	}
+	if sum(grown) != 45 {
+		panic(5)
+	}
+
+	mid := grown[2:5:7]
+	if len(mid) != 3 || cap(mid) != 5 || mid[0] != 2 {
+		panic(6)
+	}
+	mid = append(mid, 100)
+	if grown[5] != 100 {
+		panic(7)
+	}
+
+	n := copy(grown, grown[1:])
+	if n != 9 || grown[0] != 1 || grown[8] != 9 {
+		panic(8)
+	}
+
+	var arr [4]int
+	part := arr[1:]
+	part[0] = 7
+	if arr[1] != 7 || len(part) != 3 {
+		panic(9)
+	}
+
+	buf := make([]byte, 0, 2)
+	buf = append(buf, "hi "...)
+	buf = append(buf, 't', 'o')
+	if len(buf) != 5 || buf[3] != 't' {
+		panic(10)
+	}
+
+	str := "hello world"
+	world := str[6:]
+	if len(world) != 5 || world[0] != 'w' {
+		panic(11)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+func expect(got, want string, msg string) {
+	if got != want {
+		println(got)
+		panic(msg)
+	}
+}
+
+func join(words []string, sep string) string {
+	res := ""
+	for i, w := range words {
+		if i > 0 {
+			res += sep
+		}
+		res += w
+	}
+	return res
+}
+
+func countRunes(s string) int {
+	n := 0
+	for range s {
+		n++
+	}
+	return n
+}
+
+func sumRunes(s string) int {
+	sum := 0
+	for _, r := range s {
+		sum += int(r)
+	}
+	return sum
+}
+
+func main() {
+	hello := "hello"
+	world := "world"
+
+	greeting := hello + ", " + world
+	if len(greeting) != 12 {
+		panic("concat length")
+	}
+	expect(greeting, "hello, world", "concat")
+	expect(join([]string{"a", "b", "c"}, "-"), "a-b-c", "join")
+
+	if hello == world {
+		panic("equal")
+	}
+	if hello != "hel"+"lo" {
+		panic("not equal")
+	}
+	if hello >= world {
+		panic("less")
+	}
+	if world < hello {
+		panic("not less")
+	}
+	if hello > hello {
+		panic("less equal")
+	}
+	if world <= hello {
+		panic("greater")
+	}
+	if world < "wor" {
+		panic("greater equal prefix")
+	}
+	if "wor" >= world {
+		panic("prefix less")
+	}
+	if "" > hello {
+		panic("empty")
+	}
+
+	expect(greeting[7:], world, "substring to end")
+	expect(greeting[:5], hello, "substring from start")
+	expect(greeting[2:4], "ll", "substring")
+	if len(greeting[5:5]) != 0 {
+		panic("empty substring")
+	}
+
+	b := []byte(hello)
+	if len(b) != 5 {
+		panic("bytes length")
+	}
+	b[0] = 'j'
+	expect(string(b), "jello", "bytes to string")
+	expect(hello, "hello", "bytes are a copy")
+
+	r := []rune(hello)
+	if len(r) != 5 {
+		panic("runes length")
+	}
+	r[4] = 'y'
+	expect(string(r), "helly", "runes to string")
+
+	expect(string(rune('x')), "x", "rune to string")
+
+	accented := "café 世"
+	if countRunes(accented) != 6 {
+		panic("range over runes")
+	}
+	if sumRunes(accented) != 'c'+'a'+'f'+0xe9+' '+0x4e16 {
+		panic("rune values")
+	}
+
+	runes := []rune(accented)
+	if len(runes) != 6 {
+		panic("decoded runes")
+	}
+	if int(runes[3]) != 0xe9 {
+		panic("decoded rune")
+	}
+	expect(string(runes), accented, "encoded runes")
+	expect(string(rune(0x4e16)), "世", "encoded rune")
+
+	println(greeting)
+}
@@ -10,5 +10,18 @@ func SetArch(a Arch) {
 	for _, tag := range a.XformTags2() {
 		activeTags[tag] = true
 	}
+
+	// remove the xforms of the previous arch
+	n := 0
+	for _, xf := range xformers {
+		if !xf.arch {
+			xformers[n] = xf
+			n++
+		}
+	}
+	xformers = xformers[:n]
+
+	registeringArch = true
 	a.RegisterXforms()
+	registeringArch = false
 }
@@ -1,8 +1,11 @@
 package cleanup
 
 import (
+	"go/types"
+
 	"github.com/rj45/nanogo/ir2"
 	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
 	"github.com/rj45/nanogo/xform2"
 )
 
@@ -11,7 +14,9 @@ var _ = xform2.Register(copyElim,
 	xform2.OnOp(op.Copy),
 )
 
-// copyElim eliminates any copies to the same register.
+// copyElim eliminates any copies to the same register or stack slot.
+// Copies between integers of different sizes are kept, since stores
+// pick how much to store by the type of the value.
 // Note: this destroys SSA, so make sure it's no longer needed
 // when this runs.
 func copyElim(it ir2.Iter) {
@@ -21,7 +26,7 @@ func copyElim(it ir2.Iter) {
 		def := instr.Def(i)
 		arg := instr.Arg(i)
 
-		if def.Reg() == arg.Reg() {
+		if locationOf(def) == locationOf(arg) && !resizes(def, arg) {
 			def.ReplaceUsesWith(arg)
 			instr.RemoveArg(arg)
 			instr.RemoveDef(def)
@@ -37,3 +42,16 @@ func copyElim(it ir2.Iter) {
 		}
 	}
 }
+
+// resizes returns whether a copy of an integer changes its size
+func resizes(def, arg *ir2.Value) bool {
+	return isInteger(def.Type) && isInteger(arg.Type) && sizes.Sizeof(def.Type) != sizes.Sizeof(arg.Type)
+}
+
+func isInteger(typ types.Type) bool {
+	if typ == nil {
+		return false
+	}
+	basic, ok := typ.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsInteger != 0
+}
@@ -0,0 +1,39 @@
+package cleanup
+
+import (
+	"github.com/rj45/nanogo/ir2"
+)
+
+// location is a register or stack slot where a Value is stored
+type location struct {
+	kind ir2.Location
+	num  int
+}
+
+func locationOf(val *ir2.Value) location {
+	switch val.Location() {
+	case ir2.InReg:
+		return location{ir2.InReg, int(val.Reg())}
+	case ir2.InParamSlot:
+		return location{ir2.InParamSlot, val.ParamSlot()}
+	case ir2.InArgSlot:
+		return location{ir2.InArgSlot, val.ArgSlot()}
+	case ir2.InSpillSlot:
+		return location{ir2.InSpillSlot, val.SpillSlot()}
+	}
+	return location{val.Location(), int(val.ID)}
+}
+
+// setLocation puts the value in the same register or stack slot as other
+func setLocation(val *ir2.Value, other *ir2.Value) {
+	switch other.Location() {
+	case ir2.InReg:
+		val.SetReg(other.Reg())
+	case ir2.InParamSlot:
+		val.SetParamSlot(other.ParamSlot())
+	case ir2.InArgSlot:
+		val.SetArgSlot(other.ArgSlot())
+	case ir2.InSpillSlot:
+		val.SetSpillSlot(other.SpillSlot())
+	}
+}
@@ -3,7 +3,6 @@ package cleanup
 import (
 	"log"
 
-	"github.com/rj45/nanogo/ir/reg"
 	"github.com/rj45/nanogo/ir2"
 	"github.com/rj45/nanogo/ir2/op"
 	"github.com/rj45/nanogo/xform2"
@@ -19,6 +18,9 @@ var _ = xform2.Register(sequentializeCopies,
 // Based on Algorithm 13 from Benoit Boisinot's thesis, with fixes and
 // extensions by Paul Sokolovsky.
 // https://github.com/pfalcon/parcopy/blob/master/parcopy1.py
+//
+// Rather than using a temp to break cycles, the registers are swapped
+// with three xors, since no register is free after register allocation.
 func sequentializeCopies(it ir2.Iter) {
 	instr := it.Instr()
 
@@ -30,33 +32,49 @@ func sequentializeCopies(it ir2.Iter) {
 		log.Panicf("called with non-copy! %s", instr.LongString())
 	}
 
-	var ready []reg.Reg
-	var todo []reg.Reg
-	pred := make(map[reg.Reg]reg.Reg)
-	loc := make(map[reg.Reg]reg.Reg)
+	var ready []location
+	var todo []location
+	pred := make(map[location]location)
+	loc := make(map[location]location)
 
-	srcs := make(map[reg.Reg]*ir2.Value)
-	dests := make(map[reg.Reg]*ir2.Value)
+	srcs := make(map[location]*ir2.Value)
+	dests := make(map[location]*ir2.Value)
+
+	// the value currently in each location, which changes as
+	// cycles are broken with swaps
+	cur := make(map[location]*ir2.Value)
+	done := make(map[location]bool)
+
+	// the source whose value is in each location now, since a swap
+	// can move a value that was already swapped into a location
+	held := make(map[location]location)
+
+	// whether anything has been copied or swapped into a location yet
+	written := make(map[location]bool)
 
 	// fmt.Println("seq:", instr.Func().Name, instr.LongString())
 
 	var copied [][2]*ir2.Value
+	var consts [][2]*ir2.Value
 
-	emit := func(def, arg *ir2.Value) {
+	// emit copies from the value in a location to the def, where orig
+	// is the arg of the parallel copy that the value came from
+	emit := func(def, arg, orig *ir2.Value) *ir2.Value {
 		cp := it.Insert(op.Copy, def.Type, arg)
 		cpdef := cp.Def(0)
-		cpdef.SetReg(def.Reg())
+		setLocation(cpdef, def)
 		def.ReplaceUsesWith(cpdef)
-		copied = append(copied, [2]*ir2.Value{def, arg})
+		copied = append(copied, [2]*ir2.Value{def, orig})
 		it.Changed()
+		return cpdef
 	}
 
 	for i := 0; i < instr.NumDefs(); i++ {
 		def := instr.Def(i)
 		arg := instr.Arg(i)
 
-		b := def.Reg()
-		a := arg.Reg()
+		b := locationOf(def)
+		a := locationOf(arg)
 
 		if b == a {
 			// wait for copy elimination first
@@ -64,14 +82,18 @@ func sequentializeCopies(it ir2.Iter) {
 		}
 
 		if arg.IsConst() {
-			emit(def, arg)
+			// constants are copied last so that they don't
+			// overwrite a location before it's been copied
+			consts = append(consts, [2]*ir2.Value{def, arg})
 			continue
 		}
 
 		srcs[a] = arg
 		dests[b] = def
+		cur[a] = arg
 
 		loc[a] = a
+		held[a] = a
 		pred[b] = a
 
 		for _, todob := range todo {
@@ -89,7 +111,7 @@ func sequentializeCopies(it ir2.Iter) {
 			continue
 		}
 
-		b := def.Reg()
+		b := locationOf(def)
 
 		if _, found := loc[b]; !found {
 			ready = append(ready, b)
@@ -98,8 +120,16 @@ func sequentializeCopies(it ir2.Iter) {
 
 	for len(todo) > 0 {
 		for len(ready) > 0 {
-			b := ready[len(ready)-1]
-			ready = ready[:len(ready)-1]
+			// stack slots are filled first, while their sources
+			// are still in registers
+			r := len(ready) - 1
+			for i := range ready {
+				if ready[i].kind != ir2.InReg {
+					r = i
+				}
+			}
+			b := ready[r]
+			ready = append(ready[:r], ready[r+1:]...)
 
 			a, found := pred[b]
 			if !found {
@@ -108,7 +138,18 @@ func sequentializeCopies(it ir2.Iter) {
 			c := loc[a]
 
 			// fmt.Println("copy", b, "<-", c)
-			emit(dests[b], srcs[c])
+			// the value may have already been copied out of the source
+			// into c, if the source is copied to more than one place
+			src := c
+			if c.kind != ir2.InReg && !written[a] {
+				// stack slots can't be copied between, so copy from the
+				// source again while it still has the value
+				src = a
+			}
+			cur[b] = emit(dests[b], cur[src], srcs[a])
+			held[b] = a
+			done[b] = true
+			written[b] = true
 
 			for i, td := range todo {
 				if td == c {
@@ -132,10 +173,53 @@ func sequentializeCopies(it ir2.Iter) {
 		b := todo[len(todo)-1]
 		todo = todo[:len(todo)-1]
 
-		if b != loc[pred[b]] {
-			// need test program to verify this
-			panic("todo: temp needed, or figure out swap chain")
+		if done[b] {
+			continue
+		}
+
+		if b == loc[pred[b]] {
+			// a swap already put the value in place
+			def := dests[b]
+			def.ReplaceUsesWith(cur[b])
+			copied = append(copied, [2]*ir2.Value{def, srcs[pred[b]]})
+			continue
+		}
+
+		// b is in a cycle, so swap it with where its value is now,
+		// after which b is done and the value b held is in c
+		c := loc[pred[b]]
+		if b.kind != ir2.InReg || c.kind != ir2.InReg {
+			log.Panicf("cycle through a stack slot needs a temp register: %s", instr.LongString())
+		}
+
+		def := dests[b]
+		bval, cval := cur[b], cur[c]
+
+		x1 := it.Insert(op.Xor, def.Type, bval, cval).Def(0)
+		setLocation(x1, bval)
+		x2 := it.Insert(op.Xor, cval.Type, cval, x1).Def(0)
+		setLocation(x2, cval)
+		x3 := it.Insert(op.Xor, def.Type, x1, x2).Def(0)
+		setLocation(x3, bval)
+
+		cur[b] = x3
+		cur[c] = x2
+		if h, found := held[b]; found {
+			loc[h] = c
+			held[c] = h
 		}
+		held[b] = pred[b]
+		done[b] = true
+		written[b] = true
+		written[c] = true
+
+		def.ReplaceUsesWith(x3)
+		copied = append(copied, [2]*ir2.Value{def, srcs[pred[b]]})
+		it.Changed()
+	}
+
+	for _, pair := range consts {
+		emit(pair[0], pair[1], pair[1])
 	}
 
 	for _, pair := range copied {
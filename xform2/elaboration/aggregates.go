@@ -0,0 +1,125 @@
+package elaboration
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(aggregateLoads,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Load),
+)
+
+var _ = xform2.Register(aggregateStores,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Store),
+)
+
+// aggregateLoads converts loads of values that don't fit in a register
+// into a copy of a pointer to the value in memory.
+//
+// Strings, slices and interfaces are a pointer to their header, which
+// is never modified in place, so the address being loaded from is used
+// directly.
+// Structs and arrays are copied to a local, unless the load goes straight
+// into a store, since their fields can be modified afterwards.
+func aggregateLoads(it ir2.Iter) {
+	instr := it.Instr()
+	typ := instr.Def(0).Type
+	if !isAggregate(typ) {
+		return
+	}
+
+	addr := instr.Arg(0)
+
+	if !isHeader(typ) && !storedNext(instr) {
+		local := it.Insert(op.Local, types.NewPointer(typ), "copy")
+		copyMem(it, local.Def(0), addr, sizes.Sizeof(typ))
+		addr = local.Def(0)
+	}
+
+	// a copy keeps the type of the value for when it's stored
+	it.Update(op.Copy, nil, addr)
+}
+
+// aggregateStores copies the words of values that don't fit in a
+// register into memory
+func aggregateStores(it ir2.Iter) {
+	instr := it.Instr()
+	addr := instr.Arg(0)
+	val := instr.Arg(1)
+	if !isAggregate(val.Type) {
+		return
+	}
+
+	copyMem(it, addr, val, sizes.Sizeof(val.Type))
+	it.Remove()
+}
+
+// copyMem inserts loads and stores to copy size bytes from src to
+// dst, a word at a time, then a byte at a time for what's left over
+func copyMem(it ir2.Iter, dst, src *ir2.Value, size int64) {
+	uintptr := types.Typ[types.Uintptr]
+	offset := int64(0)
+
+	for offset < size {
+		typ := uintptr
+		if size-offset < sizes.WordSize() {
+			typ = types.Typ[types.Byte]
+		}
+
+		from, to := src, dst
+		if offset > 0 {
+			from = it.Insert(op.Add, uintptr, src, offset).Def(0)
+			to = it.Insert(op.Add, uintptr, dst, offset).Def(0)
+		}
+
+		word := it.Insert(op.Load, typ, from)
+		it.Insert(op.Store, nil, to, word)
+
+		offset += sizes.Sizeof(typ)
+	}
+}
+
+// storedNext returns whether the only use of the load is a store
+// of the value immediately after it
+func storedNext(load *ir2.Instr) bool {
+	def := load.Def(0)
+	if def.NumUses() != 1 {
+		return false
+	}
+
+	blk := load.Block()
+	next := load.Index() + 1
+	if next >= blk.NumInstrs() {
+		return false
+	}
+
+	store := blk.Instr(next)
+	return store.Op == op.Store && store.NumArgs() == 2 && store.Arg(1) == def
+}
+
+// isHeader returns whether values of the type are a pointer to a header
+func isHeader(typ types.Type) bool {
+	switch typ := typ.Underlying().(type) {
+	case *types.Slice, *types.Interface:
+		return true
+	case *types.Basic:
+		return typ.Kind() == types.String
+	}
+	return false
+}
+
+// isAggregate returns whether values of the type are kept in memory
+// and referred to by a pointer
+func isAggregate(typ types.Type) bool {
+	switch typ.Underlying().(type) {
+	case *types.Struct, *types.Array:
+		return true
+	}
+	return isHeader(typ)
+}
@@ -0,0 +1,50 @@
+package elaboration
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+)
+
+// boundsCheck splits the block before the current instruction to check
+// the index is less than the length, and panics if it isn't. Funcs marked
+// with //go:nobounds are not checked.
+func boundsCheck(it ir2.Iter, index, length *ir2.Value) {
+	fn := it.Block().Func()
+	if fn.NoBounds {
+		return
+	}
+
+	if index.IsConst() && length.IsConst() {
+		i, _ := ir2.Int64Value(index.Const())
+		n, _ := ir2.Int64Value(length.Const())
+		if i >= 0 && i < n {
+			return
+		}
+	}
+
+	var check []*ir2.Instr
+	if index.IsConst() {
+		// constants go on the right
+		check = append(check, fn.NewInstr(op.LessEqual, types.Typ[types.Bool], length, index))
+	} else {
+		// compare as unsigned so negative indexes fail too
+		if !isUnsigned(index.Type) {
+			cp := fn.NewInstr(op.Copy, types.Typ[types.Uintptr], index)
+			check = append(check, cp)
+			index = cp.Def(0)
+		}
+		check = append(check, fn.NewInstr(op.GreaterEqual, types.Typ[types.Bool], index, length))
+	}
+
+	panicIf(it, "index out of range", check...)
+}
+
+// isUnsigned returns whether the type is an unsigned integer
+func isUnsigned(typ types.Type) bool {
+	if basic, ok := typ.Underlying().(*types.Basic); ok {
+		return basic.Info()&types.IsUnsigned != 0
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package elaboration
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/diag"
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(builtins,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.CallBuiltin),
+)
+
+// builtins converts calls to builtin functions into the instructions
+// or runtime calls that implement them
+func builtins(it ir2.Iter) {
+	instr := it.Instr()
+	name, _ := ir2.StringValue(instr.Arg(0).Const())
+
+	switch name {
+	case "len":
+		// the length is always the second word of a string or slice
+		add := it.Insert(op.Add, types.Typ[types.Uintptr], instr.Arg(1), sizes.WordSize())
+		it.Update(op.Load, instr.Def(0).Type, add.Def(0))
+
+	case "cap":
+		// the capacity is the third word of a slice
+		add := it.Insert(op.Add, types.Typ[types.Uintptr], instr.Arg(1), 2*sizes.WordSize())
+		it.Update(op.Load, instr.Def(0).Type, add.Def(0))
+
+	case "append":
+		if instr.NumArgs() < 3 {
+			// nothing to append
+			instr.Def(0).ReplaceUsesWith(instr.Arg(1))
+			it.Remove()
+			return
+		}
+		updateToRuntimeCall(it, "sliceAppend", instr.Arg(1), instr.Arg(2), elemSize(instr.Func(), instr.Arg(1)))
+
+	case "copy":
+		updateToRuntimeCall(it, "sliceCopy", instr.Arg(1), instr.Arg(2), elemSize(instr.Func(), instr.Arg(1)))
+
+	case "print", "println":
+		var args []*ir2.Value
+		for i := 1; i < instr.NumArgs(); i++ {
+			args = append(args, instr.Arg(i))
+		}
+
+		for i, arg := range args {
+			if name == "println" && i != 0 {
+				insertRuntimeCall(it, "printspace")
+			}
+
+			typ := arg.Type.Underlying()
+			if arg.Type == types.Universe.Lookup("rune").Type() {
+				typ = arg.Type
+			}
+			insertRuntimeCall(it, "print"+typ.String(), arg)
+		}
+
+		if name == "println" {
+			insertRuntimeCall(it, "printnl")
+		}
+
+		it.Remove()
+
+	case "recover":
+		updateToRuntimeCall(it, "gorecover")
+
+	default:
+		diag.Errorf(instr.Pos, "builtin %s is not supported yet", name)
+	}
+}
+
+// insertRuntimeCall inserts a call to a function in the runtime package,
+// or returns nil if it's not there
+func insertRuntimeCall(it ir2.Iter, name string, args ...interface{}) *ir2.Instr {
+	fn := it.Block().Func()
+	callee := runtimeFunc(it.Instr(), name)
+	if callee == nil {
+		return nil
+	}
+
+	var typ types.Type = callee.Sig.Results()
+	if callee.Sig.Results().Len() == 1 {
+		typ = callee.Sig.Results().At(0).Type()
+	}
+
+	args = append([]interface{}{fn.ValueFor(callee.Sig, callee)}, args...)
+	return it.Insert(op.Call, typ, args...)
+}
+
+// updateToRuntimeCall updates the current instruction to be a call
+// to a function in the runtime package, keeping the result type, or
+// leaves it be and returns nil if the function's not there
+func updateToRuntimeCall(it ir2.Iter, name string, args ...interface{}) *ir2.Instr {
+	instr := it.Instr()
+	fn := instr.Func()
+	callee := runtimeFunc(instr, name)
+	if callee == nil {
+		return nil
+	}
+
+	args = append([]interface{}{fn.ValueFor(callee.Sig, callee)}, args...)
+	return it.Update(op.Call, instr.Def(0).Type, args...)
+}
+
+// elemSize returns the size of the elements of a slice as a value
+func elemSize(fn *ir2.Func, slice *ir2.Value) *ir2.Value {
+	elem := slice.Type.Underlying().(*types.Slice).Elem()
+	return fn.ValueFor(types.Typ[types.Uintptr], sizes.Sizeof(elem))
+}
+
+// runtimeFunc looks up a function in the runtime package and marks
+// it as called by the instr's func. If it's not there, that's reported
+// at the instr, and nil is returned.
+func runtimeFunc(instr *ir2.Instr, name string) *ir2.Func {
+	fn := instr.Func()
+	runtime := fn.Package().Program().Package("runtime")
+	if runtime == nil {
+		diag.Errorf(instr.Pos, "runtime not loaded, needed for %s", name)
+		return nil
+	}
+
+	callee := runtime.Func(name)
+	if callee == nil {
+		diag.Errorf(instr.Pos, "runtime.%s not found", name)
+		return nil
+	}
+	callee.Referenced = true
+	fn.NumCalls++
+
+	return callee
+}
@@ -1,6 +1,7 @@
 package elaboration
 
 import (
+	"go/token"
 	"go/types"
 
 	"github.com/rj45/nanogo/ir/reg"
@@ -16,28 +17,37 @@ var _ = xform2.Register(calls,
 
 func calls(it ir2.Iter) {
 	instr := it.Instr()
-	fnType := instr.Arg(0).Type.(*types.Signature)
 
-	if instr.NumArgs() > 1 && instr.Arg(1).Def() != nil && instr.Arg(1).Def().Instr().Op == op.Copy {
+	// already done if the params or results have been assigned locations
+	if instr.NumArgs() > 1 && (instr.Arg(1).InReg() || instr.Arg(1).InArgSlot()) {
 		return
 	}
 
-	if instr.NumDefs() > 0 && instr.Def(0).NumUses() == 1 && instr.Def(0).Use(0).Instr().Op == op.Copy {
+	if instr.NumDefs() > 0 && (instr.Def(0).InReg() || instr.Def(0).InArgSlot()) {
 		return
 	}
 
+	if instr.Arg(0).Reg() == reg.Context {
+		return
+	}
+
+	if !instr.Arg(0).IsConst() {
+		// calling a func value, so pass the closure object along to the
+		// callee in the context register
+		ctxCopy := it.Insert(op.Copy, instr.Arg(0).Type, instr.Arg(0))
+		ctxCopy.Def(0).SetReg(reg.Context)
+		instr.ReplaceArg(0, ctxCopy.Def(0))
+	}
+
 	// todo:
 	// - add parallel copy for clobbered regs?
 
 	if instr.NumArgs() > 1 {
-		params := fnType.Params()
-
-		args := make([]interface{}, instr.NumArgs()-1)
-		for i := 1; i < instr.NumArgs(); i++ {
-			args[i-1] = instr.Arg(i)
-		}
+		// multi-word values are split into several args, so the types
+		// are taken from the args rather than the signature
+		args := instr.Args()[1:]
 
-		paramCopy := it.Insert(op.Copy, params, args...)
+		paramCopy := it.Insert(op.Copy, tupleOf(args), args)
 		for i := 0; i < paramCopy.NumDefs(); i++ {
 			if i < len(reg.ArgRegs) {
 				paramCopy.Def(i).SetReg(reg.ArgRegs[i])
@@ -49,15 +59,12 @@ func calls(it ir2.Iter) {
 	}
 
 	if instr.NumDefs() > 0 {
-		results := fnType.Results()
-
-		args := make([]interface{}, instr.NumDefs())
-		for i := 0; i < instr.NumDefs(); i++ {
-			args[i] = instr.Def(i)
-		}
+		// use the types of the defs rather than the results, since
+		// runtime calls can return a different type with the same layout
+		defs := instr.Defs()
 
 		it.Next()
-		resCopy := it.Insert(op.Copy, results, args...)
+		resCopy := it.Insert(op.Copy, tupleOf(defs), defs)
 		for i := 0; i < resCopy.NumArgs(); i++ {
 			if i < len(reg.ArgRegs) {
 				resCopy.Arg(i).SetReg(reg.ArgRegs[i])
@@ -74,3 +81,12 @@ func calls(it ir2.Iter) {
 		}
 	}
 }
+
+// tupleOf returns a tuple of the types of the values
+func tupleOf(vals []*ir2.Value) *types.Tuple {
+	vars := make([]*types.Var, len(vals))
+	for i, val := range vals {
+		vars[i] = types.NewVar(token.NoPos, nil, "", val.Type)
+	}
+	return types.NewTuple(vars...)
+}
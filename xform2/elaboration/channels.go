@@ -0,0 +1,177 @@
+package elaboration
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
+	"github.com/rj45/nanogo/xform2"
+)
+
+// this is registered before multiWords, since values that are wider
+// than a word need their stores and loads split
+var _ = xform2.Register(channels,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.Once(),
+)
+
+// channels converts channel ops and selects into calls to the runtime,
+// which blocks the goroutine until they can go ahead. A channel is a
+// pointer to the runtime's channel, and values are passed to it by
+// pointer to a local, like maps.
+func channels(it ir2.Iter) {
+	if it.Block() == nil {
+		// funcs implemented in assembly have no blocks
+		return
+	}
+
+	for ; it.HasNext(); it.Next() {
+		instr := it.Instr()
+
+		switch instr.Op {
+		case op.MakeChan:
+			makeChan(it)
+		case op.Send:
+			chanSend(it)
+		case op.Recv:
+			chanRecv(it)
+		case op.Select:
+			chanSelect(it)
+		case op.CallBuiltin:
+			if instr.NumArgs() > 1 && isChan(instr.Arg(1)) {
+				chanBuiltin(it)
+			}
+		}
+	}
+}
+
+func isChan(val *ir2.Value) bool {
+	_, ok := val.Type.Underlying().(*types.Chan)
+	return ok
+}
+
+// chanElem returns the type of the elements of the channel, or typ for
+// nil channels, which are untyped
+func chanElem(c *ir2.Value, typ types.Type) types.Type {
+	if ch, ok := c.Type.Underlying().(*types.Chan); ok {
+		return ch.Elem()
+	}
+	return typ
+}
+
+// makeChan converts making a channel into a call to the runtime to
+// allocate it and its buffer
+func makeChan(it ir2.Iter) {
+	instr := it.Instr()
+	fn := instr.Func()
+	elem := chanElem(instr.Def(0), nil)
+
+	updateToRuntimeCall(it, "chanMake",
+		fn.ValueFor(types.Typ[types.Uintptr], sizes.Sizeof(elem)),
+		instr.Arg(0))
+}
+
+// chanSend converts sending on a channel into a runtime call
+func chanSend(it ir2.Iter) {
+	instr := it.Instr()
+	c := instr.Arg(0)
+
+	value := spill(it, instr.Arg(1), chanElem(c, instr.Arg(1).Type))
+
+	insertRuntimeCall(it, "chanSend", c, value)
+	instr.Update(instr.Op, nil)
+	it.Remove()
+}
+
+// chanRecv converts receiving from a channel into a runtime call which
+// copies the value into a local, and then loading the value
+func chanRecv(it ir2.Iter) {
+	instr := it.Instr()
+	c := instr.Arg(0)
+	typ := chanElem(c, instr.Def(0).Type)
+
+	value := it.Insert(op.Local, types.NewPointer(typ), "value").Def(0)
+	ok := insertRuntimeCall(it, "chanRecv", c, value).Def(0)
+
+	if instr.NumDefs() > 1 {
+		// comma-ok
+		def := instr.Def(1)
+		def.ReplaceUsesWith(ok)
+		instr.RemoveDef(def)
+	}
+
+	it.Update(op.Load, nil, value)
+}
+
+// chanBuiltin converts the builtins that take a channel into runtime
+// calls
+func chanBuiltin(it ir2.Iter) {
+	instr := it.Instr()
+	name, _ := ir2.StringValue(instr.Arg(0).Const())
+	c := instr.Arg(1)
+
+	switch name {
+	case "len":
+		updateToRuntimeCall(it, "chanLen", c)
+
+	case "cap":
+		updateToRuntimeCall(it, "chanCap", c)
+
+	case "close":
+		insertRuntimeCall(it, "chanClose", c)
+		instr.Update(instr.Op, nil)
+		it.Remove()
+	}
+}
+
+// chanSelect converts a select into a call to the runtime with a local
+// array of its cases, each of which is the direction, the channel, and
+// a pointer to the value to send or a local to receive into. The
+// received values are loaded from the locals if they're used.
+func chanSelect(it ir2.Iter) {
+	instr := it.Instr()
+	fn := instr.Func()
+	uintptr := types.Typ[types.Uintptr]
+	word := sizes.WordSize()
+
+	blocking := instr.Arg(0)
+	states := instr.Args()[1:]
+	numCases := len(states) / 3
+
+	cases := it.Insert(op.Local, types.NewPointer(types.NewArray(uintptr, int64(numCases*3))), "cases").Def(0)
+
+	var received []*ir2.Value
+	for i := 0; i < numCases; i++ {
+		dir, c, send := states[i*3], states[i*3+1], states[i*3+2]
+
+		var data *ir2.Value
+		if d, _ := ir2.IntValue(dir.Const()); d == int(types.SendOnly) {
+			data = spill(it, send, chanElem(c, send.Type))
+		} else {
+			typ := chanElem(c, instr.Def(2+len(received)).Type)
+			data = it.Insert(op.Local, types.NewPointer(typ), "value").Def(0)
+			received = append(received, data)
+		}
+
+		addr := it.Insert(op.Add, uintptr, cases, int64(i*3)*word).Def(0)
+		it.Insert(op.Store, nil, addr, dir)
+		addr = it.Insert(op.Add, uintptr, cases, int64(i*3+1)*word).Def(0)
+		it.Insert(op.Store, nil, addr, c)
+		addr = it.Insert(op.Add, uintptr, cases, int64(i*3+2)*word).Def(0)
+		it.Insert(op.Store, nil, addr, data)
+	}
+
+	call := insertRuntimeCall(it, "chanSelect", cases, fn.ValueFor(uintptr, numCases), blocking)
+	instr.Def(0).ReplaceUsesWith(call.Def(0))
+	instr.Def(1).ReplaceUsesWith(call.Def(1))
+
+	for i, data := range received {
+		if def := instr.Def(2 + i); def.NumUses() > 0 {
+			def.ReplaceUsesWith(it.Insert(op.Load, def.Type, data).Def(0))
+		}
+	}
+
+	instr.Update(instr.Op, nil)
+	it.Remove()
+}
@@ -0,0 +1,104 @@
+package elaboration
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(checks,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.Once(),
+)
+
+// checks adds the checks for dereferencing nil pointers and dividing by
+// zero, which panic with a runtime error like failed bounds checks. The
+// runtime checks for nil itself where it needs to, so it isn't checked.
+//
+// A pointer that's been checked isn't checked again until the end of the
+// block, since nothing before that can go around the check.
+func checks(it ir2.Iter) {
+	if it.Block() == nil {
+		// funcs implemented in assembly have no blocks
+		return
+	}
+	if it.Block().Func().Package().Name == "runtime" {
+		return
+	}
+
+	var blk *ir2.Block
+	var checked map[*ir2.Value]bool
+
+	for ; it.HasNext(); it.Next() {
+		instr := it.Instr()
+		if it.Block() != blk {
+			checked = make(map[*ir2.Value]bool)
+		}
+
+		var ptr *ir2.Value
+		switch instr.Op {
+		case op.Load, op.Store, op.IndexAddr:
+			ptr = instr.Arg(0)
+		case op.FieldAddr:
+			ptr = instr.Arg(1)
+		case op.Div, op.Rem:
+			divideCheck(it)
+		}
+
+		if ptr != nil && !checked[ptr] && mayBeNil(ptr) {
+			nilCheck(it, ptr)
+			checked[ptr] = true
+		}
+
+		// checks split the block, and what's after them is still in
+		// the same block as far as they're concerned
+		blk = it.Block()
+	}
+}
+
+// mayBeNil returns whether the value could be a nil pointer, which it
+// can't be if it's the address of something
+func mayBeNil(val *ir2.Value) bool {
+	if _, ok := val.Type.Underlying().(*types.Pointer); !ok {
+		return false
+	}
+
+	if val.IsConst() {
+		return isNil(val)
+	}
+
+	if def := val.Def(); def != nil && def.IsInstr() {
+		switch def.Instr().Op {
+		case op.Local, op.New, op.FieldAddr, op.IndexAddr:
+			return false
+		}
+	}
+	return true
+}
+
+// nilCheck panics before the current instruction if the pointer is nil
+func nilCheck(it ir2.Iter, ptr *ir2.Value) {
+	fn := it.Block().Func()
+	cmp := fn.NewInstr(op.Equal, types.Typ[types.Bool], ptr, fn.ValueFor(ptr.Type, nil))
+
+	panicIf(it, "invalid memory address or nil pointer dereference", cmp)
+}
+
+// divideCheck panics before the current divide or remainder if the
+// divisor is zero
+func divideCheck(it ir2.Iter) {
+	instr := it.Instr()
+	fn := instr.Func()
+	divisor := instr.Arg(1)
+
+	if divisor.IsConst() {
+		if n, ok := ir2.Int64Value(divisor.Const()); ok && n != 0 {
+			return
+		}
+	}
+
+	cmp := fn.NewInstr(op.Equal, types.Typ[types.Bool], divisor, fn.ValueFor(divisor.Type, 0))
+	panicIf(it, "integer divide by zero", cmp)
+}
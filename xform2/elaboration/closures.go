@@ -0,0 +1,63 @@
+package elaboration
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(makeClosures,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.MakeClosure),
+)
+
+// makeClosures allocates a closure object on the heap with the address
+// of the func's code followed by the values bound to its free variables.
+// The func value is a pointer to the closure object.
+func makeClosures(it ir2.Iter) {
+	instr := it.Instr()
+	code := instr.Arg(0)
+	bindings := instr.Args()[1:]
+
+	typs := make([]types.Type, len(bindings))
+	for i, binding := range bindings {
+		typs[i] = binding.Type
+	}
+
+	obj := it.Insert(op.New, types.NewPointer(ir2.ClosureType(typs))).Def(0)
+
+	addr := it.Insert(op.FieldAddr, types.NewPointer(types.Typ[types.Uintptr]), 0, obj)
+	it.Insert(op.Store, nil, addr, code)
+
+	for i, binding := range bindings {
+		addr := it.Insert(op.FieldAddr, types.NewPointer(binding.Type), i+1, obj)
+		it.Insert(op.Store, nil, addr, binding)
+	}
+
+	instr.Def(0).ReplaceUsesWith(obj)
+	instr.Update(instr.Op, nil)
+	it.Remove()
+}
+
+var _ = xform2.Register(freeVars,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.FreeVar),
+)
+
+// freeVars loads free variables out of the closure object
+func freeVars(it ir2.Iter) {
+	instr := it.Instr()
+	index := instr.Arg(0)
+	ctx := instr.Arg(1)
+
+	i, ok := ir2.IntValue(index.Const())
+	if !ok {
+		panic("expected int constant")
+	}
+
+	typ := instr.Def(0).Type
+	addr := it.Insert(op.FieldAddr, types.NewPointer(typ), i+1, ctx)
+	it.Update(op.Load, typ, addr)
+}
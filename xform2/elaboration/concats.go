@@ -0,0 +1,23 @@
+package elaboration
+
+import (
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(stringConcats,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Add),
+)
+
+// stringConcats converts adding strings together into a call to the
+// runtime, which allocates the new string on the heap
+func stringConcats(it ir2.Iter) {
+	instr := it.Instr()
+	if !isHeader(instr.Def(0).Type) {
+		return
+	}
+
+	updateToRuntimeCall(it, "stringConcat", instr.Arg(0), instr.Arg(1))
+}
@@ -0,0 +1,87 @@
+package elaboration
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/diag"
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(conversions,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Convert),
+)
+
+var _ = xform2.Register(conversions,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.ChangeType),
+)
+
+var _ = xform2.Register(conversions,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.ChangeInterface),
+)
+
+// conversions removes type conversions that don't need any
+// instructions to perform. Interface values keep the type table of
+// the value in them, so changing the interface type changes nothing.
+// Conversions to integers narrower than a word wrap the value around
+// to the size of the integer if it might not fit. Other conversions
+// that change the kind of pointer or integer become copies to keep
+// the new type, and conversions to and from strings are done by the
+// runtime.
+func conversions(it ir2.Iter) {
+	instr := it.Instr()
+
+	if instr.Op == op.Convert {
+		if stringConversions(it) {
+			return
+		}
+
+		if _, ok := instr.Def(0).Type.Underlying().(*types.Pointer); ok {
+			// a copy keeps the pointer type, which field addresses need
+			it.Update(op.Copy, nil, instr.Arg(0))
+			return
+		}
+
+		if isSubWord(instr.Def(0).Type) && !fits(instr.Arg(0).Type, instr.Def(0).Type) {
+			narrow(it, instr.Arg(0), instr.Def(0).Type)
+			return
+		}
+
+		if changesKind(instr.Arg(0).Type, instr.Def(0).Type) && sizes.Sizeof(instr.Def(0).Type) <= sizes.WordSize() {
+			// a copy keeps the kind of integer, which comparisons need
+			// for the signedness, and printing needs for the format
+			it.Update(op.Copy, nil, instr.Arg(0))
+			return
+		}
+
+		destsize := sizes.Sizeof(instr.Def(0).Type)
+		srcsize := sizes.Sizeof(instr.Arg(0).Type)
+
+		if srcsize > destsize && srcsize > sizes.WordSize() {
+			diag.Errorf(instr.Pos, "converting %s to %s is not supported yet", instr.Arg(0).Type, instr.Def(0).Type)
+			return
+		}
+	}
+
+	instr.Def(0).ReplaceUsesWith(instr.Arg(0))
+	it.Remove()
+}
+
+// changesKind returns whether converting between the types changes the
+// kind of integer
+func changesKind(from, to types.Type) bool {
+	x, ok := from.Underlying().(*types.Basic)
+	if !ok || x.Info()&types.IsInteger == 0 {
+		return false
+	}
+	y, ok := to.Underlying().(*types.Basic)
+	if !ok || y.Info()&types.IsInteger == 0 {
+		return false
+	}
+	return x.Kind() != y.Kind() || x.Name() != y.Name()
+}
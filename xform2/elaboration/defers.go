@@ -0,0 +1,174 @@
+package elaboration
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(defers,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.Once(),
+)
+
+// deferFrame is the layout of the runtime's deferFrame, which must match
+// the runtime: the registers to resume the call with, then the deferred
+// calls and the next frame up the stack
+var deferFrame = types.NewStruct([]*types.Var{
+	types.NewVar(0, nil, "regs", types.NewArray(types.Typ[types.Uintptr], 12)),
+	types.NewVar(0, nil, "calls", types.Typ[types.Uintptr]),
+	types.NewVar(0, nil, "next", types.Typ[types.Uintptr]),
+}, nil)
+
+// deferCall is the layout of the runtime's deferCall, which must match
+// the runtime: the next deferred call, then the func value and the
+// words for the arg registers to call it with
+var deferCall = types.NewStruct([]*types.Var{
+	types.NewVar(0, nil, "next", types.Typ[types.Uintptr]),
+	types.NewVar(0, nil, "fn", types.Typ[types.Uintptr]),
+	types.NewVar(0, nil, "args", types.Typ[types.Uintptr]),
+}, nil)
+
+// defers converts defers into calls to the runtime. A func that defers
+// calls has a frame on its stack for them, which the runtime links into
+// a list for the goroutine so a panic can run them. The frame also saves
+// the registers at the start of the func, like setjmp, so that when a
+// deferred call recovers from a panic the runtime can resume the func
+// there, which then goes to the recover block to return.
+func defers(it ir2.Iter) {
+	if it.Block() == nil {
+		// funcs implemented in assembly have no blocks
+		return
+	}
+
+	fn := it.Block().Func()
+	if fn.Recover == nil {
+		return
+	}
+
+	var frame *ir2.Value
+	for ; it.HasNext(); it.Next() {
+		instr := it.Instr()
+
+		if frame == nil {
+			// the params and free vars are copied out of their
+			// registers first
+			if instr.Op == op.Copy || instr.Op == op.FreeVar {
+				continue
+			}
+			frame = deferSetup(it)
+			instr = it.Instr()
+		}
+
+		switch instr.Op {
+		case op.Defer:
+			deferPush(it, frame)
+		case op.RunDefers:
+			insertRuntimeCall(it, "deferRun", frame)
+			instr.Update(instr.Op, nil)
+			it.Remove()
+		}
+	}
+}
+
+// deferSetup splits the block before the cursor to set up the frame and
+// save the registers, and go to the recover block when it's resumed
+func deferSetup(it ir2.Iter) *ir2.Value {
+	fn := it.Block().Func()
+	pos := it.Instr().Pos
+
+	// the recover block returns the named results, so they have to be
+	// allocated before the frame is set up
+	for i := 0; i < fn.Recover.NumInstrs(); i++ {
+		for _, arg := range fn.Recover.Instr(i).Args() {
+			def := arg.Def()
+			if def == nil || !def.IsInstr() || def.Block() != it.Block() {
+				continue
+			}
+
+			alloc := def.Instr()
+			if alloc.Op != op.Local && alloc.Op != op.New {
+				continue
+			}
+
+			if alloc == it.Instr() {
+				it.Next()
+			} else if alloc.Index() > it.Instr().Index() {
+				alloc.MoveBefore(it.Instr())
+				it.Next()
+			}
+		}
+	}
+
+	frame := it.Insert(op.Local, types.NewPointer(deferFrame), "defers").Def(0)
+	insertRuntimeCall(it, "deferEnter", frame)
+	resumed := insertRuntimeCall(it, "deferSave", frame).Def(0)
+
+	blk := it.Block()
+	it.SplitBefore()
+
+	branch := fn.NewInstr(op.If, nil, resumed)
+	branch.Pos = pos
+	blk.InsertInstr(-1, branch)
+
+	// it's resumed when the flag is true, which is the first succ
+	blk.AddSucc(fn.Recover)
+	fn.Recover.AddPred(blk)
+	blk.SwapSuccs()
+
+	return frame
+}
+
+// deferPush converts a defer into a call to the runtime to add it to the
+// frame's deferred calls. Each call and its args are on the stack, unless
+// the defer is in a loop, where they could pile up, so they go on the heap.
+func deferPush(it ir2.Iter, frame *ir2.Value) {
+	instr := it.Instr()
+	uintptr := types.Typ[types.Uintptr]
+
+	alloc := op.Local
+	if inLoop(it.Block()) {
+		alloc = op.New
+	}
+
+	fnval, args, ok := callWords(it, alloc)
+	if !ok {
+		return
+	}
+
+	call := it.Insert(alloc, types.NewPointer(deferCall), "defer").Def(0)
+	addr := it.Insert(op.Add, uintptr, call, sizes.WordSize()).Def(0)
+	it.Insert(op.Store, nil, addr, fnval)
+	addr = it.Insert(op.Add, uintptr, call, 2*sizes.WordSize()).Def(0)
+	it.Insert(op.Store, nil, addr, args)
+
+	insertRuntimeCall(it, "deferPush", frame, call)
+	instr.Update(instr.Op, nil)
+	it.Remove()
+}
+
+// inLoop returns whether the block can be reached again from itself
+func inLoop(blk *ir2.Block) bool {
+	seen := make(map[*ir2.Block]bool)
+	work := []*ir2.Block{blk}
+
+	for len(work) > 0 {
+		b := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		for i := 0; i < b.NumSuccs(); i++ {
+			succ := b.Succ(i)
+			if succ == blk {
+				return true
+			}
+			if !seen[succ] {
+				seen[succ] = true
+				work = append(work, succ)
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package elaboration
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/diag"
+	"github.com/rj45/nanogo/ir/reg"
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(goroutines,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Go),
+)
+
+// goroutines converts starting a goroutine into a call to the runtime
+// with the func value, and a block on the heap with a word for each arg
+// register. The runtime puts the words in the arg registers and calls
+// the func value on the goroutine's stack, like any other call to one.
+func goroutines(it ir2.Iter) {
+	instr := it.Instr()
+
+	fnval, args, ok := callWords(it, op.New)
+	if !ok {
+		return
+	}
+
+	insertRuntimeCall(it, "spawn", fnval, args)
+	instr.Update(instr.Op, nil)
+	it.Remove()
+}
+
+// callWords puts the args of the go or defer at the cursor in a block
+// allocated with alloc, with a word for each arg register, and returns
+// the func value to call with them as a word. Methods of the value in
+// an interface are looked up like invoke does, with the data passed as
+// the receiver. Args that don't fit in a word are reported as errors.
+func callWords(it ir2.Iter, alloc op.Op) (fnval, block *ir2.Value, ok bool) {
+	instr := it.Instr()
+	uintptr := types.Typ[types.Uintptr]
+
+	fnval = instr.Arg(0)
+	args := instr.Args()[1:]
+
+	if fnval.IsConst() && fnval.Const().Kind() == ir2.IntConst {
+		// the ID of a method of the value in an interface
+		x := instr.Arg(1)
+		fnval = insertRuntimeCall(it, "lookupMethod", x, instr.Arg(0)).Def(0)
+
+		dataAddr := it.Insert(op.Add, uintptr, x, sizes.WordSize())
+		data := it.Insert(op.Load, uintptr, dataAddr).Def(0)
+		args = append([]*ir2.Value{data}, instr.Args()[2:]...)
+	}
+
+	if len(args) > len(reg.ArgRegs) {
+		diag.Errorf(instr.Pos, "%s with more than %d args is not supported yet", instr.Op, len(reg.ArgRegs))
+		return nil, nil, false
+	}
+
+	block = it.Insert(alloc, types.NewPointer(types.NewArray(uintptr, int64(len(reg.ArgRegs)))), "args").Def(0)
+	for i, arg := range args {
+		if !isHeader(arg.Type) && (isAggregate(arg.Type) || sizes.Sizeof(arg.Type) > sizes.WordSize()) {
+			diag.Errorf(instr.Pos, "%s with %s args is not supported yet", instr.Op, arg.Type)
+			return nil, nil, false
+		}
+
+		// the copy keeps headers from being stored as an aggregate
+		word := it.Insert(op.Copy, uintptr, arg).Def(0)
+		addr := it.Insert(op.Add, uintptr, block, int64(i)*sizes.WordSize()).Def(0)
+		it.Insert(op.Store, nil, addr, word)
+	}
+
+	fnval = it.Insert(op.Copy, uintptr, fnval).Def(0)
+	return fnval, block, true
+}
@@ -19,7 +19,11 @@ var _ = xform2.Register(ifNonCompare,
 func ifNonCompare(it ir2.Iter) {
 	instr := it.Instr()
 	arg := instr.Arg(0)
-	if instr.Arg(0).Def().Instr().IsCompare() {
+	if arg.IsConst() {
+		// constant branches are removed in simplification
+		return
+	}
+	if arg.Def().IsInstr() && arg.Def().Instr().IsCompare() {
 		// if already a compare, do nothing
 		return
 	}
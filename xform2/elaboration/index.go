@@ -0,0 +1,42 @@
+package elaboration
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/diag"
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(stringIndex,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Index),
+)
+
+var _ = xform2.Register(stringIndex,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Lookup),
+)
+
+// stringIndex converts indexing into a string into loading the
+// address of the string's bytes, then loading the byte at the index
+// after checking it's in bounds
+func stringIndex(it ir2.Iter) {
+	instr := it.Instr()
+
+	str := instr.Arg(0)
+	if basic, ok := str.Type.Underlying().(*types.Basic); !ok || basic.Kind() != types.String {
+		diag.Errorf(instr.Pos, "indexing %s is not supported yet", str.Type)
+		return
+	}
+
+	// a string is a pointer to the address of the bytes and the length
+	length := it.Insert(op.Load, types.Typ[types.Uintptr], it.Insert(op.Add, types.Typ[types.Uintptr], str, sizes.WordSize()))
+	boundsCheck(it, instr.Arg(1), length.Def(0))
+
+	addr := it.Insert(op.Load, types.Typ[types.Uintptr], str)
+	elem := it.Insert(op.Add, types.Typ[types.Uintptr], addr.Def(0), instr.Arg(1))
+	it.Update(op.Load, instr.Def(0).Type, elem.Def(0))
+}
@@ -16,14 +16,36 @@ var _ = xform2.Register(indexAddrs,
 
 // indexAddrs converts `IndexAddr` instructions into a `mul` and `add` instruction
 // The `mul` is by a constant which can be optimized into shifts and adds later.
+// Slices and arrays are bounds checked first.
 func indexAddrs(it ir2.Iter) {
 	instr := it.Instr()
+	fn := instr.Func()
 	elem := instr.Def(0).Type.(*types.Pointer).Elem()
 
+	addr := instr.Arg(0)
+	index := instr.Arg(1)
+
+	switch typ := addr.Type.Underlying().(type) {
+	case *types.Slice:
+		// a slice is a pointer to the address of the elements, the length and the capacity
+		length := it.Insert(op.Load, types.Typ[types.Uintptr], it.Insert(op.Add, types.Typ[types.Uintptr], addr, sizes.WordSize()))
+		boundsCheck(it, index, length.Def(0))
+		addr = it.Insert(op.Load, types.Typ[types.Uintptr], addr).Def(0)
+
+	case *types.Pointer:
+		if array, ok := typ.Elem().Underlying().(*types.Array); ok {
+			boundsCheck(it, index, fn.ValueFor(types.Typ[types.Uintptr], array.Len()))
+		}
+	}
+
 	size := sizes.Sizeof(elem)
 
-	mul := it.Insert(op.Mul, types.Typ[types.Int], instr.Arg(1), size)
+	if i, ok := ir2.Int64Value(index.Const()); ok {
+		it.Update(op.Add, nil, addr, fn.ValueFor(types.Typ[types.Int], i*size))
+		return
+	}
+
+	mul := it.Insert(op.Mul, types.Typ[types.Int], index, size)
 
-	instr.Op = op.Add
-	instr.ReplaceArg(1, mul.Def(0))
+	it.Update(op.Add, nil, addr, mul.Def(0))
 }
@@ -0,0 +1,191 @@
+package elaboration
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
+	"github.com/rj45/nanogo/xform2"
+)
+
+// this is registered before multiWords, since the runtime calls it makes
+// need the args split into words like any other call
+var _ = xform2.Register(interfaces,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.Once(),
+)
+
+// interfaces converts interface ops into memory accesses and runtime calls.
+//
+// An interface value is a pointer to a (type, data) header, like strings
+// and slices, where type points to the type table of the value in it.
+// The data is the value itself, which for aggregates is already a
+// pointer to it, so methods can be called with it as the receiver.
+func interfaces(it ir2.Iter) {
+	for ; it.HasNext(); it.Next() {
+		instr := it.Instr()
+
+		switch instr.Op {
+		case op.MakeInterface:
+			makeInterface(it)
+		case op.TypeAssert:
+			typeAssert(it)
+		case op.Invoke:
+			invoke(it)
+		case op.Equal, op.NotEqual:
+			interfaceCompare(it)
+		}
+	}
+}
+
+// interfaceHeader is the layout of the header of an interface value
+var interfaceHeader = types.NewStruct([]*types.Var{
+	types.NewVar(0, nil, "typ", types.Typ[types.Uintptr]),
+	types.NewVar(0, nil, "data", types.Typ[types.Uintptr]),
+}, nil)
+
+// makeInterface builds the header for a value put in an interface.
+// Constants get a header in the data section, so the runtime can panic
+// with a string without allocating.
+func makeInterface(it ir2.Iter) {
+	instr := it.Instr()
+	fn := instr.Func()
+	uintptr := types.Typ[types.Uintptr]
+	table := instr.Arg(0)
+	x := instr.Arg(1)
+
+	if word, ok := constWord(x); ok {
+		hdr := fn.Package().NewTable("iface", []ir2.Const{table.Const(), word})
+		hdr.Referenced = true
+		it.Update(op.Copy, nil, fn.ValueFor(hdr.Type, hdr))
+		return
+	}
+
+	data := x
+	if isAggregate(x.Type) && !isHeader(x.Type) {
+		// box a copy, since the original can be modified afterwards
+		box := it.Insert(op.New, types.NewPointer(x.Type)).Def(0)
+		it.Insert(op.Store, nil, box, x)
+		data = box
+	}
+
+	// the copy keeps the data from being stored as an aggregate
+	data = it.Insert(op.Copy, uintptr, data).Def(0)
+
+	hdr := it.Insert(op.New, types.NewPointer(interfaceHeader)).Def(0)
+	typAddr := it.Insert(op.FieldAddr, types.NewPointer(uintptr), 0, hdr)
+	it.Insert(op.Store, nil, typAddr, table)
+	dataAddr := it.Insert(op.FieldAddr, types.NewPointer(uintptr), 1, hdr)
+	it.Insert(op.Store, nil, dataAddr, data)
+
+	// a copy keeps the interface type of the value
+	it.Update(op.Copy, nil, hdr)
+}
+
+// constWord returns the word for a constant value, if it is one
+func constWord(val *ir2.Value) (ir2.Const, bool) {
+	if !val.IsConst() {
+		return nil, false
+	}
+
+	c := val.Const()
+	switch c.Kind() {
+	case ir2.NilConst:
+		return ir2.ConstFor(0), true
+	case ir2.BoolConst:
+		b, _ := ir2.BoolValue(c)
+		if b {
+			return ir2.ConstFor(1), true
+		}
+		return ir2.ConstFor(0), true
+	case ir2.IntConst, ir2.FuncConst, ir2.GlobalConst:
+		return c, true
+	}
+	return nil, false
+}
+
+// typeAssert calls the runtime to check the type of the value in an
+// interface. Asserting to an interface type gives back the same
+// interface value, otherwise the data is the value.
+func typeAssert(it ir2.Iter) {
+	instr := it.Instr()
+	fn := instr.Func()
+	info := instr.Arg(0)
+	x := instr.Arg(1)
+
+	name := "typeAssert"
+	if types.IsInterface(instr.Def(0).Type) {
+		name = "interfaceAssert"
+	}
+	if instr.NumDefs() > 1 {
+		name += "Ok"
+	}
+
+	// the call results are the same words as the defs, so the defs keep
+	// their types
+	callee := runtimeFunc(instr, name)
+	if callee == nil {
+		return
+	}
+	it.Update(op.Call, nil, fn.ValueFor(callee.Sig, callee), x, info)
+}
+
+// invoke looks up the method in the type table of the value in the
+// interface, and calls it with the data as the receiver. The lookup
+// returns the address of the method's func in the table, which is
+// what a func value points to, so it's called like a func value.
+func invoke(it ir2.Iter) {
+	instr := it.Instr()
+	uintptr := types.Typ[types.Uintptr]
+	id := instr.Arg(0)
+	x := instr.Arg(1)
+	args := instr.Args()[2:]
+
+	entry := insertRuntimeCall(it, "lookupMethod", x, id).Def(0)
+
+	dataAddr := it.Insert(op.Add, uintptr, x, sizes.WordSize())
+	data := it.Insert(op.Load, uintptr, dataAddr).Def(0)
+
+	// a copy gives the entry the type of the method with the receiver
+	// as the first param, like any other func value being called
+	params := append([]*ir2.Value{data}, args...)
+	sig := types.NewSignatureType(nil, nil, nil, tupleOf(params), tupleOf(instr.Defs()), false)
+	fnval := it.Insert(op.Copy, sig, entry).Def(0)
+
+	it.Update(op.Call, nil, fnval, data, args)
+}
+
+// interfaceCompare compares interface values by calling the runtime,
+// or by checking the type for comparisons with nil
+func interfaceCompare(it ir2.Iter) {
+	instr := it.Instr()
+	fn := instr.Func()
+	uintptr := types.Typ[types.Uintptr]
+	x, y := instr.Arg(0), instr.Arg(1)
+
+	if !types.IsInterface(x.Type) && !types.IsInterface(y.Type) {
+		return
+	}
+
+	if isNilInterface(x) {
+		x, y = y, x
+	}
+	if isNilInterface(y) {
+		typ := it.Insert(op.Load, uintptr, x).Def(0)
+		it.Update(instr.Op, nil, typ, fn.ValueFor(uintptr, 0))
+		return
+	}
+
+	equal := insertRuntimeCall(it, "interfaceEqual", x, y).Def(0)
+	it.Update(instr.Op, nil, equal, fn.ValueFor(equal.Type, true))
+}
+
+// isNilInterface returns whether the value is the nil interface
+func isNilInterface(val *ir2.Value) bool {
+	if !val.IsConst() {
+		return false
+	}
+	glob, ok := ir2.GlobalValue(val.Const())
+	return ok && glob.Name == "nilInterface" && glob.Package().Name == "runtime"
+}
@@ -0,0 +1,265 @@
+package elaboration
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/diag"
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
+	"github.com/rj45/nanogo/xform2"
+)
+
+// this is registered before multiWords, since keys and values that
+// are wider than a word need their stores and loads split
+var _ = xform2.Register(maps,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.Once(),
+)
+
+// how the runtime compares and hashes keys, which must match the runtime
+const (
+	mapKeyMemory = 0
+	mapKeyString = 1
+)
+
+// maps converts map ops into calls to the runtime's hash table. A map
+// is a pointer to the table, and keys and values are passed to the
+// runtime by pointer to a local, so it works for any type.
+func maps(it ir2.Iter) {
+	if it.Block() == nil {
+		// funcs implemented in assembly have no blocks
+		return
+	}
+	fn := it.Block().Func()
+
+	// the map each range iterator is ranging over
+	iters := make(map[*ir2.Value]*ir2.Value)
+
+	for ; it.HasNext(); it.Next() {
+		instr := it.Instr()
+
+		switch instr.Op {
+		case op.MakeMap:
+			makeMap(it)
+		case op.MapUpdate:
+			mapUpdate(it)
+		case op.Lookup:
+			if isMap(instr.Arg(0)) {
+				mapLookup(it)
+			}
+		case op.CallBuiltin:
+			if instr.NumArgs() > 1 && isMap(instr.Arg(1)) {
+				mapBuiltin(it)
+			}
+		case op.Range:
+			if isMap(instr.Arg(0)) {
+				iters[instr.Def(0)] = instr.Arg(0)
+				mapRange(it)
+			}
+		}
+	}
+
+	// the nexts may come before the range in the block order
+	for it := fn.InstrIter(); it.HasNext(); it.Next() {
+		instr := it.Instr()
+		if instr.Op != op.Next {
+			continue
+		}
+		if m, ok := iters[instr.Arg(0)]; ok {
+			mapNext(it, m)
+		}
+	}
+}
+
+// isMap returns whether the value is a map. Nil consts are untyped, but
+// the only nil that can be indexed or ranged over is a nil map.
+func isMap(val *ir2.Value) bool {
+	if _, ok := val.Type.Underlying().(*types.Map); ok {
+		return true
+	}
+	return isNil(val)
+}
+
+// mapType returns the type of the map, or a map of the key and elem
+// types for nil maps
+func mapType(m *ir2.Value, key, elem types.Type) *types.Map {
+	if typ, ok := m.Type.Underlying().(*types.Map); ok {
+		return typ
+	}
+	return types.NewMap(key, elem)
+}
+
+// makeMap converts making a map into a call to the runtime to
+// allocate its table
+func makeMap(it ir2.Iter) {
+	instr := it.Instr()
+	fn := instr.Func()
+	uintptr := types.Typ[types.Uintptr]
+	typ := instr.Def(0).Type.Underlying().(*types.Map)
+
+	kind, ok := mapKeyKind(typ.Key())
+	if !ok {
+		diag.Errorf(instr.Pos, "maps with %s keys are not supported yet", typ.Key())
+		return
+	}
+
+	hint := instr.Arg(0)
+	if isNil(hint) {
+		hint = fn.ValueFor(uintptr, 0)
+	}
+
+	updateToRuntimeCall(it, "hashmapMake",
+		fn.ValueFor(uintptr, sizes.Sizeof(typ.Key())),
+		fn.ValueFor(uintptr, sizes.Sizeof(typ.Elem())),
+		fn.ValueFor(uintptr, kind),
+		hint)
+}
+
+// mapKeyKind returns how the runtime compares keys of the type, and
+// whether it can
+func mapKeyKind(typ types.Type) (int, bool) {
+	if basic, ok := typ.Underlying().(*types.Basic); ok && basic.Kind() == types.String {
+		return mapKeyString, true
+	}
+	if comparedByMemory(typ) {
+		return mapKeyMemory, true
+	}
+	return 0, false
+}
+
+// comparedByMemory returns whether values of the type are equal when
+// their bytes in memory are
+func comparedByMemory(typ types.Type) bool {
+	switch typ := typ.Underlying().(type) {
+	case *types.Basic:
+		return typ.Info()&(types.IsBoolean|types.IsInteger) != 0 || typ.Kind() == types.UnsafePointer
+	case *types.Pointer, *types.Chan:
+		return true
+	case *types.Array:
+		return comparedByMemory(typ.Elem())
+	case *types.Struct:
+		for i := 0; i < typ.NumFields(); i++ {
+			if !comparedByMemory(typ.Field(i).Type()) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// mapUpdate converts setting the value for a key into a runtime call
+func mapUpdate(it ir2.Iter) {
+	instr := it.Instr()
+	m := instr.Arg(0)
+	typ := mapType(m, instr.Arg(1).Type, instr.Arg(2).Type)
+
+	key := spill(it, instr.Arg(1), typ.Key())
+	value := spill(it, instr.Arg(2), typ.Elem())
+
+	insertRuntimeCall(it, "hashmapSet", m, key, value)
+	instr.Update(instr.Op, nil)
+	it.Remove()
+}
+
+// mapLookup converts getting the value for a key into a runtime call
+// which copies the value into a local, and then loading the value
+func mapLookup(it ir2.Iter) {
+	instr := it.Instr()
+	fn := instr.Func()
+	m := instr.Arg(0)
+	typ := mapType(m, instr.Arg(1).Type, instr.Def(0).Type)
+
+	key := spill(it, instr.Arg(1), typ.Key())
+	value := it.Insert(op.Local, types.NewPointer(typ.Elem()), "value").Def(0)
+
+	found := insertRuntimeCall(it, "hashmapGet", m, key, value,
+		fn.ValueFor(types.Typ[types.Uintptr], sizes.Sizeof(typ.Elem()))).Def(0)
+
+	if instr.NumDefs() > 1 {
+		// comma-ok
+		ok := instr.Def(1)
+		ok.ReplaceUsesWith(found)
+		instr.RemoveDef(ok)
+	}
+
+	it.Update(op.Load, nil, value)
+}
+
+// mapBuiltin converts the builtins that take a map into runtime calls
+func mapBuiltin(it ir2.Iter) {
+	instr := it.Instr()
+	name, _ := ir2.StringValue(instr.Arg(0).Const())
+	m := instr.Arg(1)
+
+	switch name {
+	case "len":
+		updateToRuntimeCall(it, "hashmapLen", m)
+
+	case "delete":
+		key := spill(it, instr.Arg(2), instr.Arg(2).Type)
+		insertRuntimeCall(it, "hashmapDelete", m, key)
+		instr.Update(instr.Op, nil)
+		it.Remove()
+
+	default:
+		diag.Errorf(instr.Pos, "builtin %s of a map is not supported yet", name)
+	}
+}
+
+// mapRange converts a range over a map into a local iterator, which
+// starts at the first bucket
+func mapRange(it ir2.Iter) {
+	instr := it.Instr()
+	fn := instr.Func()
+	uintptr := types.Typ[types.Uintptr]
+
+	iter := types.NewStruct([]*types.Var{
+		types.NewVar(0, nil, "bucket", uintptr),
+		types.NewVar(0, nil, "entry", uintptr),
+	}, nil)
+
+	local := instr.Def(0)
+	it.Update(op.Local, types.NewPointer(iter), "iter")
+
+	entry := it.InsertAfter(op.Add, uintptr, local, sizes.WordSize()).Def(0)
+	it.InsertAfter(op.Store, nil, entry, fn.ValueFor(uintptr, 0))
+	it.InsertAfter(op.Store, nil, local, fn.ValueFor(uintptr, 0))
+}
+
+// mapNext converts getting the next entry of a range over a map into
+// a runtime call that copies the key and value into locals, and then
+// loads the ones that are used
+func mapNext(it ir2.Iter, m *ir2.Value) {
+	instr := it.Instr()
+	typ := mapType(m, instr.Def(1).Type, instr.Def(2).Type)
+
+	key, value := m, m
+	if !isNil(m) {
+		// nil maps have no entries, so the key and value are never copied
+		// into, and without a key that's used they have no types either
+		key = it.Insert(op.Local, types.NewPointer(typ.Key()), "key").Def(0)
+		value = it.Insert(op.Local, types.NewPointer(typ.Elem()), "value").Def(0)
+	}
+
+	found := insertRuntimeCall(it, "hashmapNext", m, instr.Arg(0), key, value).Def(0)
+	instr.Def(0).ReplaceUsesWith(found)
+
+	if def := instr.Def(1); def.NumUses() > 0 {
+		def.ReplaceUsesWith(it.Insert(op.Load, typ.Key(), key).Def(0))
+	}
+	if def := instr.Def(2); def.NumUses() > 0 {
+		def.ReplaceUsesWith(it.Insert(op.Load, typ.Elem(), value).Def(0))
+	}
+
+	instr.Update(instr.Op, nil)
+	it.Remove()
+}
+
+// spill stores the value in a new local and returns the address of it
+func spill(it ir2.Iter, val *ir2.Value, typ types.Type) *ir2.Value {
+	local := it.Insert(op.Local, types.NewPointer(typ), "spill").Def(0)
+	it.Insert(op.Store, nil, local, val)
+	return local
+}
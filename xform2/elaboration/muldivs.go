@@ -0,0 +1,86 @@
+package elaboration
+
+import (
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(muls,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Mul),
+)
+
+var _ = xform2.Register(divs,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Div),
+)
+
+var _ = xform2.Register(divs,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Rem),
+)
+
+// muls converts multiplies into calls to the runtime if the arch
+// can't multiply. Multiplies by a power of two are left to be turned
+// into shifts. Ones narrower than a word are done as a word first.
+func muls(it ir2.Iter) {
+	if wrapSubWord(it) {
+		return
+	}
+
+	if xform2.HasTag(xform2.HasHardwareMul) {
+		return
+	}
+
+	instr := it.Instr()
+	if isPowerOfTwo(instr.Arg(0)) || isPowerOfTwo(instr.Arg(1)) {
+		return
+	}
+
+	updateToRuntimeCall(it, "mulsi", instr.Arg(0), instr.Arg(1))
+}
+
+// divs converts divides and remainders into calls to the runtime if
+// the arch can't divide. Divides by a power of two, and unsigned
+// remainders by one, are left to be turned into shifts and masks.
+// Signed divides narrower than a word are done as a word first.
+func divs(it ir2.Iter) {
+	if wrapSubWord(it) {
+		return
+	}
+
+	if xform2.HasTag(xform2.HasHardwareDiv) {
+		return
+	}
+
+	instr := it.Instr()
+	unsigned := isUnsigned(instr.Def(0).Type)
+	if (unsigned || instr.Op == op.Div) && isPowerOfTwo(instr.Arg(1)) {
+		return
+	}
+
+	name := "divsi"
+	if instr.Op == op.Rem {
+		name = "modsi"
+	}
+	if unsigned {
+		name = "u" + name
+	}
+
+	updateToRuntimeCall(it, name, instr.Arg(0), instr.Arg(1))
+}
+
+func isPowerOfTwo(val *ir2.Value) bool {
+	_, ok := log2(val)
+	return ok
+}
+
+// log2 returns the power of two a value is, if it is a constant
+// power of two
+func log2(val *ir2.Value) (int64, bool) {
+	if !val.IsConst() {
+		return 0, false
+	}
+	return ir2.Log2Value(val.Const())
+}
@@ -0,0 +1,751 @@
+package elaboration
+
+import (
+	"go/types"
+	"log"
+
+	"github.com/rj45/nanogo/diag"
+	"github.com/rj45/nanogo/ir/reg"
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(multiWords,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.Once(),
+)
+
+// splitter tracks the parts of the multi-word values in a Func
+type splitter struct {
+	fn *ir2.Func
+
+	// parts of each multi-word value, least significant first
+	parts map[*ir2.Value][]*ir2.Value
+
+	// types of the block params before they were split
+	params map[*ir2.Block][]types.Type
+
+	// instrs that have been replaced and need removing
+	dead []*ir2.Instr
+}
+
+// multiWords splits integers wider than a word into a value for each
+// word, least significant first, since everything after this expects
+// a value to fit in a register. Adds and subtracts are chained with
+// carries, and multiplies, divides and variable shifts call the runtime.
+func multiWords(it ir2.Iter) {
+	if it.Block() == nil {
+		// funcs implemented in assembly have no blocks
+		return
+	}
+	fn := it.Block().Func()
+
+	sp := &splitter{
+		fn:     fn,
+		parts:  make(map[*ir2.Value][]*ir2.Value),
+		params: make(map[*ir2.Block][]types.Type),
+	}
+
+	// turn what can't be done inline into runtime calls first, so
+	// the calls get split along with everything else
+	for ; it.HasNext(); it.Next() {
+		sp.runtimeCalls(it)
+	}
+
+	for i := 0; i < fn.NumBlocks(); i++ {
+		sp.splitBlockParams(fn.Block(i))
+	}
+
+	for it := fn.InstrIter(); it.HasNext(); it.Next() {
+		sp.splitInstr(it)
+	}
+
+	for i := 0; i < fn.NumBlocks(); i++ {
+		sp.splitBlockArgs(fn.Block(i))
+	}
+
+	for _, instr := range sp.dead {
+		instr.Update(instr.Op, nil)
+		instr.Block().RemoveInstr(instr)
+	}
+
+	for val := range sp.parts {
+		if val.NumUses() > 0 {
+			log.Panicf("multi-word value %v still used by %s in %s", val, val.Use(0).Instr().LongString(), fn.FullName)
+		}
+	}
+}
+
+// runtimeCalls converts multi-word ops that need a loop into calls
+// to the runtime, and ops by powers of two into shifts and masks
+func (sp *splitter) runtimeCalls(it ir2.Iter) {
+	instr := it.Instr()
+
+	switch instr.Op {
+	case op.CallBuiltin:
+		name, _ := ir2.StringValue(instr.Arg(0).Const())
+		if name != "print" && name != "println" {
+			return
+		}
+		for _, arg := range instr.Args()[1:] {
+			if isMultiWord(arg.Type) {
+				builtins(it)
+
+				// the builtin is removed, but still uses its args
+				instr.Update(instr.Op, nil)
+				return
+			}
+		}
+
+	case op.Mul, op.Div, op.Rem:
+		typ := instr.Def(0).Type
+		if !isMultiWord(typ) {
+			return
+		}
+
+		if instr.Op == op.Mul && isPowerOfTwo(instr.Arg(0)) {
+			instr.Update(op.Mul, nil, instr.Arg(1), instr.Arg(0))
+		}
+
+		if n, ok := log2(instr.Arg(1)); ok {
+			switch {
+			case instr.Op == op.Mul:
+				it.Update(op.ShiftLeft, nil, instr.Arg(0), sp.word(n))
+				return
+			case instr.Op == op.Div && isUnsigned(typ):
+				it.Update(op.ShiftRight, nil, instr.Arg(0), sp.word(n))
+				return
+			case instr.Op == op.Rem && isUnsigned(typ):
+				it.Update(op.And, nil, instr.Arg(0), sp.fn.ValueFor(typ, int64(1)<<n-1))
+				return
+			}
+		}
+
+		// the low bits of a product don't depend on the sign
+		unsigned := isUnsigned(typ) || instr.Op == op.Mul
+		name := map[ir2.Op]string{op.Mul: "mul64", op.Div: "div64", op.Rem: "mod64"}[instr.Op]
+		if unsigned && instr.Op != op.Mul {
+			name = "u" + name
+		}
+		sp.callRuntime(it, name, sp.extend64(it, instr.Arg(0), unsigned), sp.extend64(it, instr.Arg(1), unsigned))
+
+	case op.ShiftLeft, op.ShiftRight:
+		typ := instr.Def(0).Type
+		if !isMultiWord(typ) || instr.Arg(1).IsConst() {
+			return
+		}
+
+		count := instr.Arg(1)
+		uintType := types.Typ[types.Uint]
+		if count.Type != uintType {
+			count = it.Insert(op.Convert, uintType, count).Def(0)
+		}
+
+		name := "shl64"
+		if instr.Op == op.ShiftRight {
+			name = "shr64"
+			if !isUnsigned(typ) {
+				name = "ashr64"
+			}
+		}
+		sp.callRuntime(it, name, sp.extend64(it, instr.Arg(0), isUnsigned(typ) || instr.Op == op.ShiftLeft), count)
+	}
+}
+
+// extend64 converts a value to 64 bits for passing to the runtime
+func (sp *splitter) extend64(it ir2.Iter, val *ir2.Value, unsigned bool) *ir2.Value {
+	typ := types.Typ[types.Int64]
+	if unsigned {
+		typ = types.Typ[types.Uint64]
+	}
+
+	if val.IsConst() || types.Identical(val.Type, typ) {
+		return val
+	}
+	return it.Insert(op.Convert, typ, val).Def(0)
+}
+
+// callRuntime replaces the instr with a call to the runtime, converting
+// the result back to the instr's type
+func (sp *splitter) callRuntime(it ir2.Iter, name string, args ...interface{}) {
+	call := insertRuntimeCall(it, name, args...)
+	if call == nil {
+		return
+	}
+	it.Update(op.Convert, nil, call.Def(0))
+}
+
+// splitBlockParams splits the multi-word params of a block
+func (sp *splitter) splitBlockParams(blk *ir2.Block) {
+	defs := blk.Defs()
+
+	split := false
+	var typs []types.Type
+	for _, def := range defs {
+		typs = append(typs, def.Type)
+		if isMultiWord(def.Type) {
+			split = true
+		}
+	}
+	if !split {
+		return
+	}
+	sp.params[blk] = typs
+
+	for _, def := range defs {
+		blk.RemoveDef(def)
+	}
+
+	for _, def := range defs {
+		if !isMultiWord(def.Type) {
+			blk.AddDef(def)
+			continue
+		}
+
+		parts := make([]*ir2.Value, numWords(def.Type))
+		for i := range parts {
+			parts[i] = blk.AddDef(sp.fn.NewValue(types.Typ[types.Uint]))
+		}
+		sp.parts[def] = parts
+	}
+
+	if sp.fn.BlockIndex(blk) == 0 {
+		// the params of the func, which need their locations reassigned
+		for i := 0; i < blk.NumDefs(); i++ {
+			if blk.Def(i).Reg() == reg.Context {
+				// the closure object comes after the params
+				continue
+			}
+			if i < len(reg.ArgRegs) {
+				blk.Def(i).SetReg(reg.ArgRegs[i])
+			} else {
+				blk.Def(i).SetParamSlot(i - len(reg.ArgRegs))
+			}
+		}
+	}
+}
+
+// splitBlockArgs splits the args a block passes to its succs
+func (sp *splitter) splitBlockArgs(blk *ir2.Block) {
+	var typs []types.Type
+	split := false
+	for i := 0; i < blk.NumSuccs(); i++ {
+		succ := blk.Succ(i)
+		if params, ok := sp.params[succ]; ok {
+			typs = append(typs, params...)
+			split = true
+			continue
+		}
+		for _, def := range succ.Defs() {
+			typs = append(typs, def.Type)
+		}
+	}
+	if !split {
+		return
+	}
+
+	args := blk.Args()
+	if len(args) != len(typs) {
+		log.Panicf("block %v has %d args for %d params", blk, len(args), len(typs))
+	}
+
+	for _, arg := range args {
+		blk.RemoveArg(arg)
+	}
+
+	for i, arg := range args {
+		for _, part := range sp.split(arg, typs[i]) {
+			blk.InsertArg(-1, part)
+		}
+	}
+}
+
+// splitInstr splits the multi-word args and defs of an instr
+func (sp *splitter) splitInstr(it ir2.Iter) {
+	instr := it.Instr()
+
+	switch instr.Op {
+	case op.Load:
+		typ := instr.Def(0).Type
+		if !isMultiWord(typ) {
+			return
+		}
+		parts := make([]*ir2.Value, numWords(typ))
+		for i := range parts {
+			addr := sp.offset(it, instr.Arg(0), i)
+			parts[i] = sp.insert(it, op.Load, types.Typ[types.Uint], addr)
+		}
+		sp.replace(instr, parts)
+
+	case op.Store:
+		ptr, ok := instr.Arg(0).Type.Underlying().(*types.Pointer)
+		if !ok || !isMultiWord(ptr.Elem()) {
+			return
+		}
+		for i, part := range sp.split(instr.Arg(1), ptr.Elem()) {
+			addr := sp.offset(it, instr.Arg(0), i)
+			sp.insert(it, op.Store, nil, addr, part)
+		}
+		sp.dead = append(sp.dead, instr)
+
+	case op.Add, op.Sub:
+		typ := instr.Def(0).Type
+		if !isMultiWord(typ) {
+			return
+		}
+		carry := op.AddCarry
+		if instr.Op == op.Sub {
+			carry = op.SubBorrow
+		}
+		sp.replace(instr, sp.chain(it, instr.Op.(op.Op), carry,
+			sp.split(instr.Arg(0), typ), sp.split(instr.Arg(1), typ)))
+
+	case op.Negate:
+		typ := instr.Def(0).Type
+		if !isMultiWord(typ) {
+			return
+		}
+		x := sp.split(instr.Arg(0), typ)
+		zeros := make([]*ir2.Value, len(x))
+		for i := range zeros {
+			zeros[i] = sp.word(0)
+		}
+		sp.replace(instr, sp.chain(it, op.Sub, op.SubBorrow, zeros, x))
+
+	case op.And, op.Or, op.Xor, op.AndNot:
+		typ := instr.Def(0).Type
+		if !isMultiWord(typ) {
+			return
+		}
+		x := sp.split(instr.Arg(0), typ)
+		y := sp.split(instr.Arg(1), typ)
+		parts := make([]*ir2.Value, len(x))
+		for i := range parts {
+			parts[i] = sp.insert(it, instr.Op, types.Typ[types.Uint], x[i], y[i])
+		}
+		sp.replace(instr, parts)
+
+	case op.Invert:
+		typ := instr.Def(0).Type
+		if !isMultiWord(typ) {
+			return
+		}
+		x := sp.split(instr.Arg(0), typ)
+		parts := make([]*ir2.Value, len(x))
+		for i := range parts {
+			parts[i] = sp.insert(it, op.Invert, types.Typ[types.Uint], x[i])
+		}
+		sp.replace(instr, parts)
+
+	case op.ShiftLeft, op.ShiftRight:
+		sp.shift(it)
+
+	case op.Equal, op.NotEqual, op.Less, op.LessEqual, op.Greater, op.GreaterEqual:
+		sp.compare(it)
+
+	case op.Convert:
+		sp.convert(it)
+
+	case op.Call:
+		sig := instr.Arg(0).Type.Underlying().(*types.Signature)
+		args := []*ir2.Value{instr.Arg(0)}
+		for i := 1; i < instr.NumArgs(); i++ {
+			typ := instr.Arg(i).Type
+			if i-1 < sig.Params().Len() {
+				typ = sig.Params().At(i - 1).Type()
+			}
+			args = append(args, sp.split(instr.Arg(i), typ)...)
+		}
+		sp.rebuild(it, args)
+
+	case op.Copy:
+		var args []*ir2.Value
+		for i := 0; i < instr.NumArgs(); i++ {
+			args = append(args, sp.split(instr.Arg(i), instr.Def(i).Type)...)
+		}
+		sp.rebuild(it, args)
+
+	case op.Return:
+		results := sp.fn.Sig.Results()
+		var args []*ir2.Value
+		for i := 0; i < instr.NumArgs(); i++ {
+			args = append(args, sp.split(instr.Arg(i), results.At(i).Type())...)
+		}
+		if len(args) != instr.NumArgs() {
+			instr.Update(op.Return, nil, args)
+		}
+
+	default:
+		for _, def := range instr.Defs() {
+			if isMultiWord(def.Type) {
+				diag.Errorf(instr.Pos, "%s of %s is not supported yet", instr.Op, def.Type)
+				return
+			}
+		}
+
+		// other uses only care about the value fitting in a word
+		for i := 0; i < instr.NumArgs(); i++ {
+			arg := instr.Arg(i)
+			if !arg.IsConst() && isMultiWord(arg.Type) {
+				instr.ReplaceArg(i, sp.split(arg, arg.Type)[0])
+			}
+		}
+	}
+}
+
+// chain inserts an op on the lowest words, then the carry op on the
+// rest of the words, returning the resulting words
+func (sp *splitter) chain(it ir2.Iter, first, carry op.Op, x, y []*ir2.Value) []*ir2.Value {
+	parts := make([]*ir2.Value, len(x))
+	for i := range parts {
+		o := carry
+		if i == 0 {
+			o = first
+		}
+		parts[i] = sp.insert(it, o, types.Typ[types.Uint], x[i], y[i])
+	}
+	return parts
+}
+
+// shift does a shift by a constant amount by shifting each word and
+// combining it with the bits shifted in from the word next to it
+func (sp *splitter) shift(it ir2.Iter) {
+	instr := it.Instr()
+	typ := instr.Def(0).Type
+
+	if !isMultiWord(typ) {
+		// the amount can be wider than the value, but only the bottom
+		// word matters
+		count := instr.Arg(1)
+		if !count.IsConst() && isMultiWord(count.Type) {
+			instr.ReplaceArg(1, sp.split(count, count.Type)[0])
+		}
+		return
+	}
+
+	amt, ok := ir2.Int64Value(instr.Arg(1).Const())
+	if !ok {
+		log.Panicf("expected constant shift in %s", instr.LongString())
+	}
+
+	uint := types.Typ[types.Uint]
+	bits := wordBits()
+	x := sp.split(instr.Arg(0), typ)
+	n := int64(len(x))
+	words, s := amt/bits, amt%bits
+	parts := make([]*ir2.Value, n)
+
+	if instr.Op == op.ShiftLeft {
+		for i := int64(0); i < n; i++ {
+			j := i - words
+			switch {
+			case j < 0:
+				parts[i] = sp.word(0)
+			case s == 0:
+				parts[i] = x[j]
+			case j == 0:
+				parts[i] = sp.insert(it, op.ShiftLeft, uint, x[j], sp.word(s))
+			default:
+				hi := sp.insert(it, op.ShiftLeft, uint, x[j], sp.word(s))
+				lo := sp.insert(it, op.ShiftRight, uint, x[j-1], sp.word(bits-s))
+				parts[i] = sp.insert(it, op.Or, uint, hi, lo)
+			}
+		}
+		sp.replace(instr, parts)
+		return
+	}
+
+	// the top word is shifted arithmetically if signed, which also
+	// fills the words above it with the sign
+	top := uint
+	fill := sp.word(0)
+	if !isUnsigned(typ) {
+		top = types.Typ[types.Int]
+		fill = nil
+	}
+
+	for i := int64(0); i < n; i++ {
+		j := i + words
+		switch {
+		case j > n-1:
+			if fill == nil {
+				fill = sp.insert(it, op.ShiftRight, top, x[n-1], sp.word(bits-1))
+			}
+			parts[i] = fill
+		case s == 0:
+			parts[i] = x[j]
+		case j == n-1:
+			parts[i] = sp.insert(it, op.ShiftRight, top, x[j], sp.word(s))
+		default:
+			lo := sp.insert(it, op.ShiftRight, uint, x[j], sp.word(s))
+			hi := sp.insert(it, op.ShiftLeft, uint, x[j+1], sp.word(bits-s))
+			parts[i] = sp.insert(it, op.Or, uint, lo, hi)
+		}
+	}
+	sp.replace(instr, parts)
+}
+
+// compare reduces a multi-word comparison to a single word one. For
+// equality the xor of each word is or'd together, otherwise one side
+// is subtracted from the other and the borrow is turned into a word.
+func (sp *splitter) compare(it ir2.Iter) {
+	instr := it.Instr()
+
+	typ := instr.Arg(0).Type
+	if instr.Arg(0).IsConst() {
+		typ = instr.Arg(1).Type
+	}
+	if !isMultiWord(typ) {
+		return
+	}
+
+	uint := types.Typ[types.Uint]
+	x := sp.split(instr.Arg(0), typ)
+	y := sp.split(instr.Arg(1), typ)
+
+	if instr.Op == op.Equal || instr.Op == op.NotEqual {
+		var acc *ir2.Value
+		for i := range x {
+			diff := sp.insert(it, op.Xor, uint, x[i], y[i])
+			if acc != nil {
+				diff = sp.insert(it, op.Or, uint, acc, diff)
+			}
+			acc = diff
+		}
+		it.Update(instr.Op, nil, acc, sp.word(0))
+		return
+	}
+
+	// x < y is when x - y borrows, and x <= y is when y - x doesn't
+	compare := op.NotEqual
+	if instr.Op == op.LessEqual || instr.Op == op.GreaterEqual {
+		compare = op.Equal
+	}
+	if instr.Op == op.LessEqual || instr.Op == op.Greater {
+		x, y = y, x
+	}
+
+	if !isUnsigned(typ) {
+		// flipping the sign bits makes it an unsigned comparison
+		sign := int64(1) << (wordBits() - 1)
+		x = append(x[:len(x)-1:len(x)-1], sp.flip(it, x[len(x)-1], sign))
+		y = append(y[:len(y)-1:len(y)-1], sp.flip(it, y[len(y)-1], sign))
+	}
+
+	sp.chain(it, op.Sub, op.SubBorrow, x, y)
+
+	// 0 - 0 - borrow is all ones if there was a borrow, otherwise zero
+	borrow := sp.insert(it, op.SubBorrow, uint, sp.word(0), sp.word(0))
+
+	it.Update(compare, nil, borrow, sp.word(0))
+}
+
+// flip xors a word with a constant, folding it if the word is constant
+func (sp *splitter) flip(it ir2.Iter, val *ir2.Value, bits int64) *ir2.Value {
+	if val.IsConst() {
+		v, _ := ir2.Int64Value(val.Const())
+		return sp.word(v ^ bits)
+	}
+	return sp.insert(it, op.Xor, types.Typ[types.Uint], val, sp.word(bits))
+}
+
+// convert truncates, sign extends or zero extends multi-word values
+func (sp *splitter) convert(it ir2.Iter) {
+	instr := it.Instr()
+	from := instr.Arg(0).Type
+	to := instr.Def(0).Type
+
+	if instr.Arg(0).IsConst() && isInteger(to) {
+		// the type of a const can't be trusted, so fold it instead
+		sp.convertConst(instr)
+		return
+	}
+
+	if !isMultiWord(from) && !isMultiWord(to) {
+		return
+	}
+
+	x := sp.split(instr.Arg(0), from)
+
+	if !isMultiWord(to) {
+		// truncating just drops the upper words
+		it.Update(op.Convert, nil, x[0])
+		return
+	}
+
+	fill := sp.word(0)
+	if !isUnsigned(from) && len(x) < int(numWords(to)) {
+		fill = sp.insert(it, op.ShiftRight, types.Typ[types.Int], x[len(x)-1], sp.word(wordBits()-1))
+	}
+
+	parts := make([]*ir2.Value, numWords(to))
+	for i := range parts {
+		if i < len(x) {
+			parts[i] = x[i]
+		} else {
+			parts[i] = fill
+		}
+	}
+	sp.replace(instr, parts)
+}
+
+// convertConst truncates or extends a const to the type converted to
+func (sp *splitter) convertConst(instr *ir2.Instr) {
+	to := instr.Def(0).Type
+
+	c, ok := ir2.Int64Value(instr.Arg(0).Const())
+	if !ok {
+		log.Panicf("unsupported const conversion of %v in %s", instr.Arg(0), sp.fn.FullName)
+	}
+
+	if isMultiWord(to) {
+		parts := make([]*ir2.Value, numWords(to))
+		for i := range parts {
+			parts[i] = sp.word(c >> (int64(i) * wordBits()))
+		}
+		sp.replace(instr, parts)
+		return
+	}
+
+	bits := sizes.Sizeof(to) * int64(sizes.MinAddressableBits())
+	if bits < 64 {
+		if isUnsigned(to) {
+			c &= 1<<bits - 1
+		} else {
+			c = c << (64 - bits) >> (64 - bits)
+		}
+	}
+
+	instr.Def(0).ReplaceUsesWith(sp.fn.ValueFor(to, c))
+	sp.dead = append(sp.dead, instr)
+}
+
+// rebuild replaces the instr with a copy of it taking the args,
+// and with a def for each word of the original defs
+func (sp *splitter) rebuild(it ir2.Iter, args []*ir2.Value) {
+	instr := it.Instr()
+
+	split := len(args) != instr.NumArgs()
+	var defs []*ir2.Value
+	for _, def := range instr.Defs() {
+		if isMultiWord(def.Type) {
+			split = true
+			for i := int64(0); i < numWords(def.Type); i++ {
+				defs = append(defs, sp.fn.NewValue(types.Typ[types.Uint]))
+			}
+		} else {
+			defs = append(defs, def)
+		}
+	}
+	if !split {
+		return
+	}
+
+	rebuilt := it.Insert(instr.Op, tupleOf(defs), args)
+	rebuilt.Pos = instr.Pos
+
+	i := 0
+	for _, def := range instr.Defs() {
+		if isMultiWord(def.Type) {
+			n := int(numWords(def.Type))
+			sp.parts[def] = rebuilt.Defs()[i : i+n]
+			i += n
+			continue
+		}
+		def.ReplaceUsesWith(rebuilt.Def(i))
+		i++
+	}
+
+	sp.dead = append(sp.dead, instr)
+}
+
+// replace records the parts of the instr's multi-word def and marks
+// the instr for removal
+func (sp *splitter) replace(instr *ir2.Instr, parts []*ir2.Value) {
+	sp.parts[instr.Def(0)] = parts
+	sp.dead = append(sp.dead, instr)
+}
+
+// split returns the words of a value of type typ. Consts are shared
+// between types, so the type has to come from how it is used.
+func (sp *splitter) split(val *ir2.Value, typ types.Type) []*ir2.Value {
+	if !isMultiWord(typ) {
+		return []*ir2.Value{val}
+	}
+
+	if parts, ok := sp.parts[val]; ok {
+		return parts
+	}
+
+	if !val.IsConst() {
+		log.Panicf("multi-word value %v used before it was split in %s", val, sp.fn.FullName)
+	}
+
+	c, ok := ir2.Int64Value(val.Const())
+	if !ok {
+		log.Panicf("unsupported multi-word const %v in %s", val, sp.fn.FullName)
+	}
+
+	parts := make([]*ir2.Value, numWords(typ))
+	for i := range parts {
+		parts[i] = sp.word(c >> (int64(i) * wordBits()))
+	}
+	return parts
+}
+
+// word returns a const for a word, sign extending the value so it
+// doesn't depend on the size of the host's ints
+func (sp *splitter) word(v int64) *ir2.Value {
+	bits := wordBits()
+	v = v << (64 - bits) >> (64 - bits)
+	return sp.fn.ValueFor(types.Typ[types.Uint], v)
+}
+
+// offset returns the address of the ith word after addr
+func (sp *splitter) offset(it ir2.Iter, addr *ir2.Value, i int) *ir2.Value {
+	if i == 0 {
+		return addr
+	}
+	return sp.insert(it, op.Add, addr.Type, addr, sp.fn.ValueFor(types.Typ[types.Uintptr], int64(i)*sizes.WordSize()))
+}
+
+// insert inserts an instr before the current one, returning its def
+func (sp *splitter) insert(it ir2.Iter, o ir2.Op, typ types.Type, args ...interface{}) *ir2.Value {
+	pos := it.Instr().Pos
+	instr := it.Insert(o, typ, args...)
+	instr.Pos = pos
+	if instr.NumDefs() == 0 {
+		return nil
+	}
+	return instr.Def(0)
+}
+
+// isMultiWord returns whether the type is an integer wider than a word
+func isMultiWord(typ types.Type) bool {
+	return isInteger(typ) && sizes.Sizeof(typ) > sizes.WordSize()
+}
+
+// isInteger returns whether the type is an integer. Only basic and
+// named types are checked, since the range iterator's type from go/ssa
+// isn't a go/types type, and can't be asked for its underlying type.
+func isInteger(typ types.Type) bool {
+	switch typ.(type) {
+	case *types.Basic, *types.Named:
+	default:
+		return false
+	}
+	basic, ok := typ.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsInteger != 0
+}
+
+// numWords returns the number of words in a type
+func numWords(typ types.Type) int64 {
+	return sizes.Sizeof(typ) / sizes.WordSize()
+}
+
+// wordBits returns the number of bits in a word
+func wordBits() int64 {
+	return sizes.WordSize() * int64(sizes.MinAddressableBits())
+}
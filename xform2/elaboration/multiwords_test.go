@@ -0,0 +1,41 @@
+package elaboration
+
+import (
+	"go/types"
+	"testing"
+)
+
+// rangeIter is shaped like the type go/ssa gives the def of a range
+// over a string, which embeds a nil go/types type in some versions
+type rangeIter struct {
+	types.Type
+	name string
+}
+
+func (t *rangeIter) String() string { return t.name }
+
+func TestIsMultiWord_rangeOverString(t *testing.T) {
+	if isMultiWord(&rangeIter{nil, "iter"}) {
+		t.Error("expected the range iterator not to be a multi-word integer")
+	}
+}
+
+func TestIsInteger(t *testing.T) {
+	tests := []struct {
+		typ  types.Type
+		want bool
+	}{
+		{types.Typ[types.Int], true},
+		{types.Typ[types.Uint8], true},
+		{types.Typ[types.String], false},
+		{types.NewNamed(types.NewTypeName(0, nil, "T", nil), types.Typ[types.Uint16], nil), true},
+		{types.NewPointer(types.Typ[types.Int]), false},
+		{&rangeIter{nil, "iter"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isInteger(tt.typ); got != tt.want {
+			t.Errorf("isInteger(%v) = %v, want %v", tt.typ, got, tt.want)
+		}
+	}
+}
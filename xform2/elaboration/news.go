@@ -0,0 +1,24 @@
+package elaboration
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(news,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.New),
+)
+
+// news converts heap allocations into a call to the runtime's allocator
+func news(it ir2.Iter) {
+	instr := it.Instr()
+	fn := instr.Func()
+	elem := instr.Def(0).Type.(*types.Pointer).Elem()
+
+	updateToRuntimeCall(it, "alloc", fn.ValueFor(types.Typ[types.Uintptr], sizes.Sizeof(elem)))
+}
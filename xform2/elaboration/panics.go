@@ -0,0 +1,90 @@
+package elaboration
+
+import (
+	"fmt"
+	"go/types"
+	"path/filepath"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(panics,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Panic),
+)
+
+// panics converts panics into a call to the runtime with the value and
+// where the panic is, which runs the deferred calls, and prints them and
+// halts if none of them recover. The checks the compiler adds panic with
+// a string with the error rather than an interface. The runtime doesn't
+// return, so the panic is left without a value to end the block.
+func panics(it ir2.Iter) {
+	instr := it.Instr()
+	if instr.NumArgs() < 1 {
+		return
+	}
+
+	fn := instr.Func()
+	value := instr.Arg(0)
+
+	name := "gopanic"
+	if basic, ok := value.Type.Underlying().(*types.Basic); ok && basic.Kind() == types.String {
+		name = "panicError"
+	}
+
+	where := fn.Package().NewStringLiteral(fn.Name, panicWhere(instr))
+	where.Referenced = true
+
+	insertRuntimeCall(it, name, value, fn.ValueFor(types.Typ[types.String], where))
+	instr.Update(instr.Op, nil)
+}
+
+// panicWhere returns the func the panic is in, with the file and line
+// if they're known
+func panicWhere(instr *ir2.Instr) string {
+	fn := instr.Func()
+	name := fn.Package().Name + "." + fn.Name
+
+	fset := fn.Package().Program().FileSet
+	if fset == nil || !instr.Pos.IsValid() {
+		return name
+	}
+
+	pos := fset.Position(instr.Pos)
+	return fmt.Sprintf("%s (%s:%d)", name, filepath.Base(pos.Filename), pos.Line)
+}
+
+// panicIf splits the block before the current instruction to add the
+// instructions of a check to the end of the first half, which goes to a
+// block that panics with the runtime error if the last one is true
+func panicIf(it ir2.Iter, msg string, check ...*ir2.Instr) {
+	fn := it.Block().Func()
+	pos := it.Instr().Pos
+	blk := it.Block()
+	it.SplitBefore()
+
+	for _, instr := range check {
+		instr.Pos = pos
+		blk.InsertInstr(-1, instr)
+	}
+
+	branch := fn.NewInstr(op.If, nil, check[len(check)-1].Def(0))
+	branch.Pos = pos
+	blk.InsertInstr(-1, branch)
+
+	fail := fn.NewBlock()
+	fn.InsertBlock(-1, fail)
+
+	str := fn.Package().NewStringLiteral(fn.Name, msg)
+	str.Referenced = true
+	abort := fn.NewInstr(op.Panic, nil, fn.ValueFor(types.Typ[types.String], str))
+	abort.Pos = pos
+	fail.InsertInstr(-1, abort)
+
+	// the check fails when it's true, which is the first succ
+	blk.AddSucc(fail)
+	fail.AddPred(blk)
+	blk.SwapSuccs()
+}
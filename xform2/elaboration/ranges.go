@@ -0,0 +1,55 @@
+package elaboration
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/diag"
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(ranges,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Range),
+)
+
+// ranges converts a range over a string into a local iterator which
+// is reset to zero, and each next on it into a call to the runtime
+// to decode the next rune
+func ranges(it ir2.Iter) {
+	instr := it.Instr()
+	fn := instr.Func()
+	str := instr.Arg(0)
+
+	if !isString(str.Type) {
+		diag.Errorf(instr.Pos, "range over %s is not supported yet", str.Type)
+		return
+	}
+
+	callee := runtimeFunc(instr, "stringNext")
+	if callee == nil {
+		return
+	}
+
+	// the iterator is passed by pointer to the runtime
+	itPtr := callee.Sig.Params().At(1).Type()
+
+	local := instr.Def(0)
+	var nexts []*ir2.Instr
+	for i := 0; i < local.NumUses(); i++ {
+		use := local.Use(i)
+		if use.IsBlock() || use.Instr().Op != op.Next {
+			diag.Errorf(instr.Pos, "expected range iterator to only be used by next")
+			return
+		}
+		nexts = append(nexts, use.Instr())
+	}
+
+	it.Update(op.Local, itPtr)
+	it.InsertAfter(op.Store, nil, local, fn.ValueFor(types.Typ[types.Int], 0))
+
+	for _, next := range nexts {
+		next.Update(op.Call, callee.Sig.Results(), fn.ValueFor(callee.Sig, callee), str, local, stringEncoding(fn))
+	}
+}
@@ -10,25 +10,27 @@ import (
 var _ = xform2.Register(returnCopy,
 	xform2.OnlyPass(xform2.Elaboration),
 	xform2.OnOp(op.Return),
-	xform2.Once(),
 )
 
 func returnCopy(it ir2.Iter) {
-	fn := it.Block().Func()
-
 	ret := it.Instr()
 	if ret.NumArgs() < 1 {
 		return
 	}
 
-	results := fn.Sig.Results()
-	cp := it.Insert(op.Copy, results, ret.Args())
+	// already done if the results have been assigned locations
+	if ret.Arg(0).InReg() || ret.Arg(0).InParamSlot() {
+		return
+	}
+
+	cp := it.Insert(op.Copy, tupleOf(ret.Args()), ret.Args())
 
 	for i := 0; i < ret.NumArgs(); i++ {
 		if i < len(reg.ArgRegs) {
 			cp.Def(i).SetReg(reg.ArgRegs[i])
 		} else {
-			cp.Def(i).SetArgSlot(i - len(reg.ArgRegs))
+			// extra results go in the caller's arg slots
+			cp.Def(i).SetParamSlot(i - len(reg.ArgRegs))
 		}
 		ret.ReplaceArg(i, cp.Def(i))
 	}
@@ -0,0 +1,134 @@
+package elaboration
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(makeSlices,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.MakeSlice),
+)
+
+var _ = xform2.Register(slices,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Slice),
+)
+
+var _ = xform2.Register(sliceCompares,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Equal),
+)
+
+var _ = xform2.Register(sliceCompares,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.NotEqual),
+)
+
+// makeSlices converts `make` of a slice into a call to the runtime
+// to allocate it
+func makeSlices(it ir2.Iter) {
+	instr := it.Instr()
+	fn := instr.Func()
+	elem := instr.Def(0).Type.Underlying().(*types.Slice).Elem()
+
+	updateToRuntimeCall(it, "makeslice",
+		fn.ValueFor(types.Typ[types.Uintptr], sizes.Sizeof(elem)),
+		instr.Arg(0), instr.Arg(1))
+}
+
+// slices converts slice expressions into a call to the runtime to
+// build the new slice header, after checking the bounds. Missing
+// bounds default to the start, length and capacity of what's being
+// sliced.
+func slices(it ir2.Iter) {
+	instr := it.Instr()
+	fn := instr.Func()
+	uintptr := types.Typ[types.Uintptr]
+
+	x := instr.Arg(0)
+	low, high, max := instr.Arg(1), instr.Arg(2), instr.Arg(3)
+
+	// strings and slices are a pointer to a header of words
+	word := func(n int64) *ir2.Value {
+		addr := it.Insert(op.Add, uintptr, x, n*sizes.WordSize())
+		return it.Insert(op.Load, uintptr, addr).Def(0)
+	}
+
+	if isNil(low) {
+		low = fn.ValueFor(uintptr, 0)
+	}
+
+	var ptr, length, cap *ir2.Value
+	var elem types.Type
+
+	switch typ := x.Type.Underlying().(type) {
+	case *types.Slice:
+		ptr = word(0)
+		length = word(1)
+		cap = word(2)
+		elem = typ.Elem()
+
+	case *types.Pointer:
+		array := typ.Elem().Underlying().(*types.Array)
+		ptr = x
+		length = fn.ValueFor(uintptr, array.Len())
+		cap = length
+		elem = array.Elem()
+
+	default:
+		// strings have no capacity, and are sliced separately
+		length = word(1)
+		if isNil(high) {
+			high = length
+		}
+		if !fn.NoBounds {
+			insertRuntimeCall(it, "sliceBounds", low, high, high, length)
+		}
+
+		updateToRuntimeCall(it, "stringSlice", x, low, high)
+		return
+	}
+
+	if isNil(high) {
+		high = length
+	}
+	if isNil(max) {
+		max = cap
+	}
+	if !fn.NoBounds {
+		insertRuntimeCall(it, "sliceBounds", low, high, max, cap)
+	}
+
+	updateToRuntimeCall(it, "slice", ptr, fn.ValueFor(uintptr, sizes.Sizeof(elem)), low, high, max)
+}
+
+// sliceCompares compares the element pointers of slices, since
+// slices can only be compared to nil
+func sliceCompares(it ir2.Iter) {
+	instr := it.Instr()
+
+	isSlice := false
+	for i := 0; i < instr.NumArgs(); i++ {
+		if _, ok := instr.Arg(i).Type.Underlying().(*types.Slice); ok {
+			isSlice = true
+		}
+	}
+	if !isSlice {
+		return
+	}
+
+	for i := 0; i < instr.NumArgs(); i++ {
+		ptr := it.Insert(op.Load, types.Typ[types.Uintptr], instr.Arg(i))
+		instr.ReplaceArg(i, ptr.Def(0))
+	}
+}
+
+// isNil returns whether the value is the nil constant
+func isNil(val *ir2.Value) bool {
+	return val.IsConst() && val.Const().Kind() == ir2.NilConst
+}
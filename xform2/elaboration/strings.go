@@ -0,0 +1,149 @@
+package elaboration
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/diag"
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(stringCompares,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Equal),
+)
+
+var _ = xform2.Register(stringCompares,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.NotEqual),
+)
+
+var _ = xform2.Register(stringCompares,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Less),
+)
+
+var _ = xform2.Register(stringCompares,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.LessEqual),
+)
+
+var _ = xform2.Register(stringCompares,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Greater),
+)
+
+var _ = xform2.Register(stringCompares,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.GreaterEqual),
+)
+
+// how the runtime encodes strings, which must match the runtime
+const (
+	stringUTF8  = 0
+	stringUTF16 = 1
+)
+
+// stringEncoding returns the encoding of strings, which is UTF-16 when
+// bytes are 16 bits wide so that each character is usually one byte
+func stringEncoding(fn *ir2.Func) *ir2.Value {
+	if sizes.MinAddressableBits() == 16 {
+		return fn.ValueFor(types.Typ[types.Uintptr], stringUTF16)
+	}
+	return fn.ValueFor(types.Typ[types.Uintptr], stringUTF8)
+}
+
+func isString(typ types.Type) bool {
+	basic, ok := typ.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsString != 0
+}
+
+// stringCompares converts comparing strings into a call to the runtime,
+// and comparing the result with true. Greater than is less than with the
+// args swapped, and the rest are the negations of those.
+func stringCompares(it ir2.Iter) {
+	instr := it.Instr()
+	x, y := instr.Arg(0), instr.Arg(1)
+
+	if !isString(x.Type) || !isString(y.Type) {
+		return
+	}
+
+	name := "stringLess"
+	cmp := op.Equal
+	switch instr.Op {
+	case op.Equal:
+		name = "stringEqual"
+	case op.NotEqual:
+		name = "stringEqual"
+		cmp = op.NotEqual
+	case op.Greater:
+		x, y = y, x
+	case op.LessEqual:
+		x, y = y, x
+		cmp = op.NotEqual
+	case op.GreaterEqual:
+		cmp = op.NotEqual
+	}
+
+	res := insertRuntimeCall(it, name, x, y).Def(0)
+	it.Update(cmp, nil, res, instr.Func().ValueFor(res.Type, true))
+}
+
+// stringConversions converts conversions to and from strings into
+// calls to the runtime, which copies the bytes or encodes or decodes
+// the runes. It returns whether it did.
+func stringConversions(it ir2.Iter) bool {
+	instr := it.Instr()
+	fn := instr.Func()
+	from := instr.Arg(0).Type
+	to := instr.Def(0).Type
+
+	switch {
+	case isString(from) && isString(to):
+		return false
+
+	case isString(to):
+		if elem, ok := sliceElem(from); ok {
+			if elem == types.Int32 {
+				updateToRuntimeCall(it, "stringFromRunes", instr.Arg(0), stringEncoding(fn))
+				return true
+			}
+			updateToRuntimeCall(it, "stringFromBytes", instr.Arg(0))
+			return true
+		}
+
+		if sizes.Sizeof(from) > sizes.WordSize() {
+			diag.Errorf(instr.Pos, "converting %s to %s is not supported yet", from, to)
+			return true
+		}
+		updateToRuntimeCall(it, "stringFromRune", instr.Arg(0), stringEncoding(fn))
+		return true
+
+	case isString(from):
+		elem, _ := sliceElem(to)
+		if elem == types.Int32 {
+			updateToRuntimeCall(it, "stringToRunes", instr.Arg(0))
+			return true
+		}
+		updateToRuntimeCall(it, "stringToBytes", instr.Arg(0))
+		return true
+	}
+
+	return false
+}
+
+// sliceElem returns the kind of the elements of a slice of a basic type
+func sliceElem(typ types.Type) (types.BasicKind, bool) {
+	slice, ok := typ.Underlying().(*types.Slice)
+	if !ok {
+		return types.Invalid, false
+	}
+	basic, ok := slice.Elem().Underlying().(*types.Basic)
+	if !ok {
+		return types.Invalid, false
+	}
+	return basic.Kind(), true
+}
@@ -0,0 +1,142 @@
+package elaboration
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(signedLoads,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Load),
+)
+
+// signedLoads sign extends the signed integers loaded from memory they
+// take up less than a word of, since loads zero extend them
+func signedLoads(it ir2.Iter) {
+	instr := it.Instr()
+	typ := instr.Def(0).Type
+	if !isSubWord(typ) || isUnsigned(typ) {
+		return
+	}
+
+	unsigned := types.Typ[types.Uint8]
+	if sizes.Sizeof(typ) > sizes.Sizeof(unsigned) {
+		unsigned = types.Typ[types.Uint16]
+	}
+
+	load := it.Insert(op.Load, unsigned, instr.Args())
+	narrow(it, load.Def(0), typ)
+}
+
+var _ = xform2.Register(wraps,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Add),
+)
+
+var _ = xform2.Register(wraps,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Sub),
+)
+
+var _ = xform2.Register(wraps,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.ShiftLeft),
+)
+
+var _ = xform2.Register(wraps,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Negate),
+)
+
+var _ = xform2.Register(wraps,
+	xform2.OnlyPass(xform2.Elaboration),
+	xform2.OnOp(op.Invert),
+)
+
+// wraps does arithmetic on integers narrower than a word as a word,
+// then wraps the result around to the size of the integer
+func wraps(it ir2.Iter) {
+	wrapSubWord(it)
+}
+
+// wrapSubWord does the current instr as a word and wraps the result if
+// it's an integer narrower than a word that it could overflow, and
+// returns whether it did. Signed division overflows when the most
+// negative value is divided by -1.
+func wrapSubWord(it ir2.Iter) bool {
+	instr := it.Instr()
+	typ := instr.Def(0).Type
+	if !isSubWord(typ) {
+		return false
+	}
+
+	word := types.Typ[types.Int]
+	if isUnsigned(typ) {
+		if instr.Op == op.Div || instr.Op == op.Rem {
+			return false
+		}
+		word = types.Typ[types.Uint]
+	} else if instr.Op == op.Rem {
+		return false
+	}
+
+	wide := it.Insert(instr.Op, word, instr.Args())
+	narrow(it, wide.Def(0), typ)
+	return true
+}
+
+// isSubWord returns whether the type is an integer with fewer bits
+// than a word. They're kept zero extended to a word, or sign extended
+// if they're signed, so only the ops that can overflow them need to
+// wrap them around.
+func isSubWord(typ types.Type) bool {
+	return isInteger(typ) && intBits(typ) < wordBits()
+}
+
+// intBits returns the number of bits in the integer type on the target
+func intBits(typ types.Type) int64 {
+	return sizes.Sizeof(typ) * int64(sizes.MinAddressableBits())
+}
+
+// fits returns whether every value of the integer type from is also a
+// value of the integer type to
+func fits(from, to types.Type) bool {
+	if !isInteger(from) {
+		return false
+	}
+
+	frombits, tobits := intBits(from), intBits(to)
+	switch {
+	case isUnsigned(from) && isUnsigned(to):
+		return frombits <= tobits
+	case isUnsigned(from):
+		return frombits < tobits
+	case isUnsigned(to):
+		return false
+	}
+	return frombits <= tobits
+}
+
+// narrow updates the current instr to wrap a word around to the size
+// of an integer type narrower than a word, which is then extended back
+// to a word. Unsigned integers are masked, and signed ones are shifted
+// to the top of the word and arithmetic shifted back down, as a word,
+// then copied to keep the type.
+func narrow(it ir2.Iter, val *ir2.Value, typ types.Type) {
+	bits := intBits(typ)
+
+	if isUnsigned(typ) {
+		it.Update(op.And, typ, val, int64(1)<<bits-1)
+		return
+	}
+
+	word := types.Typ[types.Int]
+	shift := wordBits() - bits
+	shl := it.Insert(op.ShiftLeft, word, val, shift)
+	sar := it.Insert(op.ShiftRight, word, shl.Def(0), shift)
+	it.Update(op.Copy, typ, sar.Def(0))
+}
@@ -0,0 +1,319 @@
+package finishing
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/ir/reg"
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(buildFrame,
+	xform2.OnlyPass(xform2.Finishing),
+	xform2.Once(),
+)
+
+/*
+Here is what the call frames look like:
+
+high mem addresses
++------------------------+
+| param slot 1           |  \
++------------------------+   > caller's arg slots
+| param slot 0           |  /  <-- caller SP
++------------------------+ \
+| (alignment padding)    |  |
++------------------------+  |
+| saved reg 1            |  |
++------------------------+  |
+| saved reg 0            |  |
++------------------------+  |
+| saved fp (if needed)   |  |
++------------------------+  |
+| saved ra (if needed)   |   > current callee's frame
++------------------------+  |
+| locals                 |  |
++------------------------+  |
+| spill slot 1           |  |
++------------------------+  |
+| spill slot 0           |  |
++------------------------+  |
+| arg slot 1             |  |
++------------------------+  |
+| arg slot 0             |  | <-- SP
++------------------------+ /
+low mem addresses
+
+Param slots are the caller's arg slots, so they are found just above
+the callee's frame. Extra results are also returned in the param slots.
+*/
+
+// frame is the stack frame layout of a Func
+type frame struct {
+	fn    *ir2.Func
+	sp    *ir2.Value
+	saved []reg.Reg
+
+	// locals that need space on the stack
+	locals []*ir2.Instr
+
+	// size of the locals in words
+	localWords int64
+
+	// size of the frame in min addressable units
+	size int64
+}
+
+// buildFrame lays out the stack frame, adds the prologue and
+// epilogues, and turns copies to and from stack slots into loads
+// and stores relative to the stack pointer.
+func buildFrame(it ir2.Iter) {
+	if it.Block() == nil {
+		// funcs implemented in assembly have no blocks
+		return
+	}
+	fn := it.Block().Func()
+
+	sp := fn.NewValue(types.Typ[types.Uintptr])
+	sp.SetReg(reg.SP)
+
+	fr := &frame{
+		fn:    fn,
+		sp:    sp,
+		saved: savedRegs(fn),
+	}
+
+	fr.findLocals()
+
+	words := int64(fn.NumArgSlots()+fn.NumSpillSlots()+len(fr.saved)) + fr.localWords
+	fr.size = alignTo(words*sizes.WordSize(), sizes.StackAlign())
+
+	fr.slotAccesses()
+	fr.localAddrs()
+	fr.prologue()
+	fr.epilogues()
+
+	it.Changed()
+}
+
+// alignTo rounds n up to the next multiple of align
+func alignTo(n, align int64) int64 {
+	if align <= 1 {
+		return n
+	}
+	return (n + align - 1) / align * align
+}
+
+// savedRegs returns the registers that need to be saved on the stack,
+// which are RA if the Func makes calls, FP if there is a frame pointer
+// and the saved registers that the Func uses.
+func savedRegs(fn *ir2.Func) []reg.Reg {
+	var used reg.Reg
+	hasCalls := false
+
+	for b := 0; b < fn.NumBlocks(); b++ {
+		blk := fn.Block(b)
+
+		for d := 0; d < blk.NumDefs(); d++ {
+			used |= blk.Def(d).Reg()
+		}
+
+		for i := 0; i < blk.NumInstrs(); i++ {
+			instr := blk.Instr(i)
+			if instr.Op.IsCall() {
+				hasCalls = true
+			}
+
+			for d := 0; d < instr.NumDefs(); d++ {
+				used |= instr.Def(d).Reg()
+			}
+		}
+	}
+
+	var saved []reg.Reg
+
+	if hasCalls {
+		saved = append(saved, reg.RA)
+	}
+
+	if xform2.HasTag(xform2.HasFramePointer) && reg.FP != reg.None {
+		saved = append(saved, reg.FP)
+	}
+
+	for _, r := range reg.SavedRegs {
+		if used&r != 0 {
+			saved = append(saved, r)
+		}
+	}
+
+	return saved
+}
+
+// offset returns the offset of the slot containing the value,
+// relative to the stack pointer after the prologue
+func (fr *frame) offset(val *ir2.Value) int64 {
+	wordsize := sizes.WordSize()
+
+	switch val.Location() {
+	case ir2.InArgSlot:
+		return int64(val.ArgSlot()) * wordsize
+	case ir2.InSpillSlot:
+		return int64(fr.fn.NumArgSlots()+val.SpillSlot()) * wordsize
+	case ir2.InParamSlot:
+		return fr.size + int64(val.ParamSlot())*wordsize
+	}
+
+	panic("value not in a stack slot: " + val.IDString())
+}
+
+// savedOffset returns the offset of the ith saved register
+func (fr *frame) savedOffset(i int) int64 {
+	return (int64(fr.fn.NumArgSlots()+fr.fn.NumSpillSlots()+i) + fr.localWords) * sizes.WordSize()
+}
+
+// findLocals finds the locals in the Func and totals up their size
+func (fr *frame) findLocals() {
+	fn := fr.fn
+	wordsize := sizes.WordSize()
+
+	for b := 0; b < fn.NumBlocks(); b++ {
+		blk := fn.Block(b)
+
+		for i := 0; i < blk.NumInstrs(); i++ {
+			instr := blk.Instr(i)
+			if instr.Op != op.Local {
+				continue
+			}
+
+			elem := instr.Def(0).Type.(*types.Pointer).Elem()
+			size := sizes.Sizeof(elem)
+
+			fr.locals = append(fr.locals, instr)
+			fr.localWords += (size + wordsize - 1) / wordsize
+		}
+	}
+}
+
+// localAddrs turns each local into the address of its space on the
+// stack, which comes just after the spill slots
+func (fr *frame) localAddrs() {
+	wordsize := sizes.WordSize()
+	offset := int64(fr.fn.NumArgSlots()+fr.fn.NumSpillSlots()) * wordsize
+
+	for _, instr := range fr.locals {
+		instr.Update(op.Add, nil, fr.sp, fr.constant(offset))
+
+		elem := instr.Def(0).Type.(*types.Pointer).Elem()
+		offset += (sizes.Sizeof(elem) + wordsize - 1) / wordsize * wordsize
+	}
+}
+
+// slotAccesses turns copies into stack slots into stores, and copies
+// out of stack slots into loads. The copies have all been sequentialized
+// by this point, so there's only one def per copy.
+func (fr *frame) slotAccesses() {
+	fn := fr.fn
+
+	for b := 0; b < fn.NumBlocks(); b++ {
+		blk := fn.Block(b)
+
+		for i := 0; i < blk.NumInstrs(); i++ {
+			instr := blk.Instr(i)
+			if !instr.Op.IsCopy() || instr.NumDefs() != 1 {
+				continue
+			}
+
+			def := instr.Def(0)
+			arg := instr.Arg(0)
+
+			switch {
+			case def.InStackSlot() && arg.InStackSlot():
+				panic("copy between stack slots needs a register: " + instr.LongString())
+			case def.InStackSlot():
+				// the def stays around to mark the contents of the slot
+				// for the instruction using it
+				instr.Update(op.Store, nil, fr.sp, fr.constant(fr.offset(def)), arg)
+			case arg.InStackSlot():
+				instr.Update(op.Load, def.Type, fr.sp, fr.constant(fr.offset(arg)))
+			}
+		}
+	}
+}
+
+// prologue allocates the frame and saves registers at the
+// start of the entry block
+func (fr *frame) prologue() {
+	if fr.size == 0 {
+		return
+	}
+
+	fn := fr.fn
+	entry := fn.Block(0)
+	index := 0
+
+	insert := func(instr *ir2.Instr) {
+		entry.InsertInstr(index, instr)
+		index++
+	}
+
+	alloc := fn.NewInstr(op.Sub, types.Typ[types.Uintptr], fr.sp, fr.constant(fr.size))
+	alloc.Def(0).SetReg(reg.SP)
+	insert(alloc)
+
+	for i, r := range fr.saved {
+		insert(fn.NewInstr(op.Store, nil, fr.sp, fr.constant(fr.savedOffset(i)), fr.regValue(r)))
+	}
+
+	if xform2.HasTag(xform2.HasFramePointer) && reg.FP != reg.None {
+		setFP := fn.NewInstr(op.Copy, types.Typ[types.Uintptr], fr.sp)
+		setFP.Def(0).SetReg(reg.FP)
+		insert(setFP)
+	}
+}
+
+// epilogues restores saved registers and frees the frame
+// before each return
+func (fr *frame) epilogues() {
+	if fr.size == 0 {
+		return
+	}
+
+	fn := fr.fn
+
+	for b := 0; b < fn.NumBlocks(); b++ {
+		blk := fn.Block(b)
+		if blk.NumInstrs() == 0 || !blk.Control().Op.IsReturn() {
+			continue
+		}
+
+		index := blk.Control().Index()
+		insert := func(instr *ir2.Instr) {
+			blk.InsertInstr(index, instr)
+			index++
+		}
+
+		for i, r := range fr.saved {
+			load := fn.NewInstr(op.Load, types.Typ[types.Uintptr], fr.sp, fr.constant(fr.savedOffset(i)))
+			load.Def(0).SetReg(r)
+			insert(load)
+		}
+
+		free := fn.NewInstr(op.Add, types.Typ[types.Uintptr], fr.sp, fr.constant(fr.size))
+		free.Def(0).SetReg(reg.SP)
+		insert(free)
+	}
+}
+
+// regValue returns a value standing in for the register
+func (fr *frame) regValue(r reg.Reg) *ir2.Value {
+	val := fr.fn.NewValue(types.Typ[types.Uintptr])
+	val.SetReg(r)
+	return val
+}
+
+// constant returns a constant offset or size
+func (fr *frame) constant(n int64) *ir2.Value {
+	return fr.fn.ValueFor(types.Typ[types.Int], n)
+}
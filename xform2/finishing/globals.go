@@ -0,0 +1,43 @@
+package finishing
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/ir/reg"
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(globalAddrs,
+	xform2.OnlyPass(xform2.Finishing),
+	xform2.OnOp(op.Load),
+	xform2.Tags(xform2.LoadStoreOffset),
+)
+
+var _ = xform2.Register(globalAddrs,
+	xform2.OnlyPass(xform2.Finishing),
+	xform2.OnOp(op.Store),
+	xform2.Tags(xform2.LoadStoreOffset),
+)
+
+// globalAddrs makes loads and stores of globals relative to the
+// global pointer, with the global's label as the offset
+func globalAddrs(it ir2.Iter) {
+	instr := it.Instr()
+
+	glob := instr.Arg(0)
+	if glob.NeedsReg() {
+		return
+	}
+	if _, ok := ir2.GlobalValue(glob.Const()); !ok {
+		return
+	}
+
+	gp := instr.Func().NewValue(types.Typ[types.Uintptr])
+	gp.SetReg(reg.GP)
+
+	instr.ReplaceArg(0, gp)
+	instr.ReplaceArg(1, glob)
+	it.Changed()
+}
@@ -0,0 +1,316 @@
+package inlining
+
+import (
+	"go/types"
+	"log"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(inlining,
+	xform2.OnlyPass(xform2.Inlining),
+	xform2.Once(),
+)
+
+// budget is how big a func can be to be inlined, which is about what
+// a call costs with the copies of the args and results around it, so
+// the code doesn't get much bigger
+const budget = 6
+
+// callCost is how much a call in a func adds to its size
+const callCost = 4
+
+// inlining replaces calls to small funcs with a copy of their blocks.
+// The block with the call jumps to the copy of the entry block, passing
+// it the args, and the returns jump to the rest of the block after the
+// call, passing it the results.
+//
+// The funcs are parsed in rounds, and all of them are inlined into
+// before any are elaborated. So the blocks are copied as they were
+// parsed, and the copies needed to pass the args and results in
+// registers are never made for the calls inlined. The funcs from
+// an earlier round have been elaborated already, so they can't be.
+// That includes the runtime funcs elaboration adds calls to, like
+// printnl for println, which are only parsed in the round after the
+// func that calls them has been elaborated, so they're never inlined.
+// Only the calls in the func itself are inlined, not the ones in the
+// blocks copied, so recursive funcs can't be inlined forever. Though
+// the funcs inlined will already have had their calls inlined if they
+// came first.
+func inlining(it ir2.Iter) {
+	if it.Block() == nil {
+		// funcs implemented in assembly have no blocks
+		return
+	}
+
+	fn := it.Block().Func()
+
+	for i := 0; i < fn.NumBlocks(); i++ {
+		blk := fn.Block(i)
+
+		for j := 0; j < blk.NumInstrs(); j++ {
+			call := blk.Instr(j)
+
+			callee := inlinable(fn, call)
+			if callee == nil {
+				continue
+			}
+
+			// carry on after the blocks inlined
+			rest := inline(call, callee)
+			i = fn.BlockIndex(rest) - 1
+			break
+		}
+	}
+}
+
+// inlinable returns the func called by the instr if the call can be
+// inlined, or nil if it can't be
+func inlinable(fn *ir2.Func, call *ir2.Instr) *ir2.Func {
+	if call.Op != op.Call || !call.Arg(0).IsConst() {
+		return nil
+	}
+
+	callee, ok := ir2.FuncValue(call.Arg(0).Const())
+	if !ok || callee == fn || callee.NoInline || callee.Elaborated || callee.NumBlocks() == 0 {
+		return nil
+	}
+
+	// the checks elaboration adds depend on the func they're in, so
+	// funcs are only inlined into funcs that get the same checks
+	if callee.NoBounds != fn.NoBounds || isRuntime(callee) != isRuntime(fn) {
+		return nil
+	}
+
+	// deferred calls and recovering from panics need the callee's frame
+	if callee.Recover != nil {
+		return nil
+	}
+
+	entry := callee.Block(0)
+	if entry.NumPreds() > 0 || entry.NumDefs() != call.NumArgs()-1 {
+		return nil
+	}
+	if entry.NumDefs() > 0 && entry.Instr(0).Op != op.Copy {
+		return nil
+	}
+	if call.NumDefs() != callee.Sig.Results().Len() {
+		return nil
+	}
+
+	size := 0
+	for b := 0; b < callee.NumBlocks(); b++ {
+		blk := callee.Block(b)
+		for i := 0; i < blk.NumInstrs(); i++ {
+			instr := blk.Instr(i)
+
+			switch instr.Op {
+			case op.InlineAsm, op.Defer, op.RunDefers:
+				return nil
+
+			case op.CallBuiltin:
+				if name, _ := ir2.StringValue(instr.Arg(0).Const()); name == "recover" {
+					// only recovers when called by the deferred func itself
+					return nil
+				}
+			}
+
+			if b == 0 && i == 0 && blk.NumDefs() > 0 {
+				// the params become block params
+				continue
+			}
+
+			size += cost(instr)
+		}
+	}
+
+	if size > budget && !callee.Inline {
+		return nil
+	}
+
+	return callee
+}
+
+// inline copies the blocks of the callee in place of the call, and
+// returns the block with the rest of the instrs after the call
+func inline(call *ir2.Instr, callee *ir2.Func) *ir2.Block {
+	fn := call.Func()
+	blk := call.Block()
+	pos := call.Pos
+
+	rest := blk.SplitAt(call.Index())
+	blk.RemoveSucc(0)
+
+	// the results are passed to the rest of the block
+	for i := 0; i < call.NumDefs(); i++ {
+		def := call.Def(i)
+		param := rest.AddDef(fn.NewValue(def.Type))
+		def.ReplaceUsesWith(param)
+	}
+	args := call.Args()[1:]
+	call.Update(call.Op, nil)
+	rest.RemoveInstr(call)
+
+	blocks := make([]*ir2.Block, callee.NumBlocks())
+	instrs := make(map[*ir2.Instr]*ir2.Instr)
+	vals := make(map[*ir2.Value]*ir2.Value)
+
+	at := fn.BlockIndex(blk) + 1
+	for b := range blocks {
+		from := callee.Block(b)
+		to := fn.NewBlock()
+		fn.InsertBlock(at+b, to)
+		blocks[b] = to
+
+		start := 0
+		if b == 0 && from.NumDefs() > 0 {
+			// the copy of the params from where they're passed
+			// is replaced with block params taking the args
+			start = 1
+			params := from.Instr(0)
+			for i := 0; i < params.NumDefs(); i++ {
+				vals[params.Def(i)] = to.AddDef(fn.NewValue(params.Def(i).Type))
+			}
+		} else {
+			for _, def := range from.Defs() {
+				vals[def] = to.AddDef(fn.NewValue(def.Type))
+			}
+		}
+
+		for i := start; i < from.NumInstrs(); i++ {
+			instr := from.Instr(i)
+			clone := fn.NewInstr(instr.Op, defsType(instr))
+			clone.Pos = instr.Pos
+			to.InsertInstr(-1, clone)
+			instrs[instr] = clone
+
+			for d := 0; d < instr.NumDefs(); d++ {
+				vals[instr.Def(d)] = clone.Def(d)
+			}
+		}
+	}
+
+	value := func(val *ir2.Value) *ir2.Value {
+		if val.IsConst() {
+			return fn.ValueFor(val.Type, val.Const())
+		}
+		if clone, ok := vals[val]; ok {
+			return clone
+		}
+		log.Panicf("value %s used in %s before it's defined", val.IDString(), callee.FullName)
+		return nil
+	}
+
+	for b, to := range blocks {
+		from := callee.Block(b)
+
+		for i := 0; i < from.NumInstrs(); i++ {
+			instr := from.Instr(i)
+			clone, ok := instrs[instr]
+			if !ok {
+				continue
+			}
+
+			for _, arg := range instr.Args() {
+				clone.InsertArg(-1, value(arg))
+			}
+
+			if instr.Op == op.Return {
+				// the results are passed to the rest of the block
+				for _, arg := range clone.Args() {
+					to.InsertArg(-1, arg)
+				}
+				clone.Update(op.Jump, nil)
+				to.AddSucc(rest)
+				rest.AddPred(to)
+			}
+		}
+
+		for _, arg := range from.Args() {
+			to.InsertArg(-1, value(arg))
+		}
+		for s := 0; s < from.NumSuccs(); s++ {
+			to.AddSucc(blocks[callee.BlockIndex(from.Succ(s))])
+		}
+		for p := 0; p < from.NumPreds(); p++ {
+			to.AddPred(blocks[callee.BlockIndex(from.Pred(p))])
+		}
+	}
+
+	// the block with the call jumps to the entry, passing it the args
+	jump := fn.NewInstr(op.Jump, nil)
+	jump.Pos = pos
+	blk.InsertInstr(-1, jump)
+	for _, arg := range args {
+		blk.InsertArg(-1, arg)
+	}
+	blk.AddSucc(blocks[0])
+	blocks[0].AddPred(blk)
+
+	return rest
+}
+
+// cost estimates how much code the instr becomes
+func cost(instr *ir2.Instr) int {
+	switch instr.Op {
+	case op.Jump, op.Return:
+		// these become jumps, which can often fall through
+		return 0
+
+	case op.CallBuiltin:
+		name, _ := ir2.StringValue(instr.Arg(0).Const())
+		switch name {
+		case "len", "cap":
+			return 2
+		case "print", "println":
+			// a call to print each arg, and the spaces and newline
+			return callCost * 2 * (instr.NumArgs() - 1)
+		}
+		return callCost
+
+	case op.Call, op.Invoke, op.Go, op.Panic, op.New, op.MakeInterface,
+		op.MakeClosure, op.MakeSlice, op.MakeMap, op.MakeChan, op.Lookup,
+		op.MapUpdate, op.Send, op.Recv, op.Select, op.TypeAssert, op.Range,
+		op.Next, op.Slice, op.Convert, op.ChangeInterface:
+		// these are, or become, calls to the runtime
+		return callCost
+	}
+
+	// strings and interfaces are compared and added by the runtime
+	for _, arg := range instr.Args() {
+		switch typ := arg.Type.Underlying().(type) {
+		case *types.Interface:
+			return callCost
+		case *types.Basic:
+			if typ.Info()&types.IsString != 0 {
+				return callCost
+			}
+		}
+	}
+
+	return 1
+}
+
+// defsType returns the type to make a copy of the instr with, which is
+// a tuple if it has more than one def
+func defsType(instr *ir2.Instr) types.Type {
+	switch instr.NumDefs() {
+	case 0:
+		return nil
+	case 1:
+		return instr.Def(0).Type
+	}
+
+	vars := make([]*types.Var, instr.NumDefs())
+	for i, def := range instr.Defs() {
+		vars[i] = types.NewVar(instr.Pos, nil, "", def.Type)
+	}
+	return types.NewTuple(vars...)
+}
+
+// isRuntime returns whether the func is part of the runtime
+func isRuntime(fn *ir2.Func) bool {
+	return fn.Package().Name == "runtime"
+}
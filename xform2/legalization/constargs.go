@@ -0,0 +1,41 @@
+package legalization
+
+import (
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(constFirstArgs,
+	xform2.OnlyPass(xform2.Legalization),
+)
+
+// constFirstArgs makes sure the first operand of an arch instruction is
+// in a register, swapping the operands if possible, otherwise copying it into
+// one. Two-operand instructions are left to get a clobber copy instead.
+func constFirstArgs(it ir2.Iter) {
+	instr := it.Instr()
+	if instr.NumArgs() < 1 || instr.Arg(0).NeedsReg() {
+		return
+	}
+
+	// ops that haven't been translated to the arch's instructions
+	// yet are handled elsewhere
+	if _, ok := instr.Op.(op.Op); ok {
+		return
+	}
+
+	if instr.Op.IsCopy() || instr.Op.IsCall() || instr.Op.ClobbersArg() {
+		return
+	}
+
+	if instr.Op.IsCommutative() && instr.NumArgs() == 2 && instr.Arg(1).NeedsReg() {
+		arg0, arg1 := instr.Arg(0), instr.Arg(1)
+		instr.Update(instr.Op, nil, arg1, arg0)
+		it.Changed()
+		return
+	}
+
+	cp := it.Insert(op.Copy, instr.Arg(0).Type, instr.Arg(0))
+	instr.ReplaceArg(0, cp.Def(0))
+}
@@ -0,0 +1,59 @@
+package legalization
+
+import (
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(storeValues,
+	xform2.OnlyPass(xform2.Legalization),
+	xform2.OnOp(op.Store),
+)
+
+// storeValues makes sure the value being stored is in a register,
+// since constants can't be stored directly to memory
+func storeValues(it ir2.Iter) {
+	instr := it.Instr()
+
+	val := instr.Arg(instr.NumArgs() - 1)
+	if val.NeedsReg() {
+		return
+	}
+
+	cp := it.Insert(op.Copy, val.Type, val)
+	instr.ReplaceArg(instr.NumArgs()-1, cp.Def(0))
+}
+
+var _ = xform2.Register(constBases,
+	xform2.OnlyPass(xform2.Legalization),
+	xform2.OnOp(op.Load),
+	xform2.Tags(xform2.LoadStoreOffset),
+)
+
+var _ = xform2.Register(constBases,
+	xform2.OnlyPass(xform2.Legalization),
+	xform2.OnOp(op.Store),
+	xform2.Tags(xform2.LoadStoreOffset),
+)
+
+// constBases puts constant base addresses in a register, unless it's
+// a global with no offset, which can be made relative to the global
+// pointer instead
+func constBases(it ir2.Iter) {
+	instr := it.Instr()
+
+	base := instr.Arg(0)
+	if base.NeedsReg() {
+		return
+	}
+
+	if _, ok := ir2.GlobalValue(base.Const()); ok {
+		if offset, ok := ir2.IntValue(instr.Arg(1).Const()); ok && offset == 0 {
+			return
+		}
+	}
+
+	cp := it.Insert(op.Copy, base.Type, base)
+	instr.ReplaceArg(0, cp.Def(0))
+}
@@ -0,0 +1,29 @@
+package legalization
+
+import (
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(slotCopies,
+	xform2.OnlyPass(xform2.Legalization),
+	xform2.OnOp(op.Copy),
+)
+
+// slotCopies makes sure anything copied into a stack slot comes
+// from a register, since only registers can be stored to the stack
+func slotCopies(it ir2.Iter) {
+	instr := it.Instr()
+
+	for i := 0; i < instr.NumDefs(); i++ {
+		def := instr.Def(i)
+		arg := instr.Arg(i)
+		if !def.InStackSlot() || arg.NeedsReg() {
+			continue
+		}
+
+		cp := it.Insert(op.Copy, def.Type, arg)
+		instr.ReplaceArg(i, cp.Def(0))
+	}
+}
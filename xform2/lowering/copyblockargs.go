@@ -36,6 +36,13 @@ func copyBlockArgs(it ir2.Iter) {
 		definstr := arg.Def()
 		if definstr == nil || definstr.ID.InstrIn(blk.Func()) == nil || definstr.ID.InstrIn(blk.Func()).Op != op.Copy {
 			allCopied = false
+			continue
+		}
+
+		// a copy elsewhere may be live across the jump, which would
+		// wrongly tie its register to the succ block's def
+		if definstr.Instr().Block() != blk || arg.NumUses() != 1 {
+			allCopied = false
 		}
 	}
 
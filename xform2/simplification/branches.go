@@ -14,11 +14,15 @@ var _ = xform2.Register(swapIfBranches,
 func swapIfBranches(it ir2.Iter) {
 	instr := it.Instr()
 
-	compare := instr.Arg(0).Def().ID.InstrIn(instr.Func())
+	fn := instr.Func()
+	compare := instr.Arg(0).Def().ID.InstrIn(fn)
+
+	// blocks can be split, so block IDs are not in layout order
+	next := it.BlockIndex() + 1
 
 	// if the false branch of the `if` is not the very next block, but the true branch is
-	if instr.Block().Succ(1).Index() != instr.Block().Index()+1 {
-		if instr.Block().Succ(0).Index() == instr.Block().Index()+1 {
+	if fn.BlockIndex(instr.Block().Succ(1)) != next {
+		if fn.BlockIndex(instr.Block().Succ(0)) == next {
 			if opper, ok := compare.Op.(interface{ Opposite() op.Op }); ok {
 				compare.Update(opper.Opposite(), nil, compare.Args())
 				it.Changed()
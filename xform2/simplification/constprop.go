@@ -0,0 +1,319 @@
+package simplification
+
+import (
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(constProp,
+	xform2.OnlyPass(xform2.Simplification),
+	xform2.Once(),
+)
+
+// cell is what's known about a value: nothing yet, that it's always the
+// constant, or that it varies
+type cell struct {
+	con    ir2.Const
+	varies bool
+}
+
+// edge is the ith succ of a block
+type edge struct {
+	blk  *ir2.Block
+	succ int
+}
+
+// propagator holds what's known about the func while it's worked out
+type propagator struct {
+	fn      *ir2.Func
+	cells   map[*ir2.Value]cell
+	edges   map[edge]bool
+	reached map[*ir2.Block]bool
+	changed bool
+}
+
+// constProp does sparse conditional constant propagation. Starting from
+// the entry block, it works out which values are always the same
+// constant, and which blocks can be reached, assuming the best until
+// shown otherwise. Branches on a constant only go one way, so the other
+// way isn't reached, and block params only get the args from the ways
+// that are. This repeats until nothing more is learned.
+//
+// The constants replace the values, the branches on a constant become
+// jumps, and the blocks that can't be reached are removed.
+func constProp(it ir2.Iter) {
+	if it.Block() == nil {
+		// funcs implemented in assembly have no blocks
+		return
+	}
+
+	fn := it.Block().Func()
+	p := &propagator{
+		fn:      fn,
+		cells:   make(map[*ir2.Value]cell),
+		edges:   make(map[edge]bool),
+		reached: map[*ir2.Block]bool{fn.Block(0): true},
+	}
+
+	for p.changed = true; p.changed; {
+		p.changed = false
+		for i := 0; i < fn.NumBlocks(); i++ {
+			if blk := fn.Block(i); p.reached[blk] {
+				p.visit(blk)
+			}
+		}
+	}
+
+	p.rewrite()
+}
+
+// visit works out the block params and the instrs of the block, and
+// which of its succs can be reached
+func (p *propagator) visit(blk *ir2.Block) {
+	for i := 0; i < blk.NumDefs(); i++ {
+		p.set(blk.Def(i), p.param(blk, i))
+	}
+
+	for i := 0; i < blk.NumInstrs(); i++ {
+		instr := blk.Instr(i)
+
+		if !canFold(instr) {
+			for _, def := range instr.Defs() {
+				p.set(def, cell{varies: true})
+			}
+			continue
+		}
+
+		p.set(instr.Def(0), p.eval(instr))
+	}
+
+	p.branch(blk)
+}
+
+// param meets the args passed to the ith param of the block along the
+// edges that can be reached
+func (p *propagator) param(blk *ir2.Block, i int) cell {
+	if blk == p.fn.Block(0) || !blk.Def(i).InTemp() {
+		return cell{varies: true}
+	}
+
+	var c cell
+	seen := make(map[*ir2.Block]bool)
+	for j := 0; j < blk.NumPreds(); j++ {
+		pred := blk.Pred(j)
+		if seen[pred] {
+			continue
+		}
+		seen[pred] = true
+
+		for k := 0; k < pred.NumSuccs(); k++ {
+			if pred.Succ(k) != blk || !p.edges[edge{pred, k}] {
+				continue
+			}
+
+			c = meet(c, p.get(pred.Arg(pred.SuccArgIndex(k)+i)))
+		}
+	}
+	return c
+}
+
+// eval works out the result of the instr from its args
+func (p *propagator) eval(instr *ir2.Instr) cell {
+	args := make([]ir2.Const, instr.NumArgs())
+	for i, arg := range instr.Args() {
+		c := p.get(arg)
+		if c.varies {
+			return c
+		}
+		if c.con == nil {
+			// nothing is known about the arg yet
+			return cell{}
+		}
+		args[i] = c.con
+	}
+
+	con, ok := fold(instr, args)
+	if !ok {
+		return cell{varies: true}
+	}
+	return cell{con: con}
+}
+
+// branch marks the succs the block can go to as reached
+func (p *propagator) branch(blk *ir2.Block) {
+	if blk.NumInstrs() > 0 && blk.Control().Op == op.If {
+		c := p.get(blk.Control().Arg(0))
+		if c.con == nil && !c.varies {
+			// it's not known which way it goes yet
+			return
+		}
+
+		if c.isConst() {
+			// the first succ is taken when it's true
+			if cond, _ := ir2.BoolValue(c.con); cond {
+				p.reach(blk, 0)
+			} else {
+				p.reach(blk, 1)
+			}
+			return
+		}
+	}
+
+	for i := 0; i < blk.NumSuccs(); i++ {
+		p.reach(blk, i)
+	}
+}
+
+// reach marks the edge and the block it goes to as reached
+func (p *propagator) reach(blk *ir2.Block, succ int) {
+	e := edge{blk, succ}
+	if p.edges[e] {
+		return
+	}
+
+	p.edges[e] = true
+	p.reached[blk.Succ(succ)] = true
+	p.changed = true
+}
+
+// get returns the cell for the value. Constant ints are wrapped to the
+// size of their type on the target, like the values they're compared to.
+func (p *propagator) get(val *ir2.Value) cell {
+	if val.IsConst() {
+		switch val.Const().Kind() {
+		case ir2.IntConst:
+			x, _ := ir2.Int64Value(val.Const())
+			if con, ok := wrap(val.Type, x); ok {
+				return cell{con: con}
+			}
+			return cell{con: val.Const()}
+		case ir2.BoolConst:
+			return cell{con: val.Const()}
+		}
+		return cell{varies: true}
+	}
+	return p.cells[val]
+}
+
+// set updates the cell for the value, which only ever goes from unknown
+// to constant to varying
+func (p *propagator) set(val *ir2.Value, c cell) {
+	old := p.cells[val]
+	if old.varies || c == old || (c.con == nil && !c.varies) {
+		return
+	}
+
+	if old.con != nil {
+		// it can't be two different constants
+		c = cell{varies: true}
+	}
+
+	p.cells[val] = c
+	p.changed = true
+}
+
+// meet combines what's known about two values that could be either
+func meet(a, b cell) cell {
+	switch {
+	case a.varies || b.varies:
+		return cell{varies: true}
+	case a.con == nil:
+		return b
+	case b.con == nil || a.con == b.con:
+		return a
+	}
+	return cell{varies: true}
+}
+
+// rewrite replaces the values found to be constant, and removes the
+// branches and blocks that can't be taken
+func (p *propagator) rewrite() {
+	fn := p.fn
+
+	for i := 0; i < fn.NumBlocks(); i++ {
+		blk := fn.Block(i)
+		if !p.reached[blk] {
+			continue
+		}
+
+		if blk.NumInstrs() == 0 {
+			continue
+		}
+
+		ctrl := blk.Control()
+		if ctrl.Op != op.If || !p.get(ctrl.Arg(0)).isConst() {
+			continue
+		}
+
+		for j := blk.NumSuccs() - 1; j >= 0; j-- {
+			if !p.edges[edge{blk, j}] {
+				blk.RemoveSucc(j)
+			}
+		}
+		ctrl.Update(op.Jump, nil)
+	}
+
+	for i := 0; i < fn.NumBlocks(); i++ {
+		blk := fn.Block(i)
+		if p.reached[blk] {
+			continue
+		}
+
+		removeBlock(blk)
+		i--
+	}
+
+	for i := 0; i < fn.NumBlocks(); i++ {
+		blk := fn.Block(i)
+
+		for j := blk.NumDefs() - 1; j >= 0; j-- {
+			param := blk.Def(j)
+			if c := p.cells[param]; c.isConst() {
+				param.ReplaceUsesWith(fn.ValueFor(param.Type, c.con))
+				removeParam(blk, j)
+			}
+		}
+
+		for j := 0; j < blk.NumInstrs(); j++ {
+			instr := blk.Instr(j)
+			if !canFold(instr) {
+				continue
+			}
+
+			def := instr.Def(0)
+			if c := p.cells[def]; c.isConst() {
+				def.ReplaceUsesWith(fn.ValueFor(def.Type, c.con))
+				instr.Update(instr.Op, nil)
+				blk.RemoveInstr(instr)
+				j--
+			}
+		}
+	}
+}
+
+// removeParam removes the ith param of the block, and the args passed
+// to it by the preds
+func removeParam(blk *ir2.Block, i int) {
+	seen := make(map[*ir2.Block]bool)
+	for j := 0; j < blk.NumPreds(); j++ {
+		pred := blk.Pred(j)
+		if seen[pred] {
+			continue
+		}
+		seen[pred] = true
+
+		for k := pred.NumSuccs() - 1; k >= 0; k-- {
+			if pred.Succ(k) == blk {
+				pred.RemoveArgAt(pred.SuccArgIndex(k) + i)
+			}
+		}
+	}
+
+	blk.RemoveDef(blk.Def(i))
+}
+
+// isConst returns whether the value is always the constant
+func (c cell) isConst() bool {
+	return c.con != nil && !c.varies
+}
@@ -0,0 +1,131 @@
+package simplification
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(cse,
+	xform2.OnlyPass(xform2.Simplification),
+	xform2.Once(),
+)
+
+// cse does common subexpression elimination. The blocks are walked down
+// the dominator tree, keeping a table of the expressions computed by the
+// blocks above, by op, type and args. An instr that computes one that's
+// already in the table is replaced by the value from it, which is always
+// computed first since its block dominates this one.
+//
+// The args of commutative ops are put in order so that a+b and b+a are
+// the same expression. Loads and anything with effects are left alone.
+func cse(it ir2.Iter) {
+	if it.Block() == nil {
+		// funcs implemented in assembly have no blocks
+		return
+	}
+
+	fn := it.Block().Func()
+	dt := fn.DomTree()
+	exprs := make(map[string]*ir2.Value)
+
+	var walk func(blk *ir2.Block)
+	walk = func(blk *ir2.Block) {
+		var added []string
+
+		for i := 0; i < blk.NumInstrs(); i++ {
+			instr := blk.Instr(i)
+			if !isPure(instr) || isOffset(instr) {
+				continue
+			}
+
+			key := exprKey(instr)
+			def := instr.Def(0)
+
+			if val, ok := exprs[key]; ok {
+				if producesCarry(instr) {
+					// the carry is used by the next instr, so this has to stay
+					continue
+				}
+
+				def.ReplaceUsesWith(val)
+				instr.Update(instr.Op, nil)
+				blk.RemoveInstr(instr)
+				i--
+				continue
+			}
+
+			exprs[key] = def
+			added = append(added, key)
+		}
+
+		for _, child := range dt.Children(blk) {
+			walk(child)
+		}
+
+		// the expressions are only available in the blocks dominated
+		for _, key := range added {
+			delete(exprs, key)
+		}
+	}
+	walk(fn.Block(0))
+}
+
+// isPure returns whether the instr only computes its result from its
+// args, so it computes the same thing wherever it is
+func isPure(instr *ir2.Instr) bool {
+	if instr.NumDefs() != 1 || !instr.Def(0).InTemp() {
+		return false
+	}
+
+	// comparisons aren't shared, since they're fused with the branch
+	// using them, and each branch needs its own
+	switch instr.Op {
+	case op.Copy, op.Add, op.Sub, op.Mul, op.Div, op.Rem, op.And, op.Or,
+		op.Xor, op.ShiftLeft, op.ShiftRight, op.AndNot, op.Not, op.Negate,
+		op.Invert:
+		return true
+	}
+	return false
+}
+
+// isOffset returns whether the instr adds a constant offset, which loads
+// and stores can do for free, so sharing it would only tie up a register
+func isOffset(instr *ir2.Instr) bool {
+	return instr.Op == op.Add && instr.Arg(1).IsConst() && xform2.HasTag(xform2.LoadStoreOffset)
+}
+
+// producesCarry returns whether the next instr uses the carry or borrow
+// from this one
+func producesCarry(instr *ir2.Instr) bool {
+	blk := instr.Block()
+	next := instr.Index() + 1
+	if next >= blk.NumInstrs() {
+		return false
+	}
+
+	switch blk.Instr(next).Op {
+	case op.AddCarry, op.SubBorrow:
+		return true
+	}
+	return false
+}
+
+// exprKey returns the key for the expression the instr computes
+func exprKey(instr *ir2.Instr) string {
+	args := instr.Args()
+	if instr.Op.IsCommutative() && args[1].ID < args[0].ID {
+		args[0], args[1] = args[1], args[0]
+	}
+
+	var key strings.Builder
+	fmt.Fprintf(&key, "%s:%s", instr.Op, types.TypeString(instr.Def(0).Type, nil))
+	for _, arg := range args {
+		fmt.Fprintf(&key, " %s", arg.IDString())
+	}
+	return key.String()
+}
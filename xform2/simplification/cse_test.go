@@ -0,0 +1,65 @@
+package simplification_test
+
+import (
+	"testing"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+)
+
+func TestCSE_sharesDominatingExprs(t *testing.T) {
+	fn := simplify(t, `
+	.b0:
+		v0:int = parameter 0
+		v1:int = parameter 1
+		v2:bool = parameter 2
+		v3:int = xor v0, v1
+		if v2, .b1, .b2
+	.b1:
+		v4:int = xor v1, v0
+		v5:int = or v4, v0
+		return v5
+	.b2:
+		v6:int = or v3, v1
+		v7:int = or v1, v3
+		v8:int = sub v6, v7
+		return v8
+	`)
+
+	blocks := make([]*ir2.Block, fn.NumBlocks())
+	for i := range blocks {
+		blocks[i] = fn.Block(i)
+	}
+
+	if xors := instrsIn(blocks, op.Xor); len(xors) != 1 {
+		t.Errorf("expected xor v1, v0 to be replaced with xor v0, v1, got:\n%s", fn.LongString())
+	}
+	if ors := instrsIn(blocks, op.Or); len(ors) != 2 {
+		t.Errorf("expected or v1, v3 to be replaced with or v3, v1, got:\n%s", fn.LongString())
+	}
+}
+
+func TestCSE_leavesSiblingBlocks(t *testing.T) {
+	fn := simplify(t, `
+	.b0:
+		v0:int = parameter 0
+		v1:int = parameter 1
+		v2:bool = parameter 2
+		if v2, .b1, .b2
+	.b1:
+		v3:int = xor v0, v1
+		return v3
+	.b2:
+		v4:int = xor v0, v1
+		return v4
+	`)
+
+	blocks := make([]*ir2.Block, fn.NumBlocks())
+	for i := range blocks {
+		blocks[i] = fn.Block(i)
+	}
+
+	if xors := instrsIn(blocks, op.Xor); len(xors) != 2 {
+		t.Errorf("expected neither xor to dominate the other, got:\n%s", fn.LongString())
+	}
+}
@@ -0,0 +1,175 @@
+package simplification
+
+import (
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(deadCode,
+	xform2.Passes(xform2.Simplification, xform2.Lowering),
+	xform2.Once(),
+)
+
+// deadCode removes the blocks that can't be reached, and the instrs and
+// block params with results that are never used.
+//
+// The instrs with effects, like stores, calls and branches, are live, and
+// so are the values they use. Then the instrs defining those values are
+// live, and for block params, the args passed to them. Everything that's
+// not found to be live this way is removed, even if it's used by other
+// dead instrs or passed around a loop to itself.
+//
+// It runs again at the start of lowering to clean up after the rest of
+// simplification, like the multiplies replaced with shifts.
+func deadCode(it ir2.Iter) {
+	if it.Block() == nil {
+		// funcs implemented in assembly have no blocks
+		return
+	}
+
+	fn := it.Block().Func()
+	dt := fn.DomTree()
+
+	for i := 0; i < fn.NumBlocks(); i++ {
+		blk := fn.Block(i)
+		if !dt.Reachable(blk) {
+			removeBlock(blk)
+			i--
+		}
+	}
+
+	live := make(map[*ir2.Value]bool)
+	liveInstrs := make(map[*ir2.Instr]bool)
+	var work []*ir2.Value
+
+	var markInstr func(instr *ir2.Instr)
+	markInstr = func(instr *ir2.Instr) {
+		if liveInstrs[instr] {
+			return
+		}
+		liveInstrs[instr] = true
+		work = append(work, instr.Args()...)
+
+		switch instr.Op {
+		case op.AddCarry, op.SubBorrow:
+			// the carry comes from the instr right before
+			markInstr(instr.Block().Instr(instr.Index() - 1))
+		}
+	}
+
+	for i := 0; i < fn.NumBlocks(); i++ {
+		blk := fn.Block(i)
+		for _, param := range blk.Defs() {
+			if !param.InTemp() {
+				work = append(work, param)
+			}
+		}
+
+		for j := 0; j < blk.NumInstrs(); j++ {
+			if instr := blk.Instr(j); !canRemove(instr) {
+				markInstr(instr)
+			}
+		}
+	}
+
+	for len(work) > 0 {
+		val := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		if live[val] || val.Def() == nil {
+			continue
+		}
+		live[val] = true
+
+		def := val.Def()
+		if def.IsInstr() {
+			markInstr(def.Instr())
+			continue
+		}
+
+		// block params are live along with the args passed to them
+		blk := def.Block()
+		i := paramIndex(blk, val)
+		for j := 0; j < blk.NumPreds(); j++ {
+			pred := blk.Pred(j)
+			for k := 0; k < pred.NumSuccs(); k++ {
+				if pred.Succ(k) == blk {
+					work = append(work, pred.Arg(pred.SuccArgIndex(k)+i))
+				}
+			}
+		}
+	}
+
+	for i := 0; i < fn.NumBlocks(); i++ {
+		blk := fn.Block(i)
+
+		for j := blk.NumInstrs() - 1; j >= 0; j-- {
+			instr := blk.Instr(j)
+			if !liveInstrs[instr] {
+				instr.Update(instr.Op, nil)
+				blk.RemoveInstr(instr)
+			}
+		}
+	}
+
+	for i := 0; i < fn.NumBlocks(); i++ {
+		blk := fn.Block(i)
+
+		for j := blk.NumDefs() - 1; j >= 0; j-- {
+			if !live[blk.Def(j)] {
+				removeParam(blk, j)
+			}
+		}
+	}
+}
+
+// canRemove returns whether the instr only computes its results, without
+// any effects, so it can be removed when they aren't used
+func canRemove(instr *ir2.Instr) bool {
+	for _, def := range instr.Defs() {
+		if !def.InTemp() {
+			return false
+		}
+	}
+
+	if instr.Op.IsCompare() {
+		return true
+	}
+
+	switch instr.Op {
+	case op.Copy, op.Add, op.Sub, op.AddCarry, op.SubBorrow, op.Mul, op.Div,
+		op.Rem, op.And, op.Or, op.Xor, op.ShiftLeft, op.ShiftRight, op.AndNot,
+		op.Not, op.Negate, op.Invert, op.Load:
+		// dividing by zero and loading through nil are checked
+		// for before, so these can't trap
+		return true
+	}
+	return false
+}
+
+// paramIndex returns the index of the param of the block
+func paramIndex(blk *ir2.Block, param *ir2.Value) int {
+	for i, def := range blk.Defs() {
+		if def == param {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeBlock removes a block that can't be reached, along with the uses
+// of values by it and the edges to the blocks it goes to. The edges to it
+// are removed with the blocks they're from, which can't be reached either.
+func removeBlock(blk *ir2.Block) {
+	for i := 0; i < blk.NumInstrs(); i++ {
+		instr := blk.Instr(i)
+		instr.Update(instr.Op, nil)
+	}
+
+	for i := blk.NumSuccs() - 1; i >= 0; i-- {
+		blk.RemoveSucc(i)
+	}
+
+	blk.Func().RemoveBlock(blk)
+}
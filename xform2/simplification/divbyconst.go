@@ -0,0 +1,69 @@
+package simplification
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(divByConst,
+	xform2.OnlyPass(xform2.Simplification),
+	xform2.OnOp(op.Div),
+)
+
+var _ = xform2.Register(divByConst,
+	xform2.OnlyPass(xform2.Simplification),
+	xform2.OnOp(op.Rem),
+)
+
+// divByConst turns divides by a power of two into right shifts, and
+// unsigned remainders by a power of two into masks. Signed remainders
+// are left alone.
+func divByConst(it ir2.Iter) {
+	instr := it.Instr()
+
+	if !instr.Arg(1).IsConst() {
+		return
+	}
+
+	typ := instr.Def(0).Type
+	if !isInteger(typ) || (!isUnsigned(typ) && instr.Op == op.Rem) {
+		return
+	}
+
+	n, ok := ir2.Log2Value(instr.Arg(1).Const())
+	if !ok {
+		return
+	}
+
+	if !isUnsigned(typ) && n > 0 {
+		signedDivByPowerOfTwo(it, n)
+		return
+	}
+
+	fn := instr.Func()
+	if instr.Op == op.Div {
+		instr.Update(op.ShiftRight, instr.Def(0).Type, instr.Arg(0), fn.ValueFor(instr.Arg(1).Type, n))
+	} else {
+		instr.Update(op.And, instr.Def(0).Type, instr.Arg(0), fn.ValueFor(instr.Arg(1).Type, int64(1)<<n-1))
+	}
+	it.Changed()
+}
+
+// signedDivByPowerOfTwo turns a signed divide by 2^n into an arithmetic
+// right shift. The shift rounds down rather than towards zero, so
+// negative numbers are biased by 2^n-1 first, which is the sign shifted
+// down to the bottom n bits.
+func signedDivByPowerOfTwo(it ir2.Iter, n int64) {
+	instr := it.Instr()
+	typ := instr.Def(0).Type
+	x := instr.Arg(0)
+	bits := int64(intBits(typ))
+
+	sign := it.Insert(op.ShiftRight, typ, x, bits-1)
+	bias := it.Insert(op.ShiftRight, types.Typ[types.Uint], sign.Def(0), bits-n)
+	biased := it.Insert(op.Add, typ, x, bias.Def(0))
+	it.Update(op.ShiftRight, typ, biased.Def(0), n)
+}
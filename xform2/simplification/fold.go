@@ -0,0 +1,232 @@
+package simplification
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/sizes"
+)
+
+// canFold returns whether the instruction is one fold knows how to work
+// out from constant args, and that only has its result as an effect
+func canFold(instr *ir2.Instr) bool {
+	if instr.NumDefs() != 1 || !instr.Def(0).InTemp() {
+		return false
+	}
+
+	switch instr.Op {
+	case op.Add, op.Sub:
+		// the carry is used by the next instr, so this has to stay
+		return !producesCarry(instr)
+
+	case op.Copy, op.Mul, op.Div, op.Rem, op.And, op.Or, op.Xor,
+		op.ShiftLeft, op.ShiftRight, op.AndNot, op.Not, op.Negate,
+		op.Invert, op.Equal, op.NotEqual, op.Less, op.LessEqual,
+		op.Greater, op.GreaterEqual:
+		return true
+	}
+	return false
+}
+
+// fold works out the result of the instruction with the constant args,
+// wrapping around like the target does for the size of the result. Only
+// ints and bools are folded, and divides by zero are left to panic.
+func fold(instr *ir2.Instr, args []ir2.Const) (ir2.Const, bool) {
+	typ := instr.Def(0).Type
+
+	switch instr.Op {
+	case op.Copy:
+		// conversions between kinds of integers are copies by now
+		if b, ok := ir2.BoolValue(args[0]); ok {
+			return ir2.ConstFor(b), true
+		}
+		x, ok := ir2.Int64Value(args[0])
+		if !ok {
+			return nil, false
+		}
+		return wrap(typ, x)
+
+	case op.Not:
+		b, ok := ir2.BoolValue(args[0])
+		if !ok {
+			return nil, false
+		}
+		return ir2.ConstFor(!b), true
+
+	case op.Equal, op.NotEqual:
+		if a, ok := ir2.BoolValue(args[0]); ok {
+			b, ok := ir2.BoolValue(args[1])
+			if !ok {
+				return nil, false
+			}
+			return ir2.ConstFor((a == b) == (instr.Op == op.Equal)), true
+		}
+	}
+
+	x, ok := ir2.Int64Value(args[0])
+	if !ok {
+		return nil, false
+	}
+
+	switch instr.Op {
+	case op.Negate:
+		return wrap(typ, -trunc(typ, x))
+	case op.Invert:
+		return wrap(typ, ^trunc(typ, x))
+	}
+
+	y, ok := ir2.Int64Value(args[1])
+	if !ok {
+		return nil, false
+	}
+
+	if instr.Op.IsCompare() {
+		return compare(instr.Op, instr.Arg(0).Type, x, y)
+	}
+
+	// the words of multi-word values are reinterpreted by the op, so the
+	// args are the type of the result rather than their own
+	x = trunc(typ, x)
+	if instr.Op != op.ShiftLeft && instr.Op != op.ShiftRight {
+		y = trunc(typ, y)
+	}
+
+	switch instr.Op {
+	case op.Add:
+		return wrap(typ, x+y)
+	case op.Sub:
+		return wrap(typ, x-y)
+	case op.Mul:
+		return wrap(typ, x*y)
+	case op.And:
+		return wrap(typ, x&y)
+	case op.Or:
+		return wrap(typ, x|y)
+	case op.Xor:
+		return wrap(typ, x^y)
+	case op.AndNot:
+		return wrap(typ, x&^y)
+
+	case op.Div, op.Rem:
+		if y == 0 {
+			return nil, false
+		}
+		if isUnsigned(typ) {
+			if instr.Op == op.Div {
+				return wrap(typ, int64(uint64(x)/uint64(y)))
+			}
+			return wrap(typ, int64(uint64(x)%uint64(y)))
+		}
+		if instr.Op == op.Div {
+			return wrap(typ, x/y)
+		}
+		return wrap(typ, x%y)
+
+	case op.ShiftLeft, op.ShiftRight:
+		if y < 0 && !isUnsigned(instr.Arg(1).Type) {
+			// shifting by a negative amount panics
+			return nil, false
+		}
+		n := uint64(y)
+
+		if instr.Op == op.ShiftLeft {
+			return wrap(typ, x<<n)
+		}
+		if isUnsigned(typ) {
+			return wrap(typ, int64(uint64(x)>>n))
+		}
+		return wrap(typ, x>>n)
+	}
+
+	return nil, false
+}
+
+// compare works out the comparison, with the signedness of the type
+// of the values compared
+func compare(o ir2.Op, typ types.Type, x, y int64) (ir2.Const, bool) {
+	if !isInteger(typ) {
+		return nil, false
+	}
+	x, y = trunc(typ, x), trunc(typ, y)
+
+	cmp := 0
+	if isUnsigned(typ) {
+		if uint64(x) < uint64(y) {
+			cmp = -1
+		} else if uint64(x) > uint64(y) {
+			cmp = 1
+		}
+	} else {
+		if x < y {
+			cmp = -1
+		} else if x > y {
+			cmp = 1
+		}
+	}
+
+	switch o {
+	case op.Equal:
+		return ir2.ConstFor(cmp == 0), true
+	case op.NotEqual:
+		return ir2.ConstFor(cmp != 0), true
+	case op.Less:
+		return ir2.ConstFor(cmp < 0), true
+	case op.LessEqual:
+		return ir2.ConstFor(cmp <= 0), true
+	case op.Greater:
+		return ir2.ConstFor(cmp > 0), true
+	case op.GreaterEqual:
+		return ir2.ConstFor(cmp >= 0), true
+	}
+	return nil, false
+}
+
+// wrap returns the value as a constant of the integer type, wrapped
+// around to the size of the type on the target
+func wrap(typ types.Type, x int64) (ir2.Const, bool) {
+	if !isInteger(typ) {
+		return nil, false
+	}
+	return ir2.ConstFor(trunc(typ, x)), true
+}
+
+// trunc truncates the value to the number of bits the integer type has
+// on the target, sign extending it if the type is signed
+func trunc(typ types.Type, x int64) int64 {
+	if !isInteger(typ) {
+		return x
+	}
+
+	if bits := intBits(typ); bits < 64 {
+		shift := 64 - bits
+		if isUnsigned(typ) {
+			return int64(uint64(x) << shift >> shift)
+		}
+		return x << shift >> shift
+	}
+	return x
+}
+
+// intBits returns the number of bits in the integer type on the target
+func intBits(typ types.Type) uint {
+	if typ.Underlying().(*types.Basic).Info()&types.IsUntyped != 0 {
+		return 64
+	}
+
+	bits := sizes.Sizeof(typ) * int64(sizes.MinAddressableBits())
+	if bits <= 0 || bits > 64 {
+		return 64
+	}
+	return uint(bits)
+}
+
+func isInteger(typ types.Type) bool {
+	basic, ok := typ.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsInteger != 0
+}
+
+func isUnsigned(typ types.Type) bool {
+	basic, ok := typ.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsUnsigned != 0
+}
@@ -0,0 +1,256 @@
+package simplification
+
+import (
+	"go/types"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(loops,
+	xform2.OnlyPass(xform2.Simplification),
+	xform2.Once(),
+)
+
+// loops optimizes the loops with a preheader, starting with the ones
+// inside the others, so what's moved out of them can be moved out of
+// the loops they're in as well.
+//
+// Instrs that compute the same thing each iteration are moved to the
+// preheader. Addresses computed from an induction variable, which goes
+// up or down by a constant each iteration, become pointers that move by
+// the constant times the size each iteration instead, so walking an
+// array doesn't multiply the index and add it to the array each time.
+func loops(it ir2.Iter) {
+	if it.Block() == nil {
+		// funcs implemented in assembly have no blocks
+		return
+	}
+
+	fn := it.Block().Func()
+	ln := fn.LoopNest(fn.DomTree())
+
+	for _, loop := range ln.Loops() {
+		if loop.Preheader == nil {
+			continue
+		}
+
+		hoist(loop)
+		reduceInductions(loop)
+	}
+}
+
+// hoist moves the instrs in the loop with args that are the same each
+// iteration to the end of the preheader. The blocks are in dominator
+// order, so the instrs using the ones moved come after them.
+func hoist(loop *ir2.Loop) {
+	ctrl := loop.Preheader.Control()
+
+	for _, blk := range loop.Blocks {
+		for i := 0; i < blk.NumInstrs(); i++ {
+			instr := blk.Instr(i)
+			if !canHoist(loop, instr) {
+				continue
+			}
+
+			instr.MoveBefore(ctrl)
+			i--
+		}
+	}
+}
+
+// canHoist returns whether the instr can be moved out of the loop. It's
+// done even when the instr wouldn't always run, so ones that could
+// trap, like divides, are left alone. So are ones that only use
+// constants, since they'd just tie up a register for the whole loop.
+func canHoist(loop *ir2.Loop, instr *ir2.Instr) bool {
+	if !isPure(instr) || isOffset(instr) || producesCarry(instr) {
+		return false
+	}
+
+	switch instr.Op {
+	case op.Div, op.Rem:
+		return false
+	}
+
+	consts := true
+	for _, arg := range instr.Args() {
+		if !loop.Invariant(arg) {
+			return false
+		}
+		if !arg.IsConst() {
+			consts = false
+		}
+	}
+	return !consts
+}
+
+// reduceInductions finds the params of the loop header that are
+// induction variables, and strength reduces the addresses computed
+// from them, which look like `base + iv*size`
+func reduceInductions(loop *ir2.Loop) {
+	header := loop.Header
+
+	for i := 0; i < header.NumDefs(); i++ {
+		iv := header.Def(i)
+
+		next, step := inductionStep(loop, i)
+		if next == nil {
+			continue
+		}
+
+		for _, mul := range usersIn(loop, iv, op.Mul) {
+			size, ok := constArg(mul, iv)
+			if !ok {
+				continue
+			}
+
+			for _, add := range usersIn(loop, mul.Def(0), op.Add) {
+				base := add.Arg(0)
+				if base == mul.Def(0) {
+					base = add.Arg(1)
+				}
+				if !loop.Invariant(base) || !add.Def(0).InTemp() {
+					continue
+				}
+
+				reduce(loop, i, next, step*size, size, base, add)
+			}
+
+			if mul.Def(0).NumUses() == 0 {
+				mul.Update(mul.Op, nil)
+				mul.Block().RemoveInstr(mul)
+			}
+		}
+	}
+}
+
+// inductionStep returns the instr that adds the step to the ith param of
+// the loop header, if it's passed back to the header by every latch.
+// Subtracting a constant is a negative step.
+func inductionStep(loop *ir2.Loop, i int) (*ir2.Instr, int64) {
+	header := loop.Header
+	iv := header.Def(i)
+
+	var next *ir2.Value
+	for _, latch := range loop.Latches {
+		for k := 0; k < latch.NumSuccs(); k++ {
+			if latch.Succ(k) != header {
+				continue
+			}
+
+			arg := latch.Arg(latch.SuccArgIndex(k) + i)
+			if next != nil && arg != next {
+				return nil, 0
+			}
+			next = arg
+		}
+	}
+
+	if next == nil || !(next.IsDefinedByOp(op.Add) || next.IsDefinedByOp(op.Sub)) {
+		return nil, 0
+	}
+
+	add := next.Def().Instr()
+	if producesCarry(add) {
+		return nil, 0
+	}
+
+	step, ok := constArg(add, iv)
+	if !ok {
+		return nil, 0
+	}
+	if add.Op == op.Sub {
+		if add.Arg(0) != iv {
+			// the constant minus the variable goes back and forth
+			return nil, 0
+		}
+		step = -step
+	}
+	return add, step
+}
+
+// reduce replaces the address with a new param of the loop header,
+// which starts at the address of the initial value of the induction
+// variable, and goes up by the step times the size along with it
+func reduce(loop *ir2.Loop, i int, next *ir2.Instr, incr, size int64, base *ir2.Value, add *ir2.Instr) {
+	header := loop.Header
+	pre := loop.Preheader
+	fn := header.Func()
+	typ := add.Def(0).Type
+	intType := types.Typ[types.Int]
+	ctrl := pre.Control()
+
+	// the first address is worked out in the preheader
+	init := pre.Arg(pre.SuccArgIndex(0) + i)
+	var start *ir2.Instr
+	if n, ok := ir2.Int64Value(init.Const()); ok && init.IsConst() {
+		if n == 0 {
+			start = fn.NewInstr(op.Copy, typ, base)
+		} else {
+			start = fn.NewInstr(op.Add, typ, base, fn.ValueFor(intType, n*size))
+		}
+	} else {
+		offset := fn.NewInstr(op.Mul, intType, init, size)
+		offset.Pos = add.Pos
+		pre.InsertInstr(ctrl.Index(), offset)
+		start = fn.NewInstr(op.Add, typ, base, offset.Def(0))
+	}
+	start.Pos = add.Pos
+	pre.InsertInstr(ctrl.Index(), start)
+
+	ptr := header.AddDef(fn.NewValue(typ))
+	index := header.NumDefs() - 1
+	pre.InsertArg(pre.SuccArgIndex(0)+index, start.Def(0))
+
+	// and goes up right after the induction variable does
+	step := fn.NewInstr(op.Add, typ, ptr, fn.ValueFor(intType, incr))
+	step.Pos = next.Pos
+	next.Block().InsertInstr(next.Index()+1, step)
+
+	for _, latch := range loop.Latches {
+		for k := 0; k < latch.NumSuccs(); k++ {
+			if latch.Succ(k) == header {
+				latch.InsertArg(latch.SuccArgIndex(k)+index, step.Def(0))
+			}
+		}
+	}
+
+	add.Def(0).ReplaceUsesWith(ptr)
+	add.Update(add.Op, nil)
+	add.Block().RemoveInstr(add)
+}
+
+// usersIn returns the instrs in the loop with the op that use the value
+func usersIn(loop *ir2.Loop, val *ir2.Value, o op.Op) []*ir2.Instr {
+	var users []*ir2.Instr
+	for i := 0; i < val.NumUses(); i++ {
+		instr := val.Use(i).Instr()
+		if instr.Op != o || instr.Block() == nil || !loop.Contains(instr.Block()) {
+			continue
+		}
+		users = append(users, instr)
+	}
+	return users
+}
+
+// constArg returns the int constant the instr uses with the value, if
+// the other arg is one
+func constArg(instr *ir2.Instr, val *ir2.Value) (int64, bool) {
+	if instr.NumArgs() != 2 || !instr.Def(0).InTemp() {
+		return 0, false
+	}
+
+	other := instr.Arg(1)
+	if instr.Arg(1) == val {
+		other = instr.Arg(0)
+	} else if instr.Arg(0) != val {
+		return 0, false
+	}
+
+	if !other.IsConst() {
+		return 0, false
+	}
+	return ir2.Int64Value(other.Const())
+}
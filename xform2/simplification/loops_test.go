@@ -0,0 +1,154 @@
+package simplification_test
+
+import (
+	"testing"
+
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/ir2/parseir"
+	"github.com/rj45/nanogo/xform2"
+
+	// registering rj32 sets it as the default arch
+	_ "github.com/rj45/nanogo/arch/rj32"
+	_ "github.com/rj45/nanogo/xform2/simplification"
+)
+
+// simplify parses the func and runs the simplification pass on it
+func simplify(t *testing.T, text string) *ir2.Func {
+	t.Helper()
+
+	fn, err := parseir.ParseString(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xform2.Transform(xform2.Simplification, fn)
+	return fn
+}
+
+// instrsIn returns the instrs with the op in the blocks
+func instrsIn(blocks []*ir2.Block, o op.Op) []*ir2.Instr {
+	var instrs []*ir2.Instr
+	for _, blk := range blocks {
+		for i := 0; i < blk.NumInstrs(); i++ {
+			if blk.Instr(i).Op == o {
+				instrs = append(instrs, blk.Instr(i))
+			}
+		}
+	}
+	return instrs
+}
+
+func TestLoops_hoistsInvariants(t *testing.T) {
+	fn := simplify(t, `
+	.b0:
+		v0:*int = parameter 0
+		v1:int = parameter 1
+		v2:int = parameter 2
+		jump .b1(0:int)
+	.b1(v3:int):
+		v4:bool = less v3, 10:int
+		if v4, .b2, .b3
+	.b2:
+		v5:int = xor v1, v2
+		v6:int = add v3, v5
+		store v0, 0:int, v6
+		v7:int = add v3, 1:int
+		jump .b1(v7)
+	.b3:
+		return
+	`)
+
+	loop := fn.LoopNest(fn.DomTree()).Loops()[0]
+	if xors := instrsIn(loop.Blocks, op.Xor); len(xors) != 0 {
+		t.Errorf("expected the xor to be moved out of the loop, got:\n%s", fn.LongString())
+	}
+	if xors := instrsIn([]*ir2.Block{loop.Preheader}, op.Xor); len(xors) != 1 {
+		t.Errorf("expected the xor to be moved to the preheader, got:\n%s", fn.LongString())
+	}
+}
+
+func TestLoops_reducesInductions(t *testing.T) {
+	tests := []struct {
+		desc string
+		next string
+		incr int64
+	}{
+		{"adding", "add v2, 1:int", 4},
+		{"adding on the left", "add 2:int, v2", 8},
+		{"subtracting", "sub v2, 1:int", -4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			fn := simplify(t, `
+			.b0:
+				v0:*int = parameter 0
+				jump .b1(0:int, 15:int)
+			.b1(v1:int, v2:int):
+				v3:bool = less v2, 4:int
+				if v3, .b3, .b2
+			.b2:
+				v4:int = mul v2, 4:int
+				v5:*int = add v0, v4
+				v6:int = load v5, 0:int
+				v7:int = add v1, v6
+				v8:int = `+tt.next+`
+				jump .b1(v7, v8)
+			.b3:
+				return v1
+			`)
+
+			loop := fn.LoopNest(fn.DomTree()).Loops()[0]
+			if muls := instrsIn(loop.Blocks, op.Mul); len(muls) != 0 {
+				t.Errorf("expected the index not to be multiplied in the loop, got:\n%s", fn.LongString())
+			}
+			if loop.Header.NumDefs() != 3 {
+				t.Fatalf("expected a pointer to be added to the loop header, got:\n%s", fn.LongString())
+			}
+
+			ptr := loop.Header.Def(2)
+			load := instrsIn(loop.Blocks, op.Load)[0]
+			if load.Arg(0) != ptr {
+				t.Errorf("expected the load to use the pointer, got:\n%s", fn.LongString())
+			}
+
+			found := false
+			for _, add := range instrsIn(loop.Blocks, op.Add) {
+				if add.Arg(0) != ptr || !add.Arg(1).IsConst() {
+					continue
+				}
+				found = true
+				if incr, _ := ir2.Int64Value(add.Arg(1).Const()); incr != tt.incr {
+					t.Errorf("expected the pointer to move by %d, got:\n%s", tt.incr, fn.LongString())
+				}
+			}
+			if !found {
+				t.Errorf("expected the pointer to move each iteration, got:\n%s", fn.LongString())
+			}
+		})
+	}
+}
+
+func TestLoops_leavesSubtractingFromAConstant(t *testing.T) {
+	fn := simplify(t, `
+	.b0:
+		v0:*int = parameter 0
+		jump .b1(0:int, 15:int)
+	.b1(v1:int, v2:int):
+		v3:bool = less v2, 4:int
+		if v3, .b3, .b2
+	.b2:
+		v4:int = mul v2, 4:int
+		v5:*int = add v0, v4
+		v6:int = load v5, 0:int
+		v7:int = add v1, v6
+		v8:int = sub 20:int, v2
+		jump .b1(v7, v8)
+	.b3:
+		return v1
+	`)
+
+	loop := fn.LoopNest(fn.DomTree()).Loops()[0]
+	if loop.Header.NumDefs() != 2 {
+		t.Errorf("expected no pointer for a variable that goes back and forth, got:\n%s", fn.LongString())
+	}
+}
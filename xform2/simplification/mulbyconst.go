@@ -0,0 +1,54 @@
+package simplification
+
+import (
+	"github.com/rj45/nanogo/ir2"
+	"github.com/rj45/nanogo/ir2/op"
+	"github.com/rj45/nanogo/xform2"
+)
+
+var _ = xform2.Register(mulByConst,
+	xform2.OnlyPass(xform2.Simplification),
+	xform2.OnOp(op.Mul),
+)
+
+// mulByConst turns multiplies by a power of two into shifts, and
+// removes multiplies by one and zero.
+func mulByConst(it ir2.Iter) {
+	instr := it.Instr()
+
+	if instr.Arg(0).IsConst() && !instr.Arg(1).IsConst() {
+		// multiplies commute, so put the constant second
+		instr.Update(instr.Op, nil, instr.Arg(1), instr.Arg(0))
+		it.Changed()
+	}
+
+	if !instr.Arg(1).IsConst() {
+		return
+	}
+
+	amt, ok := ir2.Int64Value(instr.Arg(1).Const())
+	if !ok {
+		return
+	}
+
+	if amt == 1 {
+		instr.Def(0).ReplaceUsesWith(instr.Arg(0))
+		it.Remove()
+		return
+	}
+
+	if amt == 0 {
+		instr.Def(0).ReplaceUsesWith(instr.Arg(1))
+		it.Remove()
+		return
+	}
+
+	n, ok := ir2.Log2Value(instr.Arg(1).Const())
+	if !ok {
+		// TODO: can use multiple shifts and adds to calculate this
+		return
+	}
+
+	instr.Update(op.ShiftLeft, instr.Def(0).Type, instr.Arg(0), instr.Func().ValueFor(instr.Arg(1).Type, n))
+	it.Changed()
+}
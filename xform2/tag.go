@@ -7,9 +7,22 @@ const (
 	HasFramePointer
 	LoadStoreOffset
 
+	// HasHardwareMul is set when the arch can multiply in hardware,
+	// otherwise multiplies are calls into the runtime
+	HasHardwareMul
+
+	// HasHardwareDiv is set when the arch can divide in hardware,
+	// otherwise divides and remainders are calls into the runtime
+	HasHardwareDiv
+
 	// ...
 
 	NumTags
 )
 
 var activeTags []bool
+
+// HasTag returns whether the current arch has the tag
+func HasTag(tag Tag) bool {
+	return activeTags[tag]
+}
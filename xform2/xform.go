@@ -1,17 +1,19 @@
 package xform2
 
 import (
-	"log"
+	"go/token"
 	"reflect"
 	"runtime"
 
+	"github.com/rj45/nanogo/diag"
 	"github.com/rj45/nanogo/ir2"
 )
 
 type Pass int
 
 const (
-	Elaboration Pass = iota
+	Inlining Pass = iota
+	Elaboration
 	Simplification
 	Lowering
 	Legalization
@@ -28,6 +30,7 @@ type desc struct {
 	op       ir2.Op
 	once     bool
 	disabled bool
+	arch     bool
 	fn       func(ir2.Iter)
 }
 
@@ -65,12 +68,16 @@ func Once() Option {
 
 var xformers []desc
 
+// registeringArch is set while the arch is registering its xforms
+var registeringArch bool
+
 // Register an xform function
 func Register(fn func(ir2.Iter), options ...Option) int {
 	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
 	xformers = append(xformers, desc{
 		name: name,
 		fn:   fn,
+		arch: registeringArch,
 	})
 	d := &xformers[len(xformers)-1]
 
@@ -99,11 +106,13 @@ func Transform(pass Pass, fn *ir2.Func) {
 		for ; it.HasNext(); it.Next() {
 			// run the xforms specific to the current op
 			op := it.Instr().Op
+			pos := it.Instr().Pos
 			for _, xform := range opXforms[op] {
 				perform(xform, iter)
 
 				if iter.Instr() == nil {
-					log.Panicf("xform %s in pass %v left iter in nil state", xform.name, pass)
+					diag.Errorf(pos, "internal compiler error: xform %s in pass %v left iter in nil state in %s", xform.name, pass, fn.FullName)
+					return
 				}
 
 				if iter.Instr().Op != op {
@@ -115,7 +124,8 @@ func Transform(pass Pass, fn *ir2.Func) {
 			for _, xform := range otherXforms {
 				perform(xform, iter)
 				if iter.Instr() == nil {
-					log.Panicf("xform %s in pass %v left iter in nil state", xform.name, pass)
+					diag.Errorf(pos, "internal compiler error: xform %s in pass %v left iter in nil state in %s", xform.name, pass, fn.FullName)
+					return
 				}
 			}
 		}
@@ -126,7 +136,8 @@ func Transform(pass Pass, fn *ir2.Func) {
 
 		tries++
 		if tries > 1000 {
-			log.Panicf("transforms do not terminate: pass: %d active: %v", pass, active)
+			diag.Errorf(token.NoPos, "internal compiler error: transforms do not terminate in %s: pass: %d active: %v", fn.FullName, pass, active)
+			return
 		}
 	}
 }
@@ -167,6 +178,9 @@ next:
 			}
 		}
 
+		// once xforms run once for each func
+		xformers[i].disabled = false
+
 		if xf.op != nil {
 			opXforms[xf.op] = append(opXforms[xf.op], &xformers[i])
 		} else if xf.once {